@@ -0,0 +1,66 @@
+// Package aper implements the ASN.1 aligned PER (X.691) primitives needed by the E2AP and E2SM codecs.
+// Where the asn1c skeletons of the e2ap and e2sm wrappers depart from X.691 the encoding follows asn1c,
+// so that kpimon stays interoperable with the E2 nodes and RIC components built on them:
+//
+//   - a constrained whole number with a range of 129 to 256 values is octet-aligned
+//   - a constrained whole number with a range above 65536 values has its octet count
+//     encoded in the fewest bits able to hold the octet count of the range
+//   - strings of up to 2 octets (bit strings: up to 2 bits) are not octet-aligned
+package aper
+
+import (
+	"errors"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	MAX_FRAGMENT = 16384 //largest number of units in one length determinant, above it the content is fragmented
+	UNBOUNDED    = -1    //upper bound of a SIZE or value range without upper bound
+)
+
+var (
+	ErrTruncated  = errors.New("APER data truncated")
+	ErrRange      = errors.New("APER value out of the constraint range")
+	ErrLength     = errors.New("APER length out of the constraint range")
+	ErrCharacter  = errors.New("APER character not allowed by the string type")
+	ErrExtension  = errors.New("APER extension not supported")
+	ErrFragmented = errors.New("APER fragmented length not supported")
+	ErrTooLarge   = errors.New("APER value too large")
+)
+
+// rangeBits returns the number of bits needed to hold ub-lb, as asn1c computes range_bits
+func rangeBits(lb int64, ub int64) uint {
+	return uint(bits.Len64(uint64(ub - lb)))
+}
+
+// lengthBits returns the smallest i >= 1 such that 2^i >= n
+func lengthBits(n int64) uint {
+	i := uint(1)
+	for int64(1)<<i < n {
+		i++
+	}
+	return i
+}
+
+func printable(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	switch c {
+	case ' ', '\'', '(', ')', '+', ',', '-', '.', '/', ':', '=', '?':
+		return true
+	}
+	return false
+}
+
+// CheckPrintable returns ErrCharacter if s holds a character outside the PrintableString alphabet
+func CheckPrintable(s string) error {
+	for i := 0; i < len(s); i++ {
+		if !printable(s[i]) {
+			return errors.New("APER character " + strconv.QuoteRune(rune(s[i])) + " not allowed in PrintableString")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,585 @@
+package aper
+
+import (
+	"math"
+	"strconv"
+)
+
+// Decoder reads APER written by Encoder or by the asn1c skeletons. Every length read is checked
+// against the data left, so that a corrupt or hostile message cannot make the caller allocate more
+// than the message could hold.
+type Decoder struct {
+	buf []byte
+	pos uint //bit position in buf
+}
+
+func NewDecoder(buf []byte) *Decoder {
+	return &Decoder{buf: buf}
+}
+
+// Remaining returns the number of bits left
+func (d *Decoder) Remaining() uint {
+	return uint(len(d.buf))*8 - d.pos
+}
+
+// GetBits reads n bits, at most 64, most significant first
+func (d *Decoder) GetBits(n uint) (uint64, error) {
+	if n > 64 {
+		return 0, ErrTooLarge
+	}
+	if n > d.Remaining() {
+		return 0, ErrTruncated
+	}
+	var value uint64
+	for n > 0 {
+		current := d.buf[d.pos/8]
+		offset := d.pos % 8
+		take := 8 - offset
+		if n < take {
+			take = n
+		}
+		chunk := (current >> (8 - offset - take)) & byte(1<<take-1)
+		value = value<<take | uint64(chunk)
+		d.pos += take
+		n -= take
+	}
+	return value, nil
+}
+
+func (d *Decoder) GetBool() (bool, error) {
+	bit, err := d.GetBits(1)
+	return bit == 1, err
+}
+
+// Align skips the bits up to the next octet boundary
+func (d *Decoder) Align() error {
+	if d.pos%8 != 0 {
+		d.pos += 8 - d.pos%8
+	}
+	if d.pos > uint(len(d.buf))*8 {
+		return ErrTruncated
+	}
+	return nil
+}
+
+// GetOctets reads n raw octets at the current position
+func (d *Decoder) GetOctets(n int) ([]byte, error) {
+	if n < 0 || uint(n)*8 > d.Remaining() {
+		return nil, ErrTruncated
+	}
+	octets := make([]byte, n)
+	if d.pos%8 == 0 {
+		copy(octets, d.buf[d.pos/8:])
+		d.pos += uint(n) * 8
+		return octets, nil
+	}
+	for i := range octets {
+		o, err := d.GetBits(8)
+		if err != nil {
+			return nil, err
+		}
+		octets[i] = byte(o)
+	}
+	return octets, nil
+}
+
+// GetConstrainedWholeNumber reads a value in lb..ub written without extension bit
+func (d *Decoder) GetConstrainedWholeNumber(lb int64, ub int64) (int64, error) {
+	rb := rangeBits(lb, ub)
+	var offset uint64
+	var err error
+	switch {
+	case rb < 8:
+		offset, err = d.GetBits(rb)
+	case rb == 8:
+		if err = d.Align(); err == nil {
+			offset, err = d.GetBits(8)
+		}
+	case rb <= 16:
+		if err = d.Align(); err == nil {
+			offset, err = d.GetBits(16)
+		}
+	default:
+		var n uint64
+		if n, err = d.GetBits(lengthBits(int64((rb + 7) / 8))); err != nil {
+			return 0, err
+		}
+		if n+1 > 8 {
+			return 0, ErrTooLarge
+		}
+		if err = d.Align(); err == nil {
+			offset, err = d.GetBits(uint(n+1) * 8)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if offset > uint64(ub-lb) {
+		return 0, ErrRange
+	}
+	return lb + int64(offset), nil
+}
+
+// GetInteger reads an INTEGER constrained to lb..ub, ub UNBOUNDED for a semi-constrained one
+func (d *Decoder) GetInteger(lb int64, ub int64, ext bool) (int64, error) {
+	if ext {
+		outside, err := d.GetBool()
+		if err != nil {
+			return 0, err
+		}
+		if outside {
+			return d.GetUnconstrainedInteger()
+		}
+	}
+	if ub == UNBOUNDED {
+		offset, err := d.GetSemiConstrainedWholeNumber()
+		if err != nil {
+			return 0, err
+		}
+		if offset > math.MaxInt64-uint64(lb) {
+			return 0, ErrTooLarge
+		}
+		return lb + int64(offset), nil
+	}
+	return d.GetConstrainedWholeNumber(lb, ub)
+}
+
+// GetSemiConstrainedWholeNumber reads the offset of a semi-constrained INTEGER from its lower bound
+func (d *Decoder) GetSemiConstrainedWholeNumber() (uint64, error) {
+	n, err := d.getUnconstrainedLength()
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > 8 {
+		return 0, ErrTooLarge
+	}
+	return d.GetBits(uint(n) * 8)
+}
+
+// GetUnconstrainedInteger reads a two's complement INTEGER of up to 8 octets
+func (d *Decoder) GetUnconstrainedInteger() (int64, error) {
+	n, err := d.getUnconstrainedLength()
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > 8 {
+		return 0, ErrTooLarge
+	}
+	raw, err := d.GetBits(uint(n) * 8)
+	if err != nil {
+		return 0, err
+	}
+	shift := uint(64 - n*8)
+	return int64(raw<<shift) >> shift, nil
+}
+
+// GetLength reads a length determinant with a SIZE range of lb..ub, ub UNBOUNDED if none.
+// Fragmented lengths are only supported by GetFragmented.
+func (d *Decoder) GetLength(lb int64, ub int64) (int, error) {
+	if ub != UNBOUNDED && ub-lb+1 <= 65536 {
+		v, err := d.getNonNegativeWholeNumber(ub - lb + 1)
+		if err != nil {
+			return 0, err
+		}
+		if lb+v > ub {
+			return 0, ErrLength
+		}
+		return int(lb + v), nil
+	}
+	n, err := d.getUnconstrainedLength()
+	if err != nil {
+		return 0, err
+	}
+	if int64(n) < lb || (ub != UNBOUNDED && int64(n) > ub) {
+		return 0, ErrLength
+	}
+	return n, nil
+}
+
+func (d *Decoder) getNonNegativeWholeNumber(rng int64) (int64, error) {
+	var v uint64
+	var err error
+	switch {
+	case rng <= 255:
+		v, err = d.GetBits(lengthBits(rng))
+	case rng == 256:
+		if err = d.Align(); err == nil {
+			v, err = d.GetBits(8)
+		}
+	default:
+		if err = d.Align(); err == nil {
+			v, err = d.GetBits(16)
+		}
+	}
+	return int64(v), err
+}
+
+func (d *Decoder) getUnconstrainedLength() (int, error) {
+	n, fragment, err := d.getLengthOrFragment()
+	if err != nil {
+		return 0, err
+	}
+	if fragment {
+		return 0, ErrFragmented
+	}
+	return n, nil
+}
+
+// getLengthOrFragment reads an unconstrained length determinant, fragment telling whether
+// more length determinants follow the n units
+func (d *Decoder) getLengthOrFragment() (n int, fragment bool, err error) {
+	if err = d.Align(); err != nil {
+		return 0, false, err
+	}
+	first, err := d.GetBits(8)
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case first&0x80 == 0:
+		return int(first), false, nil
+	case first&0x40 == 0:
+		second, err := d.GetBits(8)
+		return int(first&0x3F)<<8 | int(second), false, err
+	}
+	m := int(first & 0x3F)
+	if m < 1 || m > 4 {
+		return 0, false, ErrLength
+	}
+	return m * MAX_FRAGMENT, true, nil
+}
+
+// GetFragmented reads an unconstrained length determinant and the units of unitBits bits it counts,
+// following fragments. It returns the content, left-aligned, and its size in units.
+func (d *Decoder) GetFragmented(unitBits uint) ([]byte, int, error) {
+	var content []byte
+	total := 0
+	for {
+		n, fragment, err := d.getLengthOrFragment()
+		if err != nil {
+			return nil, 0, err
+		}
+		if uint(n)*unitBits > d.Remaining() {
+			return nil, 0, ErrTruncated
+		}
+		if content, err = d.appendUnits(content, total, n, unitBits); err != nil {
+			return nil, 0, err
+		}
+		total += n
+		if !fragment {
+			return content, total, nil
+		}
+	}
+}
+
+// appendUnits reads count units and appends them after the first have units of content
+func (d *Decoder) appendUnits(content []byte, have int, count int, unitBits uint) ([]byte, error) {
+	if unitBits == 8 {
+		octets, err := d.GetOctets(count)
+		if err != nil {
+			return nil, err
+		}
+		return append(content, octets...), nil
+	}
+	if uint(count)*unitBits > d.Remaining() {
+		return nil, ErrTruncated
+	}
+	for i := have * int(unitBits); i < (have+count)*int(unitBits); i++ {
+		bit, _ := d.GetBits(1)
+		if i%8 == 0 {
+			content = append(content, 0)
+		}
+		content[i/8] |= byte(bit) << (7 - uint(i%8))
+	}
+	return content, nil
+}
+
+// GetNormallySmallNumber reads a number that is normally below 64
+func (d *Decoder) GetNormallySmallNumber() (int, error) {
+	large, err := d.GetBool()
+	if err != nil {
+		return 0, err
+	}
+	if !large {
+		n, err := d.GetBits(6)
+		return int(n), err
+	}
+	n, err := d.GetSemiConstrainedWholeNumber()
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 {
+		return 0, ErrTooLarge
+	}
+	return int(n), nil
+}
+
+// GetNormallySmallLength reads a length of at least 1, normally not above 64
+func (d *Decoder) GetNormallySmallLength() (int, error) {
+	large, err := d.GetBool()
+	if err != nil {
+		return 0, err
+	}
+	if !large {
+		n, err := d.GetBits(6)
+		return int(n) + 1, err
+	}
+	return d.getUnconstrainedLength()
+}
+
+// GetEnumerated reads the index of an ENUMERATED value among the count root values,
+// an index of count or more being an extension value
+func (d *Decoder) GetEnumerated(count int, ext bool) (int, error) {
+	if ext {
+		extended, err := d.GetBool()
+		if err != nil {
+			return 0, err
+		}
+		if extended {
+			n, err := d.GetNormallySmallNumber()
+			return count + n, err
+		}
+	}
+	if count <= 1 {
+		return 0, nil
+	}
+	index, err := d.GetBits(rangeBits(0, int64(count-1)))
+	if err != nil {
+		return 0, err
+	}
+	if int(index) >= count {
+		return 0, ErrRange
+	}
+	return int(index), nil
+}
+
+// GetChoice reads the index of a CHOICE alternative. An index of count or more is an extension
+// alternative whose value must then be read with GetOpenType.
+func (d *Decoder) GetChoice(count int, ext bool) (int, error) {
+	return d.GetEnumerated(count, ext)
+}
+
+// GetSequenceOf reads the number of items of a SEQUENCE OF. Each item taking at least minItemBits,
+// a count the data left cannot hold is rejected before the caller allocates the items.
+func (d *Decoder) GetSequenceOf(lb int64, ub int64, ext bool, minItemBits uint) (int, error) {
+	outside := false
+	if ext {
+		var err error
+		if outside, err = d.GetBool(); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if outside {
+		n, err = d.GetLength(0, UNBOUNDED)
+	} else {
+		n, err = d.GetLength(lb, ub)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if minItemBits > 0 && uint(n)*minItemBits > d.Remaining() {
+		return 0, ErrTruncated
+	}
+	return n, nil
+}
+
+// GetOctetString reads an OCTET STRING with a SIZE constraint of lb..ub
+func (d *Decoder) GetOctetString(lb int64, ub int64, ext bool) ([]byte, error) {
+	v, _, err := d.getString(8, lb, ub, ext)
+	return v, err
+}
+
+// GetBitString reads a BIT STRING with a SIZE constraint of lb..ub, returning its bits left-aligned and their number
+func (d *Decoder) GetBitString(lb int64, ub int64, ext bool) ([]byte, int, error) {
+	return d.getString(1, lb, ub, ext)
+}
+
+// GetPrintableString reads a PrintableString with a SIZE constraint of lb..ub
+func (d *Decoder) GetPrintableString(lb int64, ub int64, ext bool) (string, error) {
+	v, _, err := d.getString(8, lb, ub, ext)
+	if err != nil {
+		return "", err
+	}
+	s := string(v)
+	if err = CheckPrintable(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+func (d *Decoder) getString(unitBits uint, lb int64, ub int64, ext bool) ([]byte, int, error) {
+	outside := false
+	if ext {
+		var err error
+		if outside, err = d.GetBool(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if outside || ub == UNBOUNDED || ub-lb+1 > 65536 {
+		return d.GetFragmented(unitBits)
+	}
+	n := int(lb)
+	if lb != ub {
+		v, err := d.getNonNegativeWholeNumber(ub - lb + 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if lb+v > ub {
+			return nil, 0, ErrLength
+		}
+		n = int(lb + v)
+	}
+	if (n*int(unitBits)+7)/8 > 2 {
+		if err := d.Align(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if uint(n)*unitBits > d.Remaining() {
+		return nil, 0, ErrTruncated
+	}
+	content, err := d.appendUnits(make([]byte, 0, (n*int(unitBits)+7)/8), 0, n, unitBits)
+	return content, n, err
+}
+
+// GetOpenType reads an open type field and returns a Decoder over its contents
+func (d *Decoder) GetOpenType() (*Decoder, error) {
+	content, _, err := d.GetFragmented(8)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(content), nil
+}
+
+// GetExtensions reads the extension additions of a SEQUENCE whose extension bit was set, calling
+// addition with the index and contents of each one present. Unknown additions are skipped.
+func (d *Decoder) GetExtensions(addition func(index int, d *Decoder) error) error {
+	n, err := d.GetNormallySmallLength()
+	if err != nil {
+		return err
+	}
+	if uint(n) > d.Remaining() {
+		return ErrTruncated
+	}
+	present := make([]bool, n)
+	for i := range present {
+		if present[i], err = d.GetBool(); err != nil {
+			return err
+		}
+	}
+	for i := range present {
+		if !present[i] {
+			continue
+		}
+		inner, err := d.GetOpenType()
+		if err != nil {
+			return err
+		}
+		if err = addition(i, inner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReal reads a REAL written as length and X.690 contents octets
+func (d *Decoder) GetReal() (float64, error) {
+	n, err := d.getUnconstrainedLength()
+	if err != nil {
+		return 0, err
+	}
+	content, err := d.GetOctets(n)
+	if err != nil {
+		return 0, err
+	}
+	return parseReal(content)
+}
+
+// parseReal decodes the contents octets of a BER REAL (X.690 8.5)
+func parseReal(content []byte) (float64, error) {
+	if len(content) == 0 {
+		return 0, nil
+	}
+	first := content[0]
+	switch {
+	case first&0x80 != 0:
+		return parseBinaryReal(content)
+	case first&0xC0 == 0x40:
+		switch first {
+		case 0x40:
+			return math.Inf(1), nil
+		case 0x41:
+			return math.Inf(-1), nil
+		case 0x42:
+			return math.NaN(), nil
+		case 0x43:
+			return math.Copysign(0, -1), nil
+		}
+		return 0, ErrRange
+	}
+	return parseDecimalReal(content[1:])
+}
+
+func parseBinaryReal(content []byte) (float64, error) {
+	first := content[0]
+	var base float64
+	switch (first >> 4) & 0x03 {
+	case 0:
+		base = 2
+	case 1:
+		base = 8
+	case 2:
+		base = 16
+	default:
+		return 0, ErrRange
+	}
+	scale := int((first >> 2) & 0x03)
+	rest := content[1:]
+	expOctets := int(first&0x03) + 1
+	if first&0x03 == 0x03 {
+		if len(rest) == 0 {
+			return 0, ErrTruncated
+		}
+		expOctets = int(rest[0])
+		rest = rest[1:]
+	}
+	if expOctets < 1 || expOctets > 4 || len(rest) < expOctets {
+		return 0, ErrTruncated
+	}
+	exp := int64(int8(rest[0]))
+	for _, o := range rest[1:expOctets] {
+		exp = exp<<8 | int64(o)
+	}
+	rest = rest[expOctets:]
+	if len(rest) > 8 {
+		return 0, ErrTooLarge
+	}
+	var mantissa uint64
+	for _, o := range rest {
+		mantissa = mantissa<<8 | uint64(o)
+	}
+	value := float64(mantissa) * math.Pow(2, float64(scale)) * math.Pow(base, float64(exp))
+	if first&0x40 != 0 {
+		value = -value
+	}
+	return value, nil
+}
+
+// parseDecimalReal decodes the ISO 6093 NR1, NR2 and NR3 forms
+func parseDecimalReal(text []byte) (float64, error) {
+	s := make([]byte, 0, len(text))
+	for _, c := range text {
+		switch {
+		case c == ',':
+			s = append(s, '.')
+		case c != ' ':
+			s = append(s, c)
+		}
+	}
+	value, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		return 0, ErrRange
+	}
+	return value, nil
+}
@@ -0,0 +1,411 @@
+package aper
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Encoder writes APER bit by bit. The Put methods take the PER-visible constraints of the type being
+// encoded: lb and ub are the bounds of the value or SIZE range (ub UNBOUNDED for none) and ext tells
+// whether the constraint is extensible.
+type Encoder struct {
+	buf   []byte
+	nbits uint //bits used in buf
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{buf: make([]byte, 0, 64)}
+}
+
+// Bytes returns the complete encoding, padded to an octet. An empty encoding is one zero octet (X.691 11.1).
+func (e *Encoder) Bytes() []byte {
+	if len(e.buf) == 0 {
+		return []byte{0}
+	}
+	return e.buf
+}
+
+// Len returns the number of bits written so far
+func (e *Encoder) Len() uint {
+	return e.nbits
+}
+
+// PutBits writes the n low-order bits of value, most significant first
+func (e *Encoder) PutBits(value uint64, n uint) {
+	for n > 0 {
+		if e.nbits%8 == 0 {
+			e.buf = append(e.buf, 0)
+		}
+		free := 8 - e.nbits%8
+		take := free
+		if n < take {
+			take = n
+		}
+		chunk := byte(value>>(n-take)) & byte(1<<take-1)
+		e.buf[len(e.buf)-1] |= chunk << (free - take)
+		e.nbits += take
+		n -= take
+	}
+}
+
+func (e *Encoder) PutBool(b bool) {
+	if b {
+		e.PutBits(1, 1)
+	} else {
+		e.PutBits(0, 1)
+	}
+}
+
+// Align pads with zero bits up to the next octet boundary
+func (e *Encoder) Align() {
+	e.nbits = uint(len(e.buf)) * 8
+}
+
+// PutOctets writes raw octets at the current position
+func (e *Encoder) PutOctets(octets []byte) {
+	if e.nbits%8 == 0 {
+		e.buf = append(e.buf, octets...)
+		e.nbits += uint(len(octets)) * 8
+		return
+	}
+	for _, o := range octets {
+		e.PutBits(uint64(o), 8)
+	}
+}
+
+// PutConstrainedWholeNumber writes v in lb..ub without extension bit (X.691 11.5.7)
+func (e *Encoder) PutConstrainedWholeNumber(v int64, lb int64, ub int64) error {
+	if v < lb || v > ub {
+		return ErrRange
+	}
+	offset := uint64(v - lb)
+	rb := rangeBits(lb, ub)
+	switch {
+	case rb < 8:
+		e.PutBits(offset, rb)
+	case rb == 8:
+		e.Align()
+		e.PutBits(offset, 8)
+	case rb <= 16:
+		e.Align()
+		e.PutBits(offset, 16)
+	default:
+		n := (bits.Len64(offset) + 7) / 8
+		if n == 0 {
+			n = 1
+		}
+		e.PutBits(uint64(n-1), lengthBits(int64((rb+7)/8)))
+		e.Align()
+		e.PutBits(offset, uint(n)*8)
+	}
+	return nil
+}
+
+// PutInteger writes an INTEGER constrained to lb..ub, ub UNBOUNDED for a semi-constrained one.
+// A value outside an extensible range is written unconstrained after the extension bit.
+func (e *Encoder) PutInteger(v int64, lb int64, ub int64, ext bool) error {
+	inRange := v >= lb && (ub == UNBOUNDED || v <= ub)
+	if ext {
+		e.PutBool(!inRange)
+		if !inRange {
+			e.PutUnconstrainedInteger(v)
+			return nil
+		}
+	} else if !inRange {
+		return ErrRange
+	}
+	if ub == UNBOUNDED {
+		e.PutSemiConstrainedWholeNumber(uint64(v - lb))
+		return nil
+	}
+	return e.PutConstrainedWholeNumber(v, lb, ub)
+}
+
+// PutSemiConstrainedWholeNumber writes the offset of a value from the lower bound of a semi-constrained
+// INTEGER as length and minimal unsigned octets (X.691 11.7)
+func (e *Encoder) PutSemiConstrainedWholeNumber(offset uint64) {
+	n := (bits.Len64(offset) + 7) / 8
+	if n == 0 {
+		n = 1
+	}
+	e.putUnconstrainedLength(n)
+	e.PutBits(offset, uint(n)*8)
+}
+
+// PutUnconstrainedInteger writes v as length and minimal two's complement octets (X.691 11.8)
+func (e *Encoder) PutUnconstrainedInteger(v int64) {
+	n := 1
+	for n < 8 && (v < -(1<<(uint(n)*8-1)) || v >= 1<<(uint(n)*8-1)) {
+		n++
+	}
+	e.putUnconstrainedLength(n)
+	e.PutBits(uint64(v), uint(n)*8)
+}
+
+// PutLength writes a length determinant for n units with a SIZE range of lb..ub, ub UNBOUNDED if none.
+// It returns ErrFragmented for an unconstrained length of MAX_FRAGMENT units or more,
+// use PutFragmented to write such content.
+func (e *Encoder) PutLength(n int, lb int64, ub int64) error {
+	if int64(n) < lb || (ub != UNBOUNDED && int64(n) > ub) {
+		return ErrLength
+	}
+	if ub != UNBOUNDED && ub-lb+1 <= 65536 {
+		e.putNonNegativeWholeNumber(int64(n)-lb, ub-lb+1)
+		return nil
+	}
+	if n >= MAX_FRAGMENT {
+		return ErrFragmented
+	}
+	e.putUnconstrainedLength(n)
+	return nil
+}
+
+// putNonNegativeWholeNumber writes a number in 0..rng-1 as asn1c aper_put_nsnnwn does
+func (e *Encoder) putNonNegativeWholeNumber(v int64, rng int64) {
+	switch {
+	case rng <= 255:
+		e.PutBits(uint64(v), lengthBits(rng))
+	case rng == 256:
+		e.Align()
+		e.PutBits(uint64(v), 8)
+	default:
+		e.Align()
+		e.PutBits(uint64(v), 16)
+	}
+}
+
+// putUnconstrainedLength writes a length below MAX_FRAGMENT (X.691 11.9.3.6, 11.9.3.7)
+func (e *Encoder) putUnconstrainedLength(n int) {
+	e.Align()
+	if n <= 127 {
+		e.PutBits(uint64(n), 8)
+	} else {
+		e.PutBits(uint64(n)|0x8000, 16)
+	}
+}
+
+// PutFragmented writes an unconstrained length determinant and content of n units of unitBits bits each,
+// splitting it in fragments of up to 4*MAX_FRAGMENT units when needed (X.691 11.9.3.8)
+func (e *Encoder) PutFragmented(content []byte, n int, unitBits uint) {
+	offset := 0
+	for {
+		remaining := n - offset
+		if remaining < MAX_FRAGMENT {
+			e.putUnconstrainedLength(remaining)
+			e.putUnits(content, offset, remaining, unitBits)
+			return
+		}
+		m := remaining / MAX_FRAGMENT
+		if m > 4 {
+			m = 4
+		}
+		e.Align()
+		e.PutBits(uint64(0xC0|m), 8)
+		e.putUnits(content, offset, m*MAX_FRAGMENT, unitBits)
+		offset += m * MAX_FRAGMENT
+	}
+}
+
+// putUnits writes count units of content starting at unit offset
+func (e *Encoder) putUnits(content []byte, offset int, count int, unitBits uint) {
+	if unitBits == 8 {
+		e.PutOctets(content[offset : offset+count])
+		return
+	}
+	for i := offset * int(unitBits); i < (offset+count)*int(unitBits); i++ {
+		e.PutBits(uint64(content[i/8]>>(7-uint(i%8))&1), 1)
+	}
+}
+
+// PutNormallySmallNumber writes a number that is normally below 64 (X.691 11.6)
+func (e *Encoder) PutNormallySmallNumber(n int) {
+	if n < 64 {
+		e.PutBits(uint64(n), 7)
+		return
+	}
+	e.PutBits(1, 1)
+	e.PutSemiConstrainedWholeNumber(uint64(n))
+}
+
+// PutNormallySmallLength writes a length of at least 1, normally not above 64 (X.691 11.9.3.4)
+func (e *Encoder) PutNormallySmallLength(n int) {
+	if n <= 64 {
+		e.PutBits(uint64(n-1), 7)
+		return
+	}
+	e.PutBits(1, 1)
+	e.putUnconstrainedLength(n)
+}
+
+// PutEnumerated writes the index of an ENUMERATED value among the count root values,
+// an index of count or more being an extension value
+func (e *Encoder) PutEnumerated(index int, count int, ext bool) error {
+	if index < 0 || (!ext && index >= count) {
+		return ErrRange
+	}
+	if ext {
+		e.PutBool(index >= count)
+		if index >= count {
+			e.PutNormallySmallNumber(index - count)
+			return nil
+		}
+	}
+	if count > 1 {
+		e.PutBits(uint64(index), rangeBits(0, int64(count-1)))
+	}
+	return nil
+}
+
+// PutChoice writes the index of a CHOICE alternative among the count root alternatives. An index of
+// count or more is an extension alternative whose value must then be written with PutOpenType.
+func (e *Encoder) PutChoice(index int, count int, ext bool) error {
+	return e.PutEnumerated(index, count, ext)
+}
+
+// PutSequenceOf writes the number of items of a SEQUENCE OF
+func (e *Encoder) PutSequenceOf(n int, lb int64, ub int64, ext bool) error {
+	inRange := int64(n) >= lb && (ub == UNBOUNDED || int64(n) <= ub)
+	if ext {
+		e.PutBool(!inRange)
+		if !inRange {
+			return e.PutLength(n, 0, UNBOUNDED)
+		}
+	}
+	return e.PutLength(n, lb, ub)
+}
+
+// PutOctetString writes an OCTET STRING with a SIZE constraint of lb..ub
+func (e *Encoder) PutOctetString(v []byte, lb int64, ub int64, ext bool) error {
+	return e.putString(v, len(v), 8, lb, ub, ext)
+}
+
+// PutBitString writes the first n bits of v as a BIT STRING with a SIZE constraint of lb..ub
+func (e *Encoder) PutBitString(v []byte, n int, lb int64, ub int64, ext bool) error {
+	if (n+7)/8 > len(v) {
+		return ErrLength
+	}
+	return e.putString(v, n, 1, lb, ub, ext)
+}
+
+// PutPrintableString writes a PrintableString with a SIZE constraint of lb..ub
+func (e *Encoder) PutPrintableString(s string, lb int64, ub int64, ext bool) error {
+	if err := CheckPrintable(s); err != nil {
+		return err
+	}
+	return e.putString([]byte(s), len(s), 8, lb, ub, ext)
+}
+
+// putString writes n units of unitBits bits of v as asn1c OCTET_STRING_encode_aper does: a string
+// within a SIZE range of up to 65536 values is octet-aligned when it holds more than 2 octets
+func (e *Encoder) putString(v []byte, n int, unitBits uint, lb int64, ub int64, ext bool) error {
+	inRange := int64(n) >= lb && (ub == UNBOUNDED || int64(n) <= ub)
+	if ext {
+		e.PutBool(!inRange)
+	} else if !inRange {
+		return ErrLength
+	}
+	if !inRange || ub == UNBOUNDED || ub-lb+1 > 65536 {
+		e.PutFragmented(v, n, unitBits)
+		return nil
+	}
+	if lb != ub {
+		e.putNonNegativeWholeNumber(int64(n)-lb, ub-lb+1)
+	}
+	if (n*int(unitBits)+7)/8 > 2 {
+		e.Align()
+	}
+	e.putUnits(v, 0, n, unitBits)
+	return nil
+}
+
+// PutOpenType writes the complete encoding of a value as an open type field (X.691 11.2)
+func (e *Encoder) PutOpenType(value func(e *Encoder) error) error {
+	inner := NewEncoder()
+	if err := value(inner); err != nil {
+		return err
+	}
+	content := inner.Bytes()
+	e.PutFragmented(content, len(content), 8)
+	return nil
+}
+
+// PutExtensions writes the extension additions of a SEQUENCE, after its root components. additions
+// holds one entry per extension addition defined, nil for the absent ones; the extension bit written
+// before the root components must be set as HasExtensions tells.
+func (e *Encoder) PutExtensions(additions []func(e *Encoder) error) error {
+	if !HasExtensions(additions) {
+		return nil
+	}
+	e.PutNormallySmallLength(len(additions))
+	for _, addition := range additions {
+		e.PutBool(addition != nil)
+	}
+	for _, addition := range additions {
+		if addition == nil {
+			continue
+		}
+		if err := e.PutOpenType(addition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasExtensions tells whether some extension addition is present
+func HasExtensions(additions []func(e *Encoder) error) bool {
+	for _, addition := range additions {
+		if addition != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PutReal writes a REAL as length and X.690 contents octets, using the base 2 binary encoding
+// with an odd mantissa for finite values (X.691 11.3)
+func (e *Encoder) PutReal(f float64) {
+	content := realContents(f)
+	e.putUnconstrainedLength(len(content))
+	e.PutOctets(content)
+}
+
+func realContents(f float64) []byte {
+	switch {
+	case f == 0 && !math.Signbit(f):
+		return nil
+	case f == 0:
+		return []byte{0x43}
+	case math.IsInf(f, 1):
+		return []byte{0x40}
+	case math.IsInf(f, -1):
+		return []byte{0x41}
+	case math.IsNaN(f):
+		return []byte{0x42}
+	}
+	first := byte(0x80)
+	if f < 0 {
+		first |= 0x40
+		f = -f
+	}
+	frac, exp := math.Frexp(f)
+	mantissa := uint64(frac * (1 << 53))
+	exp -= 53
+	for mantissa&1 == 0 {
+		mantissa >>= 1
+		exp++
+	}
+	expOctets := 1
+	for expOctets < 4 && (exp < -(1<<(uint(expOctets)*8-1)) || exp >= 1<<(uint(expOctets)*8-1)) {
+		expOctets++
+	}
+	content := []byte{first | byte(expOctets-1)}
+	for i := expOctets - 1; i >= 0; i-- {
+		content = append(content, byte(exp>>(uint(i)*8)))
+	}
+	var m []byte
+	for mantissa > 0 {
+		m = append([]byte{byte(mantissa)}, m...)
+		mantissa >>= 8
+	}
+	return append(content, m...)
+}
@@ -1,11 +1,256 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/dataset"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/envelope"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/scenario"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dataset" {
+		os.Exit(runDataset(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		os.Exit(runKeygen(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "submgr" {
+		os.Exit(runSubmgr(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "e2mgr" {
+		os.Exit(runE2mgr(os.Args[2:]))
+	}
+
 	c := control.NewControl()
+	defer c.Ledger().Close()
+
+	var engine *scenario.Engine
+	if file := os.Getenv("scenarioFile"); file != "" {
+		if engine = startScenario(&c, file); engine != nil {
+			defer engine.Stop()
+		}
+	}
+	if addr := os.Getenv("adminAddr"); addr != "" {
+		admin := control.NewAdminServer(addr, &c)
+		url, err := admin.Start()
+		if err != nil {
+			log.Printf("Failed to start admin server on %s, exiting: %v", addr, err)
+			if engine != nil {
+				engine.Stop()
+			}
+			c.Ledger().Close()
+			os.Exit(1)
+		}
+		log.Printf("Admin server listening on %s", url)
+		defer admin.Close()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, deleting the subscriptions before exiting", sig)
+		if engine != nil {
+			engine.Stop()
+		}
+		c.Shutdown()
+		c.Ledger().Close()
+		os.Exit(0)
+	}()
+
 	c.Run()
 }
 
+// startScenario runs the campaigns of the scenario file, recording their writes in the ledger of c
+// along with the plausibility rules they violate
+func startScenario(c *control.Control, file string) *scenario.Engine {
+	s, err := scenario.LoadFile(file)
+	if err != nil {
+		log.Printf("Failed to load scenario file %s: %v", file, err)
+		return nil
+	}
+	engine := scenario.NewEngine(c.Store(), s, c.Ledger(), c.LastIndicationSN, c.Validator())
+	engine.Start()
+	return engine
+}
+
+// runDataset merges ledger files (JSONL or SQLite) into a labelled dataset:
+//
+//	kpimon dataset [-csv out.csv] [-parquet out.parquet] [-failed] ledger...
+func runDataset(args []string) int {
+	flags := flag.NewFlagSet("dataset", flag.ContinueOnError)
+	csvFile := flags.String("csv", "", "write the dataset as CSV to this file")
+	parquetFile := flags.String("parquet", "", "write the dataset as Parquet to this file")
+	includeFailed := flags.Bool("failed", false, "keep the writes that failed")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*csvFile == "" && *parquetFile == "") {
+		fmt.Fprintln(os.Stderr, "usage: kpimon dataset [-csv out.csv] [-parquet out.parquet] [-failed] ledger...")
+		return 2
+	}
+
+	var ledgers [][]*ledger.Record
+	for _, path := range flags.Args() {
+		records, err := ledger.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read ledger %s: %v\n", path, err)
+			return 1
+		}
+		ledgers = append(ledgers, records)
+	}
+	rows, err := dataset.Build(ledgers, *includeFailed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build dataset: %v\n", err)
+		return 1
+	}
+
+	outputs := []struct {
+		path  string
+		write func(*os.File, []*dataset.Row) error
+	}{
+		{*csvFile, func(f *os.File, rows []*dataset.Row) error { return dataset.WriteCSV(f, rows) }},
+		{*parquetFile, func(f *os.File, rows []*dataset.Row) error { return dataset.WriteParquet(f, rows) }},
+	}
+	for _, output := range outputs {
+		if output.path == "" {
+			continue
+		}
+		file, err := os.Create(output.path)
+		if err == nil {
+			err = output.write(file, rows)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write dataset %s: %v\n", output.path, err)
+			return 1
+		}
+	}
+	fmt.Printf("%d rows from %d ledgers\n", len(rows), len(ledgers))
+	return 0
+}
+
+// runSubmgr serves a stand-in of the subscription manager REST API, for running kpimon with
+// subscriptionBackend rest away from a RIC platform:
+//
+//	kpimon submgr [-addr :8088] [-fail ranName=cause]...
+func runSubmgr(args []string) int {
+	flags := flag.NewFlagSet("submgr", flag.ContinueOnError)
+	addr := flags.String("addr", ":8088", "listen address")
+	var failures stringList
+	flags.Var(&failures, "fail", "fail the subscriptions of an E2 node, as ranName=cause")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	standIn := control.NewSubmgrStandIn(*addr)
+	for _, failure := range failures {
+		kv := strings.SplitN(failure, "=", 2)
+		if len(kv) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: kpimon submgr [-addr :8088] [-fail ranName=cause]...")
+			return 2
+		}
+		standIn.Fail(kv[0], kv[1])
+	}
+	url, err := standIn.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start subscription manager stand-in: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Subscription manager stand-in serving on %s\n", url)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+	standIn.Close()
+	return 0
+}
+
+// runE2mgr serves a stub of the E2 manager REST API with connected E2 nodes, for running kpimon with
+// e2NodeDiscovery e2mgr away from a RIC platform:
+//
+//	kpimon e2mgr [-addr :3800] -node ranName[,nodeType[,plmnId]]...
+func runE2mgr(args []string) int {
+	flags := flag.NewFlagSet("e2mgr", flag.ContinueOnError)
+	addr := flags.String("addr", ":3800", "listen address")
+	var nodes stringList
+	flags.Var(&nodes, "node", "connected E2 node, as ranName[,nodeType[,plmnId]]")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	stub := control.NewE2ManagerStub(*addr)
+	for _, node := range nodes {
+		fields := strings.Split(node, ",")
+		e2Node := control.E2Node{RanName: fields[0], NodeType: "GNB"}
+		if len(fields) > 1 {
+			e2Node.NodeType = fields[1]
+		}
+		if len(fields) > 2 {
+			e2Node.GlobalNbID = &control.GlobalNbID{PlmnID: fields[2]}
+		}
+		stub.Connect(e2Node)
+	}
+	url, err := stub.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start E2 manager stub: %v\n", err)
+		return 1
+	}
+	fmt.Printf("E2 manager stub serving %d E2 nodes on %s\n", len(nodes), url)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+	stub.Close()
+	return 0
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runKeygen creates the key file of the signed envelopes and its public counterpart for consumer xApps:
+//
+//	kpimon keygen [-alg ed25519|hmac-sha256] [-writer kpimon] [-public public.json] keys.json
+func runKeygen(args []string) int {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	alg := flags.String("alg", envelope.ALG_ED25519, "signature algorithm, ed25519 or hmac-sha256")
+	writer := flags.String("writer", "kpimon", "writer id recorded in the envelopes")
+	publicFile := flags.String("public", "", "also write the key file without the private key to this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: kpimon keygen [-alg ed25519|hmac-sha256] [-writer kpimon] [-public public.json] keys.json")
+		return 2
+	}
+	keys, err := envelope.GenerateKeyFile(*alg, *writer)
+	if err == nil {
+		err = keys.Save(flags.Arg(0))
+	}
+	if err == nil && *publicFile != "" {
+		err = keys.Public().Save(*publicFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate keys: %v\n", err)
+		return 1
+	}
+	return 0
+}
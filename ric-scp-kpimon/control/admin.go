@@ -0,0 +1,217 @@
+package control
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminServer serves the administrative commands of kpimon over HTTP:
+//
+//	GET    /subscriptions            subscription state of every E2 node
+//	DELETE /subscriptions[/ranName]  delete the subscriptions, of every E2 node when no RAN name is given,
+//	                                 and answer once the E2 nodes responded or the deletion timed out
+//	POST   /subscriptions[/ranName]  subscribe the deleted and failed E2 nodes again
+//	GET    /nodes                    connected E2 nodes the E2 node discovery allows
+//	GET    /capabilities[/ranName]   what the KPM RAN function definition of the E2 nodes announced
+//	GET    /indications              RICindicationSN counters: gaps, late arrivals and duplicates
+//	GET    /messages                 received and unhandled messages per message type
+//	GET    /control                  RIC Control Requests per result
+//	POST   /control                  send the RIC Control Request of the body, see controlCommand, and answer
+//	                                 its outcome once the E2 node answered or the request timed out
+type AdminServer struct {
+	server  *http.Server
+	control *Control
+}
+
+func NewAdminServer(addr string, c *Control) *AdminServer {
+	a := &AdminServer{control: c}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions", a.serveSubscriptions)
+	mux.HandleFunc("/subscriptions/", a.serveSubscriptions)
+	mux.HandleFunc("/nodes", a.serveNodes)
+	mux.HandleFunc("/capabilities", a.serveCapabilities)
+	mux.HandleFunc("/capabilities/", a.serveCapabilities)
+	mux.HandleFunc("/indications", a.serveIndications)
+	mux.HandleFunc("/messages", a.serveMessages)
+	mux.HandleFunc("/control", a.serveControl)
+	a.server = &http.Server{Addr: addr, Handler: mux}
+	return a
+}
+
+// Start listens on the address of the server and returns the URL it serves on
+func (a *AdminServer) Start() (string, error) {
+	listener, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return "", err
+	}
+	go a.server.Serve(listener)
+	return "http://" + listener.Addr().String() + "/", nil
+}
+
+func (a *AdminServer) Close() error {
+	return a.server.Close()
+}
+
+func (a *AdminServer) serveSubscriptions(rw http.ResponseWriter, req *http.Request) {
+	var ranNames []string
+	if ranName := strings.Trim(strings.TrimPrefix(req.URL.Path, "/subscriptions"), "/"); ranName != "" {
+		ranNames = append(ranNames, ranName)
+	}
+	subscriptions := a.control.Subscriptions()
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(rw, subscriptions.Nodes())
+	case http.MethodDelete:
+		log.Printf("Admin command: delete subscriptions %v", ranNames)
+		writeJSON(rw, a.control.DeleteSubscriptions(ranNames))
+	case http.MethodPost:
+		if len(ranNames) == 0 {
+			ranNames = subscriptions.RanNames()
+		}
+		log.Printf("Admin command: resubscribe %v", ranNames)
+		resubscribed := []string{}
+		for _, ranName := range ranNames {
+			if subscriptions.Resubscribe(ranName) {
+				resubscribed = append(resubscribed, ranName)
+			}
+		}
+		writeJSON(rw, resubscribed)
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AdminServer) serveNodes(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if discovery := a.control.Discovery(); discovery != nil {
+		writeJSON(rw, discovery.Connected())
+	} else {
+		writeJSON(rw, a.control.Subscriptions().RanNames())
+	}
+}
+
+func (a *AdminServer) serveCapabilities(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nodes := a.control.Kpm()
+	if ranName := strings.Trim(strings.TrimPrefix(req.URL.Path, "/capabilities"), "/"); ranName != "" {
+		caps := nodes.Capabilities(ranName)
+		if caps == nil {
+			http.Error(rw, "no KPM RAN function known for "+ranName, http.StatusNotFound)
+			return
+		}
+		writeJSON(rw, caps)
+		return
+	}
+	capabilities := []*KpmCapabilities{}
+	for _, ranName := range a.control.Subscriptions().RanNames() {
+		if caps := nodes.Capabilities(ranName); caps != nil {
+			capabilities = append(capabilities, caps)
+		}
+	}
+	writeJSON(rw, capabilities)
+}
+
+func (a *AdminServer) serveIndications(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sequences := a.control.Sequences()
+	writeJSON(rw, map[string]interface{}{"counts": sequences.Counts(), "subscriptions": sequences.Stats()})
+}
+
+func (a *AdminServer) serveMessages(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(rw, map[string]interface{}{"counts": a.control.Messages().Counts(), "dead_letters": a.control.DeadLetters().Written()})
+}
+
+// controlCommand is the body of POST /control, its header, message and call process ID in hex
+type controlCommand struct {
+	RanName       string `json:"ran_name"`
+	FuncID        int    `json:"ran_function_id"`
+	Header        string `json:"header"`
+	Message       string `json:"message"`
+	CallProcessID string `json:"call_process_id"`
+	AckRequest    string `json:"ack_request"` //none, ack or nack, ack when left out
+	Timeout       string `json:"timeout"`     //e.g. 2s, the configured timeout when left out
+}
+
+func (cmd *controlCommand) request() (req *ControlRequest, err error) {
+	req = &ControlRequest{RanName: cmd.RanName, FuncID: cmd.FuncID, AckRequest: cmd.AckRequest}
+	if cmd.RanName == "" {
+		return nil, errors.New("ran_name is missing")
+	}
+	if req.Header, err = hex.DecodeString(cmd.Header); err != nil {
+		return nil, errors.New("header is not hex: " + err.Error())
+	}
+	if req.Message, err = hex.DecodeString(cmd.Message); err != nil {
+		return nil, errors.New("message is not hex: " + err.Error())
+	}
+	if cmd.CallProcessID != "" {
+		if req.CallProcessID, err = hex.DecodeString(cmd.CallProcessID); err != nil {
+			return nil, errors.New("call_process_id is not hex: " + err.Error())
+		}
+	}
+	if cmd.Timeout != "" {
+		if req.Timeout, err = time.ParseDuration(cmd.Timeout); err != nil || req.Timeout <= 0 {
+			return nil, errors.New("Invalid timeout: " + cmd.Timeout)
+		}
+	}
+	return req, nil
+}
+
+func (a *AdminServer) serveControl(rw http.ResponseWriter, req *http.Request) {
+	ricControl := a.control.RicControl()
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(rw, map[string]interface{}{"counts": ricControl.Counts(), "pending": ricControl.Pending()})
+	case http.MethodPost:
+		var cmd controlCommand
+		if err := json.NewDecoder(req.Body).Decode(&cmd); err != nil {
+			http.Error(rw, "invalid control command: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		request, err := cmd.request()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Admin command: RIC control of {%s}, RAN function %d", request.RanName, request.FuncID)
+		outcome, err := a.control.SendControl(request)
+		if outcome == nil {
+			http.Error(rw, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if err != nil {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusGatewayTimeout)
+			json.NewEncoder(rw).Encode(outcome)
+			return
+		}
+		writeJSON(rw, outcome)
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(v)
+}
@@ -0,0 +1,164 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// logAlertSink writes alerts to the xApp log and the log file
+type logAlertSink struct{}
+
+func NewLogAlertSink() AlertSink {
+	return logAlertSink{}
+}
+
+func (logAlertSink) Raise(alert *Alert) error {
+	xapp.Logger.Warn("Integrity alert: key [%s] %s by a foreign writer (event %s, expected %.12s, observed %.12s)", alert.Key, alert.Kind, alert.Event, alert.Expected, alert.Observed)
+	log.Printf("Integrity alert: key [%s] %s by a foreign writer (event %s, expected %.12s, observed %.12s)", alert.Key, alert.Kind, alert.Event, alert.Expected, alert.Observed)
+	return nil
+}
+
+// alarmMessage is the RIC_ALARM payload understood by the alarm manager
+type alarmMessage struct {
+	Alarm       alarm  `json:"alarm"`
+	AlarmAction string `json:"alarmAction"`
+	AlarmTime   int64  `json:"alarmTime"` //microseconds since the epoch
+}
+
+type alarm struct {
+	ManagedObjectId   string `json:"managedObjectId"`
+	ApplicationId     string `json:"applicationId"`
+	SpecificProblem   int    `json:"specificProblem"`
+	PerceivedSeverity string `json:"perceivedSeverity"`
+	IdentifyingInfo   string `json:"identifyingInfo"`
+	AdditionalInfo    string `json:"additionalInfo"`
+}
+
+// rmrAlertSink raises an alarm towards the alarm manager for every alert
+type rmrAlertSink struct {
+	transport Transport
+}
+
+func NewRmrAlertSink(transport Transport) AlertSink {
+	return &rmrAlertSink{transport}
+}
+
+func (s *rmrAlertSink) Raise(alert *Alert) error {
+	additionalInfo, _ := json.Marshal(alert)
+	payload, err := json.Marshal(&alarmMessage{
+		Alarm: alarm{
+			ManagedObjectId:   ALARM_MANAGED_OBJECT_ID,
+			ApplicationId:     ALARM_APPLICATION_ID,
+			SpecificProblem:   ALARM_FOREIGN_STORE_WRITE,
+			PerceivedSeverity: "MAJOR",
+			IdentifyingInfo:   "Metrics store key " + alert.Key + " " + alert.Kind + " by a foreign writer",
+			AdditionalInfo:    string(additionalInfo),
+		},
+		AlarmAction: "RAISE",
+		AlarmTime:   alert.Time.UnixNano() / int64(time.Microsecond),
+	})
+	if err != nil {
+		return err
+	}
+	params := &xapp.RMRParams{}
+	params.Mtype = RIC_ALARM
+	params.SubId = -1
+	params.Payload = payload
+	params.PayloadLen = len(payload)
+	return s.transport.Send(params)
+}
+
+// webhookAlertSink posts every alert as JSON to a URL
+type webhookAlertSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookAlertSink(url string) AlertSink {
+	return &webhookAlertSink{url, &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *webhookAlertSink) Raise(alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("Webhook answered with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// WebhookStandIn is a local receiver for the alert webhook, it logs the alerts posted to
+// it and serves the ones received so far on GET
+type WebhookStandIn struct {
+	server *http.Server
+	alerts []*Alert
+	mu     *sync.Mutex
+}
+
+func NewWebhookStandIn(addr string) *WebhookStandIn {
+	w := &WebhookStandIn{mu: &sync.Mutex{}}
+	w.server = &http.Server{Addr: addr, Handler: http.HandlerFunc(w.serve)}
+	return w
+}
+
+// Start listens on the address of the stand-in and returns the URL to post alerts to
+func (w *WebhookStandIn) Start() (string, error) {
+	listener, err := net.Listen("tcp", w.server.Addr)
+	if err != nil {
+		return "", err
+	}
+	go w.server.Serve(listener)
+	return "http://" + listener.Addr().String() + "/", nil
+}
+
+func (w *WebhookStandIn) Alerts() []*Alert {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*Alert(nil), w.alerts...)
+}
+
+func (w *WebhookStandIn) Close() error {
+	return w.server.Close()
+}
+
+func (w *WebhookStandIn) serve(rw http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(req.Body)
+		alert := &Alert{}
+		if err == nil {
+			err = json.Unmarshal(body, alert)
+		}
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.mu.Lock()
+		w.alerts = append(w.alerts, alert)
+		w.mu.Unlock()
+		log.Printf("Webhook stand-in received alert: %s", body)
+		rw.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(w.Alerts())
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
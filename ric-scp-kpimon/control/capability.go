@@ -0,0 +1,170 @@
+package control
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+)
+
+// KpmCapabilities is what an E2 node announced of its KPM RAN function in its E2 setup, read from the
+// RAN function definition of its E2SM-KPM version
+type KpmCapabilities struct {
+	RanName            string                  `json:"ran_name"`
+	RanFunctionID      int                     `json:"ran_function_id"`
+	Revision           int                     `json:"revision"`
+	Version            kpm.Version             `json:"version"`
+	Name               *kpm.RanFunctionName    `json:"name,omitempty"`
+	EventTriggerStyles []kpm.EventTriggerStyle `json:"event_trigger_styles,omitempty"`
+	ReportStyles       []kpm.ReportStyle       `json:"report_styles,omitempty"` //without action format nor measurements for KPM v1
+}
+
+// decodeKpmCapabilities reads the RAN function definition of an E2 node of a KPM version
+func decodeKpmCapabilities(ranName string, function KpmRanFunction, version kpm.Version, definition []byte) (*KpmCapabilities, error) {
+	c := &KpmCapabilities{RanName: ranName, RanFunctionID: function.ID, Revision: function.Revision, Version: version}
+	if len(definition) == 0 {
+		return c, errors.New("no RAN function definition")
+	}
+	if version == kpm.VERSION_1 {
+		var e2sm *E2sm
+		desc, err := e2sm.GetRanFunctionDescription(definition)
+		if err != nil {
+			return c, err
+		}
+		c.Name = &kpm.RanFunctionName{ShortName: desc.Name.ShortName, OID: desc.Name.E2SMOID, Description: desc.Name.Description}
+		if desc.Name.Instance >= 0 {
+			c.Name.Instance = &desc.Name.Instance
+		}
+		for _, style := range desc.EventTriggerStyles {
+			c.EventTriggerStyles = append(c.EventTriggerStyles, kpm.EventTriggerStyle{Type: style.StyleType, Name: style.StyleName, Format: style.FormatType})
+		}
+		for _, style := range desc.ReportStyles {
+			c.ReportStyles = append(c.ReportStyles, kpm.ReportStyle{Type: style.StyleType, Name: style.StyleName,
+				HeaderFormat: style.IndicationHeaderFormat, MessageFormat: style.IndicationMessageFormat})
+		}
+		return c, nil
+	}
+	desc, err := kpm.DecodeRanFunctionDescription(definition)
+	if err != nil {
+		return c, err
+	}
+	c.Name = &desc.Name
+	c.EventTriggerStyles = desc.EventTriggerStyles
+	c.ReportStyles = desc.ReportStyles
+	return c, nil
+}
+
+// ReportStyle returns the report style of a type the E2 node supports, nil when it does not
+func (c *KpmCapabilities) ReportStyle(styleType int64) *kpm.ReportStyle {
+	for i := range c.ReportStyles {
+		if c.ReportStyles[i].Type == styleType {
+			return &c.ReportStyles[i]
+		}
+	}
+	return nil
+}
+
+// periodic tells whether the E2 node supports the periodic event trigger, format 1 in every KPM version,
+// which is the only one kpimon subscribes with. An E2 node announcing no trigger style is taken to.
+func (c *KpmCapabilities) periodic() bool {
+	if len(c.EventTriggerStyles) == 0 {
+		return true
+	}
+	for _, style := range c.EventTriggerStyles {
+		if style.Format == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// tuneStyle returns the report style an action asking for a style, 0 for none, is sent with: the style
+// asked for when the E2 node supports it, else the first style kpimon can encode an action definition
+// of when the action leaves the style to kpimon, style 1 being preferred for v2/v3
+func (c *KpmCapabilities) tuneStyle(style int64) (int64, error) {
+	if len(c.ReportStyles) == 0 {
+		return style, nil
+	}
+	if style != 0 {
+		reportStyle := c.ReportStyle(style)
+		if reportStyle == nil {
+			return 0, fmt.Errorf("report style %d is not supported, only %s", style, c.styleTypes())
+		}
+		if c.Version != kpm.VERSION_1 && reportStyle.ActionFormat != 1 {
+			return 0, fmt.Errorf("report style %d takes action definition format %d, kpimon encodes format 1", style, reportStyle.ActionFormat)
+		}
+		return style, nil
+	}
+	if c.Version == kpm.VERSION_1 {
+		return c.ReportStyles[0].Type, nil
+	}
+	if reportStyle := c.ReportStyle(1); reportStyle != nil && reportStyle.ActionFormat == 1 {
+		return 1, nil
+	}
+	for _, reportStyle := range c.ReportStyles {
+		if reportStyle.ActionFormat == 1 {
+			return reportStyle.Type, nil
+		}
+	}
+	return 0, errors.New("no report style takes action definition format 1, " + c.styleTypes() + " announced")
+}
+
+// tuneMeasurements leaves out of the measurements of a v2/v3 action of a style the ones the style does
+// not list. It fails when none is left.
+func (c *KpmCapabilities) tuneMeasurements(style int64, measurements []string) (kept []string, dropped []string, err error) {
+	reportStyle := c.ReportStyle(style)
+	if reportStyle == nil || len(reportStyle.MeasInfo) == 0 {
+		return measurements, nil, nil
+	}
+	supported := make(map[string]bool, len(reportStyle.MeasInfo))
+	for _, info := range reportStyle.MeasInfo {
+		supported[info.Name] = true
+	}
+	for _, name := range measurements {
+		if supported[name] {
+			kept = append(kept, name)
+		} else {
+			dropped = append(dropped, name)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, dropped, fmt.Errorf("report style %d supports none of the measurements %s", style, strings.Join(measurements, ", "))
+	}
+	return kept, dropped, nil
+}
+
+func (c *KpmCapabilities) styleTypes() string {
+	var types []string
+	for _, style := range c.ReportStyles {
+		types = append(types, fmt.Sprintf("%d", style.Type))
+	}
+	return "report styles [" + strings.Join(types, " ") + "]"
+}
+
+// kpmRanFunction returns the KPM RAN function among the RAN functions of the E2 setup of an E2 node: the
+// one whose OID names a KPM version, else the one whose definition names a KPM E2SM
+func kpmRanFunction(functions []KpmRanFunction) (KpmRanFunction, bool) {
+	for _, function := range functions {
+		if kpm.VersionFromOID(function.OID) != kpm.VERSION_UNKNOWN {
+			return function, true
+		}
+	}
+	for _, function := range functions {
+		definition, err := decodeHexDefinition(function.Definition)
+		if err != nil {
+			continue
+		}
+		if name, _, err := kpm.DecodeRanFunctionName(definition); err == nil &&
+			(kpm.VersionFromOID(name.OID) != kpm.VERSION_UNKNOWN || strings.Contains(strings.ToUpper(name.ShortName), "KPM")) {
+			return function, true
+		}
+	}
+	return KpmRanFunction{}, false
+}
+
+// decodeHexDefinition reads a RAN function definition in hex, which the E2 manager may space out
+func decodeHexDefinition(definition string) ([]byte, error) {
+	return hex.DecodeString(strings.Join(strings.Fields(definition), ""))
+}
@@ -0,0 +1,188 @@
+package control
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+)
+
+var periodicTrigger = []kpm.EventTriggerStyle{{Type: 1, Name: "Periodic Report", Format: 1}}
+
+// v1Capabilities is what the RAN function description golden of a KPM v1 E2 node announces
+func v1Capabilities(ranName string) *KpmCapabilities {
+	instance := int64(1)
+	return &KpmCapabilities{
+		RanName: ranName, RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_1,
+		Name:               &kpm.RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: kpm.OID_V1, Description: "KPM monitor", Instance: &instance},
+		EventTriggerStyles: []kpm.EventTriggerStyle{{Type: 1, Name: "Periodic report", Format: 1}},
+		ReportStyles: []kpm.ReportStyle{
+			{Type: 1, Name: "O-DU measurement", HeaderFormat: 1, MessageFormat: 1},
+			{Type: 2, Name: "O-CU-CP measurement", HeaderFormat: 1, MessageFormat: 1},
+		},
+	}
+}
+
+var v2Description = kpm.RanFunctionDescription{
+	Name:               kpm.RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: kpm.OID_V2, Description: "KPM Monitor"},
+	EventTriggerStyles: periodicTrigger,
+	ReportStyles: []kpm.ReportStyle{
+		{Type: 1, Name: "E2 Node Measurement", ActionFormat: 1, HeaderFormat: 1, MessageFormat: 1,
+			MeasInfo: []kpm.MeasurementInfoAction{{Name: "DRB.PdcpSduVolumeDL"}, {Name: "RRU.PrbUsedDl"}}},
+		{Type: 4, Name: "Common Condition-based, UE-level Measurement", ActionFormat: 4, HeaderFormat: 1, MessageFormat: 3,
+			MeasInfo: []kpm.MeasurementInfoAction{{Name: "DRB.UEThpDl"}}},
+	},
+}
+
+// v3Description announces no style of action definition format 1 but style 3
+var v3Description = kpm.RanFunctionDescription{
+	Name:               kpm.RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: kpm.OID_V3, Description: "KPM Monitor"},
+	EventTriggerStyles: periodicTrigger,
+	ReportStyles: []kpm.ReportStyle{
+		{Type: 2, Name: "E2 Node Measurement for a single UE", ActionFormat: 2, HeaderFormat: 1, MessageFormat: 1,
+			MeasInfo: []kpm.MeasurementInfoAction{{Name: "DRB.UEThpDl"}}},
+		{Type: 3, Name: "Condition-based, UE-level E2 Node Measurement", ActionFormat: 1, HeaderFormat: 1, MessageFormat: 2,
+			MeasInfo: []kpm.MeasurementInfoAction{{Name: "DRB.UEThpDl", BinRange: &kpm.BinRangeDefinition{X: []kpm.BinRange{{Index: 1, Start: 0, End: 100}}}}}},
+	},
+}
+
+func encodeDescription(t *testing.T, desc *kpm.RanFunctionDescription) []byte {
+	t.Helper()
+	definition, err := kpm.EncodeRanFunctionDescription(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return definition
+}
+
+func TestDecodeKpmCapabilities(t *testing.T) {
+	var e2sm *E2sm
+	v1 := readGolden(t, "e2sm/ran_function_description")
+	desc, err := e2sm.GetRanFunctionDescription(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := e2sm.SetRanFunctionDescription(make([]byte, 512), desc)
+	checkEncoded(t, "e2sm/ran_function_description", encoded, err, v1)
+
+	v2, v3 := encodeDescription(t, &v2Description), encodeDescription(t, &v3Description)
+	function := KpmRanFunction{ID: 2, Revision: 1}
+	for _, test := range []struct {
+		name       string
+		version    kpm.Version
+		definition []byte
+		want       *KpmCapabilities
+		err        bool
+	}{
+		{"v1", kpm.VERSION_1, v1, v1Capabilities("gnb_1"), false},
+		{"v2", kpm.VERSION_2, v2, &KpmCapabilities{RanName: "gnb_1", RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_2,
+			Name: &v2Description.Name, EventTriggerStyles: v2Description.EventTriggerStyles, ReportStyles: v2Description.ReportStyles}, false},
+		{"v3", kpm.VERSION_3, v3, &KpmCapabilities{RanName: "gnb_1", RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_3,
+			Name: &v3Description.Name, EventTriggerStyles: v3Description.EventTriggerStyles, ReportStyles: v3Description.ReportStyles}, false},
+		{"no definition", kpm.VERSION_2, nil, &KpmCapabilities{RanName: "gnb_1", RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_2}, true},
+		{"truncated v1", kpm.VERSION_1, v1[:len(v1)-4], &KpmCapabilities{RanName: "gnb_1", RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_1}, true},
+		{"truncated v2", kpm.VERSION_2, v2[:len(v2)-4], &KpmCapabilities{RanName: "gnb_1", RanFunctionID: 2, Revision: 1, Version: kpm.VERSION_2}, true},
+	} {
+		caps, err := decodeKpmCapabilities("gnb_1", function, test.version, test.definition)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+		if err != nil {
+			caps.Name, caps.EventTriggerStyles, caps.ReportStyles = nil, nil, nil //what was read up to the error
+		}
+		checkDecoded(t, test.name, caps, test.want)
+	}
+}
+
+func TestKpmCapabilityChecks(t *testing.T) {
+	v1 := v1Capabilities("gnb_1")
+	v2 := &KpmCapabilities{Version: kpm.VERSION_2, EventTriggerStyles: v2Description.EventTriggerStyles, ReportStyles: v2Description.ReportStyles}
+	v3 := &KpmCapabilities{Version: kpm.VERSION_3, EventTriggerStyles: v3Description.EventTriggerStyles, ReportStyles: v3Description.ReportStyles}
+	ueLevel := &KpmCapabilities{Version: kpm.VERSION_2, ReportStyles: v2Description.ReportStyles[1:]}
+	noStyle := &KpmCapabilities{Version: kpm.VERSION_2}
+	onChange := &KpmCapabilities{Version: kpm.VERSION_2, EventTriggerStyles: []kpm.EventTriggerStyle{{Type: 2, Name: "On change", Format: 2}}}
+
+	for _, test := range []struct {
+		name     string
+		caps     *KpmCapabilities
+		periodic bool
+	}{
+		{"v1", v1, true},
+		{"v2", v2, true},
+		{"no trigger style", noStyle, true},
+		{"on change only", onChange, false},
+	} {
+		if periodic := test.caps.periodic(); periodic != test.periodic {
+			t.Errorf("%s: periodic %v, want %v", test.name, periodic, test.periodic)
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		caps  *KpmCapabilities
+		style int64 //asked for by the action, 0 for none
+		want  int64
+		err   string //part of the error, none when empty
+	}{
+		{"v1 first style", v1, 0, 1, ""},
+		{"v1 supported style", v1, 2, 2, ""},
+		{"v1 unsupported style", v1, 3, 0, "report style 3 is not supported, only report styles [1 2]"},
+		{"v2 style 1", v2, 0, 1, ""},
+		{"v2 supported style", v2, 1, 1, ""},
+		{"v2 style of another action format", v2, 4, 0, "takes action definition format 4"},
+		{"v3 first style of format 1", v3, 0, 3, ""},
+		{"v3 style of another action format", v3, 2, 0, "takes action definition format 2"},
+		{"no style of format 1", ueLevel, 0, 0, "no report style takes action definition format 1, report styles [4] announced"},
+		{"no style announced", noStyle, 5, 5, ""},
+	} {
+		style, err := test.caps.tuneStyle(test.style)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: style %d, error %v, want one of %q", test.name, style, err, test.err)
+			}
+			continue
+		}
+		if err != nil || style != test.want {
+			t.Errorf("%s: style %d, %v, want %d", test.name, style, err, test.want)
+		}
+	}
+
+	measurements := []string{"DRB.PdcpSduVolumeDL", "DRB.PdcpSduVolumeUL", "RRU.PrbUsedDl"}
+	kept, dropped, err := v2.tuneMeasurements(1, measurements)
+	if err != nil {
+		t.Errorf("measurements of style 1: %v", err)
+	}
+	checkDecoded(t, "measurements kept by style 1", kept, []string{"DRB.PdcpSduVolumeDL", "RRU.PrbUsedDl"})
+	checkDecoded(t, "measurements dropped by style 1", dropped, []string{"DRB.PdcpSduVolumeUL"})
+	if kept, dropped, err := v2.tuneMeasurements(4, measurements); err == nil {
+		t.Errorf("measurements of style 4 kept %v, dropped %v", kept, dropped)
+	}
+	if kept, dropped, err := v1.tuneMeasurements(1, measurements); err != nil || len(kept) != len(measurements) || dropped != nil {
+		t.Errorf("measurements of a style listing none kept %v, dropped %v, %v", kept, dropped, err)
+	}
+}
+
+// TestCapabilitySubscriptionRequest checks the request of E2 nodes whose definition was read: the style
+// of a v1 action is picked from the announced ones, and an E2 node without periodic trigger is not subscribed
+func TestCapabilitySubscriptionRequest(t *testing.T) {
+	nodes := NewKpmNodes(KpmConfig{Period: time.Second, Granularity: time.Second, Measurements: []string{"DRB.PdcpSduVolumeDL"}})
+	nodes.SetRanFunction("gnb_v1", KpmRanFunction{ID: 2, OID: kpm.OID_V1, Revision: 1, Definition: hex.EncodeToString(readGolden(t, "e2sm/ran_function_description"))})
+	checkDecoded(t, "capabilities of gnb_v1", nodes.Capabilities("gnb_v1"), v1Capabilities("gnb_v1"))
+	req, err := nodes.subscriptionRequest("gnb_v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.funcID != 2 || len(req.actionDefinitions) != 1 {
+		t.Fatalf("request of RAN function %d with %d actions", req.funcID, len(req.actionDefinitions))
+	}
+	checkEncoded(t, "action definition of gnb_v1", req.actionDefinitions[0].Buf, nil, readGolden(t, "e2sm/action_definition_style_1"))
+
+	onChange := v2Description
+	onChange.EventTriggerStyles = []kpm.EventTriggerStyle{{Type: 2, Name: "On change", Format: 2}}
+	nodes.SetRanFunction("gnb_on_change", KpmRanFunction{ID: 3, OID: kpm.OID_V2, Definition: hex.EncodeToString(encodeDescription(t, &onChange))})
+	if _, err := nodes.subscriptionRequest("gnb_on_change"); err == nil || !strings.Contains(err.Error(), "no periodic event trigger") {
+		t.Errorf("request of an E2 node without periodic trigger: %v", err)
+	}
+}
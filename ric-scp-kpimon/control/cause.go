@@ -0,0 +1,148 @@
+package control
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What to do about an E2 node whose RIC Subscription Request failed
+const (
+	FAILURE_RETRY    = iota //send the same request again after the backoff
+	FAILURE_FALLBACK        //send the request of the fallback profile, which has other action definitions
+	FAILURE_GIVE_UP         //leave the E2 node unsubscribed
+)
+
+var failureDecisionNames = []string{"retry", "fallback", "give up"}
+
+// FailurePolicy decides what to do about an E2 node from the RIC Subscription Failure it sent, nil
+// when the failure could not be decoded
+type FailurePolicy func(ranName string, failure *DecodedSubscriptionFailureMessage) int
+
+var causeTypeNames = []string{"ricRequest", "ricService", "transport", "protocol", "misc"}
+
+// causeNames holds the names of the Cause values of E2AP v1, by cause type
+var causeNames = [][]string{
+	{"ran-function-id-Invalid", "action-not-supported", "excessive-actions", "duplicate-action", "duplicate-event",
+		"function-resource-limit", "request-id-unknown", "inconsistent-action-subsequent-action-sequence",
+		"control-message-invalid", "call-process-id-invalid", "unspecified"},
+	{"function-not-required", "excessive-functions", "ric-resource-limit"},
+	{"unspecified", "transport-resource-unavailable"},
+	{"transfer-syntax-error", "abstract-syntax-error-reject", "abstract-syntax-error-ignore-and-notify",
+		"message-not-compatible-with-receiver-state", "semantic-error", "abstract-syntax-error-falsely-constructed-message",
+		"unspecified"},
+	{"control-processing-overload", "hardware-failure", "om-intervention", "unspecified"},
+}
+
+var procedureNames = []string{"", "E2setup", "ErrorIndication", "Reset", "RICcontrol", "RICindication",
+	"RICserviceQuery", "RICserviceUpdate", "RICsubscription", "RICsubscriptionDelete"}
+var triggeringMessageNames = []string{"initiating-message", "successful-outcome", "unsuccessful-outcome"}
+var criticalityNames = []string{"reject", "ignore", "notify"}
+var typeOfErrorNames = []string{"not-understood", "missing"}
+
+// CauseName returns the name of a cause as in the E2AP ASN.1, e.g. ricRequest/action-not-supported
+func CauseName(cause CauseItemType) string {
+	if cause.CauseType < CAUSE_RIC_REQUEST || cause.CauseType > CAUSE_TYPE_COUNT {
+		return fmt.Sprintf("cause-type-%d/%d", cause.CauseType, cause.CauseID)
+	}
+	return causeTypeNames[cause.CauseType-1] + "/" + enumName(causeNames[cause.CauseType-1], cause.CauseID)
+}
+
+// CriticalityDiagnosticsString describes criticality diagnostics, leaving out the absent fields
+func CriticalityDiagnosticsString(c *CriticalityDiagnosticsType) string {
+	if c == nil {
+		return "none"
+	}
+	var fields []string
+	if c.ProcedureCode >= 0 {
+		fields = append(fields, "procedure "+enumName(procedureNames, c.ProcedureCode))
+	}
+	if c.TriggeringMessage >= 0 {
+		fields = append(fields, "triggered by "+enumName(triggeringMessageNames, c.TriggeringMessage))
+	}
+	if c.ProcedureCriticality >= 0 {
+		fields = append(fields, "criticality "+enumName(criticalityNames, c.ProcedureCriticality))
+	}
+	if c.RequestID >= 0 {
+		fields = append(fields, fmt.Sprintf("request %d/%d", c.RequestID, c.RequestSequenceNumber))
+	}
+	for _, ie := range c.IEs {
+		fields = append(fields, fmt.Sprintf("IE %d %s (%s)", ie.IEID, enumName(typeOfErrorNames, ie.TypeOfError), enumName(criticalityNames, ie.IECriticality)))
+	}
+	if len(fields) == 0 {
+		return "empty"
+	}
+	return strings.Join(fields, ", ")
+}
+
+func enumName(names []string, value int32) string {
+	if value < 0 || int(value) >= len(names) || names[value] == "" {
+		return fmt.Sprintf("%d", value)
+	}
+	return names[value]
+}
+
+// retryableCause tells whether a request refused with cause may succeed when sent again: not when the
+// request itself is wrong (unknown RAN function, unsupported or inconsistent actions, syntax errors)
+func retryableCause(cause CauseItemType) bool {
+	switch cause.CauseType {
+	case CAUSE_RIC_REQUEST:
+		switch cause.CauseID {
+		case CAUSE_RAN_FUNCTION_ID_INVALID, CAUSE_ACTION_NOT_SUPPORTED, CAUSE_EXCESSIVE_ACTIONS, CAUSE_DUPLICATE_ACTION,
+			CAUSE_DUPLICATE_EVENT, CAUSE_INCONSISTENT_ACTION_SUBSEQUENT_ACTION_SEQUENCE:
+			return false
+		}
+	case CAUSE_RIC_SERVICE:
+		return cause.CauseID != CAUSE_FUNCTION_NOT_REQUIRED
+	case CAUSE_PROTOCOL:
+		return cause.CauseID == CAUSE_MESSAGE_NOT_COMPATIBLE_WITH_RECEIVER_STATE || cause.CauseID == CAUSE_PROTOCOL_UNSPECIFIED
+	}
+	return true
+}
+
+// actionCause tells whether cause refuses the actions rather than the RAN function, which another
+// action definition may get past
+func actionCause(cause CauseItemType) bool {
+	if cause.CauseType != CAUSE_RIC_REQUEST {
+		return false
+	}
+	switch cause.CauseID {
+	case CAUSE_ACTION_NOT_SUPPORTED, CAUSE_EXCESSIVE_ACTIONS, CAUSE_DUPLICATE_ACTION, CAUSE_INCONSISTENT_ACTION_SUBSEQUENT_ACTION_SEQUENCE:
+		return true
+	}
+	return false
+}
+
+// DefaultFailurePolicy retries failures that may go away, switches an E2 node that refuses the actions
+// of its profile to the fallback profile and gives up on the other failures
+func DefaultFailurePolicy(ranName string, failure *DecodedSubscriptionFailureMessage) int {
+	if failure == nil {
+		return FAILURE_RETRY
+	}
+	decision := FAILURE_RETRY
+	for _, cause := range failure.ActionNotAdmittedList.Cause {
+		if retryableCause(cause) {
+			continue
+		}
+		if !actionCause(cause) {
+			return FAILURE_GIVE_UP
+		}
+		decision = FAILURE_FALLBACK
+	}
+	return decision
+}
+
+// FailureString describes a RIC Subscription Failure with the names of its causes
+func FailureString(failure *DecodedSubscriptionFailureMessage) string {
+	var causes []string
+	for i, cause := range failure.ActionNotAdmittedList.Cause {
+		if i < len(failure.ActionNotAdmittedList.ActionID) {
+			causes = append(causes, fmt.Sprintf("action %d %s", failure.ActionNotAdmittedList.ActionID[i], CauseName(cause)))
+		}
+	}
+	if len(causes) == 0 {
+		causes = append(causes, "no action cause")
+	}
+	return fmt.Sprintf("RIC_SUB_FAILURE of request %d/%d, RAN function %d: %s; criticality diagnostics: %s",
+		failure.RequestID, failure.RequestSequenceNumber, failure.FuncID, strings.Join(causes, ", "),
+		CriticalityDiagnosticsString(failure.CriticalityDiagnostics))
+}
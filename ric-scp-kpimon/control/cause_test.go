@@ -0,0 +1,117 @@
+package control
+
+import (
+	"testing"
+)
+
+func TestCauses(t *testing.T) {
+	for _, test := range []struct {
+		cause     CauseItemType
+		name      string
+		retryable bool
+		action    bool //refuses the actions rather than the RAN function
+	}{
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_RAN_FUNCTION_ID_INVALID}, "ricRequest/ran-function-id-Invalid", false, false},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_ACTION_NOT_SUPPORTED}, "ricRequest/action-not-supported", false, true},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_EXCESSIVE_ACTIONS}, "ricRequest/excessive-actions", false, true},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_DUPLICATE_ACTION}, "ricRequest/duplicate-action", false, true},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_DUPLICATE_EVENT}, "ricRequest/duplicate-event", false, false},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_FUNCTION_RESOURCE_LIMIT}, "ricRequest/function-resource-limit", true, false},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_RIC_REQUEST_ID_UNKNOWN}, "ricRequest/request-id-unknown", true, false},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_INCONSISTENT_ACTION_SUBSEQUENT_ACTION_SEQUENCE}, "ricRequest/inconsistent-action-subsequent-action-sequence", false, true},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_RIC_REQUEST_UNSPECIFIED}, "ricRequest/unspecified", true, false},
+		{CauseItemType{CauseType: CAUSE_RIC_SERVICE, CauseID: CAUSE_FUNCTION_NOT_REQUIRED}, "ricService/function-not-required", false, false},
+		{CauseItemType{CauseType: CAUSE_RIC_SERVICE, CauseID: CAUSE_RIC_RESOURCE_LIMIT}, "ricService/ric-resource-limit", true, false},
+		{CauseItemType{CauseType: CAUSE_TRANSPORT, CauseID: CAUSE_TRANSPORT_RESOURCE_UNAVAILABLE}, "transport/transport-resource-unavailable", true, false},
+		{CauseItemType{CauseType: CAUSE_PROTOCOL, CauseID: CAUSE_TRANSFER_SYNTAX_ERROR}, "protocol/transfer-syntax-error", false, false},
+		{CauseItemType{CauseType: CAUSE_PROTOCOL, CauseID: CAUSE_MESSAGE_NOT_COMPATIBLE_WITH_RECEIVER_STATE}, "protocol/message-not-compatible-with-receiver-state", true, false},
+		{CauseItemType{CauseType: CAUSE_PROTOCOL, CauseID: CAUSE_PROTOCOL_UNSPECIFIED}, "protocol/unspecified", true, false},
+		{CauseItemType{CauseType: CAUSE_MISC, CauseID: CAUSE_HARDWARE_FAILURE}, "misc/hardware-failure", true, false},
+		{CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: 42}, "ricRequest/42", true, false},
+		{CauseItemType{CauseType: 6, CauseID: 1}, "cause-type-6/1", true, false},
+		{CauseItemType{CauseType: 0, CauseID: 0}, "cause-type-0/0", true, false},
+	} {
+		if name := CauseName(test.cause); name != test.name {
+			t.Errorf("cause %+v named %s, want %s", test.cause, name, test.name)
+		}
+		if retryable := retryableCause(test.cause); retryable != test.retryable {
+			t.Errorf("%s retryable: %v, want %v", test.name, retryable, test.retryable)
+		}
+		if action := actionCause(test.cause); action != test.action {
+			t.Errorf("%s refusing the actions: %v, want %v", test.name, action, test.action)
+		}
+	}
+}
+
+// TestFailurePolicy decides about the RIC_SUB_FAILURE payloads of the e2ap goldens and of single causes
+func TestFailurePolicy(t *testing.T) {
+	var e2ap *E2ap
+	for _, test := range []struct {
+		name     string
+		golden   string                             //e2ap golden of the payload
+		failure  *DecodedSubscriptionFailureMessage //encoded to the payload when there is no golden
+		decision int
+		reason   string //FailureString of the decoded payload
+	}{
+		{"function not required", "e2ap/sub_failure", nil, FAILURE_GIVE_UP,
+			"RIC_SUB_FAILURE of request 123/1, RAN function 0: action 1 ricService/function-not-required; criticality diagnostics: none"},
+		{"retryable causes and diagnostics", "e2ap/sub_failure_diagnostics", nil, FAILURE_RETRY,
+			"RIC_SUB_FAILURE of request 123/1, RAN function 0: action 1 protocol/message-not-compatible-with-receiver-state, action 2 misc/unspecified; " +
+				"criticality diagnostics: procedure RICsubscription, triggered by initiating-message, criticality reject, request 123/1, IE 30 missing (reject)"},
+		{"action not supported", "", subscriptionFailure(CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_ACTION_NOT_SUPPORTED}), FAILURE_FALLBACK,
+			"RIC_SUB_FAILURE of request 1001/1001, RAN function 2: action 1 ricRequest/action-not-supported; criticality diagnostics: none"},
+		{"RAN function ID invalid", "", subscriptionFailure(CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_RAN_FUNCTION_ID_INVALID}), FAILURE_GIVE_UP,
+			"RIC_SUB_FAILURE of request 1001/1001, RAN function 2: action 1 ricRequest/ran-function-id-Invalid; criticality diagnostics: none"},
+		{"function resource limit", "", subscriptionFailure(CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_FUNCTION_RESOURCE_LIMIT}), FAILURE_RETRY,
+			"RIC_SUB_FAILURE of request 1001/1001, RAN function 2: action 1 ricRequest/function-resource-limit; criticality diagnostics: none"},
+		{"actions refused for a retryable cause", "", subscriptionFailure(
+			CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_FUNCTION_RESOURCE_LIMIT}, CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_EXCESSIVE_ACTIONS}), FAILURE_FALLBACK,
+			"RIC_SUB_FAILURE of request 1001/1001, RAN function 2: action 1 ricRequest/function-resource-limit, action 2 ricRequest/excessive-actions; criticality diagnostics: none"},
+		{"actions refused along with the RAN function", "", subscriptionFailure(
+			CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_ACTION_NOT_SUPPORTED}, CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_RAN_FUNCTION_ID_INVALID}), FAILURE_GIVE_UP,
+			"RIC_SUB_FAILURE of request 1001/1001, RAN function 2: action 1 ricRequest/action-not-supported, action 2 ricRequest/ran-function-id-Invalid; criticality diagnostics: none"},
+	} {
+		var payload []byte
+		var err error
+		if test.golden != "" {
+			payload = readGolden(t, test.golden)
+		} else if payload, err = e2ap.SetSubscriptionFailurePayload(make([]byte, 1024), test.failure); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		failure, err := e2ap.GetSubscriptionFailureMessage(payload)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if decision := DefaultFailurePolicy("gnb_1", failure); decision != test.decision {
+			t.Errorf("%s: decision %s, want %s", test.name, failureDecisionNames[decision], failureDecisionNames[test.decision])
+		}
+		if reason := FailureString(failure); reason != test.reason {
+			t.Errorf("%s: failure\n%s\nwant\n%s", test.name, reason, test.reason)
+		}
+	}
+
+	//an undecodable failure may be a transient one, a failure without cause as well
+	if decision := DefaultFailurePolicy("gnb_1", nil); decision != FAILURE_RETRY {
+		t.Errorf("decision about an undecodable failure %s, want retry", failureDecisionNames[decision])
+	}
+	failure := &DecodedSubscriptionFailureMessage{RequestID: 1, RequestSequenceNumber: 2, FuncID: 3}
+	if decision := DefaultFailurePolicy("gnb_1", failure); decision != FAILURE_RETRY {
+		t.Errorf("decision about a failure without cause %s, want retry", failureDecisionNames[decision])
+	}
+	if reason, want := FailureString(failure), "RIC_SUB_FAILURE of request 1/2, RAN function 3: no action cause; criticality diagnostics: none"; reason != want {
+		t.Errorf("failure without cause\n%s\nwant\n%s", reason, want)
+	}
+}
+
+// subscriptionFailure returns the RIC_SUB_FAILURE of the default subscription request refusing its actions
+// 1, 2, ... for the causes
+func subscriptionFailure(causes ...CauseItemType) *DecodedSubscriptionFailureMessage {
+	failure := &DecodedSubscriptionFailureMessage{RequestID: SUBSCRIPTION_REQUESTOR_ID, RequestSequenceNumber: SUBSCRIPTION_INSTANCE_ID, FuncID: 2}
+	for i, cause := range causes {
+		failure.ActionNotAdmittedList.ActionID = append(failure.ActionNotAdmittedList.ActionID, int32(i+1))
+		failure.ActionNotAdmittedList.Cause = append(failure.ActionNotAdmittedList.Cause, cause)
+	}
+	return failure
+}
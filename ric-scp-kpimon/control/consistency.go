@@ -0,0 +1,287 @@
+package control
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const (
+	REPORT_DU    = "du"    //DU Usage Report: PRB usage
+	REPORT_CU_CP = "cu-cp" //CU-CP Usage Report: serving and neighbour cell RF
+	REPORT_CU_UP = "cu-up" //CU-UP Usage Report: PDCP bytes
+
+	INCONSISTENCY_SINGLE_SOURCE = "single_source" //UE reported by one report type while the E2 nodes send several
+	INCONSISTENCY_CELL_MISMATCH = "cell_mismatch" //report types, or the store entry, disagree on the serving cell of the UE
+	INCONSISTENCY_UNREPORTED    = "unreported"    //UE in the store that no E2 node ever reported
+)
+
+const DEFAULT_CONSISTENCY_INTERVAL = 10 * time.Second
+
+type ConsistencyConfig struct {
+	Enabled  bool
+	Interval time.Duration //time between two checks
+	Grace    time.Duration //time a UE has to show up in the other report types, and the E2 nodes after start to report the UEs of the store
+	Expiry   time.Duration //time after which a report no longer counts for the UE
+}
+
+// LoadConsistencyConfig reads the consistency checker configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadConsistencyConfig() ConsistencyConfig {
+	cfg := ConsistencyConfig{
+		Interval: DEFAULT_CONSISTENCY_INTERVAL,
+		Grace:    30 * time.Second,
+		Expiry:   5 * time.Minute,
+	}
+	cfg.Enabled, _ = strconv.ParseBool(os.Getenv("consistencyCheck"))
+	if d, err := time.ParseDuration(os.Getenv("consistencyInterval")); err == nil && d > 0 {
+		cfg.Interval = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("consistencyGrace")); err == nil && d >= 0 {
+		cfg.Grace = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("consistencyExpiry")); err == nil && d > 0 {
+		cfg.Expiry = d
+	}
+	return cfg
+}
+
+// UeReport is the last report of one type about a UE
+type UeReport struct {
+	ServingCellID string    `json:"serving_cell_id"`
+	RanName       string    `json:"ran_name"` //E2 node the report came from
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	Count         int64     `json:"count"`
+}
+
+// Inconsistency is a UE whose report types or store entry do not agree
+type Inconsistency struct {
+	Kind               string               `json:"kind"`
+	UeID               string               `json:"ue_id"`
+	Reports            map[string]*UeReport `json:"reports"`               //by report type, empty for unreported UEs
+	StoreServingCellID string               `json:"store_serving_cell_id"` //serving cell of the store entry, empty if there is none
+	Time               time.Time            `json:"time"`
+}
+
+// ConsistencyChecker tracks which report types (DU, CU-CP, CU-UP) contributed to each UE entry
+// and periodically compares them with each other and with the UEs found in the metrics store
+type ConsistencyChecker struct {
+	store    MetricsStore //view of the store listing every UE entry, signed or not
+	cfg      ConsistencyConfig
+	started  time.Time
+	ues      map[string]map[string]*UeReport //UE ID to report type to last report
+	reported map[string]bool                 //UE IDs reported at least once since start
+	types    map[string]bool                 //report types received at least once since start
+	flagged  map[string]bool                 //kind/UE ID of the inconsistencies currently raised
+	counts   map[string]int64                //inconsistencies raised per kind
+	mu       *sync.Mutex
+	stop     chan struct{}
+	once     *sync.Once
+}
+
+// NewConsistencyChecker returns a checker of the UEs of store, a cfg.Interval of zero or less checks every DEFAULT_CONSISTENCY_INTERVAL
+func NewConsistencyChecker(store MetricsStore, cfg ConsistencyConfig) *ConsistencyChecker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DEFAULT_CONSISTENCY_INTERVAL
+	}
+	return &ConsistencyChecker{
+		store:    store,
+		cfg:      cfg,
+		started:  time.Now(),
+		ues:      make(map[string]map[string]*UeReport),
+		reported: make(map[string]bool),
+		types:    make(map[string]bool),
+		flagged:  make(map[string]bool),
+		counts:   make(map[string]int64),
+		mu:       &sync.Mutex{},
+		stop:     make(chan struct{}),
+		once:     &sync.Once{},
+	}
+}
+
+// Observe records that a report of type report from ranName described ueID in servingCellID
+func (c *ConsistencyChecker) Observe(report string, ueID string, servingCellID string, ranName string) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reports, ok := c.ues[ueID]
+	if !ok {
+		reports = make(map[string]*UeReport)
+		c.ues[ueID] = reports
+	}
+	r, ok := reports[report]
+	if !ok {
+		r = &UeReport{FirstSeen: now}
+		reports[report] = r
+	}
+	r.ServingCellID = servingCellID
+	r.RanName = ranName
+	r.LastSeen = now
+	r.Count++
+	c.reported[ueID] = true
+	c.types[report] = true
+}
+
+// Sources returns the report types that contributed to the entry of ueID and have not expired
+func (c *ConsistencyChecker) Sources(ueID string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sources []string
+	for report, r := range c.ues[ueID] {
+		if time.Since(r.LastSeen) <= c.cfg.Expiry {
+			sources = append(sources, report)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Counts returns the number of inconsistencies raised per kind since start
+func (c *ConsistencyChecker) Counts() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for kind, n := range c.counts {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// Check compares the reports of every UE with each other and with the store entry, looks for store UEs
+// never reported and returns the inconsistencies found. Each one is logged and counted when it is first
+// found, and again if it comes back after being resolved.
+func (c *ConsistencyChecker) Check() ([]*Inconsistency, error) {
+	ueIDs, err := c.store.ListUes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	snapshots := make(map[string]map[string]*UeReport, len(c.ues))
+	for ueID, reports := range c.ues {
+		snapshot := make(map[string]*UeReport, len(reports))
+		for report, r := range reports {
+			if now.Sub(r.LastSeen) > c.cfg.Expiry {
+				delete(reports, report)
+				continue
+			}
+			copied := *r
+			snapshot[report] = &copied
+		}
+		if len(snapshot) == 0 {
+			delete(c.ues, ueID)
+			continue
+		}
+		snapshots[ueID] = snapshot
+	}
+	var unreported []string
+	if now.Sub(c.started) > c.cfg.Grace {
+		for _, ueID := range ueIDs {
+			if !c.reported[ueID] {
+				unreported = append(unreported, ueID)
+			}
+		}
+	}
+	multiSource := len(c.types) > 1
+	c.mu.Unlock()
+
+	//the store entries of the reported and the unreported UEs are read at once
+	read := append([]string(nil), unreported...)
+	for ueID := range snapshots {
+		read = append(read, ueID)
+	}
+	entries, err := c.store.GetUes(read)
+	if err != nil {
+		return nil, err
+	}
+	var found []*Inconsistency
+	for ueID, reports := range snapshots {
+		found = append(found, c.compare(ueID, reports, entries[ueID], multiSource, now)...)
+	}
+	for _, ueID := range unreported {
+		//a UE listed by the store index without an entry is not in the store
+		if ueMetrics, ok := entries[ueID]; ok {
+			found = append(found, &Inconsistency{Kind: INCONSISTENCY_UNREPORTED, UeID: ueID, StoreServingCellID: ueMetrics.ServingCellID, Time: now})
+		}
+	}
+	c.raise(found)
+	return found, nil
+}
+
+// compare returns the single source and serving cell inconsistencies of one UE, ueMetrics is its store entry, nil if there is none
+func (c *ConsistencyChecker) compare(ueID string, reports map[string]*UeReport, ueMetrics *UeMetricsEntry, multiSource bool, now time.Time) []*Inconsistency {
+	var latest *UeReport
+	cells := make(map[string]bool)
+	for _, r := range reports {
+		cells[r.ServingCellID] = true
+		if latest == nil || r.LastSeen.After(latest.LastSeen) {
+			latest = r
+		}
+	}
+	storeCellID := ""
+	if ueMetrics != nil {
+		storeCellID = ueMetrics.ServingCellID
+	}
+
+	var found []*Inconsistency
+	//a UE needs Grace to show up in the other report types, unless the E2 nodes only send one
+	if len(reports) == 1 && multiSource && now.Sub(latest.FirstSeen) > c.cfg.Grace {
+		found = append(found, &Inconsistency{INCONSISTENCY_SINGLE_SOURCE, ueID, reports, storeCellID, now})
+	}
+	//the store entry follows the latest report, give handleIndication a moment to write it
+	storeDiffers := storeCellID != "" && storeCellID != latest.ServingCellID && now.Sub(latest.LastSeen) > time.Second
+	if len(cells) > 1 || storeDiffers {
+		found = append(found, &Inconsistency{INCONSISTENCY_CELL_MISMATCH, ueID, reports, storeCellID, now})
+	}
+	return found
+}
+
+// raise logs and counts the inconsistencies not raised by the previous check
+func (c *ConsistencyChecker) raise(found []*Inconsistency) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	flagged := make(map[string]bool, len(found))
+	for _, inconsistency := range found {
+		id := inconsistency.Kind + "/" + inconsistency.UeID
+		flagged[id] = true
+		if c.flagged[id] {
+			continue
+		}
+		c.counts[inconsistency.Kind]++
+		cells := make([]string, 0, len(inconsistency.Reports))
+		for report, r := range inconsistency.Reports {
+			cells = append(cells, report+"="+r.ServingCellID)
+		}
+		sort.Strings(cells)
+		xapp.Logger.Warn("Consistency check: UE [%s] %s, reports %v, store serving cell [%s]", inconsistency.UeID, inconsistency.Kind, cells, inconsistency.StoreServingCellID)
+		log.Printf("Consistency check: UE [%s] %s, reports %v, store serving cell [%s]", inconsistency.UeID, inconsistency.Kind, cells, inconsistency.StoreServingCellID)
+	}
+	c.flagged = flagged
+}
+
+// Run checks every Interval until Close is called
+func (c *ConsistencyChecker) Run() {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if _, err := c.Check(); err != nil {
+				xapp.Logger.Error("Consistency check failed to read the UEs of the store: %v", err)
+				log.Printf("Consistency check failed to read the UEs of the store: %v", err)
+			}
+		}
+	}
+}
+
+func (c *ConsistencyChecker) Close() {
+	c.once.Do(func() { close(c.stop) })
+}
@@ -0,0 +1,66 @@
+package control
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLoadConsistencyConfig(t *testing.T) {
+	defer os.Unsetenv("consistencyInterval")
+	defer os.Unsetenv("consistencyExpiry")
+	for _, test := range []struct {
+		interval, expiry string
+		want             ConsistencyConfig
+	}{
+		{"", "", ConsistencyConfig{Interval: DEFAULT_CONSISTENCY_INTERVAL, Grace: 30 * time.Second, Expiry: 5 * time.Minute}},
+		{"1s", "1m", ConsistencyConfig{Interval: time.Second, Grace: 30 * time.Second, Expiry: time.Minute}},
+		{"0s", "0s", ConsistencyConfig{Interval: DEFAULT_CONSISTENCY_INTERVAL, Grace: 30 * time.Second, Expiry: 5 * time.Minute}},
+		{"-1s", "-1m", ConsistencyConfig{Interval: DEFAULT_CONSISTENCY_INTERVAL, Grace: 30 * time.Second, Expiry: 5 * time.Minute}},
+	} {
+		os.Setenv("consistencyInterval", test.interval)
+		os.Setenv("consistencyExpiry", test.expiry)
+		if cfg := LoadConsistencyConfig(); cfg != test.want {
+			t.Errorf("interval %q, expiry %q: %+v, want %+v", test.interval, test.expiry, cfg, test.want)
+		}
+	}
+	if c := NewConsistencyChecker(NewMemoryMetricsStore(), ConsistencyConfig{}); c.cfg.Interval != DEFAULT_CONSISTENCY_INTERVAL {
+		t.Errorf("checker of the zero config checks every %v", c.cfg.Interval)
+	}
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	store := NewMemoryMetricsStore()
+	c := NewConsistencyChecker(store, ConsistencyConfig{Expiry: time.Minute})
+	store.PutUe("ue_1", storeUe("ue_1"))
+	store.PutUe("ue_2", storeUe("ue_2"))
+	store.PutUe("ue_4", storeUe("ue_4"))
+	store.(*kvMetricsStore).kv.del("ue_4") //still listed by the UE index
+	c.Observe(REPORT_DU, "ue_1", "cell_1", "gnb_1")
+	c.Observe(REPORT_CU_CP, "ue_1", "cell_1", "gnb_1")
+	c.Observe(REPORT_DU, "ue_3", "cell_1", "gnb_1")
+	c.Observe(REPORT_CU_CP, "ue_3", "cell_2", "gnb_1")
+
+	found, err := c.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, inconsistency := range found {
+		got = append(got, inconsistency.Kind+":"+inconsistency.UeID+":"+inconsistency.StoreServingCellID)
+	}
+	sort.Strings(got)
+	want := []string{INCONSISTENCY_CELL_MISMATCH + ":ue_3:", INCONSISTENCY_UNREPORTED + ":ue_2:cell_1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inconsistencies %v, want %v", got, want)
+	}
+	c.Check()
+	if counts := c.Counts(); counts[INCONSISTENCY_CELL_MISMATCH] != 1 || counts[INCONSISTENCY_UNREPORTED] != 1 {
+		t.Errorf("counts %v, want each inconsistency counted once", counts)
+	}
+	if sources := c.Sources("ue_1"); !reflect.DeepEqual(sources, []string{REPORT_CU_CP, REPORT_DU}) {
+		t.Errorf("sources of ue_1 %v", sources)
+	}
+}
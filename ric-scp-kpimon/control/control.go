@@ -4,30 +4,41 @@ import (
 	"encoding/json"
 	"errors"
 	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
-	"github.com/go-redis/redis"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
 	"log"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
-	"fmt"
+	"sync/atomic"
 )
 
 type Control struct {
 	ranList []string //nodeB list
-	eventCreateExpired int32 //maximum time for the RIC Subscription Request event creation procedure in the E2 Node
-	eventDeleteExpired int32 //maximum time for the RIC Subscription Request event deletion procedure in the E2 Node
 	rcChan                chan *xapp.RMRParams //channel for receiving rmr message
-	client                *redis.Client        //redis client
-	eventCreateExpiredMap map[string]bool      //map for recording the RIC Subscription Request event creation procedure is expired or not
-	eventDeleteExpiredMap map[string]bool      //map for recording the RIC Subscription Request event deletion procedure is expired or not
-	eventCreateExpiredMu  *sync.Mutex          //mutex for eventCreateExpiredMap
-	eventDeleteExpiredMu  *sync.Mutex          //mutex for eventDeleteExpiredMap
+	store                 MetricsStore         //metrics store written by handleIndication, validating and recording into the ledger when enabled
+	backend               MetricsStore         //same metrics store without validation, ledger recording and integrity monitoring
+	ledger                ledger.Writer        //ground-truth ledger of the store writes
+	monitor               *IntegrityMonitor    //integrity monitor of the metrics store, nil when disabled
+	validator             *Validator           //plausibility validator of the decoded entries, nil when no rules are configured
+	consistency           *ConsistencyChecker  //cross-report consistency checker of the UE entries, nil when disabled
+	kpm                   *KpmNodes            //E2SM-KPM version and subscribed action definition of each E2 node, nil when every E2 node speaks KPM v1
+	transport             Transport            //transport for sending and receiving messages
+	subscriptions         *Subscriptions       //subscription state of each E2 node
+	subscriber            SubscriptionBackend  //sends the subscription requests, over the transport or through the subscription manager
+	discovery             *E2NodeDiscovery     //subscribes the E2 nodes of the E2 manager as they connect, nil for ranList only
+	sequences             *IndicationSequences //RICindicationSN tracking of each subscription
+	ricControl            *RicControl          //sends the RIC Control Requests and pairs them with their answers
+	messages              *MessageCounters     //received messages per message type
+	deadLetters           *DeadLetters         //dumps the payloads that could not be handled, none when no directory is configured
+	indicationSN          *int64               //sequence number of the last RIC Indication received, -1 before the first one
 }
 
 func init() {
-	file := "/opt/kpimon.log"
+	file := os.Getenv("logFile")
+	if file == "" {
+		file = "/opt/kpimon.log"
+	}
 	logFile, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
 	if err != nil {
 		panic(err)
@@ -40,72 +51,243 @@ func init() {
 
 func NewControl() Control {
 	str := os.Getenv("ranList")
-	return Control{strings.Split(str, ","),
-		5, 5,
+	storeCfg := LoadStoreConfig()
+	store, err := NewMetricsStore(storeCfg)
+	if err != nil {
+		panic(err)
+	}
+	transport, err := NewTransport(LoadTransportConfig())
+	if err != nil {
+		panic(err)
+	}
+	var records ledger.Writer
+	if cfg := ledger.LoadConfig(); cfg.Enabled() {
+		if records, err = ledger.Open(cfg); err != nil {
+			panic(err)
+		}
+	}
+	var monitor *IntegrityMonitor
+	if cfg := LoadMonitorConfig(); cfg.Enabled {
+		if monitor, err = setupIntegrityMonitor(cfg, storeCfg, transport); err != nil {
+			panic(err)
+		}
+	}
+	var rules *ValidationRules
+	if file := os.Getenv("validationRules"); file != "" {
+		if rules, err = LoadValidationRules(file); err != nil {
+			panic(err)
+		}
+	}
+	var consistency *ConsistencyChecker
+	if cfg := LoadConsistencyConfig(); cfg.Enabled {
+		consistency = NewConsistencyChecker(ForeignView(store), cfg)
+	}
+	kpmCfg, err := LoadKpmConfig()
+	if err != nil {
+		panic(err)
+	}
+	subCfg, err := LoadSubscriptionConfig()
+	if err != nil {
+		panic(err)
+	}
+	discoveryCfg, err := LoadDiscoveryConfig()
+	if err != nil {
+		panic(err)
+	}
+	c := NewControlWith(strings.Split(str, ","), store, transport, records, monitor, rules, consistency, NewKpmNodes(kpmCfg), subCfg)
+	controlCfg, err := LoadControlConfig()
+	if err != nil {
+		panic(err)
+	}
+	c.sequences = NewIndicationSequences(LoadSequenceConfig())
+	c.ricControl = NewRicControl(controlCfg, transport)
+	c.deadLetters = NewDeadLetters(LoadDeadLetterConfig())
+	if discoveryCfg.Source == E2_NODE_DISCOVERY_E2MGR {
+		discovery, err := NewE2NodeDiscovery(discoveryCfg)
+		if err != nil {
+			panic(err)
+		}
+		c.SetDiscovery(discovery)
+	}
+	return c
+}
+
+// NewControlWith builds a Control on explicit dependencies, e.g. a memory store and a
+// ChanTransport when kpimon is driven by an in-process simulator. records may be nil
+// when the store writes do not need to be recorded, monitor when they are not monitored
+// and rules when the decoded entries are stored without plausibility checks, consistency when
+// the reports are not checked against each other and nodes when every E2 node speaks KPM v1.
+// The zero subCfg subscribes over the transport, retries with the default backoff and never resubscribes
+// idle E2 nodes.
+func NewControlWith(ranList []string, store MetricsStore, transport Transport, records ledger.Writer, monitor *IntegrityMonitor, rules *ValidationRules, consistency *ConsistencyChecker, nodes *KpmNodes, subCfg SubscriptionConfig) Control {
+	indicationSN := int64(-1)
+	subscriptions := NewSubscriptions(subCfg, ranList)
+	subscriptions.SetReportPeriods(nodes.ReportPeriod)
+	recorded := store
+	if monitor != nil {
+		recorded = monitor.Wrap(recorded)
+	}
+	if records != nil {
+		recorded = newLedgerMetricsStore(recorded, records, &indicationSN)
+	} else {
+		records, _ = ledger.Open(ledger.Config{})
+	}
+	var validator *Validator
+	if rules != nil {
+		validator = NewValidator(rules)
+		recorded = validator.Wrap(recorded)
+	}
+	return Control{ranList,
 		make(chan *xapp.RMRParams),
-		redis.NewClient(&redis.Options{
-			Addr:     "10.244.0.14:6379",//os.Getenv("10.244.0.14:6379"), //"localhost:6379" "redisAddr"
-			Password: "",
-			DB:       0,
-		}),
-		make(map[string]bool),
-		make(map[string]bool),
-		&sync.Mutex{},
-		&sync.Mutex{}}
+		recorded,
+		store,
+		records,
+		monitor,
+		validator,
+		consistency,
+		nodes,
+		transport,
+		subscriptions,
+		NewSubscriptionBackend(subCfg.withDefaults(), transport, subscriptions),
+		nil,
+		NewIndicationSequences(SequenceConfig{}),
+		NewRicControl(ControlConfig{}, transport),
+		NewMessageCounters(),
+		NewDeadLetters(DeadLetterConfig{}),
+		&indicationSN}
+}
+
+// Store returns the metrics store as a foreign writer sees it: without ledger recording, integrity
+// monitoring or signing. The scenario engine writes through it and records its writes itself.
+func (c *Control) Store() MetricsStore {
+	return ForeignView(c.backend)
+}
+
+// Ledger returns the ground-truth ledger, which discards the records when no output is configured
+func (c *Control) Ledger() ledger.Writer {
+	return c.ledger
+}
+
+// Validator returns the plausibility validator of the decoded entries, nil when no rules are configured
+func (c *Control) Validator() *Validator {
+	return c.validator
+}
+
+// Consistency returns the cross-report consistency checker, nil when disabled
+func (c *Control) Consistency() *ConsistencyChecker {
+	return c.consistency
+}
+
+// Subscriptions returns the subscription state of the E2 nodes
+func (c *Control) Subscriptions() *Subscriptions {
+	return c.subscriptions
+}
+
+// Sequences returns the RICindicationSN tracking of the subscriptions
+func (c *Control) Sequences() *IndicationSequences {
+	return c.sequences
+}
+
+// RicControl returns the RIC control procedure of kpimon
+func (c *Control) RicControl() *RicControl {
+	return c.ricControl
+}
+
+// SendControl sends a RIC Control Request to an E2 node and waits for its answer, see RicControl.Send
+func (c *Control) SendControl(req *ControlRequest) (*ControlOutcome, error) {
+	return c.ricControl.Send(req)
+}
+
+// Messages returns the received messages per message type
+func (c *Control) Messages() *MessageCounters {
+	return c.messages
+}
+
+// DeadLetters returns the dump of the payloads that could not be handled
+func (c *Control) DeadLetters() *DeadLetters {
+	return c.deadLetters
+}
+
+// SetDeadLetters replaces the dead letters of the configuration. It has to be set before Run.
+func (c *Control) SetDeadLetters(deadLetters *DeadLetters) {
+	c.deadLetters = deadLetters
+}
+
+// SetDiscovery subscribes the E2 nodes discovery finds along with the ones of ranList. It has to be
+// set before Run.
+func (c *Control) SetDiscovery(discovery *E2NodeDiscovery) {
+	c.discovery = discovery
+}
+
+// Discovery returns the E2 node discovery, nil when only the E2 nodes of ranList are subscribed
+func (c *Control) Discovery() *E2NodeDiscovery {
+	return c.discovery
+}
+
+// SetFailurePolicy replaces the policy deciding about the E2 nodes that send RIC Subscription Failures,
+// DefaultFailurePolicy unless set
+func (c *Control) SetFailurePolicy(policy FailurePolicy) {
+	c.subscriptions.SetFailurePolicy(policy)
+}
+
+// Kpm returns the E2SM-KPM version registry of the E2 nodes, nil when every E2 node speaks KPM v1
+func (c *Control) Kpm() *KpmNodes {
+	return c.kpm
+}
+
+// LastIndicationSN returns the sequence number of the last RIC Indication received, -1 if none was received yet
+func (c *Control) LastIndicationSN() int64 {
+	return atomic.LoadInt64(c.indicationSN)
 }
 
 func ReadyCB(i interface{}) {
 	c := i.(*Control)
 
-	c.startTimerSubReq()
+	if err := c.subscriber.Start(); err != nil {
+		xapp.Logger.Error("Failed to start subscription backend: %v", err)
+		log.Printf("Failed to start subscription backend: %v", err)
+	}
+	c.subscriptions.Start(c.sendRicSubRequest, c.subscriber.Unsubscribe)
+	if c.discovery != nil {
+		c.discovery.Start(c.nodeConnected, c.nodeDisconnected)
+	}
 	go c.controlLoop()
 }
 
 func (c *Control) Run() {
-	_, err := c.client.Ping().Result()
+	err := c.store.Ping()
 	if err != nil {
-		xapp.Logger.Error("Failed to connect to Redis DB with %v", err)
-		log.Printf("Failed to connect to Redis DB with %v", err)
+		xapp.Logger.Error("Failed to connect to metrics store with %v", err)
+		log.Printf("Failed to connect to metrics store with %v", err)
+	}
+	if c.monitor != nil {
+		go func() {
+			if err := c.monitor.Run(); err != nil {
+				xapp.Logger.Error("Integrity monitor stopped with %v", err)
+				log.Printf("Integrity monitor stopped with %v", err)
+			}
+		}()
 	}
-	if len(c.ranList) > 0 {
-		xapp.SetReadyCB(ReadyCB, c)
-		xapp.Run(c)
+	if c.consistency != nil {
+		go c.consistency.Run()
+	}
+	if len(c.subscriptions.RanNames()) > 0 || c.discovery != nil {
+		c.transport.Run(c, func() { ReadyCB(c) })
 	} else {
-		xapp.Logger.Error("gNodeB not set for subscription")
-		log.Printf("gNodeB not set for subscription")
+		xapp.Logger.Error("gNodeB not set for subscription and E2 node discovery disabled")
+		log.Printf("gNodeB not set for subscription and E2 node discovery disabled")
 	}
 
 }
 
-func (c *Control) startTimerSubReq() {
-	timerSR := time.NewTimer(5 * time.Second)
-	count := 0
-
-	go func(t *time.Timer) {
-		defer timerSR.Stop()
-		for {
-			<-t.C
-			count++
-			xapp.Logger.Debug("send RIC_SUB_REQ to gNodeB with cnt=%d", count)
-			log.Printf("send RIC_SUB_REQ to gNodeB with cnt=%d", count)
-			err := c.sendRicSubRequest(1001, 1001, 0)
-			if err != nil && count < MAX_SUBSCRIPTION_ATTEMPTS {
-				t.Reset(5 * time.Second)
-			} else {
-				break
-			}
-		}
-	}(timerSR)
-}
-
 func (c *Control) Consume(rp *xapp.RMRParams) (err error) {
 	c.rcChan <- rp
 	return
 }
 
 func (c *Control) rmrSend(params *xapp.RMRParams) (err error) {
-	if !xapp.Rmr.Send(params, false) {
-		err = errors.New("rmr.Send() failed")
+	err = c.transport.Send(params)
+	if err != nil {
 		xapp.Logger.Error("Failed to rmrSend to %v", err)
 		log.Printf("Failed to rmrSend to %v", err)
 	}
@@ -113,8 +295,8 @@ func (c *Control) rmrSend(params *xapp.RMRParams) (err error) {
 }
 
 func (c *Control) rmrReplyToSender(params *xapp.RMRParams) (err error) {
-	if !xapp.Rmr.Send(params, true) {
-		err = errors.New("rmr.Send() failed")
+	err = c.transport.ReplyToSender(params)
+	if err != nil {
 		xapp.Logger.Error("Failed to rmrReplyToSender to %v", err)
 		log.Printf("Failed to rmrReplyToSender to %v", err)
 	}
@@ -126,21 +308,29 @@ func (c *Control) controlLoop() {
 		msg := <-c.rcChan
 		xapp.Logger.Debug("Received message type: %d", msg.Mtype)
 		log.Printf("Received message type: %d", msg.Mtype)
+		c.messages.Received(msg.Mtype)
 		switch msg.Mtype {
-		case 12050:
+		case RIC_INDICATION:
 			c.handleIndication(msg) //注意!!!
-		case 12011:
+		case RIC_SUB_RESP:
 			c.handleSubscriptionResponse(msg)
-		case 12012:
+		case RIC_SUB_FAILURE:
 			c.handleSubscriptionFailure(msg)
-		case 12021:
+		case RIC_SUB_DEL_RESP:
 			c.handleSubscriptionDeleteResponse(msg)
-		case 12022:
+		case RIC_SUB_DEL_FAILURE:
 			c.handleSubscriptionDeleteFailure(msg)
+		case RIC_CONTROL_ACK:
+			c.handleControlAcknowledge(msg)
+		case RIC_CONTROL_FAILURE:
+			c.handleControlFailure(msg)
+		case RIC_ERROR_INDICATION:
+			c.handleErrorIndication(msg)
 		default:
 			err := errors.New("Message Type " + strconv.Itoa(msg.Mtype) + " is discarded")
 			xapp.Logger.Error("Unknown message type: %v", err)
 			log.Printf("Unknown message type: %v", err)
+			c.unhandled(msg, "unknown message type")
 		}
 	}
 }
@@ -153,6 +343,13 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 	if err != nil { //skip
 		xapp.Logger.Error("Failed to decode RIC Indication message: %v", err)
 		log.Printf("Failed to decode RIC Indication message: %v", err)
+		c.unhandled(params, err.Error())
+		return
+	}
+	atomic.StoreInt64(c.indicationSN, int64(indicationMsg.IndSN))
+	c.subscriptions.Indication(params.Meid.RanName)
+	if _, drop := c.sequences.Observe(params.Meid.RanName, indicationMsg); drop {
+		log.Printf("Duplicate RIC Indication %d from {%s} dropped", indicationMsg.IndSN, params.Meid.RanName)
 		return
 	}
 	log.Printf("RIC Indication message from {%s} received", params.Meid.RanName)
@@ -166,6 +363,10 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 	log.Printf("IndicationMessage: %x", indicationMsg.IndMessage)
 	log.Printf("CallProcessID: %x", indicationMsg.CallProcessID)
 
+	if version := c.kpm.Version(params.Meid.RanName); version != kpm.VERSION_1 {
+		return c.handleKpmIndication(params.Meid.RanName, version, indicationMsg)
+	}
+
 	indicationHdr, err := e2sm.GetIndicationHeader(indicationMsg.IndHeader)
 	if err != nil {
 		xapp.Logger.Error("Failed to decode RIC Indication Header: %v", err)
@@ -185,14 +386,14 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 		log.Printf("GlobalKPMnodeIDType: %d", indHdrFormat1.GlobalKPMnodeIDType) //indHdrFormat1.GlobalKPMnodeIDType == 0
 		if indHdrFormat1.GlobalKPMnodeIDType == 1 { //skip all
-			globalKPMnodegNBID := indHdrFormat1.GlobalKPMnodeID.(GlobalKPMnodegNBIDType)
+			globalKPMnodegNBID := indHdrFormat1.GlobalKPMnodeID.(*GlobalKPMnodegNBIDType)
 
 			globalgNBID := globalKPMnodegNBID.GlobalgNBID
 
 			log.Printf("PlmnID: %x", globalgNBID.PlmnID.Buf)
 			log.Printf("gNB ID Type: %d", globalgNBID.GnbIDType)
 			if globalgNBID.GnbIDType == 1 {
-				gNBID := globalgNBID.GnbID.(GNBID)
+				gNBID := globalgNBID.GnbID.(*GNBID)
 				log.Printf("gNB ID ID: %x, Unused: %d", gNBID.Buf, gNBID.BitsUnused)
 			}
 
@@ -204,45 +405,45 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 				log.Printf("gNB-DU ID: %x", globalKPMnodegNBID.GnbDUID.Buf)
 			}
 		} else if indHdrFormat1.GlobalKPMnodeIDType == 2 {
-			globalKPMnodeengNBID := indHdrFormat1.GlobalKPMnodeID.(GlobalKPMnodeengNBIDType)
+			globalKPMnodeengNBID := indHdrFormat1.GlobalKPMnodeID.(*GlobalKPMnodeengNBIDType)
 
 			log.Printf("PlmnID: %x", globalKPMnodeengNBID.PlmnID.Buf)
 			log.Printf("en-gNB ID Type: %d", globalKPMnodeengNBID.GnbIDType)
 			if globalKPMnodeengNBID.GnbIDType == 1 {
-				engNBID := globalKPMnodeengNBID.GnbID.(ENGNBID)
+				engNBID := globalKPMnodeengNBID.GnbID.(*ENGNBID)
 				log.Printf("en-gNB ID ID: %x, Unused: %d", engNBID.Buf, engNBID.BitsUnused)
 			}
 		} else if indHdrFormat1.GlobalKPMnodeIDType == 3 {
-			globalKPMnodengeNBID := indHdrFormat1.GlobalKPMnodeID.(GlobalKPMnodengeNBIDType)
+			globalKPMnodengeNBID := indHdrFormat1.GlobalKPMnodeID.(*GlobalKPMnodengeNBIDType)
 
 			log.Printf("PlmnID: %x", globalKPMnodengeNBID.PlmnID.Buf)
 			log.Printf("ng-eNB ID Type: %d", globalKPMnodengeNBID.EnbIDType)
 			if globalKPMnodengeNBID.EnbIDType == 1 {
-				ngeNBID := globalKPMnodengeNBID.EnbID.(NGENBID_Macro)
+				ngeNBID := globalKPMnodengeNBID.EnbID.(*NGENBID_Macro)
 				log.Printf("ng-eNB ID ID: %x, Unused: %d", ngeNBID.Buf, ngeNBID.BitsUnused)
 			} else if globalKPMnodengeNBID.EnbIDType == 2 {
-				ngeNBID := globalKPMnodengeNBID.EnbID.(NGENBID_ShortMacro)
+				ngeNBID := globalKPMnodengeNBID.EnbID.(*NGENBID_ShortMacro)
 				log.Printf("ng-eNB ID ID: %x, Unused: %d", ngeNBID.Buf, ngeNBID.BitsUnused)
 			} else if globalKPMnodengeNBID.EnbIDType == 3 {
-				ngeNBID := globalKPMnodengeNBID.EnbID.(NGENBID_LongMacro)
+				ngeNBID := globalKPMnodengeNBID.EnbID.(*NGENBID_LongMacro)
 				log.Printf("ng-eNB ID ID: %x, Unused: %d", ngeNBID.Buf, ngeNBID.BitsUnused)
 			}
 		} else if indHdrFormat1.GlobalKPMnodeIDType == 4 {
-			globalKPMnodeeNBID := indHdrFormat1.GlobalKPMnodeID.(GlobalKPMnodeeNBIDType)
+			globalKPMnodeeNBID := indHdrFormat1.GlobalKPMnodeID.(*GlobalKPMnodeeNBIDType)
 
 			log.Printf("PlmnID: %x", globalKPMnodeeNBID.PlmnID.Buf)
 			log.Printf("eNB ID Type: %d", globalKPMnodeeNBID.EnbIDType)
 			if globalKPMnodeeNBID.EnbIDType == 1 {
-				eNBID := globalKPMnodeeNBID.EnbID.(ENBID_Macro)
+				eNBID := globalKPMnodeeNBID.EnbID.(*ENBID_Macro)
 				log.Printf("eNB ID ID: %x, Unused: %d", eNBID.Buf, eNBID.BitsUnused)
 			} else if globalKPMnodeeNBID.EnbIDType == 2 {
-				eNBID := globalKPMnodeeNBID.EnbID.(ENBID_Home)
+				eNBID := globalKPMnodeeNBID.EnbID.(*ENBID_Home)
 				log.Printf("eNB ID ID: %x, Unused: %d", eNBID.Buf, eNBID.BitsUnused)
 			} else if globalKPMnodeeNBID.EnbIDType == 3 {
-				eNBID := globalKPMnodeeNBID.EnbID.(ENBID_ShortMacro)
+				eNBID := globalKPMnodeeNBID.EnbID.(*ENBID_ShortMacro)
 				log.Printf("eNB ID ID: %x, Unused: %d", eNBID.Buf, eNBID.BitsUnused)
 			} else if globalKPMnodeeNBID.EnbIDType == 4 {
-				eNBID := globalKPMnodeeNBID.EnbID.(ENBID_LongMacro)
+				eNBID := globalKPMnodeeNBID.EnbID.(*ENBID_LongMacro)
 				log.Printf("eNB ID ID: %x, Unused: %d", eNBID.Buf, eNBID.BitsUnused)
 			}
 
@@ -336,9 +537,9 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 		indMsgFormat1 := indMsg.IndMsg.(*IndicationMessageFormat1)
 
-		log.Printf("PMContainerCount: %d", indMsgFormat1.PMContainerCount) //indMsgFormat1.PMContainerCount == 3 must be 3
+		log.Printf("PMContainerCount: %d", len(indMsgFormat1.PMContainers)) //PMContainerCount == 3 must be 3
 
-		for i := 0; i < indMsgFormat1.PMContainerCount; i++ { //0,1,2
+		for i := 0; i < len(indMsgFormat1.PMContainers); i++ { //0,1,2
 			flag = false
 			timestampPDCPBytes = nil
 			dlPDCPBytes = -1
@@ -361,7 +562,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 					oDU := pmContainer.PFContainer.Container.(*ODUPFContainerType)
 
-					cellResourceReportCount := oDU.CellResourceReportCount
+					cellResourceReportCount := len(oDU.CellResourceReports)
 					log.Printf("CellResourceReportCount: %d", cellResourceReportCount) //cellResourceReportCount == 1
 
 					for j := 0; j < cellResourceReportCount; j++ {
@@ -390,7 +591,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							availPRBUL = cellResourceReport.TotalofAvailablePRBs.UL
 						}
 
-						servedPlmnPerCellCount := cellResourceReport.ServedPlmnPerCellCount
+						servedPlmnPerCellCount := len(cellResourceReport.ServedPlmnPerCells)
 						log.Printf("ServedPlmnPerCellCount: %d", servedPlmnPerCellCount) // ServedPlmnPerCellCount: 1
 
 						for k := 0; k < servedPlmnPerCellCount; k++ {
@@ -401,7 +602,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							log.Printf("PlmnID: %x", servedPlmnPerCell.PlmnID.Buf) // PlmnID: 
 							//skip
 							if servedPlmnPerCell.DUPM5GC != nil {
-								slicePerPlmnPerCellCount := servedPlmnPerCell.DUPM5GC.SlicePerPlmnPerCellCount
+								slicePerPlmnPerCellCount := len(servedPlmnPerCell.DUPM5GC.SlicePerPlmnPerCells)
 								log.Printf("SlicePerPlmnPerCellCount: %d", slicePerPlmnPerCellCount)
 
 								for l := 0; l < slicePerPlmnPerCellCount; l++ {
@@ -414,7 +615,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 										log.Printf("SliceID.sD: %x", slicePerPlmnPerCell.SliceID.SD.Buf)
 									}
 
-									fQIPERSlicesPerPlmnPerCellCount := slicePerPlmnPerCell.FQIPERSlicesPerPlmnPerCellCount
+									fQIPERSlicesPerPlmnPerCellCount := len(slicePerPlmnPerCell.FQIPERSlicesPerPlmnPerCells)
 									log.Printf("5QIPerSlicesPerPlmnPerCellCount: %d", fQIPERSlicesPerPlmnPerCellCount)
 
 									for m := 0; m < fQIPERSlicesPerPlmnPerCellCount; m++ {
@@ -430,7 +631,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							}
 							//skip
 							if servedPlmnPerCell.DUPMEPC != nil {
-								perQCIReportCount := servedPlmnPerCell.DUPMEPC.PerQCIReportCount
+								perQCIReportCount := len(servedPlmnPerCell.DUPMEPC.PerQCIReports)
 								log.Printf("PerQCIReportCount: %d", perQCIReportCount)
 
 								for l := 0; l < perQCIReportCount; l++ {
@@ -464,7 +665,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 						log.Printf("gNB-CU-UP Name: %x", oCUUP.GNBCUUPName.Buf)
 					}
 
-					cuUPPFContainerItemCount := oCUUP.CUUPPFContainerItemCount
+					cuUPPFContainerItemCount := len(oCUUP.CUUPPFContainerItems)
 					log.Printf("CU-UP PF Container Item Count: %d", cuUPPFContainerItemCount) // CU-UP PF Container Item Count: 1
 
 					for j := 0; j < cuUPPFContainerItemCount; j++ {
@@ -474,7 +675,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 						log.Printf("InterfaceType: %d", cuUPPFContainerItem.InterfaceType) // InterfaceType: 2
 
-						cuUPPlmnCount := cuUPPFContainerItem.OCUUPPMContainer.CUUPPlmnCount
+						cuUPPlmnCount := len(cuUPPFContainerItem.OCUUPPMContainer.CUUPPlmns)
 						log.Printf("CU-UP Plmn Count: %d", cuUPPlmnCount) // CU-UP Plmn Count: 1
 
 						for k := 0; k < cuUPPlmnCount; k++ {
@@ -492,7 +693,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							}
 							//skip
 							if cuUPPlmn.CUUPPM5GC != nil {
-								sliceToReportCount := cuUPPlmn.CUUPPM5GC.SliceToReportCount
+								sliceToReportCount := len(cuUPPlmn.CUUPPM5GC.SliceToReports)
 								log.Printf("SliceToReportCount: %d", sliceToReportCount)
 
 								for l := 0; l < sliceToReportCount; l++ {
@@ -512,7 +713,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 										continue
 									}
 
-									fQIPERSlicesPerPlmnCount := sliceToReport.FQIPERSlicesPerPlmnCount
+									fQIPERSlicesPerPlmnCount := len(sliceToReport.FQIPERSlicesPerPlmns)
 									log.Printf("5QIPerSlicesPerPlmnCount: %d", fQIPERSlicesPerPlmnCount)
 
 									for m := 0; m < fQIPERSlicesPerPlmnCount; m++ {
@@ -557,7 +758,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							}
 							//get into when NumberOfActiveUEs != 0, opening UL & DL
 							if cuUPPlmn.CUUPPMEPC != nil {
-								cuUPPMEPCPerQCIReportCount := cuUPPlmn.CUUPPMEPC.CUUPPMEPCPerQCIReportCount
+								cuUPPMEPCPerQCIReportCount := len(cuUPPlmn.CUUPPMEPC.CUUPPMEPCPerQCIReports)
 								log.Printf("PerQCIReportCount: %d", cuUPPMEPCPerQCIReportCount) //PerQCIReportCount: 1
 
 								for l := 0; l < cuUPPMEPCPerQCIReportCount; l++ {
@@ -583,63 +784,16 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 					continue
 				}
 			}
-			/*var attacker_output_file, err = os.OpenFile("attacker.txt", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
-			if err != nil {
-				panic(err)
-			}*/
-			attacker_output_file, err := os.OpenFile("attacker.txt", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
-			if err != nil{
-				fmt.Println("Open file err =", err)
-			}
-			fmt.Printf("--------------------EXP START--------------------\n")
-			var ueMetrics *UeMetricsEntry
-			ueMetrics = &UeMetricsEntry{}
-			ueMetrics.MeasTimestampPRB.TVsec = 109
-			ueMetrics.MeasTimestampPRB.TVnsec = 110
-			var my_ueid int64 = 1001
-			for k := 0; k < 10; k++ {
-				ueMetrics.ServingCellID = string('A' + k)
-				fmt.Printf("%#v\n", ueMetrics)
-				
-				newUeJsonStr, err := json.Marshal(ueMetrics)
-				n, err := attacker_output_file.Write([]byte(newUeJsonStr))
-				m, err_ := attacker_output_file.WriteString("\n")
-				if err != nil {
-					fmt.Println("Write file err =", err)
-				}
-				fmt.Println("Write file success, n =", n)
-				if err_ != nil {
-					fmt.Println("Write file err =", err)
-				}
-				fmt.Println("Write file success, m =", m)
-				err = c.client.Set(strconv.FormatInt(my_ueid, 10), newUeJsonStr, 0).Err()
-				if err != nil {
-					fmt.Printf("--------------------EXP Insert Failed--------------------\n")
-				}
-				ueMetrics.MeasTimestampPRB.TVsec = ueMetrics.MeasTimestampPRB.TVsec + 1
-				ueMetrics.MeasTimestampPRB.TVnsec = ueMetrics.MeasTimestampPRB.TVnsec + 1
-				my_ueid = my_ueid + 1
-				time.Sleep(2 * time.Second)
-			}
-
-			var my_ueid_ int64 = 1001
-			for k := 0; k < 10; k++ {
-				err = c.client.Del(strconv.FormatInt(my_ueid_, 10)).Err()
-				if err != nil {
-					fmt.Printf("--------------------EXP Delete Failed--------------------\n")
-				}
-				my_ueid_ = my_ueid_ + 1
-				time.Sleep(2 * time.Second)
-			}
-			fmt.Printf("--------------------Sleep--------------------\n")
-			time.Sleep(10 * time.Second)
-			fmt.Printf("--------------------EXP END--------------------\n")
-			//defer attacker_output_file.Close()
 			//skip
 			if pmContainer.RANContainer != nil {
 				log.Printf("RANContainer: %x", pmContainer.RANContainer.Timestamp.Buf)
 
-				timestamp, _ := e2sm.ParseTimestamp(pmContainer.RANContainer.Timestamp.Buf, pmContainer.RANContainer.Timestamp.Size)
+				timestamp, err := e2sm.ParseTimestamp(pmContainer.RANContainer.Timestamp.Buf, pmContainer.RANContainer.Timestamp.Size)
+				if err != nil {
+					xapp.Logger.Error("Failed to parse Timestamp of RAN Container: %v", err)
+					log.Printf("Failed to parse Timestamp of RAN Container: %v", err)
+					continue
+				}
 				log.Printf("Timestamp=[sec: %d, nsec: %d]", timestamp.TVsec, timestamp.TVnsec)
 
 				containerType = pmContainer.RANContainer.ContainerType
@@ -648,7 +802,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 					oDUUE := pmContainer.RANContainer.Container.(DUUsageReportType)
 
-					for j := 0; j < oDUUE.CellResourceReportItemCount; j++ {
+					for j := 0; j < len(oDUUE.CellResourceReportItems); j++ {
 						cellResourceReportItem := oDUUE.CellResourceReportItems[j]
 
 						log.Printf("nRCGI.PlmnID: %x", cellResourceReportItem.NRCGI.PlmnID.Buf)
@@ -661,7 +815,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							continue
 						}
 
-						for k := 0; k < cellResourceReportItem.UeResourceReportItemCount; k++ {
+						for k := 0; k < len(cellResourceReportItem.UeResourceReportItems); k++ {
 							ueResourceReportItem := cellResourceReportItem.UeResourceReportItems[k]
 
 							log.Printf("C-RNTI: %x", ueResourceReportItem.CRNTI.Buf)
@@ -673,11 +827,15 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 								continue
 							}
 
+							if c.consistency != nil {
+								c.consistency.Observe(REPORT_DU, strconv.FormatInt(ueID, 10), servingCellID, params.Meid.RanName)
+							}
+
 							var ueMetrics *UeMetricsEntry
-							if isUeExist, _ := c.client.Exists(strconv.FormatInt(ueID, 10)).Result(); isUeExist == 1 {
-								ueJsonStr, _ := c.client.Get(strconv.FormatInt(ueID, 10)).Result()
-								json.Unmarshal([]byte(ueJsonStr), ueMetrics)
-							} else {
+							if isUeExist, _ := c.store.ExistsUe(strconv.FormatInt(ueID, 10)); isUeExist {
+								ueMetrics, _ = c.store.GetUe(strconv.FormatInt(ueID, 10))
+							}
+							if ueMetrics == nil {
 								ueMetrics = &UeMetricsEntry{}
 							}
 
@@ -698,16 +856,10 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 								ueMetrics.PRBUsageUL = ueResourceReportItem.PRBUsageUL
 							}
 
-							newUeJsonStr, err := json.Marshal(ueMetrics)
-							if err != nil {
-								xapp.Logger.Error("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								continue
-							}
-							err = c.client.Set(strconv.FormatInt(ueID, 10), newUeJsonStr, 0).Err()
+							err = c.store.PutUe(strconv.FormatInt(ueID, 10), ueMetrics)
 							if err != nil {
-								xapp.Logger.Error("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
+								xapp.Logger.Error("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
+								log.Printf("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
 								continue
 							}
 						}
@@ -717,7 +869,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 					oCUCPUE := pmContainer.RANContainer.Container.(CUCPUsageReportType)
 
-					for j := 0; j < oCUCPUE.CellResourceReportItemCount; j++ {
+					for j := 0; j < len(oCUCPUE.CellResourceReportItems); j++ {
 						cellResourceReportItem := oCUCPUE.CellResourceReportItems[j]
 
 						log.Printf("nRCGI.PlmnID: %x", cellResourceReportItem.NRCGI.PlmnID.Buf)
@@ -730,23 +882,27 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							continue
 						}
 
-						for k := 0; k < cellResourceReportItem.UeResourceReportItemCount; k++ {
+						for k := 0; k < len(cellResourceReportItem.UeResourceReportItems); k++ {
 							ueResourceReportItem := cellResourceReportItem.UeResourceReportItems[k]
 
 							log.Printf("C-RNTI: %x", ueResourceReportItem.CRNTI.Buf)
 
 							ueID, err := e2sm.ParseInteger(ueResourceReportItem.CRNTI.Buf, ueResourceReportItem.CRNTI.Size)
 							if err != nil {
-								xapp.Logger.Error("Failed to parse C-RNTI in CU-CP Usage Report with Serving Cell ID [%s]: %v", err)
-								log.Printf("Failed to parse C-RNTI in CU-CP Usage Report with Serving Cell ID [%s]: %v", err)
+								xapp.Logger.Error("Failed to parse C-RNTI in CU-CP Usage Report with Serving Cell ID [%s]: %v", servingCellID, err)
+								log.Printf("Failed to parse C-RNTI in CU-CP Usage Report with Serving Cell ID [%s]: %v", servingCellID, err)
 								continue
 							}
 
+							if c.consistency != nil {
+								c.consistency.Observe(REPORT_CU_CP, strconv.FormatInt(ueID, 10), servingCellID, params.Meid.RanName)
+							}
+
 							var ueMetrics *UeMetricsEntry
-							if isUeExist, _ := c.client.Exists(strconv.FormatInt(ueID, 10)).Result(); isUeExist == 1 {
-								ueJsonStr, _ := c.client.Get(strconv.FormatInt(ueID, 10)).Result()
-								json.Unmarshal([]byte(ueJsonStr), ueMetrics)
-							} else {
+							if isUeExist, _ := c.store.ExistsUe(strconv.FormatInt(ueID, 10)); isUeExist {
+								ueMetrics, _ = c.store.GetUe(strconv.FormatInt(ueID, 10))
+							}
+							if ueMetrics == nil {
 								ueMetrics = &UeMetricsEntry{}
 							}
 
@@ -758,8 +914,8 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							if ueResourceReportItem.ServingCellRF != nil {
 								err = json.Unmarshal(ueResourceReportItem.ServingCellRF.Buf, &ueMetrics.ServingCellRF)
 								if err != nil {
-									xapp.Logger.Error("Failed to Unmarshal ServingCellRF in CU-CP Usage Report with UE ID [%s]: %v", ueID, err)
-									log.Printf("Failed to Unmarshal ServingCellRF in CU-CP Usage Report with UE ID [%s]: %v", ueID, err)
+									xapp.Logger.Error("Failed to Unmarshal ServingCellRF in CU-CP Usage Report with UE ID [%d]: %v", ueID, err)
+									log.Printf("Failed to Unmarshal ServingCellRF in CU-CP Usage Report with UE ID [%d]: %v", ueID, err)
 									continue
 								}
 							}
@@ -767,22 +923,16 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							if ueResourceReportItem.NeighborCellRF != nil {
 								err = json.Unmarshal(ueResourceReportItem.NeighborCellRF.Buf, &ueMetrics.NeighborCellsRF)
 								if err != nil {
-									xapp.Logger.Error("Failed to Unmarshal NeighborCellRF in CU-CP Usage Report with UE ID [%s]: %v", ueID, err)
-									log.Printf("Failed to Unmarshal NeighborCellRF in CU-CP Usage Report with UE ID [%s]: %v", ueID, err)
+									xapp.Logger.Error("Failed to Unmarshal NeighborCellRF in CU-CP Usage Report with UE ID [%d]: %v", ueID, err)
+									log.Printf("Failed to Unmarshal NeighborCellRF in CU-CP Usage Report with UE ID [%d]: %v", ueID, err)
 									continue
 								}
 							}
 
-							newUeJsonStr, err := json.Marshal(ueMetrics)
+							err = c.store.PutUe(strconv.FormatInt(ueID, 10), ueMetrics)
 							if err != nil {
-								xapp.Logger.Error("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								continue
-							}
-							err = c.client.Set(strconv.FormatInt(ueID, 10), newUeJsonStr, 0).Err()
-							if err != nil {
-								xapp.Logger.Error("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
+								xapp.Logger.Error("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
+								log.Printf("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
 								continue
 							}
 						}
@@ -792,7 +942,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 
 					oCUUPUE := pmContainer.RANContainer.Container.(CUUPUsageReportType)
 
-					for j := 0; j < oCUUPUE.CellResourceReportItemCount; j++ {
+					for j := 0; j < len(oCUUPUE.CellResourceReportItems); j++ {
 						cellResourceReportItem := oCUUPUE.CellResourceReportItems[j]
 
 						log.Printf("nRCGI.PlmnID: %x", cellResourceReportItem.NRCGI.PlmnID.Buf)
@@ -805,7 +955,7 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							continue
 						}
 
-						for k := 0; k < cellResourceReportItem.UeResourceReportItemCount; k++ {
+						for k := 0; k < len(cellResourceReportItem.UeResourceReportItems); k++ {
 							ueResourceReportItem := cellResourceReportItem.UeResourceReportItems[k]
 
 							log.Printf("C-RNTI: %x", ueResourceReportItem.CRNTI.Buf)
@@ -817,11 +967,15 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 								continue
 							}
 
+							if c.consistency != nil {
+								c.consistency.Observe(REPORT_CU_UP, strconv.FormatInt(ueID, 10), servingCellID, params.Meid.RanName)
+							}
+
 							var ueMetrics *UeMetricsEntry
-							if isUeExist, _ := c.client.Exists(strconv.FormatInt(ueID, 10)).Result(); isUeExist == 1 {
-								ueJsonStr, _ := c.client.Get(strconv.FormatInt(ueID, 10)).Result()
-								json.Unmarshal([]byte(ueJsonStr), ueMetrics)
-							} else {
+							if isUeExist, _ := c.store.ExistsUe(strconv.FormatInt(ueID, 10)); isUeExist {
+								ueMetrics, _ = c.store.GetUe(strconv.FormatInt(ueID, 10))
+							}
+							if ueMetrics == nil {
 								ueMetrics = &UeMetricsEntry{}
 							}
 
@@ -837,8 +991,8 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							if ueResourceReportItem.PDCPBytesDL != nil {
 								ueMetrics.PDCPBytesDL, err = e2sm.ParseInteger(ueResourceReportItem.PDCPBytesDL.Buf, ueResourceReportItem.PDCPBytesDL.Size)
 								if err != nil {
-									xapp.Logger.Error("Failed to parse PDCPBytesDL in CU-UP Usage Report with UE ID [%s]: %v", ueID, err)
-									log.Printf("Failed to parse PDCPBytesDL in CU-UP Usage Report with UE ID [%s]: %v", ueID, err)
+									xapp.Logger.Error("Failed to parse PDCPBytesDL in CU-UP Usage Report with UE ID [%d]: %v", ueID, err)
+									log.Printf("Failed to parse PDCPBytesDL in CU-UP Usage Report with UE ID [%d]: %v", ueID, err)
 									continue
 								}
 							}
@@ -846,22 +1000,16 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 							if ueResourceReportItem.PDCPBytesUL != nil {
 								ueMetrics.PDCPBytesUL, err = e2sm.ParseInteger(ueResourceReportItem.PDCPBytesUL.Buf, ueResourceReportItem.PDCPBytesUL.Size)
 								if err != nil {
-									xapp.Logger.Error("Failed to parse PDCPBytesUL in CU-UP Usage Report with UE ID [%s]: %v", ueID, err)
-									log.Printf("Failed to parse PDCPBytesUL in CU-UP Usage Report with UE ID [%s]: %v", ueID, err)
+									xapp.Logger.Error("Failed to parse PDCPBytesUL in CU-UP Usage Report with UE ID [%d]: %v", ueID, err)
+									log.Printf("Failed to parse PDCPBytesUL in CU-UP Usage Report with UE ID [%d]: %v", ueID, err)
 									continue
 								}
 							}
 
-							newUeJsonStr, err := json.Marshal(ueMetrics)
+							err = c.store.PutUe(strconv.FormatInt(ueID, 10), ueMetrics)
 							if err != nil {
-								xapp.Logger.Error("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to marshal UeMetrics with UE ID [%s]: %v", ueID, err)
-								continue
-							}
-							err = c.client.Set(strconv.FormatInt(ueID, 10), newUeJsonStr, 0).Err()
-							if err != nil {
-								xapp.Logger.Error("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
-								log.Printf("Failed to set UeMetrics into redis with UE ID [%s]: %v", ueID, err)
+								xapp.Logger.Error("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
+								log.Printf("Failed to set UeMetrics into store with UE ID [%d]: %v", ueID, err)
 								continue
 							}
 						}
@@ -875,10 +1023,10 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 			//skip
 			if flag {
 				var cellMetrics *CellMetricsEntry
-				if isCellExist, _ := c.client.Exists(cellIDHdr).Result(); isCellExist == 1 {
-					cellJsonStr, _ := c.client.Get(cellIDHdr).Result()
-					json.Unmarshal([]byte(cellJsonStr), cellMetrics)
-				} else {
+				if isCellExist, _ := c.store.ExistsCell(cellIDHdr); isCellExist {
+					cellMetrics, _ = c.store.GetCell(cellIDHdr)
+				}
+				if cellMetrics == nil {
 					cellMetrics = &CellMetricsEntry{}
 				}
 
@@ -903,16 +1051,10 @@ func (c *Control) handleIndication(params *xapp.RMRParams) (err error) {
 					cellMetrics.AvailPRBUL = availPRBUL
 				}
 
-				newCellJsonStr, err := json.Marshal(cellMetrics)
-				if err != nil {
-					xapp.Logger.Error("Failed to marshal CellMetrics with CellID [%s]: %v", cellIDHdr, err)
-					log.Printf("Failed to marshal CellMetrics with CellID [%s]: %v", cellIDHdr, err)
-					continue
-				}
-				err = c.client.Set(cellIDHdr, newCellJsonStr, 0).Err()
+				err = c.store.PutCell(cellIDHdr, cellMetrics)
 				if err != nil {
-					xapp.Logger.Error("Failed to set CellMetrics into redis with CellID [%s]: %v", cellIDHdr, err)
-					log.Printf("Failed to set CellMetrics into redis with CellID [%s]: %v", cellIDHdr, err)
+					xapp.Logger.Error("Failed to set CellMetrics into store with CellID [%s]: %v", cellIDHdr, err)
+					log.Printf("Failed to set CellMetrics into store with CellID [%s]: %v", cellIDHdr, err)
 					continue
 				}
 			}
@@ -930,24 +1072,14 @@ func (c *Control) handleSubscriptionResponse(params *xapp.RMRParams) (err error)
 	xapp.Logger.Debug("The SubId in RIC_SUB_RESP is %d", params.SubId)
 	log.Printf("The SubId in RIC_SUB_RESP is %d", params.SubId)
 
-	ranName := params.Meid.RanName
-	c.eventCreateExpiredMu.Lock()
-	_, ok := c.eventCreateExpiredMap[ranName]
-	if !ok {
-		c.eventCreateExpiredMu.Unlock()
-		xapp.Logger.Debug("RIC_SUB_REQ has been deleted!")
-		log.Printf("RIC_SUB_REQ has been deleted!")
-		return nil
-	} else {
-		c.eventCreateExpiredMap[ranName] = true
-		c.eventCreateExpiredMu.Unlock()
-	}
+	c.subscriptions.Responded(params.Meid.RanName)
 
 	var cep *E2ap
 	subscriptionResp, err := cep.GetSubscriptionResponseMessage(params.Payload)
 	if err != nil {
 		xapp.Logger.Error("Failed to decode RIC Subscription Response message: %v", err)
 		log.Printf("Failed to decode RIC Subscription Response message: %v", err)
+		c.unhandled(params, err.Error())
 		return
 	}
 
@@ -958,12 +1090,12 @@ func (c *Control) handleSubscriptionResponse(params *xapp.RMRParams) (err error)
 	log.Printf("FunctionID: %d", subscriptionResp.FuncID)
 
 	log.Printf("ActionAdmittedList:")
-	for index := 0; index < subscriptionResp.ActionAdmittedList.Count; index++ {
+	for index := 0; index < len(subscriptionResp.ActionAdmittedList.ActionID); index++ {
 		log.Printf("[%d]ActionID: %d", index, subscriptionResp.ActionAdmittedList.ActionID[index])
 	}
 
 	log.Printf("ActionNotAdmittedList:")
-	for index := 0; index < subscriptionResp.ActionNotAdmittedList.Count; index++ {
+	for index := 0; index < len(subscriptionResp.ActionNotAdmittedList.ActionID); index++ {
 		log.Printf("[%d]ActionID: %d", index, subscriptionResp.ActionNotAdmittedList.ActionID[index])
 		log.Printf("[%d]CauseType: %d    CauseID: %d", index, subscriptionResp.ActionNotAdmittedList.Cause[index].CauseType, subscriptionResp.ActionNotAdmittedList.Cause[index].CauseID)
 	}
@@ -976,37 +1108,44 @@ func (c *Control) handleSubscriptionFailure(params *xapp.RMRParams) (err error)
 	log.Printf("The SubId in RIC_SUB_FAILURE is %d", params.SubId)
 
 	ranName := params.Meid.RanName
-	c.eventCreateExpiredMu.Lock()
-	_, ok := c.eventCreateExpiredMap[ranName]
-	if !ok {
-		c.eventCreateExpiredMu.Unlock()
-		xapp.Logger.Debug("RIC_SUB_REQ has been deleted!")
-		log.Printf("RIC_SUB_REQ has been deleted!")
-		return nil
-	} else {
-		c.eventCreateExpiredMap[ranName] = true
-		c.eventCreateExpiredMu.Unlock()
+	var cep *E2ap
+	subscriptionFailure, err := cep.GetSubscriptionFailureMessage(params.Payload)
+	if err != nil {
+		xapp.Logger.Error("Failed to decode RIC Subscription Failure message: %v", err)
+		log.Printf("Failed to decode RIC Subscription Failure message: %v", err)
+		c.unhandled(params, err.Error())
+		subscriptionFailure = nil
 	}
 
-	return nil
+	reason := "undecodable RIC_SUB_FAILURE"
+	if subscriptionFailure != nil {
+		reason = FailureString(subscriptionFailure)
+	}
+	decision := c.subscriptions.decide(ranName, subscriptionFailure)
+	xapp.Logger.Warn("{%s} %s, decision: %s", ranName, reason, enumName(failureDecisionNames, int32(decision)))
+	log.Printf("{%s} %s, decision: %s", ranName, reason, enumName(failureDecisionNames, int32(decision)))
+
+	switch decision {
+	case FAILURE_FALLBACK:
+		if profile := c.kpm.Fallback(ranName); profile != nil {
+			c.subscriptions.Changed(ranName, "fallback profile "+profile.Name)
+			return err
+		}
+		c.subscriptions.Failed(ranName, reason+", no fallback profile", false)
+	case FAILURE_GIVE_UP:
+		c.subscriptions.Failed(ranName, reason, false)
+	default:
+		c.subscriptions.Failed(ranName, reason, true)
+	}
+
+	return err
 }
 
 func (c *Control) handleSubscriptionDeleteResponse(params *xapp.RMRParams) (err error) {
 	xapp.Logger.Debug("The SubId in RIC_SUB_DEL_RESP is %d", params.SubId)
 	log.Printf("The SubId in RIC_SUB_DEL_RESP is %d", params.SubId)
 
-	ranName := params.Meid.RanName
-	c.eventDeleteExpiredMu.Lock()
-	_, ok := c.eventDeleteExpiredMap[ranName]
-	if !ok {
-		c.eventDeleteExpiredMu.Unlock()
-		xapp.Logger.Debug("RIC_SUB_DEL_REQ has been deleted!")
-		log.Printf("RIC_SUB_DEL_REQ has been deleted!")
-		return nil
-	} else {
-		c.eventDeleteExpiredMap[ranName] = true
-		c.eventDeleteExpiredMu.Unlock()
-	}
+	c.subscriptions.Deleted(params.Meid.RanName)
 
 	return nil
 }
@@ -1016,212 +1155,225 @@ func (c *Control) handleSubscriptionDeleteFailure(params *xapp.RMRParams) (err e
 	log.Printf("The SubId in RIC_SUB_DEL_FAILURE is %d", params.SubId)
 
 	ranName := params.Meid.RanName
-	c.eventDeleteExpiredMu.Lock()
-	_, ok := c.eventDeleteExpiredMap[ranName]
-	if !ok {
-		c.eventDeleteExpiredMu.Unlock()
-		xapp.Logger.Debug("RIC_SUB_DEL_REQ has been deleted!")
-		log.Printf("RIC_SUB_DEL_REQ has been deleted!")
-		return nil
+	var cep *E2ap
+	deleteFailure, err := cep.GetSubscriptionDeleteFailureMessage(params.Payload)
+	if err != nil {
+		xapp.Logger.Error("Failed to decode RIC Subscription Delete Failure message: %v", err)
+		log.Printf("Failed to decode RIC Subscription Delete Failure message: %v", err)
+		c.unhandled(params, err.Error())
+		c.subscriptions.DeleteFailed(ranName, "undecodable RIC_SUB_DEL_FAILURE")
+		return
+	}
+
+	reason := "RIC_SUB_DEL_FAILURE " + CauseName(deleteFailure.Cause) + "; criticality diagnostics: " + CriticalityDiagnosticsString(deleteFailure.CriticalityDiagnostics)
+	xapp.Logger.Warn("{%s} %s", ranName, reason)
+	log.Printf("{%s} %s", ranName, reason)
+	if deleteFailure.Cause.CauseType == CAUSE_RIC_REQUEST && deleteFailure.Cause.CauseID == CAUSE_RIC_REQUEST_ID_UNKNOWN { //nothing left to delete
+		c.subscriptions.Deleted(ranName)
 	} else {
-		c.eventDeleteExpiredMap[ranName] = true
-		c.eventDeleteExpiredMu.Unlock()
+		c.subscriptions.DeleteFailed(ranName, reason)
 	}
 
 	return nil
 }
 
-func (c *Control) setEventCreateExpiredTimer(ranName string) {
-	c.eventCreateExpiredMu.Lock()
-	c.eventCreateExpiredMap[ranName] = false
-	c.eventCreateExpiredMu.Unlock()
-
-	timer := time.NewTimer(time.Duration(c.eventCreateExpired) * time.Second)
-	go func(t *time.Timer) {
-		defer t.Stop()
-		xapp.Logger.Debug("RIC_SUB_REQ[%s]: Waiting for RIC_SUB_RESP...", ranName)
-		log.Printf("RIC_SUB_REQ[%s]: Waiting for RIC_SUB_RESP...", ranName)
-		for {
-			select {
-			case <-t.C:
-				c.eventCreateExpiredMu.Lock()
-				isResponsed := c.eventCreateExpiredMap[ranName]
-				delete(c.eventCreateExpiredMap, ranName)
-				c.eventCreateExpiredMu.Unlock()
-				if !isResponsed {
-					xapp.Logger.Debug("RIC_SUB_REQ[%s]: RIC Event Create Timer experied!", ranName)
-					log.Printf("RIC_SUB_REQ[%s]: RIC Event Create Timer experied!", ranName)
-					// c.sendRicSubDelRequest(subID, requestSN, funcID)
-					return
-				}
-			default:
-				c.eventCreateExpiredMu.Lock()
-				flag := c.eventCreateExpiredMap[ranName]
-				if flag {
-					delete(c.eventCreateExpiredMap, ranName)
-					c.eventCreateExpiredMu.Unlock()
-					xapp.Logger.Debug("RIC_SUB_REQ[%s]: RIC Event Create Timer canceled!", ranName)
-					log.Printf("RIC_SUB_REQ[%s]: RIC Event Create Timer canceled!", ranName)
-					return
-				} else {
-					c.eventCreateExpiredMu.Unlock()
-				}
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
-	}(timer)
-}
+func (c *Control) handleControlAcknowledge(params *xapp.RMRParams) (err error) {
+	ranName := params.Meid.RanName
+	var e2ap *E2ap
+	ack, err := e2ap.GetControlAcknowledgeMessage(params.Payload)
+	if err != nil {
+		xapp.Logger.Error("Failed to decode RIC Control Acknowledge message: %v", err)
+		log.Printf("Failed to decode RIC Control Acknowledge message: %v", err)
+		c.unhandled(params, err.Error())
+		return
+	}
 
-func (c *Control) setEventDeleteExpiredTimer(ranName string) {
-	c.eventDeleteExpiredMu.Lock()
-	c.eventDeleteExpiredMap[ranName] = false
-	c.eventDeleteExpiredMu.Unlock()
-
-	timer := time.NewTimer(time.Duration(c.eventDeleteExpired) * time.Second)
-	go func(t *time.Timer) {
-		defer t.Stop()
-		xapp.Logger.Debug("RIC_SUB_DEL_REQ[%s]: Waiting for RIC_SUB_DEL_RESP...", ranName)
-		log.Printf("RIC_SUB_DEL_REQ[%s]: Waiting for RIC_SUB_DEL_RESP...", ranName)
-		for {
-			select {
-			case <-t.C:
-				c.eventDeleteExpiredMu.Lock()
-				isResponsed := c.eventDeleteExpiredMap[ranName]
-				delete(c.eventDeleteExpiredMap, ranName)
-				c.eventDeleteExpiredMu.Unlock()
-				if !isResponsed {
-					xapp.Logger.Debug("RIC_SUB_DEL_REQ[%s]: RIC Event Delete Timer experied!", ranName)
-					log.Printf("RIC_SUB_DEL_REQ[%s]: RIC Event Delete Timer experied!", ranName)
-					return
-				}
-			default:
-				c.eventDeleteExpiredMu.Lock()
-				flag := c.eventDeleteExpiredMap[ranName]
-				if flag {
-					delete(c.eventDeleteExpiredMap, ranName)
-					c.eventDeleteExpiredMu.Unlock()
-					xapp.Logger.Debug("RIC_SUB_DEL_REQ[%s]: RIC Event Delete Timer canceled!", ranName)
-					log.Printf("RIC_SUB_DEL_REQ[%s]: RIC Event Delete Timer canceled!", ranName)
-					return
-				} else {
-					c.eventDeleteExpiredMu.Unlock()
-				}
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
-	}(timer)
+	xapp.Logger.Debug("RIC_CONTROL_ACK %d/%d from {%s}, RAN function %d, status %s", ack.RequestID, ack.RequestSequenceNumber, ranName, ack.FuncID, enumName(controlStatusNames, ack.ControlStatus))
+	log.Printf("RIC_CONTROL_ACK %d/%d from {%s}, RAN function %d, status %s", ack.RequestID, ack.RequestSequenceNumber, ranName, ack.FuncID, enumName(controlStatusNames, ack.ControlStatus))
+	c.ricControl.Acknowledged(ranName, ack)
+
+	return nil
 }
 
-func (c *Control) sendRicSubRequest(subID int, requestSN int, funcID int) (err error) {
+func (c *Control) handleControlFailure(params *xapp.RMRParams) (err error) {
+	ranName := params.Meid.RanName
 	var e2ap *E2ap
-	var e2sm *E2sm
-
-	var eventTriggerCount int = 1
-	var periods []int64 = []int64{13}
-	var eventTriggerDefinition []byte = make([]byte, 8)
-	_, err = e2sm.SetEventTriggerDefinition(eventTriggerDefinition, eventTriggerCount, periods)
+	failure, err := e2ap.GetControlFailureMessage(params.Payload)
 	if err != nil {
-		xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
-		log.Printf("Failed to send RIC_SUB_REQ: %v", err)
-		return err
+		xapp.Logger.Error("Failed to decode RIC Control Failure message: %v", err)
+		log.Printf("Failed to decode RIC Control Failure message: %v", err)
+		c.unhandled(params, err.Error())
+		return
 	}
 
-	log.Printf("Set EventTriggerDefinition: %x", eventTriggerDefinition)
-
-	var actionCount int = 1
-	var ricStyleType []int64 = []int64{0}
-	var actionIds []int64 = []int64{0}
-	var actionTypes []int64 = []int64{0}
-	var actionDefinitions []ActionDefinition = make([]ActionDefinition, actionCount)
-	var subsequentActions []SubsequentAction = []SubsequentAction{SubsequentAction{0, 0, 0}}
+	xapp.Logger.Warn("RIC_CONTROL_FAILURE %d/%d from {%s}, RAN function %d, cause %s", failure.RequestID, failure.RequestSequenceNumber, ranName, failure.FuncID, CauseName(failure.Cause))
+	log.Printf("RIC_CONTROL_FAILURE %d/%d from {%s}, RAN function %d, cause %s", failure.RequestID, failure.RequestSequenceNumber, ranName, failure.FuncID, CauseName(failure.Cause))
+	c.ricControl.Failed(ranName, failure)
 
-	for index := 0; index < actionCount; index++ {
-		if ricStyleType[index] == 0 {
-			actionDefinitions[index].Buf = nil
-			actionDefinitions[index].Size = 0
-		} else {
-			actionDefinitions[index].Buf = make([]byte, 8)
-			_, err = e2sm.SetActionDefinition(actionDefinitions[index].Buf, ricStyleType[index])
-			if err != nil {
-				xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
-				log.Printf("Failed to send RIC_SUB_REQ: %v", err)
-				return err
-			}
-			actionDefinitions[index].Size = len(actionDefinitions[index].Buf)
+	return nil
+}
 
-			log.Printf("Set ActionDefinition[%d]: %x", index, actionDefinitions[index].Buf)
-		}
+func (c *Control) handleErrorIndication(params *xapp.RMRParams) (err error) {
+	ranName := params.Meid.RanName
+	var e2ap *E2ap
+	errorIndication, err := e2ap.GetErrorIndicationMessage(params.Payload)
+	if err != nil {
+		xapp.Logger.Error("Failed to decode Error Indication message: %v", err)
+		log.Printf("Failed to decode Error Indication message: %v", err)
+		c.unhandled(params, err.Error())
+		return
 	}
 
-	for index := 0; index < len(c.ranList); index++ {
-		params := &xapp.RMRParams{}
-		params.Mtype = 12010
-		params.SubId = subID
+	cause, retry, unknown := "none", true, false
+	if errorIndication.Cause != nil {
+		cause = CauseName(*errorIndication.Cause)
+		retry = retryableCause(*errorIndication.Cause)
+		unknown = errorIndication.Cause.CauseType == CAUSE_RIC_REQUEST && errorIndication.Cause.CauseID == CAUSE_RIC_REQUEST_ID_UNKNOWN
+	}
+	requestID, requestSN := errorIndication.RequestID, errorIndication.RequestSequenceNumber
+	if diagnostics := errorIndication.CriticalityDiagnostics; requestID < 0 && diagnostics != nil && diagnostics.RequestID >= 0 {
+		requestID, requestSN = diagnostics.RequestID, diagnostics.RequestSequenceNumber
+	}
+	reason := "ErrorIndication " + cause + "; criticality diagnostics: " + CriticalityDiagnosticsString(errorIndication.CriticalityDiagnostics)
+	xapp.Logger.Warn("{%s} %s, request %d/%d, RAN function %d", ranName, reason, requestID, requestSN, errorIndication.FuncID)
+	log.Printf("{%s} %s, request %d/%d, RAN function %d", ranName, reason, requestID, requestSN, errorIndication.FuncID)
+
+	switch {
+	case requestID < 0:
+		xapp.Logger.Warn("Error Indication from {%s} names no request", ranName)
+		log.Printf("Error Indication from {%s} names no request", ranName)
+	case c.subscriptions.ErrorIndicated(ranName, int(requestID), int(requestSN), unknown, reason, retry):
+		xapp.Logger.Warn("Error Indication from {%s} is about its subscription %d/%d", ranName, requestID, requestSN)
+		log.Printf("Error Indication from {%s} is about its subscription %d/%d", ranName, requestID, requestSN)
+	case c.ricControl.ErrorIndicated(ranName, requestID, requestSN, cause):
+		xapp.Logger.Warn("Error Indication from {%s} is about RIC_CONTROL_REQ %d/%d", ranName, requestID, requestSN)
+		log.Printf("Error Indication from {%s} is about RIC_CONTROL_REQ %d/%d", ranName, requestID, requestSN)
+	default:
+		xapp.Logger.Warn("Error Indication from {%s} is about request %d/%d, which is not ours", ranName, requestID, requestSN)
+		log.Printf("Error Indication from {%s} is about request %d/%d, which is not ours", ranName, requestID, requestSN)
+	}
 
-		xapp.Logger.Debug("Send RIC_SUB_REQ to {%s}", c.ranList[index])
-		log.Printf("Send RIC_SUB_REQ to {%s}", c.ranList[index])
+	return nil
+}
 
-		params.Payload = make([]byte, 1024)
-		params.Payload, err = e2ap.SetSubscriptionRequestPayload(params.Payload, 1001, uint16(requestSN), uint16(funcID), eventTriggerDefinition, len(eventTriggerDefinition), actionCount, actionIds, actionTypes, actionDefinitions, subsequentActions)
-		if err != nil {
-			xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
-			log.Printf("Failed to send RIC_SUB_REQ: %v", err)
-			return err
-		}
+// unhandled counts a message that could not be handled and dumps it to the dead letters
+func (c *Control) unhandled(params *xapp.RMRParams, reason string) {
+	c.messages.Unhandled(params.Mtype)
+	if path, err := c.deadLetters.Dump(params, reason); err != nil {
+		xapp.Logger.Error("Failed to dump unhandled message type %d: %v", params.Mtype, err)
+		log.Printf("Failed to dump unhandled message type %d: %v", params.Mtype, err)
+	} else if path != "" {
+		log.Printf("Unhandled message type %d dumped to %s", params.Mtype, path)
+	}
+}
 
-		log.Printf("Set Payload: %x", params.Payload)
+// sendRicSubRequest sends the subscription request of the subscription profile of an E2 node
+func (c *Control) sendRicSubRequest(ranName string) (err error) {
+	req, err := c.kpm.subscriptionRequest(ranName)
+	if err != nil {
+		xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
+		log.Printf("Failed to send RIC_SUB_REQ: %v", err)
+		return err
+	}
 
-		params.Meid = &xapp.RMRMeid{RanName: c.ranList[index]}
-		xapp.Logger.Debug("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
-		log.Printf("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+	xapp.Logger.Debug("Send RIC_SUB_REQ to {%s} with profile %s", ranName, c.kpm.Profile(ranName).Name)
+	log.Printf("Send RIC_SUB_REQ to {%s} with profile %s", ranName, c.kpm.Profile(ranName).Name)
 
-		err = c.rmrSend(params)
-		if err != nil {
-			xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
-			log.Printf("Failed to send RIC_SUB_REQ: %v", err)
-			return err
+	log.Printf("Set EventTriggerDefinition: %x", req.eventTrigger)
+	for index, actionDefinition := range req.actionDefinitions {
+		if actionDefinition.Size != 0 {
+			log.Printf("Set ActionDefinition[%d]: %x", index, actionDefinition.Buf)
 		}
-
-		c.setEventCreateExpiredTimer(params.Meid.RanName)
-		c.ranList = append(c.ranList[:index], c.ranList[index+1:]...)
-		index--
 	}
 
-	return nil
-}
-
-func (c *Control) sendRicSubDelRequest(subID int, requestSN int, funcID int) (err error) {
-	params := &xapp.RMRParams{}
-	params.Mtype = 12020
-	params.SubId = subID
-	var e2ap *E2ap
-
-	params.Payload = make([]byte, 1024)
-	params.Payload, err = e2ap.SetSubscriptionDeleteRequestPayload(params.Payload, 100, uint16(requestSN), uint16(funcID))
+	c.sequences.Reset(ranName)
+	err = c.subscriber.Subscribe(ranName, req)
 	if err != nil {
-		xapp.Logger.Error("Failed to send RIC_SUB_DEL_REQ: %v", err)
+		xapp.Logger.Error("Failed to send RIC_SUB_REQ: %v", err)
+		log.Printf("Failed to send RIC_SUB_REQ: %v", err)
 		return err
 	}
 
-	log.Printf("Set Payload: %x", params.Payload)
+	return nil
+}
 
-	if funcID == 0 {
-		params.Meid = &xapp.RMRMeid{PlmnID: "::", EnbID: "::", RanName: "0"}
-	} else {
-		params.Meid = &xapp.RMRMeid{PlmnID: "::", EnbID: "::", RanName: "3"}
+// DeleteSubscriptions deletes the subscriptions of the E2 nodes, every one when ranNames is empty, and
+// waits for the E2 nodes to respond. It returns the subscription state of the E2 nodes afterwards.
+func (c *Control) DeleteSubscriptions(ranNames []string) []NodeSubscription {
+	if len(ranNames) == 0 {
+		ranNames = c.subscriptions.RanNames()
 	}
+	for _, ranName := range ranNames {
+		record, ok := c.subscriptions.Deleting(ranName)
+		if !ok || record == nil {
+			continue
+		}
+		if err := c.subscriber.Unsubscribe(ranName, record); err != nil {
+			xapp.Logger.Error("Failed to send RIC_SUB_DEL_REQ: %v", err)
+			log.Printf("Failed to send RIC_SUB_DEL_REQ: %v", err)
+			c.subscriptions.DeleteFailed(ranName, err.Error())
+		}
+	}
+	c.subscriptions.WaitDeleted(ranNames)
 
-	xapp.Logger.Debug("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
-	log.Printf("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+	var nodes []NodeSubscription
+	for _, node := range c.subscriptions.Nodes() {
+		for _, ranName := range ranNames {
+			if node.RanName == ranName {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
 
-	err = c.rmrSend(params)
-	if err != nil {
-		xapp.Logger.Error("Failed to send RIC_SUB_DEL_REQ: %v", err)
-		log.Printf("Failed to send RIC_SUB_DEL_REQ: %v", err)
-		return err
+// nodeConnected subscribes an E2 node the discovery found, with the capabilities of the KPM RAN
+// function of its E2 setup
+func (c *Control) nodeConnected(node *E2Node) {
+	if function, ok := kpmRanFunction(node.RanFunctions); ok {
+		c.kpm.SetRanFunction(node.RanName, function)
+	} else if len(node.RanFunctions) > 0 {
+		xapp.Logger.Warn("E2 node {%s} announced no KPM RAN function among %d RAN functions", node.RanName, len(node.RanFunctions))
+		log.Printf("E2 node {%s} announced no KPM RAN function among %d RAN functions", node.RanName, len(node.RanFunctions))
 	}
+	c.subscriptions.Add(node.RanName)
+}
 
-	c.setEventDeleteExpiredTimer(params.Meid.RanName)
+// nodeDisconnected forgets an E2 node that disconnected until it connects again. Its subscription is
+// deleted in the background without waiting for the response, which the E2 node may never send.
+func (c *Control) nodeDisconnected(ranName string) {
+	record, ok := c.subscriptions.Deleting(ranName)
+	c.subscriptions.Remove(ranName)
+	if !ok || record == nil {
+		return
+	}
+	go func() {
+		if err := c.subscriber.Unsubscribe(ranName, record); err != nil {
+			xapp.Logger.Error("Failed to send RIC_SUB_DEL_REQ: %v", err)
+			log.Printf("Failed to send RIC_SUB_DEL_REQ: %v", err)
+		}
+	}()
+}
 
-	return nil
-}
\ No newline at end of file
+// Shutdown stops subscribing, deletes the subscriptions of every E2 node so that they stop reporting
+// to a gone xApp and closes the transport
+func (c *Control) Shutdown() {
+	if c.discovery != nil {
+		c.discovery.Stop()
+	}
+	c.subscriptions.Stop()
+	for _, node := range c.DeleteSubscriptions(nil) {
+		if node.State != SUBSCRIPTION_DELETED {
+			xapp.Logger.Error("Subscription of {%s} left %s: %s", node.RanName, node.State, node.LastError)
+			log.Printf("Subscription of {%s} left %s: %s", node.RanName, node.State, node.LastError)
+		}
+	}
+	if err := c.subscriber.Close(); err != nil {
+		xapp.Logger.Error("Failed to close subscription backend: %v", err)
+		log.Printf("Failed to close subscription backend: %v", err)
+	}
+	if err := c.transport.Close(); err != nil {
+		xapp.Logger.Error("Failed to close transport: %v", err)
+		log.Printf("Failed to close transport: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package control
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const BENCH_RAN_NAME = "gnb_bench"
+
+// BenchmarkHandleIndication measures the time and memory it takes handleIndication to decode a RIC Indication
+// of a KPM v1 gNB reporting more and more cells and slices, and to write its entries to a memory store
+func BenchmarkHandleIndication(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, size := range []struct{ cells, slices int }{{1, 1}, {4, 2}, {16, 8}} {
+		payload, err := sampleIndication(size.cells, size.slices)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%dcells-%dslices", size.cells, size.slices), func(b *testing.B) {
+			c := NewControlWith([]string{BENCH_RAN_NAME}, NewMemoryMetricsStore(), NewChanTransport(1), nil, nil, nil, nil, nil, SubscriptionConfig{})
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				params := &xapp.RMRParams{Mtype: RIC_INDICATION, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: BENCH_RAN_NAME}}
+				if err := c.handleIndication(params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// sampleIndication encodes the RIC Indication payload of an E2SM-KPM v1 report from a gNB whose O-DU
+// and O-CU-UP containers cover cells cells, each serving one PLMN of slices slices with one 5QI each.
+// It is what BenchmarkHandleIndication hands to handleIndication to measure the cost of an indication.
+func sampleIndication(cells int, slices int) ([]byte, error) {
+	var e2ap *E2ap
+	var e2sm *E2sm
+
+	plmnID := OctetString{Buf: []byte{0x13, 0xf1, 0x84}, Size: 3}
+	gNBID := GNBID{Buf: []byte{0x00, 0x00, 0x01, 0x00}, Size: 4, BitsUnused: 10}
+	indHdr := &IndicationHeader{IndHdrType: 1, IndHdr: &IndicationHeaderFormat1{
+		GlobalKPMnodeIDType: 1,
+		GlobalKPMnodeID: &GlobalKPMnodegNBIDType{
+			GlobalgNBID: GlobalgNBIDType{PlmnID: plmnID, GnbIDType: 1, GnbID: &gNBID},
+		},
+		PlmnID: &plmnID,
+		FiveQI: -1,
+		Qci:    -1,
+	}}
+
+	oDU := &ODUPFContainerType{CellResourceReports: make([]CellResourceReportType, cells)}
+	cuUPPlmn := CUUPPlmnType{PlmnID: plmnID, CUUPPM5GC: &CUUPPM5GCType{SliceToReports: make([]SliceToReportType, slices)}}
+	for i := range oDU.CellResourceReports {
+		duPM5GC := &DUPM5GCContainerType{SlicePerPlmnPerCells: make([]SlicePerPlmnPerCellType, slices)}
+		for j := range duPM5GC.SlicePerPlmnPerCells {
+			sliceID := SliceIDType{SST: OctetString{Buf: []byte{byte(j)}, Size: 1}}
+			duPM5GC.SlicePerPlmnPerCells[j] = SlicePerPlmnPerCellType{
+				SliceID:                     sliceID,
+				FQIPERSlicesPerPlmnPerCells: []FQIPERSlicesPerPlmnPerCellType{{FiveQI: 9, PrbUsage: IntPair64{DL: 40, UL: 20}}},
+			}
+			cuUPPlmn.CUUPPM5GC.SliceToReports[j] = SliceToReportType{
+				SliceID: sliceID,
+				FQIPERSlicesPerPlmns: []FQIPERSlicesPerPlmnType{{
+					FiveQI:      9,
+					PDCPBytesDL: &Integer{Buf: []byte{0x01, 0x00, 0x00}, Size: 3},
+					PDCPBytesUL: &Integer{Buf: []byte{0x10, 0x00}, Size: 2},
+				}},
+			}
+		}
+		oDU.CellResourceReports[i] = CellResourceReportType{
+			NRCGI: NRCGIType{
+				PlmnID:   plmnID,
+				NRCellID: BitString{Buf: []byte{0x00, 0x00, 0x10, 0x00, byte(i << 4)}, Size: 5, BitsUnused: 4},
+			},
+			TotalofAvailablePRBs: IntPair64{DL: 273, UL: 273},
+			ServedPlmnPerCells:   []ServedPlmnPerCellType{{PlmnID: plmnID, DUPM5GC: duPM5GC}},
+		}
+	}
+	oCUUP := &OCUUPPFContainerType{CUUPPFContainerItems: []CUUPPFContainerItemType{
+		{InterfaceType: 2, OCUUPPMContainer: CUUPMeasurementContainerType{CUUPPlmns: []CUUPPlmnType{cuUPPlmn}}},
+	}}
+	indMsg := &IndicationMessage{StyleType: 1, IndMsgType: 1, IndMsg: &IndicationMessageFormat1{PMContainers: []PMContainerType{
+		{PFContainer: &PFContainerType{ContainerType: 1, Container: oDU}},
+		{PFContainer: &PFContainerType{ContainerType: 3, Container: oCUUP}},
+	}}}
+
+	header, err := e2sm.SetIndicationHeader(make([]byte, 1<<10), indHdr)
+	if err != nil {
+		return nil, err
+	}
+	message, err := e2sm.SetIndicationMessage(make([]byte, 1<<24), indMsg)
+	if err != nil {
+		return nil, err
+	}
+	return e2ap.SetIndicationPayload(make([]byte, len(header)+len(message)+1<<10), &DecodedIndicationMessage{
+		RequestID:  1001,
+		IndSN:      1,
+		IndHeader:  header,
+		IndMessage: message,
+	})
+}
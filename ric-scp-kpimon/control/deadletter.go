@@ -0,0 +1,192 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// messageTypeNames names the RMR message types kpimon sends or receives
+var messageTypeNames = map[int]string{
+	RIC_ALARM:            "RIC_ALARM",
+	RIC_ERROR_INDICATION: "RIC_ERROR_INDICATION",
+	RIC_SUB_REQ:          "RIC_SUB_REQ",
+	RIC_SUB_RESP:         "RIC_SUB_RESP",
+	RIC_SUB_FAILURE:      "RIC_SUB_FAILURE",
+	RIC_SUB_DEL_REQ:      "RIC_SUB_DEL_REQ",
+	RIC_SUB_DEL_RESP:     "RIC_SUB_DEL_RESP",
+	RIC_SUB_DEL_FAILURE:  "RIC_SUB_DEL_FAILURE",
+	RIC_CONTROL_REQ:      "RIC_CONTROL_REQ",
+	RIC_CONTROL_ACK:      "RIC_CONTROL_ACK",
+	RIC_CONTROL_FAILURE:  "RIC_CONTROL_FAILURE",
+	RIC_INDICATION:       "RIC_INDICATION",
+}
+
+// MessageTypeName returns the name of an RMR message type, its number when kpimon does not know it
+func MessageTypeName(mtype int) string {
+	if name, ok := messageTypeNames[mtype]; ok {
+		return name
+	}
+	return strconv.Itoa(mtype)
+}
+
+// MessageCount counts the messages of a type kpimon received, and the ones among them it could not handle:
+// of an unknown type or undecodable
+type MessageCount struct {
+	Received  int64 `json:"received"`
+	Unhandled int64 `json:"unhandled"`
+}
+
+// MessageCounters counts the received messages per RMR message type
+type MessageCounters struct {
+	counts map[int]*MessageCount
+	mu     *sync.Mutex
+}
+
+func NewMessageCounters() *MessageCounters {
+	return &MessageCounters{counts: make(map[int]*MessageCount), mu: &sync.Mutex{}}
+}
+
+func (m *MessageCounters) count(mtype int) *MessageCount {
+	count, ok := m.counts[mtype]
+	if !ok {
+		count = &MessageCount{}
+		m.counts[mtype] = count
+	}
+	return count
+}
+
+// Received counts a message of a type
+func (m *MessageCounters) Received(mtype int) {
+	m.mu.Lock()
+	m.count(mtype).Received++
+	m.mu.Unlock()
+}
+
+// Unhandled counts a received message of a type that could not be handled
+func (m *MessageCounters) Unhandled(mtype int) {
+	m.mu.Lock()
+	m.count(mtype).Unhandled++
+	m.mu.Unlock()
+}
+
+// Counts returns the counts since start by message type name
+func (m *MessageCounters) Counts() map[string]MessageCount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]MessageCount, len(m.counts))
+	for mtype, count := range m.counts {
+		counts[MessageTypeName(mtype)] = *count
+	}
+	return counts
+}
+
+type DeadLetterConfig struct {
+	Dir string //directory the unhandled payloads are dumped to, empty to dump none
+	Max int    //payloads dumped before the directory is left alone, DEFAULT_DEAD_LETTER_MAX when 0
+}
+
+// LoadDeadLetterConfig reads the dead-letter configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadDeadLetterConfig() DeadLetterConfig {
+	cfg := DeadLetterConfig{Dir: os.Getenv("deadLetterDir")}
+	if n, err := strconv.Atoi(os.Getenv("deadLetterMax")); err == nil && n > 0 {
+		cfg.Max = n
+	}
+	return cfg
+}
+
+// DeadLetter describes an unhandled payload, written next to it
+type DeadLetter struct {
+	Time       time.Time `json:"time"`
+	Mtype      int       `json:"mtype"`
+	Name       string    `json:"name"`
+	SubID      int       `json:"sub_id"`
+	RanName    string    `json:"ran_name,omitempty"`
+	Reason     string    `json:"reason"`
+	PayloadLen int       `json:"payload_len"`
+	File       string    `json:"file"` //name of the payload file, in the same directory
+}
+
+// DeadLetters dumps the payloads kpimon could not handle to disk for later analysis: each as a .bin file
+// holding the payload and a .json file describing it, named by time, message type and RAN name
+type DeadLetters struct {
+	cfg     DeadLetterConfig
+	written int
+	mu      *sync.Mutex
+}
+
+func NewDeadLetters(cfg DeadLetterConfig) *DeadLetters {
+	if cfg.Max <= 0 {
+		cfg.Max = DEFAULT_DEAD_LETTER_MAX
+	}
+	return &DeadLetters{cfg: cfg, mu: &sync.Mutex{}}
+}
+
+// Dump writes an unhandled message to the dead-letter directory and returns the path of its payload,
+// empty when no directory is configured or the directory holds its maximum already
+func (d *DeadLetters) Dump(params *xapp.RMRParams, reason string) (string, error) {
+	if d.cfg.Dir == "" {
+		return "", nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.written >= d.cfg.Max {
+		return "", nil
+	}
+	if d.written == 0 {
+		if err := os.MkdirAll(d.cfg.Dir, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	letter := DeadLetter{Time: time.Now().UTC(), Mtype: params.Mtype, Name: MessageTypeName(params.Mtype), SubID: params.SubId,
+		Reason: reason, PayloadLen: len(params.Payload)}
+	if params.Meid != nil {
+		letter.RanName = params.Meid.RanName
+	}
+	base := fmt.Sprintf("%s-%06d-%s", letter.Time.Format("20060102T150405.000000000Z"), d.written, letter.Name)
+	if letter.RanName != "" {
+		base += "-" + strings.Map(fileNameRune, letter.RanName)
+	}
+	letter.File = base + ".bin"
+	meta, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(d.cfg.Dir, letter.File)
+	if err = ioutil.WriteFile(path, params.Payload, 0644); err != nil {
+		return "", err
+	}
+	if err = ioutil.WriteFile(filepath.Join(d.cfg.Dir, base+".json"), meta, 0644); err != nil {
+		return "", err
+	}
+	d.written++
+	if d.written == d.cfg.Max {
+		xapp.Logger.Warn("%d unhandled payloads dumped to %s, no more are", d.written, d.cfg.Dir)
+		log.Printf("%d unhandled payloads dumped to %s, no more are", d.written, d.cfg.Dir)
+	}
+	return path, nil
+}
+
+// Written returns the number of payloads dumped since start
+func (d *DeadLetters) Written() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.written
+}
+
+func fileNameRune(r rune) rune {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+		return r
+	}
+	return '_'
+}
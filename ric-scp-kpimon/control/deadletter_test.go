@@ -0,0 +1,104 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+func TestMessageCounters(t *testing.T) {
+	m := NewMessageCounters()
+	for _, mtype := range []int{RIC_INDICATION, RIC_INDICATION, RIC_INDICATION, RIC_CONTROL_ACK, 99} {
+		m.Received(mtype)
+	}
+	m.Unhandled(RIC_INDICATION)
+	m.Unhandled(99)
+
+	want := map[string]MessageCount{
+		"RIC_INDICATION":  {Received: 3, Unhandled: 1},
+		"RIC_CONTROL_ACK": {Received: 1},
+		"99":              {Received: 1, Unhandled: 1},
+	}
+	if counts := m.Counts(); !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts %v, want %v", counts, want)
+	}
+}
+
+// deadLetterName is the name of a payload file: time, number of the payload, message type name and
+// RAN name when known
+var deadLetterName = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z-(\d{6})-([^-]+)(-(.+))?\.bin$`)
+
+func TestDeadLettersDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := NewDeadLetters(DeadLetterConfig{Dir: filepath.Join(dir, "dumps"), Max: 3})
+
+	for i, letter := range []struct {
+		params           *xapp.RMRParams
+		number, name     string
+		fileRan, ranName string
+	}{
+		{&xapp.RMRParams{Mtype: RIC_INDICATION, SubId: 7, Payload: []byte{0x01, 0x02, 0x03}, Meid: &xapp.RMRMeid{RanName: "gnb/1"}}, "000000", "RIC_INDICATION", "gnb_1", "gnb/1"},
+		{&xapp.RMRParams{Mtype: 99, Payload: []byte{0xff}}, "000001", "99", "", ""},
+		{&xapp.RMRParams{Mtype: RIC_CONTROL_ACK, Payload: []byte{}, Meid: &xapp.RMRMeid{RanName: "../gnb 2:é"}}, "000002", "RIC_CONTROL_ACK", ".._gnb_2__", "../gnb 2:é"},
+	} {
+		path, err := d.Dump(letter.params, "reason "+letter.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(path) != filepath.Join(dir, "dumps") {
+			t.Fatalf("payload %d dumped to %s, outside the dead-letter directory", i, path)
+		}
+		match := deadLetterName.FindStringSubmatch(filepath.Base(path))
+		if match == nil || match[1] != letter.number || match[2] != letter.name || match[4] != letter.fileRan {
+			t.Errorf("payload %d dumped as %s, want number %s, name %s and RAN %q", i, filepath.Base(path), letter.number, letter.name, letter.fileRan)
+			continue
+		}
+		if payload, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(payload, letter.params.Payload) {
+			t.Errorf("payload %d holds %x (%v), want %x", i, payload, err, letter.params.Payload)
+		}
+
+		meta, err := ioutil.ReadFile(path[:len(path)-len(".bin")] + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dumped DeadLetter
+		if err := json.Unmarshal(meta, &dumped); err != nil {
+			t.Fatal(err)
+		}
+		if dumped.File != filepath.Base(path) || dumped.Mtype != letter.params.Mtype || dumped.Name != letter.name || dumped.SubID != letter.params.SubId ||
+			dumped.RanName != letter.ranName || dumped.Reason != "reason "+letter.number || dumped.PayloadLen != len(letter.params.Payload) {
+			t.Errorf("payload %d described as %+v", i, dumped)
+		}
+	}
+
+	if path, err := d.Dump(&xapp.RMRParams{Mtype: RIC_INDICATION, Payload: []byte{0x04}}, "over the maximum"); path != "" || err != nil {
+		t.Errorf("payload over the maximum dumped to %q (%v)", path, err)
+	}
+	if written := d.Written(); written != 3 {
+		t.Errorf("%d payloads written, want 3", written)
+	}
+	if files, err := ioutil.ReadDir(filepath.Join(dir, "dumps")); err != nil || len(files) != 6 {
+		t.Errorf("%d files in the dead-letter directory (%v), want 3 payloads and their descriptions", len(files), err)
+	}
+}
+
+func TestDeadLettersWithoutDir(t *testing.T) {
+	d := NewDeadLetters(DeadLetterConfig{})
+	if path, err := d.Dump(&xapp.RMRParams{Mtype: RIC_INDICATION, Payload: []byte{0x01}}, "no directory"); path != "" || err != nil {
+		t.Errorf("payload dumped to %q (%v) without directory", path, err)
+	}
+	if written := d.Written(); written != 0 {
+		t.Errorf("%d payloads written without directory", written)
+	}
+}
@@ -19,235 +19,322 @@
 
 package control
 
-/*
-#include <stdlib.h>
-#include <e2ap/wrapper.h>
-#cgo LDFLAGS: -le2apwrapper
-#cgo CFLAGS: -I/usr/local/include/e2ap
-*/
-import "C"
-
 import (
 	"errors"
-	"unsafe"
 )
 
+// E2ap encodes and decodes the E2AP messages with the codec of e2ap_codec.go. The Set methods write
+// into the payload buffer they are given and return the part of it used.
 type E2ap struct {
 }
 
+func e2apError(action string, err error) error {
+	return errors.New("e2ap is unable to " + action + " due to wrong or invalid payload: " + err.Error())
+}
+
+func setPayload(payload []byte, encoded []byte, err error, name string) ([]byte, error) {
+	if err != nil {
+		return make([]byte, 0), e2apError("set "+name, err)
+	}
+	return fillBuffer(payload, encoded, "e2ap", name)
+}
+
 /* RICsubscriptionRequest */
 
 func (c *E2ap) GetSubscriptionRequestSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_request_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Request Sequence Number due to wrong or invalid payload")
+	subId, err = getRequestSequenceNumber(payload, E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION)
+	if err != nil {
+		return 0, e2apError("get Subscription Request Sequence Number", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
 func (c *E2ap) SetSubscriptionRequestSequenceNumber(payload []byte, newSubscriptionid uint16) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	size := C.e2ap_set_ric_subscription_request_sequence_number(cptr, C.size_t(len(payload)), C.long(newSubscriptionid))
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Request Sequence Number due to wrong or invalid payload")
-	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
-	return
+	encoded, err := setRequestSequenceNumber(payload, E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION, newSubscriptionid)
+	return setPayload(payload, encoded, err, "Subscription Request Sequence Number")
 }
 
+// SetSubscriptionRequestPayload encodes a RICsubscriptionRequest of the first actionCount actions. An action
+// has a definition when its ActionDefinition has a non-zero Size and a subsequent action when IsValid is set.
 func (c *E2ap) SetSubscriptionRequestPayload(payload []byte, ricRequestorID uint16, ricRequestSequenceNumber uint16, ranFunctionID uint16, eventTriggerDefinition []byte, eventTriggerDefinitionSize int, actionCount int, actionIds []int64, actionTypes []int64, actionDefinitions []ActionDefinition, subsequentActions []SubsequentAction) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	eventTrigger := unsafe.Pointer(&eventTriggerDefinition[0])
-	actIds := unsafe.Pointer(&actionIds[0])
-	actTypes := unsafe.Pointer(&actionTypes[0])
-
-	count := len(actionDefinitions)
-	actDefs := (*C.RICactionDefinition)(C.calloc(C.size_t(len(actionDefinitions)), C.sizeof_RICactionDefinition))
-	for index := 0; index < count; index++ {
-		ptr := *(*C.RICactionDefinition)(unsafe.Pointer((uintptr)(unsafe.Pointer(actDefs)) + (uintptr)(C.sizeof_RICactionDefinition*C.int(index))))
-		ptr.size = C.int(actionDefinitions[index].Size)
-		if ptr.size != 0 {
-			ptr.actionDefinition = (*C.uint8_t)(C.CBytes(actionDefinitions[index].Buf))
-		}
+	if eventTriggerDefinitionSize < 0 || eventTriggerDefinitionSize > len(eventTriggerDefinition) || actionCount > len(actionIds) || actionCount > len(actionTypes) {
+		return make([]byte, 0), errors.New("e2ap is unable to set Subscription Request Payload due to wrong or invalid input")
 	}
-	defer C.free(unsafe.Pointer(actDefs))
-
-	count = len(subsequentActions)
-	subActs := (*C.RICSubsequentAction)(C.calloc(C.size_t(len(subsequentActions)), C.sizeof_RICSubsequentAction))
-	for index := 0; index < count; index++ {
-		ptr := *(*C.RICSubsequentAction)(unsafe.Pointer((uintptr)(unsafe.Pointer(subActs)) + (uintptr)(C.sizeof_RICSubsequentAction*C.int(index))))
-		ptr.isValid = C.int(subsequentActions[index].IsValid)
-		ptr.subsequentActionType = C.long(subsequentActions[index].SubsequentActionType)
-		ptr.timeToWait = C.long(subsequentActions[index].TimeToWait)
+	actions := make([]actionToBeSetup, actionCount)
+	for index := range actions {
+		actions[index] = actionToBeSetup{id: actionIds[index], actionType: actionTypes[index], subsequentActionType: -1}
+		if index < len(actionDefinitions) && actionDefinitions[index].Size != 0 {
+			if actionDefinitions[index].Size > len(actionDefinitions[index].Buf) {
+				return make([]byte, 0), errors.New("e2ap is unable to set Subscription Request Payload due to wrong or invalid input")
+			}
+			actions[index].definition = actionDefinitions[index].Buf[:actionDefinitions[index].Size]
+		}
+		if index < len(subsequentActions) && subsequentActions[index].IsValid != 0 {
+			actions[index].subsequentActionType = subsequentActions[index].SubsequentActionType
+			actions[index].timeToWait = subsequentActions[index].TimeToWait
+		}
 	}
-	defer C.free(unsafe.Pointer(subActs))
+	encoded, err := encodeSubscriptionRequest(int64(ricRequestorID), int64(ricRequestSequenceNumber), int64(ranFunctionID), eventTriggerDefinition[:eventTriggerDefinitionSize], actions)
+	return setPayload(payload, encoded, err, "Subscription Request Payload")
+}
 
-	size := C.e2ap_encode_ric_subscription_request_message(cptr, C.size_t(len(payload)), C.long(ricRequestorID), C.long(ricRequestSequenceNumber), C.long(ranFunctionID), eventTrigger, C.size_t(eventTriggerDefinitionSize), C.int(actionCount), (*C.long)(actIds), (*C.long)(actTypes), actDefs, subActs)
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Request Payload due to wrong or invalid payload")
+func (c *E2ap) GetSubscriptionRequestMessage(payload []byte) (decodedMsg *DecodedSubscriptionRequestMessage, err error) {
+	decodedMsg, err = decodeSubscriptionRequest(payload)
+	if err != nil {
+		return &DecodedSubscriptionRequestMessage{}, e2apError("decode subscription request message", err)
 	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
 	return
 }
 
 /* RICsubscriptionResponse */
 
 func (c *E2ap) GetSubscriptionResponseSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_response_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Response Sequence Number due to wrong or invalid payload")
+	subId, err = getRequestSequenceNumber(payload, E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION)
+	if err != nil {
+		return 0, e2apError("get Subscription Response Sequence Number", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
 func (c *E2ap) SetSubscriptionResponseSequenceNumber(payload []byte, newSubscriptionid uint16) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	size := C.e2ap_set_ric_subscription_response_sequence_number(cptr, C.size_t(len(payload)), C.long(newSubscriptionid))
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Response Sequence Number due to wrong or invalid payload")
-	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
-	return
+	encoded, err := setRequestSequenceNumber(payload, E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION, newSubscriptionid)
+	return setPayload(payload, encoded, err, "Subscription Response Sequence Number")
 }
 
 func (c *E2ap) GetSubscriptionResponseMessage(payload []byte) (decodedMsg *DecodedSubscriptionResponseMessage, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	decodedMsg = &DecodedSubscriptionResponseMessage{}
-	decodedCMsg := C.e2ap_decode_ric_subscription_response_message(cptr, C.size_t(len(payload)))
-	defer C.free(unsafe.Pointer(decodedCMsg))
-
-	if decodedCMsg == nil {
-		return decodedMsg, errors.New("e2ap wrapper is unable to decode subscription response message due to wrong or invalid payload")
+	decodedMsg, err = decodeSubscriptionResponse(payload)
+	if err != nil {
+		return &DecodedSubscriptionResponseMessage{}, e2apError("decode subscription response message", err)
 	}
+	return
+}
 
-	decodedMsg.RequestID = int32(decodedCMsg.requestorID)
-	decodedMsg.RequestSequenceNumber = int32(decodedCMsg.requestSequenceNumber)
-	decodedMsg.FuncID = int32(decodedCMsg.ranfunctionID)
+func (c *E2ap) SetSubscriptionResponsePayload(payload []byte, msg *DecodedSubscriptionResponseMessage) (newPayload []byte, err error) {
+	encoded, err := encodeSubscriptionResponse(msg)
+	return setPayload(payload, encoded, err, "Subscription Response Payload")
+}
 
-	admittedCount := int(decodedCMsg.ricActionAdmittedList.count)
-	for index := 0; index < admittedCount; index++ {
-		decodedMsg.ActionAdmittedList.ActionID[index] = int32(decodedCMsg.ricActionAdmittedList.ricActionID[index])
-	}
-	decodedMsg.ActionAdmittedList.Count = admittedCount
+/* RICsubscriptionFailure */
 
-	notAdmittedCount := int(decodedCMsg.ricActionNotAdmittedList.count)
-	for index := 0; index < notAdmittedCount; index++ {
-		decodedMsg.ActionNotAdmittedList.ActionID[index] = int32(decodedCMsg.ricActionNotAdmittedList.ricActionID[index])
-		decodedMsg.ActionNotAdmittedList.Cause[index].CauseType = int32(decodedCMsg.ricActionNotAdmittedList.ricCause[index].ricCauseType)
-		decodedMsg.ActionNotAdmittedList.Cause[index].CauseID = int32(decodedCMsg.ricActionNotAdmittedList.ricCause[index].ricCauseID)
+func (c *E2ap) GetSubscriptionFailureSequenceNumber(payload []byte) (subId uint16, err error) {
+	subId, err = getRequestSequenceNumber(payload, E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION)
+	if err != nil {
+		return 0, e2apError("get Subscription Failure Sequence Number", err)
 	}
-	decodedMsg.ActionNotAdmittedList.Count = notAdmittedCount
-
 	return
 }
 
-/* RICsubscriptionFailure */
-
-func (c *E2ap) GetSubscriptionFailureSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_failure_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Failure Sequence Number due to wrong or invalid payload")
+func (c *E2ap) GetSubscriptionFailureMessage(payload []byte) (decodedMsg *DecodedSubscriptionFailureMessage, err error) {
+	decodedMsg, err = decodeSubscriptionFailure(payload)
+	if err != nil {
+		return &DecodedSubscriptionFailureMessage{}, e2apError("decode subscription failure message", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
+func (c *E2ap) SetSubscriptionFailurePayload(payload []byte, msg *DecodedSubscriptionFailureMessage) (newPayload []byte, err error) {
+	encoded, err := encodeSubscriptionFailure(msg)
+	return setPayload(payload, encoded, err, "Subscription Failure Payload")
+}
+
 /* RICsubscriptionDeleteRequest */
 
 func (c *E2ap) GetSubscriptionDeleteRequestSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_delete_request_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Delete Request Sequence Number due to wrong or invalid payload")
+	subId, err = getRequestSequenceNumber(payload, E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION_DEL)
+	if err != nil {
+		return 0, e2apError("get Subscription Delete Request Sequence Number", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
 func (c *E2ap) SetSubscriptionDeleteRequestSequenceNumber(payload []byte, newSubscriptionid uint16) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	size := C.e2ap_set_ric_subscription_delete_request_sequence_number(cptr, C.size_t(len(payload)), C.long(newSubscriptionid))
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Delete Request Sequence Number due to wrong or invalid payload")
-	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
-	return
+	encoded, err := setRequestSequenceNumber(payload, E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION_DEL, newSubscriptionid)
+	return setPayload(payload, encoded, err, "Subscription Delete Request Sequence Number")
 }
 
 func (c *E2ap) SetSubscriptionDeleteRequestPayload(payload []byte, ricRequestorID uint16, ricRequestSequenceNumber uint16, ranFunctionID uint16) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	size := C.e2ap_encode_ric_subscription_delete_request_message(cptr, C.size_t(len(payload)), C.long(ricRequestorID), C.long(ricRequestSequenceNumber), C.long(ranFunctionID))
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Delete Request Payload due to wrong or invalid payload")
+	encoded, err := encodeSubscriptionDelete(E2AP_INITIATING_MESSAGE, int64(ricRequestorID), int64(ricRequestSequenceNumber), int64(ranFunctionID))
+	return setPayload(payload, encoded, err, "Subscription Delete Request Payload")
+}
+
+func (c *E2ap) GetSubscriptionDeleteRequestMessage(payload []byte) (decodedMsg *DecodedSubscriptionDeleteMessage, err error) {
+	decodedMsg, err = decodeSubscriptionDelete(payload, E2AP_INITIATING_MESSAGE)
+	if err != nil {
+		return &DecodedSubscriptionDeleteMessage{}, e2apError("decode subscription delete request message", err)
 	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
 	return
 }
 
 /* RICsubscriptionDeleteResponse */
 
 func (c *E2ap) GetSubscriptionDeleteResponseSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_delete_response_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Delete Response Sequence Number due to wrong or invalid payload")
+	subId, err = getRequestSequenceNumber(payload, E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL)
+	if err != nil {
+		return 0, e2apError("get Subscription Delete Response Sequence Number", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
 func (c *E2ap) SetSubscriptionDeleteResponseSequenceNumber(payload []byte, newSubscriptionid uint16) (newPayload []byte, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	size := C.e2ap_set_ric_subscription_delete_response_sequence_number(cptr, C.size_t(len(payload)), C.long(newSubscriptionid))
-	if size < 0 {
-		return make([]byte, 0), errors.New("e2ap wrapper is unable to set Subscription Delete Response Sequence Number due to wrong or invalid payload")
+	encoded, err := setRequestSequenceNumber(payload, E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL, newSubscriptionid)
+	return setPayload(payload, encoded, err, "Subscription Delete Response Sequence Number")
+}
+
+func (c *E2ap) SetSubscriptionDeleteResponsePayload(payload []byte, ricRequestorID uint16, ricRequestSequenceNumber uint16, ranFunctionID uint16) (newPayload []byte, err error) {
+	encoded, err := encodeSubscriptionDelete(E2AP_SUCCESSFUL_OUTCOME, int64(ricRequestorID), int64(ricRequestSequenceNumber), int64(ranFunctionID))
+	return setPayload(payload, encoded, err, "Subscription Delete Response Payload")
+}
+
+func (c *E2ap) GetSubscriptionDeleteResponseMessage(payload []byte) (decodedMsg *DecodedSubscriptionDeleteMessage, err error) {
+	decodedMsg, err = decodeSubscriptionDelete(payload, E2AP_SUCCESSFUL_OUTCOME)
+	if err != nil {
+		return &DecodedSubscriptionDeleteMessage{}, e2apError("decode subscription delete response message", err)
 	}
-	newPayload = C.GoBytes(cptr, (C.int(size)+7)/8)
 	return
 }
 
 /* RICsubscriptionDeleteFailure */
 
 func (c *E2ap) GetSubscriptionDeleteFailureSequenceNumber(payload []byte) (subId uint16, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	cret := C.e2ap_get_ric_subscription_delete_failure_sequence_number(cptr, C.size_t(len(payload)))
-	if cret < 0 {
-		return 0, errors.New("e2ap wrapper is unable to get Subscirption Failure Sequence Number due to wrong or invalid payload")
+	subId, err = getRequestSequenceNumber(payload, E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL)
+	if err != nil {
+		return 0, e2apError("get Subscription Delete Failure Sequence Number", err)
+	}
+	return
+}
+
+func (c *E2ap) GetSubscriptionDeleteFailureMessage(payload []byte) (decodedMsg *DecodedSubscriptionDeleteFailureMessage, err error) {
+	decodedMsg, err = decodeSubscriptionDeleteFailure(payload)
+	if err != nil {
+		return &DecodedSubscriptionDeleteFailureMessage{}, e2apError("decode subscription delete failure message", err)
 	}
-	subId = uint16(cret)
 	return
 }
 
+func (c *E2ap) SetSubscriptionDeleteFailurePayload(payload []byte, msg *DecodedSubscriptionDeleteFailureMessage) (newPayload []byte, err error) {
+	encoded, err := encodeSubscriptionDeleteFailure(msg)
+	return setPayload(payload, encoded, err, "Subscription Delete Failure Payload")
+}
+
 /* RICindication */
 
 func (c *E2ap) GetIndicationMessage(payload []byte) (decodedMsg *DecodedIndicationMessage, err error) {
-	cptr := unsafe.Pointer(&payload[0])
-	decodedMsg = &DecodedIndicationMessage{}
-	decodedCMsg := C.e2ap_decode_ric_indication_message(cptr, C.size_t(len(payload)))
-	if decodedCMsg == nil {
-		return decodedMsg, errors.New("e2ap wrapper is unable to decode indication message due to wrong or invalid payload")
-	}
-	defer C.e2ap_free_decoded_ric_indication_message(decodedCMsg)
-
-	decodedMsg.RequestID = int32(decodedCMsg.requestorID)
-	decodedMsg.RequestSequenceNumber = int32(decodedCMsg.requestSequenceNumber)
-	decodedMsg.FuncID = int32(decodedCMsg.ranfunctionID)
-	decodedMsg.ActionID = int32(decodedCMsg.actionID)
-	decodedMsg.IndSN = int32(decodedCMsg.indicationSN)
-	decodedMsg.IndType = int32(decodedCMsg.indicationType)
-	indhdr := unsafe.Pointer(decodedCMsg.indicationHeader)
-	decodedMsg.IndHeader = C.GoBytes(indhdr, C.int(decodedCMsg.indicationHeaderSize))
-	decodedMsg.IndHeaderLength = int32(decodedCMsg.indicationHeaderSize)
-	indmsg := unsafe.Pointer(decodedCMsg.indicationMessage)
-	decodedMsg.IndMessage = C.GoBytes(indmsg, C.int(decodedCMsg.indicationMessageSize))
-	decodedMsg.IndMessageLength = int32(decodedCMsg.indicationMessageSize)
-	callproc := unsafe.Pointer(decodedCMsg.callProcessID)
-	decodedMsg.CallProcessID = C.GoBytes(callproc, C.int(decodedCMsg.callProcessIDSize))
-	decodedMsg.CallProcessIDLength = int32(decodedCMsg.callProcessIDSize)
+	decodedMsg, err = decodeIndication(payload)
+	if err != nil {
+		return &DecodedIndicationMessage{}, e2apError("decode indication message", err)
+	}
+	return
+}
+
+// SetIndicationPayload encodes a RICindication, as an E2 node would. The RICindicationSN is left out when IndSN is -1.
+func (c *E2ap) SetIndicationPayload(payload []byte, msg *DecodedIndicationMessage) (newPayload []byte, err error) {
+	encoded, err := encodeIndication(msg)
+	return setPayload(payload, encoded, err, "Indication Payload")
+}
+
+/* RICcontrolRequest */
+
+// SetControlRequestPayload encodes a RICcontrolRequest. The RICcontrolAckRequest is left out when ControlAckRequest is -1.
+func (c *E2ap) SetControlRequestPayload(payload []byte, msg *DecodedControlRequestMessage) (newPayload []byte, err error) {
+	encoded, err := encodeControlRequest(msg)
+	return setPayload(payload, encoded, err, "Control Request Payload")
+}
+
+func (c *E2ap) GetControlRequestMessage(payload []byte) (decodedMsg *DecodedControlRequestMessage, err error) {
+	decodedMsg, err = decodeControlRequest(payload)
+	if err != nil {
+		return &DecodedControlRequestMessage{}, e2apError("decode control request message", err)
+	}
+	return
+}
+
+/* RICcontrolAcknowledge */
+
+func (c *E2ap) GetControlAcknowledgeMessage(payload []byte) (decodedMsg *DecodedControlAcknowledgeMessage, err error) {
+	decodedMsg, err = decodeControlAcknowledge(payload)
+	if err != nil {
+		return &DecodedControlAcknowledgeMessage{}, e2apError("decode control acknowledge message", err)
+	}
+	return
+}
+
+func (c *E2ap) SetControlAcknowledgePayload(payload []byte, msg *DecodedControlAcknowledgeMessage) (newPayload []byte, err error) {
+	encoded, err := encodeControlAcknowledge(msg)
+	return setPayload(payload, encoded, err, "Control Acknowledge Payload")
+}
+
+/* RICcontrolFailure */
+
+func (c *E2ap) GetControlFailureMessage(payload []byte) (decodedMsg *DecodedControlFailureMessage, err error) {
+	decodedMsg, err = decodeControlFailure(payload)
+	if err != nil {
+		return &DecodedControlFailureMessage{}, e2apError("decode control failure message", err)
+	}
+	return
+}
+
+func (c *E2ap) SetControlFailurePayload(payload []byte, msg *DecodedControlFailureMessage) (newPayload []byte, err error) {
+	encoded, err := encodeControlFailure(msg)
+	return setPayload(payload, encoded, err, "Control Failure Payload")
+}
+
+/* ErrorIndication */
+
+func (c *E2ap) GetErrorIndicationMessage(payload []byte) (decodedMsg *DecodedErrorIndicationMessage, err error) {
+	decodedMsg, err = decodeErrorIndication(payload)
+	if err != nil {
+		return &DecodedErrorIndicationMessage{}, e2apError("decode error indication message", err)
+	}
+	return
+}
+
+func (c *E2ap) SetErrorIndicationPayload(payload []byte, msg *DecodedErrorIndicationMessage) (newPayload []byte, err error) {
+	encoded, err := encodeErrorIndication(msg)
+	return setPayload(payload, encoded, err, "Error Indication Payload")
+}
+
+/* RICserviceUpdate */
+
+func (c *E2ap) GetServiceUpdateMessage(payload []byte) (decodedMsg *DecodedServiceUpdateMessage, err error) {
+	decodedMsg, err = decodeServiceUpdate(payload)
+	if err != nil {
+		return &DecodedServiceUpdateMessage{}, e2apError("decode service update message", err)
+	}
+	return
+}
+
+func (c *E2ap) SetServiceUpdatePayload(payload []byte, msg *DecodedServiceUpdateMessage) (newPayload []byte, err error) {
+	encoded, err := encodeServiceUpdate(msg)
+	return setPayload(payload, encoded, err, "Service Update Payload")
+}
+
+/* RICserviceUpdateAcknowledge */
+
+func (c *E2ap) GetServiceUpdateAcknowledgeMessage(payload []byte) (decodedMsg *DecodedServiceUpdateAcknowledgeMessage, err error) {
+	decodedMsg, err = decodeServiceUpdateAcknowledge(payload)
+	if err != nil {
+		return &DecodedServiceUpdateAcknowledgeMessage{}, e2apError("decode service update acknowledge message", err)
+	}
+	return
+}
+
+func (c *E2ap) SetServiceUpdateAcknowledgePayload(payload []byte, msg *DecodedServiceUpdateAcknowledgeMessage) (newPayload []byte, err error) {
+	encoded, err := encodeServiceUpdateAcknowledge(msg)
+	return setPayload(payload, encoded, err, "Service Update Acknowledge Payload")
+}
+
+/* RICserviceUpdateFailure */
+
+func (c *E2ap) GetServiceUpdateFailureMessage(payload []byte) (decodedMsg *DecodedServiceUpdateFailureMessage, err error) {
+	decodedMsg, err = decodeServiceUpdateFailure(payload)
+	if err != nil {
+		return &DecodedServiceUpdateFailureMessage{}, e2apError("decode service update failure message", err)
+	}
 	return
 }
+
+// SetServiceUpdateFailurePayload encodes a RICserviceUpdateFailure. The TimeToWait is left out when it is -1.
+func (c *E2ap) SetServiceUpdateFailurePayload(payload []byte, msg *DecodedServiceUpdateFailureMessage) (newPayload []byte, err error) {
+	encoded, err := encodeServiceUpdateFailure(msg)
+	return setPayload(payload, encoded, err, "Service Update Failure Payload")
+}
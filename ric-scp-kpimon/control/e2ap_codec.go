@@ -0,0 +1,1693 @@
+package control
+
+import (
+	"errors"
+	"strconv"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+// APER codec of the E2AP v01.00 (ORAN-WG3.E2AP-v01.00) messages, following the asn1c skeletons
+// of the e2ap library byte for byte
+
+const (
+	E2AP_INITIATING_MESSAGE   = 0
+	E2AP_SUCCESSFUL_OUTCOME   = 1
+	E2AP_UNSUCCESSFUL_OUTCOME = 2
+)
+
+const (
+	PROCEDURE_E2_SETUP             = 1
+	PROCEDURE_ERROR_INDICATION     = 2
+	PROCEDURE_RESET                = 3
+	PROCEDURE_RIC_CONTROL          = 4
+	PROCEDURE_RIC_INDICATION       = 5
+	PROCEDURE_RIC_SERVICE_QUERY    = 6
+	PROCEDURE_RIC_SERVICE_UPDATE   = 7
+	PROCEDURE_RIC_SUBSCRIPTION     = 8
+	PROCEDURE_RIC_SUBSCRIPTION_DEL = 9
+)
+
+const (
+	CRITICALITY_REJECT = 0
+	CRITICALITY_IGNORE = 1
+	CRITICALITY_NOTIFY = 2
+)
+
+const (
+	IE_CAUSE                    = 1
+	IE_CRITICALITY_DIAGNOSTICS  = 2
+	IE_RAN_FUNCTION_ID          = 5
+	IE_RAN_FUNCTION_ID_ITEM     = 6
+	IE_RAN_FUNCTION_CAUSE_ITEM  = 7
+	IE_RAN_FUNCTION_ITEM        = 8
+	IE_RAN_FUNCTIONS_ACCEPTED   = 9
+	IE_RAN_FUNCTIONS_ADDED      = 10
+	IE_RAN_FUNCTIONS_DELETED    = 11
+	IE_RAN_FUNCTIONS_MODIFIED   = 12
+	IE_RAN_FUNCTIONS_REJECTED   = 13
+	IE_RIC_ACTION_ADMITTED_ITEM = 14
+	IE_RIC_ACTION_ID            = 15
+	IE_RIC_ACTION_NOT_ADM_ITEM  = 16
+	IE_RIC_ACTIONS_ADMITTED     = 17
+	IE_RIC_ACTIONS_NOT_ADMITTED = 18
+	IE_RIC_ACTION_TO_SETUP_ITEM = 19
+	IE_RIC_CALL_PROCESS_ID      = 20
+	IE_RIC_CONTROL_ACK_REQUEST  = 21
+	IE_RIC_CONTROL_HEADER       = 22
+	IE_RIC_CONTROL_MESSAGE      = 23
+	IE_RIC_CONTROL_STATUS       = 24
+	IE_RIC_INDICATION_HEADER    = 25
+	IE_RIC_INDICATION_MESSAGE   = 26
+	IE_RIC_INDICATION_SN        = 27
+	IE_RIC_INDICATION_TYPE      = 28
+	IE_RIC_REQUEST_ID           = 29
+	IE_RIC_SUBSCRIPTION_DETAILS = 30
+	IE_TIME_TO_WAIT             = 31
+	IE_RIC_CONTROL_OUTCOME      = 32
+)
+
+const (
+	MAX_PROTOCOL_IES            = 65535
+	MAX_RIC_ACTIONS             = 16  //maxofRICactionID
+	MAX_RAN_FUNCTIONS           = 256 //maxofRANfunctionID
+	MAX_CRITICALITY_DIAGNOSTICS = 256 //maxnoofErrors
+	MAX_RIC_REQUESTOR_ID        = 65535
+	MAX_RAN_FUNCTION_ID         = 4095
+	MAX_RIC_ACTION_ID           = 255
+	MAX_RIC_INDICATION_SN       = 65535
+)
+
+// root value counts of the ENUMERATED types
+const (
+	RIC_ACTION_TYPE_COUNT       = 3  //report, insert, policy
+	RIC_SUBSEQUENT_ACTION_COUNT = 2  //continue, wait
+	RIC_TIME_TO_WAIT_COUNT      = 18 //zero .. w60s
+	RIC_INDICATION_TYPE_COUNT   = 2  //report, insert
+	RIC_CONTROL_ACK_COUNT       = 3  //noAck, ack, nAck
+	RIC_CONTROL_STATUS_COUNT    = 3  //success, rejected, failed
+	TIME_TO_WAIT_COUNT          = 6  //v1s .. v60s
+	TRIGGERING_MESSAGE_COUNT    = 3  //initiating-message, successful-outcome, unsuccessful-outcome
+	TYPE_OF_ERROR_COUNT         = 2  //not-understood, missing
+)
+
+// CauseType values: the Cause alternative, counted from 1 as the e2ap wrapper reported it
+const (
+	CAUSE_RIC_REQUEST = 1
+	CAUSE_RIC_SERVICE = 2
+	CAUSE_TRANSPORT   = 3
+	CAUSE_PROTOCOL    = 4
+	CAUSE_MISC        = 5
+	CAUSE_TYPE_COUNT  = 5
+)
+
+// CauseID values of CAUSE_RIC_REQUEST: CauseRIC of E2AP v1
+const (
+	CAUSE_RAN_FUNCTION_ID_INVALID = iota
+	CAUSE_ACTION_NOT_SUPPORTED
+	CAUSE_EXCESSIVE_ACTIONS
+	CAUSE_DUPLICATE_ACTION
+	CAUSE_DUPLICATE_EVENT
+	CAUSE_FUNCTION_RESOURCE_LIMIT
+	CAUSE_RIC_REQUEST_ID_UNKNOWN
+	CAUSE_INCONSISTENT_ACTION_SUBSEQUENT_ACTION_SEQUENCE
+	CAUSE_CONTROL_MESSAGE_INVALID
+	CAUSE_CALL_PROCESS_ID_INVALID
+	CAUSE_RIC_REQUEST_UNSPECIFIED
+)
+
+// CauseID values of CAUSE_RIC_SERVICE: CauseRICservice
+const (
+	CAUSE_FUNCTION_NOT_REQUIRED = iota
+	CAUSE_EXCESSIVE_FUNCTIONS
+	CAUSE_RIC_RESOURCE_LIMIT
+)
+
+// CauseID values of CAUSE_TRANSPORT: CauseTransport
+const (
+	CAUSE_TRANSPORT_UNSPECIFIED = iota
+	CAUSE_TRANSPORT_RESOURCE_UNAVAILABLE
+)
+
+// CauseID values of CAUSE_PROTOCOL: CauseProtocol
+const (
+	CAUSE_TRANSFER_SYNTAX_ERROR = iota
+	CAUSE_ABSTRACT_SYNTAX_ERROR_REJECT
+	CAUSE_ABSTRACT_SYNTAX_ERROR_IGNORE_AND_NOTIFY
+	CAUSE_MESSAGE_NOT_COMPATIBLE_WITH_RECEIVER_STATE
+	CAUSE_SEMANTIC_ERROR
+	CAUSE_ABSTRACT_SYNTAX_ERROR_FALSELY_CONSTRUCTED_MESSAGE
+	CAUSE_PROTOCOL_UNSPECIFIED
+)
+
+// CauseID values of CAUSE_MISC: CauseMisc
+const (
+	CAUSE_CONTROL_PROCESSING_OVERLOAD = iota
+	CAUSE_HARDWARE_FAILURE
+	CAUSE_OM_INTERVENTION
+	CAUSE_MISC_UNSPECIFIED
+)
+
+// causeValueCounts holds the number of root values of the enumeration of each Cause alternative
+var causeValueCounts = [CAUSE_TYPE_COUNT]int{11, 3, 2, 7, 4}
+
+// e2apIE is one field of a ProtocolIE-Container, its value kept as the complete APER encoding of the
+// value type so that unknown IEs survive a decode and re-encode
+type e2apIE struct {
+	id          int64
+	criticality int
+	value       []byte
+}
+
+// e2apPDU is an E2AP-PDU of any elementary procedure, reduced to its protocolIEs
+type e2apPDU struct {
+	kind          int //E2AP_INITIATING_MESSAGE, E2AP_SUCCESSFUL_OUTCOME or E2AP_UNSUCCESSFUL_OUTCOME
+	procedureCode int64
+	criticality   int
+	ies           []e2apIE
+}
+
+func newE2apPDU(kind int, procedureCode int64, criticality int) *e2apPDU {
+	return &e2apPDU{kind: kind, procedureCode: procedureCode, criticality: criticality}
+}
+
+// add appends an IE whose value is written by value
+func (p *e2apPDU) add(id int64, criticality int, value func(e *aper.Encoder) error) error {
+	e := aper.NewEncoder()
+	if err := value(e); err != nil {
+		return errors.New("IE " + strconv.FormatInt(id, 10) + ": " + err.Error())
+	}
+	p.ies = append(p.ies, e2apIE{id: id, criticality: criticality, value: e.Bytes()})
+	return nil
+}
+
+// find returns a Decoder over the value of the first IE with the given id, nil when absent
+func (p *e2apPDU) find(id int64) *aper.Decoder {
+	for i := range p.ies {
+		if p.ies[i].id == id {
+			return aper.NewDecoder(p.ies[i].value)
+		}
+	}
+	return nil
+}
+
+// mandatory returns a Decoder over the value of a mandatory IE
+func (p *e2apPDU) mandatory(id int64) (*aper.Decoder, error) {
+	d := p.find(id)
+	if d == nil {
+		return nil, errors.New("Missing mandatory IE " + strconv.FormatInt(id, 10))
+	}
+	return d, nil
+}
+
+// e2apMessageIEs lists the IEs each decoded message may carry, keyed by message kind and procedure code
+var e2apMessageIEs = map[[2]int64][]int64{
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION}:       {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_SUBSCRIPTION_DETAILS},
+	{E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION}:       {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_ACTIONS_ADMITTED, IE_RIC_ACTIONS_NOT_ADMITTED},
+	{E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION}:     {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_ACTIONS_NOT_ADMITTED, IE_CRITICALITY_DIAGNOSTICS},
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION_DEL}:   {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID},
+	{E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL}:   {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID},
+	{E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL}: {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_CAUSE, IE_CRITICALITY_DIAGNOSTICS},
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_INDICATION}: {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_ACTION_ID, IE_RIC_INDICATION_SN,
+		IE_RIC_INDICATION_TYPE, IE_RIC_INDICATION_HEADER, IE_RIC_INDICATION_MESSAGE, IE_RIC_CALL_PROCESS_ID},
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_CONTROL}: {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_CALL_PROCESS_ID,
+		IE_RIC_CONTROL_HEADER, IE_RIC_CONTROL_MESSAGE, IE_RIC_CONTROL_ACK_REQUEST},
+	{E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL}: {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_CALL_PROCESS_ID,
+		IE_RIC_CONTROL_STATUS, IE_RIC_CONTROL_OUTCOME},
+	{E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL}: {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_RIC_CALL_PROCESS_ID,
+		IE_CAUSE, IE_RIC_CONTROL_OUTCOME},
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_ERROR_INDICATION}:     {IE_RIC_REQUEST_ID, IE_RAN_FUNCTION_ID, IE_CAUSE, IE_CRITICALITY_DIAGNOSTICS},
+	{E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SERVICE_UPDATE}:   {IE_RAN_FUNCTIONS_ADDED, IE_RAN_FUNCTIONS_MODIFIED, IE_RAN_FUNCTIONS_DELETED},
+	{E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE}:   {IE_RAN_FUNCTIONS_ACCEPTED, IE_RAN_FUNCTIONS_REJECTED},
+	{E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE}: {IE_RAN_FUNCTIONS_REJECTED, IE_TIME_TO_WAIT, IE_CRITICALITY_DIAGNOSTICS},
+}
+
+// expect checks the message is the wanted one. As E2AP section 10.3.4 requires, an IE that is not
+// comprehended fails the message when sent with criticality reject, and is skipped otherwise.
+func (p *e2apPDU) expect(kind int, procedureCode int64) error {
+	if p.kind != kind || p.procedureCode != procedureCode {
+		return errors.New("Unexpected E2AP message " + strconv.Itoa(p.kind) + "/" + strconv.FormatInt(p.procedureCode, 10) +
+			", want " + strconv.Itoa(kind) + "/" + strconv.FormatInt(procedureCode, 10))
+	}
+	known := e2apMessageIEs[[2]int64{int64(kind), procedureCode}]
+next:
+	for _, ie := range p.ies {
+		if ie.criticality != CRITICALITY_REJECT {
+			continue
+		}
+		for _, id := range known {
+			if ie.id == id {
+				continue next
+			}
+		}
+		return errors.New("Unknown IE " + strconv.FormatInt(ie.id, 10) + " with criticality reject")
+	}
+	return nil
+}
+
+func (p *e2apPDU) encode() ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutChoice(p.kind, 3, true)
+	if err := e.PutConstrainedWholeNumber(p.procedureCode, 0, 255); err != nil {
+		return nil, err
+	}
+	if err := e.PutEnumerated(p.criticality, 3, false); err != nil {
+		return nil, err
+	}
+	err := e.PutOpenType(func(e *aper.Encoder) error {
+		e.PutBool(false)
+		if err := e.PutSequenceOf(len(p.ies), 0, MAX_PROTOCOL_IES, false); err != nil {
+			return err
+		}
+		for _, ie := range p.ies {
+			if err := putField(e, ie.id, ie.criticality, func(e *aper.Encoder) error {
+				e.PutOctets(ie.value)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func decodeE2apPDU(buf []byte) (*e2apPDU, error) {
+	d := aper.NewDecoder(buf)
+	kind, err := d.GetChoice(3, true)
+	if err != nil {
+		return nil, err
+	}
+	if kind >= 3 {
+		return nil, errors.New("Unknown E2AP-PDU alternative " + strconv.Itoa(kind))
+	}
+	p := &e2apPDU{kind: kind}
+	if p.procedureCode, err = d.GetConstrainedWholeNumber(0, 255); err != nil {
+		return nil, err
+	}
+	if p.criticality, err = d.GetEnumerated(3, false); err != nil {
+		return nil, err
+	}
+	value, err := d.GetOpenType()
+	if err != nil {
+		return nil, err
+	}
+	extended, err := value.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	n, err := value.GetSequenceOf(0, MAX_PROTOCOL_IES, false, 32)
+	if err != nil {
+		return nil, err
+	}
+	p.ies = make([]e2apIE, n)
+	for i := range p.ies {
+		ie := &p.ies[i]
+		if ie.id, err = value.GetConstrainedWholeNumber(0, MAX_PROTOCOL_IES); err != nil {
+			return nil, err
+		}
+		if ie.criticality, err = value.GetEnumerated(3, false); err != nil {
+			return nil, err
+		}
+		if ie.value, _, err = value.GetFragmented(8); err != nil {
+			return nil, err
+		}
+	}
+	if err = skipExtensions(value, extended); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// putField writes a ProtocolIE-Field, which is also the ProtocolIE-SingleContainer of the list items
+func putField(e *aper.Encoder, id int64, criticality int, value func(e *aper.Encoder) error) error {
+	if err := e.PutConstrainedWholeNumber(id, 0, MAX_PROTOCOL_IES); err != nil {
+		return err
+	}
+	if err := e.PutEnumerated(criticality, 3, false); err != nil {
+		return err
+	}
+	return e.PutOpenType(value)
+}
+
+// getField reads a ProtocolIE-SingleContainer list item that must carry the IE id
+func getField(d *aper.Decoder, id int64) (*aper.Decoder, error) {
+	itemID, err := d.GetConstrainedWholeNumber(0, MAX_PROTOCOL_IES)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = d.GetEnumerated(3, false); err != nil {
+		return nil, err
+	}
+	if itemID != id {
+		return nil, errors.New("Unexpected list item IE " + strconv.FormatInt(itemID, 10) + ", want " + strconv.FormatInt(id, 10))
+	}
+	return d.GetOpenType()
+}
+
+func putRequestID(e *aper.Encoder, ricRequestorID int64, ricInstanceID int64) error {
+	e.PutBool(false)
+	if err := e.PutConstrainedWholeNumber(ricRequestorID, 0, MAX_RIC_REQUESTOR_ID); err != nil {
+		return err
+	}
+	return e.PutConstrainedWholeNumber(ricInstanceID, 0, MAX_RIC_REQUESTOR_ID)
+}
+
+func getRequestID(d *aper.Decoder) (ricRequestorID int64, ricInstanceID int64, err error) {
+	extended, err := d.GetBool()
+	if err != nil {
+		return
+	}
+	if ricRequestorID, err = d.GetConstrainedWholeNumber(0, MAX_RIC_REQUESTOR_ID); err != nil {
+		return
+	}
+	if ricInstanceID, err = d.GetConstrainedWholeNumber(0, MAX_RIC_REQUESTOR_ID); err != nil {
+		return
+	}
+	err = skipExtensions(d, extended)
+	return
+}
+
+func putRanFunctionID(e *aper.Encoder, ranFunctionID int64) error {
+	return e.PutConstrainedWholeNumber(ranFunctionID, 0, MAX_RAN_FUNCTION_ID)
+}
+
+func getRanFunctionID(d *aper.Decoder) (int64, error) {
+	return d.GetConstrainedWholeNumber(0, MAX_RAN_FUNCTION_ID)
+}
+
+func putOctetString(e *aper.Encoder, v []byte) error {
+	return e.PutOctetString(v, 0, aper.UNBOUNDED, false)
+}
+
+// getOctetString never returns nil on success, so that an empty but present optional IE
+// is told apart from an absent one
+func getOctetString(d *aper.Decoder) ([]byte, error) {
+	v, err := d.GetOctetString(0, aper.UNBOUNDED, false)
+	if err == nil && v == nil {
+		v = []byte{}
+	}
+	return v, err
+}
+
+func putCause(e *aper.Encoder, cause CauseItemType) error {
+	if cause.CauseType < CAUSE_RIC_REQUEST || cause.CauseType > CAUSE_TYPE_COUNT {
+		return errors.New("Invalid Cause type " + strconv.Itoa(int(cause.CauseType)))
+	}
+	e.PutChoice(int(cause.CauseType)-1, CAUSE_TYPE_COUNT, true)
+	return e.PutEnumerated(int(cause.CauseID), causeValueCounts[cause.CauseType-1], true)
+}
+
+// getCause reads a Cause into the CauseType (choice present, 1..5) and CauseID (enumeration index)
+// pair the e2ap wrapper reported. An extension alternative has a CauseType above 5 and CauseID -1.
+func getCause(d *aper.Decoder) (cause CauseItemType, err error) {
+	index, err := d.GetChoice(CAUSE_TYPE_COUNT, true)
+	if err != nil {
+		return
+	}
+	cause.CauseType = int32(index + 1)
+	if index >= CAUSE_TYPE_COUNT {
+		cause.CauseID = -1
+		_, err = d.GetOpenType()
+		return
+	}
+	value, err := d.GetEnumerated(causeValueCounts[index], true)
+	cause.CauseID = int32(value)
+	return
+}
+
+func putCriticalityDiagnostics(e *aper.Encoder, c *CriticalityDiagnosticsType) error {
+	e.PutBool(false)
+	e.PutBool(c.ProcedureCode >= 0)
+	e.PutBool(c.TriggeringMessage >= 0)
+	e.PutBool(c.ProcedureCriticality >= 0)
+	e.PutBool(c.RequestID >= 0)
+	e.PutBool(len(c.IEs) > 0)
+	if c.ProcedureCode >= 0 {
+		if err := e.PutConstrainedWholeNumber(int64(c.ProcedureCode), 0, 255); err != nil {
+			return err
+		}
+	}
+	if c.TriggeringMessage >= 0 {
+		if err := e.PutEnumerated(int(c.TriggeringMessage), TRIGGERING_MESSAGE_COUNT, false); err != nil {
+			return err
+		}
+	}
+	if c.ProcedureCriticality >= 0 {
+		if err := e.PutEnumerated(int(c.ProcedureCriticality), 3, false); err != nil {
+			return err
+		}
+	}
+	if c.RequestID >= 0 {
+		if err := putRequestID(e, int64(c.RequestID), int64(c.RequestSequenceNumber)); err != nil {
+			return err
+		}
+	}
+	if len(c.IEs) > 0 {
+		if err := e.PutSequenceOf(len(c.IEs), 1, MAX_CRITICALITY_DIAGNOSTICS, false); err != nil {
+			return err
+		}
+		for _, ie := range c.IEs {
+			e.PutBool(false)
+			if err := e.PutEnumerated(int(ie.IECriticality), 3, false); err != nil {
+				return err
+			}
+			if err := e.PutConstrainedWholeNumber(int64(ie.IEID), 0, MAX_PROTOCOL_IES); err != nil {
+				return err
+			}
+			if err := e.PutEnumerated(int(ie.TypeOfError), TYPE_OF_ERROR_COUNT, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getCriticalityDiagnostics(d *aper.Decoder) (*CriticalityDiagnosticsType, error) {
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	present, err := getOptionals(d, 5)
+	if err != nil {
+		return nil, err
+	}
+	c := &CriticalityDiagnosticsType{ProcedureCode: -1, TriggeringMessage: -1, ProcedureCriticality: -1, RequestID: -1, RequestSequenceNumber: -1}
+	if present[0] {
+		v, err := d.GetConstrainedWholeNumber(0, 255)
+		if err != nil {
+			return nil, err
+		}
+		c.ProcedureCode = int32(v)
+	}
+	if present[1] {
+		v, err := d.GetEnumerated(TRIGGERING_MESSAGE_COUNT, false)
+		if err != nil {
+			return nil, err
+		}
+		c.TriggeringMessage = int32(v)
+	}
+	if present[2] {
+		v, err := d.GetEnumerated(3, false)
+		if err != nil {
+			return nil, err
+		}
+		c.ProcedureCriticality = int32(v)
+	}
+	if present[3] {
+		requestor, instance, err := getRequestID(d)
+		if err != nil {
+			return nil, err
+		}
+		c.RequestID, c.RequestSequenceNumber = int32(requestor), int32(instance)
+	}
+	if present[4] {
+		n, err := d.GetSequenceOf(1, MAX_CRITICALITY_DIAGNOSTICS, false, 20)
+		if err != nil {
+			return nil, err
+		}
+		c.IEs = make([]CriticalityDiagnosticsIEType, n)
+		for i := range c.IEs {
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			criticality, err := d.GetEnumerated(3, false)
+			if err != nil {
+				return nil, err
+			}
+			id, err := d.GetConstrainedWholeNumber(0, MAX_PROTOCOL_IES)
+			if err != nil {
+				return nil, err
+			}
+			typeOfError, err := d.GetEnumerated(TYPE_OF_ERROR_COUNT, true)
+			if err != nil {
+				return nil, err
+			}
+			c.IEs[i] = CriticalityDiagnosticsIEType{IECriticality: int32(criticality), IEID: int32(id), TypeOfError: int32(typeOfError)}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, skipExtensions(d, extended)
+}
+
+/* RICsubscriptionRequest */
+
+type actionToBeSetup struct {
+	id                   int64
+	actionType           int64
+	definition           []byte //nil when absent
+	subsequentActionType int64  //-1 when the subsequent action is absent
+	timeToWait           int64
+}
+
+func encodeSubscriptionRequest(ricRequestorID int64, ricInstanceID int64, ranFunctionID int64, eventTrigger []byte, actions []actionToBeSetup) ([]byte, error) {
+	p := newE2apPDU(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION, CRITICALITY_REJECT)
+	if err := p.add(IE_RIC_REQUEST_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return putRequestID(e, ricRequestorID, ricInstanceID)
+	}); err != nil {
+		return nil, err
+	}
+	if err := p.add(IE_RAN_FUNCTION_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return putRanFunctionID(e, ranFunctionID)
+	}); err != nil {
+		return nil, err
+	}
+	if err := p.add(IE_RIC_SUBSCRIPTION_DETAILS, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		e.PutBool(false)
+		if err := putOctetString(e, eventTrigger); err != nil {
+			return err
+		}
+		if err := e.PutSequenceOf(len(actions), 1, MAX_RIC_ACTIONS, false); err != nil {
+			return err
+		}
+		for _, action := range actions {
+			//the e2ap wrapper sent the action items with criticality reject, kept for byte compatibility
+			if err := putField(e, IE_RIC_ACTION_TO_SETUP_ITEM, CRITICALITY_REJECT, action.encode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func (a *actionToBeSetup) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(a.definition != nil)
+	e.PutBool(a.subsequentActionType >= 0)
+	if err := e.PutConstrainedWholeNumber(a.id, 0, MAX_RIC_ACTION_ID); err != nil {
+		return err
+	}
+	if err := e.PutEnumerated(int(a.actionType), RIC_ACTION_TYPE_COUNT, true); err != nil {
+		return err
+	}
+	if a.definition != nil {
+		if err := putOctetString(e, a.definition); err != nil {
+			return err
+		}
+	}
+	if a.subsequentActionType >= 0 {
+		e.PutBool(false)
+		if err := e.PutEnumerated(int(a.subsequentActionType), RIC_SUBSEQUENT_ACTION_COUNT, true); err != nil {
+			return err
+		}
+		if err := e.PutEnumerated(int(a.timeToWait), RIC_TIME_TO_WAIT_COUNT, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *actionToBeSetup) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	if a.id, err = d.GetConstrainedWholeNumber(0, MAX_RIC_ACTION_ID); err != nil {
+		return err
+	}
+	actionType, err := d.GetEnumerated(RIC_ACTION_TYPE_COUNT, true)
+	if err != nil {
+		return err
+	}
+	a.actionType = int64(actionType)
+	if present[0] {
+		if a.definition, err = getOctetString(d); err != nil {
+			return err
+		}
+	}
+	a.subsequentActionType = -1
+	if present[1] {
+		subsequentExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		subsequentActionType, err := d.GetEnumerated(RIC_SUBSEQUENT_ACTION_COUNT, true)
+		if err != nil {
+			return err
+		}
+		timeToWait, err := d.GetEnumerated(RIC_TIME_TO_WAIT_COUNT, true)
+		if err != nil {
+			return err
+		}
+		a.subsequentActionType, a.timeToWait = int64(subsequentActionType), int64(timeToWait)
+		if err = skipExtensions(d, subsequentExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func decodeSubscriptionRequest(buf []byte) (*DecodedSubscriptionRequestMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SUBSCRIPTION); err != nil {
+		return nil, err
+	}
+	msg := &DecodedSubscriptionRequestMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	d, err := p.mandatory(IE_RIC_SUBSCRIPTION_DETAILS)
+	if err != nil {
+		return nil, err
+	}
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	if msg.EventTriggerDefinition, err = getOctetString(d); err != nil {
+		return nil, err
+	}
+	n, err := d.GetSequenceOf(1, MAX_RIC_ACTIONS, false, 24)
+	if err != nil {
+		return nil, err
+	}
+	msg.Actions = make([]ActionToBeSetupType, n)
+	for i := range msg.Actions {
+		item, err := getField(d, IE_RIC_ACTION_TO_SETUP_ITEM)
+		if err != nil {
+			return nil, err
+		}
+		var action actionToBeSetup
+		if err = action.decode(item); err != nil {
+			return nil, err
+		}
+		msg.Actions[i] = ActionToBeSetupType{
+			ActionID:         int32(action.id),
+			ActionType:       int32(action.actionType),
+			ActionDefinition: action.definition,
+		}
+		if action.subsequentActionType >= 0 {
+			msg.Actions[i].SubsequentAction = SubsequentAction{IsValid: 1, SubsequentActionType: action.subsequentActionType, TimeToWait: action.timeToWait}
+		}
+	}
+	return msg, skipExtensions(d, extended)
+}
+
+// requestAndFunction reads the mandatory RICrequestID and RANfunctionID IEs
+func (p *e2apPDU) requestAndFunction() (requestID int32, requestSequenceNumber int32, funcID int32, err error) {
+	d, err := p.mandatory(IE_RIC_REQUEST_ID)
+	if err != nil {
+		return
+	}
+	requestor, instance, err := getRequestID(d)
+	if err != nil {
+		return
+	}
+	if d, err = p.mandatory(IE_RAN_FUNCTION_ID); err != nil {
+		return
+	}
+	function, err := getRanFunctionID(d)
+	return int32(requestor), int32(instance), int32(function), err
+}
+
+// optionalRequestAndFunction reads the optional RICrequestID and RANfunctionID IEs, -1 when absent
+func (p *e2apPDU) optionalRequestAndFunction() (requestID int32, requestSequenceNumber int32, funcID int32, err error) {
+	requestID, requestSequenceNumber, funcID = -1, -1, -1
+	if d := p.find(IE_RIC_REQUEST_ID); d != nil {
+		requestor, instance, err := getRequestID(d)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		requestID, requestSequenceNumber = int32(requestor), int32(instance)
+	}
+	if d := p.find(IE_RAN_FUNCTION_ID); d != nil {
+		function, err := getRanFunctionID(d)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		funcID = int32(function)
+	}
+	return
+}
+
+// addRequestAndFunction appends the RICrequestID and RANfunctionID IEs all RIC procedures start with
+func (p *e2apPDU) addRequestAndFunction(requestID int32, requestSequenceNumber int32, funcID int32) error {
+	if err := p.add(IE_RIC_REQUEST_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return putRequestID(e, int64(requestID), int64(requestSequenceNumber))
+	}); err != nil {
+		return err
+	}
+	return p.add(IE_RAN_FUNCTION_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return putRanFunctionID(e, int64(funcID))
+	})
+}
+
+// getRequestSequenceNumber returns the ricInstanceID of the RICrequestID of a message of the given kind and procedure
+func getRequestSequenceNumber(buf []byte, kind int, procedureCode int64) (uint16, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return 0, err
+	}
+	if err = p.expect(kind, procedureCode); err != nil {
+		return 0, err
+	}
+	d, err := p.mandatory(IE_RIC_REQUEST_ID)
+	if err != nil {
+		return 0, err
+	}
+	_, instance, err := getRequestID(d)
+	return uint16(instance), err
+}
+
+// setRequestSequenceNumber replaces the ricInstanceID of the RICrequestID of a message of the given kind
+// and procedure, leaving the other IEs untouched
+func setRequestSequenceNumber(buf []byte, kind int, procedureCode int64, sequenceNumber uint16) ([]byte, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(kind, procedureCode); err != nil {
+		return nil, err
+	}
+	for i := range p.ies {
+		ie := &p.ies[i]
+		if ie.id != IE_RIC_REQUEST_ID {
+			continue
+		}
+		requestor, _, err := getRequestID(aper.NewDecoder(ie.value))
+		if err != nil {
+			return nil, err
+		}
+		e := aper.NewEncoder()
+		if err = putRequestID(e, requestor, int64(sequenceNumber)); err != nil {
+			return nil, err
+		}
+		ie.value = e.Bytes()
+		return p.encode()
+	}
+	return nil, errors.New("Missing mandatory IE " + strconv.Itoa(IE_RIC_REQUEST_ID))
+}
+
+/* RICsubscriptionResponse */
+
+func encodeSubscriptionResponse(msg *DecodedSubscriptionResponseMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	admitted := msg.ActionAdmittedList.ActionID
+	if err := p.add(IE_RIC_ACTIONS_ADMITTED, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		if err := putActionCount(e, "RICaction-Admitted-List", len(admitted), 1); err != nil {
+			return err
+		}
+		for _, id := range admitted {
+			if err := putField(e, IE_RIC_ACTION_ADMITTED_ITEM, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+				e.PutBool(false)
+				return e.PutConstrainedWholeNumber(int64(id), 0, MAX_RIC_ACTION_ID)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(msg.ActionNotAdmittedList.ActionID) > 0 {
+		if err := p.addActionsNotAdmitted(&msg.ActionNotAdmittedList); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeSubscriptionResponse(buf []byte) (*DecodedSubscriptionResponseMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION); err != nil {
+		return nil, err
+	}
+	msg := &DecodedSubscriptionResponseMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	d, err := p.mandatory(IE_RIC_ACTIONS_ADMITTED)
+	if err != nil {
+		return nil, err
+	}
+	n, err := getActionCount(d, "RICaction-Admitted-List", 1)
+	if err != nil {
+		return nil, err
+	}
+	msg.ActionAdmittedList.ActionID = make([]int32, n)
+	for i := range msg.ActionAdmittedList.ActionID {
+		item, err := getField(d, IE_RIC_ACTION_ADMITTED_ITEM)
+		if err != nil {
+			return nil, err
+		}
+		extended, err := item.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		id, err := item.GetConstrainedWholeNumber(0, MAX_RIC_ACTION_ID)
+		if err != nil {
+			return nil, err
+		}
+		if err = skipExtensions(item, extended); err != nil {
+			return nil, err
+		}
+		msg.ActionAdmittedList.ActionID[i] = int32(id)
+	}
+	if d = p.find(IE_RIC_ACTIONS_NOT_ADMITTED); d != nil {
+		if err = getActionsNotAdmitted(d, &msg.ActionNotAdmittedList); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func (p *e2apPDU) addActionsNotAdmitted(list *ActionNotAdmittedListType) error {
+	if len(list.Cause) != len(list.ActionID) {
+		return errors.New("RICaction-NotAdmitted-List of " + strconv.Itoa(len(list.ActionID)) + " actions and " +
+			strconv.Itoa(len(list.Cause)) + " causes")
+	}
+	return p.add(IE_RIC_ACTIONS_NOT_ADMITTED, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		if err := putActionCount(e, "RICaction-NotAdmitted-List", len(list.ActionID), 0); err != nil {
+			return err
+		}
+		for i := range list.ActionID {
+			if err := putField(e, IE_RIC_ACTION_NOT_ADM_ITEM, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+				e.PutBool(false)
+				if err := e.PutConstrainedWholeNumber(int64(list.ActionID[i]), 0, MAX_RIC_ACTION_ID); err != nil {
+					return err
+				}
+				return putCause(e, list.Cause[i])
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func getActionsNotAdmitted(d *aper.Decoder, list *ActionNotAdmittedListType) error {
+	n, err := getActionCount(d, "RICaction-NotAdmitted-List", 0)
+	if err != nil {
+		return err
+	}
+	list.ActionID, list.Cause = make([]int32, n), make([]CauseItemType, n)
+	for i := range list.ActionID {
+		item, err := getField(d, IE_RIC_ACTION_NOT_ADM_ITEM)
+		if err != nil {
+			return err
+		}
+		extended, err := item.GetBool()
+		if err != nil {
+			return err
+		}
+		id, err := item.GetConstrainedWholeNumber(0, MAX_RIC_ACTION_ID)
+		if err != nil {
+			return err
+		}
+		if list.Cause[i], err = getCause(item); err != nil {
+			return err
+		}
+		if err = skipExtensions(item, extended); err != nil {
+			return err
+		}
+		list.ActionID[i] = int32(id)
+	}
+	return nil
+}
+
+// putActionCount writes the item count of a list of lb..maxofRICactionID actions
+func putActionCount(e *aper.Encoder, list string, n int, lb int64) error {
+	if int64(n) < lb || n > MAX_RIC_ACTIONS {
+		return errors.New(list + " of " + strconv.Itoa(n) + " items, " + strconv.FormatInt(lb, 10) + ".." +
+			strconv.Itoa(MAX_RIC_ACTIONS) + " allowed")
+	}
+	return e.PutSequenceOf(n, lb, MAX_RIC_ACTIONS, false)
+}
+
+// getActionCount reads the item count of a list of lb..maxofRICactionID actions
+func getActionCount(d *aper.Decoder, list string, lb int64) (int, error) {
+	n, err := d.GetSequenceOf(lb, MAX_RIC_ACTIONS, false, 32)
+	if err == aper.ErrLength {
+		return 0, errors.New(list + " exceeds the limit of " + strconv.Itoa(MAX_RIC_ACTIONS) + " items")
+	}
+	return n, err
+}
+
+/* RICsubscriptionFailure */
+
+func encodeSubscriptionFailure(msg *DecodedSubscriptionFailureMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if err := p.addActionsNotAdmitted(&msg.ActionNotAdmittedList); err != nil {
+		return nil, err
+	}
+	if err := p.addCriticalityDiagnostics(msg.CriticalityDiagnostics); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func decodeSubscriptionFailure(buf []byte) (*DecodedSubscriptionFailureMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION); err != nil {
+		return nil, err
+	}
+	msg := &DecodedSubscriptionFailureMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	d, err := p.mandatory(IE_RIC_ACTIONS_NOT_ADMITTED)
+	if err != nil {
+		return nil, err
+	}
+	if err = getActionsNotAdmitted(d, &msg.ActionNotAdmittedList); err != nil {
+		return nil, err
+	}
+	msg.CriticalityDiagnostics, err = p.criticalityDiagnostics()
+	return msg, err
+}
+
+func (p *e2apPDU) addCriticalityDiagnostics(c *CriticalityDiagnosticsType) error {
+	if c == nil {
+		return nil
+	}
+	return p.add(IE_CRITICALITY_DIAGNOSTICS, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+		return putCriticalityDiagnostics(e, c)
+	})
+}
+
+func (p *e2apPDU) criticalityDiagnostics() (*CriticalityDiagnosticsType, error) {
+	d := p.find(IE_CRITICALITY_DIAGNOSTICS)
+	if d == nil {
+		return nil, nil
+	}
+	return getCriticalityDiagnostics(d)
+}
+
+func (p *e2apPDU) addCause(cause CauseItemType) error {
+	return p.add(IE_CAUSE, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+		return putCause(e, cause)
+	})
+}
+
+func (p *e2apPDU) cause() (CauseItemType, error) {
+	d, err := p.mandatory(IE_CAUSE)
+	if err != nil {
+		return CauseItemType{}, err
+	}
+	return getCause(d)
+}
+
+/* RICsubscriptionDeleteRequest, RICsubscriptionDeleteResponse */
+
+func encodeSubscriptionDelete(kind int, ricRequestorID int64, ricInstanceID int64, ranFunctionID int64) ([]byte, error) {
+	p := newE2apPDU(kind, PROCEDURE_RIC_SUBSCRIPTION_DEL, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(int32(ricRequestorID), int32(ricInstanceID), int32(ranFunctionID)); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func decodeSubscriptionDelete(buf []byte, kind int) (*DecodedSubscriptionDeleteMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(kind, PROCEDURE_RIC_SUBSCRIPTION_DEL); err != nil {
+		return nil, err
+	}
+	msg := &DecodedSubscriptionDeleteMessage{}
+	msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction()
+	return msg, err
+}
+
+/* RICsubscriptionDeleteFailure */
+
+func encodeSubscriptionDeleteFailure(msg *DecodedSubscriptionDeleteFailureMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if err := p.addCause(msg.Cause); err != nil {
+		return nil, err
+	}
+	if err := p.addCriticalityDiagnostics(msg.CriticalityDiagnostics); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func decodeSubscriptionDeleteFailure(buf []byte) (*DecodedSubscriptionDeleteFailureMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SUBSCRIPTION_DEL); err != nil {
+		return nil, err
+	}
+	msg := &DecodedSubscriptionDeleteFailureMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	if msg.Cause, err = p.cause(); err != nil {
+		return nil, err
+	}
+	msg.CriticalityDiagnostics, err = p.criticalityDiagnostics()
+	return msg, err
+}
+
+/* RICindication */
+
+func encodeIndication(msg *DecodedIndicationMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_INDICATION, CRITICALITY_IGNORE)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if err := p.add(IE_RIC_ACTION_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return e.PutConstrainedWholeNumber(int64(msg.ActionID), 0, MAX_RIC_ACTION_ID)
+	}); err != nil {
+		return nil, err
+	}
+	if msg.IndSN >= 0 {
+		if err := p.add(IE_RIC_INDICATION_SN, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+			return e.PutConstrainedWholeNumber(int64(msg.IndSN), 0, MAX_RIC_INDICATION_SN)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.add(IE_RIC_INDICATION_TYPE, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return e.PutEnumerated(int(msg.IndType), RIC_INDICATION_TYPE_COUNT, true)
+	}); err != nil {
+		return nil, err
+	}
+	if err := p.addOctetString(IE_RIC_INDICATION_HEADER, CRITICALITY_REJECT, msg.IndHeader); err != nil {
+		return nil, err
+	}
+	if err := p.addOctetString(IE_RIC_INDICATION_MESSAGE, CRITICALITY_REJECT, msg.IndMessage); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID != nil {
+		if err := p.addOctetString(IE_RIC_CALL_PROCESS_ID, CRITICALITY_REJECT, msg.CallProcessID); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeIndication(buf []byte) (*DecodedIndicationMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_INDICATION); err != nil {
+		return nil, err
+	}
+	msg := &DecodedIndicationMessage{IndSN: -1}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	d, err := p.mandatory(IE_RIC_ACTION_ID)
+	if err != nil {
+		return nil, err
+	}
+	actionID, err := d.GetConstrainedWholeNumber(0, MAX_RIC_ACTION_ID)
+	if err != nil {
+		return nil, err
+	}
+	msg.ActionID = int32(actionID)
+	if d = p.find(IE_RIC_INDICATION_SN); d != nil {
+		indSN, err := d.GetConstrainedWholeNumber(0, MAX_RIC_INDICATION_SN)
+		if err != nil {
+			return nil, err
+		}
+		msg.IndSN = int32(indSN)
+	}
+	if d, err = p.mandatory(IE_RIC_INDICATION_TYPE); err != nil {
+		return nil, err
+	}
+	indType, err := d.GetEnumerated(RIC_INDICATION_TYPE_COUNT, true)
+	if err != nil {
+		return nil, err
+	}
+	msg.IndType = int32(indType)
+	if msg.IndHeader, err = p.mandatoryOctetString(IE_RIC_INDICATION_HEADER); err != nil {
+		return nil, err
+	}
+	if msg.IndMessage, err = p.mandatoryOctetString(IE_RIC_INDICATION_MESSAGE); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID, err = p.optionalOctetString(IE_RIC_CALL_PROCESS_ID); err != nil {
+		return nil, err
+	}
+	msg.IndHeaderLength = int32(len(msg.IndHeader))
+	msg.IndMessageLength = int32(len(msg.IndMessage))
+	msg.CallProcessIDLength = int32(len(msg.CallProcessID))
+	return msg, nil
+}
+
+func (p *e2apPDU) addOctetString(id int64, criticality int, v []byte) error {
+	return p.add(id, criticality, func(e *aper.Encoder) error {
+		return putOctetString(e, v)
+	})
+}
+
+func (p *e2apPDU) mandatoryOctetString(id int64) ([]byte, error) {
+	d, err := p.mandatory(id)
+	if err != nil {
+		return nil, err
+	}
+	return getOctetString(d)
+}
+
+// optionalOctetString returns the value of an optional OCTET STRING IE, nil when absent
+func (p *e2apPDU) optionalOctetString(id int64) ([]byte, error) {
+	d := p.find(id)
+	if d == nil {
+		return nil, nil
+	}
+	return getOctetString(d)
+}
+
+/* RICcontrolRequest */
+
+func encodeControlRequest(msg *DecodedControlRequestMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_CONTROL, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID != nil {
+		if err := p.addOctetString(IE_RIC_CALL_PROCESS_ID, CRITICALITY_REJECT, msg.CallProcessID); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.addOctetString(IE_RIC_CONTROL_HEADER, CRITICALITY_REJECT, msg.ControlHeader); err != nil {
+		return nil, err
+	}
+	if err := p.addOctetString(IE_RIC_CONTROL_MESSAGE, CRITICALITY_REJECT, msg.ControlMessage); err != nil {
+		return nil, err
+	}
+	if msg.ControlAckRequest >= 0 {
+		if err := p.add(IE_RIC_CONTROL_ACK_REQUEST, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+			return e.PutEnumerated(int(msg.ControlAckRequest), RIC_CONTROL_ACK_COUNT, true)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeControlRequest(buf []byte) (*DecodedControlRequestMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_CONTROL); err != nil {
+		return nil, err
+	}
+	msg := &DecodedControlRequestMessage{ControlAckRequest: -1}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID, err = p.optionalOctetString(IE_RIC_CALL_PROCESS_ID); err != nil {
+		return nil, err
+	}
+	if msg.ControlHeader, err = p.mandatoryOctetString(IE_RIC_CONTROL_HEADER); err != nil {
+		return nil, err
+	}
+	if msg.ControlMessage, err = p.mandatoryOctetString(IE_RIC_CONTROL_MESSAGE); err != nil {
+		return nil, err
+	}
+	if d := p.find(IE_RIC_CONTROL_ACK_REQUEST); d != nil {
+		ackRequest, err := d.GetEnumerated(RIC_CONTROL_ACK_COUNT, true)
+		if err != nil {
+			return nil, err
+		}
+		msg.ControlAckRequest = int32(ackRequest)
+	}
+	return msg, nil
+}
+
+/* RICcontrolAcknowledge */
+
+func encodeControlAcknowledge(msg *DecodedControlAcknowledgeMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID != nil {
+		if err := p.addOctetString(IE_RIC_CALL_PROCESS_ID, CRITICALITY_REJECT, msg.CallProcessID); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.add(IE_RIC_CONTROL_STATUS, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+		return e.PutEnumerated(int(msg.ControlStatus), RIC_CONTROL_STATUS_COUNT, true)
+	}); err != nil {
+		return nil, err
+	}
+	if msg.ControlOutcome != nil {
+		if err := p.addOctetString(IE_RIC_CONTROL_OUTCOME, CRITICALITY_REJECT, msg.ControlOutcome); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeControlAcknowledge(buf []byte) (*DecodedControlAcknowledgeMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL); err != nil {
+		return nil, err
+	}
+	msg := &DecodedControlAcknowledgeMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID, err = p.optionalOctetString(IE_RIC_CALL_PROCESS_ID); err != nil {
+		return nil, err
+	}
+	d, err := p.mandatory(IE_RIC_CONTROL_STATUS)
+	if err != nil {
+		return nil, err
+	}
+	status, err := d.GetEnumerated(RIC_CONTROL_STATUS_COUNT, true)
+	if err != nil {
+		return nil, err
+	}
+	msg.ControlStatus = int32(status)
+	msg.ControlOutcome, err = p.optionalOctetString(IE_RIC_CONTROL_OUTCOME)
+	return msg, err
+}
+
+/* RICcontrolFailure */
+
+func encodeControlFailure(msg *DecodedControlFailureMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL, CRITICALITY_REJECT)
+	if err := p.addRequestAndFunction(msg.RequestID, msg.RequestSequenceNumber, msg.FuncID); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID != nil {
+		if err := p.addOctetString(IE_RIC_CALL_PROCESS_ID, CRITICALITY_REJECT, msg.CallProcessID); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.addCause(msg.Cause); err != nil {
+		return nil, err
+	}
+	if msg.ControlOutcome != nil {
+		if err := p.addOctetString(IE_RIC_CONTROL_OUTCOME, CRITICALITY_REJECT, msg.ControlOutcome); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeControlFailure(buf []byte) (*DecodedControlFailureMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_CONTROL); err != nil {
+		return nil, err
+	}
+	msg := &DecodedControlFailureMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.requestAndFunction(); err != nil {
+		return nil, err
+	}
+	if msg.CallProcessID, err = p.optionalOctetString(IE_RIC_CALL_PROCESS_ID); err != nil {
+		return nil, err
+	}
+	if msg.Cause, err = p.cause(); err != nil {
+		return nil, err
+	}
+	msg.ControlOutcome, err = p.optionalOctetString(IE_RIC_CONTROL_OUTCOME)
+	return msg, err
+}
+
+/* ErrorIndication */
+
+func encodeErrorIndication(msg *DecodedErrorIndicationMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_INITIATING_MESSAGE, PROCEDURE_ERROR_INDICATION, CRITICALITY_IGNORE)
+	if msg.RequestID >= 0 {
+		if err := p.add(IE_RIC_REQUEST_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+			return putRequestID(e, int64(msg.RequestID), int64(msg.RequestSequenceNumber))
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if msg.FuncID >= 0 {
+		if err := p.add(IE_RAN_FUNCTION_ID, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+			return putRanFunctionID(e, int64(msg.FuncID))
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Cause != nil {
+		if err := p.addCause(*msg.Cause); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.addCriticalityDiagnostics(msg.CriticalityDiagnostics); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func decodeErrorIndication(buf []byte) (*DecodedErrorIndicationMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_INITIATING_MESSAGE, PROCEDURE_ERROR_INDICATION); err != nil {
+		return nil, err
+	}
+	msg := &DecodedErrorIndicationMessage{}
+	if msg.RequestID, msg.RequestSequenceNumber, msg.FuncID, err = p.optionalRequestAndFunction(); err != nil {
+		return nil, err
+	}
+	if p.find(IE_CAUSE) != nil {
+		cause, err := p.cause()
+		if err != nil {
+			return nil, err
+		}
+		msg.Cause = &cause
+	}
+	msg.CriticalityDiagnostics, err = p.criticalityDiagnostics()
+	return msg, err
+}
+
+/* RICserviceUpdate */
+
+func encodeServiceUpdate(msg *DecodedServiceUpdateMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SERVICE_UPDATE, CRITICALITY_REJECT)
+	lists := []struct {
+		id        int64
+		functions []RanFunctionItemType
+	}{{IE_RAN_FUNCTIONS_ADDED, msg.Added}, {IE_RAN_FUNCTIONS_MODIFIED, msg.Modified}}
+	for _, list := range lists {
+		if list.functions == nil {
+			continue
+		}
+		functions := list.functions
+		if err := p.add(list.id, CRITICALITY_REJECT, func(e *aper.Encoder) error {
+			if err := e.PutSequenceOf(len(functions), 0, MAX_RAN_FUNCTIONS, false); err != nil {
+				return err
+			}
+			for i := range functions {
+				if err := putField(e, IE_RAN_FUNCTION_ITEM, CRITICALITY_IGNORE, functions[i].encode); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Deleted != nil {
+		if err := p.addRanFunctionIDs(IE_RAN_FUNCTIONS_DELETED, CRITICALITY_REJECT, msg.Deleted); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeServiceUpdate(buf []byte) (*DecodedServiceUpdateMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_INITIATING_MESSAGE, PROCEDURE_RIC_SERVICE_UPDATE); err != nil {
+		return nil, err
+	}
+	msg := &DecodedServiceUpdateMessage{}
+	if msg.Added, err = p.ranFunctions(IE_RAN_FUNCTIONS_ADDED); err != nil {
+		return nil, err
+	}
+	if msg.Modified, err = p.ranFunctions(IE_RAN_FUNCTIONS_MODIFIED); err != nil {
+		return nil, err
+	}
+	msg.Deleted, err = p.ranFunctionIDs(IE_RAN_FUNCTIONS_DELETED)
+	return msg, err
+}
+
+func (f *RanFunctionItemType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putRanFunctionID(e, int64(f.FuncID)); err != nil {
+		return err
+	}
+	if err := putOctetString(e, f.Definition); err != nil {
+		return err
+	}
+	return e.PutConstrainedWholeNumber(int64(f.Revision), 0, MAX_RAN_FUNCTION_ID)
+}
+
+func (f *RanFunctionItemType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	funcID, err := getRanFunctionID(d)
+	if err != nil {
+		return err
+	}
+	if f.Definition, err = getOctetString(d); err != nil {
+		return err
+	}
+	revision, err := d.GetConstrainedWholeNumber(0, MAX_RAN_FUNCTION_ID)
+	if err != nil {
+		return err
+	}
+	f.FuncID, f.Revision = int32(funcID), int32(revision)
+	return skipExtensions(d, extended)
+}
+
+// ranFunctions reads an optional RANfunctions-List IE, nil when absent
+func (p *e2apPDU) ranFunctions(id int64) ([]RanFunctionItemType, error) {
+	d := p.find(id)
+	if d == nil {
+		return nil, nil
+	}
+	n, err := d.GetSequenceOf(0, MAX_RAN_FUNCTIONS, false, 32)
+	if err != nil {
+		return nil, err
+	}
+	functions := make([]RanFunctionItemType, n)
+	for i := range functions {
+		item, err := getField(d, IE_RAN_FUNCTION_ITEM)
+		if err != nil {
+			return nil, err
+		}
+		if err = functions[i].decode(item); err != nil {
+			return nil, err
+		}
+	}
+	return functions, nil
+}
+
+func (p *e2apPDU) addRanFunctionIDs(id int64, criticality int, functions []RanFunctionIDItemType) error {
+	return p.add(id, criticality, func(e *aper.Encoder) error {
+		if err := e.PutSequenceOf(len(functions), 0, MAX_RAN_FUNCTIONS, false); err != nil {
+			return err
+		}
+		for _, function := range functions {
+			if err := putField(e, IE_RAN_FUNCTION_ID_ITEM, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+				e.PutBool(false)
+				if err := putRanFunctionID(e, int64(function.FuncID)); err != nil {
+					return err
+				}
+				return e.PutConstrainedWholeNumber(int64(function.Revision), 0, MAX_RAN_FUNCTION_ID)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ranFunctionIDs reads an optional RANfunctionsID-List IE, nil when absent
+func (p *e2apPDU) ranFunctionIDs(id int64) ([]RanFunctionIDItemType, error) {
+	d := p.find(id)
+	if d == nil {
+		return nil, nil
+	}
+	n, err := d.GetSequenceOf(0, MAX_RAN_FUNCTIONS, false, 32)
+	if err != nil {
+		return nil, err
+	}
+	functions := make([]RanFunctionIDItemType, n)
+	for i := range functions {
+		item, err := getField(d, IE_RAN_FUNCTION_ID_ITEM)
+		if err != nil {
+			return nil, err
+		}
+		extended, err := item.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		funcID, err := getRanFunctionID(item)
+		if err != nil {
+			return nil, err
+		}
+		revision, err := item.GetConstrainedWholeNumber(0, MAX_RAN_FUNCTION_ID)
+		if err != nil {
+			return nil, err
+		}
+		functions[i] = RanFunctionIDItemType{FuncID: int32(funcID), Revision: int32(revision)}
+		if err = skipExtensions(item, extended); err != nil {
+			return nil, err
+		}
+	}
+	return functions, nil
+}
+
+func (p *e2apPDU) addRanFunctionCauses(id int64, criticality int, functions []RanFunctionIDCauseItemType) error {
+	return p.add(id, criticality, func(e *aper.Encoder) error {
+		if err := e.PutSequenceOf(len(functions), 0, MAX_RAN_FUNCTIONS, false); err != nil {
+			return err
+		}
+		for _, function := range functions {
+			if err := putField(e, IE_RAN_FUNCTION_CAUSE_ITEM, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+				e.PutBool(false)
+				if err := putRanFunctionID(e, int64(function.FuncID)); err != nil {
+					return err
+				}
+				return putCause(e, function.Cause)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ranFunctionCauses reads an optional RANfunctionsIDcause-List IE, nil when absent
+func (p *e2apPDU) ranFunctionCauses(id int64) ([]RanFunctionIDCauseItemType, error) {
+	d := p.find(id)
+	if d == nil {
+		return nil, nil
+	}
+	n, err := d.GetSequenceOf(0, MAX_RAN_FUNCTIONS, false, 32)
+	if err != nil {
+		return nil, err
+	}
+	functions := make([]RanFunctionIDCauseItemType, n)
+	for i := range functions {
+		item, err := getField(d, IE_RAN_FUNCTION_CAUSE_ITEM)
+		if err != nil {
+			return nil, err
+		}
+		extended, err := item.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		funcID, err := getRanFunctionID(item)
+		if err != nil {
+			return nil, err
+		}
+		cause, err := getCause(item)
+		if err != nil {
+			return nil, err
+		}
+		functions[i] = RanFunctionIDCauseItemType{FuncID: int32(funcID), Cause: cause}
+		if err = skipExtensions(item, extended); err != nil {
+			return nil, err
+		}
+	}
+	return functions, nil
+}
+
+/* RICserviceUpdateAcknowledge */
+
+func encodeServiceUpdateAcknowledge(msg *DecodedServiceUpdateAcknowledgeMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE, CRITICALITY_REJECT)
+	if msg.Accepted != nil {
+		if err := p.addRanFunctionIDs(IE_RAN_FUNCTIONS_ACCEPTED, CRITICALITY_REJECT, msg.Accepted); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Rejected != nil {
+		if err := p.addRanFunctionCauses(IE_RAN_FUNCTIONS_REJECTED, CRITICALITY_REJECT, msg.Rejected); err != nil {
+			return nil, err
+		}
+	}
+	return p.encode()
+}
+
+func decodeServiceUpdateAcknowledge(buf []byte) (*DecodedServiceUpdateAcknowledgeMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_SUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE); err != nil {
+		return nil, err
+	}
+	msg := &DecodedServiceUpdateAcknowledgeMessage{}
+	if msg.Accepted, err = p.ranFunctionIDs(IE_RAN_FUNCTIONS_ACCEPTED); err != nil {
+		return nil, err
+	}
+	msg.Rejected, err = p.ranFunctionCauses(IE_RAN_FUNCTIONS_REJECTED)
+	return msg, err
+}
+
+/* RICserviceUpdateFailure */
+
+func encodeServiceUpdateFailure(msg *DecodedServiceUpdateFailureMessage) ([]byte, error) {
+	p := newE2apPDU(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE, CRITICALITY_REJECT)
+	if msg.Rejected != nil {
+		if err := p.addRanFunctionCauses(IE_RAN_FUNCTIONS_REJECTED, CRITICALITY_IGNORE, msg.Rejected); err != nil {
+			return nil, err
+		}
+	}
+	if msg.TimeToWait >= 0 {
+		if err := p.add(IE_TIME_TO_WAIT, CRITICALITY_IGNORE, func(e *aper.Encoder) error {
+			return e.PutEnumerated(int(msg.TimeToWait), TIME_TO_WAIT_COUNT, true)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.addCriticalityDiagnostics(msg.CriticalityDiagnostics); err != nil {
+		return nil, err
+	}
+	return p.encode()
+}
+
+func decodeServiceUpdateFailure(buf []byte) (*DecodedServiceUpdateFailureMessage, error) {
+	p, err := decodeE2apPDU(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(E2AP_UNSUCCESSFUL_OUTCOME, PROCEDURE_RIC_SERVICE_UPDATE); err != nil {
+		return nil, err
+	}
+	msg := &DecodedServiceUpdateFailureMessage{TimeToWait: -1}
+	if msg.Rejected, err = p.ranFunctionCauses(IE_RAN_FUNCTIONS_REJECTED); err != nil {
+		return nil, err
+	}
+	if d := p.find(IE_TIME_TO_WAIT); d != nil {
+		timeToWait, err := d.GetEnumerated(TIME_TO_WAIT_COUNT, true)
+		if err != nil {
+			return nil, err
+		}
+		msg.TimeToWait = int32(timeToWait)
+	}
+	msg.CriticalityDiagnostics, err = p.criticalityDiagnostics()
+	return msg, err
+}
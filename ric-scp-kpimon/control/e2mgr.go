@@ -0,0 +1,448 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const (
+	E2_NODE_DISCOVERY_STATIC = "static" //the E2 nodes of ranList only
+	E2_NODE_DISCOVERY_E2MGR  = "e2mgr"  //the E2 nodes the E2 manager knows, along with the ones of ranList
+
+	DEFAULT_E2MGR_URL = "http://service-ricplt-e2mgr-http.ricplt:3800"
+
+	E2_NODE_CONNECTED    = "CONNECTED"
+	E2_NODE_DISCONNECTED = "DISCONNECTED"
+
+	E2MGR_SDL_NAMESPACE      = "e2Manager"                    //SDL namespace the E2 manager publishes in
+	E2MGR_CONNECTION_CHANNEL = "RAN_CONNECTION_STATUS_CHANGE" //channel of the <ranName>_<connection status> events
+)
+
+type DiscoveryConfig struct {
+	Source        string        //static or e2mgr
+	E2mgrURL      string        //base URL of the E2 manager REST API
+	PollInterval  time.Duration //interval of the E2 node list queries, between the connection notifications
+	Notifications bool          //subscribe to the connection notifications the E2 manager publishes through SDL
+	Allow         []string      //filter rules an E2 node has to match one of, any E2 node when empty
+	Deny          []string      //filter rules no E2 node may match
+}
+
+// LoadDiscoveryConfig reads the E2 node discovery configuration from the xApp environment
+// (see appenv in the xApp descriptor)
+func LoadDiscoveryConfig() (DiscoveryConfig, error) {
+	cfg := DiscoveryConfig{
+		Source:       os.Getenv("e2NodeDiscovery"),
+		E2mgrURL:     os.Getenv("e2mgrUrl"),
+		PollInterval: 30 * time.Second,
+		Allow:        splitList(os.Getenv("e2NodeAllow")),
+		Deny:         splitList(os.Getenv("e2NodeDeny")),
+	}
+	if cfg.Source == "" {
+		cfg.Source = E2_NODE_DISCOVERY_STATIC
+	}
+	if cfg.Source != E2_NODE_DISCOVERY_STATIC && cfg.Source != E2_NODE_DISCOVERY_E2MGR {
+		return cfg, errors.New("Unknown E2 node discovery: " + cfg.Source)
+	}
+	if cfg.E2mgrURL == "" {
+		cfg.E2mgrURL = DEFAULT_E2MGR_URL
+	}
+	if d, err := time.ParseDuration(os.Getenv("e2NodePollInterval")); err == nil && d > 0 {
+		cfg.PollInterval = d
+	}
+	cfg.Notifications, _ = strconv.ParseBool(os.Getenv("e2NodeNotifications"))
+	if _, err := NewE2NodeFilter(cfg.Allow, cfg.Deny); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func splitList(str string) []string {
+	var values []string
+	for _, value := range strings.Split(str, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// GlobalNbID is the global identity of an E2 node as the E2 manager reports it
+type GlobalNbID struct {
+	PlmnID string `json:"plmnId"`
+	NbID   string `json:"nbId"`
+}
+
+// E2Node is an E2 node of the E2 manager
+type E2Node struct {
+	RanName          string      `json:"inventoryName"`
+	GlobalNbID       *GlobalNbID `json:"globalNbId,omitempty"`
+	ConnectionStatus string      `json:"connectionStatus"`
+	NodeType         string      `json:"nodeType,omitempty"` //ENB or GNB, only in the details of an E2 node
+
+	RanFunctions []KpmRanFunction `json:"-"` //RAN functions of the E2 setup, only in the details of an E2 node
+}
+
+// PlmnID returns the PLMN identity of the E2 node, empty when unknown
+func (n *E2Node) PlmnID() string {
+	if n.GlobalNbID == nil {
+		return ""
+	}
+	return n.GlobalNbID.PlmnID
+}
+
+// e2mgrNodebInfo is the part of the details of an E2 node kpimon reads
+type e2mgrNodebInfo struct {
+	RanName          string      `json:"ranName"`
+	GlobalNbID       *GlobalNbID `json:"globalNbId,omitempty"`
+	ConnectionStatus string      `json:"connectionStatus"`
+	NodeType         string      `json:"nodeType"`
+	Gnb              *e2mgrNb    `json:"gnb,omitempty"`
+	Enb              *e2mgrNb    `json:"enb,omitempty"`
+}
+
+type e2mgrNb struct {
+	RanFunctions []KpmRanFunction `json:"ranFunctions,omitempty"`
+}
+
+// E2NodeFilter selects the E2 nodes kpimon subscribes by rules of the form ran:<pattern>, plmn:<pattern>
+// or type:<pattern>, the patterns as in path.Match and case insensitive for the node type
+type E2NodeFilter struct {
+	allow []e2NodeRule
+	deny  []e2NodeRule
+}
+
+type e2NodeRule struct {
+	field   string
+	pattern string
+}
+
+func NewE2NodeFilter(allow []string, deny []string) (*E2NodeFilter, error) {
+	f := &E2NodeFilter{}
+	for _, list := range []struct {
+		rules  []string
+		parsed *[]e2NodeRule
+	}{{allow, &f.allow}, {deny, &f.deny}} {
+		for _, rule := range list.rules {
+			kv := strings.SplitN(rule, ":", 2)
+			if len(kv) != 2 || kv[0] != "ran" && kv[0] != "plmn" && kv[0] != "type" {
+				return nil, errors.New("E2 node filter rule " + rule + " is not ran:, plmn: or type:<pattern>")
+			}
+			if _, err := path.Match(kv[1], ""); err != nil {
+				return nil, errors.New("E2 node filter rule " + rule + ": " + err.Error())
+			}
+			*list.parsed = append(*list.parsed, e2NodeRule{kv[0], kv[1]})
+		}
+	}
+	return f, nil
+}
+
+// Allows tells whether kpimon subscribes an E2 node. Rules on a field the node lacks do not match.
+func (f *E2NodeFilter) Allows(node *E2Node) bool {
+	if f == nil {
+		return true
+	}
+	for _, rule := range f.deny {
+		if rule.matches(node) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, rule := range f.allow {
+		if rule.matches(node) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r e2NodeRule) matches(node *E2Node) bool {
+	var value string
+	pattern := r.pattern
+	switch r.field {
+	case "ran":
+		value = node.RanName
+	case "plmn":
+		value = node.PlmnID()
+	case "type":
+		value, pattern = strings.ToUpper(node.NodeType), strings.ToUpper(pattern)
+	}
+	if value == "" {
+		return false
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// E2ManagerClient queries the REST API of the E2 manager
+type E2ManagerClient struct {
+	url    string
+	client *http.Client
+}
+
+func NewE2ManagerClient(url string) *E2ManagerClient {
+	return &E2ManagerClient{strings.TrimRight(url, "/"), &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Nodes returns the E2 nodes the E2 manager knows, without their node type
+func (e *E2ManagerClient) Nodes() ([]*E2Node, error) {
+	var nodes []*E2Node
+	return nodes, e.get("/v1/nodeb/states", &nodes)
+}
+
+// Node returns the details of an E2 node
+func (e *E2ManagerClient) Node(ranName string) (*E2Node, error) {
+	info := &e2mgrNodebInfo{}
+	if err := e.get("/v1/nodeb/"+url.PathEscape(ranName), info); err != nil {
+		return nil, err
+	}
+	node := &E2Node{RanName: ranName, GlobalNbID: info.GlobalNbID, ConnectionStatus: info.ConnectionStatus, NodeType: info.NodeType}
+	for _, nb := range []*e2mgrNb{info.Gnb, info.Enb} {
+		if nb != nil {
+			node.RanFunctions = append(node.RanFunctions, nb.RanFunctions...)
+		}
+	}
+	return node, nil
+}
+
+func (e *E2ManagerClient) get(path string, v interface{}) error {
+	resp, err := e.client.Get(e.url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("E2 manager answered GET " + path + " with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// E2NodeDiscovery follows the E2 nodes of the E2 manager: it subscribes the E2 nodes the filter allows
+// as they connect and unsubscribes them as they disconnect. Connection notifications are handled as they
+// come, the periodic queries of the node list catch the ones missed.
+type E2NodeDiscovery struct {
+	cfg        DiscoveryConfig
+	e2mgr      *E2ManagerClient
+	filter     *E2NodeFilter
+	connect    func(node *E2Node)
+	disconnect func(ranName string)
+	connected  map[string]bool //E2 nodes connected and allowed, by RAN name
+	mu         *sync.Mutex
+	updating   *sync.Mutex //serializes the updates of the polls and of the notifications
+	stop       chan struct{}
+	once       *sync.Once
+}
+
+func NewE2NodeDiscovery(cfg DiscoveryConfig) (*E2NodeDiscovery, error) {
+	filter, err := NewE2NodeFilter(cfg.Allow, cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &E2NodeDiscovery{
+		cfg:       cfg,
+		e2mgr:     NewE2ManagerClient(cfg.E2mgrURL),
+		filter:    filter,
+		connected: make(map[string]bool),
+		mu:        &sync.Mutex{},
+		updating:  &sync.Mutex{},
+		stop:      make(chan struct{}),
+		once:      &sync.Once{},
+	}, nil
+}
+
+// Start calls connect for the connected E2 nodes the filter allows and disconnect for the ones that
+// disconnect afterwards, until Stop
+func (d *E2NodeDiscovery) Start(connect func(node *E2Node), disconnect func(ranName string)) {
+	d.connect, d.disconnect = connect, disconnect
+	if d.cfg.Notifications {
+		sdl := sdlgo.NewSdlInstance(E2MGR_SDL_NAMESPACE, sdlgo.NewDatabase())
+		if err := sdl.SubscribeChannel(d.notified, E2MGR_CONNECTION_CHANNEL); err != nil {
+			xapp.Logger.Error("Failed to subscribe to E2 node connection notifications, polling only: %v", err)
+			log.Printf("Failed to subscribe to E2 node connection notifications, polling only: %v", err)
+		}
+	}
+	go func() {
+		d.Poll()
+		ticker := time.NewTicker(d.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.Poll()
+			}
+		}
+	}()
+}
+
+func (d *E2NodeDiscovery) Stop() {
+	d.once.Do(func() { close(d.stop) })
+}
+
+// Poll queries the E2 node list and handles the connections and disconnections since the last query
+func (d *E2NodeDiscovery) Poll() {
+	nodes, err := d.e2mgr.Nodes()
+	if err != nil {
+		xapp.Logger.Error("Failed to query the E2 nodes of the E2 manager: %v", err)
+		log.Printf("Failed to query the E2 nodes of the E2 manager: %v", err)
+		return
+	}
+	listed := make(map[string]bool)
+	for _, node := range nodes {
+		listed[node.RanName] = true
+		d.update(node.RanName, node.ConnectionStatus)
+	}
+	for _, ranName := range d.Connected() {
+		if !listed[ranName] {
+			d.update(ranName, E2_NODE_DISCONNECTED)
+		}
+	}
+}
+
+// notified handles the <ranName>_<connection status> events of the E2 manager
+func (d *E2NodeDiscovery) notified(channel string, events ...string) {
+	for _, event := range events {
+		for _, status := range []string{"_" + E2_NODE_CONNECTED, "_" + E2_NODE_DISCONNECTED} {
+			if strings.HasSuffix(event, status) {
+				d.update(strings.TrimSuffix(event, status), status[1:])
+				break
+			}
+		}
+	}
+}
+
+func (d *E2NodeDiscovery) update(ranName string, status string) {
+	d.updating.Lock()
+	defer d.updating.Unlock()
+	d.mu.Lock()
+	known := d.connected[ranName]
+	d.mu.Unlock()
+
+	switch {
+	case status == E2_NODE_CONNECTED && !known:
+		node, err := d.e2mgr.Node(ranName)
+		if err != nil {
+			xapp.Logger.Error("Failed to query E2 node {%s}: %v", ranName, err)
+			log.Printf("Failed to query E2 node {%s}: %v", ranName, err)
+			return
+		}
+		if !d.filter.Allows(node) {
+			log.Printf("E2 node {%s} (PLMN %s, %s) connected, filtered out", ranName, node.PlmnID(), node.NodeType)
+			return
+		}
+		d.mu.Lock()
+		d.connected[ranName] = true
+		d.mu.Unlock()
+		xapp.Logger.Info("E2 node {%s} (PLMN %s, %s) connected", ranName, node.PlmnID(), node.NodeType)
+		log.Printf("E2 node {%s} (PLMN %s, %s) connected", ranName, node.PlmnID(), node.NodeType)
+		d.connect(node)
+	case status != E2_NODE_CONNECTED && known:
+		d.mu.Lock()
+		delete(d.connected, ranName)
+		d.mu.Unlock()
+		xapp.Logger.Info("E2 node {%s} %s", ranName, status)
+		log.Printf("E2 node {%s} %s", ranName, status)
+		d.disconnect(ranName)
+	}
+}
+
+// Connected returns the connected E2 nodes the filter allows, sorted
+func (d *E2NodeDiscovery) Connected() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ranNames := make([]string, 0, len(d.connected))
+	for ranName := range d.connected {
+		ranNames = append(ranNames, ranName)
+	}
+	sort.Strings(ranNames)
+	return ranNames
+}
+
+// E2ManagerStub is a local stand-in for the REST API of the E2 manager serving the node list and node
+// details, its E2 nodes connect and disconnect on Connect and Disconnect
+type E2ManagerStub struct {
+	server *http.Server
+	nodes  map[string]*E2Node
+	mu     *sync.Mutex
+}
+
+func NewE2ManagerStub(addr string) *E2ManagerStub {
+	s := &E2ManagerStub{nodes: make(map[string]*E2Node), mu: &sync.Mutex{}}
+	s.server = &http.Server{Addr: addr, Handler: http.HandlerFunc(s.serve)}
+	return s
+}
+
+// Start listens on the address of the stub and returns the URL to configure as e2mgrUrl
+func (s *E2ManagerStub) Start() (string, error) {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return "", err
+	}
+	go s.server.Serve(listener)
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Connect adds an E2 node, or connects it again
+func (s *E2ManagerStub) Connect(node E2Node) {
+	node.ConnectionStatus = E2_NODE_CONNECTED
+	s.mu.Lock()
+	s.nodes[node.RanName] = &node
+	s.mu.Unlock()
+}
+
+func (s *E2ManagerStub) Disconnect(ranName string) {
+	s.mu.Lock()
+	if node, ok := s.nodes[ranName]; ok {
+		node.ConnectionStatus = E2_NODE_DISCONNECTED
+	}
+	s.mu.Unlock()
+}
+
+func (s *E2ManagerStub) Close() error {
+	return s.server.Close()
+}
+
+func (s *E2ManagerStub) serve(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.URL.Path == "/v1/nodeb/states" {
+		nodes := make([]*E2Node, 0, len(s.nodes))
+		for _, node := range s.nodes {
+			nodes = append(nodes, &E2Node{RanName: node.RanName, GlobalNbID: node.GlobalNbID, ConnectionStatus: node.ConnectionStatus})
+		}
+		writeJSON(rw, nodes)
+		return
+	}
+	ranName, err := url.PathUnescape(strings.TrimPrefix(req.URL.Path, "/v1/nodeb/"))
+	node, ok := s.nodes[ranName]
+	if err != nil || !strings.HasPrefix(req.URL.Path, "/v1/nodeb/") || !ok {
+		http.Error(rw, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(rw, &e2mgrNodebInfo{node.RanName, node.GlobalNbID, node.ConnectionStatus, node.NodeType, &e2mgrNb{node.RanFunctions}, nil})
+}
@@ -0,0 +1,193 @@
+package control
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// discoveryEvents records the calls of an E2NodeDiscovery, "+<ranName>" for a connection and
+// "-<ranName>" for a disconnection
+type discoveryEvents struct {
+	events []string
+	nodes  map[string]*E2Node
+}
+
+// stubDiscovery serves an E2ManagerStub over httptest and builds a discovery on it, polled by the test
+func stubDiscovery(t *testing.T, allow []string, deny []string) (*E2ManagerStub, *E2NodeDiscovery, *discoveryEvents) {
+	t.Helper()
+	stub := NewE2ManagerStub("")
+	server := httptest.NewServer(stub.server.Handler)
+	t.Cleanup(server.Close)
+	d, err := NewE2NodeDiscovery(DiscoveryConfig{Source: E2_NODE_DISCOVERY_E2MGR, E2mgrURL: server.URL, PollInterval: time.Hour, Allow: allow, Deny: deny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := &discoveryEvents{nodes: make(map[string]*E2Node)}
+	d.connect = func(node *E2Node) {
+		events.events = append(events.events, "+"+node.RanName)
+		events.nodes[node.RanName] = node
+	}
+	d.disconnect = func(ranName string) { events.events = append(events.events, "-"+ranName) }
+	return stub, d, events
+}
+
+func (e *discoveryEvents) check(t *testing.T, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(e.events, want) {
+		t.Errorf("discovery events %v, want %v", e.events, want)
+	}
+	e.events = nil
+}
+
+func TestE2ManagerStubConnectDisconnect(t *testing.T) {
+	stub, d, events := stubDiscovery(t, nil, nil)
+	function := KpmRanFunction{ID: 2, OID: "1.3.6.1.4.1.53148.1.2.2.2"}
+	stub.Connect(E2Node{RanName: "gnb_1", GlobalNbID: &GlobalNbID{PlmnID: "00f110", NbID: "0000000000001"}, NodeType: "GNB", RanFunctions: []KpmRanFunction{function}})
+
+	d.Poll()
+	events.check(t, "+gnb_1")
+	node := events.nodes["gnb_1"]
+	if node.PlmnID() != "00f110" || node.NodeType != "GNB" || node.ConnectionStatus != E2_NODE_CONNECTED {
+		t.Errorf("connected E2 node %+v, want the details of the stub", node)
+	}
+	if len(node.RanFunctions) != 1 || node.RanFunctions[0].ID != 2 {
+		t.Errorf("RAN functions %+v, want %+v", node.RanFunctions, function)
+	}
+
+	d.Poll()
+	events.check(t)
+
+	stub.Disconnect("gnb_1")
+	d.Poll()
+	events.check(t, "-gnb_1")
+	if connected := d.Connected(); len(connected) != 0 {
+		t.Errorf("connected %v after the disconnection", connected)
+	}
+	d.Poll()
+	events.check(t)
+
+	stub.Connect(E2Node{RanName: "gnb_1", GlobalNbID: &GlobalNbID{PlmnID: "00f110"}, NodeType: "GNB"})
+	d.Poll()
+	events.check(t, "+gnb_1")
+	if connected := d.Connected(); !reflect.DeepEqual(connected, []string{"gnb_1"}) {
+		t.Errorf("connected %v, want [gnb_1]", connected)
+	}
+}
+
+func TestE2ManagerStubNotifications(t *testing.T) {
+	stub, d, events := stubDiscovery(t, nil, nil)
+	stub.Connect(E2Node{RanName: "gnb_1", NodeType: "GNB"})
+
+	d.notified(E2MGR_CONNECTION_CHANNEL, "gnb_1_"+E2_NODE_CONNECTED)
+	events.check(t, "+gnb_1")
+	d.notified(E2MGR_CONNECTION_CHANNEL, "gnb_1_"+E2_NODE_CONNECTED, "gnb_1_"+E2_NODE_DISCONNECTED, "gnb_1_SHUTTING_DOWN")
+	events.check(t, "-gnb_1")
+
+	//the node the E2 manager does not know cannot be queried
+	d.notified(E2MGR_CONNECTION_CHANNEL, "gnb_2_"+E2_NODE_CONNECTED)
+	events.check(t)
+}
+
+func TestE2ManagerStubFilter(t *testing.T) {
+	stub, d, events := stubDiscovery(t, []string{"plmn:00f1*", "type:gnb"}, []string{"ran:*_lab_*"})
+	for _, node := range []E2Node{
+		{RanName: "gnb_a", GlobalNbID: &GlobalNbID{PlmnID: "00f110"}, NodeType: "GNB"},
+		{RanName: "gnb_lab_1", GlobalNbID: &GlobalNbID{PlmnID: "00f110"}, NodeType: "GNB"},
+		{RanName: "enb_b", GlobalNbID: &GlobalNbID{PlmnID: "13f184"}, NodeType: "ENB"},
+		{RanName: "enb_c", GlobalNbID: &GlobalNbID{PlmnID: "00f120"}, NodeType: "ENB"},
+		{RanName: "gnb_d", GlobalNbID: &GlobalNbID{PlmnID: "13f184"}, NodeType: "gnb"},
+		{RanName: "unknown", NodeType: ""},
+	} {
+		stub.Connect(node)
+	}
+
+	d.Poll()
+	sort.Strings(events.events)
+	events.check(t, "+enb_c", "+gnb_a", "+gnb_d")
+
+	//a filtered out E2 node is queried again on every poll, it is never connected
+	d.Poll()
+	events.check(t)
+	stub.Disconnect("gnb_lab_1")
+	stub.Disconnect("gnb_a")
+	d.Poll()
+	events.check(t, "-gnb_a")
+}
+
+func TestE2NodeFilterRules(t *testing.T) {
+	for _, rules := range [][]string{{"ran"}, {"cell:1"}, {"ran:[a"}} {
+		if _, err := NewE2NodeFilter(rules, nil); err == nil {
+			t.Errorf("allow rules %v accepted", rules)
+		}
+		if _, err := NewE2NodeFilter(nil, rules); err == nil {
+			t.Errorf("deny rules %v accepted", rules)
+		}
+	}
+
+	filter, err := NewE2NodeFilter(nil, []string{"plmn:13f184"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Allows(&E2Node{RanName: "gnb_1"}) {
+		t.Error("deny rule on the PLMN denied an E2 node without PLMN")
+	}
+	if filter.Allows(&E2Node{RanName: "gnb_1", GlobalNbID: &GlobalNbID{PlmnID: "13f184"}}) {
+		t.Error("deny rule on the PLMN allowed its PLMN")
+	}
+	var none *E2NodeFilter
+	if !none.Allows(&E2Node{RanName: "gnb_1"}) {
+		t.Error("nil filter denied an E2 node")
+	}
+}
+
+// TestNodeDisconnectedDoesNotWait checks that a disconnection does not wait for a deletion the subscription
+// manager takes long to answer
+func TestNodeDisconnectedDoesNotWait(t *testing.T) {
+	deleted := make(chan string, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodDelete {
+			deleted <- req.URL.Path
+			<-release
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewControlWith(nil, NewMemoryMetricsStore(), NewChanTransport(1), nil, nil, nil, nil, nil,
+		SubscriptionConfig{Backend: SUBSCRIPTION_BACKEND_REST, SubmgrURL: server.URL + "/ric/v1", CallbackAddr: "127.0.0.1:0"})
+	c.subscriptions.Add("gnb_1")
+	c.subscriptions.Sent("gnb_1", SubscriptionRecord{RestID: "7"})
+	c.subscriptions.Responded("gnb_1")
+
+	start := time.Now()
+	c.nodeDisconnected("gnb_1")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("nodeDisconnected took %v", elapsed)
+	}
+	if ranNames := c.subscriptions.RanNames(); len(ranNames) != 0 {
+		t.Errorf("E2 nodes %v left after the disconnection", ranNames)
+	}
+	select {
+	case path := <-deleted:
+		if path != "/ric/v1/subscriptions/7" {
+			t.Errorf("DELETE %s, want subscription 7", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("subscription of the disconnected E2 node not deleted")
+	}
+
+	//the node connects again before the late answer to the deletion
+	c.subscriptions.Add("gnb_1")
+	c.subscriptions.Sent("gnb_1", SubscriptionRecord{RestID: "8"})
+	c.subscriptions.Responded("gnb_1")
+	c.subscriptions.Deleted("gnb_1")
+	if node := c.subscriptions.Nodes()[0]; node.State != SUBSCRIPTION_ACTIVE {
+		t.Errorf("late deletion moved the new subscription to %s", node.State)
+	}
+}
@@ -0,0 +1,1618 @@
+package control
+
+import (
+	"errors"
+	"strconv"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+// APER codec of the E2SM-KPM v1 (ORAN-WG3.E2SM-KPM-v01.00) types, following the asn1c skeletons
+// of the e2sm library byte for byte
+
+const (
+	RT_PERIOD_COUNT         = 20 //root values of RT-Period-IE
+	MAX_POLICY_TESTS        = 15 //policyTest-List of E2SM-KPM-EventTriggerDefinition-Format1
+	MAX_PM_CONTAINERS       = 512
+	MAX_CELLING_PER_DU      = 512 //maxCellingNBDU
+	MAX_PLMN                = 12  //maxPLMN
+	MAX_SLICES              = 1024
+	MAX_5QI                 = 64
+	MAX_QCI                 = 256
+	MAX_CU_UP_PF_CONTAINERS = 3
+	MAX_GNB_CU_UP_ID        = 68719476735
+	MAX_PDCP_BYTES          = 10000000000
+	MAX_NAME_LENGTH         = 150
+	MAX_OID_LENGTH          = 1000
+	MAX_RIC_STYLES          = 63 //maxofRICstyles
+)
+
+var errUnknownIndicationHeader = errors.New("Unknown RIC Indication Header type")
+var errUnknownIndicationMessage = errors.New("Unknown RIC Indication Message Format")
+
+func encodeEventTriggerDefinition(periods []int64) ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutChoice(0, 1, true)
+	e.PutBool(false)
+	e.PutBool(true)
+	if err := e.PutSequenceOf(len(periods), 1, MAX_POLICY_TESTS, false); err != nil {
+		return nil, err
+	}
+	for _, period := range periods {
+		if period < 0 || period >= RT_PERIOD_COUNT {
+			return nil, errors.New("Invalid RT-Period-IE " + strconv.FormatInt(period, 10))
+		}
+		e.PutBool(false)
+		e.PutEnumerated(int(period), RT_PERIOD_COUNT, true)
+	}
+	return e.Bytes(), nil
+}
+
+func decodeEventTriggerDefinition(buf []byte) ([]int64, error) {
+	d := aper.NewDecoder(buf)
+	index, err := d.GetChoice(1, true)
+	if err != nil {
+		return nil, err
+	}
+	if index != 0 {
+		return nil, errors.New("Unknown RIC Event Trigger Definition Format")
+	}
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	present, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	var periods []int64
+	if present {
+		n, err := d.GetSequenceOf(1, MAX_POLICY_TESTS, false, 7)
+		if err != nil {
+			return nil, err
+		}
+		periods = make([]int64, n)
+		for i := range periods {
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			period, err := d.GetEnumerated(RT_PERIOD_COUNT, true)
+			if err != nil {
+				return nil, err
+			}
+			periods[i] = int64(period)
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return periods, skipExtensions(d, extended)
+}
+
+func encodeActionDefinition(ricStyleType int64) []byte {
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	e.PutUnconstrainedInteger(ricStyleType)
+	return e.Bytes()
+}
+
+func decodeActionDefinition(buf []byte) (int64, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return 0, err
+	}
+	ricStyleType, err := d.GetUnconstrainedInteger()
+	if err != nil {
+		return 0, err
+	}
+	return ricStyleType, skipExtensions(d, extended)
+}
+
+func encodeIndicationHeader(indHdr *IndicationHeader) ([]byte, error) {
+	indHdrFormat1, ok := indHdr.IndHdr.(*IndicationHeaderFormat1)
+	if indHdr.IndHdrType != 1 || !ok {
+		return nil, errUnknownIndicationHeader
+	}
+	e := aper.NewEncoder()
+	e.PutChoice(0, 1, true)
+	if err := indHdrFormat1.encode(e); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func decodeIndicationHeader(buf []byte) (*IndicationHeader, error) {
+	d := aper.NewDecoder(buf)
+	index, err := d.GetChoice(1, true)
+	if err != nil {
+		return nil, err
+	}
+	if index != 0 {
+		return nil, errUnknownIndicationHeader
+	}
+	indHdrFormat1 := &IndicationHeaderFormat1{}
+	if err = indHdrFormat1.decode(d); err != nil {
+		return nil, err
+	}
+	return &IndicationHeader{IndHdrType: 1, IndHdr: indHdrFormat1}, nil
+}
+
+func encodeIndicationMessage(indMsg *IndicationMessage) ([]byte, error) {
+	indMsgFormat1, ok := indMsg.IndMsg.(*IndicationMessageFormat1)
+	if indMsg.IndMsgType != 1 || !ok {
+		return nil, errUnknownIndicationMessage
+	}
+	e := aper.NewEncoder()
+	e.PutUnconstrainedInteger(indMsg.StyleType)
+	e.PutChoice(0, 1, true)
+	if err := indMsgFormat1.encode(e); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func decodeIndicationMessage(buf []byte) (*IndicationMessage, error) {
+	d := aper.NewDecoder(buf)
+	styleType, err := d.GetUnconstrainedInteger()
+	if err != nil {
+		return nil, err
+	}
+	index, err := d.GetChoice(1, true)
+	if err != nil {
+		return nil, err
+	}
+	if index != 0 {
+		return nil, errUnknownIndicationMessage
+	}
+	indMsgFormat1 := &IndicationMessageFormat1{}
+	if err = indMsgFormat1.decode(d); err != nil {
+		return nil, err
+	}
+	return &IndicationMessage{StyleType: styleType, IndMsgType: 1, IndMsg: indMsgFormat1}, nil
+}
+
+// encodeRanFunctionDescription encodes the E2SM-KPM-RANfunction-Description of a v1 E2 node, as its E2 setup
+// would carry it
+func encodeRanFunctionDescription(desc *RanFunctionDescription) ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	if err := desc.Name.encode(e); err != nil {
+		return nil, err
+	}
+	e.PutBool(false)
+	e.PutBool(len(desc.EventTriggerStyles) > 0)
+	e.PutBool(len(desc.ReportStyles) > 0)
+	if len(desc.EventTriggerStyles) > 0 {
+		if err := putCount(e, "RIC-EventTriggerStyle-List", len(desc.EventTriggerStyles), MAX_RIC_STYLES); err != nil {
+			return nil, err
+		}
+		for _, style := range desc.EventTriggerStyles {
+			e.PutBool(false)
+			e.PutUnconstrainedInteger(style.StyleType)
+			if err := e.PutPrintableString(style.StyleName, 1, MAX_NAME_LENGTH, true); err != nil {
+				return nil, err
+			}
+			e.PutUnconstrainedInteger(style.FormatType)
+		}
+	}
+	if len(desc.ReportStyles) > 0 {
+		if err := putCount(e, "RIC-ReportStyle-List", len(desc.ReportStyles), MAX_RIC_STYLES); err != nil {
+			return nil, err
+		}
+		for _, style := range desc.ReportStyles {
+			e.PutBool(false)
+			e.PutUnconstrainedInteger(style.StyleType)
+			if err := e.PutPrintableString(style.StyleName, 1, MAX_NAME_LENGTH, true); err != nil {
+				return nil, err
+			}
+			e.PutUnconstrainedInteger(style.IndicationHeaderFormat)
+			e.PutUnconstrainedInteger(style.IndicationMessageFormat)
+		}
+	}
+	return e.Bytes(), nil
+}
+
+func decodeRanFunctionDescription(buf []byte) (*RanFunctionDescription, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	desc := &RanFunctionDescription{}
+	if err = desc.Name.decode(d); err != nil {
+		return nil, err
+	}
+	itemExtended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return nil, err
+	}
+	if present[0] {
+		n, err := getCount(d, "RIC-EventTriggerStyle-List", MAX_RIC_STYLES, 27)
+		if err != nil {
+			return nil, err
+		}
+		desc.EventTriggerStyles = make([]EventTriggerStyleType, n)
+		for i := range desc.EventTriggerStyles {
+			style := &desc.EventTriggerStyles[i]
+			styleExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			if style.StyleType, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if style.StyleName, err = d.GetPrintableString(1, MAX_NAME_LENGTH, true); err != nil {
+				return nil, err
+			}
+			if style.FormatType, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if err = skipExtensions(d, styleExtended); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if present[1] {
+		n, err := getCount(d, "RIC-ReportStyle-List", MAX_RIC_STYLES, 43)
+		if err != nil {
+			return nil, err
+		}
+		desc.ReportStyles = make([]ReportStyleType, n)
+		for i := range desc.ReportStyles {
+			style := &desc.ReportStyles[i]
+			styleExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			if style.StyleType, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if style.StyleName, err = d.GetPrintableString(1, MAX_NAME_LENGTH, true); err != nil {
+				return nil, err
+			}
+			if style.IndicationHeaderFormat, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if style.IndicationMessageFormat, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if err = skipExtensions(d, styleExtended); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = skipExtensions(d, itemExtended); err != nil {
+		return nil, err
+	}
+	return desc, skipExtensions(d, extended)
+}
+
+func (n *RanFunctionNameType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(n.Instance >= 0)
+	if err := e.PutPrintableString(n.ShortName, 1, MAX_NAME_LENGTH, true); err != nil {
+		return err
+	}
+	if err := e.PutPrintableString(n.E2SMOID, 1, MAX_OID_LENGTH, true); err != nil {
+		return err
+	}
+	if err := e.PutPrintableString(n.Description, 1, MAX_NAME_LENGTH, true); err != nil {
+		return err
+	}
+	if n.Instance >= 0 {
+		e.PutUnconstrainedInteger(n.Instance)
+	}
+	return nil
+}
+
+func (n *RanFunctionNameType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	hasInstance, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if n.ShortName, err = d.GetPrintableString(1, MAX_NAME_LENGTH, true); err != nil {
+		return err
+	}
+	if n.E2SMOID, err = d.GetPrintableString(1, MAX_OID_LENGTH, true); err != nil {
+		return err
+	}
+	if n.Description, err = d.GetPrintableString(1, MAX_NAME_LENGTH, true); err != nil {
+		return err
+	}
+	n.Instance = -1
+	if hasInstance {
+		if n.Instance, err = d.GetUnconstrainedInteger(); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (h *IndicationHeaderFormat1) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(h.GlobalKPMnodeIDType != 0)
+	e.PutBool(h.NRCGI != nil)
+	e.PutBool(h.PlmnID != nil)
+	e.PutBool(h.SliceID != nil)
+	e.PutBool(h.FiveQI != -1)
+	e.PutBool(h.Qci != -1)
+	if h.GlobalKPMnodeIDType != 0 {
+		if err := h.encodeGlobalKPMnodeID(e); err != nil {
+			return err
+		}
+	}
+	if h.NRCGI != nil {
+		if err := h.NRCGI.encode(e); err != nil {
+			return err
+		}
+	}
+	if h.PlmnID != nil {
+		if err := putPlmnID(e, *h.PlmnID); err != nil {
+			return err
+		}
+	}
+	if h.SliceID != nil {
+		if err := h.SliceID.encode(e); err != nil {
+			return err
+		}
+	}
+	if h.FiveQI != -1 {
+		if err := e.PutInteger(h.FiveQI, 0, 255, false); err != nil {
+			return err
+		}
+	}
+	if h.Qci != -1 {
+		if err := e.PutInteger(h.Qci, 0, 255, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *IndicationHeaderFormat1) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 6)
+	if err != nil {
+		return err
+	}
+	if present[0] {
+		if err = h.decodeGlobalKPMnodeID(d); err != nil {
+			return err
+		}
+	}
+	if present[1] {
+		h.NRCGI = &NRCGIType{}
+		if err = h.NRCGI.decode(d); err != nil {
+			return err
+		}
+	}
+	if present[2] {
+		plmnID, err := getPlmnID(d)
+		if err != nil {
+			return err
+		}
+		h.PlmnID = &plmnID
+	}
+	if present[3] {
+		h.SliceID = &SliceIDType{}
+		if err = h.SliceID.decode(d); err != nil {
+			return err
+		}
+	}
+	h.FiveQI, h.Qci = -1, -1
+	if present[4] {
+		if h.FiveQI, err = d.GetInteger(0, 255, false); err != nil {
+			return err
+		}
+	}
+	if present[5] {
+		if h.Qci, err = d.GetInteger(0, 255, false); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (h *IndicationHeaderFormat1) encodeGlobalKPMnodeID(e *aper.Encoder) error {
+	switch h.GlobalKPMnodeIDType {
+	case 1:
+		globalgNBID, ok := h.GlobalKPMnodeID.(*GlobalKPMnodegNBIDType)
+		if !ok {
+			break
+		}
+		e.PutChoice(0, 4, true)
+		e.PutBool(false)
+		e.PutBool(globalgNBID.GnbCUUPID != nil)
+		e.PutBool(globalgNBID.GnbDUID != nil)
+		e.PutBool(false)
+		if err := putPlmnID(e, globalgNBID.GlobalgNBID.PlmnID); err != nil {
+			return err
+		}
+		gNBID, ok := globalgNBID.GlobalgNBID.GnbID.(*GNBID)
+		if globalgNBID.GlobalgNBID.GnbIDType != 1 || !ok {
+			return errors.New("Unknown gNB ID type")
+		}
+		e.PutChoice(0, 1, true)
+		if err := putBitString(e, BitString(*gNBID), 22, 32); err != nil {
+			return err
+		}
+		if globalgNBID.GnbCUUPID != nil {
+			if err := putInteger(e, globalgNBID.GnbCUUPID, 0, MAX_GNB_CU_UP_ID, false); err != nil {
+				return err
+			}
+		}
+		if globalgNBID.GnbDUID != nil {
+			if err := putInteger(e, globalgNBID.GnbDUID, 0, MAX_GNB_CU_UP_ID, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 2:
+		globalengNBID, ok := h.GlobalKPMnodeID.(*GlobalKPMnodeengNBIDType)
+		if !ok {
+			break
+		}
+		e.PutChoice(1, 4, true)
+		e.PutBool(false)
+		e.PutBool(false)
+		if err := putPlmnID(e, globalengNBID.PlmnID); err != nil {
+			return err
+		}
+		engNBID, ok := globalengNBID.GnbID.(*ENGNBID)
+		if globalengNBID.GnbIDType != 1 || !ok {
+			return errors.New("Unknown en-gNB ID type")
+		}
+		e.PutChoice(0, 1, true)
+		return putBitString(e, BitString(*engNBID), 22, 32)
+	case 3:
+		globalngeNBID, ok := h.GlobalKPMnodeID.(*GlobalKPMnodengeNBIDType)
+		if !ok {
+			break
+		}
+		e.PutChoice(2, 4, true)
+		e.PutBool(false)
+		e.PutBool(false)
+		if err := putPlmnID(e, globalngeNBID.PlmnID); err != nil {
+			return err
+		}
+		var ngeNBID BitString
+		var index int
+		var size int64
+		switch v := globalngeNBID.EnbID.(type) {
+		case *NGENBID_Macro:
+			ngeNBID, index, size = BitString(*v), 0, 20
+		case *NGENBID_ShortMacro:
+			ngeNBID, index, size = BitString(*v), 1, 18
+		case *NGENBID_LongMacro:
+			ngeNBID, index, size = BitString(*v), 2, 21
+		default:
+			return errors.New("Unknown ng-eNB ID type")
+		}
+		e.PutChoice(index, 3, true)
+		return putBitString(e, ngeNBID, size, size)
+	case 4:
+		globaleNBID, ok := h.GlobalKPMnodeID.(*GlobalKPMnodeeNBIDType)
+		if !ok {
+			break
+		}
+		e.PutChoice(3, 4, true)
+		e.PutBool(false)
+		e.PutBool(false)
+		if err := putPlmnID(e, globaleNBID.PlmnID); err != nil {
+			return err
+		}
+		var eNBID BitString
+		var index int
+		var size int64
+		switch v := globaleNBID.EnbID.(type) {
+		case *ENBID_Macro:
+			eNBID, index, size = BitString(*v), 0, 20
+		case *ENBID_Home:
+			eNBID, index, size = BitString(*v), 1, 28
+		case *ENBID_ShortMacro:
+			eNBID, index, size = BitString(*v), 2, 18
+		case *ENBID_LongMacro:
+			eNBID, index, size = BitString(*v), 3, 21
+		default:
+			return errors.New("Unknown eNB ID type")
+		}
+		//short and long macro eNB IDs are extension alternatives of ENB-ID
+		e.PutChoice(index, 2, true)
+		if index > 1 {
+			return e.PutOpenType(func(e *aper.Encoder) error {
+				return putBitString(e, eNBID, size, size)
+			})
+		}
+		return putBitString(e, eNBID, size, size)
+	}
+	return errors.New("Unknown GlobalKPMnodeID type " + strconv.Itoa(int(h.GlobalKPMnodeIDType)))
+}
+
+func (h *IndicationHeaderFormat1) decodeGlobalKPMnodeID(d *aper.Decoder) error {
+	nodeType, err := d.GetChoice(4, true)
+	if err != nil {
+		return err
+	}
+	switch nodeType {
+	case 0:
+		globalgNBID := &GlobalKPMnodegNBIDType{}
+		extended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		present, err := getOptionals(d, 2)
+		if err != nil {
+			return err
+		}
+		globalExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if globalgNBID.GlobalgNBID.PlmnID, err = getPlmnID(d); err != nil {
+			return err
+		}
+		index, err := d.GetChoice(1, true)
+		if err != nil {
+			return err
+		}
+		if index != 0 {
+			return errors.New("Unknown gNB ID type")
+		}
+		gNBID, err := getBitString(d, 22, 32)
+		if err != nil {
+			return err
+		}
+		globalgNBID.GlobalgNBID.GnbIDType = 1
+		globalgNBID.GlobalgNBID.GnbID = (*GNBID)(gNBID)
+		if err = skipExtensions(d, globalExtended); err != nil {
+			return err
+		}
+		if present[0] {
+			if globalgNBID.GnbCUUPID, err = getInteger(d, 0, MAX_GNB_CU_UP_ID, false); err != nil {
+				return err
+			}
+		}
+		if present[1] {
+			if globalgNBID.GnbDUID, err = getInteger(d, 0, MAX_GNB_CU_UP_ID, false); err != nil {
+				return err
+			}
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return err
+		}
+		h.GlobalKPMnodeID = globalgNBID
+	case 1:
+		globalengNBID := &GlobalKPMnodeengNBIDType{}
+		extended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		globalExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if globalengNBID.PlmnID, err = getPlmnID(d); err != nil {
+			return err
+		}
+		index, err := d.GetChoice(1, true)
+		if err != nil {
+			return err
+		}
+		if index != 0 {
+			return errors.New("Unknown en-gNB ID type")
+		}
+		engNBID, err := getBitString(d, 22, 32)
+		if err != nil {
+			return err
+		}
+		globalengNBID.GnbIDType = 1
+		globalengNBID.GnbID = (*ENGNBID)(engNBID)
+		if err = skipExtensions(d, globalExtended); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return err
+		}
+		h.GlobalKPMnodeID = globalengNBID
+	case 2:
+		globalngeNBID := &GlobalKPMnodengeNBIDType{}
+		extended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		globalExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if globalngeNBID.PlmnID, err = getPlmnID(d); err != nil {
+			return err
+		}
+		index, err := d.GetChoice(3, true)
+		if err != nil {
+			return err
+		}
+		switch index {
+		case 0:
+			ngeNBID, err := getBitString(d, 20, 20)
+			if err != nil {
+				return err
+			}
+			globalngeNBID.EnbID = (*NGENBID_Macro)(ngeNBID)
+		case 1:
+			ngeNBID, err := getBitString(d, 18, 18)
+			if err != nil {
+				return err
+			}
+			globalngeNBID.EnbID = (*NGENBID_ShortMacro)(ngeNBID)
+		case 2:
+			ngeNBID, err := getBitString(d, 21, 21)
+			if err != nil {
+				return err
+			}
+			globalngeNBID.EnbID = (*NGENBID_LongMacro)(ngeNBID)
+		default:
+			return errors.New("Unknown ng-eNB ID type")
+		}
+		globalngeNBID.EnbIDType = index + 1
+		if err = skipExtensions(d, globalExtended); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return err
+		}
+		h.GlobalKPMnodeID = globalngeNBID
+	case 3:
+		globaleNBID := &GlobalKPMnodeeNBIDType{}
+		extended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		globalExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if globaleNBID.PlmnID, err = getPlmnID(d); err != nil {
+			return err
+		}
+		index, err := d.GetChoice(2, true)
+		if err != nil {
+			return err
+		}
+		if index > 3 {
+			return errors.New("Unknown eNB ID type")
+		}
+		//short and long macro eNB IDs are extension alternatives of ENB-ID
+		inner := d
+		if index > 1 {
+			if inner, err = d.GetOpenType(); err != nil {
+				return err
+			}
+		}
+		size := []int64{20, 28, 18, 21}[index]
+		eNBID, err := getBitString(inner, size, size)
+		if err != nil {
+			return err
+		}
+		switch index {
+		case 0:
+			globaleNBID.EnbID = (*ENBID_Macro)(eNBID)
+		case 1:
+			globaleNBID.EnbID = (*ENBID_Home)(eNBID)
+		case 2:
+			globaleNBID.EnbID = (*ENBID_ShortMacro)(eNBID)
+		case 3:
+			globaleNBID.EnbID = (*ENBID_LongMacro)(eNBID)
+		}
+		globaleNBID.EnbIDType = index + 1
+		if err = skipExtensions(d, globalExtended); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return err
+		}
+		h.GlobalKPMnodeID = globaleNBID
+	default:
+		return errors.New("Unknown GlobalKPMnodeID type")
+	}
+	h.GlobalKPMnodeIDType = int32(nodeType + 1)
+	return nil
+}
+
+func (m *IndicationMessageFormat1) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "PM-Containers", len(m.PMContainers), MAX_PM_CONTAINERS); err != nil {
+		return err
+	}
+	for i := range m.PMContainers {
+		if err := m.PMContainers[i].encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *IndicationMessageFormat1) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "PM-Containers", MAX_PM_CONTAINERS, 3)
+	if err != nil {
+		return err
+	}
+	m.PMContainers = make([]PMContainerType, n)
+	for i := range m.PMContainers {
+		if err = m.PMContainers[i].decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+// The RAN container of an indication is not carried: the decoder skips it and the encoder leaves it out
+func (p *PMContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(p.PFContainer != nil)
+	e.PutBool(false)
+	if p.PFContainer == nil {
+		return nil
+	}
+	switch p.PFContainer.ContainerType {
+	case 1:
+		if oDU, ok := p.PFContainer.Container.(*ODUPFContainerType); ok {
+			e.PutChoice(0, 3, false)
+			return oDU.encode(e)
+		}
+	case 2:
+		if oCUCP, ok := p.PFContainer.Container.(*OCUCPPFContainerType); ok {
+			e.PutChoice(1, 3, false)
+			return oCUCP.encode(e)
+		}
+	case 3:
+		if oCUUP, ok := p.PFContainer.Container.(*OCUUPPFContainerType); ok {
+			e.PutChoice(2, 3, false)
+			return oCUUP.encode(e)
+		}
+	}
+	return errors.New("Unknown PF Container type")
+}
+
+func (p *PMContainerType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	if present[0] {
+		index, err := d.GetChoice(3, false)
+		if err != nil {
+			return err
+		}
+		pfContainer := &PFContainerType{ContainerType: int32(index + 1)}
+		switch index {
+		case 0:
+			oDU := &ODUPFContainerType{}
+			err = oDU.decode(d)
+			pfContainer.Container = oDU
+		case 1:
+			oCUCP := &OCUCPPFContainerType{}
+			err = oCUCP.decode(d)
+			pfContainer.Container = oCUCP
+		case 2:
+			oCUUP := &OCUUPPFContainerType{}
+			err = oCUUP.decode(d)
+			pfContainer.Container = oCUUP
+		}
+		if err != nil {
+			return err
+		}
+		p.PFContainer = pfContainer
+	}
+	if present[1] {
+		if _, err = d.GetOctetString(0, aper.UNBOUNDED, false); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (c *ODUPFContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "CellResourceReportList", len(c.CellResourceReports), MAX_CELLING_PER_DU); err != nil {
+		return err
+	}
+	for i := range c.CellResourceReports {
+		if err := c.CellResourceReports[i].encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ODUPFContainerType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "CellResourceReportList", MAX_CELLING_PER_DU, 68)
+	if err != nil {
+		return err
+	}
+	c.CellResourceReports = make([]CellResourceReportType, n)
+	for i := range c.CellResourceReports {
+		if err = c.CellResourceReports[i].decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (r *CellResourceReportType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(r.TotalofAvailablePRBs.DL != -1)
+	e.PutBool(r.TotalofAvailablePRBs.UL != -1)
+	if err := r.NRCGI.encode(e); err != nil {
+		return err
+	}
+	if err := putPrbs(e, r.TotalofAvailablePRBs, 273); err != nil {
+		return err
+	}
+	if err := putCount(e, "ServedPlmnPerCellList", len(r.ServedPlmnPerCells), MAX_PLMN); err != nil {
+		return err
+	}
+	for i := range r.ServedPlmnPerCells {
+		if err := r.ServedPlmnPerCells[i].encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CellResourceReportType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	if err = r.NRCGI.decode(d); err != nil {
+		return err
+	}
+	if r.TotalofAvailablePRBs, err = getPrbs(d, present, 273); err != nil {
+		return err
+	}
+	n, err := getCount(d, "ServedPlmnPerCellList", MAX_PLMN, 27)
+	if err != nil {
+		return err
+	}
+	r.ServedPlmnPerCells = make([]ServedPlmnPerCellType, n)
+	for i := range r.ServedPlmnPerCells {
+		if err = r.ServedPlmnPerCells[i].decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (s *ServedPlmnPerCellType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(s.DUPM5GC != nil)
+	e.PutBool(s.DUPMEPC != nil)
+	if err := putPlmnID(e, s.PlmnID); err != nil {
+		return err
+	}
+	if s.DUPM5GC != nil {
+		if err := s.DUPM5GC.encode(e); err != nil {
+			return err
+		}
+	}
+	if s.DUPMEPC != nil {
+		if err := s.DUPMEPC.encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ServedPlmnPerCellType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	if s.PlmnID, err = getPlmnID(d); err != nil {
+		return err
+	}
+	if present[0] {
+		s.DUPM5GC = &DUPM5GCContainerType{}
+		if err = s.DUPM5GC.decode(d); err != nil {
+			return err
+		}
+	}
+	if present[1] {
+		s.DUPMEPC = &DUPMEPCContainerType{}
+		if err = s.DUPMEPC.decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (c *DUPM5GCContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "SlicePerPlmnPerCellList", len(c.SlicePerPlmnPerCells), MAX_SLICES); err != nil {
+		return err
+	}
+	for i := range c.SlicePerPlmnPerCells {
+		slice := &c.SlicePerPlmnPerCells[i]
+		e.PutBool(false)
+		if err := slice.SliceID.encode(e); err != nil {
+			return err
+		}
+		if err := putCount(e, "FQIPERSlicesPerPlmnPerCellList", len(slice.FQIPERSlicesPerPlmnPerCells), MAX_5QI); err != nil {
+			return err
+		}
+		for j := range slice.FQIPERSlicesPerPlmnPerCells {
+			fiveQI := &slice.FQIPERSlicesPerPlmnPerCells[j]
+			e.PutBool(false)
+			e.PutBool(fiveQI.PrbUsage.DL != -1)
+			e.PutBool(fiveQI.PrbUsage.UL != -1)
+			if err := e.PutInteger(fiveQI.FiveQI, 0, 255, false); err != nil {
+				return err
+			}
+			if err := putPrbs(e, fiveQI.PrbUsage, 273); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DUPM5GCContainerType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "SlicePerPlmnPerCellList", MAX_SLICES, 20)
+	if err != nil {
+		return err
+	}
+	c.SlicePerPlmnPerCells = make([]SlicePerPlmnPerCellType, n)
+	for i := range c.SlicePerPlmnPerCells {
+		slice := &c.SlicePerPlmnPerCells[i]
+		sliceExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if err = slice.SliceID.decode(d); err != nil {
+			return err
+		}
+		n, err := getCount(d, "FQIPERSlicesPerPlmnPerCellList", MAX_5QI, 11)
+		if err != nil {
+			return err
+		}
+		slice.FQIPERSlicesPerPlmnPerCells = make([]FQIPERSlicesPerPlmnPerCellType, n)
+		for j := range slice.FQIPERSlicesPerPlmnPerCells {
+			fiveQI := &slice.FQIPERSlicesPerPlmnPerCells[j]
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			present, err := getOptionals(d, 2)
+			if err != nil {
+				return err
+			}
+			if fiveQI.FiveQI, err = d.GetInteger(0, 255, false); err != nil {
+				return err
+			}
+			if fiveQI.PrbUsage, err = getPrbs(d, present, 273); err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+		if err = skipExtensions(d, sliceExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (c *DUPMEPCContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "PerQCIReportList", len(c.PerQCIReports), MAX_QCI); err != nil {
+		return err
+	}
+	for i := range c.PerQCIReports {
+		report := &c.PerQCIReports[i]
+		e.PutBool(false)
+		e.PutBool(report.PrbUsage.DL != -1)
+		e.PutBool(report.PrbUsage.UL != -1)
+		if err := e.PutInteger(report.QCI, 0, 255, false); err != nil {
+			return err
+		}
+		if err := putPrbs(e, report.PrbUsage, 100); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *DUPMEPCContainerType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "PerQCIReportList", MAX_QCI, 11)
+	if err != nil {
+		return err
+	}
+	c.PerQCIReports = make([]DUPMEPCPerQCIReportType, n)
+	for i := range c.PerQCIReports {
+		report := &c.PerQCIReports[i]
+		itemExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		present, err := getOptionals(d, 2)
+		if err != nil {
+			return err
+		}
+		if report.QCI, err = d.GetInteger(0, 255, false); err != nil {
+			return err
+		}
+		if report.PrbUsage, err = getPrbs(d, present, 100); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, itemExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (c *OCUCPPFContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(c.GNBCUCPName != nil)
+	if c.GNBCUCPName != nil {
+		if err := putName(e, c.GNBCUCPName); err != nil {
+			return err
+		}
+	}
+	e.PutBool(c.CUCPResourceStatus.NumberOfActiveUEs != 0)
+	if c.CUCPResourceStatus.NumberOfActiveUEs != 0 {
+		return e.PutInteger(c.CUCPResourceStatus.NumberOfActiveUEs, 1, 65536, true)
+	}
+	return nil
+}
+
+func (c *OCUCPPFContainerType) decode(d *aper.Decoder) error {
+	present, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if present {
+		if c.GNBCUCPName, err = getName(d); err != nil {
+			return err
+		}
+	}
+	if present, err = d.GetBool(); err != nil {
+		return err
+	}
+	if present {
+		if c.CUCPResourceStatus.NumberOfActiveUEs, err = d.GetInteger(1, 65536, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *OCUUPPFContainerType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(c.GNBCUUPName != nil)
+	if c.GNBCUUPName != nil {
+		if err := putName(e, c.GNBCUUPName); err != nil {
+			return err
+		}
+	}
+	if err := putCount(e, "PF-ContainerList", len(c.CUUPPFContainerItems), MAX_CU_UP_PF_CONTAINERS); err != nil {
+		return err
+	}
+	for i := range c.CUUPPFContainerItems {
+		item := &c.CUUPPFContainerItems[i]
+		e.PutBool(false)
+		if err := e.PutEnumerated(int(item.InterfaceType), 3, true); err != nil {
+			return err
+		}
+		e.PutBool(false)
+		measurement := &item.OCUUPPMContainer
+		if err := putCount(e, "PlmnList", len(measurement.CUUPPlmns), MAX_PLMN); err != nil {
+			return err
+		}
+		for j := range measurement.CUUPPlmns {
+			if err := measurement.CUUPPlmns[j].encode(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *OCUUPPFContainerType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if present {
+		if c.GNBCUUPName, err = getName(d); err != nil {
+			return err
+		}
+	}
+	n, err := getCount(d, "PF-ContainerList", MAX_CU_UP_PF_CONTAINERS, 32)
+	if err != nil {
+		return err
+	}
+	c.CUUPPFContainerItems = make([]CUUPPFContainerItemType, n)
+	for i := range c.CUUPPFContainerItems {
+		item := &c.CUUPPFContainerItems[i]
+		itemExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		interfaceType, err := d.GetEnumerated(3, true)
+		if err != nil {
+			return err
+		}
+		item.InterfaceType = int64(interfaceType)
+		measurementExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		measurement := &item.OCUUPPMContainer
+		n, err := getCount(d, "PlmnList", MAX_PLMN, 27)
+		if err != nil {
+			return err
+		}
+		measurement.CUUPPlmns = make([]CUUPPlmnType, n)
+		for j := range measurement.CUUPPlmns {
+			if err = measurement.CUUPPlmns[j].decode(d); err != nil {
+				return err
+			}
+		}
+		if err = skipExtensions(d, measurementExtended); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, itemExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (p *CUUPPlmnType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(p.CUUPPM5GC != nil)
+	e.PutBool(p.CUUPPMEPC != nil)
+	if err := putPlmnID(e, p.PlmnID); err != nil {
+		return err
+	}
+	if p.CUUPPM5GC != nil {
+		if err := p.CUUPPM5GC.encode(e); err != nil {
+			return err
+		}
+	}
+	if p.CUUPPMEPC != nil {
+		if err := p.CUUPPMEPC.encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *CUUPPlmnType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	if p.PlmnID, err = getPlmnID(d); err != nil {
+		return err
+	}
+	if present[0] {
+		p.CUUPPM5GC = &CUUPPM5GCType{}
+		if err = p.CUUPPM5GC.decode(d); err != nil {
+			return err
+		}
+	}
+	if present[1] {
+		p.CUUPPMEPC = &CUUPPMEPCType{}
+		if err = p.CUUPPMEPC.decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (f *CUUPPM5GCType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "SliceToReportList", len(f.SliceToReports), MAX_SLICES); err != nil {
+		return err
+	}
+	for i := range f.SliceToReports {
+		slice := &f.SliceToReports[i]
+		e.PutBool(false)
+		if err := slice.SliceID.encode(e); err != nil {
+			return err
+		}
+		if err := putCount(e, "FQIPERSlicesPerPlmnList", len(slice.FQIPERSlicesPerPlmns), MAX_5QI); err != nil {
+			return err
+		}
+		for j := range slice.FQIPERSlicesPerPlmns {
+			fiveQI := &slice.FQIPERSlicesPerPlmns[j]
+			e.PutBool(false)
+			e.PutBool(fiveQI.PDCPBytesDL != nil)
+			e.PutBool(fiveQI.PDCPBytesUL != nil)
+			if err := e.PutInteger(fiveQI.FiveQI, 0, 255, false); err != nil {
+				return err
+			}
+			if err := putPDCPBytes(e, fiveQI.PDCPBytesDL, fiveQI.PDCPBytesUL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *CUUPPM5GCType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "SliceToReportList", MAX_SLICES, 20)
+	if err != nil {
+		return err
+	}
+	f.SliceToReports = make([]SliceToReportType, n)
+	for i := range f.SliceToReports {
+		slice := &f.SliceToReports[i]
+		sliceExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if err = slice.SliceID.decode(d); err != nil {
+			return err
+		}
+		n, err := getCount(d, "FQIPERSlicesPerPlmnList", MAX_5QI, 11)
+		if err != nil {
+			return err
+		}
+		slice.FQIPERSlicesPerPlmns = make([]FQIPERSlicesPerPlmnType, n)
+		for j := range slice.FQIPERSlicesPerPlmns {
+			fiveQI := &slice.FQIPERSlicesPerPlmns[j]
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			present, err := getOptionals(d, 2)
+			if err != nil {
+				return err
+			}
+			if fiveQI.FiveQI, err = d.GetInteger(0, 255, false); err != nil {
+				return err
+			}
+			if fiveQI.PDCPBytesDL, fiveQI.PDCPBytesUL, err = getPDCPBytes(d, present); err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+		if err = skipExtensions(d, sliceExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (f *CUUPPMEPCType) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := putCount(e, "PerQCIReportList", len(f.CUUPPMEPCPerQCIReports), MAX_QCI); err != nil {
+		return err
+	}
+	for i := range f.CUUPPMEPCPerQCIReports {
+		report := &f.CUUPPMEPCPerQCIReports[i]
+		e.PutBool(false)
+		e.PutBool(report.PDCPBytesDL != nil)
+		e.PutBool(report.PDCPBytesUL != nil)
+		if err := e.PutInteger(report.QCI, 0, 255, false); err != nil {
+			return err
+		}
+		if err := putPDCPBytes(e, report.PDCPBytesDL, report.PDCPBytesUL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *CUUPPMEPCType) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	n, err := getCount(d, "PerQCIReportList", MAX_QCI, 11)
+	if err != nil {
+		return err
+	}
+	f.CUUPPMEPCPerQCIReports = make([]CUUPPMEPCPerQCIReportType, n)
+	for i := range f.CUUPPMEPCPerQCIReports {
+		report := &f.CUUPPMEPCPerQCIReports[i]
+		itemExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		present, err := getOptionals(d, 2)
+		if err != nil {
+			return err
+		}
+		if report.QCI, err = d.GetInteger(0, 255, false); err != nil {
+			return err
+		}
+		if report.PDCPBytesDL, report.PDCPBytesUL, err = getPDCPBytes(d, present); err != nil {
+			return err
+		}
+		if err = skipExtensions(d, itemExtended); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (n *NRCGIType) encode(e *aper.Encoder) error {
+	if err := putPlmnID(e, n.PlmnID); err != nil {
+		return err
+	}
+	return putBitString(e, n.NRCellID, 36, 36)
+}
+
+func (n *NRCGIType) decode(d *aper.Decoder) error {
+	var err error
+	if n.PlmnID, err = getPlmnID(d); err != nil {
+		return err
+	}
+	nRCellID, err := getBitString(d, 36, 36)
+	if err != nil {
+		return err
+	}
+	n.NRCellID = *nRCellID
+	return nil
+}
+
+func (s *SliceIDType) encode(e *aper.Encoder) error {
+	if s.SST.Size > len(s.SST.Buf) || (s.SD != nil && s.SD.Size > len(s.SD.Buf)) {
+		return aper.ErrLength
+	}
+	e.PutBool(s.SD != nil)
+	if err := e.PutOctetString(s.SST.Buf[:s.SST.Size], 1, 1, false); err != nil {
+		return err
+	}
+	if s.SD != nil {
+		return e.PutOctetString(s.SD.Buf[:s.SD.Size], 3, 3, false)
+	}
+	return nil
+}
+
+func (s *SliceIDType) decode(d *aper.Decoder) error {
+	present, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	sST, err := d.GetOctetString(1, 1, false)
+	if err != nil {
+		return err
+	}
+	s.SST = OctetString{Buf: sST, Size: len(sST)}
+	if present {
+		sD, err := d.GetOctetString(3, 3, false)
+		if err != nil {
+			return err
+		}
+		s.SD = &OctetString{Buf: sD, Size: len(sD)}
+	}
+	return nil
+}
+
+func putPlmnID(e *aper.Encoder, plmnID OctetString) error {
+	if plmnID.Size > len(plmnID.Buf) {
+		return aper.ErrLength
+	}
+	return e.PutOctetString(plmnID.Buf[:plmnID.Size], 3, 3, false)
+}
+
+func getPlmnID(d *aper.Decoder) (OctetString, error) {
+	buf, err := d.GetOctetString(3, 3, false)
+	return OctetString{Buf: buf, Size: len(buf)}, err
+}
+
+func putBitString(e *aper.Encoder, b BitString, lb int64, ub int64) error {
+	if b.Size > len(b.Buf) {
+		return aper.ErrLength
+	}
+	return e.PutBitString(b.Buf[:b.Size], b.Size*8-b.BitsUnused, lb, ub, false)
+}
+
+func getBitString(d *aper.Decoder, lb int64, ub int64) (*BitString, error) {
+	buf, n, err := d.GetBitString(lb, ub, false)
+	if err != nil {
+		return nil, err
+	}
+	return &BitString{Buf: buf, Size: len(buf), BitsUnused: len(buf)*8 - n}, nil
+}
+
+func putName(e *aper.Encoder, name *PrintableString) error {
+	if name.Size > len(name.Buf) {
+		return aper.ErrLength
+	}
+	return e.PutPrintableString(string(name.Buf[:name.Size]), 1, MAX_NAME_LENGTH, true)
+}
+
+func getName(d *aper.Decoder) (*PrintableString, error) {
+	name, err := d.GetPrintableString(1, MAX_NAME_LENGTH, true)
+	if err != nil {
+		return nil, err
+	}
+	return &PrintableString{Buf: []byte(name), Size: len(name)}, nil
+}
+
+// putPrbs writes the DL and UL PRB values of a container, -1 standing for an absent one
+func putPrbs(e *aper.Encoder, prbs IntPair64, ub int64) error {
+	if prbs.DL != -1 {
+		if err := e.PutInteger(prbs.DL, 0, ub, false); err != nil {
+			return err
+		}
+	}
+	if prbs.UL != -1 {
+		return e.PutInteger(prbs.UL, 0, ub, false)
+	}
+	return nil
+}
+
+func getPrbs(d *aper.Decoder, present []bool, ub int64) (IntPair64, error) {
+	prbs := IntPair64{DL: -1, UL: -1}
+	var err error
+	if present[0] {
+		if prbs.DL, err = d.GetInteger(0, ub, false); err != nil {
+			return prbs, err
+		}
+	}
+	if present[1] {
+		if prbs.UL, err = d.GetInteger(0, ub, false); err != nil {
+			return prbs, err
+		}
+	}
+	return prbs, nil
+}
+
+func putPDCPBytes(e *aper.Encoder, dl *Integer, ul *Integer) error {
+	if dl != nil {
+		if err := putInteger(e, dl, 0, MAX_PDCP_BYTES, true); err != nil {
+			return err
+		}
+	}
+	if ul != nil {
+		return putInteger(e, ul, 0, MAX_PDCP_BYTES, true)
+	}
+	return nil
+}
+
+func getPDCPBytes(d *aper.Decoder, present []bool) (dl *Integer, ul *Integer, err error) {
+	if present[0] {
+		if dl, err = getInteger(d, 0, MAX_PDCP_BYTES, true); err != nil {
+			return
+		}
+	}
+	if present[1] {
+		ul, err = getInteger(d, 0, MAX_PDCP_BYTES, true)
+	}
+	return
+}
+
+// putInteger writes an INTEGER held as asn1c INTEGER_t: big-endian two's complement octets
+func putInteger(e *aper.Encoder, i *Integer, lb int64, ub int64, ext bool) error {
+	if i.Size < 1 || i.Size > 8 || i.Size > len(i.Buf) {
+		return errors.New("Invalid INTEGER of " + strconv.Itoa(i.Size) + " bytes")
+	}
+	v := int64(int8(i.Buf[0]))
+	for _, b := range i.Buf[1:i.Size] {
+		v = v<<8 | int64(b)
+	}
+	return e.PutInteger(v, lb, ub, ext)
+}
+
+// getInteger reads an INTEGER into the minimal two's complement octets asn1c keeps in an INTEGER_t
+func getInteger(d *aper.Decoder, lb int64, ub int64, ext bool) (*Integer, error) {
+	v, err := d.GetInteger(lb, ub, ext)
+	if err != nil {
+		return nil, err
+	}
+	n := 1
+	for n < 8 && (v < -(1<<(uint(n)*8-1)) || v >= 1<<(uint(n)*8-1)) {
+		n++
+	}
+	buf := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		buf[i] = byte(v)
+		v >>= 8
+	}
+	return &Integer{Buf: buf, Size: n}, nil
+}
+
+// putCount writes the item count of a list of at most ub items
+func putCount(e *aper.Encoder, list string, n int, ub int64) error {
+	if n < 1 || int64(n) > ub {
+		return errors.New(list + " of " + strconv.Itoa(n) + " items, 1.." + strconv.FormatInt(ub, 10) + " allowed")
+	}
+	return e.PutSequenceOf(n, 1, ub, false)
+}
+
+// getCount reads the item count of a list of at most ub items. The caller sizes the list from it, so a
+// count the rest of the message cannot hold, at minItemBits an item, is rejected before anything is
+// allocated.
+func getCount(d *aper.Decoder, list string, ub int64, minItemBits uint) (int, error) {
+	n, err := d.GetSequenceOf(1, ub, false, minItemBits)
+	switch err {
+	case nil:
+		return n, nil
+	case aper.ErrLength:
+		return 0, errors.New(list + " exceeds the limit of " + strconv.FormatInt(ub, 10) + " items")
+	case aper.ErrTruncated:
+		return 0, errors.New(list + " holds more items than the message carries")
+	}
+	return 0, err
+}
+
+func getOptionals(d *aper.Decoder, n int) ([]bool, error) {
+	present := make([]bool, n)
+	for i := range present {
+		var err error
+		if present[i], err = d.GetBool(); err != nil {
+			return nil, err
+		}
+	}
+	return present, nil
+}
+
+// skipExtensions reads and drops the extension additions of a SEQUENCE whose extension bit was set
+func skipExtensions(d *aper.Decoder, extended bool) error {
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(int, *aper.Decoder) error { return nil })
+}
@@ -0,0 +1,246 @@
+package control
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The golden payloads in testdata/e2sm were encoded by the asn1c codec of libe2smwrapper (see testdata/README.md):
+// the Go codec must decode them to the expected values and encode these back to the same bytes.
+
+func octets(b ...byte) OctetString {
+	return OctetString{Buf: b, Size: len(b)}
+}
+
+func bitString(unused int, b ...byte) BitString {
+	return BitString{Buf: b, Size: len(b), BitsUnused: unused}
+}
+
+func integer(b ...byte) *Integer {
+	return &Integer{Buf: b, Size: len(b)}
+}
+
+func printable(s string) *PrintableString {
+	return &PrintableString{Buf: []byte(s), Size: len(s)}
+}
+
+func loadGolden(name string) ([]byte, error) {
+	text, err := ioutil.ReadFile(filepath.Join("testdata", name+".hex"))
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(text)))
+}
+
+func readGolden(t testing.TB, name string) []byte {
+	t.Helper()
+	payload, err := loadGolden(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return payload
+}
+
+func checkDecoded(t *testing.T, name string, got interface{}, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("%s decoded to\n%s\nwant\n%s", name, gotJSON, wantJSON)
+	}
+}
+
+func checkEncoded(t *testing.T, name string, got []byte, err error, want []byte) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+	} else if !bytes.Equal(got, want) {
+		t.Errorf("%s encoded to %x, want %x", name, got, want)
+	}
+}
+
+var eventTriggerGoldens = []struct {
+	name    string
+	periods []int64
+}{
+	{"e2sm/event_trigger_one_period", []int64{13}},
+	{"e2sm/event_trigger_periods", []int64{0, 10, 19}},
+}
+
+func TestEventTriggerDefinitionGolden(t *testing.T) {
+	var e2sm E2sm
+	for _, golden := range eventTriggerGoldens {
+		payload := readGolden(t, golden.name)
+		periods, err := e2sm.GetEventTriggerDefinition(payload)
+		if err != nil {
+			t.Errorf("%s: %v", golden.name, err)
+			continue
+		}
+		checkDecoded(t, golden.name, periods, golden.periods)
+		encoded, err := e2sm.SetEventTriggerDefinition(make([]byte, 64), len(periods), periods)
+		checkEncoded(t, golden.name, encoded, err, payload)
+	}
+}
+
+var actionDefinitionGoldens = []struct {
+	name  string
+	style int64
+}{
+	{"e2sm/action_definition_style_1", 1},
+	{"e2sm/action_definition_style_300", 300},
+}
+
+func TestActionDefinitionGolden(t *testing.T) {
+	var e2sm E2sm
+	for _, golden := range actionDefinitionGoldens {
+		payload := readGolden(t, golden.name)
+		style, err := e2sm.GetActionDefinition(payload)
+		if err != nil {
+			t.Errorf("%s: %v", golden.name, err)
+			continue
+		}
+		checkDecoded(t, golden.name, style, golden.style)
+		encoded, err := e2sm.SetActionDefinition(make([]byte, 16), style)
+		checkEncoded(t, golden.name, encoded, err, payload)
+	}
+}
+
+func gnbNodeID() *GlobalKPMnodegNBIDType {
+	gnbID := GNBID(bitString(2, 0x12, 0x34, 0x54))
+	return &GlobalKPMnodegNBIDType{
+		GlobalgNBID: GlobalgNBIDType{PlmnID: octets(0x13, 0xf1, 0x84), GnbIDType: 1, GnbID: &gnbID},
+		GnbCUUPID:   integer(0x01, 0x00),
+		GnbDUID:     integer(0x07),
+	}
+}
+
+func enbNodeID() *GlobalKPMnodeeNBIDType {
+	enbID := ENBID_Macro(bitString(4, 0xf0, 0xf0, 0xf0))
+	return &GlobalKPMnodeeNBIDType{PlmnID: octets(0x01, 0x02, 0x03), EnbIDType: 1, EnbID: &enbID}
+}
+
+func nrcgi(cell byte) NRCGIType {
+	return NRCGIType{PlmnID: octets(0x13, 0xf1, 0x84), NRCellID: bitString(4, 0x00, 0x00, 0x10, 0x00, cell<<4)}
+}
+
+var indicationHeaderGoldens = []struct {
+	name   string
+	header *IndicationHeaderFormat1
+}{
+	{"e2sm/indication_header_no_optionals", &IndicationHeaderFormat1{FiveQI: -1, Qci: -1}},
+	{"e2sm/indication_header_gnb", func() *IndicationHeaderFormat1 {
+		cell := nrcgi(1)
+		plmnID := octets(0x13, 0xf1, 0x84)
+		sd := octets(0x00, 0x00, 0x01)
+		return &IndicationHeaderFormat1{
+			GlobalKPMnodeIDType: 1,
+			GlobalKPMnodeID:     gnbNodeID(),
+			NRCGI:               &cell,
+			PlmnID:              &plmnID,
+			SliceID:             &SliceIDType{SST: octets(0x01), SD: &sd},
+			FiveQI:              9,
+			Qci:                 8,
+		}
+	}()},
+	{"e2sm/indication_header_enb", &IndicationHeaderFormat1{GlobalKPMnodeIDType: 4, GlobalKPMnodeID: enbNodeID(), FiveQI: -1, Qci: -1}},
+}
+
+func TestIndicationHeaderGolden(t *testing.T) {
+	var e2sm E2sm
+	for _, golden := range indicationHeaderGoldens {
+		payload := readGolden(t, golden.name)
+		header, err := e2sm.GetIndicationHeader(payload)
+		if err != nil {
+			t.Errorf("%s: %v", golden.name, err)
+			continue
+		}
+		checkDecoded(t, golden.name, header, &IndicationHeader{IndHdrType: 1, IndHdr: golden.header})
+		encoded, err := e2sm.SetIndicationHeader(make([]byte, 256), header)
+		checkEncoded(t, golden.name, encoded, err, payload)
+	}
+}
+
+func pfContainer(containerType int32, container interface{}) PMContainerType {
+	return PMContainerType{PFContainer: &PFContainerType{ContainerType: containerType, Container: container}}
+}
+
+var indicationMessageGoldens = []struct {
+	name       string
+	containers []PMContainerType
+}{
+	{"e2sm/indication_message_odu", []PMContainerType{pfContainer(1, &ODUPFContainerType{CellResourceReports: []CellResourceReportType{{
+		NRCGI:                nrcgi(1),
+		TotalofAvailablePRBs: IntPair64{DL: 273, UL: 106},
+		ServedPlmnPerCells: []ServedPlmnPerCellType{{
+			PlmnID: octets(0x13, 0xf1, 0x84),
+			DUPM5GC: &DUPM5GCContainerType{SlicePerPlmnPerCells: []SlicePerPlmnPerCellType{{
+				SliceID:                     SliceIDType{SST: octets(0x01)},
+				FQIPERSlicesPerPlmnPerCells: []FQIPERSlicesPerPlmnPerCellType{{FiveQI: 9, PrbUsage: IntPair64{DL: 120, UL: 40}}},
+			}}},
+			DUPMEPC: &DUPMEPCContainerType{PerQCIReports: []DUPMEPCPerQCIReportType{{QCI: 8, PrbUsage: IntPair64{DL: 30, UL: 10}}}},
+		}},
+	}}})}},
+	{"e2sm/indication_message_odu_no_optionals", []PMContainerType{pfContainer(1, &ODUPFContainerType{CellResourceReports: []CellResourceReportType{{
+		NRCGI:                nrcgi(2),
+		TotalofAvailablePRBs: IntPair64{DL: -1, UL: -1},
+		ServedPlmnPerCells:   []ServedPlmnPerCellType{{PlmnID: octets(0x13, 0xf1, 0x84)}},
+	}}})}},
+	{"e2sm/indication_message_ocucp", []PMContainerType{pfContainer(2, &OCUCPPFContainerType{
+		GNBCUCPName:        printable("gnb-cu-cp"),
+		CUCPResourceStatus: CUCPResourceStatusType{NumberOfActiveUEs: 42},
+	})}},
+	{"e2sm/indication_message_ocucp_no_optionals", []PMContainerType{pfContainer(2, &OCUCPPFContainerType{})}},
+	{"e2sm/indication_message_ocuup", []PMContainerType{pfContainer(3, &OCUUPPFContainerType{
+		GNBCUUPName: printable("gnb-cu-up"),
+		CUUPPFContainerItems: []CUUPPFContainerItemType{{
+			InterfaceType: 1,
+			OCUUPPMContainer: CUUPMeasurementContainerType{CUUPPlmns: []CUUPPlmnType{{
+				PlmnID: octets(0x13, 0xf1, 0x84),
+				CUUPPM5GC: &CUUPPM5GCType{SliceToReports: []SliceToReportType{{
+					SliceID:              SliceIDType{SST: octets(0x01), SD: func() *OctetString { sd := octets(0x00, 0x00, 0x01); return &sd }()},
+					FQIPERSlicesPerPlmns: []FQIPERSlicesPerPlmnType{{FiveQI: 9, PDCPBytesDL: integer(0x01, 0x86, 0xa0), PDCPBytesUL: integer(0x27, 0x10)}},
+				}}},
+				CUUPPMEPC: &CUUPPMEPCType{CUUPPMEPCPerQCIReports: []CUUPPMEPCPerQCIReportType{{QCI: 8, PDCPBytesDL: integer(0x03, 0xe8), PDCPBytesUL: integer(0x64)}}},
+			}}},
+		}},
+	})}},
+	{"e2sm/indication_message_ocuup_no_optionals", []PMContainerType{pfContainer(3, &OCUUPPFContainerType{
+		CUUPPFContainerItems: []CUUPPFContainerItemType{{
+			InterfaceType: 0,
+			OCUUPPMContainer: CUUPMeasurementContainerType{CUUPPlmns: []CUUPPlmnType{{
+				PlmnID:    octets(0x13, 0xf1, 0x84),
+				CUUPPMEPC: &CUUPPMEPCType{CUUPPMEPCPerQCIReports: []CUUPPMEPCPerQCIReportType{{QCI: 8}}},
+			}}},
+		}},
+	})}},
+	{"e2sm/indication_message_containers", []PMContainerType{
+		pfContainer(2, &OCUCPPFContainerType{CUCPResourceStatus: CUCPResourceStatusType{NumberOfActiveUEs: 1}}),
+		{},
+		pfContainer(1, &ODUPFContainerType{CellResourceReports: []CellResourceReportType{{
+			NRCGI:                nrcgi(3),
+			TotalofAvailablePRBs: IntPair64{DL: 0, UL: -1},
+			ServedPlmnPerCells:   []ServedPlmnPerCellType{{PlmnID: octets(0x00, 0xf1, 0x10)}},
+		}}}),
+	}},
+}
+
+func TestIndicationMessageGolden(t *testing.T) {
+	var e2sm E2sm
+	for _, golden := range indicationMessageGoldens {
+		payload := readGolden(t, golden.name)
+		message, err := e2sm.GetIndicationMessage(payload)
+		if err != nil {
+			t.Errorf("%s: %v", golden.name, err)
+			continue
+		}
+		checkDecoded(t, golden.name, message, &IndicationMessage{StyleType: 1, IndMsgType: 1, IndMsg: &IndicationMessageFormat1{PMContainers: golden.containers}})
+		encoded, err := e2sm.SetIndicationMessage(make([]byte, 4096), message)
+		checkEncoded(t, golden.name, encoded, err, payload)
+	}
+}
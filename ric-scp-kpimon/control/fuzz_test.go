@@ -0,0 +1,346 @@
+//go:build go1.18
+// +build go1.18
+
+package control
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+)
+
+// Fuzz targets of the path of an RMR payload through the E2AP and E2SM decoders into the metrics entries:
+//
+//	go test -run XXX -fuzz FuzzIndicationDecode ./control
+//
+// go test replays the seed indications and the inputs kept in testdata/fuzz/<target>, add there the ones
+// that made a target fail once fixed.
+
+const FUZZ_RAN_NAME = "gnb_fuzz" //RAN name the fuzzed indications come from
+
+var fuzzVersions = []kpm.Version{kpm.VERSION_1, kpm.VERSION_2, kpm.VERSION_3}
+
+func addSeedIndications(f *testing.F) [][]byte {
+	seeds, err := seedIndications()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	return seeds
+}
+
+func quietLog(f *testing.F) {
+	log.SetOutput(ioutil.Discard)
+	f.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+// fuzzControls builds a memory-backed Control per E2SM-KPM version, the v2/v3 ones subscribed to the
+// measurements of the seed indications
+func fuzzControls() map[kpm.Version]*Control {
+	controls := make(map[kpm.Version]*Control)
+	for _, version := range fuzzVersions {
+		nodes := NewKpmNodes(KpmConfig{
+			Version:      version,
+			Period:       time.Second,
+			Granularity:  time.Second,
+			Measurements: seedMeasurements,
+			Fields:       DefaultKpmFields,
+		})
+		if version != kpm.VERSION_1 {
+			nodes.subscriptionRequest(FUZZ_RAN_NAME) //the v2/v3 indications are read against the subscribed action
+		}
+		c := NewControlWith([]string{FUZZ_RAN_NAME}, NewMemoryMetricsStore(), NewChanTransport(1), nil, nil, nil, nil, nodes, SubscriptionConfig{})
+		controls[version] = &c
+	}
+	return controls
+}
+
+// FuzzIndicationDecode hands a payload to handleIndication as an indication of each E2SM-KPM version, as
+// the RMR receive loop does. A malformed payload must be dropped with an error, not panic.
+func FuzzIndicationDecode(f *testing.F) {
+	quietLog(f)
+	addSeedIndications(f)
+	controls := fuzzControls()
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		for _, version := range fuzzVersions {
+			params := &xapp.RMRParams{Mtype: RIC_INDICATION, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: FUZZ_RAN_NAME}}
+			controls[version].handleIndication(params)
+		}
+	})
+}
+
+// FuzzE2apDecode decodes a payload as every E2AP message kpimon receives or sends
+func FuzzE2apDecode(f *testing.F) {
+	addSeedIndications(f)
+	for _, golden := range e2apGoldens {
+		payload, err := loadGolden(golden.name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(payload)
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		for _, golden := range e2apGoldens {
+			decodeE2ap(golden.message, payload)
+		}
+		var e2ap E2ap
+		e2ap.GetSubscriptionRequestSequenceNumber(payload)
+		e2ap.GetSubscriptionResponseSequenceNumber(payload)
+		e2ap.GetSubscriptionFailureSequenceNumber(payload)
+		e2ap.GetSubscriptionDeleteRequestSequenceNumber(payload)
+		e2ap.GetSubscriptionDeleteResponseSequenceNumber(payload)
+		e2ap.GetSubscriptionDeleteFailureSequenceNumber(payload)
+		e2ap.GetServiceUpdateMessage(payload)
+		e2ap.GetServiceUpdateAcknowledgeMessage(payload)
+		e2ap.GetServiceUpdateFailureMessage(payload)
+	})
+}
+
+// FuzzE2smDecode decodes a payload as every E2SM-KPM type of each version, and parses the IDs, counts and
+// timestamps handleIndication reads from a decoded v1 header
+func FuzzE2smDecode(f *testing.F) {
+	var e2ap E2ap
+	for _, seed := range addSeedIndications(f) {
+		indication, err := e2ap.GetIndicationMessage(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(indication.IndHeader)
+		f.Add(indication.IndMessage)
+	}
+	for _, golden := range indicationMessageGoldens {
+		payload, err := loadGolden(golden.name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(payload)
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		var e2sm E2sm
+		e2sm.GetEventTriggerDefinition(payload)
+		e2sm.GetActionDefinition(payload)
+		e2sm.GetIndicationMessage(payload)
+		e2sm.GetRanFunctionDescription(payload)
+		e2sm.ParseInteger(payload, len(payload))
+		e2sm.ParseTimestamp(payload, len(payload))
+		e2sm.ParsePLMNIdentity(payload, len(payload))
+		if header, err := e2sm.GetIndicationHeader(payload); err == nil {
+			if format1, ok := header.IndHdr.(*IndicationHeaderFormat1); ok {
+				if format1.NRCGI != nil {
+					e2sm.ParseNRCGI(*format1.NRCGI)
+				}
+				if format1.PlmnID != nil {
+					e2sm.ParsePLMNIdentity(format1.PlmnID.Buf, format1.PlmnID.Size)
+				}
+				if format1.SliceID != nil {
+					e2sm.ParseSliceID(*format1.SliceID)
+				}
+			}
+		}
+
+		kpm.DecodeEventTrigger(payload)
+		kpm.DecodeIndicationHeader(payload)
+		kpm.DecodeRanFunctionDescription(payload)
+		for _, version := range fuzzVersions[1:] {
+			kpm.DecodeActionDefinition(payload, version)
+			kpm.DecodeIndicationMessage(payload, version)
+		}
+	})
+}
+
+// seedMeasurements are the measurements the v2/v3 seed indications report, in the order of their records
+var seedMeasurements = []string{"DRB.PdcpSduVolumeDL", "DRB.PdcpSduVolumeUL", "RRU.PrbUsedDl", "RRU.PrbAvailDl"}
+
+// seedIndications returns the RIC Indication payloads the fuzz targets start from: the indication of the
+// E2 simulator kpimon was first run against (PLMN 00f110, cell 0000000010, the values the comments of
+// handleIndication record), v1 reports of several cells and slices and one whose header selects a cell
+// and slice, and v2/v3 reports of every indication message format
+func seedIndications() ([][]byte, error) {
+	var seeds [][]byte
+	add := func(payload []byte, err error) error {
+		if err == nil {
+			seeds = append(seeds, payload)
+		}
+		return err
+	}
+	if err := add(simulatorIndication()); err != nil {
+		return nil, err
+	}
+	if err := add(sampleIndication(1, 1)); err != nil {
+		return nil, err
+	}
+	if err := add(sampleIndication(4, 2)); err != nil {
+		return nil, err
+	}
+	if err := add(sliceIndication()); err != nil {
+		return nil, err
+	}
+	for _, version := range []kpm.Version{kpm.VERSION_2, kpm.VERSION_3} {
+		for format := 1; format <= 3; format++ {
+			if err := add(kpmIndication(version, format)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return seeds, nil
+}
+
+// simulatorIndication encodes the three PF containers of the E2 simulator indication: an O-DU cell report
+// without PRB counts, an O-CU-CP report of no active UEs and an O-CU-UP EPC report of QCI 0
+func simulatorIndication() ([]byte, error) {
+	plmnID := OctetString{Buf: []byte{0x00, 0xf1, 0x10}, Size: 3}
+	nRCGI := NRCGIType{PlmnID: plmnID, NRCellID: BitString{Buf: []byte{0x00, 0x00, 0x00, 0x00, 0x10}, Size: 5, BitsUnused: 4}}
+	indHdr := &IndicationHeader{IndHdrType: 1, IndHdr: &IndicationHeaderFormat1{
+		GlobalKPMnodeIDType: 1,
+		GlobalKPMnodeID: &GlobalKPMnodegNBIDType{
+			GlobalgNBID: GlobalgNBIDType{PlmnID: plmnID, GnbIDType: 1, GnbID: &GNBID{Buf: []byte{0x00, 0x00, 0x00, 0x00}, Size: 4, BitsUnused: 10}},
+		},
+		NRCGI:  &nRCGI,
+		PlmnID: &plmnID,
+		FiveQI: -1,
+		Qci:    -1,
+	}}
+	indMsg := &IndicationMessage{StyleType: 4, IndMsgType: 1, IndMsg: &IndicationMessageFormat1{PMContainers: []PMContainerType{
+		{PFContainer: &PFContainerType{ContainerType: 1, Container: &ODUPFContainerType{CellResourceReports: []CellResourceReportType{{
+			NRCGI:                nRCGI,
+			TotalofAvailablePRBs: IntPair64{DL: -1, UL: -1},
+			ServedPlmnPerCells:   []ServedPlmnPerCellType{{PlmnID: plmnID}},
+		}}}}},
+		{PFContainer: &PFContainerType{ContainerType: 2, Container: &OCUCPPFContainerType{}}},
+		{PFContainer: &PFContainerType{ContainerType: 3, Container: &OCUUPPFContainerType{CUUPPFContainerItems: []CUUPPFContainerItemType{{
+			InterfaceType: 2,
+			OCUUPPMContainer: CUUPMeasurementContainerType{CUUPPlmns: []CUUPPlmnType{{
+				PlmnID: plmnID,
+				CUUPPMEPC: &CUUPPMEPCType{CUUPPMEPCPerQCIReports: []CUUPPMEPCPerQCIReportType{{
+					QCI:         0,
+					PDCPBytesDL: &Integer{Buf: []byte{0x67, 0x50}, Size: 2},
+					PDCPBytesUL: &Integer{Buf: []byte{0x63, 0xc0}, Size: 2},
+				}}},
+			}}},
+		}}}}},
+	}}}
+	return encodeV1Indication(indHdr, indMsg)
+}
+
+// sliceIndication encodes an indication whose header names the cell, slice and 5QI its O-DU and O-CU-UP
+// containers report, so that the PRB and PDCP counts are parsed into the cell entry
+func sliceIndication() ([]byte, error) {
+	plmnID := OctetString{Buf: []byte{0x13, 0xf1, 0x84}, Size: 3}
+	nRCGI := NRCGIType{PlmnID: plmnID, NRCellID: BitString{Buf: []byte{0x12, 0x34, 0x56, 0x78, 0x90}, Size: 5, BitsUnused: 4}}
+	sliceID := SliceIDType{SST: OctetString{Buf: []byte{0x01}, Size: 1}, SD: &OctetString{Buf: []byte{0x00, 0x00, 0x2a}, Size: 3}}
+	indHdr := &IndicationHeader{IndHdrType: 1, IndHdr: &IndicationHeaderFormat1{
+		GlobalKPMnodeIDType: 1,
+		GlobalKPMnodeID: &GlobalKPMnodegNBIDType{
+			GlobalgNBID: GlobalgNBIDType{PlmnID: plmnID, GnbIDType: 1, GnbID: &GNBID{Buf: []byte{0x00, 0x00, 0x01, 0x00}, Size: 4, BitsUnused: 10}},
+			GnbDUID:     &Integer{Buf: []byte{0x01}, Size: 1},
+		},
+		NRCGI:   &nRCGI,
+		PlmnID:  &plmnID,
+		SliceID: &sliceID,
+		FiveQI:  9,
+		Qci:     -1,
+	}}
+	indMsg := &IndicationMessage{StyleType: 1, IndMsgType: 1, IndMsg: &IndicationMessageFormat1{PMContainers: []PMContainerType{
+		{PFContainer: &PFContainerType{ContainerType: 1, Container: &ODUPFContainerType{CellResourceReports: []CellResourceReportType{{
+			NRCGI:                nRCGI,
+			TotalofAvailablePRBs: IntPair64{DL: 273, UL: 273},
+			ServedPlmnPerCells: []ServedPlmnPerCellType{{PlmnID: plmnID, DUPM5GC: &DUPM5GCContainerType{SlicePerPlmnPerCells: []SlicePerPlmnPerCellType{{
+				SliceID:                     sliceID,
+				FQIPERSlicesPerPlmnPerCells: []FQIPERSlicesPerPlmnPerCellType{{FiveQI: 9, PrbUsage: IntPair64{DL: 40, UL: 20}}},
+			}}}}},
+		}}}}},
+		{PFContainer: &PFContainerType{ContainerType: 3, Container: &OCUUPPFContainerType{CUUPPFContainerItems: []CUUPPFContainerItemType{{
+			InterfaceType: 2,
+			OCUUPPMContainer: CUUPMeasurementContainerType{CUUPPlmns: []CUUPPlmnType{{
+				PlmnID: plmnID,
+				CUUPPM5GC: &CUUPPM5GCType{SliceToReports: []SliceToReportType{{
+					SliceID: sliceID,
+					FQIPERSlicesPerPlmns: []FQIPERSlicesPerPlmnType{{
+						FiveQI:      9,
+						PDCPBytesDL: &Integer{Buf: []byte{0x01, 0x00, 0x00}, Size: 3},
+						PDCPBytesUL: &Integer{Buf: []byte{0x10, 0x00}, Size: 2},
+					}},
+				}}},
+			}}},
+		}}}}},
+	}}}
+	return encodeV1Indication(indHdr, indMsg)
+}
+
+func encodeV1Indication(indHdr *IndicationHeader, indMsg *IndicationMessage) ([]byte, error) {
+	var e2sm *E2sm
+
+	header, err := e2sm.SetIndicationHeader(make([]byte, 1<<10), indHdr)
+	if err != nil {
+		return nil, err
+	}
+	message, err := e2sm.SetIndicationMessage(make([]byte, 1<<16), indMsg)
+	if err != nil {
+		return nil, err
+	}
+	return seedIndication(header, message)
+}
+
+// kpmIndication encodes a v2/v3 indication of format, reporting the measurements of seedMeasurements:
+// per E2 node for formats 1 and 2, per UE for format 3
+func kpmIndication(version kpm.Version, format int) ([]byte, error) {
+	hdr := &kpm.IndicationHeader{SenderName: "fuzz", VendorName: "kpimon"}
+	hdr.SetCollectStart(time.Unix(1700000000, 0))
+	header, err := kpm.EncodeIndicationHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	records := func(values ...uint32) []kpm.MeasurementData {
+		data := kpm.MeasurementData{}
+		for _, value := range values {
+			data.Record = append(data.Record, kpm.RecordItem{Kind: kpm.RECORD_INTEGER, Integer: value})
+		}
+		return []kpm.MeasurementData{data}
+	}
+	ranUeID := [8]byte{0, 0, 0, 0, 0, 0, 0, 7}
+	msg := &kpm.IndicationMessage{Format: format}
+	switch format {
+	case 1:
+		msg.MeasData = records(1000, 200, 40, 273)
+	case 2:
+		msg.MeasData = records(1000)
+		msg.MeasCondUeIDs = []kpm.MeasurementCondUeID{{
+			Type:       kpm.MeasurementType{Name: "DRB.PdcpSduVolumeDL"},
+			Conditions: []kpm.MatchingCondition{{Label: &kpm.Label{NoLabel: true}}},
+			UeIDs:      []kpm.UeID{{Type: kpm.UEID_GNB_DU, GnbCuUeF1apIDs: []uint32{1}, RanUeID: &ranUeID}},
+		}}
+	case 3:
+		msg.UeReports = []kpm.UeMeasurementReport{{
+			UeID: kpm.UeID{Type: kpm.UEID_GNB_DU, GnbCuUeF1apIDs: []uint32{1}, RanUeID: &ranUeID},
+			Report: &kpm.IndicationMessage{Format: 1, MeasData: records(42), MeasInfo: []kpm.MeasurementInfo{
+				{Type: kpm.MeasurementType{Name: "RRU.PrbUsedDl"}, Labels: []kpm.Label{{NoLabel: true}}},
+			}},
+		}}
+	}
+	message, err := kpm.EncodeIndicationMessage(msg, version)
+	if err != nil {
+		return nil, err
+	}
+	return seedIndication(header, message)
+}
+
+func seedIndication(header []byte, message []byte) ([]byte, error) {
+	var e2ap *E2ap
+
+	return e2ap.SetIndicationPayload(make([]byte, len(header)+len(message)+1<<10), &DecodedIndicationMessage{
+		RequestID:  1001,
+		FuncID:     0,
+		ActionID:   1,
+		IndSN:      1,
+		IndType:    0,
+		IndHeader:  header,
+		IndMessage: message,
+	})
+}
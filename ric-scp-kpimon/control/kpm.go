@@ -0,0 +1,440 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+)
+
+const (
+	FIELD_PDCP_BYTES_DL = "pdcp-bytes-dl" //PDCPBytesDL of the UE, or of the cell for an E2 node measurement
+	FIELD_PDCP_BYTES_UL = "pdcp-bytes-ul" //PDCPBytesUL of the UE, or of the cell for an E2 node measurement
+	FIELD_PRB_USAGE_DL  = "prb-usage-dl"  //PRBUsageDL of the UE
+	FIELD_PRB_USAGE_UL  = "prb-usage-ul"  //PRBUsageUL of the UE
+	FIELD_AVAIL_PRB_DL  = "avail-prb-dl"  //AvailPRBDL of the cell
+	FIELD_AVAIL_PRB_UL  = "avail-prb-ul"  //AvailPRBUL of the cell
+	FIELD_RSRP          = "rsrp"          //ServingCellRF.RSRP of the UE
+	FIELD_RSRQ          = "rsrq"          //ServingCellRF.RSRQ of the UE
+	FIELD_RSSINR        = "rssinr"        //ServingCellRF.RSSINR of the UE
+)
+
+// DefaultKpmFields maps the 3GPP TS 28.552 measurements of KPM v2/v3 E2 nodes to the metrics entry fields
+// the v1 PF containers fill
+var DefaultKpmFields = map[string]string{
+	"DRB.PdcpSduVolumeDL": FIELD_PDCP_BYTES_DL,
+	"DRB.PdcpSduVolumeUL": FIELD_PDCP_BYTES_UL,
+	"RRU.PrbUsedDl":       FIELD_PRB_USAGE_DL,
+	"RRU.PrbUsedUl":       FIELD_PRB_USAGE_UL,
+	"RRU.PrbAvailDl":      FIELD_AVAIL_PRB_DL,
+	"RRU.PrbAvailUl":      FIELD_AVAIL_PRB_UL,
+}
+
+// KpmRanFunction is the KPM RAN function an E2 node announced in its E2 setup, with the E2 manager field names
+type KpmRanFunction struct {
+	ID         int    `json:"ranFunctionId"`
+	OID        string `json:"ranFunctionOid"`
+	Definition string `json:"ranFunctionDefinition"` //APER encoded RAN function definition in hex
+	Revision   int    `json:"ranFunctionRevision"`
+}
+
+type KpmConfig struct {
+	Version      kpm.Version               //E2SM-KPM version of every E2 node, VERSION_UNKNOWN to negotiate it per E2 node
+	Fallback     kpm.Version               //version of the E2 nodes without a RAN function definition naming a known version
+	RanFunctions map[string]KpmRanFunction //KPM RAN function of the E2 nodes by RAN name
+	Period       time.Duration             //reporting period of the v2/v3 subscriptions
+	Granularity  time.Duration             //granularity period of the v2/v3 subscriptions
+	Measurements []string                  //measurements of the v2/v3 subscriptions
+	Fields       map[string]string         //metrics entry field of each measurement
+	Profiles     *SubscriptionProfiles     //subscription profiles of the E2 nodes, nil to subscribe every E2 node with the default profile
+}
+
+// LoadKpmConfig reads the E2SM-KPM version configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadKpmConfig() (KpmConfig, error) {
+	cfg := KpmConfig{
+		Fallback:    kpm.VERSION_1,
+		Period:      time.Second,
+		Granularity: time.Second,
+		Fields:      make(map[string]string),
+	}
+	var err error
+	if cfg.Version, err = kpm.ParseVersion(os.Getenv("kpmVersion")); err != nil {
+		return cfg, err
+	}
+	if file := os.Getenv("kpmRanFunctions"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return cfg, err
+		}
+		if err = json.Unmarshal(data, &cfg.RanFunctions); err != nil {
+			return cfg, errors.New("Failed to parse KPM RAN function file " + file + ": " + err.Error())
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("kpmReportingPeriod")); err == nil {
+		cfg.Period = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("kpmGranularityPeriod")); err == nil {
+		cfg.Granularity = d
+	}
+	for name, field := range DefaultKpmFields {
+		cfg.Fields[name] = field
+	}
+	if str := os.Getenv("kpmMeasurementFields"); str != "" {
+		for _, pair := range strings.Split(str, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return cfg, errors.New("Invalid KPM measurement field mapping: " + pair)
+			}
+			cfg.Fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	if file := os.Getenv("subscriptionProfiles"); file != "" {
+		if cfg.Profiles, err = LoadSubscriptionProfiles(file); err != nil {
+			return cfg, err
+		}
+	}
+	if str := os.Getenv("kpmMeasurements"); str != "" {
+		for _, name := range strings.Split(str, ",") {
+			cfg.Measurements = append(cfg.Measurements, strings.TrimSpace(name))
+		}
+	} else {
+		for _, name := range []string{"DRB.PdcpSduVolumeDL", "DRB.PdcpSduVolumeUL", "RRU.PrbUsedDl", "RRU.PrbUsedUl", "RRU.PrbAvailDl", "RRU.PrbAvailUl"} {
+			cfg.Measurements = append(cfg.Measurements, name)
+		}
+	}
+	return cfg, nil
+}
+
+// KpmNodes keeps the E2SM-KPM version of each E2 node and the action definition it was subscribed with,
+// which the v2/v3 indications only make sense against
+type KpmNodes struct {
+	cfg      KpmConfig
+	versions map[string]kpm.Version           //negotiated version by RAN name
+	names    map[string]*kpm.RanFunctionName  //RAN function name by RAN name, when the definition could be read
+	actions  map[string]*kpm.ActionDefinition //action definition of the last v2/v3 subscription by RAN name
+	fallback map[string]*SubscriptionProfile  //profile replacing the configured one after RIC Subscription Failures, by RAN name
+	caps     map[string]*KpmCapabilities      //capabilities by RAN name, when the definition could be read
+	mu       *sync.Mutex
+}
+
+func NewKpmNodes(cfg KpmConfig) *KpmNodes {
+	if cfg.Fallback == kpm.VERSION_UNKNOWN {
+		cfg.Fallback = kpm.VERSION_1
+	}
+	k := &KpmNodes{
+		cfg:      cfg,
+		versions: make(map[string]kpm.Version),
+		names:    make(map[string]*kpm.RanFunctionName),
+		actions:  make(map[string]*kpm.ActionDefinition),
+		fallback: make(map[string]*SubscriptionProfile),
+		caps:     make(map[string]*KpmCapabilities),
+		mu:       &sync.Mutex{},
+	}
+	for ranName, function := range cfg.RanFunctions {
+		k.SetRanFunction(ranName, function)
+	}
+	return k
+}
+
+// SetRanFunction negotiates the E2SM-KPM version of an E2 node from the KPM RAN function of its E2 setup,
+// whose OID and definition may be empty, and reads the capabilities of the E2 node from the definition
+func (k *KpmNodes) SetRanFunction(ranName string, function KpmRanFunction) kpm.Version {
+	if k == nil {
+		return kpm.VERSION_1
+	}
+	definition, err := decodeHexDefinition(function.Definition)
+	if err != nil {
+		xapp.Logger.Error("Invalid RAN function definition of {%s}: %v", ranName, err)
+		log.Printf("Invalid RAN function definition of {%s}: %v", ranName, err)
+	}
+	version, name := kpm.Negotiate(function.OID, definition, k.cfg.Fallback)
+	if k.cfg.Version != kpm.VERSION_UNKNOWN {
+		version = k.cfg.Version
+	}
+	caps, err := decodeKpmCapabilities(ranName, function, version, definition)
+	if err != nil {
+		xapp.Logger.Warn("Capabilities of {%s} unknown, subscribing without checking the profile: %v", ranName, err)
+		log.Printf("Capabilities of {%s} unknown, subscribing without checking the profile: %v", ranName, err)
+	}
+	k.mu.Lock()
+	k.versions[ranName] = version
+	k.names[ranName] = name
+	k.caps[ranName] = caps
+	k.mu.Unlock()
+	xapp.Logger.Info("E2SM-KPM version of {%s} is %v, RAN function %d", ranName, version, function.ID)
+	log.Printf("E2SM-KPM version of {%s} is %v, RAN function %d", ranName, version, function.ID)
+	return version
+}
+
+// Capabilities returns what the KPM RAN function definition of an E2 node announced, nil if no RAN
+// function is known for it. Without a readable definition only the RAN function ID and version are set.
+func (k *KpmNodes) Capabilities(ranName string) *KpmCapabilities {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.caps[ranName]
+}
+
+// Version returns the E2SM-KPM version of an E2 node, v1 when kpimon runs without version negotiation
+func (k *KpmNodes) Version(ranName string) kpm.Version {
+	if k == nil {
+		return kpm.VERSION_1
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if version, ok := k.versions[ranName]; ok {
+		return version
+	}
+	if k.cfg.Version != kpm.VERSION_UNKNOWN {
+		return k.cfg.Version
+	}
+	return k.cfg.Fallback
+}
+
+// RanFunctionName returns the RAN function name of an E2 node, nil if its RAN function definition is unknown
+func (k *KpmNodes) RanFunctionName(ranName string) *kpm.RanFunctionName {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.names[ranName]
+}
+
+// Action returns the action definition an E2 node was last subscribed with, nil if none
+func (k *KpmNodes) Action(ranName string) *kpm.ActionDefinition {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.actions[ranName]
+}
+
+// SetAction records the action definition an E2 node is subscribed with
+func (k *KpmNodes) SetAction(ranName string, action *kpm.ActionDefinition) {
+	k.mu.Lock()
+	k.actions[ranName] = action
+	k.mu.Unlock()
+}
+
+// Profile returns the subscription profile of an E2 node
+func (k *KpmNodes) Profile(ranName string) *SubscriptionProfile {
+	if k == nil {
+		return DefaultSubscriptionProfile()
+	}
+	k.mu.Lock()
+	profile := k.fallback[ranName]
+	k.mu.Unlock()
+	if profile != nil {
+		return profile
+	}
+	return k.cfg.Profiles.Profile(ranName)
+}
+
+// Fallback switches an E2 node to the fallback profile of its profile, nil when there is none
+func (k *KpmNodes) Fallback(ranName string) *SubscriptionProfile {
+	if k == nil {
+		return nil
+	}
+	profile := k.cfg.Profiles.Named(k.Profile(ranName).Fallback)
+	if profile != nil {
+		k.mu.Lock()
+		k.fallback[ranName] = profile
+		k.mu.Unlock()
+	}
+	return profile
+}
+
+// ReportPeriod returns the period an E2 node reports at when subscribed with its subscription profile
+func (k *KpmNodes) ReportPeriod(ranName string) time.Duration {
+	profile := k.Profile(ranName)
+	if k.Version(ranName) == kpm.VERSION_1 {
+		if profile.rtPeriod >= 0 && int(profile.rtPeriod) < len(rtPeriods) {
+			return rtPeriods[profile.rtPeriod]
+		}
+		return profile.period
+	}
+	return k.period(profile)
+}
+
+// period returns the v2/v3 reporting period of a subscription profile
+func (k *KpmNodes) period(profile *SubscriptionProfile) time.Duration {
+	if profile.period != 0 {
+		return profile.period
+	}
+	return k.cfg.Period
+}
+
+// actionDefinition returns the v2/v3 format 1 action definition of the measurements of a report action
+func (k *KpmNodes) actionDefinition(action *SubscriptionAction) *kpm.ActionDefinition {
+	definition := &kpm.ActionDefinition{Style: action.Style, Format: 1, Granularity: k.cfg.Granularity}
+	if definition.Style == 0 {
+		definition.Style = 1
+	}
+	measurements := action.Measurements
+	if len(measurements) == 0 {
+		measurements = k.cfg.Measurements
+	}
+	for _, name := range measurements {
+		definition.MeasInfo = append(definition.MeasInfo, kpm.MeasurementInfo{Type: kpm.MeasurementType{Name: name}})
+	}
+	return definition
+}
+
+// Field returns the metrics entry field a measurement is stored in, empty if it is not stored
+func (k *KpmNodes) Field(name string) string {
+	if k == nil {
+		return DefaultKpmFields[name]
+	}
+	return k.cfg.Fields[name]
+}
+
+// handleKpmIndication stores the measurements of a RIC Indication of a KPM v2/v3 E2 node: the values
+// about a UE into its UE entry, the others into the entry of the cell of the action definition, or of
+// the E2 node when the action definition names no cell
+func (c *Control) handleKpmIndication(ranName string, version kpm.Version, indicationMsg *DecodedIndicationMessage) (err error) {
+	action := c.kpm.Action(ranName)
+	report, err := kpm.Decode(version, indicationMsg.IndHeader, indicationMsg.IndMessage, action)
+	if err != nil {
+		xapp.Logger.Error("Failed to decode KPM %v RIC Indication from {%s}: %v", version, ranName, err)
+		log.Printf("Failed to decode KPM %v RIC Indication from {%s}: %v", version, ranName, err)
+		return
+	}
+	log.Printf("KPM %v RIC Indication Message Format: %d, %d measurements", version, report.Message.Format, len(report.Measurements))
+
+	cellIDHdr := ranName
+	if action != nil && action.Cell != nil {
+		if cellIDHdr, err = kpmCellID(action.Cell); err != nil {
+			xapp.Logger.Error("Failed to parse CGI of the action definition of {%s}: %v", ranName, err)
+			log.Printf("Failed to parse CGI of the action definition of {%s}: %v", ranName, err)
+			return
+		}
+	}
+
+	ues := make(map[string]*UeMetricsEntry)
+	ueReports := make(map[string]map[string]bool)
+	var cellMetrics *CellMetricsEntry
+	for i := range report.Measurements {
+		m := &report.Measurements[i]
+		field := c.kpm.Field(m.Type.Name)
+		if field == "" || m.NoValue || (m.Label != nil && !m.Label.NoLabel) {
+			continue //only the unfiltered value of the measurements kpimon stores
+		}
+		value := int64(m.Value)
+		timestamp := Timestamp{TVsec: m.Start.Unix(), TVnsec: int64(m.Start.Nanosecond())}
+		if m.UeID == nil {
+			if cellMetrics == nil {
+				if isCellExist, _ := c.store.ExistsCell(cellIDHdr); isCellExist {
+					cellMetrics, _ = c.store.GetCell(cellIDHdr)
+				}
+				if cellMetrics == nil {
+					cellMetrics = &CellMetricsEntry{}
+				}
+			}
+			switch field {
+			case FIELD_PDCP_BYTES_DL:
+				cellMetrics.MeasTimestampPDCPBytes, cellMetrics.PDCPBytesDL = timestamp, value
+			case FIELD_PDCP_BYTES_UL:
+				cellMetrics.MeasTimestampPDCPBytes, cellMetrics.PDCPBytesUL = timestamp, value
+			case FIELD_AVAIL_PRB_DL:
+				cellMetrics.MeasTimestampPRB, cellMetrics.AvailPRBDL = timestamp, value
+			case FIELD_AVAIL_PRB_UL:
+				cellMetrics.MeasTimestampPRB, cellMetrics.AvailPRBUL = timestamp, value
+			}
+			continue
+		}
+
+		ueID := m.UeID.Key()
+		ueMetrics, ok := ues[ueID]
+		if !ok {
+			if isUeExist, _ := c.store.ExistsUe(ueID); isUeExist {
+				ueMetrics, _ = c.store.GetUe(ueID)
+			}
+			if ueMetrics == nil {
+				ueMetrics = &UeMetricsEntry{}
+			}
+			ueMetrics.UeID = ueID
+			ueMetrics.ServingCellID = cellIDHdr
+			ues[ueID] = ueMetrics
+			ueReports[ueID] = make(map[string]bool)
+		}
+		switch field {
+		case FIELD_PDCP_BYTES_DL:
+			ueMetrics.MeasTimestampPDCPBytes, ueMetrics.PDCPBytesDL = timestamp, value
+			ueReports[ueID][REPORT_CU_UP] = true
+		case FIELD_PDCP_BYTES_UL:
+			ueMetrics.MeasTimestampPDCPBytes, ueMetrics.PDCPBytesUL = timestamp, value
+			ueReports[ueID][REPORT_CU_UP] = true
+		case FIELD_PRB_USAGE_DL:
+			ueMetrics.MeasTimestampPRB, ueMetrics.PRBUsageDL = timestamp, value
+			ueReports[ueID][REPORT_DU] = true
+		case FIELD_PRB_USAGE_UL:
+			ueMetrics.MeasTimestampPRB, ueMetrics.PRBUsageUL = timestamp, value
+			ueReports[ueID][REPORT_DU] = true
+		case FIELD_RSRP:
+			ueMetrics.MeasTimeRF, ueMetrics.ServingCellRF.RSRP = timestamp, int(value)
+			ueReports[ueID][REPORT_CU_CP] = true
+		case FIELD_RSRQ:
+			ueMetrics.MeasTimeRF, ueMetrics.ServingCellRF.RSRQ = timestamp, int(value)
+			ueReports[ueID][REPORT_CU_CP] = true
+		case FIELD_RSSINR:
+			ueMetrics.MeasTimeRF, ueMetrics.ServingCellRF.RSSINR = timestamp, int(value)
+			ueReports[ueID][REPORT_CU_CP] = true
+		}
+	}
+
+	for ueID, ueMetrics := range ues {
+		if c.consistency != nil {
+			for reportType := range ueReports[ueID] {
+				c.consistency.Observe(reportType, ueID, cellIDHdr, ranName)
+			}
+		}
+		if err = c.store.PutUe(ueID, ueMetrics); err != nil {
+			xapp.Logger.Error("Failed to set UeMetrics into store with UE ID [%s]: %v", ueID, err)
+			log.Printf("Failed to set UeMetrics into store with UE ID [%s]: %v", ueID, err)
+		}
+	}
+	if cellMetrics != nil {
+		if err = c.store.PutCell(cellIDHdr, cellMetrics); err != nil {
+			xapp.Logger.Error("Failed to set CellMetrics into store with CellID [%s]: %v", cellIDHdr, err)
+			log.Printf("Failed to set CellMetrics into store with CellID [%s]: %v", cellIDHdr, err)
+		}
+	}
+	return nil
+}
+
+// kpmCellID returns the cell ID a v1 E2 node reporting the same cell in its NRCGI would be stored under.
+// An E-UTRA cell identity takes the 28 leading bits of the NR cell identity.
+func kpmCellID(cgi *kpm.CGI) (string, error) {
+	cellID := cgi.CellID
+	if cgi.EUTRA {
+		cellID <<= 8
+	}
+	nRCGI := NRCGIType{
+		PlmnID:   OctetString{Buf: cgi.PlmnID[:], Size: 3},
+		NRCellID: BitString{Buf: make([]byte, 5), Size: 5, BitsUnused: 4},
+	}
+	for i := 0; i < 5; i++ {
+		nRCGI.NRCellID.Buf[i] = byte(cellID << 4 >> uint(32-8*i))
+	}
+	var e2sm *E2sm
+	return e2sm.ParseNRCGI(nRCGI)
+}
+
+// RanFunctionID returns the RAN function ID an E2 node announced for KPM, fallback if it is unknown
+func (k *KpmNodes) RanFunctionID(ranName string, fallback int) int {
+	if caps := k.Capabilities(ranName); caps != nil {
+		return caps.RanFunctionID
+	}
+	return fallback
+}
@@ -0,0 +1,68 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+)
+
+func kpmRecord(values ...uint32) []kpm.MeasurementData {
+	data := kpm.MeasurementData{}
+	for _, v := range values {
+		data.Record = append(data.Record, kpm.RecordItem{Kind: kpm.RECORD_INTEGER, Integer: v})
+	}
+	return []kpm.MeasurementData{data}
+}
+
+// TestKpmIndicationLabels checks that only the values reported without label, for the whole cell or UE,
+// are stored: a value of a label is about a subset, e.g. a 5QI or slice
+func TestKpmIndicationLabels(t *testing.T) {
+	fiveQI := int64(9)
+	nodes := NewKpmNodes(KpmConfig{Version: kpm.VERSION_2, Granularity: time.Second, Fields: DefaultKpmFields})
+	nodes.SetAction("gnb_1", &kpm.ActionDefinition{Style: 1, Format: 1, Granularity: time.Second})
+	store := NewMemoryMetricsStore()
+	c := NewControlWith([]string{"gnb_1"}, store, NewChanTransport(1), nil, nil, nil, nil, nodes, SubscriptionConfig{})
+
+	hdr := &kpm.IndicationHeader{}
+	hdr.SetCollectStart(time.Unix(1700000000, 0))
+	header, err := kpm.EncodeIndicationHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cellMessage := &kpm.IndicationMessage{Format: 1, MeasData: kpmRecord(80, 50, 1000, 200), MeasInfo: []kpm.MeasurementInfo{
+		{Type: kpm.MeasurementType{Name: "RRU.PrbAvailDl"}, Labels: []kpm.Label{{NoLabel: true}, {FiveQI: &fiveQI}}},
+		{Type: kpm.MeasurementType{Name: "DRB.PdcpSduVolumeDL"}, Labels: []kpm.Label{{SliceID: &kpm.SNSSAI{SST: 1}}}},
+		{Type: kpm.MeasurementType{Name: "DRB.PdcpSduVolumeUL"}},
+	}}
+	ueMessage := &kpm.IndicationMessage{Format: 3, UeReports: []kpm.UeMeasurementReport{{
+		UeID: kpm.UeID{Type: kpm.UEID_GNB_DU, GnbCuUeF1apIDs: []uint32{21}},
+		Report: &kpm.IndicationMessage{Format: 1, MeasData: kpmRecord(7, 5), MeasInfo: []kpm.MeasurementInfo{
+			{Type: kpm.MeasurementType{Name: "RRU.PrbUsedDl"}, Labels: []kpm.Label{{NoLabel: true}, {FiveQI: &fiveQI}}},
+		}},
+	}}}
+	for _, message := range []*kpm.IndicationMessage{cellMessage, ueMessage} {
+		payload, err := kpm.EncodeIndicationMessage(message, kpm.VERSION_2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.handleKpmIndication("gnb_1", kpm.VERSION_2, &DecodedIndicationMessage{IndHeader: header, IndMessage: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cell, err := store.GetCell("gnb_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cell.AvailPRBDL != 80 || cell.PDCPBytesDL != 0 || cell.PDCPBytesUL != 200 {
+		t.Errorf("cell entry %+v, want the unlabelled values AvailPRBDL 80 and PDCPBytesUL 200 only", cell)
+	}
+	ue, err := store.GetUe("21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ue.PRBUsageDL != 7 || ue.ServingCellID != "gnb_1" {
+		t.Errorf("UE entry %+v, want PRBUsageDL 7 in cell gnb_1", ue)
+	}
+}
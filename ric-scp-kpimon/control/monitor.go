@@ -0,0 +1,296 @@
+package control
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/envelope"
+	"github.com/go-redis/redis"
+)
+
+const (
+	ALERT_CREATED  = "created"  //a key kpimon never wrote appeared
+	ALERT_MODIFIED = "modified" //a key kpimon wrote holds another value than the last one kpimon wrote
+	ALERT_DELETED  = "deleted"  //a key kpimon wrote was removed by someone else
+
+	KEYSPACE_EVENTS = "K$gxe" //keyspace notifications for string, generic, expired and evicted events
+)
+
+// Alert reports a store write that kpimon did not do itself
+type Alert struct {
+	Kind     string    `json:"kind"`     //created, modified or deleted
+	Key      string    `json:"key"`      //UE or cell ID
+	Event    string    `json:"event"`    //redis keyspace event, e.g. set or del
+	Expected string    `json:"expected"` //SHA-256 of the last value written by kpimon, empty if none
+	Observed string    `json:"observed"` //SHA-256 of the value found in the store, empty if the key is gone
+	Time     time.Time `json:"time"`
+}
+
+// AlertSink receives the alerts raised by the integrity monitor
+type AlertSink interface {
+	Raise(alert *Alert) error
+}
+
+type MonitorConfig struct {
+	Enabled                 bool   //run the integrity monitor, only supported with the redis store backend
+	WebhookURL              string //URL receiving every alert as a JSON POST
+	WebhookStandInAddr      string //listen address of the in-process webhook stand-in, used when WebhookURL is empty
+	ConfigureKeyspaceEvents bool   //add KEYSPACE_EVENTS to notify-keyspace-events with CONFIG SET, off by default
+}
+
+// LoadMonitorConfig reads the monitor configuration from the xApp environment (see appenv in the xApp descriptor).
+//
+// The monitor only sees the writes redis notifies, the redis server must be started with
+// "notify-keyspace-events K$gxe" (or "KA") in redis.conf, or set with
+// redis-cli CONFIG SET notify-keyspace-events 'K$gxe'. With configureKeyspaceEvents=true kpimon sets it
+// itself at start, which needs the CONFIG command that managed and shared redis servers usually deny.
+func LoadMonitorConfig() MonitorConfig {
+	cfg := MonitorConfig{
+		WebhookURL:         os.Getenv("alertWebhook"),
+		WebhookStandInAddr: os.Getenv("alertWebhookStandIn"),
+	}
+	cfg.Enabled, _ = strconv.ParseBool(os.Getenv("integrityMonitor"))
+	if configure, err := strconv.ParseBool(os.Getenv("configureKeyspaceEvents")); err == nil {
+		cfg.ConfigureKeyspaceEvents = configure
+	}
+	return cfg
+}
+
+// IntegrityMonitor watches the redis keyspace notifications of the metrics store and compares every
+// observed write with what kpimon itself last wrote through the store returned by Wrap. Signed
+// envelopes are compared on their payload.
+type IntegrityMonitor struct {
+	client    *redis.Client
+	kv        kvStore //reads the values of the notified keys, on the client
+	db        int
+	configure bool
+	sinks     []AlertSink
+	owned     map[string]string //key to the SHA-256 of the last value kpimon wrote, empty after kpimon deleted it
+	mu        *sync.Mutex
+	pubsub    *redis.PubSub
+}
+
+func NewIntegrityMonitor(storeCfg StoreConfig, configure bool, sinks ...AlertSink) (*IntegrityMonitor, error) {
+	if storeCfg.Backend != STORE_BACKEND_REDIS {
+		return nil, errors.New("Integrity monitor needs the redis store backend, not " + storeCfg.Backend)
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     storeCfg.RedisAddr,
+		Password: storeCfg.RedisPassword,
+		DB:       storeCfg.RedisDB,
+	})
+	return &IntegrityMonitor{client, &redisKVStore{client}, storeCfg.RedisDB, configure, sinks, make(map[string]string), &sync.Mutex{}, nil}, nil
+}
+
+// setupIntegrityMonitor builds the monitor described by cfg with the log, RMR alarm and webhook alert sinks,
+// starting the webhook stand-in when no webhook URL is given
+func setupIntegrityMonitor(cfg MonitorConfig, storeCfg StoreConfig, transport Transport) (*IntegrityMonitor, error) {
+	sinks := []AlertSink{NewLogAlertSink(), NewRmrAlertSink(transport)}
+	url := cfg.WebhookURL
+	if url == "" && cfg.WebhookStandInAddr != "" {
+		var err error
+		if url, err = NewWebhookStandIn(cfg.WebhookStandInAddr).Start(); err != nil {
+			return nil, err
+		}
+	}
+	if url != "" {
+		sinks = append(sinks, NewWebhookAlertSink(url))
+	}
+	return NewIntegrityMonitor(storeCfg, cfg.ConfigureKeyspaceEvents, sinks...)
+}
+
+// Wrap returns a view of store whose writes are known to the monitor as kpimon's own
+func (m *IntegrityMonitor) Wrap(store MetricsStore) MetricsStore {
+	return &monitoredMetricsStore{store, m}
+}
+
+// Run subscribes to the keyspace notifications and checks them until Close is called
+func (m *IntegrityMonitor) Run() error {
+	if m.configure {
+		if err := m.configureKeyspaceEvents(); err != nil {
+			xapp.Logger.Warn("Failed to enable redis keyspace notifications: %v", err)
+			log.Printf("Failed to enable redis keyspace notifications: %v", err)
+		}
+	}
+	prefix := "__keyspace@" + strconv.Itoa(m.db) + "__:"
+	m.mu.Lock()
+	m.pubsub = m.client.PSubscribe(prefix + "*")
+	pubsub := m.pubsub
+	m.mu.Unlock()
+	if _, err := pubsub.Receive(); err != nil {
+		return err
+	}
+	xapp.Logger.Info("Integrity monitor subscribed to %s*", prefix)
+	log.Printf("Integrity monitor subscribed to %s*", prefix)
+
+	for msg := range pubsub.Channel() {
+		m.check(strings.TrimPrefix(msg.Channel, prefix), msg.Payload)
+	}
+	return nil
+}
+
+func (m *IntegrityMonitor) Close() error {
+	m.mu.Lock()
+	pubsub := m.pubsub
+	m.mu.Unlock()
+	if pubsub != nil {
+		pubsub.Close()
+	}
+	return m.client.Close()
+}
+
+// configureKeyspaceEvents adds the flags needed by the monitor to notify-keyspace-events
+func (m *IntegrityMonitor) configureKeyspaceEvents() error {
+	values, err := m.client.ConfigGet("notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	current := ""
+	if len(values) == 2 {
+		current, _ = values[1].(string)
+	}
+	flags := current
+	for _, flag := range KEYSPACE_EVENTS {
+		if !strings.ContainsRune(flags, flag) && !(flag != 'K' && strings.ContainsRune(flags, 'A')) {
+			flags += string(flag)
+		}
+	}
+	if flags == current {
+		return nil
+	}
+	return m.client.ConfigSet("notify-keyspace-events", flags).Err()
+}
+
+// own records a value kpimon is about to write, nil for a delete
+func (m *IntegrityMonitor) own(key string, value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value == nil {
+		m.owned[key] = ""
+	} else {
+		m.owned[key] = fingerprint(value)
+	}
+}
+
+// check compares a keyspace event with what kpimon wrote. Only the keys kpimon owns are checked: the
+// ones it wrote and the ones shaped like its UE and cell IDs, other writers of a shared redis are left alone.
+func (m *IntegrityMonitor) check(key string, event string) {
+	m.mu.Lock()
+	expected, known := m.owned[key]
+	m.mu.Unlock()
+	if !known && !isMetricsKey(key) {
+		return
+	}
+
+	alert := &Alert{Key: key, Event: event, Expected: expected, Time: time.Now()}
+	switch event {
+	case "del", "expired", "evicted":
+		if !known || expected == "" {
+			return
+		}
+		alert.Kind = ALERT_DELETED
+	case "expire", "rename_from", "rename_to":
+		return
+	default:
+		value, err := m.kv.get(key)
+		if err == ErrEntryNotFound {
+			return
+		} else if err != nil {
+			xapp.Logger.Error("Integrity monitor failed to read key [%s]: %v", key, err)
+			log.Printf("Integrity monitor failed to read key [%s]: %v", key, err)
+			return
+		}
+		alert.Observed = fingerprint(envelope.Payload(value))
+		if !known {
+			alert.Kind = ALERT_CREATED
+		} else if alert.Observed != expected {
+			alert.Kind = ALERT_MODIFIED
+		} else {
+			return
+		}
+	}
+	m.raise(alert)
+}
+
+func (m *IntegrityMonitor) raise(alert *Alert) {
+	for _, sink := range m.sinks {
+		if err := sink.Raise(alert); err != nil {
+			xapp.Logger.Error("Failed to raise %s alert for key [%s]: %v", alert.Kind, alert.Key, err)
+			log.Printf("Failed to raise %s alert for key [%s]: %v", alert.Kind, alert.Key, err)
+		}
+	}
+}
+
+// isMetricsKey tells whether a key has the shape of the UE and cell IDs kpimon writes: decimal, as the
+// RAN UE IDs and the PLMN and NR cell ID digits of an NRCGI are
+func isMetricsKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func fingerprint(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// monitoredMetricsStore tells the monitor about every write before doing it, so that
+// the keyspace notification of the write is not taken for a foreign one
+type monitoredMetricsStore struct {
+	MetricsStore
+	monitor *IntegrityMonitor
+}
+
+func (s *monitoredMetricsStore) PutUe(ueID string, ueMetrics *UeMetricsEntry) error {
+	value, err := json.Marshal(ueMetrics)
+	if err != nil {
+		return err
+	}
+	s.monitor.own(ueID, value)
+	return s.MetricsStore.PutUe(ueID, ueMetrics)
+}
+
+func (s *monitoredMetricsStore) PutCell(cellID string, cellMetrics *CellMetricsEntry) error {
+	value, err := json.Marshal(cellMetrics)
+	if err != nil {
+		return err
+	}
+	s.monitor.own(cellID, value)
+	return s.MetricsStore.PutCell(cellID, cellMetrics)
+}
+
+func (s *monitoredMetricsStore) DeleteUe(ueID string) error {
+	s.monitor.own(ueID, nil)
+	return s.MetricsStore.DeleteUe(ueID)
+}
+
+func (s *monitoredMetricsStore) DeleteCell(cellID string) error {
+	s.monitor.own(cellID, nil)
+	return s.MetricsStore.DeleteCell(cellID)
+}
+
+func (s *monitoredMetricsStore) WriteBatch(batch *MetricsBatch) error {
+	pairs, err := batch.encode()
+	if err != nil {
+		return err
+	}
+	for key, value := range pairs {
+		s.monitor.own(key, value)
+	}
+	return s.MetricsStore.WriteBatch(batch)
+}
@@ -0,0 +1,140 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/envelope"
+)
+
+// alertRecorder is an AlertSink keeping the alerts it is raised
+type alertRecorder struct {
+	alerts []*Alert
+}
+
+func (r *alertRecorder) Raise(alert *Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// take returns the kinds of the alerts raised since the last call
+func (r *alertRecorder) take() []string {
+	var kinds []string
+	for _, alert := range r.alerts {
+		kinds = append(kinds, alert.Kind+":"+alert.Key)
+	}
+	r.alerts = nil
+	return kinds
+}
+
+type failingSink struct{}
+
+func (failingSink) Raise(alert *Alert) error {
+	return errors.New("sink down")
+}
+
+// memoryMonitor returns a monitor reading the notified keys from kv instead of redis
+func memoryMonitor(kv kvStore) (*IntegrityMonitor, *alertRecorder) {
+	recorder := &alertRecorder{}
+	return &IntegrityMonitor{kv: kv, sinks: []AlertSink{failingSink{}, recorder}, owned: make(map[string]string), mu: &sync.Mutex{}}, recorder
+}
+
+func checkAlerts(t *testing.T, recorder *alertRecorder, step string, want ...string) {
+	t.Helper()
+	got := recorder.take()
+	if len(got) != len(want) {
+		t.Errorf("%s: alerts %v, want %v", step, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: alerts %v, want %v", step, got, want)
+			return
+		}
+	}
+}
+
+func TestMonitorCheck(t *testing.T) {
+	kv := newMemoryKVStore()
+	m, recorder := memoryMonitor(kv)
+	store := m.Wrap(&kvMetricsStore{kv})
+
+	store.PutUe("1", storeUe("1"))
+	m.check("1", "set")
+	checkAlerts(t, recorder, "own write")
+
+	foreign := storeUe("1")
+	foreign.PRBUsageDL = 99
+	value, _ := json.Marshal(foreign)
+	kv.set(map[string][]byte{"1": value})
+	m.check("1", "set")
+	alert := recorder.alerts[0]
+	checkAlerts(t, recorder, "foreign write", ALERT_MODIFIED+":1")
+	owned, _ := json.Marshal(storeUe("1"))
+	if alert.Event != "set" || alert.Expected != fingerprint(owned) || alert.Observed != fingerprint(value) {
+		t.Errorf("modified alert %+v", alert)
+	}
+
+	//keys shaped like UE and cell IDs are watched before kpimon writes them, other keys are left alone
+	kv.set(map[string][]byte{"2": value, "session:2": value})
+	m.check("2", "set")
+	m.check("session:2", "set")
+	checkAlerts(t, recorder, "foreign keys", ALERT_CREATED+":2")
+
+	//a notification of a key gone in the meantime, or of its expiry setting, is not an alert
+	m.check("3", "set")
+	m.check("1", "expire")
+	checkAlerts(t, recorder, "key gone")
+
+	store.DeleteUe("1")
+	m.check("1", "del")
+	checkAlerts(t, recorder, "own delete")
+	batch := NewMetricsBatch()
+	batch.PutUe("1", storeUe("1"))
+	batch.PutCell("4", storeCell())
+	store.WriteBatch(batch)
+	m.check("1", "set")
+	m.check("4", "set")
+	checkAlerts(t, recorder, "own batch")
+
+	kv.del("4")
+	m.check("4", "del")
+	m.check("5", "del")
+	m.check("1", "expired")
+	checkAlerts(t, recorder, "foreign deletes", ALERT_DELETED+":4", ALERT_DELETED+":1")
+}
+
+// TestMonitorCheckSigned checks that signed records are compared on their payload
+func TestMonitorCheckSigned(t *testing.T) {
+	signed := signedStore(t, envelope.ALG_ED25519, SIGNING_ENFORCE)
+	m, recorder := memoryMonitor(rawKV(signed))
+	store := m.Wrap(signed)
+
+	store.PutCell("4", storeCell())
+	m.check("4", "set")
+	checkAlerts(t, recorder, "own signed write")
+	ForeignView(signed).PutCell("4", storeCell())
+	m.check("4", "set")
+	checkAlerts(t, recorder, "same plain value")
+
+	cell := storeCell()
+	cell.AvailPRBDL = 1
+	ForeignView(signed).PutCell("4", cell)
+	m.check("4", "set")
+	checkAlerts(t, recorder, "foreign plain value", ALERT_MODIFIED+":4")
+}
+
+func TestLoadMonitorConfig(t *testing.T) {
+	os.Unsetenv("configureKeyspaceEvents")
+	if cfg := LoadMonitorConfig(); cfg.ConfigureKeyspaceEvents {
+		t.Error("keyspace events configured by default")
+	}
+	os.Setenv("configureKeyspaceEvents", "true")
+	defer os.Unsetenv("configureKeyspaceEvents")
+	if cfg := LoadMonitorConfig(); !cfg.ConfigureKeyspaceEvents {
+		t.Error("configureKeyspaceEvents=true ignored")
+	}
+}
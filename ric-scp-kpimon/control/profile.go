@@ -0,0 +1,398 @@
+package control
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/kpm"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SUBSCRIPTION_REQUESTOR_ID = 1001 //RIC requestor ID kpimon subscribes with
+	SUBSCRIPTION_INSTANCE_ID  = 1001 //RIC instance ID, and RMR SubId, of the subscriptions
+
+	SUBSCRIPTION_ACTION_REPORT = "report"
+	SUBSCRIPTION_ACTION_INSERT = "insert"
+	SUBSCRIPTION_ACTION_POLICY = "policy"
+
+	SUBSEQUENT_ACTION_CONTINUE = "continue"
+	SUBSEQUENT_ACTION_WAIT     = "wait"
+)
+
+// rtPeriods are the RT-Period-IE values of the E2SM-KPM v1 event trigger, in their enumeration order
+var rtPeriods = []time.Duration{
+	10 * time.Millisecond, 20 * time.Millisecond, 32 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond,
+	64 * time.Millisecond, 70 * time.Millisecond, 80 * time.Millisecond, 128 * time.Millisecond, 160 * time.Millisecond,
+	256 * time.Millisecond, 320 * time.Millisecond, 512 * time.Millisecond, 640 * time.Millisecond, 1024 * time.Millisecond,
+	1280 * time.Millisecond, 2048 * time.Millisecond, 2560 * time.Millisecond, 5120 * time.Millisecond, 10240 * time.Millisecond,
+}
+
+// timesToWait are the RICtimeToWait values of a subsequent action, in their enumeration order
+var timesToWait = []time.Duration{
+	0, time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond,
+	30 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 20 * time.Second, 60 * time.Second,
+}
+
+// SubscriptionProfiles is the content of a subscription profile file: the profiles and the profile
+// each E2 node is subscribed with
+type SubscriptionProfiles struct {
+	Default  string                 `yaml:"default" json:"default"` //profile of the E2 nodes Nodes does not name, the built-in one when empty
+	Nodes    map[string]string      `yaml:"nodes" json:"nodes"`     //profile name by RAN name
+	Profiles []*SubscriptionProfile `yaml:"profiles" json:"profiles"`
+
+	byName map[string]*SubscriptionProfile
+}
+
+// SubscriptionProfile is what a RIC Subscription Request to an E2 node asks for
+type SubscriptionProfile struct {
+	Name          string                `yaml:"name" json:"name"`
+	RanFunctionID *int                  `yaml:"ran_function_id" json:"ran_function_id"` //the ID the E2 node announced for KPM when absent
+	RequestorID   int                   `yaml:"requestor_id" json:"requestor_id"`       //SUBSCRIPTION_REQUESTOR_ID when 0
+	InstanceID    int                   `yaml:"instance_id" json:"instance_id"`         //SUBSCRIPTION_INSTANCE_ID when 0
+	ReportPeriod  string                `yaml:"report_period" json:"report_period"`     //e.g. 640ms; an RT-Period-IE value for KPM v1, kpmReportingPeriod for v2/v3 when empty
+	Actions       []*SubscriptionAction `yaml:"actions" json:"actions"`
+	Fallback      string                `yaml:"fallback" json:"fallback"` //profile to subscribe with when the E2 node refuses the actions of this one
+
+	period   time.Duration
+	rtPeriod int64
+}
+
+// SubscriptionAction is one RIC action to be setup
+type SubscriptionAction struct {
+	ID               int64                    `yaml:"id" json:"id"`
+	Type             string                   `yaml:"type" json:"type"`                 //report, insert or policy, report when empty
+	Style            int64                    `yaml:"style" json:"style"`               //RIC style type of the action definition, none for KPM v1 when 0
+	Definition       string                   `yaml:"definition" json:"definition"`     //APER encoded action definition in hex, sent as is
+	Measurements     []string                 `yaml:"measurements" json:"measurements"` //KPM v2/v3 measurements of a format 1 definition, kpmMeasurements when empty
+	SubsequentAction *SubsequentActionProfile `yaml:"subsequent_action" json:"subsequent_action"`
+
+	actionType int64
+	definition []byte
+}
+
+// SubsequentActionProfile is the RIC subsequent action of an action
+type SubsequentActionProfile struct {
+	Type       string `yaml:"type" json:"type"`                 //continue or wait
+	TimeToWait string `yaml:"time_to_wait" json:"time_to_wait"` //a RICtimeToWait value: 0s, 1ms, 2ms, 5ms, ... 20s, 60s
+
+	subsequentType int64
+	timeToWait     int64
+}
+
+// DefaultSubscriptionProfile is the profile kpimon subscribes with when no profile file names one:
+// one report action of ID 0 without action definition, reported every 640 ms (RT-Period-IE 13)
+func DefaultSubscriptionProfile() *SubscriptionProfile {
+	p := &SubscriptionProfile{Name: "default", Actions: []*SubscriptionAction{{ID: 0}}}
+	p.validate()
+	return p
+}
+
+// LoadSubscriptionProfiles reads a subscription profile file in YAML (.yaml, .yml) or JSON (.json)
+func LoadSubscriptionProfiles(path string) (*SubscriptionProfiles, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := &SubscriptionProfiles{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, profiles)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, profiles)
+	default:
+		return nil, errors.New("Unknown subscription profile file format: " + path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subscription profile file %s: %v", path, err)
+	}
+	return profiles, profiles.Validate()
+}
+
+func (s *SubscriptionProfiles) Validate() error {
+	s.byName = make(map[string]*SubscriptionProfile, len(s.Profiles))
+	for i, profile := range s.Profiles {
+		if profile.Name == "" {
+			profile.Name = fmt.Sprintf("profile-%d", i)
+		}
+		if s.byName[profile.Name] != nil {
+			return errors.New("Duplicate subscription profile: " + profile.Name)
+		}
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("subscription profile %s: %v", profile.Name, err)
+		}
+		s.byName[profile.Name] = profile
+	}
+	if s.Default != "" && s.byName[s.Default] == nil {
+		return errors.New("Unknown default subscription profile: " + s.Default)
+	}
+	for ranName, name := range s.Nodes {
+		if s.byName[name] == nil {
+			return fmt.Errorf("Unknown subscription profile %s of {%s}", name, ranName)
+		}
+	}
+	for _, profile := range s.Profiles {
+		seen := map[string]bool{profile.Name: true}
+		for fallback := profile.Fallback; fallback != ""; fallback = s.byName[fallback].Fallback {
+			if s.byName[fallback] == nil {
+				return fmt.Errorf("Unknown fallback profile %s of subscription profile %s", fallback, profile.Name)
+			}
+			if seen[fallback] {
+				return errors.New("Fallback profiles of subscription profile " + profile.Name + " loop")
+			}
+			seen[fallback] = true
+		}
+	}
+	return nil
+}
+
+// Named returns the profile of a name, nil when there is none
+func (s *SubscriptionProfiles) Named(name string) *SubscriptionProfile {
+	if s == nil {
+		return nil
+	}
+	return s.byName[name]
+}
+
+// Profile returns the profile an E2 node is subscribed with
+func (s *SubscriptionProfiles) Profile(ranName string) *SubscriptionProfile {
+	if s == nil {
+		return DefaultSubscriptionProfile()
+	}
+	if name, ok := s.Nodes[ranName]; ok {
+		return s.byName[name]
+	}
+	if s.Default != "" {
+		return s.byName[s.Default]
+	}
+	return DefaultSubscriptionProfile()
+}
+
+func (p *SubscriptionProfile) validate() error {
+	if p.RequestorID == 0 {
+		p.RequestorID = SUBSCRIPTION_REQUESTOR_ID
+	}
+	if p.InstanceID == 0 {
+		p.InstanceID = SUBSCRIPTION_INSTANCE_ID
+	}
+	if p.RequestorID < 0 || p.RequestorID > 65535 || p.InstanceID < 0 || p.InstanceID > 65535 {
+		return errors.New("requestor and instance IDs must be in 0..65535")
+	}
+	if p.RanFunctionID != nil && (*p.RanFunctionID < 0 || *p.RanFunctionID > 4095) {
+		return errors.New("RAN function ID must be in 0..4095")
+	}
+	p.rtPeriod = 13
+	if p.ReportPeriod != "" {
+		period, err := time.ParseDuration(p.ReportPeriod)
+		if err != nil {
+			return err
+		}
+		if period < time.Millisecond {
+			return errors.New("report period below 1ms")
+		}
+		p.period = period
+		p.rtPeriod = -1 //only KPM v2/v3 E2 nodes can be subscribed with a period that is no RT-Period-IE value
+		for i, rtPeriod := range rtPeriods {
+			if rtPeriod == period {
+				p.rtPeriod = int64(i)
+			}
+		}
+	}
+	if len(p.Actions) == 0 || len(p.Actions) > 16 {
+		return errors.New("a subscription takes 1 to 16 actions")
+	}
+	ids := make(map[int64]bool)
+	for _, action := range p.Actions {
+		if action.ID < 0 || action.ID > 255 {
+			return fmt.Errorf("action ID %d is not in 0..255", action.ID)
+		}
+		if ids[action.ID] {
+			return fmt.Errorf("duplicate action ID %d", action.ID)
+		}
+		ids[action.ID] = true
+		if err := action.validate(); err != nil {
+			return fmt.Errorf("action %d: %v", action.ID, err)
+		}
+	}
+	return nil
+}
+
+func (a *SubscriptionAction) validate() error {
+	switch a.Type {
+	case "", SUBSCRIPTION_ACTION_REPORT:
+		a.actionType = 0
+	case SUBSCRIPTION_ACTION_INSERT:
+		a.actionType = 1
+	case SUBSCRIPTION_ACTION_POLICY:
+		a.actionType = 2
+	default:
+		return errors.New("unknown action type " + a.Type)
+	}
+	if a.Style < 0 {
+		return errors.New("negative RIC style type")
+	}
+	if a.Definition != "" {
+		definition, err := hex.DecodeString(a.Definition)
+		if err != nil {
+			return fmt.Errorf("action definition: %v", err)
+		}
+		a.definition = definition
+	}
+	if a.SubsequentAction != nil {
+		return a.SubsequentAction.validate()
+	}
+	return nil
+}
+
+func (s *SubsequentActionProfile) validate() error {
+	switch s.Type {
+	case SUBSEQUENT_ACTION_CONTINUE:
+		s.subsequentType = 0
+	case SUBSEQUENT_ACTION_WAIT:
+		s.subsequentType = 1
+	default:
+		return errors.New("unknown subsequent action type " + s.Type)
+	}
+	if s.TimeToWait == "" {
+		return nil
+	}
+	timeToWait, err := time.ParseDuration(s.TimeToWait)
+	if err != nil {
+		return err
+	}
+	for i, value := range timesToWait {
+		if value == timeToWait {
+			s.timeToWait = int64(i)
+			return nil
+		}
+	}
+	return errors.New("time to wait " + s.TimeToWait + " is no RICtimeToWait value")
+}
+
+// subscriptionRequest is the content of the RIC Subscription Request of an E2 node
+type subscriptionRequest struct {
+	requestorID       int
+	instanceID        int
+	funcID            int
+	eventTrigger      []byte
+	actionIds         []int64
+	actionTypes       []int64
+	actionDefinitions []ActionDefinition
+	subsequentActions []SubsequentAction
+}
+
+// subscriptionRequest applies the subscription profile of an E2 node to its E2SM-KPM version. KPM v1
+// actions carry the action definition of their style, v2/v3 report actions a format 1 definition of their
+// measurements unless the profile gives the definition; the definition of the first report action is what
+// the v2/v3 indications are read against.
+func (k *KpmNodes) subscriptionRequest(ranName string) (*subscriptionRequest, error) {
+	var e2sm *E2sm
+
+	profile := k.Profile(ranName)
+	version := k.Version(ranName)
+	req := &subscriptionRequest{
+		requestorID: profile.RequestorID,
+		instanceID:  profile.InstanceID,
+		funcID:      k.RanFunctionID(ranName, 0),
+	}
+	caps := k.Capabilities(ranName)
+	if profile.RanFunctionID != nil {
+		if caps != nil && caps.RanFunctionID != *profile.RanFunctionID {
+			return nil, fmt.Errorf("profile %s asks for RAN function %d, {%s} announced %d for KPM", profile.Name, *profile.RanFunctionID, ranName, caps.RanFunctionID)
+		}
+		req.funcID = *profile.RanFunctionID
+	}
+	if caps != nil && !caps.periodic() {
+		return nil, fmt.Errorf("{%s} announces no periodic event trigger (format 1)", ranName)
+	}
+
+	var err error
+	if version == kpm.VERSION_1 {
+		if profile.rtPeriod < 0 {
+			return nil, fmt.Errorf("report period %s of profile %s is no KPM v1 RT-Period-IE value", profile.ReportPeriod, profile.Name)
+		}
+		if req.eventTrigger, err = e2sm.SetEventTriggerDefinition(make([]byte, 64), 1, []int64{profile.rtPeriod}); err != nil {
+			return nil, err
+		}
+	} else if req.eventTrigger, err = kpm.EncodeEventTrigger(k.period(profile)); err != nil {
+		return nil, err
+	}
+
+	var reportAction *kpm.ActionDefinition
+	for _, action := range profile.Actions {
+		definition := action.definition
+		if definition == nil && action.actionType == 0 && caps != nil {
+			if action, err = k.tune(ranName, caps, action); err != nil {
+				return nil, fmt.Errorf("action %d of profile %s: %v", action.ID, profile.Name, err)
+			}
+		}
+		if definition == nil && version == kpm.VERSION_1 && action.Style != 0 {
+			if definition, err = e2sm.SetActionDefinition(make([]byte, 64), action.Style); err != nil {
+				return nil, err
+			}
+		} else if definition == nil && version != kpm.VERSION_1 && action.actionType == 0 {
+			if definition, err = kpm.EncodeActionDefinition(k.actionDefinition(action), version); err != nil {
+				return nil, err
+			}
+		}
+		if version != kpm.VERSION_1 && action.actionType == 0 && reportAction == nil {
+			if reportAction, err = kpm.DecodeActionDefinition(definition, version); err != nil {
+				return nil, fmt.Errorf("action definition of action %d of profile %s: %v", action.ID, profile.Name, err)
+			}
+		}
+		req.actionIds = append(req.actionIds, action.ID)
+		req.actionTypes = append(req.actionTypes, action.actionType)
+		req.actionDefinitions = append(req.actionDefinitions, ActionDefinition{Buf: definition, Size: len(definition)})
+		if action.SubsequentAction != nil {
+			req.subsequentActions = append(req.subsequentActions, SubsequentAction{1, action.SubsequentAction.subsequentType, action.SubsequentAction.timeToWait})
+		} else {
+			req.subsequentActions = append(req.subsequentActions, SubsequentAction{0, 0, 0})
+		}
+	}
+	if reportAction != nil {
+		k.SetAction(ranName, reportAction)
+	}
+	return req, nil
+}
+
+// tune returns the report action of a profile as the capabilities of an E2 node allow to send it: with
+// the report style the E2 node supports, picked from the announced ones when the action names none, and
+// for KPM v2/v3 without the measurements that style does not list
+func (k *KpmNodes) tune(ranName string, caps *KpmCapabilities, action *SubscriptionAction) (*SubscriptionAction, error) {
+	style, err := caps.tuneStyle(action.Style)
+	if err != nil {
+		return action, err
+	}
+	tuned := *action
+	if style != action.Style {
+		xapp.Logger.Info("Action %d to {%s} takes report style %d", action.ID, ranName, style)
+		log.Printf("Action %d to {%s} takes report style %d", action.ID, ranName, style)
+		tuned.Style = style
+	}
+	if caps.Version == kpm.VERSION_1 {
+		return &tuned, nil
+	}
+	measurements := action.Measurements
+	if len(measurements) == 0 {
+		measurements = k.cfg.Measurements
+	}
+	kept, dropped, err := caps.tuneMeasurements(style, measurements)
+	if err != nil {
+		return action, err
+	}
+	if len(dropped) > 0 {
+		xapp.Logger.Warn("Action %d to {%s} leaves out the measurements report style %d does not support: %s", action.ID, ranName, style, strings.Join(dropped, ", "))
+		log.Printf("Action %d to {%s} leaves out the measurements report style %d does not support: %s", action.ID, ranName, style, strings.Join(dropped, ", "))
+		tuned.Measurements = kept
+	}
+	return &tuned, nil
+}
@@ -0,0 +1,94 @@
+package control
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSubscriptionProfilesValidate(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		profiles   string
+		err        string //part of the error, valid when empty
+		rtPeriod   int64  //RT-Period-IE of the first profile
+		timeToWait int64  //RICtimeToWait of the subsequent action of its first action, if any
+	}{
+		{"defaults", `profiles: [{actions: [{id: 0}]}]`, "", 13, 0},
+		{"RT-Period-IE of the report period", `profiles: [{report_period: 10ms, actions: [{id: 0}]}]`, "", 0, 0},
+		{"last RT-Period-IE", `profiles: [{report_period: 10240ms, actions: [{id: 0}]}]`, "", 19, 0},
+		{"RT-Period-IE of another unit", `profiles: [{report_period: 1.28s, actions: [{id: 0}]}]`, "", 15, 0},
+		{"report period of v2/v3 only", `profiles: [{report_period: 1s, actions: [{id: 0}]}]`, "", -1, 0},
+		{"report period below 1ms", `profiles: [{report_period: 500us, actions: [{id: 0}]}]`, "below 1ms", 0, 0},
+		{"unparsable report period", `profiles: [{report_period: often, actions: [{id: 0}]}]`, "duration", 0, 0},
+		{"time to wait", `profiles: [{actions: [{id: 0, subsequent_action: {type: wait, time_to_wait: 10ms}}]}]`, "", 13, 4},
+		{"last time to wait", `profiles: [{actions: [{id: 0, subsequent_action: {type: wait, time_to_wait: 1m}}]}]`, "", 13, 17},
+		{"zero time to wait", `profiles: [{actions: [{id: 0, subsequent_action: {type: continue, time_to_wait: 0s}}]}]`, "", 13, 0},
+		{"no RICtimeToWait value", `profiles: [{actions: [{id: 0, subsequent_action: {type: wait, time_to_wait: 3ms}}]}]`, "no RICtimeToWait value", 0, 0},
+		{"unknown subsequent action", `profiles: [{actions: [{id: 0, subsequent_action: {type: stop}}]}]`, "unknown subsequent action type", 0, 0},
+		{"highest IDs", `profiles: [{requestor_id: 65535, instance_id: 65535, ran_function_id: 4095, actions: [{id: 255}]}]`, "", 13, 0},
+		{"RAN function ID 0", `profiles: [{ran_function_id: 0, actions: [{id: 0}]}]`, "", 13, 0},
+		{"requestor ID above 65535", `profiles: [{requestor_id: 65536, actions: [{id: 0}]}]`, "requestor and instance IDs", 0, 0},
+		{"negative instance ID", `profiles: [{instance_id: -1, actions: [{id: 0}]}]`, "requestor and instance IDs", 0, 0},
+		{"RAN function ID above 4095", `profiles: [{ran_function_id: 4096, actions: [{id: 0}]}]`, "RAN function ID", 0, 0},
+		{"negative RAN function ID", `profiles: [{ran_function_id: -1, actions: [{id: 0}]}]`, "RAN function ID", 0, 0},
+		{"action ID above 255", `profiles: [{actions: [{id: 256}]}]`, "action ID 256", 0, 0},
+		{"negative action ID", `profiles: [{actions: [{id: -1}]}]`, "action ID -1", 0, 0},
+		{"duplicate action ID", `profiles: [{actions: [{id: 1}, {id: 1}]}]`, "duplicate action ID 1", 0, 0},
+		{"no action", `profiles: [{name: a}]`, "1 to 16 actions", 0, 0},
+		{"17 actions", `profiles: [{actions: [{id: 0}, {id: 1}, {id: 2}, {id: 3}, {id: 4}, {id: 5}, {id: 6}, {id: 7}, {id: 8}, {id: 9}, {id: 10}, {id: 11}, {id: 12}, {id: 13}, {id: 14}, {id: 15}, {id: 16}]}]`, "1 to 16 actions", 0, 0},
+		{"unknown action type", `profiles: [{actions: [{id: 0, type: query}]}]`, "unknown action type", 0, 0},
+		{"definition of no hex", `profiles: [{actions: [{id: 0, definition: 0g}]}]`, "action definition", 0, 0},
+		{"fallback chain", `profiles: [{name: a, fallback: b, actions: [{id: 0}]}, {name: b, fallback: c, actions: [{id: 0}]}, {name: c, actions: [{id: 0}]}]`, "", 13, 0},
+		{"fallback to itself", `profiles: [{name: a, fallback: a, actions: [{id: 0}]}]`, "of subscription profile a loop", 0, 0},
+		{"fallback loop", `profiles: [{name: a, fallback: b, actions: [{id: 0}]}, {name: b, fallback: a, actions: [{id: 0}]}]`, "loop", 0, 0},
+		{"fallback loop after a chain", `profiles: [{name: a, fallback: b, actions: [{id: 0}]}, {name: b, fallback: c, actions: [{id: 0}]}, {name: c, fallback: b, actions: [{id: 0}]}]`, "of subscription profile a loop", 0, 0},
+		{"unknown fallback", `profiles: [{name: a, fallback: b, actions: [{id: 0}]}]`, "Unknown fallback profile b", 0, 0},
+		{"duplicate profile", `profiles: [{name: a, actions: [{id: 0}]}, {name: a, actions: [{id: 0}]}]`, "Duplicate subscription profile", 0, 0},
+		{"unknown default", `{default: b, profiles: [{name: a, actions: [{id: 0}]}]}`, "Unknown default subscription profile", 0, 0},
+		{"unknown profile of a node", `{nodes: {gnb_1: b}, profiles: [{name: a, actions: [{id: 0}]}]}`, "Unknown subscription profile b of {gnb_1}", 0, 0},
+	} {
+		profiles := &SubscriptionProfiles{}
+		if err := yaml.UnmarshalStrict([]byte(test.profiles), profiles); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		err := profiles.Validate()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v, want one of %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		profile := profiles.Profiles[0]
+		if profile.rtPeriod != test.rtPeriod {
+			t.Errorf("%s: RT-Period-IE %d, want %d", test.name, profile.rtPeriod, test.rtPeriod)
+		}
+		if subsequent := profile.Actions[0].SubsequentAction; subsequent != nil && subsequent.timeToWait != test.timeToWait {
+			t.Errorf("%s: RICtimeToWait %d, want %d", test.name, subsequent.timeToWait, test.timeToWait)
+		}
+		if profile.RequestorID == 0 || profile.InstanceID == 0 {
+			t.Errorf("%s: requestor ID %d and instance ID %d left 0", test.name, profile.RequestorID, profile.InstanceID)
+		}
+	}
+}
+
+func TestLoadSubscriptionProfiles(t *testing.T) {
+	profiles, err := LoadSubscriptionProfiles("../subscriptions/profiles.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := profiles.Profile("gnb_1"); profile == nil || profile.Name != "kpm-v1-report" || profile.rtPeriod != 13 {
+		t.Errorf("profile of an E2 node the file does not name %+v, want kpm-v1-report of RT-Period-IE 13", profile)
+	}
+	if fallback := profiles.Named(profiles.Named("kpm-e2-node-measurements").Fallback); fallback == nil || fallback.rtPeriod != -1 {
+		t.Errorf("fallback profile %+v, want kpm-e2-node-volume of a period of v2/v3 only", fallback)
+	}
+	if _, err := LoadSubscriptionProfiles("../subscriptions/profiles.txt"); err == nil {
+		t.Error("profile file of an unknown format loaded")
+	}
+}
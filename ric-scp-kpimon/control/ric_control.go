@@ -0,0 +1,266 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const DEFAULT_CONTROL_TIMEOUT = 5 * time.Second
+
+var controlAckRequests = []string{CONTROL_ACK_NONE, CONTROL_ACK, CONTROL_NACK}
+var controlStatusNames = []string{"success", "rejected", "failed"}
+
+var errControlTimeout = errors.New("no RIC control answer before the timeout")
+
+type ControlConfig struct {
+	RequestorID int           //RIC requestor ID of the RIC Control Requests, SUBSCRIPTION_REQUESTOR_ID when 0
+	Timeout     time.Duration //time an E2 node has to answer a RIC Control Request, DEFAULT_CONTROL_TIMEOUT when 0
+}
+
+// LoadControlConfig reads the RIC control configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadControlConfig() (ControlConfig, error) {
+	var cfg ControlConfig
+	if str := os.Getenv("controlRequestorId"); str != "" {
+		id, err := strconv.Atoi(str)
+		if err != nil || id < 0 || id > 65535 {
+			return cfg, errors.New("Invalid controlRequestorId: " + str)
+		}
+		cfg.RequestorID = id
+	}
+	if str := os.Getenv("controlTimeout"); str != "" {
+		d, err := time.ParseDuration(str)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("Invalid controlTimeout: " + str)
+		}
+		cfg.Timeout = d
+	}
+	return cfg, nil
+}
+
+// ControlRequest is a RIC Control Request to an E2 node, its header and message encoded in the E2SM
+// of the RAN function
+type ControlRequest struct {
+	RanName       string
+	FuncID        int
+	Header        []byte
+	Message       []byte
+	CallProcessID []byte        //nil for none
+	AckRequest    string        //none, ack or nack, ack when empty
+	Timeout       time.Duration //the configured timeout when 0
+}
+
+// ControlOutcome is what became of a RIC Control Request
+type ControlOutcome struct {
+	RanName       string        `json:"ran_name"`
+	RequestID     int           `json:"request_id"`
+	RequestSN     int           `json:"request_sn"`
+	FuncID        int           `json:"ran_function_id"`
+	Result        string        `json:"result"`           //acknowledged, failed, timed out or sent
+	Status        string        `json:"status,omitempty"` //RICcontrolStatus of an acknowledgement: success, rejected or failed
+	Cause         string        `json:"cause,omitempty"`  //cause of a failure
+	CallProcessID []byte        `json:"call_process_id,omitempty"`
+	Outcome       []byte        `json:"outcome,omitempty"` //RICcontrolOutcome, in the E2SM of the RAN function
+	Elapsed       time.Duration `json:"elapsed"`
+}
+
+type controlKey struct {
+	ranName   string
+	requestSN int
+}
+
+// RicControl sends the RIC Control Requests of kpimon over the transport and pairs each with the
+// RIC_CONTROL_ACK or RIC_CONTROL_FAILURE answering it by RAN name and RIC request sequence number
+type RicControl struct {
+	cfg       ControlConfig
+	transport Transport
+	pending   map[controlKey]chan *ControlOutcome
+	next      int              //RIC request sequence number of the next request
+	counts    map[string]int64 //RIC Control Requests per result, answers matching no request included
+	mu        *sync.Mutex
+}
+
+func NewRicControl(cfg ControlConfig, transport Transport) *RicControl {
+	if cfg.RequestorID == 0 {
+		cfg.RequestorID = SUBSCRIPTION_REQUESTOR_ID
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DEFAULT_CONTROL_TIMEOUT
+	}
+	return &RicControl{
+		cfg:       cfg,
+		transport: transport,
+		pending:   make(map[controlKey]chan *ControlOutcome),
+		next:      1,
+		counts:    make(map[string]int64),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// Send sends a RIC Control Request and waits for its answer when it asks for one. It fails when the
+// request could not be sent or was not answered in time; a RIC_CONTROL_FAILURE is an outcome, not an error.
+func (r *RicControl) Send(req *ControlRequest) (*ControlOutcome, error) {
+	var e2ap *E2ap
+
+	ackRequest := -1
+	for i, name := range controlAckRequests {
+		if req.AckRequest == name || (req.AckRequest == "" && name == CONTROL_ACK) {
+			ackRequest = i
+		}
+	}
+	if ackRequest < 0 {
+		return nil, errors.New("Unknown RIC control ack request: " + req.AckRequest)
+	}
+	if req.FuncID < 0 || req.FuncID > 4095 {
+		return nil, fmt.Errorf("RAN function ID %d is not in 0..4095", req.FuncID)
+	}
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = r.cfg.Timeout
+	}
+
+	r.mu.Lock()
+	key := controlKey{req.RanName, r.next}
+	for r.pending[key] != nil {
+		key.requestSN = key.requestSN%65535 + 1
+	}
+	r.next = key.requestSN%65535 + 1
+	answer := make(chan *ControlOutcome, 1)
+	r.pending[key] = answer
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, key)
+		r.mu.Unlock()
+	}()
+
+	outcome := &ControlOutcome{RanName: req.RanName, RequestID: r.cfg.RequestorID, RequestSN: key.requestSN, FuncID: req.FuncID, CallProcessID: req.CallProcessID}
+	payload, err := e2ap.SetControlRequestPayload(make([]byte, 1024+len(req.Header)+len(req.Message)), &DecodedControlRequestMessage{
+		RequestID:             int32(r.cfg.RequestorID),
+		RequestSequenceNumber: int32(key.requestSN),
+		FuncID:                int32(req.FuncID),
+		CallProcessID:         req.CallProcessID,
+		ControlHeader:         req.Header,
+		ControlMessage:        req.Message,
+		ControlAckRequest:     int32(ackRequest),
+	})
+	if err != nil {
+		return nil, err
+	}
+	params := &xapp.RMRParams{Mtype: RIC_CONTROL_REQ, SubId: -1, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: req.RanName}}
+	xapp.Logger.Debug("Send RIC_CONTROL_REQ %d/%d to {%s}, RAN function %d, %s", outcome.RequestID, outcome.RequestSN, req.RanName, req.FuncID, controlAckRequests[ackRequest])
+	log.Printf("Send RIC_CONTROL_REQ %d/%d to {%s}, RAN function %d, %s", outcome.RequestID, outcome.RequestSN, req.RanName, req.FuncID, controlAckRequests[ackRequest])
+	xapp.Logger.Debug("Set Payload: %x", params.Payload)
+
+	start := time.Now()
+	if err = r.transport.Send(params); err != nil {
+		xapp.Logger.Error("Failed to send RIC_CONTROL_REQ: %v", err)
+		log.Printf("Failed to send RIC_CONTROL_REQ: %v", err)
+		return nil, err
+	}
+	if controlAckRequests[ackRequest] == CONTROL_ACK_NONE {
+		outcome.Result = CONTROL_SENT
+		r.count(outcome.Result)
+		return outcome, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case answered := <-answer:
+		answered.RequestID, answered.RequestSN, answered.FuncID = outcome.RequestID, outcome.RequestSN, outcome.FuncID
+		answered.Elapsed = time.Since(start)
+		r.count(answered.Result)
+		return answered, nil
+	case <-timer.C:
+		outcome.Elapsed = time.Since(start)
+		if controlAckRequests[ackRequest] == CONTROL_NACK {
+			outcome.Result = CONTROL_SENT //a nAck request is only answered when it failed
+			r.count(outcome.Result)
+			return outcome, nil
+		}
+		outcome.Result = CONTROL_TIMED_OUT
+		r.count(outcome.Result)
+		xapp.Logger.Warn("RIC_CONTROL_REQ %d/%d to {%s} not answered in %v", outcome.RequestID, outcome.RequestSN, req.RanName, timeout)
+		log.Printf("RIC_CONTROL_REQ %d/%d to {%s} not answered in %v", outcome.RequestID, outcome.RequestSN, req.RanName, timeout)
+		return outcome, errControlTimeout
+	}
+}
+
+// Acknowledged hands a RIC_CONTROL_ACK of an E2 node to the request it answers
+func (r *RicControl) Acknowledged(ranName string, msg *DecodedControlAcknowledgeMessage) {
+	outcome := &ControlOutcome{RanName: ranName, Result: CONTROL_ACKNOWLEDGED, Status: enumName(controlStatusNames, msg.ControlStatus),
+		CallProcessID: msg.CallProcessID, Outcome: msg.ControlOutcome}
+	if !r.answer(ranName, msg.RequestID, msg.RequestSequenceNumber, outcome) {
+		r.unmatched(ranName, msg.RequestID, msg.RequestSequenceNumber)
+	}
+}
+
+// Failed hands a RIC_CONTROL_FAILURE of an E2 node to the request it answers
+func (r *RicControl) Failed(ranName string, msg *DecodedControlFailureMessage) {
+	outcome := &ControlOutcome{RanName: ranName, Result: CONTROL_FAILED, Cause: CauseName(msg.Cause),
+		CallProcessID: msg.CallProcessID, Outcome: msg.ControlOutcome}
+	if !r.answer(ranName, msg.RequestID, msg.RequestSequenceNumber, outcome) {
+		r.unmatched(ranName, msg.RequestID, msg.RequestSequenceNumber)
+	}
+}
+
+// ErrorIndicated fails the pending request an Error Indication of an E2 node is about, and tells whether
+// there was one
+func (r *RicControl) ErrorIndicated(ranName string, requestID int32, requestSN int32, cause string) bool {
+	return r.answer(ranName, requestID, requestSN, &ControlOutcome{RanName: ranName, Result: CONTROL_FAILED, Cause: cause})
+}
+
+func (r *RicControl) answer(ranName string, requestID int32, requestSN int32, outcome *ControlOutcome) bool {
+	r.mu.Lock()
+	key := controlKey{ranName, int(requestSN)}
+	answer := r.pending[key]
+	if int(requestID) != r.cfg.RequestorID {
+		answer = nil
+	}
+	if answer != nil {
+		delete(r.pending, key)
+	}
+	r.mu.Unlock()
+	if answer == nil {
+		return false
+	}
+	answer <- outcome
+	return true
+}
+
+func (r *RicControl) unmatched(ranName string, requestID int32, requestSN int32) {
+	r.count("unmatched")
+	xapp.Logger.Warn("RIC control answer %d/%d from {%s} matches no pending request", requestID, requestSN, ranName)
+	log.Printf("RIC control answer %d/%d from {%s} matches no pending request", requestID, requestSN, ranName)
+}
+
+func (r *RicControl) count(result string) {
+	r.mu.Lock()
+	r.counts[result]++
+	r.mu.Unlock()
+}
+
+// Counts returns the number of RIC Control Requests per result since start
+func (r *RicControl) Counts() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int64, len(r.counts))
+	for result, n := range r.counts {
+		counts[result] = n
+	}
+	return counts
+}
+
+// Pending returns the number of RIC Control Requests waiting for their answer
+func (r *RicControl) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
@@ -0,0 +1,152 @@
+package control
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const CONTROL_RAN_NAME = "gnb_control"
+
+type controlResult struct {
+	outcome *ControlOutcome
+	err     error
+}
+
+// sendControl sends req over a ChanTransport and hands the RIC Control Request that went out to answer,
+// which answers it as the E2 node would, or not at all
+func sendControl(t *testing.T, c *Control, transport *ChanTransport, req *ControlRequest, answer func(sent *DecodedControlRequestMessage)) (*ControlOutcome, error) {
+	t.Helper()
+	var e2ap *E2ap
+
+	done := make(chan controlResult, 1)
+	go func() {
+		outcome, err := c.ricControl.Send(req)
+		done <- controlResult{outcome, err}
+	}()
+	select {
+	case params := <-transport.Sent():
+		if params.Mtype != RIC_CONTROL_REQ || params.Meid.RanName != req.RanName {
+			t.Fatalf("sent message type %d to {%s}, want RIC_CONTROL_REQ to {%s}", params.Mtype, params.Meid.RanName, req.RanName)
+		}
+		sent, err := e2ap.GetControlRequestMessage(params.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sent.ControlHeader, req.Header) || !bytes.Equal(sent.ControlMessage, req.Message) || int(sent.FuncID) != req.FuncID {
+			t.Fatalf("sent %+v, want the header, message and RAN function of %+v", sent, req)
+		}
+		if answer != nil {
+			answer(sent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no RIC_CONTROL_REQ sent")
+	}
+	result := <-done
+	return result.outcome, result.err
+}
+
+// acknowledge delivers the RIC_CONTROL_ACK of a request to the control loop handler
+func acknowledge(t *testing.T, c *Control, msg *DecodedControlAcknowledgeMessage) {
+	t.Helper()
+	var e2ap *E2ap
+	payload, err := e2ap.SetControlAcknowledgePayload(make([]byte, 1024), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.handleControlAcknowledge(&xapp.RMRParams{Mtype: RIC_CONTROL_ACK, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: CONTROL_RAN_NAME}})
+}
+
+func TestRicControlSend(t *testing.T) {
+	var e2ap *E2ap
+	transport := NewChanTransport(1)
+	c := NewControlWith([]string{CONTROL_RAN_NAME}, NewMemoryMetricsStore(), transport, nil, nil, nil, nil, nil, SubscriptionConfig{})
+	request := func(ackRequest string) *ControlRequest {
+		return &ControlRequest{RanName: CONTROL_RAN_NAME, FuncID: 3, Header: []byte{0x01, 0x02}, Message: []byte{0x03},
+			CallProcessID: []byte{0xca, 0x11}, AckRequest: ackRequest, Timeout: 100 * time.Millisecond}
+	}
+
+	outcome, err := sendControl(t, &c, transport, request(""), func(sent *DecodedControlRequestMessage) {
+		if sent.ControlAckRequest != 1 {
+			t.Errorf("ack request %d, want ack (1) by default", sent.ControlAckRequest)
+		}
+		acknowledge(t, &c, &DecodedControlAcknowledgeMessage{RequestID: sent.RequestID, RequestSequenceNumber: sent.RequestSequenceNumber,
+			FuncID: sent.FuncID, CallProcessID: sent.CallProcessID, ControlStatus: 0, ControlOutcome: []byte{0x0f}})
+	})
+	if err != nil || outcome.Result != CONTROL_ACKNOWLEDGED || outcome.Status != "success" || !bytes.Equal(outcome.Outcome, []byte{0x0f}) {
+		t.Errorf("acknowledged request: %+v, %v", outcome, err)
+	}
+	if outcome.RequestID != SUBSCRIPTION_REQUESTOR_ID || outcome.RequestSN != 1 || outcome.FuncID != 3 || !bytes.Equal(outcome.CallProcessID, []byte{0xca, 0x11}) {
+		t.Errorf("acknowledged request %d/%d, RAN function %d, call process %x", outcome.RequestID, outcome.RequestSN, outcome.FuncID, outcome.CallProcessID)
+	}
+
+	outcome, err = sendControl(t, &c, transport, request(CONTROL_ACK), func(sent *DecodedControlRequestMessage) {
+		payload, err := e2ap.SetControlFailurePayload(make([]byte, 1024), &DecodedControlFailureMessage{RequestID: sent.RequestID,
+			RequestSequenceNumber: sent.RequestSequenceNumber, FuncID: sent.FuncID, Cause: CauseItemType{CauseType: CAUSE_RIC_REQUEST, CauseID: CAUSE_CONTROL_MESSAGE_INVALID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.handleControlFailure(&xapp.RMRParams{Mtype: RIC_CONTROL_FAILURE, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: CONTROL_RAN_NAME}})
+	})
+	if err != nil || outcome.Result != CONTROL_FAILED || outcome.Cause != "ricRequest/control-message-invalid" || outcome.RequestSN != 2 {
+		t.Errorf("failed request: %+v, %v", outcome, err)
+	}
+
+	outcome, err = sendControl(t, &c, transport, request(CONTROL_ACK_NONE), func(sent *DecodedControlRequestMessage) {
+		if sent.ControlAckRequest != 0 {
+			t.Errorf("ack request %d, want noAck (0)", sent.ControlAckRequest)
+		}
+	})
+	if err != nil || outcome.Result != CONTROL_SENT {
+		t.Errorf("request without ack: %+v, %v", outcome, err)
+	}
+
+	outcome, err = sendControl(t, &c, transport, request(CONTROL_NACK), nil)
+	if err != nil || outcome.Result != CONTROL_SENT || outcome.Elapsed < 100*time.Millisecond {
+		t.Errorf("nAck request left unanswered: %+v, %v", outcome, err)
+	}
+
+	var late *DecodedControlRequestMessage
+	outcome, err = sendControl(t, &c, transport, request(CONTROL_ACK), func(sent *DecodedControlRequestMessage) { late = sent })
+	if err != errControlTimeout || outcome.Result != CONTROL_TIMED_OUT {
+		t.Errorf("ack request left unanswered: %+v, %v", outcome, err)
+	}
+
+	//an answer after the timeout, an answer to another requestor and one to no request match nothing
+	acknowledge(t, &c, &DecodedControlAcknowledgeMessage{RequestID: late.RequestID, RequestSequenceNumber: late.RequestSequenceNumber, FuncID: late.FuncID})
+	acknowledge(t, &c, &DecodedControlAcknowledgeMessage{RequestID: late.RequestID + 1, RequestSequenceNumber: 1, FuncID: late.FuncID})
+	acknowledge(t, &c, &DecodedControlAcknowledgeMessage{RequestID: late.RequestID, RequestSequenceNumber: 999, FuncID: late.FuncID})
+
+	counts := c.ricControl.Counts()
+	want := map[string]int64{CONTROL_ACKNOWLEDGED: 1, CONTROL_FAILED: 1, CONTROL_SENT: 2, CONTROL_TIMED_OUT: 1, "unmatched": 3}
+	for result, n := range want {
+		if counts[result] != n {
+			t.Errorf("%d requests %s, want %d", counts[result], result, n)
+		}
+	}
+	if pending := c.ricControl.Pending(); pending != 0 {
+		t.Errorf("%d requests still pending", pending)
+	}
+}
+
+func TestRicControlSendInvalid(t *testing.T) {
+	transport := NewChanTransport(0) //unbuffered, a send fails once closed
+	r := NewRicControl(ControlConfig{}, transport)
+	for _, req := range []*ControlRequest{
+		{RanName: CONTROL_RAN_NAME, AckRequest: "always"},
+		{RanName: CONTROL_RAN_NAME, FuncID: 4096},
+	} {
+		if outcome, err := r.Send(req); err == nil {
+			t.Errorf("request %+v sent: %+v", req, outcome)
+		}
+	}
+	transport.Close()
+	if _, err := r.Send(&ControlRequest{RanName: CONTROL_RAN_NAME}); err == nil {
+		t.Error("request sent over a closed transport")
+	}
+	if pending := r.Pending(); pending != 0 {
+		t.Errorf("%d requests still pending", pending)
+	}
+}
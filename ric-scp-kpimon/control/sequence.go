@@ -0,0 +1,234 @@
+package control
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// What a RIC Indication is to the sequence of its subscription
+const (
+	SEQUENCE_IN_ORDER   = "in_order"   //the RICindicationSN following the highest one received
+	SEQUENCE_GAP        = "gap"        //a RICindicationSN further ahead, the ones skipped are missing
+	SEQUENCE_REORDERED  = "reordered"  //a missing RICindicationSN arriving late
+	SEQUENCE_DUPLICATE  = "duplicate"  //a RICindicationSN received before
+	SEQUENCE_RESTART    = "restart"    //a RICindicationSN too far behind to be late, the E2 node numbers anew
+	SEQUENCE_UNNUMBERED = "unnumbered" //no RICindicationSN, which is optional
+
+	DEFAULT_SEQUENCE_WINDOW = 256 //RICindicationSNs behind the highest one that count as late or duplicate
+)
+
+type SequenceConfig struct {
+	DropDuplicates bool //leave the duplicate RIC Indications out of the metrics store
+	Window         int  //RICindicationSNs behind the highest one still tracked
+}
+
+// LoadSequenceConfig reads the indication sequence tracking configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadSequenceConfig() SequenceConfig {
+	cfg := SequenceConfig{Window: DEFAULT_SEQUENCE_WINDOW}
+	cfg.DropDuplicates, _ = strconv.ParseBool(os.Getenv("indicationDropDuplicates"))
+	if window, err := strconv.Atoi(os.Getenv("indicationSequenceWindow")); err == nil && window > 0 {
+		cfg.Window = window
+	}
+	return cfg
+}
+
+// SequenceStats counts the RIC Indications of a subscription by what they were to its sequence
+type SequenceStats struct {
+	RanName     string `json:"ran_name"`
+	RequestID   int32  `json:"request_id"`
+	RequestSN   int32  `json:"request_sn"`
+	ActionID    int32  `json:"action_id"`
+	Highest     int32  `json:"highest_sn"` //-1 before the first numbered RIC Indication
+	Indications int64  `json:"indications"`
+	InOrder     int64  `json:"in_order"`
+	Gaps        int64  `json:"gaps"`        //RIC Indications that skipped RICindicationSNs
+	Missing     int64  `json:"missing"`     //RICindicationSNs skipped by the gaps
+	Outstanding int    `json:"outstanding"` //RICindicationSNs still missing within the window
+	Reordered   int64  `json:"reordered"`
+	Duplicates  int64  `json:"duplicates"`
+	Dropped     int64  `json:"dropped"` //duplicates left out of the metrics store
+	Restarts    int64  `json:"restarts"`
+	Unnumbered  int64  `json:"unnumbered"`
+}
+
+// sequenceKey identifies the indications of one action of a subscription
+type sequenceKey struct {
+	ranName   string
+	requestID int32
+	requestSN int32
+	actionID  int32
+}
+
+type sequence struct {
+	stats   SequenceStats
+	missing map[int32]bool //RICindicationSNs skipped and not received yet, within the window
+}
+
+// IndicationSequences tracks the RICindicationSNs of the RIC Indications of each subscription action and
+// tells the gaps, late arrivals and duplicates apart. The RICindicationSN wraps around after 65535.
+type IndicationSequences struct {
+	cfg       SequenceConfig
+	sequences map[sequenceKey]*sequence
+	totals    map[string]int64 //RIC Indications per kind since start, missing and dropped included
+	mu        *sync.Mutex
+}
+
+func NewIndicationSequences(cfg SequenceConfig) *IndicationSequences {
+	if cfg.Window <= 0 {
+		cfg.Window = DEFAULT_SEQUENCE_WINDOW
+	} else if cfg.Window > (MAX_RIC_INDICATION_SN+1)/2 {
+		cfg.Window = (MAX_RIC_INDICATION_SN + 1) / 2
+	}
+	return &IndicationSequences{
+		cfg:       cfg,
+		sequences: make(map[sequenceKey]*sequence),
+		totals:    make(map[string]int64),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// Observe records a RIC Indication of an E2 node and returns what it is to the sequence of its
+// subscription, and whether it is to be left out of the metrics store
+func (s *IndicationSequences) Observe(ranName string, msg *DecodedIndicationMessage) (kind string, drop bool) {
+	key := sequenceKey{ranName, msg.RequestID, msg.RequestSequenceNumber, msg.ActionID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq, ok := s.sequences[key]
+	if !ok {
+		seq = &sequence{stats: SequenceStats{RanName: ranName, RequestID: msg.RequestID, RequestSN: msg.RequestSequenceNumber,
+			ActionID: msg.ActionID, Highest: -1}, missing: make(map[int32]bool)}
+		s.sequences[key] = seq
+	}
+	stats := &seq.stats
+	stats.Indications++
+
+	sn := msg.IndSN
+	previous := stats.Highest
+	switch {
+	case sn < 0:
+		kind = SEQUENCE_UNNUMBERED
+		stats.Unnumbered++
+	case stats.Highest < 0:
+		kind = SEQUENCE_IN_ORDER
+		stats.InOrder++
+		stats.Highest = sn
+	default:
+		ahead := snDistance(stats.Highest, sn)
+		switch {
+		case ahead == 1:
+			kind = SEQUENCE_IN_ORDER
+			stats.InOrder++
+			stats.Highest = sn
+		case ahead > 1 && ahead <= (MAX_RIC_INDICATION_SN+1)/2:
+			kind = SEQUENCE_GAP
+			stats.Gaps++
+			stats.Missing += int64(ahead - 1)
+			for skipped := ahead - 1; skipped >= 1 && ahead-skipped <= int32(s.cfg.Window); skipped-- {
+				seq.missing[snAdd(stats.Highest, skipped)] = true
+			}
+			stats.Highest = sn
+		case seq.missing[sn]:
+			kind = SEQUENCE_REORDERED
+			stats.Reordered++
+			delete(seq.missing, sn)
+		case ahead == 0 || MAX_RIC_INDICATION_SN+1-ahead <= int32(s.cfg.Window):
+			kind = SEQUENCE_DUPLICATE
+			stats.Duplicates++
+			drop = s.cfg.DropDuplicates
+			if drop {
+				stats.Dropped++
+			}
+		default:
+			kind = SEQUENCE_RESTART
+			stats.Restarts++
+			stats.Highest = sn
+			seq.missing = make(map[int32]bool)
+		}
+	}
+	for missing := range seq.missing {
+		if snDistance(missing, stats.Highest) > int32(s.cfg.Window) {
+			delete(seq.missing, missing)
+		}
+	}
+	stats.Outstanding = len(seq.missing)
+	s.totals[kind]++
+	if kind == SEQUENCE_GAP {
+		s.totals["missing"] += int64(snDistance(previous, sn) - 1)
+	}
+	if drop {
+		s.totals["dropped"]++
+	}
+
+	switch kind {
+	case SEQUENCE_GAP:
+		xapp.Logger.Warn("RIC Indication %d from {%s} follows %d, %d missing (request %d/%d, action %d)", sn, ranName, previous,
+			snDistance(previous, sn)-1, msg.RequestID, msg.RequestSequenceNumber, msg.ActionID)
+		log.Printf("RIC Indication %d from {%s} follows %d, %d missing (request %d/%d, action %d)", sn, ranName, previous,
+			snDistance(previous, sn)-1, msg.RequestID, msg.RequestSequenceNumber, msg.ActionID)
+	case SEQUENCE_REORDERED, SEQUENCE_DUPLICATE, SEQUENCE_RESTART:
+		xapp.Logger.Info("RIC Indication %d from {%s} is %s (request %d/%d, action %d)", sn, ranName, kind, msg.RequestID, msg.RequestSequenceNumber, msg.ActionID)
+		log.Printf("RIC Indication %d from {%s} is %s (request %d/%d, action %d)", sn, ranName, kind, msg.RequestID, msg.RequestSequenceNumber, msg.ActionID)
+	}
+	return
+}
+
+// Reset forgets the sequences of an E2 node, whose next subscription numbers its indications anew
+func (s *IndicationSequences) Reset(ranName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.sequences {
+		if key.ranName == ranName {
+			delete(s.sequences, key)
+		}
+	}
+}
+
+// Stats returns the counters of every subscription action, sorted by RAN name, request and action
+func (s *IndicationSequences) Stats() []SequenceStats {
+	s.mu.Lock()
+	stats := make([]SequenceStats, 0, len(s.sequences))
+	for _, seq := range s.sequences {
+		stats = append(stats, seq.stats)
+	}
+	s.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.RanName != b.RanName {
+			return a.RanName < b.RanName
+		}
+		if a.RequestID != b.RequestID {
+			return a.RequestID < b.RequestID
+		}
+		if a.RequestSN != b.RequestSN {
+			return a.RequestSN < b.RequestSN
+		}
+		return a.ActionID < b.ActionID
+	})
+	return stats
+}
+
+// Counts returns the number of RIC Indications of each kind since start, over every subscription, along
+// with the RICindicationSNs missing and the duplicates dropped
+func (s *IndicationSequences) Counts() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int64, len(s.totals))
+	for kind, n := range s.totals {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// snDistance returns how far to ahead of from a RICindicationSN is, modulo the RICindicationSN range
+func snDistance(from int32, to int32) int32 {
+	return (to - from + MAX_RIC_INDICATION_SN + 1) % (MAX_RIC_INDICATION_SN + 1)
+}
+
+func snAdd(sn int32, n int32) int32 {
+	return (sn + n) % (MAX_RIC_INDICATION_SN + 1)
+}
@@ -0,0 +1,80 @@
+package control
+
+import (
+	"testing"
+)
+
+func sequenceIndication(actionID int32, sn int32) *DecodedIndicationMessage {
+	return &DecodedIndicationMessage{RequestID: SUBSCRIPTION_REQUESTOR_ID, RequestSequenceNumber: SUBSCRIPTION_INSTANCE_ID, ActionID: actionID, IndSN: sn}
+}
+
+func TestSequenceObserve(t *testing.T) {
+	s := NewIndicationSequences(SequenceConfig{DropDuplicates: true, Window: 8})
+	for i, test := range []struct {
+		name   string
+		action int32
+		sn     int32 //-1 for none
+		kind   string
+		drop   bool
+	}{
+		{"first", 1, 1, SEQUENCE_IN_ORDER, false},
+		{"next", 1, 2, SEQUENCE_IN_ORDER, false},
+		{"gap of 3 and 4", 1, 5, SEQUENCE_GAP, false},
+		{"late 3", 1, 3, SEQUENCE_REORDERED, false},
+		{"3 again", 1, 3, SEQUENCE_DUPLICATE, true},
+		{"no RICindicationSN", 1, -1, SEQUENCE_UNNUMBERED, false},
+		{"gap beyond the window", 1, 20, SEQUENCE_GAP, false},
+		{"late 12, within the window", 1, 12, SEQUENCE_REORDERED, false},
+		{"next after the gap", 1, 21, SEQUENCE_IN_ORDER, false},
+		{"late 4, pruned from the window", 1, 4, SEQUENCE_RESTART, false},
+		{"far jump", 1, 40000, SEQUENCE_RESTART, false},
+		{"first of another action", 2, 65534, SEQUENCE_IN_ORDER, false},
+		{"last RICindicationSN", 2, 65535, SEQUENCE_IN_ORDER, false},
+		{"wraparound", 2, 0, SEQUENCE_IN_ORDER, false},
+		{"gap of 1 and 2", 2, 3, SEQUENCE_GAP, false},
+		{"late 1", 2, 1, SEQUENCE_REORDERED, false},
+		{"65535 again", 2, 65535, SEQUENCE_DUPLICATE, true},
+	} {
+		kind, drop := s.Observe("gnb_1", sequenceIndication(test.action, test.sn))
+		if kind != test.kind || drop != test.drop {
+			t.Errorf("%d %s: RICindicationSN %d %s, drop %v, want %s, drop %v", i, test.name, test.sn, kind, drop, test.kind, test.drop)
+		}
+	}
+
+	checkDecoded(t, "sequence stats", s.Stats(), []SequenceStats{
+		{RanName: "gnb_1", RequestID: SUBSCRIPTION_REQUESTOR_ID, RequestSN: SUBSCRIPTION_INSTANCE_ID, ActionID: 1, Highest: 40000,
+			Indications: 11, InOrder: 3, Gaps: 2, Missing: 16, Outstanding: 0, Reordered: 2, Duplicates: 1, Dropped: 1, Restarts: 2, Unnumbered: 1},
+		{RanName: "gnb_1", RequestID: SUBSCRIPTION_REQUESTOR_ID, RequestSN: SUBSCRIPTION_INSTANCE_ID, ActionID: 2, Highest: 3,
+			Indications: 6, InOrder: 3, Gaps: 1, Missing: 2, Outstanding: 1, Reordered: 1, Duplicates: 1, Dropped: 1},
+	})
+	checkDecoded(t, "sequence counts", s.Counts(), map[string]int64{
+		SEQUENCE_IN_ORDER: 6, SEQUENCE_GAP: 3, SEQUENCE_REORDERED: 3, SEQUENCE_DUPLICATE: 2, SEQUENCE_RESTART: 2, SEQUENCE_UNNUMBERED: 1,
+		"missing": 18, "dropped": 2,
+	})
+
+	//the next subscription numbers anew, the totals since start stay
+	s.Reset("gnb_1")
+	if stats := s.Stats(); len(stats) != 0 {
+		t.Errorf("sequences %+v left after the reset", stats)
+	}
+	if kind, _ := s.Observe("gnb_1", sequenceIndication(1, 3)); kind != SEQUENCE_IN_ORDER {
+		t.Errorf("first RIC Indication after the reset %s", kind)
+	}
+	if counts := s.Counts(); counts[SEQUENCE_IN_ORDER] != 7 {
+		t.Errorf("counts %v after the reset", counts)
+	}
+}
+
+func TestSequenceConfig(t *testing.T) {
+	s := NewIndicationSequences(SequenceConfig{})
+	if s.cfg.Window != DEFAULT_SEQUENCE_WINDOW {
+		t.Errorf("window %d, want %d", s.cfg.Window, DEFAULT_SEQUENCE_WINDOW)
+	}
+	s.Observe("gnb_1", sequenceIndication(1, 7))
+	if kind, drop := s.Observe("gnb_1", sequenceIndication(1, 7)); kind != SEQUENCE_DUPLICATE || drop {
+		t.Errorf("duplicate %s, drop %v, want kept without DropDuplicates", kind, drop)
+	}
+	if s := NewIndicationSequences(SequenceConfig{Window: MAX_RIC_INDICATION_SN}); s.cfg.Window != (MAX_RIC_INDICATION_SN+1)/2 {
+		t.Errorf("window %d, want half the RICindicationSN range", s.cfg.Window)
+	}
+}
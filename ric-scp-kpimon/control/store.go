@@ -0,0 +1,273 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+)
+
+var ErrEntryNotFound = errors.New("metrics entry not found")
+
+// MetricsStore is the storage backend for the UE and cell metrics written by handleIndication
+type MetricsStore interface {
+	GetUe(ueID string) (*UeMetricsEntry, error)
+	PutUe(ueID string, ueMetrics *UeMetricsEntry) error
+	DeleteUe(ueID string) error
+	ExistsUe(ueID string) (bool, error)
+	ListUes() ([]string, error)
+	GetUes(ueIDs []string) (map[string]*UeMetricsEntry, error)
+	GetCell(cellID string) (*CellMetricsEntry, error)
+	PutCell(cellID string, cellMetrics *CellMetricsEntry) error
+	DeleteCell(cellID string) error
+	ExistsCell(cellID string) (bool, error)
+	WriteBatch(batch *MetricsBatch) error
+	Ping() error
+	Close() error
+}
+
+// MetricsBatch collects UE and cell entries that are written to the store in one round trip
+type MetricsBatch struct {
+	Ues   map[string]*UeMetricsEntry
+	Cells map[string]*CellMetricsEntry
+}
+
+func NewMetricsBatch() *MetricsBatch {
+	return &MetricsBatch{
+		Ues:   make(map[string]*UeMetricsEntry),
+		Cells: make(map[string]*CellMetricsEntry),
+	}
+}
+
+func (b *MetricsBatch) PutUe(ueID string, ueMetrics *UeMetricsEntry) {
+	b.Ues[ueID] = ueMetrics
+}
+
+func (b *MetricsBatch) PutCell(cellID string, cellMetrics *CellMetricsEntry) {
+	b.Cells[cellID] = cellMetrics
+}
+
+func (b *MetricsBatch) Len() int {
+	return len(b.Ues) + len(b.Cells)
+}
+
+// encode flattens the batch into the key/value pairs stored by the backend
+func (b *MetricsBatch) encode() (pairs map[string][]byte, err error) {
+	pairs = make(map[string][]byte, b.Len())
+	for ueID, ueMetrics := range b.Ues {
+		if pairs[ueID], err = json.Marshal(ueMetrics); err != nil {
+			return nil, err
+		}
+	}
+	for cellID, cellMetrics := range b.Cells {
+		if pairs[cellID], err = json.Marshal(cellMetrics); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+type StoreConfig struct {
+	Backend        string //redis, memory or sdl
+	RedisAddr      string //address of the redis server used by the redis backend
+	RedisPassword  string //password of the redis server used by the redis backend
+	RedisDB        int    //database index used by the redis backend
+	SdlNamespace   string //namespace used by the sdl backend
+	SigningMode    string //off, sign or enforce
+	SigningKeyFile string //key file of the signed envelopes, see envelope.KeyFile
+}
+
+// LoadStoreConfig reads the store configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadStoreConfig() StoreConfig {
+	cfg := StoreConfig{
+		Backend:        os.Getenv("storeBackend"),
+		RedisAddr:      os.Getenv("redisAddr"),
+		RedisPassword:  os.Getenv("redisPassword"),
+		SdlNamespace:   os.Getenv("sdlNamespace"),
+		SigningMode:    os.Getenv("signingMode"),
+		SigningKeyFile: os.Getenv("signingKeyFile"),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = STORE_BACKEND_REDIS
+	}
+	if cfg.RedisAddr == "" {
+		cfg.RedisAddr = DEFAULT_REDIS_ADDR
+	}
+	if db, err := strconv.Atoi(os.Getenv("redisDB")); err == nil {
+		cfg.RedisDB = db
+	}
+	if cfg.SdlNamespace == "" {
+		cfg.SdlNamespace = DEFAULT_SDL_NAMESPACE
+	}
+	if cfg.SigningMode == "" {
+		cfg.SigningMode = SIGNING_OFF
+	}
+	return cfg
+}
+
+func NewMetricsStore(cfg StoreConfig) (MetricsStore, error) {
+	var kv kvStore
+	switch cfg.Backend {
+	case STORE_BACKEND_REDIS:
+		kv = newRedisKVStore(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	case STORE_BACKEND_MEMORY:
+		kv = newMemoryKVStore()
+	case STORE_BACKEND_SDL:
+		kv = newSdlKVStore(cfg.SdlNamespace)
+	default:
+		return nil, errors.New("Unknown metrics store backend: " + cfg.Backend)
+	}
+	switch cfg.SigningMode {
+	case SIGNING_OFF, "":
+	case SIGNING_SIGN, SIGNING_ENFORCE:
+		signed, err := newSignedKVStore(kv, cfg.SigningKeyFile, cfg.SigningMode == SIGNING_ENFORCE)
+		if err != nil {
+			kv.close()
+			return nil, err
+		}
+		kv = signed
+	default:
+		kv.close()
+		return nil, errors.New("Unknown signing mode: " + cfg.SigningMode)
+	}
+	return &kvMetricsStore{kv}, nil
+}
+
+// ForeignView returns a view of store writing plain, unsigned records, as an xApp without the
+// signing key would. Signed records are still read, without being verified.
+func ForeignView(store MetricsStore) MetricsStore {
+	if s, ok := store.(*kvMetricsStore); ok {
+		if signed, ok := s.kv.(*signedKVStore); ok {
+			return &kvMetricsStore{&signedKVStore{signed.kvStore, nil, nil, nil, false}}
+		}
+	}
+	return store
+}
+
+// kvStore is the raw key/value interface each backend provides, values are the JSON encoded entries
+type kvStore interface {
+	get(key string) ([]byte, error)
+	set(pairs map[string][]byte) error
+	del(key string) error
+	exists(key string) (bool, error)
+	getAll(keys []string) (map[string][]byte, error) //values of the keys found, read in one round trip
+	index(add []string, remove []string) error       //adds and removes members of the UE index
+	indexed() ([]string, error)                      //members of the UE index
+	ping() error
+	close() error
+}
+
+// kvMetricsStore implements MetricsStore on top of a kvStore backend
+type kvMetricsStore struct {
+	kv kvStore
+}
+
+func (s *kvMetricsStore) GetUe(ueID string) (*UeMetricsEntry, error) {
+	value, err := s.kv.get(ueID)
+	if err != nil {
+		return nil, err
+	}
+	ueMetrics := &UeMetricsEntry{}
+	if err = json.Unmarshal(value, ueMetrics); err != nil {
+		return nil, err
+	}
+	return ueMetrics, nil
+}
+
+func (s *kvMetricsStore) PutUe(ueID string, ueMetrics *UeMetricsEntry) error {
+	value, err := json.Marshal(ueMetrics)
+	if err != nil {
+		return err
+	}
+	if err = s.kv.set(map[string][]byte{ueID: value}); err != nil {
+		return err
+	}
+	return s.kv.index([]string{ueID}, nil)
+}
+
+func (s *kvMetricsStore) DeleteUe(ueID string) error {
+	if err := s.kv.del(ueID); err != nil {
+		return err
+	}
+	return s.kv.index(nil, []string{ueID})
+}
+
+func (s *kvMetricsStore) ExistsUe(ueID string) (bool, error) {
+	return s.kv.exists(ueID)
+}
+
+// ListUes returns the IDs of the UE entries in the store from the UE index, which holds the UEs written
+// through a kvMetricsStore. A UE entry deleted from the backend directly stays listed, GetUes leaves it out.
+func (s *kvMetricsStore) ListUes() ([]string, error) {
+	return s.kv.indexed()
+}
+
+// GetUes returns the entries of the UEs of ueIDs found in the store, read in one round trip
+func (s *kvMetricsStore) GetUes(ueIDs []string) (map[string]*UeMetricsEntry, error) {
+	values, err := s.kv.getAll(ueIDs)
+	if err != nil {
+		return nil, err
+	}
+	ues := make(map[string]*UeMetricsEntry, len(values))
+	for ueID, value := range values {
+		ueMetrics := &UeMetricsEntry{}
+		if json.Unmarshal(value, ueMetrics) == nil {
+			ues[ueID] = ueMetrics
+		}
+	}
+	return ues, nil
+}
+
+func (s *kvMetricsStore) GetCell(cellID string) (*CellMetricsEntry, error) {
+	value, err := s.kv.get(cellID)
+	if err != nil {
+		return nil, err
+	}
+	cellMetrics := &CellMetricsEntry{}
+	if err = json.Unmarshal(value, cellMetrics); err != nil {
+		return nil, err
+	}
+	return cellMetrics, nil
+}
+
+func (s *kvMetricsStore) PutCell(cellID string, cellMetrics *CellMetricsEntry) error {
+	value, err := json.Marshal(cellMetrics)
+	if err != nil {
+		return err
+	}
+	return s.kv.set(map[string][]byte{cellID: value})
+}
+
+func (s *kvMetricsStore) DeleteCell(cellID string) error {
+	return s.kv.del(cellID)
+}
+
+func (s *kvMetricsStore) ExistsCell(cellID string) (bool, error) {
+	return s.kv.exists(cellID)
+}
+
+func (s *kvMetricsStore) WriteBatch(batch *MetricsBatch) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	pairs, err := batch.encode()
+	if err != nil {
+		return err
+	}
+	if err = s.kv.set(pairs); err != nil || len(batch.Ues) == 0 {
+		return err
+	}
+	ueIDs := make([]string, 0, len(batch.Ues))
+	for ueID := range batch.Ues {
+		ueIDs = append(ueIDs, ueID)
+	}
+	return s.kv.index(ueIDs, nil)
+}
+
+func (s *kvMetricsStore) Ping() error {
+	return s.kv.ping()
+}
+
+func (s *kvMetricsStore) Close() error {
+	return s.kv.close()
+}
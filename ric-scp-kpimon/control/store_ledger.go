@@ -0,0 +1,108 @@
+package control
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+)
+
+// ledgerMetricsStore records every write going through it in a ledger as a genuine write, together
+// with the entry it replaced and the last indication sequence number. The replaced entry is the one
+// last written through the store, null for the first write of a key since start, so that recording
+// costs no read of the backend.
+type ledgerMetricsStore struct {
+	MetricsStore
+	ledger       ledger.Writer
+	indicationSN *int64
+	last         map[string]json.RawMessage //entry last written per target and key
+	mu           *sync.Mutex
+}
+
+func newLedgerMetricsStore(store MetricsStore, records ledger.Writer, indicationSN *int64) MetricsStore {
+	return &ledgerMetricsStore{store, records, indicationSN, make(map[string]json.RawMessage), &sync.Mutex{}}
+}
+
+func (s *ledgerMetricsStore) PutUe(ueID string, ueMetrics *UeMetricsEntry) error {
+	err := s.MetricsStore.PutUe(ueID, ueMetrics)
+	s.record(ledger.TARGET_UE, ueID, ueMetrics, err)
+	return err
+}
+
+func (s *ledgerMetricsStore) PutCell(cellID string, cellMetrics *CellMetricsEntry) error {
+	err := s.MetricsStore.PutCell(cellID, cellMetrics)
+	s.record(ledger.TARGET_CELL, cellID, cellMetrics, err)
+	return err
+}
+
+func (s *ledgerMetricsStore) DeleteUe(ueID string) error {
+	err := s.MetricsStore.DeleteUe(ueID)
+	s.record(ledger.TARGET_UE, ueID, nil, err)
+	return err
+}
+
+func (s *ledgerMetricsStore) DeleteCell(cellID string) error {
+	err := s.MetricsStore.DeleteCell(cellID)
+	s.record(ledger.TARGET_CELL, cellID, nil, err)
+	return err
+}
+
+func (s *ledgerMetricsStore) WriteBatch(batch *MetricsBatch) error {
+	err := s.MetricsStore.WriteBatch(batch)
+	for ueID, ueMetrics := range batch.Ues {
+		s.record(ledger.TARGET_UE, ueID, ueMetrics, err)
+	}
+	for cellID, cellMetrics := range batch.Cells {
+		s.record(ledger.TARGET_CELL, cellID, cellMetrics, err)
+	}
+	return err
+}
+
+// swap remembers the entry written to a key when the write succeeded and returns the one it replaced
+func (s *ledgerMetricsStore) swap(target string, key string, after json.RawMessage, written bool) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before, ok := s.last[target+"/"+key]
+	if !ok {
+		before = json.RawMessage("null")
+	}
+	if written && after == nil {
+		delete(s.last, target+"/"+key)
+	} else if written {
+		s.last[target+"/"+key] = after
+	}
+	return before
+}
+
+func (s *ledgerMetricsStore) record(target string, key string, after interface{}, err error) {
+	record := &ledger.Record{
+		Primitive:    ledger.GENUINE,
+		Target:       target,
+		Key:          key,
+		Time:         time.Now(),
+		IndicationSN: atomic.LoadInt64(s.indicationSN),
+		Success:      err == nil,
+	}
+	var data json.RawMessage
+	if after != nil {
+		data, _ = json.Marshal(after)
+	}
+	record.Before = s.swap(target, key, data, err == nil)
+	if data == nil {
+		record.After = json.RawMessage("null")
+	} else {
+		record.After = data
+	}
+	record.Fields = ledger.ChangedFields(record.Before, record.After)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err = s.ledger.Write(record); err != nil {
+		xapp.Logger.Error("Failed to record %s [%s] in the ledger: %v", target, key, err)
+		log.Printf("Failed to record %s [%s] in the ledger: %v", target, key, err)
+	}
+}
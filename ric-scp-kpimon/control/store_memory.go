@@ -0,0 +1,95 @@
+package control
+
+import (
+	"sync"
+)
+
+// memoryKVStore keeps the entries in process, it is meant for local runs and unit tests
+type memoryKVStore struct {
+	entries map[string][]byte
+	ues     map[string]bool //UE index
+	mu      *sync.RWMutex
+}
+
+func NewMemoryMetricsStore() MetricsStore {
+	return &kvMetricsStore{newMemoryKVStore()}
+}
+
+func newMemoryKVStore() kvStore {
+	return &memoryKVStore{make(map[string][]byte), make(map[string]bool), &sync.RWMutex{}}
+}
+
+func (s *memoryKVStore) get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.entries[key]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (s *memoryKVStore) set(pairs map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range pairs {
+		s.entries[key] = append([]byte(nil), value...)
+	}
+	return nil
+}
+
+func (s *memoryKVStore) del(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *memoryKVStore) exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.entries[key]
+	return ok, nil
+}
+
+func (s *memoryKVStore) getAll(keys []string) (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := s.entries[key]; ok {
+			values[key] = append([]byte(nil), value...)
+		}
+	}
+	return values, nil
+}
+
+func (s *memoryKVStore) index(add []string, remove []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range add {
+		s.ues[key] = true
+	}
+	for _, key := range remove {
+		delete(s.ues, key)
+	}
+	return nil
+}
+
+func (s *memoryKVStore) indexed() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.ues))
+	for key := range s.ues {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *memoryKVStore) ping() error {
+	return nil
+}
+
+func (s *memoryKVStore) close() error {
+	return nil
+}
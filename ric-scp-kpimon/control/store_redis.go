@@ -0,0 +1,107 @@
+package control
+
+import (
+	"github.com/go-redis/redis"
+)
+
+type redisKVStore struct {
+	client *redis.Client //redis client
+}
+
+func NewRedisMetricsStore(addr string, password string, db int) MetricsStore {
+	return &kvMetricsStore{newRedisKVStore(addr, password, db)}
+}
+
+func newRedisKVStore(addr string, password string, db int) kvStore {
+	return &redisKVStore{
+		redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+	}
+}
+
+func (s *redisKVStore) get(key string) ([]byte, error) {
+	value, err := s.client.Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrEntryNotFound
+	}
+	return value, err
+}
+
+func (s *redisKVStore) set(pairs map[string][]byte) error {
+	if len(pairs) == 1 {
+		for key, value := range pairs {
+			return s.client.Set(key, value, 0).Err()
+		}
+	}
+	pipe := s.client.TxPipeline()
+	for key, value := range pairs {
+		pipe.Set(key, value, 0)
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+func (s *redisKVStore) del(key string) error {
+	return s.client.Del(key).Err()
+}
+
+func (s *redisKVStore) exists(key string) (bool, error) {
+	n, err := s.client.Exists(key).Result()
+	return n == 1, err
+}
+
+func (s *redisKVStore) getAll(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	results, err := s.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if value, ok := result.(string); ok {
+			values[keys[i]] = []byte(value)
+		}
+	}
+	return values, nil
+}
+
+// index updates the UE index, a redis set, in the same round trip for additions and removals
+func (s *redisKVStore) index(add []string, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	pipe := s.client.TxPipeline()
+	if len(add) > 0 {
+		pipe.SAdd(UE_INDEX_KEY, members(add)...)
+	}
+	if len(remove) > 0 {
+		pipe.SRem(UE_INDEX_KEY, members(remove)...)
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+func (s *redisKVStore) indexed() ([]string, error) {
+	return s.client.SMembers(UE_INDEX_KEY).Result()
+}
+
+func members(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = key
+	}
+	return values
+}
+
+func (s *redisKVStore) ping() error {
+	return s.client.Ping().Err()
+}
+
+func (s *redisKVStore) close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,100 @@
+package control
+
+import (
+	"errors"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
+)
+
+// sdlKVStore stores the entries through the O-RAN SDL, keys are scoped to the configured namespace
+type sdlKVStore struct {
+	sdl *sdlgo.SdlInstance
+}
+
+func NewSdlMetricsStore(namespace string) MetricsStore {
+	return &kvMetricsStore{newSdlKVStore(namespace)}
+}
+
+func newSdlKVStore(namespace string) kvStore {
+	return &sdlKVStore{sdlgo.NewSdlInstance(namespace, sdlgo.NewDatabase())}
+}
+
+func (s *sdlKVStore) get(key string) ([]byte, error) {
+	values, err := s.sdl.Get([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	switch value := values[key].(type) {
+	case nil:
+		return nil, ErrEntryNotFound
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	default:
+		return nil, errors.New("Unexpected SDL value type for key " + key)
+	}
+}
+
+func (s *sdlKVStore) set(pairs map[string][]byte) error {
+	kvs := make([]interface{}, 0, 2*len(pairs))
+	for key, value := range pairs {
+		kvs = append(kvs, key, value)
+	}
+	return s.sdl.Set(kvs...)
+}
+
+func (s *sdlKVStore) del(key string) error {
+	return s.sdl.Remove([]string{key})
+}
+
+func (s *sdlKVStore) exists(key string) (bool, error) {
+	values, err := s.sdl.Get([]string{key})
+	if err != nil {
+		return false, err
+	}
+	return values[key] != nil, nil
+}
+
+func (s *sdlKVStore) getAll(keys []string) (map[string][]byte, error) {
+	results, err := s.sdl.Get(keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(results))
+	for key, result := range results {
+		switch value := result.(type) {
+		case string:
+			values[key] = []byte(value)
+		case []byte:
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// index updates the UE index, an SDL set of the namespace
+func (s *sdlKVStore) index(add []string, remove []string) error {
+	if len(add) > 0 {
+		if err := s.sdl.SetAdd(UE_INDEX_KEY, members(add)...); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		return s.sdl.SetRemove(UE_INDEX_KEY, members(remove)...)
+	}
+	return nil
+}
+
+func (s *sdlKVStore) indexed() ([]string, error) {
+	return s.sdl.GetMembers(UE_INDEX_KEY)
+}
+
+func (s *sdlKVStore) ping() error {
+	_, err := s.sdl.Get([]string{"ping"})
+	return err
+}
+
+func (s *sdlKVStore) close() error {
+	return s.sdl.Close()
+}
@@ -0,0 +1,100 @@
+package control
+
+import (
+	"log"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/envelope"
+)
+
+// signedKVStore seals every value written in a signed envelope bound to its key and verifies
+// the envelopes it reads, handing the plain JSON entries to kvMetricsStore
+type signedKVStore struct {
+	kvStore
+	signer   *envelope.Signer      //nil for a view writing plain records
+	verifier *envelope.Verifier    //nil for a view reading envelopes without verification
+	guard    *envelope.ReplayGuard //rejects signed records older than the last one seen for their key
+	enforce  bool                  //reject plain records on read
+}
+
+func newSignedKVStore(kv kvStore, keyFile string, enforce bool) (kvStore, error) {
+	keys, err := envelope.LoadKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := envelope.NewSigner(keys)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := envelope.NewVerifier(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &signedKVStore{kv, signer, verifier, envelope.NewReplayGuard(), enforce}, nil
+}
+
+func (s *signedKVStore) get(key string) ([]byte, error) {
+	value, err := s.kvStore.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(key, value)
+}
+
+// getAll leaves out the records get would reject
+func (s *signedKVStore) getAll(keys []string) (map[string][]byte, error) {
+	values, err := s.kvStore.getAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		if values[key], err = s.open(key, value); err != nil {
+			delete(values, key)
+		}
+	}
+	return values, nil
+}
+
+// open verifies the record read from key and returns its plain JSON entry
+func (s *signedKVStore) open(key string, value []byte) ([]byte, error) {
+	if !envelope.IsEnvelope(value) {
+		if s.enforce && s.verifier != nil {
+			return nil, s.reject(key, envelope.ErrNotSigned)
+		}
+		return value, nil
+	}
+	if s.verifier == nil {
+		return envelope.Payload(value), nil
+	}
+	e, err := s.verifier.Verify(key, value)
+	if err == nil {
+		err = s.guard.Check(key, e)
+	}
+	if err != nil {
+		return nil, s.reject(key, err)
+	}
+	return e.Payload, nil
+}
+
+func (s *signedKVStore) set(pairs map[string][]byte) error {
+	if s.signer == nil {
+		return s.kvStore.set(pairs)
+	}
+	sealed := make(map[string][]byte, len(pairs))
+	for key, value := range pairs {
+		data, err := s.signer.Seal(key, value)
+		if err != nil {
+			return err
+		}
+		sealed[key] = data
+		e, _ := envelope.Parse(data)
+		s.guard.Check(key, e)
+	}
+	return s.kvStore.set(sealed)
+}
+
+func (s *signedKVStore) reject(key string, err error) error {
+	xapp.Logger.Warn("Rejected metrics record of key [%s]: %v", key, err)
+	log.Printf("Rejected metrics record of key [%s]: %v", key, err)
+	return err
+}
@@ -0,0 +1,244 @@
+package control
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/envelope"
+)
+
+func storeUe(ueID string) *UeMetricsEntry {
+	return &UeMetricsEntry{UeID: ueID, ServingCellID: "cell_1", PDCPBytesDL: 1000, PDCPBytesUL: 200, PRBUsageDL: 10, PRBUsageUL: 2,
+		ServingCellRF: CellRFType{RSRP: -90, RSRQ: -10, RSSINR: 15}, NeighborCellsRF: []NeighborCellRFType{{CellID: "cell_2", CellRF: CellRFType{RSRP: -100}}}}
+}
+
+func storeCell() *CellMetricsEntry {
+	return &CellMetricsEntry{PDCPBytesDL: 5000, PDCPBytesUL: 700, AvailPRBDL: 80, AvailPRBUL: 90}
+}
+
+func TestMemoryStoreEntries(t *testing.T) {
+	s := NewMemoryMetricsStore()
+	defer s.Close()
+	if err := s.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetUe("ue_1"); err != ErrEntryNotFound {
+		t.Errorf("GetUe of a missing UE returned %v, want ErrEntryNotFound", err)
+	}
+	if _, err := s.GetCell("cell_1"); err != ErrEntryNotFound {
+		t.Errorf("GetCell of a missing cell returned %v, want ErrEntryNotFound", err)
+	}
+	if ok, err := s.ExistsUe("ue_1"); ok || err != nil {
+		t.Errorf("missing UE exists: %v, %v", ok, err)
+	}
+
+	ue := storeUe("ue_1")
+	if err := s.PutUe("ue_1", ue); err != nil {
+		t.Fatal(err)
+	}
+	ue.PDCPBytesDL = 1 //the store keeps its own copy
+	got, err := s.GetUe("ue_1")
+	if err != nil || !reflect.DeepEqual(got, storeUe("ue_1")) {
+		t.Errorf("GetUe returned %+v, %v, want %+v", got, err, storeUe("ue_1"))
+	}
+	if err := s.PutCell("cell_1", storeCell()); err != nil {
+		t.Fatal(err)
+	}
+	if cell, err := s.GetCell("cell_1"); err != nil || !reflect.DeepEqual(cell, storeCell()) {
+		t.Errorf("GetCell returned %+v, %v, want %+v", cell, err, storeCell())
+	}
+	if ok, err := s.ExistsCell("cell_1"); !ok || err != nil {
+		t.Errorf("written cell exists: %v, %v", ok, err)
+	}
+
+	if err := s.DeleteUe("ue_1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.ExistsUe("ue_1"); ok {
+		t.Error("deleted UE still exists")
+	}
+	if _, err := s.GetUe("ue_1"); err != ErrEntryNotFound {
+		t.Errorf("GetUe of a deleted UE returned %v, want ErrEntryNotFound", err)
+	}
+	if err := s.DeleteCell("cell_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteCell("cell_1"); err != nil {
+		t.Errorf("deleting a missing cell returned %v", err)
+	}
+	if ok, _ := s.ExistsCell("cell_1"); ok {
+		t.Error("deleted cell still exists")
+	}
+}
+
+func TestMemoryStoreWriteBatch(t *testing.T) {
+	s := NewMemoryMetricsStore()
+	if err := s.WriteBatch(NewMetricsBatch()); err != nil {
+		t.Errorf("empty batch returned %v", err)
+	}
+
+	batch := NewMetricsBatch()
+	batch.PutUe("ue_1", storeUe("ue_1"))
+	batch.PutUe("ue_2", storeUe("ue_2"))
+	batch.PutCell("cell_1", storeCell())
+	if batch.Len() != 3 {
+		t.Errorf("batch of %d entries, want 3", batch.Len())
+	}
+	if err := s.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	for _, ueID := range []string{"ue_1", "ue_2"} {
+		if ue, err := s.GetUe(ueID); err != nil || !reflect.DeepEqual(ue, storeUe(ueID)) {
+			t.Errorf("UE %s written as %+v, %v", ueID, ue, err)
+		}
+	}
+	if cell, err := s.GetCell("cell_1"); err != nil || !reflect.DeepEqual(cell, storeCell()) {
+		t.Errorf("cell written as %+v, %v", cell, err)
+	}
+
+	//cells are not listed as UEs
+	ueIDs, err := s.ListUes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ueIDs)
+	if !reflect.DeepEqual(ueIDs, []string{"ue_1", "ue_2"}) {
+		t.Errorf("ListUes returned %v, want [ue_1 ue_2]", ueIDs)
+	}
+	s.DeleteUe("ue_2")
+	if ueIDs, _ := s.ListUes(); !reflect.DeepEqual(ueIDs, []string{"ue_1"}) {
+		t.Errorf("ListUes returned %v after deleting ue_2, want [ue_1]", ueIDs)
+	}
+
+	//an entry deleted behind the index is listed but not read
+	s.PutUe("ue_3", storeUe("ue_3"))
+	s.(*kvMetricsStore).kv.del("ue_3")
+	ues, err := s.GetUes([]string{"ue_1", "ue_2", "ue_3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ues) != 1 || !reflect.DeepEqual(ues["ue_1"], storeUe("ue_1")) {
+		t.Errorf("GetUes returned %+v, want ue_1 only", ues)
+	}
+	if ueIDs, _ := s.ListUes(); len(ueIDs) != 2 {
+		t.Errorf("ListUes returned %v, want ue_1 and the deleted ue_3", ueIDs)
+	}
+}
+
+// signedStore opens a memory store signing with a key file of algorithm written to a temporary directory
+func signedStore(t *testing.T, algorithm string, mode string) MetricsStore {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "signedstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	keys, err := envelope.GenerateKeyFile(algorithm, "kpimon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "keys.json")
+	if err := keys.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewMetricsStore(StoreConfig{Backend: STORE_BACKEND_MEMORY, SigningMode: mode, SigningKeyFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// rawKV returns the backend of a signed store, holding the envelopes as written
+func rawKV(s MetricsStore) kvStore {
+	return s.(*kvMetricsStore).kv.(*signedKVStore).kvStore
+}
+
+func TestSignedStore(t *testing.T) {
+	for _, algorithm := range []string{envelope.ALG_HMAC_SHA256, envelope.ALG_ED25519} {
+		s := signedStore(t, algorithm, SIGNING_ENFORCE)
+		if err := s.PutUe("ue_1", storeUe("ue_1")); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := rawKV(s).get("ue_1")
+		if err != nil || !envelope.IsEnvelope(raw) {
+			t.Fatalf("%s: UE stored as %s (%v), want an envelope", algorithm, raw, err)
+		}
+		if ue, err := s.GetUe("ue_1"); err != nil || !reflect.DeepEqual(ue, storeUe("ue_1")) {
+			t.Errorf("%s: GetUe returned %+v, %v", algorithm, ue, err)
+		}
+		if ueIDs, _ := s.ListUes(); !reflect.DeepEqual(ueIDs, []string{"ue_1"}) {
+			t.Errorf("%s: ListUes returned %v, want [ue_1]", algorithm, ueIDs)
+		}
+
+		//a valid envelope moved to another key does not verify
+		rawKV(s).set(map[string][]byte{"ue_2": raw})
+		if _, err := s.GetUe("ue_2"); err != envelope.ErrBadSignature {
+			t.Errorf("%s: record moved to another key returned %v, want ErrBadSignature", algorithm, err)
+		}
+
+		//an older envelope written back to its key is a replay
+		if err := s.PutUe("ue_1", storeUe("ue_1")); err != nil {
+			t.Fatal(err)
+		}
+		rawKV(s).set(map[string][]byte{"ue_1": raw})
+		if _, err := s.GetUe("ue_1"); err != envelope.ErrReplayed {
+			t.Errorf("%s: replayed record returned %v, want ErrReplayed", algorithm, err)
+		}
+
+		//the foreign view writes plain records, which the enforcing store rejects, and reads signed ones unverified
+		foreign := ForeignView(s)
+		if err := foreign.PutCell("cell_1", storeCell()); err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := rawKV(s).get("cell_1"); envelope.IsEnvelope(raw) {
+			t.Errorf("%s: foreign view wrote an envelope", algorithm)
+		}
+		if _, err := s.GetCell("cell_1"); err != envelope.ErrNotSigned {
+			t.Errorf("%s: plain record returned %v, want ErrNotSigned", algorithm, err)
+		}
+		if err := s.PutCell("cell_2", storeCell()); err != nil {
+			t.Fatal(err)
+		}
+		if cell, err := foreign.GetCell("cell_2"); err != nil || !reflect.DeepEqual(cell, storeCell()) {
+			t.Errorf("%s: foreign view read %+v, %v", algorithm, cell, err)
+		}
+
+		//GetUes leaves out the records GetUe rejects, the foreign view reads them all
+		s.PutUe("ue_1", storeUe("ue_1"))
+		foreign.PutUe("ue_3", storeUe("ue_3"))
+		ueIDs := []string{"ue_1", "ue_2", "ue_3"}
+		if ues, err := s.GetUes(ueIDs); err != nil || len(ues) != 1 || !reflect.DeepEqual(ues["ue_1"], storeUe("ue_1")) {
+			t.Errorf("%s: GetUes returned %+v, %v, want ue_1 only", algorithm, ues, err)
+		}
+		if ues, err := foreign.GetUes(ueIDs); err != nil || len(ues) != 3 {
+			t.Errorf("%s: foreign GetUes returned %+v, %v, want the 3 UEs", algorithm, ues, err)
+		}
+	}
+}
+
+func TestSignedStoreSignMode(t *testing.T) {
+	s := signedStore(t, envelope.ALG_HMAC_SHA256, SIGNING_SIGN)
+	if err := ForeignView(s).PutCell("cell_1", storeCell()); err != nil {
+		t.Fatal(err)
+	}
+	if cell, err := s.GetCell("cell_1"); err != nil || !reflect.DeepEqual(cell, storeCell()) {
+		t.Errorf("sign mode read the plain record as %+v, %v", cell, err)
+	}
+
+	//the foreign view of a store without signing is the store itself
+	plain := NewMemoryMetricsStore()
+	if ForeignView(plain) != plain {
+		t.Error("foreign view of an unsigned store is another store")
+	}
+	if _, err := NewMetricsStore(StoreConfig{Backend: STORE_BACKEND_MEMORY, SigningMode: "always"}); err == nil {
+		t.Error("unknown signing mode accepted")
+	}
+	if _, err := NewMetricsStore(StoreConfig{Backend: STORE_BACKEND_MEMORY, SigningMode: SIGNING_ENFORCE, SigningKeyFile: "/nonexistent/keys.json"}); err == nil {
+		t.Error("missing key file accepted")
+	}
+}
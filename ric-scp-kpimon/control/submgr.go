@@ -0,0 +1,377 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// SUBMGR_NOTIFICATION_PATH is where the subscription manager posts the outcome of a REST subscription
+const SUBMGR_NOTIFICATION_PATH = "/ric/v1/subscriptions/response"
+
+var submgrActionTypes = []string{SUBSCRIPTION_ACTION_REPORT, SUBSCRIPTION_ACTION_INSERT, SUBSCRIPTION_ACTION_POLICY}
+var submgrSubsequentActionTypes = []string{SUBSEQUENT_ACTION_CONTINUE, SUBSEQUENT_ACTION_WAIT}
+var submgrTimesToWait = []string{"zero", "w1ms", "w2ms", "w5ms", "w10ms", "w20ms", "w30ms", "w40ms", "w50ms",
+	"w100ms", "w200ms", "w500ms", "w1s", "w2s", "w5s", "w10s", "w20s", "w60s"}
+
+// The JSON model of the subscription manager REST API, as in the clientmodel package of xapp-frame
+
+type submgrSubscriptionParams struct {
+	SubscriptionID      string                     `json:"SubscriptionId,omitempty"`
+	ClientEndpoint      submgrClientEndpoint       `json:"ClientEndpoint"`
+	Meid                string                     `json:"Meid"`
+	RANFunctionID       int                        `json:"RANFunctionID"`
+	SubscriptionDetails []submgrSubscriptionDetail `json:"SubscriptionDetails"`
+}
+
+type submgrClientEndpoint struct {
+	Host     string `json:"Host"`
+	HTTPPort int    `json:"HTTPPort"`
+	RMRPort  int    `json:"RMRPort"`
+}
+
+type submgrSubscriptionDetail struct {
+	XappEventInstanceID int            `json:"XappEventInstanceId"`
+	EventTriggers       []int64        `json:"EventTriggers"`
+	ActionToBeSetupList []submgrAction `json:"ActionToBeSetupList"`
+}
+
+type submgrAction struct {
+	ActionID         int64                   `json:"ActionID"`
+	ActionType       string                  `json:"ActionType"`
+	ActionDefinition []int64                 `json:"ActionDefinition,omitempty"`
+	SubsequentAction *submgrSubsequentAction `json:"SubsequentAction,omitempty"`
+}
+
+type submgrSubsequentAction struct {
+	SubsequentActionType string `json:"SubsequentActionType"`
+	TimeToWait           string `json:"TimeToWait"`
+}
+
+type submgrSubscriptionResponse struct {
+	SubscriptionID        string                       `json:"SubscriptionId"`
+	SubscriptionInstances []submgrSubscriptionInstance `json:"SubscriptionInstances"`
+}
+
+type submgrSubscriptionInstance struct {
+	XappEventInstanceID int    `json:"XappEventInstanceId"`
+	E2EventInstanceID   int    `json:"E2EventInstanceId"`
+	ErrorCause          string `json:"ErrorCause,omitempty"`
+	ErrorSource         string `json:"ErrorSource,omitempty"`
+	TimeoutType         string `json:"TimeoutType,omitempty"`
+}
+
+// restSubscriptionBackend subscribes through the REST interface of the RIC subscription manager, which
+// allocates the RMR SubId (E2EventInstanceId) and routes the E2AP messages to the E2 nodes. The outcome
+// of a subscription is posted back to kpimon on SUBMGR_NOTIFICATION_PATH.
+type restSubscriptionBackend struct {
+	url           string
+	endpoint      submgrClientEndpoint
+	callbackAddr  string
+	subscriptions *Subscriptions
+	client        *http.Client
+	server        *http.Server
+	records       map[string]*SubscriptionRecord //record by subscription manager subscription ID
+	ranNames      map[string]string              //RAN name by subscription manager subscription ID
+	mu            *sync.Mutex
+}
+
+func NewRestSubscriptionBackend(cfg SubscriptionConfig, subscriptions *Subscriptions) SubscriptionBackend {
+	b := &restSubscriptionBackend{
+		url:           strings.TrimRight(cfg.SubmgrURL, "/"),
+		endpoint:      submgrClientEndpoint{Host: cfg.ClientHost, RMRPort: cfg.RmrPort},
+		callbackAddr:  cfg.CallbackAddr,
+		subscriptions: subscriptions,
+		client:        &http.Client{Timeout: 5 * time.Second},
+		records:       make(map[string]*SubscriptionRecord),
+		ranNames:      make(map[string]string),
+		mu:            &sync.Mutex{},
+	}
+	if b.endpoint.Host == "" {
+		b.endpoint.Host, _ = os.Hostname()
+	}
+	if _, port, err := net.SplitHostPort(b.callbackAddr); err == nil {
+		b.endpoint.HTTPPort, _ = strconv.Atoi(port)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(SUBMGR_NOTIFICATION_PATH, b.serveNotification)
+	b.server = &http.Server{Addr: b.callbackAddr, Handler: mux}
+	return b
+}
+
+// Start listens for the notifications of the subscription manager
+func (b *restSubscriptionBackend) Start() error {
+	listener, err := net.Listen("tcp", b.callbackAddr)
+	if err != nil {
+		return err
+	}
+	if b.endpoint.HTTPPort == 0 {
+		b.endpoint.HTTPPort = listener.Addr().(*net.TCPAddr).Port
+	}
+	go b.server.Serve(listener)
+	return nil
+}
+
+func (b *restSubscriptionBackend) Subscribe(ranName string, req *subscriptionRequest) error {
+	params := &submgrSubscriptionParams{
+		ClientEndpoint: b.endpoint,
+		Meid:           ranName,
+		RANFunctionID:  req.funcID,
+	}
+	detail := submgrSubscriptionDetail{XappEventInstanceID: req.instanceID, EventTriggers: int64s(req.eventTrigger)}
+	for i, id := range req.actionIds {
+		action := submgrAction{
+			ActionID:         id,
+			ActionType:       submgrActionTypes[req.actionTypes[i]],
+			ActionDefinition: int64s(req.actionDefinitions[i].Buf[:req.actionDefinitions[i].Size]),
+		}
+		if subsequent := req.subsequentActions[i]; subsequent.IsValid != 0 {
+			action.SubsequentAction = &submgrSubsequentAction{submgrSubsequentActionTypes[subsequent.SubsequentActionType], submgrTimesToWait[subsequent.TimeToWait]}
+		}
+		detail.ActionToBeSetupList = append(detail.ActionToBeSetupList, action)
+	}
+	params.SubscriptionDetails = []submgrSubscriptionDetail{detail}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	//the notification of the subscription waits for the record
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	log.Printf("POST %s/subscriptions: %s", b.url, body)
+	resp, err := b.client.Post(b.url+"/subscriptions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.New("Subscription manager answered with status " + strconv.Itoa(resp.StatusCode) + ": " + strings.TrimSpace(string(body)))
+	}
+	response := &submgrSubscriptionResponse{}
+	if err = json.Unmarshal(body, response); err != nil {
+		return err
+	}
+	if response.SubscriptionID == "" {
+		return errors.New("Subscription manager answered without subscription ID")
+	}
+
+	record := &SubscriptionRecord{RestID: response.SubscriptionID, RequestorID: req.requestorID, RequestSN: req.instanceID, FuncID: req.funcID}
+	b.records[response.SubscriptionID], b.ranNames[response.SubscriptionID] = record, ranName
+	b.subscriptions.Sent(ranName, *record)
+	xapp.Logger.Info("Subscription %s of {%s} created by the subscription manager", response.SubscriptionID, ranName)
+	log.Printf("Subscription %s of {%s} created by the subscription manager", response.SubscriptionID, ranName)
+	return nil
+}
+
+func (b *restSubscriptionBackend) Unsubscribe(ranName string, record *SubscriptionRecord) error {
+	if record.RestID == "" {
+		return errors.New("Subscription of {" + ranName + "} has no subscription manager ID")
+	}
+	req, err := http.NewRequest(http.MethodDelete, b.url+"/subscriptions/"+record.RestID, nil)
+	if err != nil {
+		return err
+	}
+	log.Printf("DELETE %s/subscriptions/%s", b.url, record.RestID)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return errors.New("Subscription manager answered with status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	b.mu.Lock()
+	delete(b.records, record.RestID)
+	delete(b.ranNames, record.RestID)
+	b.mu.Unlock()
+	b.subscriptions.Deleted(ranName)
+	return nil
+}
+
+func (b *restSubscriptionBackend) Close() error {
+	return b.server.Close()
+}
+
+func (b *restSubscriptionBackend) serveNotification(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	notification := &submgrSubscriptionResponse{}
+	if err := json.NewDecoder(req.Body).Decode(notification); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+
+	b.mu.Lock()
+	ranName, ok := b.ranNames[notification.SubscriptionID]
+	record := b.records[notification.SubscriptionID]
+	b.mu.Unlock()
+	if !ok {
+		xapp.Logger.Error("Notification of unknown subscription %s", notification.SubscriptionID)
+		log.Printf("Notification of unknown subscription %s", notification.SubscriptionID)
+		return
+	}
+
+	for _, instance := range notification.SubscriptionInstances {
+		if instance.ErrorCause != "" || instance.TimeoutType != "" {
+			reason := "subscription manager: " + instance.ErrorCause
+			if instance.ErrorSource != "" {
+				reason += " (" + instance.ErrorSource + ")"
+			}
+			if instance.TimeoutType != "" {
+				reason += " timeout " + instance.TimeoutType
+			}
+			b.mu.Lock()
+			delete(b.records, notification.SubscriptionID)
+			delete(b.ranNames, notification.SubscriptionID)
+			b.mu.Unlock()
+			b.subscriptions.Failed(ranName, reason, true)
+			return
+		}
+		confirmed := *record
+		confirmed.SubID = instance.E2EventInstanceID
+		b.subscriptions.Sent(ranName, confirmed)
+	}
+	b.subscriptions.Responded(ranName)
+}
+
+func int64s(buf []byte) []int64 {
+	values := make([]int64, len(buf))
+	for i, b := range buf {
+		values[i] = int64(b)
+	}
+	return values
+}
+
+// SubmgrStandIn is a local stand-in for the REST interface of the subscription manager: it accepts the
+// subscriptions posted to it, notifies the client endpoint as if every E2 node admitted them, unless told
+// to fail the ones of a RAN name, and serves the subscriptions it holds on GET
+type SubmgrStandIn struct {
+	server        *http.Server
+	client        *http.Client
+	subscriptions map[string]*submgrSubscriptionParams
+	failures      map[string]string //error cause by RAN name
+	next          int
+	mu            *sync.Mutex
+}
+
+func NewSubmgrStandIn(addr string) *SubmgrStandIn {
+	s := &SubmgrStandIn{
+		client:        &http.Client{Timeout: 5 * time.Second},
+		subscriptions: make(map[string]*submgrSubscriptionParams),
+		failures:      make(map[string]string),
+		mu:            &sync.Mutex{},
+	}
+	s.server = &http.Server{Addr: addr, Handler: http.HandlerFunc(s.serve)}
+	return s
+}
+
+// Start listens on the address of the stand-in and returns the URL to configure as submgrUrl
+func (s *SubmgrStandIn) Start() (string, error) {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return "", err
+	}
+	go s.server.Serve(listener)
+	return "http://" + listener.Addr().String() + "/ric/v1", nil
+}
+
+// Fail makes the subscriptions of an E2 node fail with cause, or succeed again when cause is empty
+func (s *SubmgrStandIn) Fail(ranName string, cause string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cause == "" {
+		delete(s.failures, ranName)
+	} else {
+		s.failures[ranName] = cause
+	}
+}
+
+func (s *SubmgrStandIn) Close() error {
+	return s.server.Close()
+}
+
+func (s *SubmgrStandIn) serve(rw http.ResponseWriter, req *http.Request) {
+	path := strings.TrimRight(req.URL.Path, "/")
+	switch {
+	case req.Method == http.MethodPost && path == "/ric/v1/subscriptions":
+		params := &submgrSubscriptionParams{}
+		if err := json.NewDecoder(req.Body).Decode(params); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if params.Meid == "" || params.ClientEndpoint.Host == "" || len(params.SubscriptionDetails) == 0 {
+			http.Error(rw, "Meid, ClientEndpoint and SubscriptionDetails are mandatory", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.next++
+		params.SubscriptionID = strconv.Itoa(s.next)
+		s.subscriptions[params.SubscriptionID] = params
+		notification := &submgrSubscriptionResponse{SubscriptionID: params.SubscriptionID}
+		for _, detail := range params.SubscriptionDetails {
+			instance := submgrSubscriptionInstance{XappEventInstanceID: detail.XappEventInstanceID, E2EventInstanceID: s.next}
+			if cause, ok := s.failures[params.Meid]; ok {
+				instance.ErrorCause, instance.ErrorSource = cause, "E2Node"
+				delete(s.subscriptions, params.SubscriptionID)
+			}
+			notification.SubscriptionInstances = append(notification.SubscriptionInstances, instance)
+		}
+		s.mu.Unlock()
+		log.Printf("Subscription manager stand-in: subscription %s of {%s}", params.SubscriptionID, params.Meid)
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		json.NewEncoder(rw).Encode(&submgrSubscriptionResponse{SubscriptionID: params.SubscriptionID})
+		go s.notify(params.ClientEndpoint, notification)
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/ric/v1/subscriptions/"):
+		id := strings.TrimPrefix(path, "/ric/v1/subscriptions/")
+		s.mu.Lock()
+		_, ok := s.subscriptions[id]
+		delete(s.subscriptions, id)
+		s.mu.Unlock()
+		if !ok {
+			http.Error(rw, "subscription not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Subscription manager stand-in: subscription %s deleted", id)
+		rw.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodGet && path == "/ric/v1/subscriptions":
+		s.mu.Lock()
+		subscriptions := make([]*submgrSubscriptionParams, 0, len(s.subscriptions))
+		for _, params := range s.subscriptions {
+			subscriptions = append(subscriptions, params)
+		}
+		s.mu.Unlock()
+		writeJSON(rw, subscriptions)
+	default:
+		http.Error(rw, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *SubmgrStandIn) notify(endpoint submgrClientEndpoint, notification *submgrSubscriptionResponse) {
+	body, _ := json.Marshal(notification)
+	url := "http://" + net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.HTTPPort)) + SUBMGR_NOTIFICATION_PATH
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Subscription manager stand-in: failed to notify %s: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+}
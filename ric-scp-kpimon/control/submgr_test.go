@@ -0,0 +1,186 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const SUBMGR_RAN_NAME = "gnb_submgr"
+
+// restBackend starts a REST subscription backend against the subscription manager at url, notified on
+// a free local port
+func restBackend(t *testing.T, url string) (SubscriptionBackend, *Subscriptions) {
+	t.Helper()
+	cfg := SubscriptionConfig{Backend: SUBSCRIPTION_BACKEND_REST, SubmgrURL: url, CallbackAddr: "127.0.0.1:0", ClientHost: "127.0.0.1"}
+	subscriptions := NewSubscriptions(cfg, []string{SUBMGR_RAN_NAME})
+	backend := NewSubscriptionBackend(cfg.withDefaults(), nil, subscriptions)
+	if err := backend.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend, subscriptions
+}
+
+func submgrRequest(t *testing.T) *subscriptionRequest {
+	t.Helper()
+	var nodes *KpmNodes
+	req, err := nodes.subscriptionRequest(SUBMGR_RAN_NAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func submgrNode(subscriptions *Subscriptions) NodeSubscription {
+	for _, node := range subscriptions.Nodes() {
+		if node.RanName == SUBMGR_RAN_NAME {
+			return node
+		}
+	}
+	return NodeSubscription{}
+}
+
+// waitSubscription waits for the subscription of the E2 node to be done, as the notifications arrive
+// asynchronously
+func waitSubscription(t *testing.T, subscriptions *Subscriptions, done func(node NodeSubscription) bool) NodeSubscription {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		node := submgrNode(subscriptions)
+		if done(node) {
+			return node
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription of %s left %s (%s)", SUBMGR_RAN_NAME, node.State, node.LastError)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func inState(state string) func(node NodeSubscription) bool {
+	return func(node NodeSubscription) bool { return node.State == state }
+}
+
+func standInSubscriptions(t *testing.T, url string) []*submgrSubscriptionParams {
+	t.Helper()
+	resp, err := http.Get(url + "/subscriptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var subscriptions []*submgrSubscriptionParams
+	if err := json.NewDecoder(resp.Body).Decode(&subscriptions); err != nil {
+		t.Fatal(err)
+	}
+	return subscriptions
+}
+
+func TestSubmgrStandInSubscribeNotifyDelete(t *testing.T) {
+	standIn := NewSubmgrStandIn("")
+	server := httptest.NewServer(standIn.server.Handler)
+	defer server.Close()
+	url := server.URL + "/ric/v1"
+	backend, subscriptions := restBackend(t, url)
+
+	if err := backend.Subscribe(SUBMGR_RAN_NAME, submgrRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	node := waitSubscription(t, subscriptions, inState(SUBSCRIPTION_ACTIVE))
+	if node.Record == nil || node.Record.RestID != "1" || node.Record.SubID != 1 {
+		t.Fatalf("record %+v, want subscription 1 with E2 event instance 1", node.Record)
+	}
+	held := standInSubscriptions(t, url)
+	if len(held) != 1 || held[0].Meid != SUBMGR_RAN_NAME || held[0].ClientEndpoint.Host != "127.0.0.1" {
+		t.Fatalf("stand-in holds %+v", held)
+	}
+
+	record, ok := subscriptions.Deleting(SUBMGR_RAN_NAME)
+	if !ok || record == nil {
+		t.Fatalf("Deleting returned %v, %v", record, ok)
+	}
+	if err := backend.Unsubscribe(SUBMGR_RAN_NAME, record); err != nil {
+		t.Fatal(err)
+	}
+	if node := waitSubscription(t, subscriptions, inState(SUBSCRIPTION_DELETED)); node.Record != nil {
+		t.Errorf("deleted subscription keeps record %+v", node.Record)
+	}
+	if held := standInSubscriptions(t, url); len(held) != 0 {
+		t.Errorf("stand-in still holds %d subscriptions", len(held))
+	}
+}
+
+func TestSubmgrStandInFailure(t *testing.T) {
+	standIn := NewSubmgrStandIn("")
+	standIn.Fail(SUBMGR_RAN_NAME, "action-not-supported")
+	server := httptest.NewServer(standIn.server.Handler)
+	defer server.Close()
+	backend, subscriptions := restBackend(t, server.URL+"/ric/v1")
+
+	if err := backend.Subscribe(SUBMGR_RAN_NAME, submgrRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	node := waitSubscription(t, subscriptions, func(node NodeSubscription) bool { return node.LastError != "" })
+	if node.State != SUBSCRIPTION_RETRYING {
+		t.Errorf("refused subscription is %s, want %s", node.State, SUBSCRIPTION_RETRYING)
+	}
+	if !strings.Contains(node.LastError, "action-not-supported (E2Node)") {
+		t.Errorf("last error %q, want the cause of the stand-in", node.LastError)
+	}
+	if node.Record != nil {
+		t.Errorf("refused subscription keeps record %+v", node.Record)
+	}
+}
+
+func TestSubmgrErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "submgr down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	backend, subscriptions := restBackend(t, server.URL+"/ric/v1")
+
+	err := backend.Subscribe(SUBMGR_RAN_NAME, submgrRequest(t))
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "submgr down") {
+		t.Fatalf("Subscribe returned %v, want the 503 of the subscription manager", err)
+	}
+	if node := submgrNode(subscriptions); node.Record != nil {
+		t.Errorf("refused subscription recorded as %+v", node.Record)
+	}
+
+	subscriptions.Sent(SUBMGR_RAN_NAME, SubscriptionRecord{RestID: "1"})
+	record, _ := subscriptions.Deleting(SUBMGR_RAN_NAME)
+	if err := backend.Unsubscribe(SUBMGR_RAN_NAME, record); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Unsubscribe returned %v, want the 503 of the subscription manager", err)
+	}
+	if node := submgrNode(subscriptions); node.State != SUBSCRIPTION_DELETING {
+		t.Errorf("subscription is %s after a refused delete, want %s", node.State, SUBSCRIPTION_DELETING)
+	}
+}
+
+func TestSubmgrUnknownNotification(t *testing.T) {
+	standIn := NewSubmgrStandIn("")
+	server := httptest.NewServer(standIn.server.Handler)
+	defer server.Close()
+	backend, subscriptions := restBackend(t, server.URL+"/ric/v1")
+
+	endpoint := backend.(*restSubscriptionBackend).endpoint
+	url := "http://" + net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.HTTPPort)) + SUBMGR_NOTIFICATION_PATH
+	body, _ := json.Marshal(&submgrSubscriptionResponse{SubscriptionID: "42", SubscriptionInstances: []submgrSubscriptionInstance{{E2EventInstanceID: 42}}})
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("notification answered with %d, want 200", resp.StatusCode)
+	}
+	if node := submgrNode(subscriptions); node.State != SUBSCRIPTION_RETRYING || node.Record != nil {
+		t.Errorf("notification of an unknown subscription changed %s to %s, record %+v", SUBMGR_RAN_NAME, node.State, node.Record)
+	}
+}
@@ -0,0 +1,578 @@
+package control
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const (
+	SUBSCRIPTION_PENDING  = "pending"  //RIC_SUB_REQ sent, waiting for the response
+	SUBSCRIPTION_ACTIVE   = "active"   //subscribed, the E2 node reports
+	SUBSCRIPTION_FAILED   = "failed"   //the last request failed and will not be retried
+	SUBSCRIPTION_RETRYING = "retrying" //waiting for the backoff to (re)send the request
+	SUBSCRIPTION_DELETING = "deleting" //RIC_SUB_DEL_REQ sent, waiting for the response
+	SUBSCRIPTION_DELETED  = "deleted"  //deleted, the E2 node is not resubscribed
+)
+
+type SubscriptionConfig struct {
+	StartDelay      time.Duration //delay of the first request after the transport is ready
+	Backoff         time.Duration //delay of the first retry, doubled for each further one
+	MaxBackoff      time.Duration //bound of the retry delay
+	MaxAttempts     int           //requests sent before giving up on an E2 node
+	ResponseTimeout time.Duration //time to wait for RIC_SUB_RESP or RIC_SUB_FAILURE
+	IdleTimeout     time.Duration //time without indications after which an active E2 node is resubscribed, IdlePeriods report periods when 0
+	IdlePeriods     int           //report periods of an E2 node without indications after which it is resubscribed, 0 to never resubscribe
+	DeleteTimeout   time.Duration //time to wait for RIC_SUB_DEL_RESP or RIC_SUB_DEL_FAILURE
+
+	Backend      string //rmr or rest
+	SubmgrURL    string //base URL of the subscription manager REST API, rest only
+	CallbackAddr string //listen address of the subscription manager notifications, rest only
+	ClientHost   string //host the subscription manager reaches kpimon at, the host name when empty, rest only
+	RmrPort      int    //RMR data port the subscription manager routes to, rest only
+}
+
+// LoadSubscriptionConfig reads the subscription lifecycle configuration from the xApp environment
+// (see appenv in the xApp descriptor)
+func LoadSubscriptionConfig() (SubscriptionConfig, error) {
+	cfg := SubscriptionConfig{
+		IdlePeriods:  IDLE_REPORT_PERIODS,
+		Backend:      os.Getenv("subscriptionBackend"),
+		SubmgrURL:    os.Getenv("submgrUrl"),
+		CallbackAddr: os.Getenv("subscriptionCallbackAddr"),
+		ClientHost:   os.Getenv("subscriptionClientHost"),
+	}
+	durations := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"subscriptionStartDelay", &cfg.StartDelay},
+		{"subscriptionBackoff", &cfg.Backoff},
+		{"subscriptionMaxBackoff", &cfg.MaxBackoff},
+		{"subscriptionResponseTimeout", &cfg.ResponseTimeout},
+		{"subscriptionIdleTimeout", &cfg.IdleTimeout},
+		{"subscriptionDeleteTimeout", &cfg.DeleteTimeout},
+	}
+	for _, d := range durations {
+		if value, err := time.ParseDuration(os.Getenv(d.env)); err == nil {
+			*d.value = value
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("subscriptionMaxAttempts")); err == nil {
+		cfg.MaxAttempts = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("subscriptionIdlePeriods")); err == nil {
+		cfg.IdlePeriods = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("subscriptionRmrPort")); err == nil {
+		cfg.RmrPort = n
+	}
+	cfg = cfg.withDefaults()
+	if cfg.Backend != SUBSCRIPTION_BACKEND_RMR && cfg.Backend != SUBSCRIPTION_BACKEND_REST {
+		return cfg, errors.New("Unknown subscription backend: " + cfg.Backend)
+	}
+	return cfg, nil
+}
+
+func (cfg SubscriptionConfig) withDefaults() SubscriptionConfig {
+	if cfg.StartDelay <= 0 {
+		cfg.StartDelay = 5 * time.Second
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 5 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = 5 * time.Minute
+		if cfg.MaxBackoff < cfg.Backoff {
+			cfg.MaxBackoff = cfg.Backoff
+		}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = MAX_SUBSCRIPTION_ATTEMPTS
+	}
+	if cfg.ResponseTimeout <= 0 {
+		cfg.ResponseTimeout = 5 * time.Second
+	}
+	if cfg.DeleteTimeout <= 0 {
+		cfg.DeleteTimeout = 5 * time.Second
+	}
+	if cfg.IdleTimeout < 0 {
+		cfg.IdleTimeout = 0
+	}
+	if cfg.IdlePeriods < 0 {
+		cfg.IdlePeriods = 0
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = SUBSCRIPTION_BACKEND_RMR
+	}
+	if cfg.SubmgrURL == "" {
+		cfg.SubmgrURL = DEFAULT_SUBMGR_URL
+	}
+	if cfg.CallbackAddr == "" {
+		cfg.CallbackAddr = DEFAULT_SUBSCRIPTION_CALLBACK_ADDR
+	}
+	if cfg.RmrPort == 0 {
+		cfg.RmrPort = DEFAULT_RMR_DATA_PORT
+	}
+	return cfg
+}
+
+// SubscriptionRecord identifies the subscription of an E2 node as the RIC_SUB_DEL_REQ has to address it
+type SubscriptionRecord struct {
+	SubID       int    `json:"sub_id"`            //RMR subscription ID
+	RequestorID int    `json:"requestor_id"`      //RIC requestor ID
+	RequestSN   int    `json:"request_sn"`        //RIC instance ID, the sequence number of the request
+	FuncID      int    `json:"ran_function_id"`   //RAN function ID
+	RestID      string `json:"rest_id,omitempty"` //subscription manager subscription ID, rest backend only
+}
+
+// NodeSubscription is the subscription state of an E2 node
+type NodeSubscription struct {
+	RanName        string              `json:"ran_name"`
+	State          string              `json:"state"`
+	Since          time.Time           `json:"since"`    //time of the last state change
+	Attempts       int                 `json:"attempts"` //requests sent since the E2 node was last active
+	LastError      string              `json:"last_error,omitempty"`
+	NextAttempt    time.Time           `json:"next_attempt"`     //time of the next request when retrying
+	LastIndication time.Time           `json:"last_indication"`  //time of the last indication, zero before the first one
+	Record         *SubscriptionRecord `json:"record,omitempty"` //the last request sent, nil before the first one
+
+	resubscribe bool //Deleting to be subscribed again once deleted
+}
+
+// Subscriptions drives the subscription of each E2 node through Pending, Active, Failed, Retrying, Deleting and
+// Deleted: a request that times out or fails is retried with exponential backoff unless the failure
+// cause says a retry cannot succeed, and an active E2 node whose indications stop has its subscription deleted
+// and is resubscribed
+type Subscriptions struct {
+	cfg         SubscriptionConfig
+	nodes       map[string]*NodeSubscription
+	send        func(ranName string) error                             //sends the RIC_SUB_REQ of an E2 node
+	unsubscribe func(ranName string, record *SubscriptionRecord) error //sends the RIC_SUB_DEL_REQ of an E2 node
+	periods     func(ranName string) time.Duration                     //report period of an E2 node
+	policy      FailurePolicy
+	mu          *sync.Mutex
+	stop        chan struct{}
+	once        *sync.Once
+}
+
+func NewSubscriptions(cfg SubscriptionConfig, ranList []string) *Subscriptions {
+	s := &Subscriptions{
+		cfg:    cfg.withDefaults(),
+		nodes:  make(map[string]*NodeSubscription),
+		policy: DefaultFailurePolicy,
+		mu:     &sync.Mutex{},
+		stop:   make(chan struct{}),
+		once:   &sync.Once{},
+	}
+	for _, ranName := range ranList {
+		if ranName != "" {
+			s.nodes[ranName] = &NodeSubscription{RanName: ranName, State: SUBSCRIPTION_RETRYING}
+		}
+	}
+	return s
+}
+
+// Start schedules the first request of every E2 node and runs the lifecycle until Stop
+func (s *Subscriptions) Start(send func(ranName string) error, unsubscribe func(ranName string, record *SubscriptionRecord) error) {
+	s.mu.Lock()
+	s.send, s.unsubscribe = send, unsubscribe
+	now := time.Now()
+	for _, node := range s.nodes {
+		if node.State == SUBSCRIPTION_RETRYING {
+			node.Since, node.NextAttempt = now, now.Add(s.cfg.StartDelay)
+		}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.tick(now)
+			}
+		}
+	}()
+}
+
+// Stop ends the lifecycle: no request is sent or expired afterwards, deletions still work
+func (s *Subscriptions) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// tick sends the requests that are due, expires the ones left without response and deletes the
+// subscriptions of the idle E2 nodes before they are resubscribed
+func (s *Subscriptions) tick(now time.Time) {
+	var due []string
+	deleting := make(map[string]*SubscriptionRecord)
+	s.mu.Lock()
+	for ranName, node := range s.nodes {
+		switch node.State {
+		case SUBSCRIPTION_PENDING:
+			if now.Sub(node.Since) >= s.cfg.ResponseTimeout {
+				s.fail(node, now, "no response within "+s.cfg.ResponseTimeout.String(), true)
+			}
+		case SUBSCRIPTION_ACTIVE:
+			if idle := s.idleTimeout(ranName); idle > 0 && now.Sub(node.LastIndication) >= idle {
+				reason := "no indication within " + idle.String()
+				if node.Record == nil {
+					s.transition(node, SUBSCRIPTION_RETRYING, now, reason)
+					node.Attempts, node.NextAttempt = 0, now
+				} else {
+					node.resubscribe = true
+					s.transition(node, SUBSCRIPTION_DELETING, now, reason+", RIC_SUB_DEL_REQ")
+					deleting[ranName] = node.Record
+				}
+			}
+		case SUBSCRIPTION_DELETING:
+			if node.resubscribe && now.Sub(node.Since) >= s.cfg.DeleteTimeout {
+				node.LastError = "no RIC_SUB_DEL_RESP within " + s.cfg.DeleteTimeout.String()
+				s.deleted(node, now, node.LastError)
+			}
+		}
+		if node.State == SUBSCRIPTION_RETRYING && !now.Before(node.NextAttempt) {
+			node.Attempts++
+			s.transition(node, SUBSCRIPTION_PENDING, now, "attempt "+strconv.Itoa(node.Attempts))
+			due = append(due, ranName)
+		}
+	}
+	send, unsubscribe := s.send, s.unsubscribe
+	s.mu.Unlock()
+
+	for ranName, record := range deleting {
+		if err := unsubscribe(ranName, record); err != nil {
+			s.DeleteFailed(ranName, err.Error())
+		}
+	}
+	for _, ranName := range due {
+		if err := send(ranName); err != nil {
+			s.mu.Lock()
+			if node, ok := s.nodes[ranName]; ok && node.State == SUBSCRIPTION_PENDING {
+				s.fail(node, time.Now(), err.Error(), true)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Sent records the request sent to an E2 node
+func (s *Subscriptions) Sent(ranName string, record SubscriptionRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if node, ok := s.nodes[ranName]; ok {
+		node.Record = &record
+	}
+}
+
+// Responded records the RIC_SUB_RESP of an E2 node. A response arriving after the request timed out
+// still makes the E2 node active, it does report.
+func (s *Subscriptions) Responded(ranName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok || node.State == SUBSCRIPTION_DELETING || node.State == SUBSCRIPTION_DELETED || node.State == SUBSCRIPTION_ACTIVE {
+		return
+	}
+	now := time.Now()
+	s.transition(node, SUBSCRIPTION_ACTIVE, now, "RIC_SUB_RESP")
+	node.Attempts, node.LastError, node.LastIndication = 0, "", now
+}
+
+// Failed records the RIC_SUB_FAILURE of an E2 node, retried when retry is true
+func (s *Subscriptions) Failed(ranName string, reason string, retry bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok || node.State != SUBSCRIPTION_PENDING && node.State != SUBSCRIPTION_RETRYING {
+		return
+	}
+	node.Record = nil //refused, there is nothing to delete
+	s.fail(node, time.Now(), reason, retry)
+}
+
+// Changed records that the request of an E2 node changed after a failure: the new request is sent
+// right away and has the attempts of a first one
+func (s *Subscriptions) Changed(ranName string, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok || node.State != SUBSCRIPTION_PENDING && node.State != SUBSCRIPTION_RETRYING && node.State != SUBSCRIPTION_FAILED {
+		return
+	}
+	now := time.Now()
+	s.transition(node, SUBSCRIPTION_RETRYING, now, reason)
+	node.Attempts, node.LastError, node.NextAttempt = 0, "", now
+}
+
+// SetReportPeriods sets the report period of each E2 node the idle timeout is derived from when IdleTimeout is 0
+func (s *Subscriptions) SetReportPeriods(periods func(ranName string) time.Duration) {
+	s.mu.Lock()
+	s.periods = periods
+	s.mu.Unlock()
+}
+
+// idleTimeout returns the time without indications after which an active E2 node is resubscribed, 0 for never
+func (s *Subscriptions) idleTimeout(ranName string) time.Duration {
+	if s.cfg.IdleTimeout > 0 {
+		return s.cfg.IdleTimeout
+	}
+	if s.cfg.IdlePeriods == 0 || s.periods == nil {
+		return 0
+	}
+	return time.Duration(s.cfg.IdlePeriods) * s.periods(ranName)
+}
+
+// SetFailurePolicy replaces DefaultFailurePolicy
+func (s *Subscriptions) SetFailurePolicy(policy FailurePolicy) {
+	s.mu.Lock()
+	s.policy = policy
+	s.mu.Unlock()
+}
+
+// decide applies the failure policy to a RIC Subscription Failure of an E2 node
+func (s *Subscriptions) decide(ranName string, failure *DecodedSubscriptionFailureMessage) int {
+	s.mu.Lock()
+	policy := s.policy
+	s.mu.Unlock()
+	if policy == nil {
+		return DefaultFailurePolicy(ranName, failure)
+	}
+	return policy(ranName, failure)
+}
+
+// Indication records an indication of an E2 node, which proves a subscription that is not known to be active yet
+func (s *Subscriptions) Indication(ranName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	node.LastIndication = now
+	if node.State == SUBSCRIPTION_PENDING || node.State == SUBSCRIPTION_RETRYING {
+		s.transition(node, SUBSCRIPTION_ACTIVE, now, "RIC_INDICATION")
+		node.Attempts, node.LastError = 0, ""
+	}
+}
+
+// Deleting moves an E2 node that may be subscribed to Deleting and returns the request to delete,
+// an E2 node without subscription is deleted right away. An E2 node being deleted to be resubscribed
+// stays deleted instead, with no request to send. ok is false for unknown E2 nodes and the ones
+// already deleted or being deleted.
+func (s *Subscriptions) Deleting(ranName string) (record *SubscriptionRecord, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if ok && node.State == SUBSCRIPTION_DELETING && node.resubscribe {
+		node.resubscribe = false
+		return nil, true
+	}
+	if !ok || node.State == SUBSCRIPTION_DELETING || node.State == SUBSCRIPTION_DELETED {
+		return nil, false
+	}
+	if node.Record == nil {
+		s.transition(node, SUBSCRIPTION_DELETED, time.Now(), "not subscribed")
+		return nil, true
+	}
+	s.transition(node, SUBSCRIPTION_DELETING, time.Now(), "RIC_SUB_DEL_REQ")
+	return node.Record, true
+}
+
+// Deleted records the deletion of the subscription of an E2 node being deleted, or whose deletion timed
+// out. A late response to the deletion of an E2 node subscribed again since is ignored.
+func (s *Subscriptions) Deleted(ranName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if node, ok := s.nodes[ranName]; ok && (node.State == SUBSCRIPTION_DELETING || node.State == SUBSCRIPTION_FAILED) {
+		s.deleted(node, time.Now(), "RIC_SUB_DEL_RESP")
+	}
+}
+
+// DeleteFailed records that the subscription of an E2 node could not be deleted. The E2 node is
+// left Failed, it may still report, unless it was being deleted to be resubscribed.
+func (s *Subscriptions) DeleteFailed(ranName string, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if node, ok := s.nodes[ranName]; ok && node.State == SUBSCRIPTION_DELETING {
+		node.LastError = reason
+		if node.resubscribe {
+			s.deleted(node, time.Now(), reason)
+			return
+		}
+		s.transition(node, SUBSCRIPTION_FAILED, time.Now(), reason)
+	}
+}
+
+// ErrorIndicated records an Error Indication of an E2 node about the request of a RIC request ID and tells
+// whether that is the last request sent to it. A pending request is failed, retried when retry is true. A
+// subscription the E2 node no longer knows (request-id-unknown) is sent again when active and deleted when
+// being deleted, other errors only mark it.
+func (s *Subscriptions) ErrorIndicated(ranName string, requestorID int, requestSN int, unknown bool, reason string, retry bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok || node.Record == nil || node.Record.RequestorID != requestorID || node.Record.RequestSN != requestSN {
+		return false
+	}
+	now := time.Now()
+	node.LastError = reason
+	switch {
+	case node.State == SUBSCRIPTION_PENDING:
+		node.Record = nil
+		s.fail(node, now, reason, retry)
+	case node.State == SUBSCRIPTION_ACTIVE && unknown:
+		node.Record = nil
+		s.transition(node, SUBSCRIPTION_RETRYING, now, reason)
+		node.Attempts, node.NextAttempt = 0, now
+	case node.State == SUBSCRIPTION_DELETING && unknown:
+		s.deleted(node, now, reason)
+	}
+	return true
+}
+
+// WaitDeleted waits until none of the E2 nodes is Deleting any more, at most DeleteTimeout, and fails the
+// deletions left without response
+func (s *Subscriptions) WaitDeleted(ranNames []string) {
+	deadline := time.Now().Add(s.cfg.DeleteTimeout)
+	for {
+		var deleting []string
+		s.mu.Lock()
+		for _, ranName := range ranNames {
+			if node, ok := s.nodes[ranName]; ok && node.State == SUBSCRIPTION_DELETING {
+				deleting = append(deleting, ranName)
+			}
+		}
+		s.mu.Unlock()
+		if len(deleting) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, ranName := range deleting {
+				s.DeleteFailed(ranName, "no RIC_SUB_DEL_RESP within "+s.cfg.DeleteTimeout.String())
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Resubscribe sends the request of a deleted or failed E2 node again, with the attempts of a first one
+func (s *Subscriptions) Resubscribe(ranName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[ranName]
+	if !ok || node.State != SUBSCRIPTION_DELETED && node.State != SUBSCRIPTION_FAILED {
+		return false
+	}
+	now := time.Now()
+	s.transition(node, SUBSCRIPTION_RETRYING, now, "resubscribe")
+	node.Attempts, node.LastError, node.NextAttempt = 0, "", now
+	return true
+}
+
+// Add subscribes a new E2 node right away, or a deleted or failed one again
+func (s *Subscriptions) Add(ranName string) {
+	s.mu.Lock()
+	node, ok := s.nodes[ranName]
+	if !ok {
+		now := time.Now()
+		s.nodes[ranName] = &NodeSubscription{RanName: ranName, State: SUBSCRIPTION_RETRYING, Since: now, NextAttempt: now}
+	}
+	resubscribe := ok && (node.State == SUBSCRIPTION_DELETED || node.State == SUBSCRIPTION_FAILED)
+	s.mu.Unlock()
+	if resubscribe {
+		s.Resubscribe(ranName)
+	}
+}
+
+// Remove forgets an E2 node
+func (s *Subscriptions) Remove(ranName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[ranName]; ok {
+		xapp.Logger.Info("Subscription of {%s} removed", ranName)
+		log.Printf("Subscription of {%s} removed", ranName)
+		delete(s.nodes, ranName)
+	}
+}
+
+// RanNames returns the E2 nodes, sorted
+func (s *Subscriptions) RanNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranNames := make([]string, 0, len(s.nodes))
+	for ranName := range s.nodes {
+		ranNames = append(ranNames, ranName)
+	}
+	sort.Strings(ranNames)
+	return ranNames
+}
+
+// Nodes returns the subscription state of every E2 node, sorted by RAN name
+func (s *Subscriptions) Nodes() []NodeSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes := make([]NodeSubscription, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].RanName < nodes[j].RanName })
+	return nodes
+}
+
+// deleted moves an E2 node whose subscription is gone to Deleted, or to Retrying right away when it was
+// deleted to be resubscribed
+func (s *Subscriptions) deleted(node *NodeSubscription, now time.Time, reason string) {
+	node.Record = nil
+	if !node.resubscribe {
+		s.transition(node, SUBSCRIPTION_DELETED, now, reason)
+		return
+	}
+	node.resubscribe = false
+	s.transition(node, SUBSCRIPTION_RETRYING, now, reason+", resubscribe")
+	node.Attempts, node.NextAttempt = 0, now
+}
+
+// fail moves an E2 node to Failed, then to Retrying when the failure is retried and attempts are left
+func (s *Subscriptions) fail(node *NodeSubscription, now time.Time, reason string, retry bool) {
+	node.LastError = reason
+	s.transition(node, SUBSCRIPTION_FAILED, now, reason)
+	if !retry || node.Attempts >= s.cfg.MaxAttempts {
+		xapp.Logger.Error("Subscription of {%s} given up after %d attempts: %s", node.RanName, node.Attempts, reason)
+		log.Printf("Subscription of {%s} given up after %d attempts: %s", node.RanName, node.Attempts, reason)
+		return
+	}
+	node.NextAttempt = now.Add(s.backoff(node.Attempts))
+	s.transition(node, SUBSCRIPTION_RETRYING, now, "next attempt at "+node.NextAttempt.Format(time.RFC3339))
+}
+
+// backoff returns the delay of the retry after attempts requests: Backoff doubled for each request
+// after the first, bounded by MaxBackoff, minus up to a tenth so that E2 nodes failing together spread out
+func (s *Subscriptions) backoff(attempts int) time.Duration {
+	delay := s.cfg.Backoff
+	for i := 1; i < attempts && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.MaxBackoff {
+		delay = s.cfg.MaxBackoff
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/10+1))
+}
+
+func (s *Subscriptions) transition(node *NodeSubscription, state string, now time.Time, reason string) {
+	xapp.Logger.Info("Subscription of {%s}: %s -> %s (%s)", node.RanName, node.State, state, reason)
+	log.Printf("Subscription of {%s}: %s -> %s (%s)", node.RanName, node.State, state, reason)
+	node.State, node.Since = state, now
+}
@@ -0,0 +1,87 @@
+package control
+
+import (
+	"log"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// SubscriptionBackend subscribes the E2 nodes and deletes their subscriptions. It records the requests
+// it sends in Subscriptions, the outcome arrives later as RMR messages or subscription manager notifications.
+type SubscriptionBackend interface {
+	Start() error
+	Subscribe(ranName string, req *subscriptionRequest) error
+	Unsubscribe(ranName string, record *SubscriptionRecord) error
+	Close() error
+}
+
+// NewSubscriptionBackend creates the backend selected by the configuration, the RMR one when none is
+func NewSubscriptionBackend(cfg SubscriptionConfig, transport Transport, subscriptions *Subscriptions) SubscriptionBackend {
+	if cfg.Backend == SUBSCRIPTION_BACKEND_REST {
+		return NewRestSubscriptionBackend(cfg, subscriptions)
+	}
+	return &rmrSubscriptionBackend{transport, subscriptions}
+}
+
+// rmrSubscriptionBackend sends E2AP RIC_SUB_REQ and RIC_SUB_DEL_REQ messages over the transport,
+// with the RIC instance ID as RMR SubId
+type rmrSubscriptionBackend struct {
+	transport     Transport
+	subscriptions *Subscriptions
+}
+
+func (b *rmrSubscriptionBackend) Start() error {
+	return nil
+}
+
+func (b *rmrSubscriptionBackend) Subscribe(ranName string, req *subscriptionRequest) error {
+	var e2ap *E2ap
+
+	params := &xapp.RMRParams{}
+	params.Mtype = RIC_SUB_REQ
+	params.SubId = req.instanceID
+
+	payload, err := e2ap.SetSubscriptionRequestPayload(make([]byte, 1024), uint16(req.requestorID), uint16(req.instanceID), uint16(req.funcID), req.eventTrigger, len(req.eventTrigger), len(req.actionIds), req.actionIds, req.actionTypes, req.actionDefinitions, req.subsequentActions)
+	if err != nil {
+		return err
+	}
+	params.Payload = payload
+
+	log.Printf("Set Payload: %x", params.Payload)
+
+	params.Meid = &xapp.RMRMeid{RanName: ranName}
+	xapp.Logger.Debug("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+	log.Printf("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+
+	if err = b.transport.Send(params); err != nil {
+		return err
+	}
+	b.subscriptions.Sent(ranName, SubscriptionRecord{SubID: params.SubId, RequestorID: req.requestorID, RequestSN: req.instanceID, FuncID: req.funcID})
+	return nil
+}
+
+func (b *rmrSubscriptionBackend) Unsubscribe(ranName string, record *SubscriptionRecord) error {
+	var e2ap *E2ap
+
+	params := &xapp.RMRParams{}
+	params.Mtype = RIC_SUB_DEL_REQ
+	params.SubId = record.SubID
+
+	payload, err := e2ap.SetSubscriptionDeleteRequestPayload(make([]byte, 1024), uint16(record.RequestorID), uint16(record.RequestSN), uint16(record.FuncID))
+	if err != nil {
+		return err
+	}
+	params.Payload = payload
+
+	log.Printf("Set Payload: %x", params.Payload)
+
+	params.Meid = &xapp.RMRMeid{RanName: ranName}
+	xapp.Logger.Debug("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+	log.Printf("The RMR message to be sent is %d with SubId=%d", params.Mtype, params.SubId)
+
+	return b.transport.Send(params)
+}
+
+func (b *rmrSubscriptionBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,472 @@
+package control
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const (
+	LIFECYCLE_RAN_NAME = "gnb_lifecycle"
+	LIFECYCLE_BACKOFF  = 10 * time.Second
+)
+
+var lifecycleConfig = SubscriptionConfig{
+	Backoff:         LIFECYCLE_BACKOFF,
+	MaxBackoff:      30 * time.Second,
+	MaxAttempts:     4,
+	ResponseTimeout: 5 * time.Second,
+	IdleTimeout:     time.Minute,
+	DeleteTimeout:   3 * time.Second,
+}
+
+// lifecycle builds a Control subscribing its E2 nodes over a ChanTransport. Its lifecycle runs on the
+// ticks of the test only, at the times the test gives.
+func lifecycle(cfg SubscriptionConfig, ranNames ...string) (*Control, *ChanTransport) {
+	transport := NewChanTransport(16)
+	c := NewControlWith(ranNames, NewMemoryMetricsStore(), transport, nil, nil, nil, nil, nil, cfg)
+	c.subscriptions.send, c.subscriptions.unsubscribe = c.sendRicSubRequest, c.subscriber.Unsubscribe
+	return &c, transport
+}
+
+// sentMessage returns the message sent last over the transport, which has to be of mtype to ranName
+func sentMessage(t *testing.T, transport *ChanTransport, mtype int, ranName string) *xapp.RMRParams {
+	t.Helper()
+	select {
+	case params := <-transport.Sent():
+		if params.Mtype != mtype || params.Meid.RanName != ranName {
+			t.Fatalf("sent message type %d to {%s}, want %d to {%s}", params.Mtype, params.Meid.RanName, mtype, ranName)
+		}
+		return params
+	default:
+		t.Fatalf("no message %d sent to {%s}", mtype, ranName)
+		return nil
+	}
+}
+
+// noMessage checks that nothing was sent over the transport
+func noMessage(t *testing.T, transport *ChanTransport) {
+	t.Helper()
+	select {
+	case params := <-transport.Sent():
+		t.Fatalf("sent message type %d to {%s}", params.Mtype, params.Meid.RanName)
+	default:
+	}
+}
+
+func nodeSubscription(t *testing.T, s *Subscriptions, ranName string) NodeSubscription {
+	t.Helper()
+	for _, node := range s.Nodes() {
+		if node.RanName == ranName {
+			return node
+		}
+	}
+	t.Fatalf("no subscription of {%s}", ranName)
+	return NodeSubscription{}
+}
+
+func checkState(t *testing.T, s *Subscriptions, ranName string, state string, attempts int) NodeSubscription {
+	t.Helper()
+	node := nodeSubscription(t, s, ranName)
+	if node.State != state || node.Attempts != attempts {
+		t.Fatalf("{%s} %s after %d attempts (%s), want %s after %d", ranName, node.State, node.Attempts, node.LastError, state, attempts)
+	}
+	return node
+}
+
+// checkBackoff checks that the next attempt of a node is delay after now, less the jitter of up to a tenth
+func checkBackoff(t *testing.T, node NodeSubscription, now time.Time, delay time.Duration) {
+	t.Helper()
+	if wait := node.NextAttempt.Sub(now); wait > delay || wait < delay-delay/10 {
+		t.Fatalf("next attempt of {%s} in %v, want %v less up to a tenth", node.RanName, wait, delay)
+	}
+}
+
+// subscribe ticks the first request of the E2 node out and responds to it
+func subscribe(t *testing.T, c *Control, transport *ChanTransport, ranName string) {
+	t.Helper()
+	c.subscriptions.tick(time.Now())
+	sentMessage(t, transport, RIC_SUB_REQ, ranName)
+	c.subscriptions.Responded(ranName)
+	checkState(t, c.subscriptions, ranName, SUBSCRIPTION_ACTIVE, 0)
+}
+
+func TestSubscriptionResponseTimeout(t *testing.T) {
+	c, transport := lifecycle(lifecycleConfig, LIFECYCLE_RAN_NAME)
+	s := c.subscriptions
+	now := time.Now()
+
+	s.tick(now)
+	params := sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	if params.SubId != SUBSCRIPTION_INSTANCE_ID {
+		t.Errorf("RIC_SUB_REQ of SubId %d, want %d", params.SubId, SUBSCRIPTION_INSTANCE_ID)
+	}
+	node := checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, 1)
+	if node.Record == nil || node.Record.RequestorID != SUBSCRIPTION_REQUESTOR_ID || node.Record.RequestSN != SUBSCRIPTION_INSTANCE_ID {
+		t.Errorf("record of the request %+v", node.Record)
+	}
+
+	s.tick(now.Add(lifecycleConfig.ResponseTimeout - time.Millisecond))
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, 1)
+
+	//each timeout doubles the delay of the next attempt up to MaxBackoff
+	for attempt, delay := range []time.Duration{LIFECYCLE_BACKOFF, 2 * LIFECYCLE_BACKOFF, lifecycleConfig.MaxBackoff} {
+		now = now.Add(lifecycleConfig.ResponseTimeout)
+		s.tick(now)
+		node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, attempt+1)
+		if !strings.Contains(node.LastError, "no response within") {
+			t.Errorf("last error %q of a response timeout", node.LastError)
+		}
+		checkBackoff(t, node, now, delay)
+		noMessage(t, transport)
+
+		s.tick(node.NextAttempt.Add(-time.Millisecond))
+		checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, attempt+1)
+		now = node.NextAttempt
+		s.tick(now)
+		sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+		checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, attempt+2)
+	}
+
+	//the timeout of the last attempt gives up
+	now = now.Add(lifecycleConfig.ResponseTimeout)
+	s.tick(now)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_FAILED, lifecycleConfig.MaxAttempts)
+	s.tick(now.Add(time.Hour))
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_FAILED, lifecycleConfig.MaxAttempts)
+	noMessage(t, transport)
+
+	//a late response still makes it active
+	s.Responded(LIFECYCLE_RAN_NAME)
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+	if node.LastError != "" {
+		t.Errorf("last error %q left once active", node.LastError)
+	}
+}
+
+func TestSubscriptionIdleTimeout(t *testing.T) {
+	c, transport := lifecycle(lifecycleConfig, LIFECYCLE_RAN_NAME)
+	s := c.subscriptions
+	subscribe(t, c, transport, LIFECYCLE_RAN_NAME)
+
+	now := time.Now()
+	s.tick(now.Add(lifecycleConfig.IdleTimeout / 2))
+	s.Indication(LIFECYCLE_RAN_NAME)
+	now = time.Now()
+	s.tick(now.Add(lifecycleConfig.IdleTimeout - time.Millisecond))
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+	noMessage(t, transport)
+
+	//idle: the subscription is deleted, then sent again once the deletion is responded to
+	now = now.Add(lifecycleConfig.IdleTimeout)
+	s.tick(now)
+	sentMessage(t, transport, RIC_SUB_DEL_REQ, LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+	s.Deleted(LIFECYCLE_RAN_NAME)
+	node := checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, 0)
+	if node.Record != nil {
+		t.Errorf("record %+v of a deleted subscription", node.Record)
+	}
+	s.tick(time.Now())
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, 1)
+
+	//idle again: the E2 node does not respond to the deletion either and is resubscribed after DeleteTimeout
+	s.Responded(LIFECYCLE_RAN_NAME)
+	now = time.Now().Add(lifecycleConfig.IdleTimeout)
+	s.tick(now)
+	sentMessage(t, transport, RIC_SUB_DEL_REQ, LIFECYCLE_RAN_NAME)
+	s.tick(now.Add(lifecycleConfig.DeleteTimeout - time.Millisecond))
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+	now = now.Add(lifecycleConfig.DeleteTimeout)
+	s.tick(now)
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, 1)
+	if !strings.Contains(node.LastError, "no RIC_SUB_DEL_RESP within") {
+		t.Errorf("last error %q of a deletion timeout", node.LastError)
+	}
+
+	//a failed deletion resubscribes as well
+	s.Responded(LIFECYCLE_RAN_NAME)
+	s.tick(time.Now().Add(lifecycleConfig.IdleTimeout))
+	sentMessage(t, transport, RIC_SUB_DEL_REQ, LIFECYCLE_RAN_NAME)
+	s.DeleteFailed(LIFECYCLE_RAN_NAME, "RIC_SUB_DEL_FAILURE")
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, 0)
+}
+
+func TestSubscriptionIdlePeriods(t *testing.T) {
+	cfg := lifecycleConfig
+	cfg.IdleTimeout, cfg.IdlePeriods = 0, 3
+	c, transport := lifecycle(cfg, LIFECYCLE_RAN_NAME)
+	s := c.subscriptions
+	s.SetReportPeriods(func(ranName string) time.Duration { return 2 * time.Second })
+	subscribe(t, c, transport, LIFECYCLE_RAN_NAME)
+
+	now := time.Now()
+	s.tick(now.Add(6*time.Second - time.Millisecond))
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+	s.tick(now.Add(6 * time.Second))
+	sentMessage(t, transport, RIC_SUB_DEL_REQ, LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+
+	//no idle periods: never resubscribed
+	cfg.IdlePeriods = 0
+	c, transport = lifecycle(cfg, LIFECYCLE_RAN_NAME)
+	subscribe(t, c, transport, LIFECYCLE_RAN_NAME)
+	c.subscriptions.tick(time.Now().Add(24 * time.Hour))
+	checkState(t, c.subscriptions, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+	noMessage(t, transport)
+}
+
+func TestSubscriptionOutcomes(t *testing.T) {
+	c, transport := lifecycle(lifecycleConfig, LIFECYCLE_RAN_NAME)
+	s := c.subscriptions
+	now := time.Now()
+
+	//an indication proves a subscription left without response
+	s.tick(now)
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	s.Indication(LIFECYCLE_RAN_NAME)
+	node := checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+	if node.LastIndication.Before(now) {
+		t.Errorf("last indication %v before %v", node.LastIndication, now)
+	}
+	s.Failed(LIFECYCLE_RAN_NAME, "late RIC_SUB_FAILURE", true)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+
+	//a failure to retry backs off, one not to retry gives up
+	c, transport = lifecycle(lifecycleConfig, LIFECYCLE_RAN_NAME)
+	s = c.subscriptions
+	s.tick(now)
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	s.Failed(LIFECYCLE_RAN_NAME, "RIC_SUB_FAILURE", true)
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, 1)
+	if node.LastError != "RIC_SUB_FAILURE" || node.Record != nil {
+		t.Errorf("last error %q and record %+v of a refused request", node.LastError, node.Record)
+	}
+	checkBackoff(t, node, node.Since, LIFECYCLE_BACKOFF)
+	s.tick(node.NextAttempt)
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	s.Failed(LIFECYCLE_RAN_NAME, "RIC_SUB_FAILURE", false)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_FAILED, 2)
+	s.tick(now.Add(time.Hour))
+	noMessage(t, transport)
+
+	//a changed request, e.g. of the fallback profile, is sent right away as a first one
+	s.Changed(LIFECYCLE_RAN_NAME, "fallback profile")
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, 0)
+	if node.LastError != "" {
+		t.Errorf("last error %q left by a changed request", node.LastError)
+	}
+	s.tick(time.Now())
+	sentMessage(t, transport, RIC_SUB_REQ, LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_PENDING, 1)
+	s.Responded(LIFECYCLE_RAN_NAME)
+	s.Changed(LIFECYCLE_RAN_NAME, "fallback profile")
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_ACTIVE, 0)
+
+	//a request the transport cannot send is retried
+	transport = NewChanTransport(0)
+	closed := NewControlWith([]string{LIFECYCLE_RAN_NAME}, NewMemoryMetricsStore(), transport, nil, nil, nil, nil, nil, lifecycleConfig)
+	s = closed.subscriptions
+	s.send, s.unsubscribe = closed.sendRicSubRequest, closed.subscriber.Unsubscribe
+	transport.Close()
+	s.tick(time.Now())
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_RETRYING, 1)
+	if node.LastError != "transport closed" {
+		t.Errorf("last error %q of a request not sent", node.LastError)
+	}
+}
+
+func TestSubscriptionDeletion(t *testing.T) {
+	c, transport := lifecycle(lifecycleConfig, LIFECYCLE_RAN_NAME, "gnb_unsubscribed")
+	s := c.subscriptions
+
+	//an E2 node never subscribed has nothing to delete
+	if record, ok := s.Deleting("gnb_unsubscribed"); !ok || record != nil {
+		t.Errorf("deletion of an E2 node never subscribed returned %+v, %v", record, ok)
+	}
+	checkState(t, s, "gnb_unsubscribed", SUBSCRIPTION_DELETED, 0)
+	subscribe(t, c, transport, LIFECYCLE_RAN_NAME)
+	if _, ok := s.Deleting("gnb_unknown"); ok {
+		t.Error("deletion of an unknown E2 node")
+	}
+
+	record, ok := s.Deleting(LIFECYCLE_RAN_NAME)
+	if !ok || record == nil || record.RequestorID != SUBSCRIPTION_REQUESTOR_ID || record.RequestSN != SUBSCRIPTION_INSTANCE_ID {
+		t.Fatalf("deletion returned %+v, %v", record, ok)
+	}
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+	if _, ok := s.Deleting(LIFECYCLE_RAN_NAME); ok {
+		t.Error("deletion of an E2 node being deleted")
+	}
+	s.Responded(LIFECYCLE_RAN_NAME)
+	s.Indication(LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+	s.tick(time.Now().Add(time.Hour)) //only a deletion to resubscribe times out on ticks
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETING, 0)
+
+	//a failed deletion leaves the E2 node failed, a late response still deletes it
+	s.DeleteFailed(LIFECYCLE_RAN_NAME, "RIC_SUB_DEL_FAILURE")
+	node := checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_FAILED, 0)
+	if node.LastError != "RIC_SUB_DEL_FAILURE" || node.Record == nil {
+		t.Errorf("last error %q and record %+v of a failed deletion", node.LastError, node.Record)
+	}
+	s.Deleted(LIFECYCLE_RAN_NAME)
+	node = checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETED, 0)
+	if node.Record != nil {
+		t.Errorf("record %+v of a deleted subscription", node.Record)
+	}
+	s.tick(time.Now().Add(time.Hour))
+	noMessage(t, transport)
+
+	//deleted E2 nodes are subscribed again on request only
+	if !s.Resubscribe(LIFECYCLE_RAN_NAME) || s.Resubscribe(LIFECYCLE_RAN_NAME) {
+		t.Error("resubscription of a deleted E2 node, then of one retrying")
+	}
+	subscribe(t, c, transport, LIFECYCLE_RAN_NAME)
+
+	//deleting an E2 node being deleted to be resubscribed keeps it deleted
+	s.tick(time.Now().Add(lifecycleConfig.IdleTimeout))
+	sentMessage(t, transport, RIC_SUB_DEL_REQ, LIFECYCLE_RAN_NAME)
+	if record, ok := s.Deleting(LIFECYCLE_RAN_NAME); !ok || record != nil {
+		t.Errorf("deletion of an E2 node being resubscribed returned %+v, %v", record, ok)
+	}
+	s.Deleted(LIFECYCLE_RAN_NAME)
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETED, 0)
+}
+
+func TestSubscriptionWaitDeleted(t *testing.T) {
+	cfg := lifecycleConfig
+	cfg.DeleteTimeout = 300 * time.Millisecond
+	c, transport := lifecycle(cfg, LIFECYCLE_RAN_NAME, "gnb_silent")
+	s := c.subscriptions
+	s.tick(time.Now())
+	for i := 0; i < 2; i++ {
+		<-transport.Sent()
+	}
+	s.Responded(LIFECYCLE_RAN_NAME)
+	s.Responded("gnb_silent")
+	s.Deleting(LIFECYCLE_RAN_NAME)
+	s.Deleting("gnb_silent")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Deleted(LIFECYCLE_RAN_NAME)
+	}()
+	start := time.Now()
+	s.WaitDeleted([]string{LIFECYCLE_RAN_NAME})
+	if waited := time.Since(start); waited >= cfg.DeleteTimeout {
+		t.Errorf("waited %v for a deletion responded to", waited)
+	}
+	checkState(t, s, LIFECYCLE_RAN_NAME, SUBSCRIPTION_DELETED, 0)
+	checkState(t, s, "gnb_silent", SUBSCRIPTION_DELETING, 0)
+
+	s.WaitDeleted([]string{LIFECYCLE_RAN_NAME, "gnb_silent"})
+	if waited := time.Since(start); waited < cfg.DeleteTimeout {
+		t.Errorf("waited %v for a deletion left without response, want DeleteTimeout", waited)
+	}
+	node := checkState(t, s, "gnb_silent", SUBSCRIPTION_FAILED, 0)
+	if !strings.Contains(node.LastError, "no RIC_SUB_DEL_RESP within") {
+		t.Errorf("last error %q of a deletion timeout", node.LastError)
+	}
+}
+
+// e2Node answers the RIC_SUB_DEL_REQs sent over the transport as the E2 nodes would: the ones of respond
+// with a RIC_SUB_DEL_RESP, the others not at all. It checks that each request addresses the subscription
+// of records and hands the RAN name to deleted.
+func e2Node(t *testing.T, transport *ChanTransport, records map[string]SubscriptionRecord, respond map[string]bool, deleted chan<- string) {
+	var e2ap *E2ap
+	for params := range transport.Sent() {
+		ranName := params.Meid.RanName
+		record := records[ranName]
+		if params.Mtype != RIC_SUB_DEL_REQ || params.SubId != record.SubID {
+			t.Errorf("sent message type %d of SubId %d to {%s}, want RIC_SUB_DEL_REQ of SubId %d", params.Mtype, params.SubId, ranName, record.SubID)
+			continue
+		}
+		req, err := e2ap.GetSubscriptionDeleteRequestMessage(params.Payload)
+		if err != nil {
+			t.Errorf("RIC_SUB_DEL_REQ to {%s}: %v", ranName, err)
+			continue
+		}
+		if int(req.RequestID) != record.RequestorID || int(req.RequestSequenceNumber) != record.RequestSN || int(req.FuncID) != record.FuncID {
+			t.Errorf("RIC_SUB_DEL_REQ %+v to {%s}, want the request of %+v", req, ranName, record)
+		}
+		deleted <- ranName
+		if !respond[ranName] {
+			continue
+		}
+		payload, err := e2ap.SetSubscriptionDeleteResponsePayload(make([]byte, 1024), uint16(req.RequestID), uint16(req.RequestSequenceNumber), uint16(req.FuncID))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		transport.Deliver(&xapp.RMRParams{Mtype: RIC_SUB_DEL_RESP, SubId: params.SubId, Payload: payload, PayloadLen: len(payload), Meid: &xapp.RMRMeid{RanName: ranName}})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	cfg := lifecycleConfig
+	cfg.DeleteTimeout = 500 * time.Millisecond
+	c, transport := lifecycle(cfg, "gnb_responding", "gnb_silent")
+	s := c.subscriptions
+	s.tick(time.Now())
+	for i := 0; i < 2; i++ {
+		<-transport.Sent()
+	}
+	s.Responded("gnb_responding")
+	s.Responded("gnb_silent")
+	records := make(map[string]SubscriptionRecord)
+	for _, node := range s.Nodes() {
+		records[node.RanName] = *node.Record
+	}
+	s.Add("gnb_unsubscribed") //never sent a request, nothing to delete
+
+	go transport.Run(c, func() {})
+	go c.controlLoop()
+	deleted := make(chan string, 4)
+	go e2Node(t, transport, records, map[string]bool{"gnb_responding": true}, deleted)
+
+	//the E2 node that responds is deleted without waiting for the timeout
+	start := time.Now()
+	nodes := c.DeleteSubscriptions([]string{"gnb_responding"})
+	if waited := time.Since(start); waited >= cfg.DeleteTimeout {
+		t.Errorf("waited %v for a deletion responded to", waited)
+	}
+	if len(nodes) != 1 || nodes[0].RanName != "gnb_responding" || nodes[0].State != SUBSCRIPTION_DELETED {
+		t.Errorf("deletion of {gnb_responding} left %+v", nodes)
+	}
+	checkState(t, s, "gnb_silent", SUBSCRIPTION_ACTIVE, 0)
+
+	//shutdown deletes the others, waits for the silent E2 node until the timeout and closes the transport
+	start = time.Now()
+	c.Shutdown()
+	if waited := time.Since(start); waited < cfg.DeleteTimeout {
+		t.Errorf("shutdown waited %v for a silent E2 node, want DeleteTimeout", waited)
+	}
+	for _, ranName := range []string{"gnb_responding", "gnb_silent", ""} {
+		select {
+		case to := <-deleted:
+			if to != ranName {
+				t.Errorf("RIC_SUB_DEL_REQ sent to {%s}, want {%s}", to, ranName)
+			}
+		default:
+			if ranName != "" {
+				t.Errorf("no RIC_SUB_DEL_REQ sent to {%s}", ranName)
+			}
+		}
+	}
+	checkState(t, s, "gnb_responding", SUBSCRIPTION_DELETED, 0)
+	checkState(t, s, "gnb_unsubscribed", SUBSCRIPTION_DELETED, 0)
+	node := checkState(t, s, "gnb_silent", SUBSCRIPTION_FAILED, 0)
+	if !strings.Contains(node.LastError, "no RIC_SUB_DEL_RESP within") {
+		t.Errorf("last error %q of a silent E2 node", node.LastError)
+	}
+	select {
+	case <-transport.done:
+	default:
+		t.Error("transport left open")
+	}
+}
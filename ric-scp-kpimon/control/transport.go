@@ -0,0 +1,54 @@
+package control
+
+import (
+	"errors"
+	"os"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// Transport carries the RMR messages between kpimon and the RIC platform (or a local simulator)
+type Transport interface {
+	// Run delivers every received message to the consumer and calls readyCB once the transport can send.
+	// It blocks until the transport is closed.
+	Run(consumer xapp.MessageConsumer, readyCB func())
+	Send(params *xapp.RMRParams) error
+	ReplyToSender(params *xapp.RMRParams) error
+	Close() error
+}
+
+type TransportConfig struct {
+	Type string //rmr, tcp or unix
+	Addr string //listen address of the tcp and unix transports
+}
+
+// LoadTransportConfig reads the transport configuration from the xApp environment
+func LoadTransportConfig() TransportConfig {
+	cfg := TransportConfig{
+		Type: os.Getenv("transport"),
+		Addr: os.Getenv("transportAddr"),
+	}
+	if cfg.Type == "" {
+		cfg.Type = TRANSPORT_RMR
+	}
+	if cfg.Addr == "" && cfg.Type == TRANSPORT_TCP {
+		cfg.Addr = DEFAULT_TCP_TRANSPORT_ADDR
+	}
+	return cfg
+}
+
+// NewTransport creates the transport selected by the configuration, the in-process channel
+// transport is not selectable here since its peer has to live in the same process
+func NewTransport(cfg TransportConfig) (Transport, error) {
+	switch cfg.Type {
+	case TRANSPORT_RMR:
+		return NewRmrTransport(), nil
+	case TRANSPORT_TCP, TRANSPORT_UNIX:
+		if cfg.Addr == "" {
+			return nil, errors.New("No listen address for " + cfg.Type + " transport")
+		}
+		return NewStreamTransport(cfg.Type, cfg.Addr), nil
+	default:
+		return nil, errors.New("Unknown transport: " + cfg.Type)
+	}
+}
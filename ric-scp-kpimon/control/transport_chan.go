@@ -0,0 +1,71 @@
+package control
+
+import (
+	"errors"
+	"sync"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// ChanTransport is an in-process transport, a simulator running in the same process
+// delivers messages to kpimon with Deliver and reads what kpimon sends from Sent
+type ChanTransport struct {
+	inbound  chan *xapp.RMRParams //messages towards kpimon
+	outbound chan *xapp.RMRParams //messages sent by kpimon
+	done     chan struct{}
+	once     *sync.Once
+}
+
+func NewChanTransport(bufferSize int) *ChanTransport {
+	return &ChanTransport{
+		make(chan *xapp.RMRParams, bufferSize),
+		make(chan *xapp.RMRParams, bufferSize),
+		make(chan struct{}),
+		&sync.Once{},
+	}
+}
+
+func (t *ChanTransport) Run(consumer xapp.MessageConsumer, readyCB func()) {
+	readyCB()
+	for {
+		select {
+		case params := <-t.inbound:
+			consumer.Consume(params)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *ChanTransport) Send(params *xapp.RMRParams) error {
+	select {
+	case t.outbound <- params:
+		return nil
+	case <-t.done:
+		return errors.New("transport closed")
+	}
+}
+
+func (t *ChanTransport) ReplyToSender(params *xapp.RMRParams) error {
+	return t.Send(params)
+}
+
+func (t *ChanTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
+
+// Deliver hands a message to kpimon as if it was received from the RIC
+func (t *ChanTransport) Deliver(params *xapp.RMRParams) error {
+	select {
+	case t.inbound <- params:
+		return nil
+	case <-t.done:
+		return errors.New("transport closed")
+	}
+}
+
+// Sent returns the channel of messages sent by kpimon
+func (t *ChanTransport) Sent() <-chan *xapp.RMRParams {
+	return t.outbound
+}
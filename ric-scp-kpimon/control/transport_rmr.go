@@ -0,0 +1,38 @@
+package control
+
+import (
+	"errors"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// rmrTransport sends and receives through the xapp-frame RMR client
+type rmrTransport struct {
+}
+
+func NewRmrTransport() Transport {
+	return &rmrTransport{}
+}
+
+func (t *rmrTransport) Run(consumer xapp.MessageConsumer, readyCB func()) {
+	xapp.SetReadyCB(func(interface{}) { readyCB() }, nil)
+	xapp.Run(consumer)
+}
+
+func (t *rmrTransport) Send(params *xapp.RMRParams) error {
+	if !xapp.Rmr.Send(params, false) {
+		return errors.New("rmr.Send() failed")
+	}
+	return nil
+}
+
+func (t *rmrTransport) ReplyToSender(params *xapp.RMRParams) error {
+	if !xapp.Rmr.Send(params, true) {
+		return errors.New("rmr.Send() failed")
+	}
+	return nil
+}
+
+func (t *rmrTransport) Close() error {
+	return nil
+}
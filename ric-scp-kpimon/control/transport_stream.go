@@ -0,0 +1,194 @@
+package control
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// streamTransport listens on a TCP or unix socket and exchanges length-prefixed frames
+// (see WriteFrame) with local simulators. Messages sent by kpimon go to the peer that last
+// sent a message for the same RAN name, or to every peer when the RAN name is unknown.
+type streamTransport struct {
+	network  string
+	addr     string
+	listener net.Listener
+	peers    map[net.Conn]*sync.Mutex //connected peers and their write locks
+	ranPeers map[string]net.Conn      //RAN name to the peer serving it
+	mu       *sync.Mutex
+}
+
+func NewStreamTransport(network string, addr string) Transport {
+	return &streamTransport{
+		network:  network,
+		addr:     addr,
+		peers:    make(map[net.Conn]*sync.Mutex),
+		ranPeers: make(map[string]net.Conn),
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (t *streamTransport) Run(consumer xapp.MessageConsumer, readyCB func()) {
+	if t.network == TRANSPORT_UNIX {
+		os.Remove(t.addr)
+	}
+	listener, err := net.Listen(t.network, t.addr)
+	if err != nil {
+		xapp.Logger.Error("Failed to listen on %s %s: %v", t.network, t.addr, err)
+		log.Printf("Failed to listen on %s %s: %v", t.network, t.addr, err)
+		return
+	}
+	t.mu.Lock()
+	t.listener = listener
+	t.mu.Unlock()
+
+	readyCB()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		t.peers[conn] = &sync.Mutex{}
+		t.mu.Unlock()
+		go t.serve(conn, consumer)
+	}
+}
+
+func (t *streamTransport) serve(conn net.Conn, consumer xapp.MessageConsumer) {
+	defer t.drop(conn)
+	reader := bufio.NewReader(conn)
+	for {
+		params, err := ReadFrame(reader)
+		if err != nil {
+			if err != io.EOF {
+				xapp.Logger.Error("Failed to read frame from %v: %v", conn.RemoteAddr(), err)
+				log.Printf("Failed to read frame from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if params.Meid != nil && params.Meid.RanName != "" {
+			t.mu.Lock()
+			t.ranPeers[params.Meid.RanName] = conn
+			t.mu.Unlock()
+		}
+		consumer.Consume(params)
+	}
+}
+
+func (t *streamTransport) drop(conn net.Conn) {
+	t.mu.Lock()
+	delete(t.peers, conn)
+	for ranName, peer := range t.ranPeers {
+		if peer == conn {
+			delete(t.ranPeers, ranName)
+		}
+	}
+	t.mu.Unlock()
+	conn.Close()
+}
+
+func (t *streamTransport) Send(params *xapp.RMRParams) error {
+	t.mu.Lock()
+	var targets []net.Conn
+	if params.Meid != nil {
+		if conn, ok := t.ranPeers[params.Meid.RanName]; ok {
+			targets = append(targets, conn)
+		}
+	}
+	if targets == nil {
+		for conn := range t.peers {
+			targets = append(targets, conn)
+		}
+	}
+	locks := make([]*sync.Mutex, len(targets))
+	for i, conn := range targets {
+		locks[i] = t.peers[conn]
+	}
+	t.mu.Unlock()
+
+	if len(targets) == 0 {
+		return errors.New("no peer connected to " + t.network + " transport")
+	}
+	var err error
+	for i, conn := range targets {
+		locks[i].Lock()
+		if e := WriteFrame(conn, params); e != nil {
+			err = e
+		}
+		locks[i].Unlock()
+	}
+	return err
+}
+
+func (t *streamTransport) ReplyToSender(params *xapp.RMRParams) error {
+	return t.Send(params)
+}
+
+func (t *streamTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for conn := range t.peers {
+		conn.Close()
+	}
+	if t.listener != nil {
+		return t.listener.Close()
+	}
+	return nil
+}
+
+// WriteFrame writes one message in the stream transport framing:
+// length(4) | mtype(4) | subId(4) | ranNameLength(2) | ranName | payload, integers big endian,
+// length counting every byte after itself
+func WriteFrame(w io.Writer, params *xapp.RMRParams) error {
+	var ranName string
+	if params.Meid != nil {
+		ranName = params.Meid.RanName
+	}
+	if len(ranName) > 0xffff {
+		return errors.New("RAN name too long for frame")
+	}
+	frame := make([]byte, 14, 14+len(ranName)+len(params.Payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(10+len(ranName)+len(params.Payload)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(int32(params.Mtype)))
+	binary.BigEndian.PutUint32(frame[8:12], uint32(int32(params.SubId)))
+	binary.BigEndian.PutUint16(frame[12:14], uint16(len(ranName)))
+	frame = append(frame, ranName...)
+	frame = append(frame, params.Payload...)
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads one message written by WriteFrame
+func ReadFrame(r io.Reader) (*xapp.RMRParams, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length < 10 || length > MAX_FRAME_SIZE {
+		return nil, errors.New("invalid frame length")
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	ranNameLength := int(binary.BigEndian.Uint16(frame[8:10]))
+	if 10+ranNameLength > len(frame) {
+		return nil, errors.New("invalid RAN name length in frame")
+	}
+	params := &xapp.RMRParams{}
+	params.Mtype = int(int32(binary.BigEndian.Uint32(frame[0:4])))
+	params.SubId = int(int32(binary.BigEndian.Uint32(frame[4:8])))
+	params.Meid = &xapp.RMRMeid{RanName: string(frame[10 : 10+ranNameLength])}
+	params.Payload = frame[10+ranNameLength:]
+	params.PayloadLen = len(params.Payload)
+	return params, nil
+}
@@ -0,0 +1,300 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+const STREAM_RAN_NAME = "gnb_stream"
+
+var frames = []*xapp.RMRParams{
+	{Mtype: RIC_INDICATION, SubId: 7, Meid: &xapp.RMRMeid{RanName: STREAM_RAN_NAME}, Payload: []byte{0x01, 0x02, 0x03}, PayloadLen: 3},
+	{Mtype: RIC_CONTROL_REQ, SubId: -1, Meid: &xapp.RMRMeid{RanName: ""}, Payload: []byte{}},
+	{Mtype: RIC_ERROR_INDICATION, SubId: 0, Meid: &xapp.RMRMeid{RanName: "é"}, Payload: bytes.Repeat([]byte{0xff}, 4096), PayloadLen: 4096},
+}
+
+func TestStreamFrames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	for _, params := range frames {
+		if err := WriteFrame(buf, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteFrame(buf, &xapp.RMRParams{Mtype: RIC_SUB_RESP, Payload: []byte{0x04}}); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.Bytes()
+	if length := binary.BigEndian.Uint32(written[0:4]); length != 10+uint32(len(STREAM_RAN_NAME))+3 {
+		t.Errorf("first frame of length %d, want %d", length, 10+len(STREAM_RAN_NAME)+3)
+	}
+
+	//the frames are read back whole however the stream splits them
+	for name, r := range map[string]io.Reader{
+		"whole":    bytes.NewReader(written),
+		"one byte": iotest.OneByteReader(bytes.NewReader(written)),
+		"half":     iotest.HalfReader(bytes.NewReader(written)),
+	} {
+		for i, want := range frames {
+			params, err := ReadFrame(r)
+			if err != nil {
+				t.Fatalf("%s: frame %d: %v", name, i, err)
+			}
+			if !reflect.DeepEqual(params, want) {
+				t.Errorf("%s: frame %d read as %+v, want %+v", name, i, params, want)
+			}
+		}
+		params, err := ReadFrame(r)
+		if err != nil || params.Mtype != RIC_SUB_RESP || params.Meid.RanName != "" || !bytes.Equal(params.Payload, []byte{0x04}) {
+			t.Errorf("%s: frame without RAN name read as %+v, %v", name, params, err)
+		}
+		if _, err := ReadFrame(r); err != io.EOF {
+			t.Errorf("%s: end of the stream read as %v, want EOF", name, err)
+		}
+	}
+
+	if _, err := ReadFrame(bytes.NewReader(written[:20])); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated frame read as %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func TestStreamFramesInvalid(t *testing.T) {
+	frame := func(length uint32, body []byte) io.Reader {
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, length)
+		return bytes.NewReader(append(header, body...))
+	}
+	for name, r := range map[string]io.Reader{
+		"over MAX_FRAME_SIZE": frame(MAX_FRAME_SIZE+1, make([]byte, 16)),
+		"shorter than header": frame(9, make([]byte, 9)),
+		"RAN name too long":   frame(12, []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 3, 'g', 'n'}),
+	} {
+		if params, err := ReadFrame(r); err == nil {
+			t.Errorf("frame %s read as %+v", name, params)
+		}
+	}
+
+	if params, err := ReadFrame(frame(MAX_FRAME_SIZE, make([]byte, MAX_FRAME_SIZE))); err != nil || len(params.Payload) != MAX_FRAME_SIZE-10 {
+		t.Errorf("frame of MAX_FRAME_SIZE read with %v", err)
+	}
+	ranName := strings.Repeat("g", 0x10000)
+	if err := WriteFrame(ioutil.Discard, &xapp.RMRParams{Meid: &xapp.RMRMeid{RanName: ranName}}); err == nil {
+		t.Error("frame of a RAN name over 65535 bytes written")
+	}
+}
+
+// streamConsumer hands the messages a stream transport receives to a channel
+type streamConsumer chan *xapp.RMRParams
+
+func (c streamConsumer) Consume(params *xapp.RMRParams) error {
+	c <- params
+	return nil
+}
+
+// runStream runs transport until the test ends and returns the address of its unix socket
+func runStream(t *testing.T, run func(transport Transport, ready func())) (Transport, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	addr := filepath.Join(dir, "kpimon.sock")
+	transport := NewStreamTransport(TRANSPORT_UNIX, addr)
+
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		run(transport, func() { close(ready) })
+		close(done)
+	}()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream transport not ready")
+	}
+	t.Cleanup(func() {
+		transport.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("stream transport still running after Close")
+		}
+	})
+	return transport, addr
+}
+
+func readPeerFrame(t *testing.T, r io.Reader) *xapp.RMRParams {
+	t.Helper()
+	type result struct {
+		params *xapp.RMRParams
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		params, err := ReadFrame(r)
+		done <- result{params, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		return res.params
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+	}
+	return nil
+}
+
+func TestStreamTransport(t *testing.T) {
+	received := make(streamConsumer, 4)
+	transport, addr := runStream(t, func(transport Transport, ready func()) { transport.Run(received, ready) })
+	if err := transport.Send(&xapp.RMRParams{Mtype: RIC_SUB_REQ, Meid: &xapp.RMRMeid{RanName: STREAM_RAN_NAME}}); err == nil {
+		t.Error("message sent without peer")
+	}
+
+	peers := make([]net.Conn, 2)
+	for i := range peers {
+		conn, err := net.Dial(TRANSPORT_UNIX, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		peers[i] = conn
+	}
+
+	//the second peer serves the RAN, messages for other RAN names go to every peer
+	if err := WriteFrame(peers[1], frames[0]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case params := <-received:
+		if params.Mtype != frames[0].Mtype || params.Meid.RanName != STREAM_RAN_NAME {
+			t.Errorf("received %+v, want %+v", params, frames[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("frame of the peer not received")
+	}
+	readers := []*bufio.Reader{bufio.NewReader(peers[0]), bufio.NewReader(peers[1])}
+	if err := transport.Send(&xapp.RMRParams{Mtype: RIC_SUB_REQ, SubId: 1, Meid: &xapp.RMRMeid{RanName: STREAM_RAN_NAME}, Payload: []byte{0x01}}); err != nil {
+		t.Fatal(err)
+	}
+	if params := readPeerFrame(t, readers[1]); params.Mtype != RIC_SUB_REQ || params.SubId != 1 {
+		t.Errorf("peer of the RAN received %+v", params)
+	}
+	if err := transport.Send(&xapp.RMRParams{Mtype: RIC_SUB_REQ, SubId: 2, Meid: &xapp.RMRMeid{RanName: "gnb_other"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range readers {
+		if params := readPeerFrame(t, r); params.SubId != 2 {
+			t.Errorf("peer %d received %+v, want the message for every peer", i, params)
+		}
+	}
+
+	//an invalid frame drops the peer and the RAN it served
+	binary.Write(peers[1], binary.BigEndian, uint32(MAX_FRAME_SIZE+1))
+	if _, err := readers[1].ReadByte(); err != io.EOF {
+		t.Errorf("peer sending an oversized frame read %v, want EOF", err)
+	}
+	if err := transport.Send(&xapp.RMRParams{Mtype: RIC_SUB_REQ, SubId: 3, Meid: &xapp.RMRMeid{RanName: STREAM_RAN_NAME}}); err != nil {
+		t.Fatal(err)
+	}
+	if params := readPeerFrame(t, readers[0]); params.SubId != 3 {
+		t.Errorf("remaining peer received %+v", params)
+	}
+
+	//Close disconnects the peers
+	transport.Close()
+	if _, err := readers[0].ReadByte(); err != io.EOF {
+		t.Errorf("peer read %v after Close, want EOF", err)
+	}
+}
+
+// TestStreamSubscribeIndicate runs kpimon over a unix socket and plays the E2 node on the other end:
+// it answers the RIC_SUB_REQ and sends an indication whose entries must reach the store
+func TestStreamSubscribeIndicate(t *testing.T) {
+	var e2ap *E2ap
+	store := NewMemoryMetricsStore()
+	var c Control
+	_, addr := runStream(t, func(transport Transport, ready func()) {
+		c = NewControlWith([]string{STREAM_RAN_NAME}, store, transport, nil, nil, nil, nil, nil,
+			SubscriptionConfig{StartDelay: 10 * time.Millisecond, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+		transport.Run(&c, func() {
+			ReadyCB(&c)
+			ready()
+		})
+	})
+
+	conn, err := net.Dial(TRANSPORT_UNIX, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	//the requests sent before the connection failed and are retried
+	req := readPeerFrame(t, reader)
+	if req.Mtype != RIC_SUB_REQ || req.Meid.RanName != STREAM_RAN_NAME {
+		t.Fatalf("received %d for {%s}, want RIC_SUB_REQ for {%s}", req.Mtype, req.Meid.RanName, STREAM_RAN_NAME)
+	}
+	sub, err := e2ap.GetSubscriptionRequestMessage(req.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := e2ap.SetSubscriptionResponsePayload(make([]byte, 1024), &DecodedSubscriptionResponseMessage{RequestID: sub.RequestID,
+		RequestSequenceNumber: sub.RequestSequenceNumber, FuncID: sub.FuncID, ActionAdmittedList: ActionAdmittedListType{ActionID: []int32{sub.Actions[0].ActionID}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrame(conn, &xapp.RMRParams{Mtype: RIC_SUB_RESP, SubId: req.SubId, Meid: req.Meid, Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	waitStream(t, "subscription not active", func() bool {
+		nodes := c.Subscriptions().Nodes()
+		return len(nodes) == 1 && nodes[0].State == SUBSCRIPTION_ACTIVE
+	})
+
+	indication, err := e2ap.SetIndicationPayload(make([]byte, 1024), &DecodedIndicationMessage{RequestID: sub.RequestID,
+		RequestSequenceNumber: sub.RequestSequenceNumber, FuncID: sub.FuncID, ActionID: sub.Actions[0].ActionID, IndSN: 1,
+		IndHeader: readGolden(t, "e2sm/indication_header_gnb"), IndMessage: readGolden(t, "e2sm/indication_message_odu")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrame(conn, &xapp.RMRParams{Mtype: RIC_INDICATION, SubId: req.SubId, Meid: req.Meid, Payload: indication}); err != nil {
+		t.Fatal(err)
+	}
+	waitStream(t, "indication not stored", func() bool {
+		ok, _ := store.ExistsCell("1315184000010001")
+		return ok
+	})
+	if cell, err := store.GetCell("1315184000010001"); err != nil || cell.AvailPRBDL != 273 || cell.AvailPRBUL != 106 {
+		t.Errorf("cell of the indication stored as %+v, %v", cell, err)
+	}
+	if sn := c.LastIndicationSN(); sn != 1 {
+		t.Errorf("last indication %d, want 1", sn)
+	}
+}
+
+func waitStream(t *testing.T, failure string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal(failure)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,12 +2,85 @@ package control
 
 const MAX_SUBSCRIPTION_ATTEMPTS = 100
 
+const IDLE_REPORT_PERIODS = 10 //report periods without indications after which an active E2 node is resubscribed
+
+const (
+	STORE_BACKEND_REDIS  = "redis"
+	STORE_BACKEND_MEMORY = "memory"
+	STORE_BACKEND_SDL    = "sdl"
+
+	DEFAULT_REDIS_ADDR    = "10.244.0.14:6379"
+	DEFAULT_SDL_NAMESPACE = "kpimon"
+	UE_INDEX_KEY          = "kpimon:ues" //set of the keys holding UE entries, next to the entries
+
+	SIGNING_OFF     = "off"     //plain JSON records
+	SIGNING_SIGN    = "sign"    //signed envelopes written, plain records still accepted on read
+	SIGNING_ENFORCE = "enforce" //signed envelopes written, plain or invalid records rejected on read
+)
+
+const (
+	TRANSPORT_RMR  = "rmr"
+	TRANSPORT_TCP  = "tcp"
+	TRANSPORT_UNIX = "unix"
+
+	DEFAULT_TCP_TRANSPORT_ADDR = "127.0.0.1:4570"
+	MAX_FRAME_SIZE             = 1 << 20
+)
+
+const (
+	SUBSCRIPTION_BACKEND_RMR  = "rmr"  //kpimon sends the RIC_SUB_REQ and RIC_SUB_DEL_REQ itself
+	SUBSCRIPTION_BACKEND_REST = "rest" //the subscription manager subscribes on behalf of kpimon
+
+	DEFAULT_SUBMGR_URL                 = "http://service-ricplt-submgr-http.ricplt:8088/ric/v1"
+	DEFAULT_SUBSCRIPTION_CALLBACK_ADDR = ":8080"
+	DEFAULT_RMR_DATA_PORT              = 4560
+)
+
+const (
+	RIC_SUB_REQ         = 12010 //RMR message types of the RIC subscription procedures and of the RIC Indication
+	RIC_SUB_RESP        = 12011
+	RIC_SUB_FAILURE     = 12012
+	RIC_SUB_DEL_REQ     = 12020
+	RIC_SUB_DEL_RESP    = 12021
+	RIC_SUB_DEL_FAILURE = 12022
+	RIC_INDICATION      = 12050
+)
+
+const (
+	RIC_CONTROL_REQ     = 12040 //RMR message types of the RIC control procedure
+	RIC_CONTROL_ACK     = 12041
+	RIC_CONTROL_FAILURE = 12042
+
+	CONTROL_ACK_NONE = "none" //RICcontrolAckRequest noAck: the E2 node answers nothing
+	CONTROL_ACK      = "ack"  //RICcontrolAckRequest ack: the E2 node answers the outcome
+	CONTROL_NACK     = "nack" //RICcontrolAckRequest nAck: the E2 node only answers failures
+
+	CONTROL_ACKNOWLEDGED = "acknowledged" //RIC_CONTROL_ACK received
+	CONTROL_FAILED       = "failed"       //RIC_CONTROL_FAILURE received
+	CONTROL_TIMED_OUT    = "timed out"    //no answer before the timeout
+	CONTROL_SENT         = "sent"         //no answer expected, or none received to a nAck request before the timeout
+)
+
+const (
+	RIC_ERROR_INDICATION = 10065 //RMR message type of the E2AP Error Indication
+
+	DEFAULT_DEAD_LETTER_MAX = 1000 //unhandled payloads dumped to the dead-letter directory before it is left alone
+)
+
+const (
+	RIC_ALARM = 110 //RMR message type of the alarms sent to the alarm manager
+
+	ALARM_MANAGED_OBJECT_ID   = "RIC"
+	ALARM_APPLICATION_ID      = "kpimon"
+	ALARM_FOREIGN_STORE_WRITE = 8100 //specific problem of the alarm raised for a foreign metrics store write
+)
+
 type DecodedIndicationMessage struct {
 	RequestID             int32
 	RequestSequenceNumber int32
 	FuncID                int32
 	ActionID              int32
-	IndSN                 int32
+	IndSN                 int32 //-1 when the optional RICindicationSN is absent
 	IndType               int32
 	IndHeader             []byte
 	IndHeaderLength       int32
@@ -23,14 +96,12 @@ type CauseItemType struct {
 }
 
 type ActionAdmittedListType struct {
-	ActionID [16]int32
-	Count    int
+	ActionID []int32
 }
 
 type ActionNotAdmittedListType struct {
-	ActionID [16]int32
-	Cause    [16]CauseItemType
-	Count    int
+	ActionID []int32
+	Cause    []CauseItemType
 }
 
 type DecodedSubscriptionResponseMessage struct {
@@ -41,6 +112,131 @@ type DecodedSubscriptionResponseMessage struct {
 	ActionNotAdmittedList ActionNotAdmittedListType
 }
 
+type ActionToBeSetupType struct {
+	ActionID         int32
+	ActionType       int32
+	ActionDefinition []byte //nil when absent
+	SubsequentAction SubsequentAction
+}
+
+type DecodedSubscriptionRequestMessage struct {
+	RequestID              int32
+	RequestSequenceNumber  int32
+	FuncID                 int32
+	EventTriggerDefinition []byte
+	Actions                []ActionToBeSetupType
+}
+
+type CriticalityDiagnosticsIEType struct {
+	IECriticality int32
+	IEID          int32
+	TypeOfError   int32
+}
+
+// CriticalityDiagnosticsType holds -1 in the optional fields that are absent
+type CriticalityDiagnosticsType struct {
+	ProcedureCode         int32
+	TriggeringMessage     int32
+	ProcedureCriticality  int32
+	RequestID             int32
+	RequestSequenceNumber int32
+	IEs                   []CriticalityDiagnosticsIEType
+}
+
+type DecodedSubscriptionFailureMessage struct {
+	RequestID              int32
+	RequestSequenceNumber  int32
+	FuncID                 int32
+	ActionNotAdmittedList  ActionNotAdmittedListType
+	CriticalityDiagnostics *CriticalityDiagnosticsType
+}
+
+type DecodedSubscriptionDeleteMessage struct {
+	RequestID             int32
+	RequestSequenceNumber int32
+	FuncID                int32
+}
+
+type DecodedSubscriptionDeleteFailureMessage struct {
+	RequestID              int32
+	RequestSequenceNumber  int32
+	FuncID                 int32
+	Cause                  CauseItemType
+	CriticalityDiagnostics *CriticalityDiagnosticsType
+}
+
+type DecodedControlRequestMessage struct {
+	RequestID             int32
+	RequestSequenceNumber int32
+	FuncID                int32
+	CallProcessID         []byte //nil when absent
+	ControlHeader         []byte
+	ControlMessage        []byte
+	ControlAckRequest     int32 //-1 when absent
+}
+
+type DecodedControlAcknowledgeMessage struct {
+	RequestID             int32
+	RequestSequenceNumber int32
+	FuncID                int32
+	CallProcessID         []byte //nil when absent
+	ControlStatus         int32
+	ControlOutcome        []byte //nil when absent
+}
+
+type DecodedControlFailureMessage struct {
+	RequestID             int32
+	RequestSequenceNumber int32
+	FuncID                int32
+	CallProcessID         []byte //nil when absent
+	Cause                 CauseItemType
+	ControlOutcome        []byte //nil when absent
+}
+
+// DecodedErrorIndicationMessage holds -1 in RequestID, RequestSequenceNumber and FuncID when the
+// optional IEs are absent
+type DecodedErrorIndicationMessage struct {
+	RequestID              int32
+	RequestSequenceNumber  int32
+	FuncID                 int32
+	Cause                  *CauseItemType
+	CriticalityDiagnostics *CriticalityDiagnosticsType
+}
+
+type RanFunctionItemType struct {
+	FuncID     int32
+	Definition []byte
+	Revision   int32
+}
+
+type RanFunctionIDItemType struct {
+	FuncID   int32
+	Revision int32
+}
+
+type RanFunctionIDCauseItemType struct {
+	FuncID int32
+	Cause  CauseItemType
+}
+
+// DecodedServiceUpdateMessage holds nil lists for the optional IEs that are absent
+type DecodedServiceUpdateMessage struct {
+	Added    []RanFunctionItemType
+	Modified []RanFunctionItemType
+	Deleted  []RanFunctionIDItemType
+}
+
+type DecodedServiceUpdateAcknowledgeMessage struct {
+	Accepted []RanFunctionIDItemType
+	Rejected []RanFunctionIDCauseItemType
+}
+
+type DecodedServiceUpdateFailureMessage struct {
+	Rejected               []RanFunctionIDCauseItemType
+	TimeToWait             int32 //-1 when absent
+	CriticalityDiagnostics *CriticalityDiagnosticsType
+}
+
 type IntPair64 struct {
 	DL int64
 	UL int64
@@ -154,14 +350,12 @@ type FQIPERSlicesPerPlmnPerCellType struct {
 }
 
 type SlicePerPlmnPerCellType struct {
-	SliceID                         SliceIDType
-	FQIPERSlicesPerPlmnPerCells     [64]FQIPERSlicesPerPlmnPerCellType
-	FQIPERSlicesPerPlmnPerCellCount int
+	SliceID                     SliceIDType
+	FQIPERSlicesPerPlmnPerCells []FQIPERSlicesPerPlmnPerCellType
 }
 
 type DUPM5GCContainerType struct {
-	SlicePerPlmnPerCells     [1024]SlicePerPlmnPerCellType
-	SlicePerPlmnPerCellCount int
+	SlicePerPlmnPerCells []SlicePerPlmnPerCellType
 }
 
 type DUPMEPCPerQCIReportType struct {
@@ -170,8 +364,7 @@ type DUPMEPCPerQCIReportType struct {
 }
 
 type DUPMEPCContainerType struct {
-	PerQCIReports     [256]DUPMEPCPerQCIReportType
-	PerQCIReportCount int
+	PerQCIReports []DUPMEPCPerQCIReportType
 }
 
 type ServedPlmnPerCellType struct {
@@ -181,15 +374,13 @@ type ServedPlmnPerCellType struct {
 }
 
 type CellResourceReportType struct {
-	NRCGI                  NRCGIType
-	TotalofAvailablePRBs   IntPair64
-	ServedPlmnPerCells     [12]ServedPlmnPerCellType
-	ServedPlmnPerCellCount int
+	NRCGI                NRCGIType
+	TotalofAvailablePRBs IntPair64
+	ServedPlmnPerCells   []ServedPlmnPerCellType
 }
 
 type ODUPFContainerType struct {
-	CellResourceReports     [512]CellResourceReportType
-	CellResourceReportCount int
+	CellResourceReports []CellResourceReportType
 }
 
 type CUCPResourceStatusType struct {
@@ -208,14 +399,12 @@ type FQIPERSlicesPerPlmnType struct {
 }
 
 type SliceToReportType struct {
-	SliceID                  SliceIDType
-	FQIPERSlicesPerPlmns     [64]FQIPERSlicesPerPlmnType
-	FQIPERSlicesPerPlmnCount int
+	SliceID              SliceIDType
+	FQIPERSlicesPerPlmns []FQIPERSlicesPerPlmnType
 }
 
 type CUUPPM5GCType struct {
-	SliceToReports     [1024]SliceToReportType
-	SliceToReportCount int
+	SliceToReports []SliceToReportType
 }
 
 type CUUPPMEPCPerQCIReportType struct {
@@ -225,8 +414,7 @@ type CUUPPMEPCPerQCIReportType struct {
 }
 
 type CUUPPMEPCType struct {
-	CUUPPMEPCPerQCIReports     [256]CUUPPMEPCPerQCIReportType
-	CUUPPMEPCPerQCIReportCount int
+	CUUPPMEPCPerQCIReports []CUUPPMEPCPerQCIReportType
 }
 
 type CUUPPlmnType struct {
@@ -236,8 +424,7 @@ type CUUPPlmnType struct {
 }
 
 type CUUPMeasurementContainerType struct {
-	CUUPPlmns     [12]CUUPPlmnType
-	CUUPPlmnCount int
+	CUUPPlmns []CUUPPlmnType
 }
 
 type CUUPPFContainerItemType struct {
@@ -246,9 +433,8 @@ type CUUPPFContainerItemType struct {
 }
 
 type OCUUPPFContainerType struct {
-	GNBCUUPName              *PrintableString
-	CUUPPFContainerItems     [3]CUUPPFContainerItemType
-	CUUPPFContainerItemCount int
+	GNBCUUPName          *PrintableString
+	CUUPPFContainerItems []CUUPPFContainerItemType
 }
 
 type DUUsageReportUeResourceReportItemType struct {
@@ -258,14 +444,12 @@ type DUUsageReportUeResourceReportItemType struct {
 }
 
 type DUUsageReportCellResourceReportItemType struct {
-	NRCGI                     NRCGIType
-	UeResourceReportItems     [32]DUUsageReportUeResourceReportItemType
-	UeResourceReportItemCount int
+	NRCGI                 NRCGIType
+	UeResourceReportItems []DUUsageReportUeResourceReportItemType
 }
 
 type DUUsageReportType struct {
-	CellResourceReportItems     [512]DUUsageReportCellResourceReportItemType
-	CellResourceReportItemCount int
+	CellResourceReportItems []DUUsageReportCellResourceReportItemType
 }
 
 type CUCPUsageReportUeResourceReportItemType struct {
@@ -275,14 +459,12 @@ type CUCPUsageReportUeResourceReportItemType struct {
 }
 
 type CUCPUsageReportCellResourceReportItemType struct {
-	NRCGI                     NRCGIType
-	UeResourceReportItems     [32]CUCPUsageReportUeResourceReportItemType
-	UeResourceReportItemCount int
+	NRCGI                 NRCGIType
+	UeResourceReportItems []CUCPUsageReportUeResourceReportItemType
 }
 
 type CUCPUsageReportType struct {
-	CellResourceReportItems     [16384]CUCPUsageReportCellResourceReportItemType
-	CellResourceReportItemCount int
+	CellResourceReportItems []CUCPUsageReportCellResourceReportItemType
 }
 
 type CUUPUsageReportUeResourceReportItemType struct {
@@ -292,14 +474,12 @@ type CUUPUsageReportUeResourceReportItemType struct {
 }
 
 type CUUPUsageReportCellResourceReportItemType struct {
-	NRCGI                     NRCGIType
-	UeResourceReportItems     [32]CUUPUsageReportUeResourceReportItemType
-	UeResourceReportItemCount int
+	NRCGI                 NRCGIType
+	UeResourceReportItems []CUUPUsageReportUeResourceReportItemType
 }
 
 type CUUPUsageReportType struct {
-	CellResourceReportItems     [512]CUUPUsageReportCellResourceReportItemType
-	CellResourceReportItemCount int
+	CellResourceReportItems []CUUPUsageReportCellResourceReportItemType
 }
 
 type PFContainerType struct {
@@ -319,8 +499,7 @@ type PMContainerType struct {
 }
 
 type IndicationMessageFormat1 struct {
-	PMContainers     [8]PMContainerType
-	PMContainerCount int
+	PMContainers []PMContainerType
 }
 
 type IndicationMessage struct {
@@ -329,6 +508,33 @@ type IndicationMessage struct {
 	IndMsg     interface{}
 }
 
+type RanFunctionNameType struct {
+	ShortName   string
+	E2SMOID     string
+	Description string
+	Instance    int64 //-1 when absent
+}
+
+type EventTriggerStyleType struct {
+	StyleType  int64
+	StyleName  string
+	FormatType int64
+}
+
+type ReportStyleType struct {
+	StyleType               int64
+	StyleName               string
+	IndicationHeaderFormat  int64
+	IndicationMessageFormat int64
+}
+
+// RanFunctionDescription holds nil style lists for the optional lists that are absent
+type RanFunctionDescription struct {
+	Name               RanFunctionNameType
+	EventTriggerStyles []EventTriggerStyleType
+	ReportStyles       []ReportStyleType
+}
+
 type Timestamp struct {
 	TVsec  int64 `json:"tv_sec"`
 	TVnsec int64 `json:"tv_nsec"`
@@ -341,6 +547,7 @@ type CellMetricsEntry struct {
 	MeasTimestampPRB       Timestamp `json:"Meas-Timestamp-PRB"`
 	AvailPRBDL             int64     `json:"Avail-PRB-DL"`
 	AvailPRBUL             int64     `json:"Avail-PRB-UL"`
+	PlausibilityTags       []string  `json:"Plausibility-Tags,omitempty"` //plausibility rules with a tag action the entry violated
 }
 
 type CellRFType struct {
@@ -366,4 +573,5 @@ type UeMetricsEntry struct {
 	MeasTimeRF             Timestamp            `json:"Meas-Time-RF"`
 	ServingCellRF          CellRFType           `json:"Serving-Cell-RF"`
 	NeighborCellsRF        []NeighborCellRFType `json:"Neighbor-Cell-RF"`
+	PlausibilityTags       []string             `json:"Plausibility-Tags,omitempty"` //plausibility rules with a tag action the entry violated
 }
@@ -0,0 +1,562 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	RULE_RANGE       = "range"       //Min <= field <= Max, for every element when the path goes through an array
+	RULE_MONOTONIC   = "monotonic"   //field never decreases between two writes of the same key
+	RULE_CROSS_FIELD = "cross_field" //field compared with RefField of the same entry, or of the serving cell entry when RefTarget is cell
+	RULE_NOT_FUTURE  = "not_future"  //timestamp field not later than now plus Tolerance
+
+	ACTION_REJECT = "reject" //drop the write
+	ACTION_CLAMP  = "clamp"  //bring the field back to the violated bound and write
+	ACTION_TAG    = "tag"    //write the entry with the rule name in its Plausibility-Tags
+
+	TARGET_UE   = "ue"
+	TARGET_CELL = "cell"
+)
+
+var ErrRejected = errors.New("metrics entry rejected by the plausibility validator")
+
+// ValidationRules is the content of a rule file
+type ValidationRules struct {
+	Rules []*ValidationRule `yaml:"rules" json:"rules"`
+}
+
+// ValidationRule is one declarative plausibility check. Field paths use the JSON field names of the
+// entries, e.g. Serving-Cell-RF.rsrp or Neighbor-Cell-RF[].Cell-RF.rsrp
+type ValidationRule struct {
+	Name      string   `yaml:"name" json:"name"`
+	Type      string   `yaml:"type" json:"type"`
+	Target    string   `yaml:"target" json:"target"` //ue or cell
+	Field     string   `yaml:"field" json:"field"`
+	Min       *float64 `yaml:"min" json:"min"`
+	Max       *float64 `yaml:"max" json:"max"`
+	Op        string   `yaml:"op" json:"op"`                 //cross_field comparison of Field with RefField: le, lt, ge, gt or eq
+	RefTarget string   `yaml:"ref_target" json:"ref_target"` //empty for the same entry, cell for the serving cell of a UE entry
+	RefField  string   `yaml:"ref_field" json:"ref_field"`
+	Tolerance string   `yaml:"tolerance" json:"tolerance"` //not_future clock skew allowance, e.g. 5s
+	Action    string   `yaml:"action" json:"action"`       //reject, clamp or tag
+
+	tolerance time.Duration
+}
+
+// Violation is one failed rule for one value of an entry
+type Violation struct {
+	Rule   string  `json:"rule"`
+	Action string  `json:"action"`
+	Field  string  `json:"field"`
+	Value  float64 `json:"value"`
+	Bound  float64 `json:"bound"` //value the rule expected at most or at least
+}
+
+// RuleCounter counts the checks and the violations of a rule
+type RuleCounter struct {
+	Checked  int64 `json:"checked"`
+	Violated int64 `json:"violated"`
+	Rejected int64 `json:"rejected"`
+	Clamped  int64 `json:"clamped"`
+	Tagged   int64 `json:"tagged"`
+}
+
+// Validator checks UE and cell entries against plausibility rules before they are stored. The previous
+// entry of a monotonic rule and the serving cell entry of a cross_field rule are the ones last written
+// through the validator, kept as the fields it checked, so that validating costs no read of the backend.
+// Nothing is known of a key before its first write since start.
+type Validator struct {
+	rules    []*ValidationRule
+	counters map[string]*RuleCounter
+	last     map[string]map[string]interface{} //fields of the entry last written per target and key
+	mu       *sync.Mutex
+}
+
+// LoadValidationRules reads a rule file in YAML (.yaml, .yml) or JSON (.json)
+func LoadValidationRules(path string) (*ValidationRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := &ValidationRules{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, rules)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, rules)
+	default:
+		return nil, errors.New("Unknown validation rule file format: " + path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse validation rule file %s: %v", path, err)
+	}
+	return rules, rules.Validate()
+}
+
+func (r *ValidationRules) Validate() error {
+	names := make(map[string]bool)
+	for i, rule := range r.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if names[rule.Name] {
+			return errors.New("Duplicate validation rule: " + rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("validation rule %s: %v", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *ValidationRule) validate() error {
+	if r.Target == "" {
+		r.Target = TARGET_UE
+	}
+	if r.Target != TARGET_UE && r.Target != TARGET_CELL {
+		return errors.New("unknown target " + r.Target)
+	}
+	if r.Field == "" {
+		return errors.New("missing field")
+	}
+	if r.Action == "" {
+		r.Action = ACTION_TAG
+	}
+	if r.Action != ACTION_REJECT && r.Action != ACTION_CLAMP && r.Action != ACTION_TAG {
+		return errors.New("unknown action " + r.Action)
+	}
+	switch r.Type {
+	case RULE_RANGE:
+		if r.Min == nil && r.Max == nil {
+			return errors.New("range without min or max")
+		}
+	case RULE_MONOTONIC:
+		if strings.Contains(r.Field, "[]") {
+			return errors.New("monotonic field cannot go through an array")
+		}
+	case RULE_CROSS_FIELD:
+		switch r.Op {
+		case "le", "lt", "ge", "gt", "eq":
+		default:
+			return errors.New("unknown op " + r.Op)
+		}
+		if r.RefField == "" {
+			return errors.New("cross_field without ref_field")
+		}
+		if r.RefTarget != "" && (r.RefTarget != TARGET_CELL || r.Target != TARGET_UE) {
+			return errors.New("ref_target cell is only supported for ue rules")
+		}
+	case RULE_NOT_FUTURE:
+		if r.Tolerance != "" {
+			tolerance, err := time.ParseDuration(r.Tolerance)
+			if err != nil {
+				return err
+			}
+			r.tolerance = tolerance
+		}
+	default:
+		return errors.New("unknown rule type " + r.Type)
+	}
+	return nil
+}
+
+func NewValidator(rules *ValidationRules) *Validator {
+	counters := make(map[string]*RuleCounter, len(rules.Rules))
+	for _, rule := range rules.Rules {
+		counters[rule.Name] = &RuleCounter{}
+	}
+	return &Validator{rules.Rules, counters, make(map[string]map[string]interface{}), &sync.Mutex{}}
+}
+
+// Wrap returns a view of store validating every entry before it is written
+func (v *Validator) Wrap(store MetricsStore) MetricsStore {
+	return &validatingMetricsStore{store, v}
+}
+
+// Counters returns a snapshot of the per rule counters
+func (v *Validator) Counters() map[string]RuleCounter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	counters := make(map[string]RuleCounter, len(v.counters))
+	for name, counter := range v.counters {
+		counters[name] = *counter
+	}
+	return counters
+}
+
+// ValidateUe checks a UE entry about to be written under ueID, clamping and tagging it in place.
+// The entry must not be written when rejected is true.
+func (v *Validator) ValidateUe(ueID string, ueMetrics *UeMetricsEntry) (violations []*Violation, rejected bool, err error) {
+	_, violations, rejected, err = v.validate(TARGET_UE, ueID, ueMetrics, &ueMetrics.PlausibilityTags)
+	return
+}
+
+// ValidateCell is ValidateUe for cell entries
+func (v *Validator) ValidateCell(cellID string, cellMetrics *CellMetricsEntry) (violations []*Violation, rejected bool, err error) {
+	_, violations, rejected, err = v.validate(TARGET_CELL, cellID, cellMetrics, &cellMetrics.PlausibilityTags)
+	return
+}
+
+// validate checks entry against the rules of target, clamps it and sets its plausibility tags, and
+// returns the fields it checked, which the store remembers once the entry is written
+func (v *Validator) validate(target string, key string, entry interface{}, plausibilityTags *[]string) (map[string]interface{}, []*Violation, bool, error) {
+	fields, err := toJSONFields(entry)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	violations, rejected := v.evaluate(target, fields, v.previous(target, key), true)
+	*plausibilityTags = nil
+	if err = fromJSONFields(fields, entry); err != nil {
+		return nil, nil, false, err
+	}
+	*plausibilityTags = tags(violations)
+	return fields, violations, rejected, nil
+}
+
+// previous returns the fields of the entry last written through the validator, nil when there is none
+func (v *Validator) previous(target string, key string) map[string]interface{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.last[target+"/"+key]
+}
+
+// remember keeps the fields of an entry written to a key, nil fields for a deleted key
+func (v *Validator) remember(target string, key string, fields map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if fields == nil {
+		delete(v.last, target+"/"+key)
+	} else {
+		v.last[target+"/"+key] = fields
+	}
+}
+
+// Evaluate reports which rules an entry (*UeMetricsEntry or *CellMetricsEntry) would violate if it replaced
+// previous, without modifying it or counting, e.g. to tell whether an injected record would have been caught
+func (v *Validator) Evaluate(target string, entry interface{}, previous interface{}) ([]*Violation, bool) {
+	fields, err := toJSONFields(entry)
+	if err != nil {
+		return nil, false
+	}
+	previousFields, _ := toJSONFields(previous)
+	return v.evaluate(target, fields, previousFields, false)
+}
+
+func (v *Validator) evaluate(target string, fields map[string]interface{}, previousFields map[string]interface{}, count bool) (violations []*Violation, rejected bool) {
+	for _, rule := range v.rules {
+		if rule.Target != target {
+			continue
+		}
+		found := v.check(rule, fields, previousFields)
+		if count {
+			v.count(rule, len(found))
+		}
+		for _, violation := range found {
+			if rule.Action == ACTION_REJECT {
+				rejected = true
+			}
+			if count {
+				xapp.Logger.Debug("Plausibility rule %s violated by %s=%v (bound %v), action %s", rule.Name, violation.Field, violation.Value, violation.Bound, rule.Action)
+			}
+		}
+		violations = append(violations, found...)
+	}
+	return
+}
+
+func (v *Validator) count(rule *ValidationRule, violated int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	counter := v.counters[rule.Name]
+	counter.Checked++
+	if violated == 0 {
+		return
+	}
+	counter.Violated++
+	switch rule.Action {
+	case ACTION_REJECT:
+		counter.Rejected++
+	case ACTION_CLAMP:
+		counter.Clamped++
+	case ACTION_TAG:
+		counter.Tagged++
+	}
+}
+
+// check applies one rule to the fields of an entry, clamping them when the rule says so
+func (v *Validator) check(rule *ValidationRule, fields map[string]interface{}, previous map[string]interface{}) (violations []*Violation) {
+	violate := func(ref *fieldRef, bound float64) {
+		violations = append(violations, &Violation{rule.Name, rule.Action, rule.Field, ref.value, bound})
+		if rule.Action == ACTION_CLAMP {
+			ref.set(bound)
+		}
+	}
+
+	switch rule.Type {
+	case RULE_RANGE:
+		for _, ref := range lookupFields(fields, rule.Field) {
+			if rule.Min != nil && ref.value < *rule.Min {
+				violate(ref, *rule.Min)
+			} else if rule.Max != nil && ref.value > *rule.Max {
+				violate(ref, *rule.Max)
+			}
+		}
+	case RULE_MONOTONIC:
+		before := lookupFields(previous, rule.Field)
+		for _, ref := range lookupFields(fields, rule.Field) {
+			if len(before) == 1 && ref.value < before[0].value {
+				violate(ref, before[0].value)
+			}
+		}
+	case RULE_CROSS_FIELD:
+		refFields := fields
+		if rule.RefTarget == TARGET_CELL {
+			cellID, _ := fields["Serving Cell ID"].(string)
+			if refFields = v.previous(TARGET_CELL, cellID); cellID == "" || refFields == nil {
+				return
+			}
+		}
+		refs := lookupFields(refFields, rule.RefField)
+		if len(refs) != 1 {
+			return
+		}
+		bound := refs[0].value
+		for _, ref := range lookupFields(fields, rule.Field) {
+			if !compare(ref.value, rule.Op, bound) {
+				violate(ref, bound)
+			}
+		}
+	case RULE_NOT_FUTURE:
+		now := time.Now().Add(rule.tolerance)
+		bound := float64(now.Unix()) + float64(now.Nanosecond())/1e9
+		for _, ref := range lookupFields(fields, rule.Field) {
+			if ref.value > bound {
+				violate(ref, bound)
+			}
+		}
+	}
+	return
+}
+
+func compare(value float64, op string, bound float64) bool {
+	switch op {
+	case "le":
+		return value <= bound
+	case "lt":
+		return value < bound
+	case "ge":
+		return value >= bound
+	case "gt":
+		return value > bound
+	default:
+		return value == bound
+	}
+}
+
+// fieldRef is a numeric value found at a field path, timestamps ({tv_sec, tv_nsec}) read as seconds
+type fieldRef struct {
+	value float64
+	set   func(float64)
+}
+
+func lookupFields(fields map[string]interface{}, path string) []*fieldRef {
+	if fields == nil {
+		return nil
+	}
+	var refs []*fieldRef
+	var walk func(object map[string]interface{}, names []string)
+	walk = func(object map[string]interface{}, names []string) {
+		name := strings.TrimSuffix(names[0], "[]")
+		value, ok := object[name]
+		if !ok {
+			return
+		}
+		if strings.HasSuffix(names[0], "[]") {
+			items, _ := value.([]interface{})
+			for _, item := range items {
+				if len(names) == 1 {
+					continue
+				}
+				if child, ok := item.(map[string]interface{}); ok {
+					walk(child, names[1:])
+				}
+			}
+			return
+		}
+		if len(names) > 1 {
+			if child, ok := value.(map[string]interface{}); ok {
+				walk(child, names[1:])
+			}
+			return
+		}
+		switch v := value.(type) {
+		case float64:
+			refs = append(refs, &fieldRef{v, func(bound float64) { object[name] = math.Round(bound) }})
+		case map[string]interface{}:
+			sec, secOk := v["tv_sec"].(float64)
+			nsec, _ := v["tv_nsec"].(float64)
+			if secOk {
+				refs = append(refs, &fieldRef{sec + nsec/1e9, func(bound float64) {
+					v["tv_sec"] = float64(int64(bound))
+					v["tv_nsec"] = float64(int64((bound - float64(int64(bound))) * 1e9))
+				}})
+			}
+		}
+	}
+	walk(fields, strings.Split(path, "."))
+	return refs
+}
+
+func toJSONFields(entry interface{}) (map[string]interface{}, error) {
+	switch e := entry.(type) {
+	case nil:
+		return nil, nil
+	case *UeMetricsEntry:
+		if e == nil {
+			return nil, nil
+		}
+	case *CellMetricsEntry:
+		if e == nil {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func fromJSONFields(fields map[string]interface{}, entry interface{}) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, entry)
+}
+
+func tags(violations []*Violation) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, violation := range violations {
+		if violation.Action == ACTION_TAG && !seen[violation.Rule] {
+			seen[violation.Rule] = true
+			names = append(names, violation.Rule)
+		}
+	}
+	return names
+}
+
+// validatingMetricsStore runs the validator on every entry before writing it
+type validatingMetricsStore struct {
+	MetricsStore
+	validator *Validator
+}
+
+func (s *validatingMetricsStore) PutUe(ueID string, ueMetrics *UeMetricsEntry) error {
+	fields, violations, rejected, err := s.validator.validate(TARGET_UE, ueID, ueMetrics, &ueMetrics.PlausibilityTags)
+	if err != nil {
+		return err
+	}
+	if rejected {
+		return s.reject("UE", ueID, violations)
+	}
+	if err = s.MetricsStore.PutUe(ueID, ueMetrics); err == nil {
+		s.validator.remember(TARGET_UE, ueID, fields)
+	}
+	return err
+}
+
+func (s *validatingMetricsStore) PutCell(cellID string, cellMetrics *CellMetricsEntry) error {
+	fields, violations, rejected, err := s.validator.validate(TARGET_CELL, cellID, cellMetrics, &cellMetrics.PlausibilityTags)
+	if err != nil {
+		return err
+	}
+	if rejected {
+		return s.reject("Cell", cellID, violations)
+	}
+	if err = s.MetricsStore.PutCell(cellID, cellMetrics); err == nil {
+		s.validator.remember(TARGET_CELL, cellID, fields)
+	}
+	return err
+}
+
+func (s *validatingMetricsStore) DeleteUe(ueID string) error {
+	err := s.MetricsStore.DeleteUe(ueID)
+	if err == nil {
+		s.validator.remember(TARGET_UE, ueID, nil)
+	}
+	return err
+}
+
+func (s *validatingMetricsStore) DeleteCell(cellID string) error {
+	err := s.MetricsStore.DeleteCell(cellID)
+	if err == nil {
+		s.validator.remember(TARGET_CELL, cellID, nil)
+	}
+	return err
+}
+
+func (s *validatingMetricsStore) WriteBatch(batch *MetricsBatch) error {
+	valid := NewMetricsBatch()
+	checked := make(map[string]map[string]interface{})
+	for ueID, ueMetrics := range batch.Ues {
+		fields, violations, rejected, err := s.validator.validate(TARGET_UE, ueID, ueMetrics, &ueMetrics.PlausibilityTags)
+		if err != nil {
+			return err
+		}
+		if rejected {
+			s.reject("UE", ueID, violations)
+			continue
+		}
+		valid.PutUe(ueID, ueMetrics)
+		checked[TARGET_UE+"/"+ueID] = fields
+	}
+	for cellID, cellMetrics := range batch.Cells {
+		fields, violations, rejected, err := s.validator.validate(TARGET_CELL, cellID, cellMetrics, &cellMetrics.PlausibilityTags)
+		if err != nil {
+			return err
+		}
+		if rejected {
+			s.reject("Cell", cellID, violations)
+			continue
+		}
+		valid.PutCell(cellID, cellMetrics)
+		checked[TARGET_CELL+"/"+cellID] = fields
+	}
+	err := s.MetricsStore.WriteBatch(valid)
+	if err == nil {
+		for key, fields := range checked {
+			target := strings.SplitN(key, "/", 2)
+			s.validator.remember(target[0], target[1], fields)
+		}
+	}
+	return err
+}
+
+func (s *validatingMetricsStore) reject(kind string, key string, violations []*Violation) error {
+	var rules []string
+	for _, violation := range violations {
+		if violation.Action == ACTION_REJECT {
+			rules = append(rules, fmt.Sprintf("%s (%s=%v)", violation.Rule, violation.Field, violation.Value))
+		}
+	}
+	xapp.Logger.Warn("%sMetrics of [%s] rejected by %s", kind, key, strings.Join(rules, ", "))
+	log.Printf("%sMetrics of [%s] rejected by %s", kind, key, strings.Join(rules, ", "))
+	return ErrRejected
+}
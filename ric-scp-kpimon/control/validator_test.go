@@ -0,0 +1,351 @@
+package control
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func bound(value float64) *float64 {
+	return &value
+}
+
+// newValidator validates rules and returns their validator with a view of a memory store through it
+func newValidator(t *testing.T, rules ...*ValidationRule) (*Validator, MetricsStore, *readCountingStore) {
+	t.Helper()
+	validationRules := &ValidationRules{rules}
+	if err := validationRules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	v := NewValidator(validationRules)
+	backend := &readCountingStore{MetricsStore: NewMemoryMetricsStore()}
+	return v, v.Wrap(backend), backend
+}
+
+// readCountingStore counts the entries read from the backend, which the validator never needs
+type readCountingStore struct {
+	MetricsStore
+	reads int64
+}
+
+func (s *readCountingStore) GetUe(ueID string) (*UeMetricsEntry, error) {
+	atomic.AddInt64(&s.reads, 1)
+	return s.MetricsStore.GetUe(ueID)
+}
+
+func (s *readCountingStore) GetCell(cellID string) (*CellMetricsEntry, error) {
+	atomic.AddInt64(&s.reads, 1)
+	return s.MetricsStore.GetCell(cellID)
+}
+
+func TestValidateRange(t *testing.T) {
+	v, _, _ := newValidator(t,
+		&ValidationRule{Name: "prb-dl", Type: RULE_RANGE, Field: "PRB-Usage-DL", Min: bound(0), Max: bound(273), Action: ACTION_CLAMP},
+		&ValidationRule{Name: "prb-ul", Type: RULE_RANGE, Field: "PRB-Usage-UL", Max: bound(100), Action: ACTION_REJECT},
+		&ValidationRule{Name: "neighbor-rsrp", Type: RULE_RANGE, Field: "Neighbor-Cell-RF[].Cell-RF.rsrp", Min: bound(0), Max: bound(127)},
+		&ValidationRule{Name: "cell-prb", Type: RULE_RANGE, Target: TARGET_CELL, Field: "Avail-PRB-DL", Max: bound(273)},
+	)
+	for _, test := range []struct {
+		name       string
+		change     func(ue *UeMetricsEntry)
+		violations []string
+		rejected   bool
+		prbDL      int64
+		tags       []string
+	}{
+		{"plausible", func(ue *UeMetricsEntry) {}, nil, false, 10, nil},
+		{"clamped above", func(ue *UeMetricsEntry) { ue.PRBUsageDL = 300 }, []string{"prb-dl"}, false, 273, nil},
+		{"clamped below", func(ue *UeMetricsEntry) { ue.PRBUsageDL = -5 }, []string{"prb-dl"}, false, 0, nil},
+		{"rejected", func(ue *UeMetricsEntry) { ue.PRBUsageUL = 101 }, []string{"prb-ul"}, true, 10, nil},
+		{"tagged for one of two neighbours", func(ue *UeMetricsEntry) {
+			ue.NeighborCellsRF = append(ue.NeighborCellsRF, NeighborCellRFType{CellID: "cell_3", CellRF: CellRFType{RSRP: 200}})
+		}, []string{"neighbor-rsrp"}, false, 10, []string{"neighbor-rsrp"}},
+		{"tagged once for two neighbours", func(ue *UeMetricsEntry) {
+			ue.NeighborCellsRF[0].CellRF.RSRP = -1
+			ue.NeighborCellsRF = append(ue.NeighborCellsRF, NeighborCellRFType{CellID: "cell_3", CellRF: CellRFType{RSRP: 200}})
+		}, []string{"neighbor-rsrp", "neighbor-rsrp"}, false, 10, []string{"neighbor-rsrp"}},
+		{"stale tags dropped", func(ue *UeMetricsEntry) {
+			ue.NeighborCellsRF = nil
+			ue.PlausibilityTags = []string{"neighbor-rsrp"}
+		}, nil, false, 10, nil},
+	} {
+		ue := storeUe("ue_1")
+		ue.NeighborCellsRF[0].CellRF.RSRP = 50 //reported values are mapped to 0-127
+		test.change(ue)
+		violations, rejected, err := v.ValidateUe("ue_1", ue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rules []string
+		for _, violation := range violations {
+			rules = append(rules, violation.Rule)
+		}
+		if !reflect.DeepEqual(rules, test.violations) || rejected != test.rejected {
+			t.Errorf("%s: violations %v, rejected %v, want %v, %v", test.name, rules, rejected, test.violations, test.rejected)
+		}
+		if ue.PRBUsageDL != test.prbDL || !reflect.DeepEqual(ue.PlausibilityTags, test.tags) {
+			t.Errorf("%s: PRB usage %d, tags %v, want %d, %v", test.name, ue.PRBUsageDL, ue.PlausibilityTags, test.prbDL, test.tags)
+		}
+	}
+	if violations, _, _ := v.ValidateUe("ue_1", &UeMetricsEntry{PRBUsageDL: 300}); violations[0].Value != 300 || violations[0].Bound != 273 {
+		t.Errorf("clamp violation %+v", violations[0])
+	}
+
+	//an entry violating a rule twice counts once, the cell rule was not run on UE entries
+	want := map[string]RuleCounter{
+		"prb-dl":        {Checked: 8, Violated: 3, Clamped: 3},
+		"prb-ul":        {Checked: 8, Violated: 1, Rejected: 1},
+		"neighbor-rsrp": {Checked: 8, Violated: 2, Tagged: 2},
+		"cell-prb":      {},
+	}
+	if counters := v.Counters(); !reflect.DeepEqual(counters, want) {
+		t.Errorf("counters %+v, want %+v", counters, want)
+	}
+}
+
+func TestValidatingStore(t *testing.T) {
+	_, store, backend := newValidator(t,
+		&ValidationRule{Name: "prb-dl", Type: RULE_RANGE, Field: "PRB-Usage-DL", Max: bound(273), Action: ACTION_CLAMP},
+		&ValidationRule{Name: "prb-ul", Type: RULE_RANGE, Field: "PRB-Usage-UL", Max: bound(100), Action: ACTION_REJECT},
+		&ValidationRule{Name: "cell-prb", Type: RULE_RANGE, Target: TARGET_CELL, Field: "Avail-PRB-DL", Max: bound(273), Action: ACTION_REJECT},
+	)
+	ue := storeUe("ue_1")
+	ue.PRBUsageDL = 300
+	if err := store.PutUe("ue_1", ue); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := backend.MetricsStore.GetUe("ue_1"); stored.PRBUsageDL != 273 {
+		t.Errorf("stored PRB usage %d, want the clamped 273", stored.PRBUsageDL)
+	}
+	ue = storeUe("ue_2")
+	ue.PRBUsageUL = 101
+	if err := store.PutUe("ue_2", ue); err != ErrRejected {
+		t.Errorf("PutUe of a rejected entry returned %v", err)
+	}
+	cell := storeCell()
+	cell.AvailPRBDL = 300
+	if err := store.PutCell("cell_1", cell); err != ErrRejected {
+		t.Errorf("PutCell of a rejected entry returned %v", err)
+	}
+
+	//a batch keeps the entries that pass
+	batch := NewMetricsBatch()
+	batch.PutUe("ue_2", ue)
+	batch.PutUe("ue_3", storeUe("ue_3"))
+	batch.PutCell("cell_1", cell)
+	batch.PutCell("cell_2", storeCell())
+	if err := store.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"ue_2", "ue_3"} {
+		if ok, _ := backend.MetricsStore.ExistsUe(key); ok != (key == "ue_3") {
+			t.Errorf("UE %s stored: %v", key, ok)
+		}
+	}
+	for _, key := range []string{"cell_1", "cell_2"} {
+		if ok, _ := backend.MetricsStore.ExistsCell(key); ok != (key == "cell_2") {
+			t.Errorf("cell %s stored: %v", key, ok)
+		}
+	}
+	if backend.reads != 0 {
+		t.Errorf("%d entries read from the backend", backend.reads)
+	}
+}
+
+func TestValidateMonotonic(t *testing.T) {
+	at := func(sec int64) *UeMetricsEntry {
+		ue := storeUe("ue_1")
+		ue.MeasTimestampPRB = Timestamp{TVsec: sec, TVnsec: 500}
+		return ue
+	}
+	v, store, backend := newValidator(t, &ValidationRule{Name: "prb-timestamp", Type: RULE_MONOTONIC, Field: "Meas-Timestamp-PRB"})
+	for i, step := range []struct {
+		sec  int64
+		tags []string
+	}{
+		{100, nil},
+		{100, nil},
+		{90, []string{"prb-timestamp"}},
+		{95, nil}, //the tagged entry was written, it is the previous one now
+	} {
+		ue := at(step.sec)
+		if err := store.PutUe("ue_1", ue); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ue.PlausibilityTags, step.tags) {
+			t.Errorf("write %d at %d: tags %v, want %v", i, step.sec, ue.PlausibilityTags, step.tags)
+		}
+	}
+	if violations, _, _ := v.ValidateUe("ue_1", at(94)); len(violations) != 1 || int64(violations[0].Bound) != 95 || int64(violations[0].Value) != 94 {
+		t.Errorf("violations %+v, want the previous timestamp as bound", violations)
+	}
+	if violations, _, _ := v.ValidateUe("ue_2", at(1)); len(violations) != 0 {
+		t.Errorf("first write of a key violated %+v", violations[0])
+	}
+
+	//only the entries written through the validator are known, a rejected entry is not one of them
+	v, store, backend = newValidator(t, &ValidationRule{Name: "prb-timestamp", Type: RULE_MONOTONIC, Field: "Meas-Timestamp-PRB", Action: ACTION_REJECT})
+	backend.MetricsStore.PutUe("ue_1", at(200))
+	for i, step := range []struct {
+		sec int64
+		err error
+	}{
+		{100, nil},
+		{90, ErrRejected},
+		{95, ErrRejected},
+		{100, nil},
+	} {
+		if err := store.PutUe("ue_1", at(step.sec)); err != step.err {
+			t.Errorf("write %d at %d returned %v, want %v", i, step.sec, err, step.err)
+		}
+	}
+	store.DeleteUe("ue_1")
+	if err := store.PutUe("ue_1", at(50)); err != nil {
+		t.Errorf("write after a delete returned %v", err)
+	}
+	if backend.reads != 0 {
+		t.Errorf("%d entries read from the backend", backend.reads)
+	}
+}
+
+func TestValidateCrossField(t *testing.T) {
+	v, store, backend := newValidator(t,
+		&ValidationRule{Name: "prb-vs-cell", Type: RULE_CROSS_FIELD, Field: "PRB-Usage-DL", Op: "le", RefTarget: TARGET_CELL, RefField: "Avail-PRB-DL"},
+		&ValidationRule{Name: "ul-below-dl", Type: RULE_CROSS_FIELD, Field: "PRB-Usage-UL", Op: "lt", RefField: "PRB-Usage-DL", Action: ACTION_CLAMP},
+	)
+	ue := func(prbDL int64) *UeMetricsEntry {
+		ue := storeUe("ue_1")
+		ue.PRBUsageDL = prbDL
+		return ue
+	}
+	check := func(step string, prbDL int64, want ...string) {
+		t.Helper()
+		violations, _, err := v.ValidateUe("ue_1", ue(prbDL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rules []string
+		for _, violation := range violations {
+			rules = append(rules, violation.Rule)
+		}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("%s: violations %v, want %v", step, rules, want)
+		}
+	}
+
+	//the serving cell is unknown until it is written through the validator
+	backend.MetricsStore.PutCell("cell_1", storeCell())
+	check("unknown cell", 90)
+	store.PutCell("cell_1", storeCell())
+	check("above the cell", 90, "prb-vs-cell")
+	check("within the cell", 80)
+	batch := NewMetricsBatch()
+	cell := storeCell()
+	cell.AvailPRBDL = 100
+	batch.PutCell("cell_1", cell)
+	store.WriteBatch(batch)
+	check("within the cell of a batch", 90)
+	store.DeleteCell("cell_1")
+	check("deleted cell", 200)
+
+	//a comparison in the same entry clamps to the other field
+	entry := ue(10)
+	entry.PRBUsageUL = 12
+	v.ValidateUe("ue_1", entry)
+	if entry.PRBUsageUL != 10 || entry.PlausibilityTags != nil {
+		t.Errorf("UL %d clamped to the DL %d, tags %v", entry.PRBUsageUL, entry.PRBUsageDL, entry.PlausibilityTags)
+	}
+	if backend.reads != 0 {
+		t.Errorf("%d entries read from the backend", backend.reads)
+	}
+}
+
+func TestValidateNotFuture(t *testing.T) {
+	v, _, _ := newValidator(t,
+		&ValidationRule{Name: "ue-future", Type: RULE_NOT_FUTURE, Field: "Meas-Timestamp-PRB", Tolerance: "5s"},
+		&ValidationRule{Name: "cell-future", Type: RULE_NOT_FUTURE, Target: TARGET_CELL, Field: "Meas-Timestamp-PRB", Action: ACTION_REJECT},
+	)
+	now := time.Now().Unix()
+	for _, test := range []struct {
+		sec  int64
+		tags []string
+	}{
+		{now - 3600, nil},
+		{now + 2, nil}, //within the tolerance
+		{now + 3600, []string{"ue-future"}},
+	} {
+		ue := storeUe("ue_1")
+		ue.MeasTimestampPRB.TVsec = test.sec
+		v.ValidateUe("ue_1", ue)
+		if !reflect.DeepEqual(ue.PlausibilityTags, test.tags) {
+			t.Errorf("timestamp now%+ds: tags %v, want %v", test.sec-now, ue.PlausibilityTags, test.tags)
+		}
+	}
+	cell := storeCell()
+	cell.MeasTimestampPRB.TVsec = now + 2
+	if _, rejected, _ := v.ValidateCell("cell_1", cell); !rejected {
+		t.Error("cell timestamp 2s ahead without tolerance not rejected")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	v, _, _ := newValidator(t,
+		&ValidationRule{Name: "prb-dl", Type: RULE_RANGE, Field: "PRB-Usage-DL", Max: bound(273), Action: ACTION_CLAMP},
+		&ValidationRule{Name: "prb-timestamp", Type: RULE_MONOTONIC, Field: "Meas-Timestamp-PRB", Action: ACTION_REJECT},
+	)
+	previous := storeUe("ue_1")
+	previous.MeasTimestampPRB.TVsec = 100
+	entry := storeUe("ue_1")
+	entry.PRBUsageDL = 300
+	entry.MeasTimestampPRB.TVsec = 90
+	violations, rejected := v.Evaluate(TARGET_UE, entry, previous)
+	if len(violations) != 2 || !rejected {
+		t.Errorf("violations %+v, rejected %v, want both rules", violations, rejected)
+	}
+	if entry.PRBUsageDL != 300 || entry.PlausibilityTags != nil {
+		t.Errorf("evaluated entry modified to %+v", entry)
+	}
+	if violations, rejected := v.Evaluate(TARGET_UE, entry, nil); len(violations) != 1 || rejected {
+		t.Errorf("without previous entry: violations %+v, rejected %v", violations, rejected)
+	}
+	for name, counter := range v.Counters() {
+		if counter != (RuleCounter{}) {
+			t.Errorf("rule %s counted %+v by Evaluate", name, counter)
+		}
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	if _, err := LoadValidationRules("../rules/plausibility.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		rule *ValidationRule
+		err  string
+	}{
+		{&ValidationRule{Type: RULE_RANGE, Field: "PRB-Usage-DL"}, "range without min or max"},
+		{&ValidationRule{Type: RULE_RANGE, Min: bound(0)}, "missing field"},
+		{&ValidationRule{Type: "sum", Field: "PRB-Usage-DL"}, "unknown rule type sum"},
+		{&ValidationRule{Type: RULE_RANGE, Target: "slice", Field: "PRB-Usage-DL", Min: bound(0)}, "unknown target slice"},
+		{&ValidationRule{Type: RULE_RANGE, Field: "PRB-Usage-DL", Min: bound(0), Action: "drop"}, "unknown action drop"},
+		{&ValidationRule{Type: RULE_MONOTONIC, Field: "Neighbor-Cell-RF[].Cell-RF.rsrp"}, "monotonic field cannot go through an array"},
+		{&ValidationRule{Type: RULE_CROSS_FIELD, Field: "PRB-Usage-DL", Op: "ne", RefField: "PRB-Usage-UL"}, "unknown op ne"},
+		{&ValidationRule{Type: RULE_CROSS_FIELD, Field: "PRB-Usage-DL", Op: "le"}, "cross_field without ref_field"},
+		{&ValidationRule{Type: RULE_CROSS_FIELD, Target: TARGET_CELL, Field: "Avail-PRB-DL", Op: "le", RefTarget: TARGET_CELL, RefField: "Avail-PRB-UL"}, "ref_target cell is only supported for ue rules"},
+		{&ValidationRule{Type: RULE_NOT_FUTURE, Field: "Meas-Timestamp-PRB", Tolerance: "soon"}, "time: invalid duration"},
+	} {
+		err := (&ValidationRules{[]*ValidationRule{test.rule}}).Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), "validation rule rule-0: "+test.err) {
+			t.Errorf("%+v: error %v, want %s", test.rule, err, test.err)
+		}
+	}
+	rule := &ValidationRule{Name: "a", Type: RULE_RANGE, Field: "PRB-Usage-DL", Min: bound(0)}
+	if err := (&ValidationRules{[]*ValidationRule{rule, rule}}).Validate(); err == nil || err.Error() != "Duplicate validation rule: a" {
+		t.Errorf("duplicate rule: %v", err)
+	}
+	if rule.Target != TARGET_UE || rule.Action != ACTION_TAG {
+		t.Errorf("rule defaults %+v", rule)
+	}
+}
@@ -0,0 +1,155 @@
+package dataset
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+)
+
+const (
+	LABEL_GENUINE  = "genuine"  //written by handleIndication from a DU, CU-CP or CU-UP usage report
+	LABEL_INJECTED = "injected" //written by an injection campaign, including its cleanup
+)
+
+// Row is one store write, with the UE or cell entry after the write flattened into columns.
+// Timestamps are in nanoseconds, Neighbor-Cell-RF is kept as its JSON encoding.
+type Row struct {
+	TimeNs                 int64  `parquet:"name=time_ns, type=INT64"`
+	Time                   string `parquet:"name=time, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Label                  string `parquet:"name=label, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Campaign               string `parquet:"name=campaign, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Primitive              string `parquet:"name=primitive, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Target                 string `parquet:"name=target, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UeID                   string `parquet:"name=ue_id, type=BYTE_ARRAY, convertedtype=UTF8"`   //empty for cell rows
+	CellID                 string `parquet:"name=cell_id, type=BYTE_ARRAY, convertedtype=UTF8"` //serving cell for UE rows
+	IndicationSN           int64  `parquet:"name=indication_sn, type=INT64"`
+	Success                bool   `parquet:"name=success, type=BOOLEAN"`
+	Deleted                bool   `parquet:"name=deleted, type=BOOLEAN"`
+	Fields                 string `parquet:"name=fields, type=BYTE_ARRAY, convertedtype=UTF8"` //changed fields separated by ;
+	MeasTimestampPDCPBytes int64  `parquet:"name=meas_timestamp_pdcp_bytes, type=INT64"`
+	PDCPBytesDL            int64  `parquet:"name=pdcp_bytes_dl, type=INT64"`
+	PDCPBytesUL            int64  `parquet:"name=pdcp_bytes_ul, type=INT64"`
+	MeasTimestampPRB       int64  `parquet:"name=meas_timestamp_prb, type=INT64"`
+	PRBUsageDL             int64  `parquet:"name=prb_usage_dl, type=INT64"`
+	PRBUsageUL             int64  `parquet:"name=prb_usage_ul, type=INT64"`
+	AvailPRBDL             int64  `parquet:"name=avail_prb_dl, type=INT64"`
+	AvailPRBUL             int64  `parquet:"name=avail_prb_ul, type=INT64"`
+	MeasTimeRF             int64  `parquet:"name=meas_time_rf, type=INT64"`
+	ServingRSRP            int32  `parquet:"name=serving_rsrp, type=INT32"`
+	ServingRSRQ            int32  `parquet:"name=serving_rsrq, type=INT32"`
+	ServingRSSINR          int32  `parquet:"name=serving_rssinr, type=INT32"`
+	NeighborCount          int32  `parquet:"name=neighbor_count, type=INT32"`
+	NeighborCellsRF        string `parquet:"name=neighbor_cells_rf, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Violations             string `parquet:"name=violations, type=BYTE_ARRAY, convertedtype=UTF8"` //plausibility rules violated separated by ;
+}
+
+// Build merges the records of several ledgers into time ordered rows, failed writes are
+// left out unless includeFailed is set since they did not change the store
+func Build(ledgers [][]*ledger.Record, includeFailed bool) ([]*Row, error) {
+	var rows []*Row
+	for _, records := range ledgers {
+		for _, record := range records {
+			if !record.Success && !includeFailed {
+				continue
+			}
+			row, err := newRow(record)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].TimeNs < rows[j].TimeNs
+	})
+	return rows, nil
+}
+
+func newRow(record *ledger.Record) (*Row, error) {
+	row := &Row{
+		TimeNs:       record.Time.UnixNano(),
+		Time:         record.Time.UTC().Format(time.RFC3339Nano),
+		Label:        LABEL_INJECTED,
+		Campaign:     record.Campaign,
+		Primitive:    record.Primitive,
+		Target:       record.Target,
+		IndicationSN: record.IndicationSN,
+		Success:      record.Success,
+		Deleted:      isNull(record.After),
+		Fields:       strings.Join(record.Fields, ";"),
+		Violations:   strings.Join(record.Violations, ";"),
+	}
+	if record.Primitive == ledger.GENUINE {
+		row.Label = LABEL_GENUINE
+	}
+	//a deleted entry is described by its last value
+	entry := record.After
+	if row.Deleted {
+		entry = record.Before
+	}
+
+	if record.Target == ledger.TARGET_CELL {
+		row.CellID = record.Key
+		if isNull(entry) {
+			return row, nil
+		}
+		cellMetrics := &control.CellMetricsEntry{}
+		if err := json.Unmarshal(entry, cellMetrics); err != nil {
+			return nil, err
+		}
+		row.MeasTimestampPDCPBytes = nanoseconds(cellMetrics.MeasTimestampPDCPBytes)
+		row.PDCPBytesDL = cellMetrics.PDCPBytesDL
+		row.PDCPBytesUL = cellMetrics.PDCPBytesUL
+		row.MeasTimestampPRB = nanoseconds(cellMetrics.MeasTimestampPRB)
+		row.AvailPRBDL = cellMetrics.AvailPRBDL
+		row.AvailPRBUL = cellMetrics.AvailPRBUL
+		row.violations(cellMetrics.PlausibilityTags)
+		return row, nil
+	}
+
+	row.UeID = record.Key
+	if isNull(entry) {
+		return row, nil
+	}
+	ueMetrics := &control.UeMetricsEntry{}
+	if err := json.Unmarshal(entry, ueMetrics); err != nil {
+		return nil, err
+	}
+	row.CellID = ueMetrics.ServingCellID
+	row.MeasTimestampPDCPBytes = nanoseconds(ueMetrics.MeasTimestampPDCPBytes)
+	row.PDCPBytesDL = ueMetrics.PDCPBytesDL
+	row.PDCPBytesUL = ueMetrics.PDCPBytesUL
+	row.MeasTimestampPRB = nanoseconds(ueMetrics.MeasTimestampPRB)
+	row.PRBUsageDL = ueMetrics.PRBUsageDL
+	row.PRBUsageUL = ueMetrics.PRBUsageUL
+	row.MeasTimeRF = nanoseconds(ueMetrics.MeasTimeRF)
+	row.ServingRSRP = int32(ueMetrics.ServingCellRF.RSRP)
+	row.ServingRSRQ = int32(ueMetrics.ServingCellRF.RSRQ)
+	row.ServingRSSINR = int32(ueMetrics.ServingCellRF.RSSINR)
+	row.NeighborCount = int32(len(ueMetrics.NeighborCellsRF))
+	if ueMetrics.NeighborCellsRF != nil {
+		neighbors, _ := json.Marshal(ueMetrics.NeighborCellsRF)
+		row.NeighborCellsRF = string(neighbors)
+	}
+	row.violations(ueMetrics.PlausibilityTags)
+	return row, nil
+}
+
+// violations falls back to the rules the validator tagged a genuine entry with
+func (row *Row) violations(tags []string) {
+	if row.Violations == "" && row.Label == LABEL_GENUINE {
+		row.Violations = strings.Join(tags, ";")
+	}
+}
+
+func isNull(entry json.RawMessage) bool {
+	return len(entry) == 0 || string(entry) == "null"
+}
+
+func nanoseconds(timestamp control.Timestamp) int64 {
+	return timestamp.TVsec*int64(time.Second) + timestamp.TVnsec
+}
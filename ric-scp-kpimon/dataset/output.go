@@ -0,0 +1,64 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// columns lists the column names of Row, taken from the name of its parquet tags so that
+// the CSV and Parquet outputs share one schema
+func columns() []string {
+	rowType := reflect.TypeOf(Row{})
+	names := make([]string, rowType.NumField())
+	for i := range names {
+		tag := rowType.Field(i).Tag.Get("parquet")
+		names[i] = strings.TrimPrefix(strings.Split(tag, ",")[0], "name=")
+	}
+	return names
+}
+
+// WriteCSV writes the rows with a header line of column names
+func WriteCSV(w io.Writer, rows []*Row) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(columns()); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		value := reflect.ValueOf(row).Elem()
+		fields := make([]string, value.NumField())
+		for i := range fields {
+			switch field := value.Field(i); field.Kind() {
+			case reflect.String:
+				fields[i] = field.String()
+			case reflect.Bool:
+				fields[i] = strconv.FormatBool(field.Bool())
+			default:
+				fields[i] = strconv.FormatInt(field.Int(), 10)
+			}
+		}
+		if err := out.Write(fields); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteParquet writes the rows as a single Parquet file
+func WriteParquet(w io.Writer, rows []*Row) error {
+	out, err := writer.NewParquetWriterFromWriter(w, new(Row), 1)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err = out.Write(row); err != nil {
+			return err
+		}
+	}
+	return out.WriteStop()
+}
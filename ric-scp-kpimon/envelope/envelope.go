@@ -0,0 +1,233 @@
+// Package envelope signs and verifies the metric records kpimon writes to the metrics store.
+//
+// A signed record is stored as a JSON envelope:
+//
+//	{"payload": <UE or cell entry>, "writer": "kpimon", "seq": 1, "timestamp": 1600000000000000000, "alg": "ed25519", "sig": "<base64>"}
+//
+// The signature covers the algorithm, the writer id, the store key of the record, the sequence
+// number, the timestamp and the payload, so a signed record cannot be moved to another key.
+// Consumer xApps only need this package and a key file to verify what they read.
+package envelope
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ALG_HMAC_SHA256 = "hmac-sha256"
+	ALG_ED25519     = "ed25519"
+
+	signingContext = "kpimon-envelope-v1"
+)
+
+var (
+	ErrNotSigned     = errors.New("record is not a signed envelope")
+	ErrUnknownWriter = errors.New("no verification key for the writer of the record")
+	ErrBadSignature  = errors.New("signature of the record does not verify")
+	ErrReplayed      = errors.New("record is older than the last one verified for its key")
+	ErrNoSigningKey  = errors.New("key file has no signing key for the writer")
+	ErrKeyMismatch   = errors.New("private key of the key file does not match the public key of the writer")
+)
+
+type Envelope struct {
+	Payload   json.RawMessage `json:"payload"`
+	Writer    string          `json:"writer"`
+	Seq       uint64          `json:"seq"`
+	Timestamp int64           `json:"timestamp"` //nanoseconds since the epoch
+	Alg       string          `json:"alg"`
+	Signature []byte          `json:"sig"`
+}
+
+// IsEnvelope reports whether data looks like an envelope, without checking its signature
+func IsEnvelope(data []byte) bool {
+	_, err := Parse(data)
+	return err == nil
+}
+
+// Payload returns the payload of an envelope without checking its signature, or data itself
+// when it is not an envelope
+func Payload(data []byte) []byte {
+	if e, err := Parse(data); err == nil {
+		return e.Payload
+	}
+	return data
+}
+
+// Parse decodes an envelope without checking its signature, see Verifier.Verify
+func Parse(data []byte) (*Envelope, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, ErrNotSigned
+	}
+	e := &Envelope{}
+	if err := json.Unmarshal(data, e); err != nil || e.Payload == nil || e.Signature == nil || e.Alg == "" {
+		return nil, ErrNotSigned
+	}
+	return e, nil
+}
+
+// signingInput is the byte string the signature is computed over, strings are length prefixed
+func signingInput(alg string, writer string, key string, seq uint64, timestamp int64, payload []byte) []byte {
+	buf := &bytes.Buffer{}
+	for _, s := range []string{signingContext, alg, writer, key} {
+		binary.Write(buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	binary.Write(buf, binary.BigEndian, seq)
+	binary.Write(buf, binary.BigEndian, timestamp)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// Signer seals payloads in envelopes under the writer id and signing key of a key file
+type Signer struct {
+	alg    string
+	writer string
+	secret []byte             //HMAC secret
+	priv   ed25519.PrivateKey //Ed25519 private key
+	seq    *uint64
+}
+
+// NewSigner returns a Signer for the writer of keys. Sequence numbers start at the current
+// time in nanoseconds so that they keep increasing across restarts. With ed25519 the public key
+// derived from private_key must be the one keys holds for the writer, or nothing it signs would verify.
+func NewSigner(keys *KeyFile) (*Signer, error) {
+	s := &Signer{alg: keys.Algorithm, writer: keys.Writer, seq: new(uint64)}
+	*s.seq = uint64(time.Now().UnixNano())
+	var err error
+	switch keys.Algorithm {
+	case ALG_HMAC_SHA256:
+		s.secret, err = keys.key(keys.Writer)
+	case ALG_ED25519:
+		if s.priv, err = keys.privateKey(); err == nil {
+			err = checkPublicKey(keys, s.priv)
+		}
+	default:
+		err = errors.New("Unknown signature algorithm: " + keys.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func checkPublicKey(keys *KeyFile, priv ed25519.PrivateKey) error {
+	pub, err := keys.key(keys.Writer)
+	if err != nil {
+		return err
+	}
+	derived := ed25519.NewKeyFromSeed(priv.Seed()).Public().(ed25519.PublicKey)
+	if !bytes.Equal(derived, pub) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+func (s *Signer) Writer() string {
+	return s.writer
+}
+
+// Seal wraps payload, a JSON document, in a signed envelope bound to the store key
+func (s *Signer) Seal(key string, payload []byte) ([]byte, error) {
+	//normalize the payload the way encoding/json will write it, so that the signed bytes are the stored ones
+	normalized, err := json.Marshal(json.RawMessage(payload))
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{
+		Payload:   normalized,
+		Writer:    s.writer,
+		Seq:       atomic.AddUint64(s.seq, 1),
+		Timestamp: time.Now().UnixNano(),
+		Alg:       s.alg,
+	}
+	input := signingInput(e.Alg, e.Writer, key, e.Seq, e.Timestamp, e.Payload)
+	if s.alg == ALG_HMAC_SHA256 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(input)
+		e.Signature = mac.Sum(nil)
+	} else {
+		e.Signature = ed25519.Sign(s.priv, input)
+	}
+	return json.Marshal(e)
+}
+
+// Verifier checks envelopes against the verification keys of a key file
+type Verifier struct {
+	alg  string
+	keys map[string][]byte //writer id to HMAC secret or Ed25519 public key
+}
+
+func NewVerifier(keys *KeyFile) (*Verifier, error) {
+	if keys.Algorithm != ALG_HMAC_SHA256 && keys.Algorithm != ALG_ED25519 {
+		return nil, errors.New("Unknown signature algorithm: " + keys.Algorithm)
+	}
+	v := &Verifier{keys.Algorithm, make(map[string][]byte, len(keys.Keys))}
+	for writer := range keys.Keys {
+		key, err := keys.key(writer)
+		if err != nil {
+			return nil, err
+		}
+		if keys.Algorithm == ALG_ED25519 && len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("Invalid ed25519 public key for writer " + writer)
+		}
+		v.keys[writer] = key
+	}
+	return v, nil
+}
+
+// Verify checks that data is an envelope signed for the store key by a known writer and returns it
+func (v *Verifier) Verify(key string, data []byte) (*Envelope, error) {
+	e, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	writerKey, ok := v.keys[e.Writer]
+	if !ok || e.Alg != v.alg {
+		return e, ErrUnknownWriter
+	}
+	input := signingInput(e.Alg, e.Writer, key, e.Seq, e.Timestamp, e.Payload)
+	if e.Alg == ALG_HMAC_SHA256 {
+		mac := hmac.New(sha256.New, writerKey)
+		mac.Write(input)
+		ok = hmac.Equal(mac.Sum(nil), e.Signature)
+	} else {
+		ok = ed25519.Verify(ed25519.PublicKey(writerKey), input, e.Signature)
+	}
+	if !ok {
+		return e, ErrBadSignature
+	}
+	return e, nil
+}
+
+// ReplayGuard remembers the highest sequence number verified per store key and writer,
+// so that a validly signed but stale record written back to its key is detected
+type ReplayGuard struct {
+	last map[string]uint64
+	mu   *sync.Mutex
+}
+
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{make(map[string]uint64), &sync.Mutex{}}
+}
+
+// Check accepts e for key if its sequence number is not older than the last one accepted
+func (g *ReplayGuard) Check(key string, e *Envelope) error {
+	id := e.Writer + "/" + key
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last, ok := g.last[id]; ok && e.Seq < last {
+		return ErrReplayed
+	}
+	g.last[id] = e.Seq
+	return nil
+}
@@ -0,0 +1,206 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var algorithms = []string{ALG_HMAC_SHA256, ALG_ED25519}
+
+const PAYLOAD = `{"UE ID": "1", "PRB-Usage-DL": 10}`
+
+func signerOf(t *testing.T, keys *KeyFile) (*Signer, *Verifier) {
+	t.Helper()
+	signer, err := NewSigner(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, verifier
+}
+
+func generate(t *testing.T, algorithm string) *KeyFile {
+	t.Helper()
+	keys, err := GenerateKeyFile(algorithm, "kpimon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestSealVerify(t *testing.T) {
+	for _, algorithm := range algorithms {
+		keys := generate(t, algorithm)
+		signer, verifier := signerOf(t, keys)
+		data, err := signer.Seal("1", []byte(PAYLOAD))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEnvelope(data) {
+			t.Fatalf("%s: sealed record %s is not an envelope", algorithm, data)
+		}
+		e, err := verifier.Verify("1", data)
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		//the payload is stored compacted, as encoding/json writes it
+		if string(e.Payload) != `{"UE ID":"1","PRB-Usage-DL":10}` || !bytes.Equal(Payload(data), e.Payload) {
+			t.Errorf("%s: payload %s", algorithm, e.Payload)
+		}
+		if e.Writer != "kpimon" || e.Alg != algorithm || e.Seq == 0 || e.Timestamp == 0 {
+			t.Errorf("%s: envelope %+v", algorithm, e)
+		}
+
+		//the public part of the key file verifies, a verifier of other keys does not
+		public, err := NewVerifier(keys.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := public.Verify("1", data); err != nil {
+			t.Errorf("%s: public key file: %v", algorithm, err)
+		}
+		_, other := signerOf(t, generate(t, algorithm))
+		if _, err := other.Verify("1", data); err != ErrBadSignature {
+			t.Errorf("%s: wrong key: %v, want ErrBadSignature", algorithm, err)
+		}
+		stranger, _ := GenerateKeyFile(algorithm, "stranger")
+		_, strangers := signerOf(t, stranger)
+		if _, err := strangers.Verify("1", data); err != ErrUnknownWriter {
+			t.Errorf("%s: unknown writer: %v, want ErrUnknownWriter", algorithm, err)
+		}
+
+		//the signature binds the record to its key, payload and sequence number
+		if _, err := verifier.Verify("2", data); err != ErrBadSignature {
+			t.Errorf("%s: record moved to another key: %v, want ErrBadSignature", algorithm, err)
+		}
+		for name, tamper := range map[string]func(e *Envelope){
+			"payload": func(e *Envelope) { e.Payload = json.RawMessage(`{"UE ID":"1","PRB-Usage-DL":99}`) },
+			"seq":     func(e *Envelope) { e.Seq++ },
+			"writer":  func(e *Envelope) { e.Writer = "stranger" },
+			"alg":     func(e *Envelope) { e.Alg = ALG_HMAC_SHA256 + ALG_ED25519 },
+		} {
+			tampered, _ := Parse(data)
+			tamper(tampered)
+			tamperedData, _ := json.Marshal(tampered)
+			if _, err := verifier.Verify("1", tamperedData); err == nil {
+				t.Errorf("%s: tampered %s verified", algorithm, name)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, data := range []string{
+		PAYLOAD,
+		`{"payload": {"UE ID": "1"}, "alg": "ed25519"}`,
+		`{"payload": {"UE ID": "1"}, "sig": "AAAA"}`,
+		`[1, 2]`,
+		`not json`,
+		``,
+	} {
+		if e, err := Parse([]byte(data)); err != ErrNotSigned {
+			t.Errorf("Parse(%s) returned %+v, %v, want ErrNotSigned", data, e, err)
+		}
+		if IsEnvelope([]byte(data)) {
+			t.Errorf("%s taken for an envelope", data)
+		}
+		if payload := Payload([]byte(data)); string(payload) != data {
+			t.Errorf("payload of %s is %s", data, payload)
+		}
+	}
+	if _, err := Parse([]byte(` {"payload": {}, "alg": "ed25519", "sig": "AAAA"} `)); err != nil {
+		t.Errorf("envelope with surrounding spaces: %v", err)
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	signer, verifier := signerOf(t, generate(t, ALG_ED25519))
+	var envelopes []*Envelope
+	for i := 0; i < 2; i++ {
+		data, _ := signer.Seal("1", []byte(PAYLOAD))
+		e, err := verifier.Verify("1", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		envelopes = append(envelopes, e)
+	}
+	if envelopes[1].Seq <= envelopes[0].Seq {
+		t.Fatalf("sequence numbers %d then %d", envelopes[0].Seq, envelopes[1].Seq)
+	}
+
+	guard := NewReplayGuard()
+	for i, step := range []struct {
+		key string
+		e   *Envelope
+		err error
+	}{
+		{"1", envelopes[0], nil},
+		{"1", envelopes[1], nil},
+		{"1", envelopes[1], nil}, //the same record read again
+		{"1", envelopes[0], ErrReplayed},
+		{"2", envelopes[0], nil}, //keys are guarded apart
+		{"1", &Envelope{Writer: "other", Seq: 1}, nil},
+	} {
+		if err := guard.Check(step.key, step.e); err != step.err {
+			t.Errorf("check %d of seq %d for key %s: %v, want %v", i, step.e.Seq, step.key, err, step.err)
+		}
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	keys := generate(t, ALG_ED25519)
+	other := generate(t, ALG_ED25519)
+
+	mismatch := *keys
+	mismatch.PrivateKey = other.PrivateKey
+	if _, err := NewSigner(&mismatch); err != ErrKeyMismatch {
+		t.Errorf("private key of another writer: %v, want ErrKeyMismatch", err)
+	}
+	missing := *keys
+	missing.Keys = map[string]string{"other": other.Keys["kpimon"]}
+	if _, err := NewSigner(&missing); err != ErrNoSigningKey {
+		t.Errorf("no public key for the writer: %v, want ErrNoSigningKey", err)
+	}
+	if _, err := NewSigner(keys.Public()); err != ErrNoSigningKey {
+		t.Errorf("public key file: %v, want ErrNoSigningKey", err)
+	}
+	if _, err := NewSigner(&KeyFile{Algorithm: "rsa", Writer: "kpimon"}); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+	if _, err := NewSigner(generate(t, ALG_HMAC_SHA256).Public()); err != ErrNoSigningKey {
+		t.Errorf("hmac key file without writer: %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestKeyFileSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envelope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, algorithm := range algorithms {
+		keys := generate(t, algorithm)
+		path := filepath.Join(dir, algorithm+".json")
+		if err := keys.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s: key file saved with mode %v (%v)", algorithm, info.Mode(), err)
+		}
+		loaded, err := LoadKeyFile(path)
+		if err != nil || !reflect.DeepEqual(loaded, keys) {
+			t.Errorf("%s: loaded %+v, %v, want %+v", algorithm, loaded, err, keys)
+		}
+	}
+	if _, err := GenerateKeyFile("rsa", "kpimon"); err == nil {
+		t.Error("unknown algorithm generated")
+	}
+}
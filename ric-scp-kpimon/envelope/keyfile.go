@@ -0,0 +1,99 @@
+package envelope
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+)
+
+// KeyFile is the key material of a writer and of the writers it trusts, stored as JSON:
+//
+//	{"algorithm": "ed25519", "writer": "kpimon", "private_key": "<base64>", "keys": {"kpimon": "<base64>"}}
+//
+// keys maps a writer id to its HMAC secret (hmac-sha256) or Ed25519 public key (ed25519).
+// private_key, the Ed25519 seed or private key, and writer are only needed to sign.
+type KeyFile struct {
+	Algorithm  string            `json:"algorithm"`
+	Writer     string            `json:"writer,omitempty"`
+	PrivateKey string            `json:"private_key,omitempty"`
+	Keys       map[string]string `json:"keys"`
+}
+
+func LoadKeyFile(path string) (*KeyFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := &KeyFile{}
+	if err = json.Unmarshal(data, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GenerateKeyFile creates fresh key material for writer
+func GenerateKeyFile(algorithm string, writer string) (*KeyFile, error) {
+	keys := &KeyFile{Algorithm: algorithm, Writer: writer, Keys: make(map[string]string)}
+	switch algorithm {
+	case ALG_HMAC_SHA256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		keys.Keys[writer] = base64.StdEncoding.EncodeToString(secret)
+	case ALG_ED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keys.PrivateKey = base64.StdEncoding.EncodeToString(priv.Seed())
+		keys.Keys[writer] = base64.StdEncoding.EncodeToString(pub)
+	default:
+		return nil, errors.New("Unknown signature algorithm: " + algorithm)
+	}
+	return keys, nil
+}
+
+// Save writes the key file readable by its owner only
+func (k *KeyFile) Save(path string) error {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Public returns a copy of the key file without the private key, to hand to consumer xApps.
+// HMAC secrets are shared keys: with hmac-sha256 the copy can still sign.
+func (k *KeyFile) Public() *KeyFile {
+	return &KeyFile{Algorithm: k.Algorithm, Keys: k.Keys}
+}
+
+func (k *KeyFile) key(writer string) ([]byte, error) {
+	encoded, ok := k.Keys[writer]
+	if !ok {
+		return nil, ErrNoSigningKey
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func (k *KeyFile) privateKey() (ed25519.PrivateKey, error) {
+	if k.PrivateKey == "" {
+		return nil, ErrNoSigningKey
+	}
+	key, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, errors.New("Invalid ed25519 private key")
+	}
+}
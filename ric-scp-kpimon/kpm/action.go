@@ -0,0 +1,446 @@
+package kpm
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+// ActionDefinition is an E2SM-KPM-ActionDefinition of any of the formats 1 to 5:
+//
+//	1: measurements of the E2 node or of Cell                          (report style 1)
+//	2: measurements of one UE, UeID                                    (report style 2)
+//	3: measurements per condition, MeasConditions                      (report style 3)
+//	4: per-UE measurements of the UEs matching UeConditions            (report style 4)
+//	5: per-UE measurements of the UEs in UeIDs                         (report style 5)
+//
+// Formats 2, 4 and 5 carry the measurements of a format 1 (MeasInfo, Granularity, Cell, DistBinRanges).
+type ActionDefinition struct {
+	Style          int64                  `json:"style" yaml:"style"`
+	Format         int                    `json:"format" yaml:"format"`
+	MeasInfo       []MeasurementInfo      `json:"meas_info,omitempty" yaml:"meas_info,omitempty"`
+	Granularity    time.Duration          `json:"granularity" yaml:"granularity"` //whole milliseconds
+	Cell           *CGI                   `json:"cell,omitempty" yaml:"cell,omitempty"`
+	DistBinRanges  []DistBinRange         `json:"dist_bin_ranges,omitempty" yaml:"dist_bin_ranges,omitempty"` //KPM v3
+	UeID           *UeID                  `json:"ue_id,omitempty" yaml:"ue_id,omitempty"`
+	MeasConditions []MeasurementCondition `json:"meas_conditions,omitempty" yaml:"meas_conditions,omitempty"`
+	UeConditions   []TestCondition        `json:"ue_conditions,omitempty" yaml:"ue_conditions,omitempty"`
+	UeIDs          []UeID                 `json:"ue_ids,omitempty" yaml:"ue_ids,omitempty"`
+}
+
+// MeasurementCondition is a MeasurementCondItem: a measurement taken on the subset matching Conditions
+type MeasurementCondition struct {
+	Type       MeasurementType     `json:"type" yaml:"type"`
+	Conditions []MatchingCondition `json:"conditions" yaml:"conditions"`
+	BinRange   *BinRangeDefinition `json:"bin_range,omitempty" yaml:"bin_range,omitempty"` //KPM v3
+}
+
+// EncodeEventTrigger returns the E2SM-KPM-EventTriggerDefinition (format 1) reporting every period
+func EncodeEventTrigger(period time.Duration) ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	if err := e.PutChoice(0, 1, true); err != nil {
+		return nil, err
+	}
+	e.PutBool(false)
+	if err := e.PutInteger(int64(period/time.Millisecond), 1, 4294967295, false); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// DecodeEventTrigger returns the reporting period of an E2SM-KPM-EventTriggerDefinition
+func DecodeEventTrigger(buf []byte) (time.Duration, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return 0, err
+	}
+	index, err := d.GetChoice(1, true)
+	if err != nil {
+		return 0, err
+	}
+	if index != 0 {
+		return 0, errors.New("Unknown E2SM-KPM-EventTriggerDefinition format")
+	}
+	formatExtended, err := d.GetBool()
+	if err != nil {
+		return 0, err
+	}
+	period, err := d.GetInteger(1, 4294967295, false)
+	if err != nil {
+		return 0, err
+	}
+	if err = skipExtensions(d, formatExtended); err != nil {
+		return 0, err
+	}
+	return time.Duration(period) * time.Millisecond, skipExtensions(d, extended)
+}
+
+// EncodeActionDefinition returns the E2SM-KPM-ActionDefinition of a for an E2 node of version
+func EncodeActionDefinition(a *ActionDefinition, version Version) ([]byte, error) {
+	if version != VERSION_2 && version != VERSION_3 {
+		return nil, errors.New("No KPM " + version.String() + " action definition encoder")
+	}
+	if a.Format < 1 || a.Format > 5 {
+		return nil, errors.New("Unknown E2SM-KPM-ActionDefinition format " + strconv.Itoa(a.Format))
+	}
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	e.PutUnconstrainedInteger(a.Style)
+	//formats 4 and 5 are extension alternatives of actionDefinition-formats
+	if err := e.PutChoice(a.Format-1, 3, true); err != nil {
+		return nil, err
+	}
+	var err error
+	if a.Format > 3 {
+		err = e.PutOpenType(func(e *aper.Encoder) error { return a.encodeFormat(e, version) })
+	} else {
+		err = a.encodeFormat(e, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func (a *ActionDefinition) encodeFormat(e *aper.Encoder, version Version) error {
+	switch a.Format {
+	case 1:
+		return a.encodeFormat1(e, version)
+	case 2:
+		if a.UeID == nil {
+			return errors.New("E2SM-KPM-ActionDefinition format 2 needs a UE ID")
+		}
+		e.PutBool(false)
+		if err := a.UeID.encode(e); err != nil {
+			return err
+		}
+		return a.encodeFormat1(e, version)
+	case 3:
+		e.PutBool(false)
+		e.PutBool(a.Cell != nil)
+		if err := e.PutSequenceOf(len(a.MeasConditions), 1, MAX_MEASUREMENT_INFO, false); err != nil {
+			return err
+		}
+		for i := range a.MeasConditions {
+			if err := a.MeasConditions[i].encode(e, version); err != nil {
+				return err
+			}
+		}
+		if err := a.putGranularity(e); err != nil {
+			return err
+		}
+		if a.Cell != nil {
+			return a.Cell.encode(e)
+		}
+		return nil
+	case 4:
+		e.PutBool(false)
+		if err := e.PutSequenceOf(len(a.UeConditions), 1, MAX_CONDITION_INFO_PERSUB, false); err != nil {
+			return err
+		}
+		for i := range a.UeConditions {
+			condition := a.UeConditions[i]
+			var ext []func(e *aper.Encoder) error
+			if condition.Or {
+				if version < VERSION_3 {
+					return errors.New("MatchingUeCondPerSubItem logicalOR needs KPM v3")
+				}
+				ext = append(ext, func(e *aper.Encoder) error {
+					putTrue(e)
+					return nil
+				})
+			}
+			e.PutBool(aper.HasExtensions(ext))
+			if err := condition.encode(e, version); err != nil {
+				return err
+			}
+			if err := e.PutExtensions(ext); err != nil {
+				return err
+			}
+		}
+		return a.encodeFormat1(e, version)
+	default:
+		e.PutBool(false)
+		if err := e.PutSequenceOf(len(a.UeIDs), 2, MAX_UEID_PERSUB, false); err != nil {
+			return err
+		}
+		for i := range a.UeIDs {
+			e.PutBool(false)
+			if err := a.UeIDs[i].encode(e); err != nil {
+				return err
+			}
+		}
+		return a.encodeFormat1(e, version)
+	}
+}
+
+func (a *ActionDefinition) encodeFormat1(e *aper.Encoder, version Version) error {
+	var ext []func(e *aper.Encoder) error
+	if len(a.DistBinRanges) > 0 {
+		if version < VERSION_3 {
+			return errors.New("E2SM-KPM-ActionDefinition distMeasBinRangeInfo needs KPM v3")
+		}
+		ext = append(ext, func(e *aper.Encoder) error {
+			if err := e.PutSequenceOf(len(a.DistBinRanges), 1, MAX_MEASUREMENT_INFO, false); err != nil {
+				return err
+			}
+			for i := range a.DistBinRanges {
+				e.PutBool(false)
+				if err := e.PutPrintableString(a.DistBinRanges[i].Name, 1, 150, true); err != nil {
+					return err
+				}
+				if err := a.DistBinRanges[i].Bins.encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	e.PutBool(aper.HasExtensions(ext))
+	e.PutBool(a.Cell != nil)
+	if err := encodeMeasurementInfoList(e, a.MeasInfo, version); err != nil {
+		return err
+	}
+	if err := a.putGranularity(e); err != nil {
+		return err
+	}
+	if a.Cell != nil {
+		if err := a.Cell.encode(e); err != nil {
+			return err
+		}
+	}
+	return e.PutExtensions(ext)
+}
+
+func (a *ActionDefinition) putGranularity(e *aper.Encoder) error {
+	return e.PutInteger(int64(a.Granularity/time.Millisecond), 1, 4294967295, false)
+}
+
+func (m *MeasurementCondition) encode(e *aper.Encoder, version Version) error {
+	var ext []func(e *aper.Encoder) error
+	if m.BinRange != nil {
+		if version < VERSION_3 {
+			return errors.New("MeasurementCondItem bin-range-def needs KPM v3")
+		}
+		ext = append(ext, m.BinRange.encode)
+	}
+	e.PutBool(aper.HasExtensions(ext))
+	if err := m.Type.encode(e); err != nil {
+		return err
+	}
+	if err := encodeMatchingConditions(e, m.Conditions, version); err != nil {
+		return err
+	}
+	return e.PutExtensions(ext)
+}
+
+func (m *MeasurementCondition) decode(d *aper.Decoder, version Version) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = m.Type.decode(d); err != nil {
+		return err
+	}
+	if m.Conditions, err = decodeMatchingConditions(d, version); err != nil {
+		return err
+	}
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(index int, d *aper.Decoder) error {
+		if index != 0 {
+			return nil
+		}
+		m.BinRange = &BinRangeDefinition{}
+		return m.BinRange.decode(d)
+	})
+}
+
+// DecodeActionDefinition reads an E2SM-KPM-ActionDefinition of an E2 node of version
+func DecodeActionDefinition(buf []byte, version Version) (*ActionDefinition, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	a := &ActionDefinition{}
+	if a.Style, err = d.GetUnconstrainedInteger(); err != nil {
+		return nil, err
+	}
+	index, err := d.GetChoice(3, true)
+	if err != nil {
+		return nil, err
+	}
+	if index > 4 {
+		return nil, errors.New("Unknown E2SM-KPM-ActionDefinition format " + strconv.Itoa(index+1))
+	}
+	a.Format = index + 1
+	inner := d
+	if a.Format > 3 {
+		if inner, err = d.GetOpenType(); err != nil {
+			return nil, err
+		}
+	}
+	if err = a.decodeFormat(inner, version); err != nil {
+		return nil, err
+	}
+	return a, skipExtensions(d, extended)
+}
+
+func (a *ActionDefinition) decodeFormat(d *aper.Decoder, version Version) error {
+	if a.Format == 1 {
+		return a.decodeFormat1(d)
+	}
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	switch a.Format {
+	case 2:
+		a.UeID = &UeID{}
+		if err = a.UeID.decode(d); err != nil {
+			return err
+		}
+		if err = a.decodeFormat1(d); err != nil {
+			return err
+		}
+	case 3:
+		hasCell, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_INFO, false, 12)
+		if err != nil {
+			return err
+		}
+		a.MeasConditions = make([]MeasurementCondition, n)
+		for i := range a.MeasConditions {
+			if err = a.MeasConditions[i].decode(d, version); err != nil {
+				return err
+			}
+		}
+		if err = a.getGranularity(d); err != nil {
+			return err
+		}
+		if hasCell {
+			a.Cell = &CGI{}
+			if err = a.Cell.decode(d); err != nil {
+				return err
+			}
+		}
+	case 4:
+		n, err := d.GetSequenceOf(1, MAX_CONDITION_INFO_PERSUB, false, 5)
+		if err != nil {
+			return err
+		}
+		a.UeConditions = make([]TestCondition, n)
+		for i := range a.UeConditions {
+			condition := &a.UeConditions[i]
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			if err = condition.decode(d); err != nil {
+				return err
+			}
+			if itemExtended {
+				if err = d.GetExtensions(func(index int, d *aper.Decoder) (err error) {
+					if index == 0 {
+						condition.Or, err = getTrue(d)
+					}
+					return err
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		if err = a.decodeFormat1(d); err != nil {
+			return err
+		}
+	default:
+		n, err := d.GetSequenceOf(2, MAX_UEID_PERSUB, false, 8)
+		if err != nil {
+			return err
+		}
+		a.UeIDs = make([]UeID, n)
+		for i := range a.UeIDs {
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			if err = a.UeIDs[i].decode(d); err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+		if err = a.decodeFormat1(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (a *ActionDefinition) decodeFormat1(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	hasCell, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if a.MeasInfo, err = decodeMeasurementInfoList(d); err != nil {
+		return err
+	}
+	if err = a.getGranularity(d); err != nil {
+		return err
+	}
+	if hasCell {
+		a.Cell = &CGI{}
+		if err = a.Cell.decode(d); err != nil {
+			return err
+		}
+	}
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(index int, d *aper.Decoder) error {
+		if index != 0 {
+			return nil
+		}
+		n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_INFO, false, 16)
+		if err != nil {
+			return err
+		}
+		a.DistBinRanges = make([]DistBinRange, n)
+		for i := range a.DistBinRanges {
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			if a.DistBinRanges[i].Name, err = d.GetPrintableString(1, 150, true); err != nil {
+				return err
+			}
+			if err = a.DistBinRanges[i].Bins.decode(d); err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *ActionDefinition) getGranularity(d *aper.Decoder) error {
+	granularity, err := d.GetInteger(1, 4294967295, false)
+	a.Granularity = time.Duration(granularity) * time.Millisecond
+	return err
+}
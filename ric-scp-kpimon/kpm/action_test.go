@@ -0,0 +1,141 @@
+package kpm
+
+import (
+	"testing"
+	"time"
+)
+
+var testMeasInfo = []MeasurementInfo{
+	{Type: MeasurementType{Name: "DRB.UEThpDl"}, Labels: []Label{{NoLabel: true}}},
+	{Type: MeasurementType{ID: 3}, Labels: []Label{
+		{PlmnID: &testPlmn, SliceID: &SNSSAI{SST: 1, SD: &[3]byte{0, 0, 1}}},
+		{FiveQI: int64p(9), Sum: true},
+		{StartEndInd: START_END_END, Min: true, DistBinX: int64p(2), ARPMax: int64p(15)},
+	}},
+}
+
+var testConditions = []TestCondition{
+	{Type: TEST_RSRP, Expression: EXPR_GREATER_THAN, Value: &TestValue{Kind: VALUE_INT, Int: -100}},
+	{Type: TEST_IS_STAT, Expression: EXPR_EQUAL, Value: &TestValue{Kind: VALUE_BOOL, Bool: true}},
+	{Type: TEST_CQI, Expression: EXPR_LESS_THAN, Value: &TestValue{Kind: VALUE_REAL, Real: 7.5}},
+	{Type: TEST_SNSSAI, Expression: EXPR_CONTAINS, Value: &TestValue{Kind: VALUE_OCTETS, Bytes: []byte{1, 0, 0, 1}}},
+	{Type: TEST_GBR, Value: &TestValue{Kind: VALUE_BITS, Bytes: []byte{0xa0}, Bits: 3}},
+	{Type: TEST_AMBR, Expression: EXPR_PRESENT},
+}
+
+var actionDefinitions = []struct {
+	name    string
+	version Version
+	action  ActionDefinition
+}{
+	{"format 1", VERSION_2, ActionDefinition{Style: 1, Format: 1, MeasInfo: testMeasInfo, Granularity: time.Second, Cell: testCell}},
+	{"format 1 without cell", VERSION_3, ActionDefinition{Style: 1, Format: 1, MeasInfo: testMeasInfo[:1], Granularity: 100 * time.Millisecond}},
+	{"format 1 of v3", VERSION_3, ActionDefinition{
+		Style: 1, Format: 1, Granularity: time.Second, Cell: &CGI{PlmnID: testPlmn, CellID: 0x1234567, EUTRA: true},
+		MeasInfo:      []MeasurementInfo{{Type: MeasurementType{Name: "L1M.RS-SINR.Bin"}, Labels: []Label{{SSBIndex: int64p(4), MIMOModeIndex: int64p(2), DistBinX: int64p(1)}}}},
+		DistBinRanges: []DistBinRange{{Name: "L1M.RS-SINR.Bin", Bins: BinRangeDefinition{X: []BinRange{{Index: 1, Start: -10, End: 0}, {Index: 2, Start: 0, End: 12.5, Real: true}}}}},
+	}},
+	{"format 2", VERSION_2, ActionDefinition{Style: 2, Format: 2, UeID: &gnbUe, MeasInfo: testMeasInfo, Granularity: time.Second}},
+	{"format 3", VERSION_2, ActionDefinition{Style: 3, Format: 3, Granularity: time.Second, Cell: testCell, MeasConditions: []MeasurementCondition{
+		{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: []MatchingCondition{{Label: &Label{NoLabel: true}}, {Test: &testConditions[0]}}},
+		{Type: MeasurementType{ID: 2}, Conditions: []MatchingCondition{{Test: &testConditions[2]}}},
+	}}},
+	{"format 3 of v3", VERSION_3, ActionDefinition{Style: 3, Format: 3, Granularity: time.Second, MeasConditions: []MeasurementCondition{
+		{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: []MatchingCondition{{Test: &testConditions[0], Or: true}, {Label: &Label{FiveQI: int64p(9)}}},
+			BinRange: &BinRangeDefinition{X: []BinRange{{Index: 1, Start: 0, End: 100}}}},
+	}}},
+	{"format 4", VERSION_2, ActionDefinition{Style: 4, Format: 4, UeConditions: testConditions, MeasInfo: testMeasInfo[:1], Granularity: time.Second}},
+	{"format 4 of v3", VERSION_3, ActionDefinition{Style: 4, Format: 4, MeasInfo: testMeasInfo[:1], Granularity: time.Second,
+		UeConditions: []TestCondition{{Type: TEST_FIVE_QI, Expression: EXPR_EQUAL, Value: &TestValue{Kind: VALUE_INT, Int: 9}, Or: true}, testConditions[0]}}},
+	{"format 5", VERSION_2, ActionDefinition{Style: 5, Format: 5, UeIDs: []UeID{gnbUe, duUe}, MeasInfo: testMeasInfo, Granularity: time.Second, Cell: testCell}},
+}
+
+func TestActionDefinition(t *testing.T) {
+	for _, test := range actionDefinitions {
+		payload, err := EncodeActionDefinition(&test.action, test.version)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		action, err := DecodeActionDefinition(payload, test.version)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, action, &test.action)
+		for i := 1; i < len(payload); i++ {
+			if _, err := DecodeActionDefinition(payload[:i], test.version); err == nil {
+				t.Errorf("%s: truncated to %d octets decoded", test.name, i)
+			}
+		}
+	}
+
+	//style 1 reporting measurement ID 1 without label every second: the measurement is written as
+	//reported for noLabel
+	golden := goldenBytes(t, "000101000000200000012000004003e7")
+	action := ActionDefinition{Style: 1, Format: 1, MeasInfo: []MeasurementInfo{{Type: MeasurementType{ID: 1}}}, Granularity: time.Second}
+	payload, err := EncodeActionDefinition(&action, VERSION_2)
+	checkEncoded(t, "action definition", payload, err, golden)
+	decoded, err := DecodeActionDefinition(golden, VERSION_2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	action.MeasInfo[0].Labels = []Label{{NoLabel: true}}
+	checkDecoded(t, "action definition", decoded, &action)
+}
+
+func TestActionDefinitionErrors(t *testing.T) {
+	format1 := actionDefinitions[0].action
+	for _, test := range []struct {
+		name    string
+		version Version
+		action  ActionDefinition
+	}{
+		{"v1", VERSION_1, format1},
+		{"format 0", VERSION_2, ActionDefinition{Style: 1, MeasInfo: testMeasInfo, Granularity: time.Second}},
+		{"format 6", VERSION_2, ActionDefinition{Style: 1, Format: 6, MeasInfo: testMeasInfo, Granularity: time.Second}},
+		{"no measurement", VERSION_2, ActionDefinition{Style: 1, Format: 1, Granularity: time.Second}},
+		{"no granularity period", VERSION_2, ActionDefinition{Style: 1, Format: 1, MeasInfo: testMeasInfo}},
+		{"v3 label of v2", VERSION_2, actionDefinitions[2].action},
+		{"v3 bin ranges of v2", VERSION_2, ActionDefinition{Style: 1, Format: 1, MeasInfo: testMeasInfo, Granularity: time.Second, DistBinRanges: actionDefinitions[2].action.DistBinRanges}},
+		{"format 2 without UE", VERSION_2, ActionDefinition{Style: 2, Format: 2, MeasInfo: testMeasInfo, Granularity: time.Second}},
+		{"v3 matching condition of v2", VERSION_2, actionDefinitions[5].action},
+		{"empty matching condition", VERSION_2, ActionDefinition{Style: 3, Format: 3, Granularity: time.Second, MeasConditions: []MeasurementCondition{{Type: MeasurementType{ID: 1}, Conditions: []MatchingCondition{{}}}}}},
+		{"v3 UE condition of v2", VERSION_2, actionDefinitions[7].action},
+		{"unknown test", VERSION_2, ActionDefinition{Style: 4, Format: 4, MeasInfo: testMeasInfo, Granularity: time.Second, UeConditions: []TestCondition{{Type: "snr"}}}},
+		{"format 5 of one UE", VERSION_2, ActionDefinition{Style: 5, Format: 5, UeIDs: []UeID{gnbUe}, MeasInfo: testMeasInfo, Granularity: time.Second}},
+		{"unknown UE ID type", VERSION_2, ActionDefinition{Style: 2, Format: 2, UeID: &UeID{Type: "nr"}, MeasInfo: testMeasInfo, Granularity: time.Second}},
+	} {
+		if payload, err := EncodeActionDefinition(&test.action, test.version); err == nil {
+			t.Errorf("%s: encoded to %x", test.name, payload)
+		}
+	}
+}
+
+func TestEventTrigger(t *testing.T) {
+	//extension bits and the period of 1000 ms, 999 above the lower bound, in 2 octets
+	golden := goldenBytes(t, "0803e7")
+	payload, err := EncodeEventTrigger(time.Second)
+	checkEncoded(t, "event trigger", payload, err, golden)
+	if period, err := DecodeEventTrigger(golden); period != time.Second || err != nil {
+		t.Errorf("event trigger decoded to %v, %v", period, err)
+	}
+	for _, period := range []time.Duration{time.Millisecond, 10 * time.Millisecond, time.Minute, 4294967295 * time.Millisecond} {
+		payload, err := EncodeEventTrigger(period)
+		if err != nil {
+			t.Errorf("%v: %v", period, err)
+			continue
+		}
+		if decoded, err := DecodeEventTrigger(payload); decoded != period || err != nil {
+			t.Errorf("%v decoded to %v, %v", period, decoded, err)
+		}
+	}
+	if payload, err := EncodeEventTrigger(0); err == nil {
+		t.Errorf("period 0 encoded to %x", payload)
+	}
+	for _, payload := range [][]byte{nil, {0x08, 0x03}, {0x40}} {
+		if period, err := DecodeEventTrigger(payload); err == nil {
+			t.Errorf("event trigger %x decoded to %v", payload, period)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package kpm
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+// IEs of the E2SM common definitions (O-RAN.WG3.E2SM) used by KPM v2 and v3
+
+// PlmnID is a PLMN identity: MCC and MNC digits in BCD, filler 0xF for a 2-digit MNC (TS 38.413)
+type PlmnID [3]byte
+
+func (p PlmnID) String() string {
+	return hex.EncodeToString(p[:])
+}
+
+func (p *PlmnID) encode(e *aper.Encoder) error {
+	return e.PutOctetString(p[:], 3, 3, false)
+}
+
+func (p *PlmnID) decode(d *aper.Decoder) error {
+	v, err := d.GetOctetString(3, 3, false)
+	if err != nil {
+		return err
+	}
+	copy(p[:], v)
+	return nil
+}
+
+// CGI is a cell global identity, NR unless EUTRA is set
+type CGI struct {
+	PlmnID PlmnID `json:"plmn_id"`
+	CellID uint64 `json:"cell_id"` //NR cell identity (36 bits) or E-UTRA cell identity (28 bits)
+	EUTRA  bool   `json:"eutra,omitempty"`
+}
+
+func (c *CGI) encode(e *aper.Encoder) error {
+	index, size := 0, 36
+	if c.EUTRA {
+		index, size = 1, 28
+	}
+	if err := e.PutChoice(index, 2, true); err != nil {
+		return err
+	}
+	e.PutBool(false)
+	if err := c.PlmnID.encode(e); err != nil {
+		return err
+	}
+	return putBitsValue(e, c.CellID, size, int64(size), int64(size), false)
+}
+
+func (c *CGI) decode(d *aper.Decoder) error {
+	index, err := d.GetChoice(2, true)
+	if err != nil {
+		return err
+	}
+	if index >= 2 {
+		return errors.New("Unknown CGI alternative")
+	}
+	size := int64(36)
+	c.EUTRA = index == 1
+	if c.EUTRA {
+		size = 28
+	}
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = c.PlmnID.decode(d); err != nil {
+		return err
+	}
+	if c.CellID, _, err = getBitsValue(d, size, size, false); err != nil {
+		return err
+	}
+	return skipExtensions(d, extended)
+}
+
+// SNSSAI is a network slice: slice/service type and optional slice differentiator
+type SNSSAI struct {
+	SST byte     `json:"sst"`
+	SD  *[3]byte `json:"sd,omitempty"`
+}
+
+func (s *SNSSAI) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(s.SD != nil)
+	if err := e.PutOctetString([]byte{s.SST}, 1, 1, false); err != nil {
+		return err
+	}
+	if s.SD != nil {
+		return e.PutOctetString(s.SD[:], 3, 3, false)
+	}
+	return nil
+}
+
+func (s *SNSSAI) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	hasSD, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	sst, err := d.GetOctetString(1, 1, false)
+	if err != nil {
+		return err
+	}
+	s.SST = sst[0]
+	if hasSD {
+		sd, err := d.GetOctetString(3, 3, false)
+		if err != nil {
+			return err
+		}
+		s.SD = &[3]byte{}
+		copy(s.SD[:], sd)
+	}
+	return skipExtensions(d, extended)
+}
+
+// GUAMI is a globally unique AMF identifier
+type GUAMI struct {
+	PlmnID      PlmnID `json:"plmn_id"`
+	AMFRegionID uint8  `json:"amf_region_id"`
+	AMFSetID    uint16 `json:"amf_set_id"`  //10 bits
+	AMFPointer  uint8  `json:"amf_pointer"` //6 bits
+}
+
+func (g *GUAMI) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := g.PlmnID.encode(e); err != nil {
+		return err
+	}
+	if err := putBitsValue(e, uint64(g.AMFRegionID), 8, 8, 8, false); err != nil {
+		return err
+	}
+	if err := putBitsValue(e, uint64(g.AMFSetID), 10, 10, 10, false); err != nil {
+		return err
+	}
+	return putBitsValue(e, uint64(g.AMFPointer), 6, 6, 6, false)
+}
+
+func (g *GUAMI) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = g.PlmnID.decode(d); err != nil {
+		return err
+	}
+	region, _, err := getBitsValue(d, 8, 8, false)
+	if err != nil {
+		return err
+	}
+	set, _, err := getBitsValue(d, 10, 10, false)
+	if err != nil {
+		return err
+	}
+	pointer, _, err := getBitsValue(d, 6, 6, false)
+	if err != nil {
+		return err
+	}
+	g.AMFRegionID, g.AMFSetID, g.AMFPointer = uint8(region), uint16(set), uint8(pointer)
+	return skipExtensions(d, extended)
+}
+
+// GUMMEI is a globally unique MME identifier
+type GUMMEI struct {
+	PlmnID     PlmnID `json:"plmn_id"`
+	MMEGroupID uint16 `json:"mme_group_id"`
+	MMECode    uint8  `json:"mme_code"`
+}
+
+func (g *GUMMEI) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := g.PlmnID.encode(e); err != nil {
+		return err
+	}
+	if err := e.PutOctetString([]byte{byte(g.MMEGroupID >> 8), byte(g.MMEGroupID)}, 2, 2, false); err != nil {
+		return err
+	}
+	return e.PutOctetString([]byte{g.MMECode}, 1, 1, false)
+}
+
+func (g *GUMMEI) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = g.PlmnID.decode(d); err != nil {
+		return err
+	}
+	group, err := d.GetOctetString(2, 2, false)
+	if err != nil {
+		return err
+	}
+	code, err := d.GetOctetString(1, 1, false)
+	if err != nil {
+		return err
+	}
+	g.MMEGroupID, g.MMECode = uint16(group[0])<<8|uint16(group[1]), code[0]
+	return skipExtensions(d, extended)
+}
+
+const (
+	NODE_GNB    = "gnb"
+	NODE_NG_ENB = "ng-enb"
+	NODE_ENB    = "enb"
+
+	ENB_MACRO       = "macro"
+	ENB_HOME        = "home"
+	ENB_SHORT_MACRO = "short-macro"
+	ENB_LONG_MACRO  = "long-macro"
+)
+
+// GlobalNodeID is a global gNB, ng-eNB or eNB identifier
+type GlobalNodeID struct {
+	Type   string `json:"type"` //gnb, ng-enb or enb
+	PlmnID PlmnID `json:"plmn_id"`
+	NodeID uint64 `json:"node_id"`
+	Bits   int    `json:"bits"`           //length of the node ID: 22 to 32 for a gNB, 18 to 28 for an (ng-)eNB
+	Kind   string `json:"kind,omitempty"` //(ng-)eNB ID kind: macro, home, short-macro or long-macro
+}
+
+var enbKinds = map[string]int{ENB_MACRO: 20, ENB_HOME: 28, ENB_SHORT_MACRO: 18, ENB_LONG_MACRO: 21}
+
+func (n *GlobalNodeID) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	if err := n.PlmnID.encode(e); err != nil {
+		return err
+	}
+	switch n.Type {
+	case NODE_GNB:
+		if err := e.PutChoice(0, 1, true); err != nil {
+			return err
+		}
+		return putBitsValue(e, n.NodeID, n.Bits, 22, 32, false)
+	case NODE_NG_ENB, NODE_ENB:
+		bits, ok := enbKinds[n.Kind]
+		if !ok {
+			return errors.New("Unknown eNB ID kind: " + n.Kind)
+		}
+		var index int
+		switch {
+		case n.Type == NODE_NG_ENB:
+			index = map[string]int{ENB_MACRO: 0, ENB_SHORT_MACRO: 1, ENB_LONG_MACRO: 2}[n.Kind]
+			if n.Kind == ENB_HOME {
+				return errors.New("ng-eNB ID cannot be a home eNB ID")
+			}
+			if err := e.PutChoice(index, 3, true); err != nil {
+				return err
+			}
+		case n.Kind == ENB_MACRO || n.Kind == ENB_HOME:
+			index = map[string]int{ENB_MACRO: 0, ENB_HOME: 1}[n.Kind]
+			if err := e.PutChoice(index, 2, true); err != nil {
+				return err
+			}
+		default:
+			//short and long macro eNB IDs are extension alternatives of ENB-ID
+			index = map[string]int{ENB_SHORT_MACRO: 2, ENB_LONG_MACRO: 3}[n.Kind]
+			if err := e.PutChoice(index, 2, true); err != nil {
+				return err
+			}
+			return e.PutOpenType(func(e *aper.Encoder) error {
+				return putBitsValue(e, n.NodeID, bits, int64(bits), int64(bits), false)
+			})
+		}
+		return putBitsValue(e, n.NodeID, bits, int64(bits), int64(bits), false)
+	}
+	return errors.New("Unknown global node ID type: " + n.Type)
+}
+
+func (n *GlobalNodeID) decode(d *aper.Decoder, nodeType string) error {
+	n.Type = nodeType
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = n.PlmnID.decode(d); err != nil {
+		return err
+	}
+	switch nodeType {
+	case NODE_GNB:
+		index, err := d.GetChoice(1, true)
+		if err != nil {
+			return err
+		}
+		if index >= 1 {
+			return errors.New("Unknown GNB-ID alternative")
+		}
+		if n.NodeID, n.Bits, err = getBitsValue(d, 22, 32, false); err != nil {
+			return err
+		}
+	case NODE_NG_ENB:
+		index, err := d.GetChoice(3, true)
+		if err != nil {
+			return err
+		}
+		if index >= 3 {
+			return errors.New("Unknown NgENB-ID alternative")
+		}
+		n.Kind = []string{ENB_MACRO, ENB_SHORT_MACRO, ENB_LONG_MACRO}[index]
+		bits := int64(enbKinds[n.Kind])
+		if n.NodeID, n.Bits, err = getBitsValue(d, bits, bits, false); err != nil {
+			return err
+		}
+	default:
+		index, err := d.GetChoice(2, true)
+		if err != nil {
+			return err
+		}
+		if index >= 4 {
+			return errors.New("Unknown ENB-ID alternative")
+		}
+		n.Kind = []string{ENB_MACRO, ENB_HOME, ENB_SHORT_MACRO, ENB_LONG_MACRO}[index]
+		bits := int64(enbKinds[n.Kind])
+		inner := d
+		if index >= 2 {
+			if inner, err = d.GetOpenType(); err != nil {
+				return err
+			}
+		}
+		if n.NodeID, n.Bits, err = getBitsValue(inner, bits, bits, false); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+// encodeRanNode writes a GlobalNGRANNodeID, a CHOICE of a global gNB or ng-eNB ID
+func (n *GlobalNodeID) encodeRanNode(e *aper.Encoder) error {
+	index := 0
+	if n.Type == NODE_NG_ENB {
+		index = 1
+	}
+	if err := e.PutChoice(index, 2, true); err != nil {
+		return err
+	}
+	return n.encode(e)
+}
+
+func decodeRanNode(d *aper.Decoder) (*GlobalNodeID, error) {
+	index, err := d.GetChoice(2, true)
+	if err != nil {
+		return nil, err
+	}
+	if index >= 2 {
+		return nil, errors.New("Unknown GlobalNGRANNodeID alternative")
+	}
+	n := &GlobalNodeID{}
+	return n, n.decode(d, []string{NODE_GNB, NODE_NG_ENB}[index])
+}
+
+// putBitsValue writes the n low-order bits of v as a BIT STRING
+func putBitsValue(e *aper.Encoder, v uint64, n int, lb int64, ub int64, ext bool) error {
+	if n < 0 || n > 64 || (n < 64 && v>>uint(n) != 0) {
+		return aper.ErrRange
+	}
+	buf := make([]byte, (n+7)/8)
+	left := v << uint(64-n)
+	for i := range buf {
+		buf[i] = byte(left >> uint(56-8*i))
+	}
+	return e.PutBitString(buf, n, lb, ub, ext)
+}
+
+// getBitsValue reads a BIT STRING of up to 64 bits as the value of its bits and their number
+func getBitsValue(d *aper.Decoder, lb int64, ub int64, ext bool) (uint64, int, error) {
+	buf, n, err := d.GetBitString(lb, ub, ext)
+	if err != nil {
+		return 0, 0, err
+	}
+	if n > 64 {
+		return 0, 0, aper.ErrTooLarge
+	}
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(buf[i/8]>>(7-uint(i%8))&1)
+	}
+	return v, n, nil
+}
+
+// skipExtensions reads and drops the extension additions of a SEQUENCE whose extension bit was extended
+func skipExtensions(d *aper.Decoder, extended bool) error {
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(int, *aper.Decoder) error { return nil })
+}
+
+// skipOpenType reads and drops the value of an unknown extension alternative of a CHOICE
+func skipOpenType(d *aper.Decoder) error {
+	_, err := d.GetOpenType()
+	return err
+}
@@ -0,0 +1,572 @@
+package kpm
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+const ntpUnixOffset = 2208988800 //seconds from 1900 to 1970
+
+// IndicationHeader is an E2SM-KPM-IndicationHeader (format 1)
+type IndicationHeader struct {
+	CollectStartTime  [4]byte `json:"collect_start_time"`
+	FileFormatVersion string  `json:"file_format_version,omitempty"`
+	SenderName        string  `json:"sender_name,omitempty"`
+	SenderType        string  `json:"sender_type,omitempty"`
+	VendorName        string  `json:"vendor_name,omitempty"`
+}
+
+// CollectStart returns the collection start time. The 4 octets are the seconds of an NTP timestamp,
+// but some E2 node simulators send UNIX seconds, told apart by being before 1970 as NTP seconds.
+func (h *IndicationHeader) CollectStart() time.Time {
+	seconds := int64(binary.BigEndian.Uint32(h.CollectStartTime[:]))
+	if seconds >= ntpUnixOffset {
+		seconds -= ntpUnixOffset
+	}
+	return time.Unix(seconds, 0)
+}
+
+// SetCollectStart sets the collection start time as NTP seconds
+func (h *IndicationHeader) SetCollectStart(t time.Time) {
+	binary.BigEndian.PutUint32(h.CollectStartTime[:], uint32(t.Unix()+ntpUnixOffset))
+}
+
+func EncodeIndicationHeader(h *IndicationHeader) ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	if err := e.PutChoice(0, 1, true); err != nil {
+		return nil, err
+	}
+	optionals := []struct {
+		value string
+		ub    int64
+	}{{h.FileFormatVersion, 15}, {h.SenderName, 400}, {h.SenderType, 8}, {h.VendorName, 32}}
+	e.PutBool(false)
+	for _, optional := range optionals {
+		e.PutBool(optional.value != "")
+	}
+	if err := e.PutOctetString(h.CollectStartTime[:], 4, 4, false); err != nil {
+		return nil, err
+	}
+	for _, optional := range optionals {
+		if optional.value == "" {
+			continue
+		}
+		if err := e.PutPrintableString(optional.value, 0, optional.ub, true); err != nil {
+			return nil, err
+		}
+	}
+	return e.Bytes(), nil
+}
+
+func DecodeIndicationHeader(buf []byte) (*IndicationHeader, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	index, err := d.GetChoice(1, true)
+	if err != nil {
+		return nil, err
+	}
+	if index != 0 {
+		return nil, errors.New("Unknown E2SM-KPM-IndicationHeader format")
+	}
+	formatExtended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	present, err := getOptionals(d, 4)
+	if err != nil {
+		return nil, err
+	}
+	h := &IndicationHeader{}
+	start, err := d.GetOctetString(4, 4, false)
+	if err != nil {
+		return nil, err
+	}
+	copy(h.CollectStartTime[:], start)
+	optionals := []struct {
+		value *string
+		ub    int64
+	}{{&h.FileFormatVersion, 15}, {&h.SenderName, 400}, {&h.SenderType, 8}, {&h.VendorName, 32}}
+	for i, optional := range optionals {
+		if !present[i] {
+			continue
+		}
+		if *optional.value, err = d.GetPrintableString(0, optional.ub, true); err != nil {
+			return nil, err
+		}
+	}
+	if err = skipExtensions(d, formatExtended); err != nil {
+		return nil, err
+	}
+	return h, skipExtensions(d, extended)
+}
+
+const (
+	RECORD_INTEGER  = "integer"
+	RECORD_REAL     = "real"
+	RECORD_NO_VALUE = "no-value"
+)
+
+// RecordItem is a MeasurementRecordItem
+type RecordItem struct {
+	Kind    string  `json:"kind"` //integer, real or no-value
+	Integer uint32  `json:"integer,omitempty"`
+	Real    float64 `json:"real,omitempty"`
+}
+
+// MeasurementData is a MeasurementDataItem: the values of one granularity period
+type MeasurementData struct {
+	Record     []RecordItem `json:"record"`
+	Incomplete bool         `json:"incomplete,omitempty"`
+}
+
+// MeasurementCondUeID is a MeasurementCondUEidItem of an indication message format 2
+type MeasurementCondUeID struct {
+	Type       MeasurementType     `json:"type"`
+	Conditions []MatchingCondition `json:"conditions"`
+	UeIDs      []UeID              `json:"ue_ids,omitempty"`        //UEs matching the conditions
+	UeIDsPerGP [][]UeID            `json:"ue_ids_per_gp,omitempty"` //UEs matching the conditions per granularity period (KPM v3)
+}
+
+// UeMeasurementReport is a UEMeasurementReportItem of an indication message format 3
+type UeMeasurementReport struct {
+	UeID   UeID               `json:"ue_id"`
+	Report *IndicationMessage `json:"report"` //format 1
+}
+
+// IndicationMessage is an E2SM-KPM-IndicationMessage of any of the formats 1 to 3
+type IndicationMessage struct {
+	Format        int                   `json:"format"`
+	MeasData      []MeasurementData     `json:"meas_data,omitempty"`        //formats 1 and 2, one item per granularity period
+	MeasInfo      []MeasurementInfo     `json:"meas_info,omitempty"`        //format 1, absent when the action definition gives it
+	MeasCondUeIDs []MeasurementCondUeID `json:"meas_cond_ue_ids,omitempty"` //format 2
+	Granularity   time.Duration         `json:"granularity,omitempty"`      //formats 1 and 2, 0 when absent
+	UeReports     []UeMeasurementReport `json:"ue_reports,omitempty"`       //format 3
+}
+
+func EncodeIndicationMessage(m *IndicationMessage, version Version) ([]byte, error) {
+	if version != VERSION_2 && version != VERSION_3 {
+		return nil, errors.New("No KPM " + version.String() + " indication message encoder")
+	}
+	if m.Format < 1 || m.Format > 3 {
+		return nil, errors.New("Unknown E2SM-KPM-IndicationMessage format " + strconv.Itoa(m.Format))
+	}
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	if err := e.PutChoice(m.Format-1, 2, true); err != nil {
+		return nil, err
+	}
+	var err error
+	if m.Format == 3 {
+		err = e.PutOpenType(func(e *aper.Encoder) error { return m.encodeFormat(e, version) })
+	} else {
+		err = m.encodeFormat(e, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func (m *IndicationMessage) encodeFormat(e *aper.Encoder, version Version) error {
+	switch m.Format {
+	case 1:
+		e.PutBool(false)
+		e.PutBool(len(m.MeasInfo) > 0)
+		e.PutBool(m.Granularity > 0)
+		if err := encodeMeasurementData(e, m.MeasData); err != nil {
+			return err
+		}
+		if len(m.MeasInfo) > 0 {
+			if err := encodeMeasurementInfoList(e, m.MeasInfo, version); err != nil {
+				return err
+			}
+		}
+	case 2:
+		e.PutBool(false)
+		e.PutBool(m.Granularity > 0)
+		if err := encodeMeasurementData(e, m.MeasData); err != nil {
+			return err
+		}
+		if err := e.PutSequenceOf(len(m.MeasCondUeIDs), 1, MAX_MEASUREMENT_INFO, false); err != nil {
+			return err
+		}
+		for i := range m.MeasCondUeIDs {
+			if err := m.MeasCondUeIDs[i].encode(e, version); err != nil {
+				return err
+			}
+		}
+	default:
+		e.PutBool(false)
+		if err := e.PutSequenceOf(len(m.UeReports), 1, MAX_UE_MEAS_REPORT, false); err != nil {
+			return err
+		}
+		for _, report := range m.UeReports {
+			if report.Report == nil || report.Report.Format != 1 {
+				return errors.New("UEMeasurementReportItem needs a format 1 report")
+			}
+			e.PutBool(false)
+			if err := report.UeID.encode(e); err != nil {
+				return err
+			}
+			if err := report.Report.encodeFormat(e, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if m.Granularity > 0 {
+		return e.PutInteger(int64(m.Granularity/time.Millisecond), 1, 4294967295, false)
+	}
+	return nil
+}
+
+func encodeMeasurementData(e *aper.Encoder, data []MeasurementData) error {
+	if err := e.PutSequenceOf(len(data), 1, MAX_MEASUREMENT_RECORD, false); err != nil {
+		return err
+	}
+	for _, item := range data {
+		e.PutBool(false)
+		e.PutBool(item.Incomplete)
+		if err := e.PutSequenceOf(len(item.Record), 1, MAX_MEASUREMENT_VALUE, false); err != nil {
+			return err
+		}
+		for _, record := range item.Record {
+			switch record.Kind {
+			case RECORD_INTEGER:
+				if err := e.PutChoice(0, 3, true); err != nil {
+					return err
+				}
+				if err := e.PutInteger(int64(record.Integer), 0, 4294967295, false); err != nil {
+					return err
+				}
+			case RECORD_REAL:
+				if err := e.PutChoice(1, 3, true); err != nil {
+					return err
+				}
+				e.PutReal(record.Real)
+			case RECORD_NO_VALUE:
+				if err := e.PutChoice(2, 3, true); err != nil {
+					return err
+				}
+			default:
+				return errors.New("Unknown MeasurementRecordItem kind: " + record.Kind)
+			}
+		}
+		if item.Incomplete {
+			putTrue(e)
+		}
+	}
+	return nil
+}
+
+func (m *MeasurementCondUeID) encode(e *aper.Encoder, version Version) error {
+	var ext []func(e *aper.Encoder) error
+	if len(m.UeIDsPerGP) > 0 {
+		if version < VERSION_3 {
+			return errors.New("MeasurementCondUEidItem matchingUEidPerGP needs KPM v3")
+		}
+		ext = append(ext, func(e *aper.Encoder) error {
+			if err := e.PutSequenceOf(len(m.UeIDsPerGP), 1, MAX_MEASUREMENT_RECORD, false); err != nil {
+				return err
+			}
+			for _, matched := range m.UeIDsPerGP {
+				e.PutBool(false)
+				if len(matched) == 0 {
+					if err := e.PutChoice(0, 2, true); err != nil {
+						return err
+					}
+					putTrue(e)
+					continue
+				}
+				if err := e.PutChoice(1, 2, true); err != nil {
+					return err
+				}
+				if err := encodeUeIDList(e, matched); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	e.PutBool(aper.HasExtensions(ext))
+	e.PutBool(len(m.UeIDs) > 0)
+	if err := m.Type.encode(e); err != nil {
+		return err
+	}
+	if err := encodeMatchingConditions(e, m.Conditions, version); err != nil {
+		return err
+	}
+	if len(m.UeIDs) > 0 {
+		if err := encodeUeIDList(e, m.UeIDs); err != nil {
+			return err
+		}
+	}
+	return e.PutExtensions(ext)
+}
+
+func (m *MeasurementCondUeID) decode(d *aper.Decoder, version Version) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	hasUeIDs, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if err = m.Type.decode(d); err != nil {
+		return err
+	}
+	if m.Conditions, err = decodeMatchingConditions(d, version); err != nil {
+		return err
+	}
+	if hasUeIDs {
+		if m.UeIDs, err = decodeUeIDList(d); err != nil {
+			return err
+		}
+	}
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(index int, d *aper.Decoder) error {
+		if index != 0 {
+			return nil
+		}
+		n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_RECORD, false, 2)
+		if err != nil {
+			return err
+		}
+		m.UeIDsPerGP = make([][]UeID, n)
+		for i := range m.UeIDsPerGP {
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			index, err := d.GetChoice(2, true)
+			if err != nil {
+				return err
+			}
+			switch index {
+			case 0:
+				_, err = getTrue(d)
+			case 1:
+				m.UeIDsPerGP[i], err = decodeUeIDList(d)
+			default:
+				err = skipOpenType(d)
+			}
+			if err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// encodeUeIDList writes a MatchingUEidList (or MatchingUEidList-PerGP): UEID items, each an extensible SEQUENCE
+func encodeUeIDList(e *aper.Encoder, ueIDs []UeID) error {
+	if err := e.PutSequenceOf(len(ueIDs), 1, MAX_UEID, false); err != nil {
+		return err
+	}
+	for i := range ueIDs {
+		e.PutBool(false)
+		if err := ueIDs[i].encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeUeIDList(d *aper.Decoder) ([]UeID, error) {
+	n, err := d.GetSequenceOf(1, MAX_UEID, false, 8)
+	if err != nil {
+		return nil, err
+	}
+	ueIDs := make([]UeID, n)
+	for i := range ueIDs {
+		extended, err := d.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		if err = ueIDs[i].decode(d); err != nil {
+			return nil, err
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return nil, err
+		}
+	}
+	return ueIDs, nil
+}
+
+// DecodeIndicationMessage reads an E2SM-KPM-IndicationMessage from an E2 node of version
+func DecodeIndicationMessage(buf []byte, version Version) (*IndicationMessage, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	index, err := d.GetChoice(2, true)
+	if err != nil {
+		return nil, err
+	}
+	if index > 2 {
+		return nil, errors.New("Unknown E2SM-KPM-IndicationMessage format " + strconv.Itoa(index+1))
+	}
+	m := &IndicationMessage{Format: index + 1}
+	inner := d
+	if m.Format == 3 {
+		if inner, err = d.GetOpenType(); err != nil {
+			return nil, err
+		}
+	}
+	if err = m.decodeFormat(inner, version); err != nil {
+		return nil, err
+	}
+	return m, skipExtensions(d, extended)
+}
+
+func (m *IndicationMessage) decodeFormat(d *aper.Decoder, version Version) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	switch m.Format {
+	case 1:
+		present, err := getOptionals(d, 2)
+		if err != nil {
+			return err
+		}
+		if m.MeasData, err = decodeMeasurementData(d); err != nil {
+			return err
+		}
+		if present[0] {
+			if m.MeasInfo, err = decodeMeasurementInfoList(d); err != nil {
+				return err
+			}
+		}
+		if present[1] {
+			if err = m.getGranularity(d); err != nil {
+				return err
+			}
+		}
+	case 2:
+		hasGranularity, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if m.MeasData, err = decodeMeasurementData(d); err != nil {
+			return err
+		}
+		n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_INFO, false, 12)
+		if err != nil {
+			return err
+		}
+		m.MeasCondUeIDs = make([]MeasurementCondUeID, n)
+		for i := range m.MeasCondUeIDs {
+			if err = m.MeasCondUeIDs[i].decode(d, version); err != nil {
+				return err
+			}
+		}
+		if hasGranularity {
+			if err = m.getGranularity(d); err != nil {
+				return err
+			}
+		}
+	default:
+		n, err := d.GetSequenceOf(1, MAX_UE_MEAS_REPORT, false, 16)
+		if err != nil {
+			return err
+		}
+		m.UeReports = make([]UeMeasurementReport, n)
+		for i := range m.UeReports {
+			report := &m.UeReports[i]
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			if err = report.UeID.decode(d); err != nil {
+				return err
+			}
+			report.Report = &IndicationMessage{Format: 1}
+			if err = report.Report.decodeFormat(d, version); err != nil {
+				return err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return err
+			}
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (m *IndicationMessage) getGranularity(d *aper.Decoder) error {
+	granularity, err := d.GetInteger(1, 4294967295, false)
+	m.Granularity = time.Duration(granularity) * time.Millisecond
+	return err
+}
+
+func decodeMeasurementData(d *aper.Decoder) ([]MeasurementData, error) {
+	n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_RECORD, false, 10)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]MeasurementData, n)
+	for i := range data {
+		extended, err := d.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		hasIncomplete, err := d.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		records, err := d.GetSequenceOf(1, MAX_MEASUREMENT_VALUE, false, 2)
+		if err != nil {
+			return nil, err
+		}
+		data[i].Record = make([]RecordItem, records)
+		for j := range data[i].Record {
+			record := &data[i].Record[j]
+			index, err := d.GetChoice(3, true)
+			if err != nil {
+				return nil, err
+			}
+			switch index {
+			case 0:
+				record.Kind = RECORD_INTEGER
+				var v int64
+				v, err = d.GetInteger(0, 4294967295, false)
+				record.Integer = uint32(v)
+			case 1:
+				record.Kind = RECORD_REAL
+				record.Real, err = d.GetReal()
+			case 2:
+				record.Kind = RECORD_NO_VALUE
+			default:
+				record.Kind = RECORD_NO_VALUE
+				err = skipOpenType(d)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if hasIncomplete {
+			if data[i].Incomplete, err = getTrue(d); err != nil {
+				return nil, err
+			}
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
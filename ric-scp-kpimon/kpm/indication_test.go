@@ -0,0 +1,143 @@
+package kpm
+
+import (
+	"testing"
+	"time"
+)
+
+var testMeasData = []MeasurementData{
+	{Record: []RecordItem{{Kind: RECORD_INTEGER, Integer: 1000}, {Kind: RECORD_REAL, Real: 0.25}, {Kind: RECORD_NO_VALUE}, {Kind: RECORD_INTEGER, Integer: 4294967295}}},
+	{Record: []RecordItem{{Kind: RECORD_INTEGER}, {Kind: RECORD_REAL, Real: -3}, {Kind: RECORD_INTEGER, Integer: 7}, {Kind: RECORD_NO_VALUE}}, Incomplete: true},
+}
+
+var indicationMessages = []struct {
+	name    string
+	version Version
+	message IndicationMessage
+}{
+	{"format 1", VERSION_2, IndicationMessage{Format: 1, MeasData: testMeasData, MeasInfo: testMeasInfo, Granularity: time.Second}},
+	{"format 1 of the action measurements", VERSION_3, IndicationMessage{Format: 1, MeasData: testMeasData[:1]}},
+	{"format 1 of v3 labels", VERSION_3, IndicationMessage{Format: 1, MeasData: []MeasurementData{{Record: []RecordItem{{Kind: RECORD_INTEGER, Integer: 3}}}},
+		MeasInfo: actionDefinitions[2].action.MeasInfo}},
+	{"format 2", VERSION_2, IndicationMessage{Format: 2, MeasData: testMeasData, Granularity: 10 * time.Second, MeasCondUeIDs: []MeasurementCondUeID{
+		{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: []MatchingCondition{{Test: &testConditions[0]}}, UeIDs: []UeID{gnbUe, duUe}},
+		{Type: MeasurementType{ID: 2}, Conditions: []MatchingCondition{{Label: &Label{NoLabel: true}}}},
+		{Type: MeasurementType{Name: "RRU.PrbUsedDl"}, Conditions: []MatchingCondition{{Label: &Label{FiveQI: int64p(9)}}}},
+		{Type: MeasurementType{Name: "RRU.PrbUsedUl"}, Conditions: []MatchingCondition{{Test: &testConditions[5]}}},
+	}}},
+	{"format 2 of v3", VERSION_3, IndicationMessage{Format: 2, MeasData: testMeasData[:1], MeasCondUeIDs: []MeasurementCondUeID{
+		{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: []MatchingCondition{{Test: &testConditions[0], Or: true}, {Test: &testConditions[1]}},
+			UeIDs: []UeID{gnbUe}, UeIDsPerGP: [][]UeID{{gnbUe}, nil}},
+		{Type: MeasurementType{ID: 2}, Conditions: []MatchingCondition{{Label: &Label{NoLabel: true}}}},
+		{Type: MeasurementType{ID: 3}, Conditions: []MatchingCondition{{Label: &Label{NoLabel: true}}}},
+		{Type: MeasurementType{ID: 4}, Conditions: []MatchingCondition{{Label: &Label{NoLabel: true}}}},
+	}}},
+	{"format 3", VERSION_2, IndicationMessage{Format: 3, UeReports: []UeMeasurementReport{
+		{UeID: gnbUe, Report: &IndicationMessage{Format: 1, MeasData: testMeasData[:1], Granularity: time.Second}},
+		{UeID: duUe, Report: &IndicationMessage{Format: 1, MeasData: testMeasData[1:], MeasInfo: testMeasInfo}},
+	}}},
+}
+
+func TestIndicationMessage(t *testing.T) {
+	for _, test := range indicationMessages {
+		payload, err := EncodeIndicationMessage(&test.message, test.version)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		message, err := DecodeIndicationMessage(payload, test.version)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, message, &test.message)
+		for i := 1; i < len(payload); i++ {
+			if _, err := DecodeIndicationMessage(payload[:i], test.version); err == nil {
+				t.Errorf("%s: truncated to %d octets decoded", test.name, i)
+			}
+		}
+	}
+
+	//one granularity period of the integer 5, the measurements and period being those of the action
+	golden := goldenBytes(t, "00000000010005")
+	message := IndicationMessage{Format: 1, MeasData: []MeasurementData{{Record: []RecordItem{{Kind: RECORD_INTEGER, Integer: 5}}}}}
+	payload, err := EncodeIndicationMessage(&message, VERSION_2)
+	checkEncoded(t, "indication message", payload, err, golden)
+	decoded, err := DecodeIndicationMessage(golden, VERSION_2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDecoded(t, "indication message", decoded, &message)
+}
+
+func TestIndicationMessageErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version Version
+		message IndicationMessage
+	}{
+		{"v1", VERSION_1, indicationMessages[0].message},
+		{"format 4", VERSION_2, IndicationMessage{Format: 4, MeasData: testMeasData}},
+		{"no measurement data", VERSION_2, IndicationMessage{Format: 1}},
+		{"empty record", VERSION_2, IndicationMessage{Format: 1, MeasData: []MeasurementData{{}}}},
+		{"unknown record kind", VERSION_2, IndicationMessage{Format: 1, MeasData: []MeasurementData{{Record: []RecordItem{{Kind: "string"}}}}}},
+		{"v3 label of v2", VERSION_2, indicationMessages[2].message},
+		{"v3 matched UEs of v2", VERSION_2, indicationMessages[4].message},
+		{"format 3 without report", VERSION_2, IndicationMessage{Format: 3, UeReports: []UeMeasurementReport{{UeID: gnbUe}}}},
+		{"format 3 of a format 2 report", VERSION_2, IndicationMessage{Format: 3, UeReports: []UeMeasurementReport{{UeID: gnbUe, Report: &indicationMessages[3].message}}}},
+	} {
+		if payload, err := EncodeIndicationMessage(&test.message, test.version); err == nil {
+			t.Errorf("%s: encoded to %x", test.name, payload)
+		}
+	}
+}
+
+func TestIndicationHeader(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	for _, test := range []struct {
+		name   string
+		header IndicationHeader
+	}{
+		{"collection start only", IndicationHeader{CollectStartTime: [4]byte{0xe8, 0xfe, 0x6f, 0x80}}},
+		{"every field", IndicationHeader{CollectStartTime: [4]byte{0xe8, 0xfe, 0x6f, 0x80}, FileFormatVersion: "1", SenderName: "gnb-1", SenderType: "O-DU", VendorName: "kpimon"}},
+	} {
+		payload, err := EncodeIndicationHeader(&test.header)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		header, err := DecodeIndicationHeader(payload)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, header, &test.header)
+		if !header.CollectStart().Equal(start) {
+			t.Errorf("%s: collection start %v, want %v", test.name, header.CollectStart(), start)
+		}
+	}
+
+	//the sender name after the collection start time, its length in 2 aligned octets
+	golden := goldenBytes(t, "08e8fe6f8000000474657374")
+	header := IndicationHeader{SenderName: "test"}
+	header.SetCollectStart(start)
+	payload, err := EncodeIndicationHeader(&header)
+	checkEncoded(t, "indication header", payload, err, golden)
+	if decoded, err := DecodeIndicationHeader(golden); err != nil || *decoded != header {
+		t.Errorf("indication header decoded to %+v, %v", decoded, err)
+	}
+
+	//UNIX seconds of simulators are told apart from NTP seconds
+	unix := IndicationHeader{CollectStartTime: [4]byte{0x65, 0x53, 0xf1, 0x00}}
+	if !unix.CollectStart().Equal(start) {
+		t.Errorf("UNIX collection start %v, want %v", unix.CollectStart(), start)
+	}
+	if _, err := EncodeIndicationHeader(&IndicationHeader{SenderName: "gnb_1"}); err == nil {
+		t.Error("sender name of a non printable character encoded")
+	}
+	for _, payload := range [][]byte{nil, {0x00, 0xe8, 0xfe}, {0x40}} {
+		if header, err := DecodeIndicationHeader(payload); err == nil {
+			t.Errorf("indication header %x decoded to %+v", payload, header)
+		}
+	}
+}
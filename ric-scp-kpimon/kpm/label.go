@@ -0,0 +1,245 @@
+package kpm
+
+import (
+	"errors"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+const (
+	START_END_START = "start"
+	START_END_END   = "end"
+)
+
+// Label is a MeasurementLabel: the subset (slice, QoS flow, ...) a measurement value is about.
+// Unset pointers and false flags are absent labels.
+type Label struct {
+	NoLabel           bool    `json:"no_label,omitempty"`
+	PlmnID            *PlmnID `json:"plmn_id,omitempty"`
+	SliceID           *SNSSAI `json:"slice_id,omitempty"`
+	FiveQI            *int64  `json:"five_qi,omitempty"`
+	QFI               *int64  `json:"qfi,omitempty"`
+	QCI               *int64  `json:"qci,omitempty"`
+	QCIMax            *int64  `json:"qci_max,omitempty"`
+	QCIMin            *int64  `json:"qci_min,omitempty"`
+	ARPMax            *int64  `json:"arp_max,omitempty"`
+	ARPMin            *int64  `json:"arp_min,omitempty"`
+	BitrateRange      *int64  `json:"bitrate_range,omitempty"`
+	LayerMuMimo       *int64  `json:"layer_mu_mimo,omitempty"`
+	Sum               bool    `json:"sum,omitempty"`
+	DistBinX          *int64  `json:"dist_bin_x,omitempty"`
+	DistBinY          *int64  `json:"dist_bin_y,omitempty"`
+	DistBinZ          *int64  `json:"dist_bin_z,omitempty"`
+	PreLabelOverride  bool    `json:"pre_label_override,omitempty"`
+	StartEndInd       string  `json:"start_end_ind,omitempty"` //start or end
+	Min               bool    `json:"min,omitempty"`
+	Max               bool    `json:"max,omitempty"`
+	Avg               bool    `json:"avg,omitempty"`
+	SSBIndex          *int64  `json:"ssb_index,omitempty"`             //KPM v3
+	NonGoBBFModeIndex *int64  `json:"non_gob_bf_mode_index,omitempty"` //KPM v3
+	MIMOModeIndex     *int64  `json:"mimo_mode_index,omitempty"`       //KPM v3
+}
+
+// labelInteger is an optional INTEGER component of MeasurementLabel with its extensible range
+type labelInteger struct {
+	value **int64
+	lb    int64
+	ub    int64
+}
+
+func (l *Label) integers() []labelInteger {
+	return []labelInteger{
+		{&l.FiveQI, 0, 255}, {&l.QFI, 0, 63}, {&l.QCI, 0, 255}, {&l.QCIMax, 0, 255}, {&l.QCIMin, 0, 255},
+		{&l.ARPMax, 1, 15}, {&l.ARPMin, 1, 15}, {&l.BitrateRange, 1, 65535}, {&l.LayerMuMimo, 1, 65535},
+	}
+}
+
+func (l *Label) distBins() []labelInteger {
+	return []labelInteger{{&l.DistBinX, 1, 65535}, {&l.DistBinY, 1, 65535}, {&l.DistBinZ, 1, 65535}}
+}
+
+func (l *Label) v3Integers() []labelInteger {
+	return []labelInteger{{&l.SSBIndex, 1, 65535}, {&l.NonGoBBFModeIndex, 1, 65535}, {&l.MIMOModeIndex, 1, 2}}
+}
+
+func (l *Label) encode(e *aper.Encoder, version Version) error {
+	var ext []func(e *aper.Encoder) error
+	for _, field := range l.v3Integers() {
+		if *field.value == nil {
+			ext = append(ext, nil)
+			continue
+		}
+		if version < VERSION_3 {
+			return errors.New("MeasurementLabel ssbIndex, nonGoB-BFmode-Index and mIMO-mode-Index need KPM v3")
+		}
+		value, lb, ub := **field.value, field.lb, field.ub
+		ext = append(ext, func(e *aper.Encoder) error { return e.PutInteger(value, lb, ub, true) })
+	}
+	e.PutBool(aper.HasExtensions(ext))
+
+	e.PutBool(l.NoLabel)
+	e.PutBool(l.PlmnID != nil)
+	e.PutBool(l.SliceID != nil)
+	for _, field := range l.integers() {
+		e.PutBool(*field.value != nil)
+	}
+	e.PutBool(l.Sum)
+	for _, field := range l.distBins() {
+		e.PutBool(*field.value != nil)
+	}
+	for _, flag := range []bool{l.PreLabelOverride, l.StartEndInd != "", l.Min, l.Max, l.Avg} {
+		e.PutBool(flag)
+	}
+
+	if l.NoLabel {
+		putTrue(e)
+	}
+	if l.PlmnID != nil {
+		if err := l.PlmnID.encode(e); err != nil {
+			return err
+		}
+	}
+	if l.SliceID != nil {
+		if err := l.SliceID.encode(e); err != nil {
+			return err
+		}
+	}
+	if err := putLabelIntegers(e, l.integers()); err != nil {
+		return err
+	}
+	if l.Sum {
+		putTrue(e)
+	}
+	if err := putLabelIntegers(e, l.distBins()); err != nil {
+		return err
+	}
+	if l.PreLabelOverride {
+		putTrue(e)
+	}
+	if l.StartEndInd != "" {
+		index := map[string]int{START_END_START: 0, START_END_END: 1}
+		i, ok := index[l.StartEndInd]
+		if !ok {
+			return errors.New("Unknown MeasurementLabel startEndInd: " + l.StartEndInd)
+		}
+		if err := e.PutEnumerated(i, 2, true); err != nil {
+			return err
+		}
+	}
+	for _, flag := range []bool{l.Min, l.Max, l.Avg} {
+		if flag {
+			putTrue(e)
+		}
+	}
+	return e.PutExtensions(ext)
+}
+
+func (l *Label) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 21)
+	if err != nil {
+		return err
+	}
+	if present[0] {
+		if l.NoLabel, err = getTrue(d); err != nil {
+			return err
+		}
+	}
+	if present[1] {
+		l.PlmnID = &PlmnID{}
+		if err = l.PlmnID.decode(d); err != nil {
+			return err
+		}
+	}
+	if present[2] {
+		l.SliceID = &SNSSAI{}
+		if err = l.SliceID.decode(d); err != nil {
+			return err
+		}
+	}
+	if err = getLabelIntegers(d, l.integers(), present[3:12]); err != nil {
+		return err
+	}
+	if present[12] {
+		if l.Sum, err = getTrue(d); err != nil {
+			return err
+		}
+	}
+	if err = getLabelIntegers(d, l.distBins(), present[13:16]); err != nil {
+		return err
+	}
+	if present[16] {
+		if l.PreLabelOverride, err = getTrue(d); err != nil {
+			return err
+		}
+	}
+	if present[17] {
+		i, err := d.GetEnumerated(2, true)
+		if err != nil {
+			return err
+		}
+		if i >= 2 {
+			return errors.New("Unknown MeasurementLabel startEndInd value")
+		}
+		l.StartEndInd = []string{START_END_START, START_END_END}[i]
+	}
+	for i, flag := range []*bool{&l.Min, &l.Max, &l.Avg} {
+		if present[18+i] {
+			if *flag, err = getTrue(d); err != nil {
+				return err
+			}
+		}
+	}
+	if !extended {
+		return nil
+	}
+	v3 := l.v3Integers()
+	return d.GetExtensions(func(index int, d *aper.Decoder) error {
+		if index >= len(v3) {
+			return nil
+		}
+		v, err := d.GetInteger(v3[index].lb, v3[index].ub, true)
+		*v3[index].value = &v
+		return err
+	})
+}
+
+func putLabelIntegers(e *aper.Encoder, fields []labelInteger) error {
+	for _, field := range fields {
+		if *field.value == nil {
+			continue
+		}
+		if err := e.PutInteger(**field.value, field.lb, field.ub, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getLabelIntegers(d *aper.Decoder, fields []labelInteger, present []bool) error {
+	for i, field := range fields {
+		if !present[i] {
+			continue
+		}
+		v, err := d.GetInteger(field.lb, field.ub, true)
+		if err != nil {
+			return err
+		}
+		*field.value = &v
+	}
+	return nil
+}
+
+// putTrue writes the only root value of an ENUMERATED {true, ...}
+func putTrue(e *aper.Encoder) {
+	e.PutEnumerated(0, 1, true)
+}
+
+// getTrue reads an ENUMERATED {true, ...}, an extension value not being true
+func getTrue(d *aper.Decoder) (bool, error) {
+	i, err := d.GetEnumerated(1, true)
+	return i == 0, err
+}
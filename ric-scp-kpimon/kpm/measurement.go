@@ -0,0 +1,547 @@
+package kpm
+
+import (
+	"errors"
+	"strconv"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+const (
+	MAX_MEASUREMENT_INFO      = 65535      //maxnoofMeasurementInfo
+	MAX_LABEL_INFO            = 2147483647 //maxnoofLabelInfo
+	MAX_MEASUREMENT_RECORD    = 65535      //maxnoofMeasurementRecord
+	MAX_MEASUREMENT_VALUE     = 2147483647 //maxnoofMeasurementValue
+	MAX_CONDITION_INFO        = 32768      //maxnoofConditionInfo
+	MAX_CONDITION_INFO_PERSUB = 32768      //maxnoofConditionInfoPerSub
+	MAX_UEID                  = 65535      //maxnoofUEID
+	MAX_UEID_PERSUB           = 65535      //maxnoofUEIDPerSub
+	MAX_UE_MEAS_REPORT        = 64         //maxnoofUEMeasReport
+	MAX_BIN                   = 65535      //maxnoofBin
+
+	TEST_GBR     = "gbr"
+	TEST_AMBR    = "ambr"
+	TEST_IS_STAT = "is-stat"
+	TEST_IS_CATM = "is-cat-m"
+	TEST_RSRP    = "rsrp"
+	TEST_RSRQ    = "rsrq"
+	TEST_UL_RSRP = "ul-rsrp"
+	TEST_CQI     = "cqi"
+	TEST_FIVE_QI = "five-qi"
+	TEST_QCI     = "qci"
+	TEST_SNSSAI  = "s-nssai"
+
+	EXPR_EQUAL        = "equal"
+	EXPR_GREATER_THAN = "greater-than"
+	EXPR_LESS_THAN    = "less-than"
+	EXPR_CONTAINS     = "contains"
+	EXPR_PRESENT      = "present"
+
+	VALUE_INT       = "int"
+	VALUE_ENUM      = "enum"
+	VALUE_BOOL      = "bool"
+	VALUE_BITS      = "bits"
+	VALUE_OCTETS    = "octets"
+	VALUE_PRINTABLE = "printable"
+	VALUE_REAL      = "real"
+)
+
+var (
+	testTypes   = []string{TEST_GBR, TEST_AMBR, TEST_IS_STAT, TEST_IS_CATM, TEST_RSRP, TEST_RSRQ, TEST_UL_RSRP, TEST_CQI, TEST_FIVE_QI, TEST_QCI, TEST_SNSSAI}
+	expressions = []string{EXPR_EQUAL, EXPR_GREATER_THAN, EXPR_LESS_THAN, EXPR_CONTAINS, EXPR_PRESENT}
+	valueKinds  = []string{VALUE_INT, VALUE_ENUM, VALUE_BOOL, VALUE_BITS, VALUE_OCTETS, VALUE_PRINTABLE, VALUE_REAL}
+)
+
+// MeasurementType names a measurement by its 3GPP name (e.g. RRU.PrbUsedDl) or, when Name is empty, by ID
+type MeasurementType struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	ID   int64  `json:"id,omitempty" yaml:"id,omitempty"`
+}
+
+func (t MeasurementType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "id:" + strconv.FormatInt(t.ID, 10)
+}
+
+func (t *MeasurementType) encode(e *aper.Encoder) error {
+	if t.Name != "" {
+		if err := e.PutChoice(0, 2, true); err != nil {
+			return err
+		}
+		return e.PutPrintableString(t.Name, 1, 150, true)
+	}
+	if err := e.PutChoice(1, 2, true); err != nil {
+		return err
+	}
+	return e.PutInteger(t.ID, 1, 65536, true)
+}
+
+func (t *MeasurementType) decode(d *aper.Decoder) error {
+	index, err := d.GetChoice(2, true)
+	if err != nil {
+		return err
+	}
+	switch index {
+	case 0:
+		t.Name, err = d.GetPrintableString(1, 150, true)
+	case 1:
+		t.ID, err = d.GetInteger(1, 65536, true)
+	default:
+		err = errors.New("Unknown MeasurementType alternative")
+	}
+	return err
+}
+
+// MeasurementInfo is a MeasurementInfoItem: a measurement and the labels it is reported for, one value per label
+type MeasurementInfo struct {
+	Type   MeasurementType `json:"type" yaml:"type"`
+	Labels []Label         `json:"labels" yaml:"labels"`
+}
+
+func encodeMeasurementInfoList(e *aper.Encoder, list []MeasurementInfo, version Version) error {
+	if err := e.PutSequenceOf(len(list), 1, MAX_MEASUREMENT_INFO, false); err != nil {
+		return err
+	}
+	for i := range list {
+		item := &list[i]
+		e.PutBool(false)
+		if err := item.Type.encode(e); err != nil {
+			return err
+		}
+		labels := item.Labels
+		if len(labels) == 0 {
+			labels = []Label{{NoLabel: true}}
+		}
+		if err := e.PutSequenceOf(len(labels), 1, MAX_LABEL_INFO, false); err != nil {
+			return err
+		}
+		for j := range labels {
+			e.PutBool(false)
+			if err := labels[j].encode(e, version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeMeasurementInfoList(d *aper.Decoder) ([]MeasurementInfo, error) {
+	n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_INFO, false, 8)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]MeasurementInfo, n)
+	for i := range list {
+		extended, err := d.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		if err = list[i].Type.decode(d); err != nil {
+			return nil, err
+		}
+		labels, err := d.GetSequenceOf(1, MAX_LABEL_INFO, false, 23)
+		if err != nil {
+			return nil, err
+		}
+		list[i].Labels = make([]Label, labels)
+		for j := range list[i].Labels {
+			labelExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			if err = list[i].Labels[j].decode(d); err != nil {
+				return nil, err
+			}
+			if err = skipExtensions(d, labelExtended); err != nil {
+				return nil, err
+			}
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// TestValue is a TestCond-Value, Kind naming the alternative and the field holding the value
+type TestValue struct {
+	Kind   string  `json:"kind" yaml:"kind"` //int, enum, bool, bits, octets, printable or real
+	Int    int64   `json:"int,omitempty" yaml:"int,omitempty"`
+	Bool   bool    `json:"bool,omitempty" yaml:"bool,omitempty"`
+	Bytes  []byte  `json:"bytes,omitempty" yaml:"bytes,omitempty"` //bits and octets
+	Bits   int     `json:"bits,omitempty" yaml:"bits,omitempty"`   //number of bits of a bits value
+	String string  `json:"string,omitempty" yaml:"string,omitempty"`
+	Real   float64 `json:"real,omitempty" yaml:"real,omitempty"`
+}
+
+func (v *TestValue) encode(e *aper.Encoder, version Version) error {
+	index := indexOf(valueKinds, v.Kind)
+	if index < 0 {
+		return errors.New("Unknown TestCond-Value kind: " + v.Kind)
+	}
+	if err := e.PutChoice(index, 6, true); err != nil {
+		return err
+	}
+	switch v.Kind {
+	case VALUE_INT, VALUE_ENUM:
+		e.PutUnconstrainedInteger(v.Int)
+	case VALUE_BOOL:
+		e.PutBool(v.Bool)
+	case VALUE_BITS:
+		return e.PutBitString(v.Bytes, v.Bits, 0, aper.UNBOUNDED, false)
+	case VALUE_OCTETS:
+		return e.PutOctetString(v.Bytes, 0, aper.UNBOUNDED, false)
+	case VALUE_PRINTABLE:
+		return e.PutPrintableString(v.String, 0, aper.UNBOUNDED, false)
+	case VALUE_REAL:
+		real := v.Real
+		return e.PutOpenType(func(e *aper.Encoder) error {
+			e.PutReal(real)
+			return nil
+		})
+	}
+	return nil
+}
+
+func (v *TestValue) decode(d *aper.Decoder) error {
+	index, err := d.GetChoice(6, true)
+	if err != nil {
+		return err
+	}
+	if index >= len(valueKinds) {
+		return errors.New("Unknown TestCond-Value alternative")
+	}
+	v.Kind = valueKinds[index]
+	switch v.Kind {
+	case VALUE_INT, VALUE_ENUM:
+		v.Int, err = d.GetUnconstrainedInteger()
+	case VALUE_BOOL:
+		v.Bool, err = d.GetBool()
+	case VALUE_BITS:
+		v.Bytes, v.Bits, err = d.GetBitString(0, aper.UNBOUNDED, false)
+	case VALUE_OCTETS:
+		v.Bytes, err = d.GetOctetString(0, aper.UNBOUNDED, false)
+	case VALUE_PRINTABLE:
+		v.String, err = d.GetPrintableString(0, aper.UNBOUNDED, false)
+	case VALUE_REAL:
+		var inner *aper.Decoder
+		if inner, err = d.GetOpenType(); err == nil {
+			v.Real, err = inner.GetReal()
+		}
+	}
+	return err
+}
+
+// TestCondition is a TestCondInfo: a test on a UE property (e.g. its RSRP or 5QI), Or chaining it
+// to the next condition with a logical OR instead of AND (KPM v3)
+type TestCondition struct {
+	Type       string     `json:"type" yaml:"type"`
+	Expression string     `json:"expression,omitempty" yaml:"expression,omitempty"`
+	Value      *TestValue `json:"value,omitempty" yaml:"value,omitempty"`
+	Or         bool       `json:"or,omitempty" yaml:"or,omitempty"`
+}
+
+func (t *TestCondition) encode(e *aper.Encoder, version Version) error {
+	index := indexOf(testTypes, t.Type)
+	if index < 0 {
+		return errors.New("Unknown TestCond-Type: " + t.Type)
+	}
+	e.PutBool(false)
+	e.PutBool(t.Expression != "")
+	e.PutBool(t.Value != nil)
+	if err := e.PutChoice(index, 6, true); err != nil {
+		return err
+	}
+	if index >= 6 {
+		if err := e.PutOpenType(func(e *aper.Encoder) error {
+			putTrue(e)
+			return nil
+		}); err != nil {
+			return err
+		}
+	} else {
+		putTrue(e)
+	}
+	if t.Expression != "" {
+		expr := indexOf(expressions, t.Expression)
+		if expr < 0 {
+			return errors.New("Unknown TestCond-Expression: " + t.Expression)
+		}
+		if err := e.PutEnumerated(expr, len(expressions), true); err != nil {
+			return err
+		}
+	}
+	if t.Value != nil {
+		return t.Value.encode(e, version)
+	}
+	return nil
+}
+
+func (t *TestCondition) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	index, err := d.GetChoice(6, true)
+	if err != nil {
+		return err
+	}
+	if index >= len(testTypes) {
+		return errors.New("Unknown TestCond-Type alternative")
+	}
+	t.Type = testTypes[index]
+	if index >= 6 {
+		err = skipOpenType(d)
+	} else {
+		_, err = getTrue(d)
+	}
+	if err != nil {
+		return err
+	}
+	if present[0] {
+		expr, err := d.GetEnumerated(len(expressions), true)
+		if err != nil {
+			return err
+		}
+		if expr >= len(expressions) {
+			return errors.New("Unknown TestCond-Expression value")
+		}
+		t.Expression = expressions[expr]
+	}
+	if present[1] {
+		t.Value = &TestValue{}
+		if err = t.Value.decode(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+// MatchingCondition is a MatchingCondItem: a label or a test the measured subset must match,
+// Or chaining it to the next condition with a logical OR (KPM v3)
+type MatchingCondition struct {
+	Label *Label         `json:"label,omitempty" yaml:"label,omitempty"`
+	Test  *TestCondition `json:"test,omitempty" yaml:"test,omitempty"`
+	Or    bool           `json:"or,omitempty" yaml:"or,omitempty"`
+}
+
+func (m *MatchingCondition) encode(e *aper.Encoder, version Version) error {
+	if version >= VERSION_3 {
+		//MatchingCondItem became a SEQUENCE of the v2 CHOICE and logicalOR
+		e.PutBool(false)
+		e.PutBool(m.Or)
+	} else if m.Or {
+		return errors.New("MatchingCondItem logicalOR needs KPM v3")
+	}
+	switch {
+	case m.Label != nil:
+		if err := e.PutChoice(0, 2, true); err != nil {
+			return err
+		}
+		if err := m.Label.encode(e, version); err != nil {
+			return err
+		}
+	case m.Test != nil:
+		if err := e.PutChoice(1, 2, true); err != nil {
+			return err
+		}
+		if err := m.Test.encode(e, version); err != nil {
+			return err
+		}
+	default:
+		return errors.New("MatchingCondItem needs a label or a test")
+	}
+	if version >= VERSION_3 && m.Or {
+		putTrue(e)
+	}
+	return nil
+}
+
+func (m *MatchingCondition) decode(d *aper.Decoder, version Version) error {
+	extended, hasOr := false, false
+	var err error
+	if version >= VERSION_3 {
+		if extended, err = d.GetBool(); err != nil {
+			return err
+		}
+		if hasOr, err = d.GetBool(); err != nil {
+			return err
+		}
+	}
+	index, err := d.GetChoice(2, true)
+	if err != nil {
+		return err
+	}
+	switch index {
+	case 0:
+		m.Label = &Label{}
+		err = m.Label.decode(d)
+	case 1:
+		m.Test = &TestCondition{}
+		err = m.Test.decode(d)
+	default:
+		err = errors.New("Unknown MatchingCondItem alternative")
+	}
+	if err != nil {
+		return err
+	}
+	if hasOr {
+		if m.Or, err = getTrue(d); err != nil {
+			return err
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func encodeMatchingConditions(e *aper.Encoder, list []MatchingCondition, version Version) error {
+	if err := e.PutSequenceOf(len(list), 1, MAX_CONDITION_INFO, false); err != nil {
+		return err
+	}
+	for i := range list {
+		if err := list[i].encode(e, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMatchingConditions(d *aper.Decoder, version Version) ([]MatchingCondition, error) {
+	n, err := d.GetSequenceOf(1, MAX_CONDITION_INFO, false, 2)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]MatchingCondition, n)
+	for i := range list {
+		if err = list[i].decode(d, version); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// BinRange is a BinRangeItem of a distribution measurement (KPM v3), bounds being integers unless Real is set
+type BinRange struct {
+	Index int64   `json:"index" yaml:"index"`
+	Start float64 `json:"start" yaml:"start"`
+	End   float64 `json:"end" yaml:"end"`
+	Real  bool    `json:"real,omitempty" yaml:"real,omitempty"`
+}
+
+// BinRangeDefinition gives the bins of the X, Y and Z axes of a distribution measurement (KPM v3)
+type BinRangeDefinition struct {
+	X []BinRange `json:"x" yaml:"x"`
+	Y []BinRange `json:"y,omitempty" yaml:"y,omitempty"`
+	Z []BinRange `json:"z,omitempty" yaml:"z,omitempty"`
+}
+
+func (b *BinRangeDefinition) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(len(b.Y) > 0)
+	e.PutBool(len(b.Z) > 0)
+	for i, axis := range [][]BinRange{b.X, b.Y, b.Z} {
+		if i > 0 && len(axis) == 0 {
+			continue
+		}
+		if err := e.PutSequenceOf(len(axis), 1, MAX_BIN, false); err != nil {
+			return err
+		}
+		for _, bin := range axis {
+			e.PutBool(false)
+			if err := e.PutInteger(bin.Index, 1, 65535, true); err != nil {
+				return err
+			}
+			for _, bound := range []float64{bin.Start, bin.End} {
+				if bin.Real {
+					if err := e.PutChoice(1, 2, true); err != nil {
+						return err
+					}
+					e.PutReal(bound)
+				} else {
+					if err := e.PutChoice(0, 2, true); err != nil {
+						return err
+					}
+					e.PutUnconstrainedInteger(int64(bound))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (b *BinRangeDefinition) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return err
+	}
+	axes := []*[]BinRange{&b.X, &b.Y, &b.Z}
+	for i, axis := range axes {
+		if i > 0 && !present[i-1] {
+			continue
+		}
+		n, err := d.GetSequenceOf(1, MAX_BIN, false, 24)
+		if err != nil {
+			return err
+		}
+		*axis = make([]BinRange, n)
+		for j := range *axis {
+			bin := &(*axis)[j]
+			binExtended, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			if bin.Index, err = d.GetInteger(1, 65535, true); err != nil {
+				return err
+			}
+			for _, bound := range []*float64{&bin.Start, &bin.End} {
+				index, err := d.GetChoice(2, true)
+				if err != nil {
+					return err
+				}
+				switch index {
+				case 0:
+					v, err := d.GetUnconstrainedInteger()
+					if err != nil {
+						return err
+					}
+					*bound = float64(v)
+				case 1:
+					bin.Real = true
+					if *bound, err = d.GetReal(); err != nil {
+						return err
+					}
+				default:
+					return errors.New("Unknown BinRangeValue alternative")
+				}
+			}
+			if err = skipExtensions(d, binExtended); err != nil {
+				return err
+			}
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+// DistBinRange is a DistMeasurementBinRangeItem: the bins of one distribution measurement (KPM v3)
+type DistBinRange struct {
+	Name string             `json:"name" yaml:"name"`
+	Bins BinRangeDefinition `json:"bins" yaml:"bins"`
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
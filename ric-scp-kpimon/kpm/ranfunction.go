@@ -0,0 +1,215 @@
+package kpm
+
+import (
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+const MAX_RIC_STYLES = 63 //maxnoofRICStyles
+
+// EventTriggerStyle is a RIC-EventTriggerStyle-Item of a KPM v2/v3 RAN function definition
+type EventTriggerStyle struct {
+	Type   int64  `json:"type"`
+	Name   string `json:"name"`
+	Format int64  `json:"format"`
+}
+
+// MeasurementInfoAction is a MeasurementInfo-Action-Item: a measurement a report style supports
+type MeasurementInfoAction struct {
+	Name     string              `json:"name"`
+	ID       *int64              `json:"id,omitempty"`
+	BinRange *BinRangeDefinition `json:"bin_range,omitempty"` //KPM v3
+}
+
+// ReportStyle is a RIC-ReportStyle-Item of a KPM v2/v3 RAN function definition
+type ReportStyle struct {
+	Type          int64                   `json:"type"`
+	Name          string                  `json:"name"`
+	ActionFormat  int64                   `json:"action_format"`
+	MeasInfo      []MeasurementInfoAction `json:"meas_info"`
+	HeaderFormat  int64                   `json:"header_format"`
+	MessageFormat int64                   `json:"message_format"`
+}
+
+// RanFunctionDescription is an E2SM-KPM-RANfunction-Description of KPM v2 or v3
+type RanFunctionDescription struct {
+	Name               RanFunctionName     `json:"name"`
+	EventTriggerStyles []EventTriggerStyle `json:"event_trigger_styles,omitempty"`
+	ReportStyles       []ReportStyle       `json:"report_styles,omitempty"`
+}
+
+func EncodeRanFunctionDescription(desc *RanFunctionDescription) ([]byte, error) {
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	e.PutBool(len(desc.EventTriggerStyles) > 0)
+	e.PutBool(len(desc.ReportStyles) > 0)
+	if err := desc.Name.encode(e); err != nil {
+		return nil, err
+	}
+	if len(desc.EventTriggerStyles) > 0 {
+		if err := e.PutSequenceOf(len(desc.EventTriggerStyles), 1, MAX_RIC_STYLES, false); err != nil {
+			return nil, err
+		}
+		for _, style := range desc.EventTriggerStyles {
+			e.PutBool(false)
+			e.PutUnconstrainedInteger(style.Type)
+			if err := e.PutPrintableString(style.Name, 1, 150, true); err != nil {
+				return nil, err
+			}
+			e.PutUnconstrainedInteger(style.Format)
+		}
+	}
+	if len(desc.ReportStyles) > 0 {
+		if err := e.PutSequenceOf(len(desc.ReportStyles), 1, MAX_RIC_STYLES, false); err != nil {
+			return nil, err
+		}
+		for _, style := range desc.ReportStyles {
+			e.PutBool(false)
+			e.PutUnconstrainedInteger(style.Type)
+			if err := e.PutPrintableString(style.Name, 1, 150, true); err != nil {
+				return nil, err
+			}
+			e.PutUnconstrainedInteger(style.ActionFormat)
+			if err := e.PutSequenceOf(len(style.MeasInfo), 1, MAX_MEASUREMENT_INFO, false); err != nil {
+				return nil, err
+			}
+			for _, info := range style.MeasInfo {
+				var ext []func(e *aper.Encoder) error
+				if info.BinRange != nil {
+					ext = append(ext, info.BinRange.encode)
+				}
+				e.PutBool(aper.HasExtensions(ext))
+				e.PutBool(info.ID != nil)
+				if err := e.PutPrintableString(info.Name, 1, 150, true); err != nil {
+					return nil, err
+				}
+				if info.ID != nil {
+					if err := e.PutInteger(*info.ID, 1, 65536, true); err != nil {
+						return nil, err
+					}
+				}
+				if err := e.PutExtensions(ext); err != nil {
+					return nil, err
+				}
+			}
+			e.PutUnconstrainedInteger(style.HeaderFormat)
+			e.PutUnconstrainedInteger(style.MessageFormat)
+		}
+	}
+	return e.Bytes(), nil
+}
+
+// DecodeRanFunctionDescription reads the RAN function definition of a KPM v2 or v3 E2 node
+func DecodeRanFunctionDescription(buf []byte) (*RanFunctionDescription, error) {
+	d := aper.NewDecoder(buf)
+	extended, err := d.GetBool()
+	if err != nil {
+		return nil, err
+	}
+	present, err := getOptionals(d, 2)
+	if err != nil {
+		return nil, err
+	}
+	desc := &RanFunctionDescription{}
+	if err = desc.Name.decode(d); err != nil {
+		return nil, err
+	}
+	if present[0] {
+		n, err := d.GetSequenceOf(1, MAX_RIC_STYLES, false, 27)
+		if err != nil {
+			return nil, err
+		}
+		desc.EventTriggerStyles = make([]EventTriggerStyle, n)
+		for i := range desc.EventTriggerStyles {
+			style := &desc.EventTriggerStyles[i]
+			itemExtended, err := d.GetBool()
+			if err != nil {
+				return nil, err
+			}
+			if style.Type, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if style.Name, err = d.GetPrintableString(1, 150, true); err != nil {
+				return nil, err
+			}
+			if style.Format, err = d.GetUnconstrainedInteger(); err != nil {
+				return nil, err
+			}
+			if err = skipExtensions(d, itemExtended); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if present[1] {
+		n, err := d.GetSequenceOf(1, MAX_RIC_STYLES, false, 48)
+		if err != nil {
+			return nil, err
+		}
+		desc.ReportStyles = make([]ReportStyle, n)
+		for i := range desc.ReportStyles {
+			if err = desc.ReportStyles[i].decode(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return desc, skipExtensions(d, extended)
+}
+
+func (s *ReportStyle) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if s.Type, err = d.GetUnconstrainedInteger(); err != nil {
+		return err
+	}
+	if s.Name, err = d.GetPrintableString(1, 150, true); err != nil {
+		return err
+	}
+	if s.ActionFormat, err = d.GetUnconstrainedInteger(); err != nil {
+		return err
+	}
+	n, err := d.GetSequenceOf(1, MAX_MEASUREMENT_INFO, false, 11)
+	if err != nil {
+		return err
+	}
+	s.MeasInfo = make([]MeasurementInfoAction, n)
+	for i := range s.MeasInfo {
+		info := &s.MeasInfo[i]
+		itemExtended, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		hasID, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		if info.Name, err = d.GetPrintableString(1, 150, true); err != nil {
+			return err
+		}
+		if hasID {
+			id, err := d.GetInteger(1, 65536, true)
+			if err != nil {
+				return err
+			}
+			info.ID = &id
+		}
+		if itemExtended {
+			if err = d.GetExtensions(func(index int, d *aper.Decoder) error {
+				if index != 0 {
+					return nil
+				}
+				info.BinRange = &BinRangeDefinition{}
+				return info.BinRange.decode(d)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.HeaderFormat, err = d.GetUnconstrainedInteger(); err != nil {
+		return err
+	}
+	if s.MessageFormat, err = d.GetUnconstrainedInteger(); err != nil {
+		return err
+	}
+	return skipExtensions(d, extended)
+}
@@ -0,0 +1,65 @@
+package kpm
+
+import (
+	"testing"
+)
+
+var testReportStyle = ReportStyle{
+	Type: 1, Name: "E2 Node Measurement", ActionFormat: 1,
+	MeasInfo:     []MeasurementInfoAction{{Name: "DRB.UEThpDl", ID: int64p(1)}, {Name: "RRU.PrbUsedDl"}},
+	HeaderFormat: 1, MessageFormat: 1,
+}
+
+var ranFunctionDescriptions = []struct {
+	name string
+	desc RanFunctionDescription
+}{
+	{"name only", RanFunctionDescription{Name: RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V2, Description: "KPM Monitor"}}},
+	{"v2 styles", RanFunctionDescription{
+		Name:               RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V2, Description: "KPM Monitor", Instance: int64p(3)},
+		EventTriggerStyles: []EventTriggerStyle{{Type: 1, Name: "Periodic Report", Format: 1}},
+		ReportStyles: []ReportStyle{
+			testReportStyle,
+			{Type: 4, Name: "Common Condition-based, UE-level Measurement", ActionFormat: 4, MeasInfo: []MeasurementInfoAction{{Name: "DRB.UEThpUl"}}, HeaderFormat: 1, MessageFormat: 3},
+		},
+	}},
+	{"v3 bin range", RanFunctionDescription{
+		Name: RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V3, Description: "KPM Monitor"},
+		ReportStyles: []ReportStyle{{Type: 1, Name: "E2 Node Measurement", ActionFormat: 1, HeaderFormat: 1, MessageFormat: 1, MeasInfo: []MeasurementInfoAction{{
+			Name:     "L1M.RS-SINR.Bin",
+			BinRange: &BinRangeDefinition{X: []BinRange{{Index: 1, Start: 0, End: 10}, {Index: 2, Start: 10.5, End: 20, Real: true}}},
+		}}}},
+	}},
+}
+
+func TestRanFunctionDescription(t *testing.T) {
+	for _, test := range ranFunctionDescriptions {
+		payload, err := EncodeRanFunctionDescription(&test.desc)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		desc, err := DecodeRanFunctionDescription(payload)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, desc, &test.desc)
+		if name, version, err := DecodeRanFunctionName(payload); err != nil || name.OID != test.desc.Name.OID || version != VersionFromOID(test.desc.Name.OID) {
+			t.Errorf("%s: RAN function name %+v, %v, %v", test.name, name, version, err)
+		}
+		for i := 1; i < len(payload); i++ {
+			if _, err := DecodeRanFunctionDescription(payload[:i]); err == nil {
+				t.Errorf("%s: truncated to %d octets decoded", test.name, i)
+			}
+		}
+	}
+
+	//no style list: the extension and presence bits, then the 3 strings of the RANfunction-Name
+	golden := goldenBytes(t, "00304f52414e2d4532534d2d4b504d000018312e332e362e312e342e312e35333134382e312e322e322e3205004b504d204d6f6e69746f72")
+	payload, err := EncodeRanFunctionDescription(&ranFunctionDescriptions[0].desc)
+	checkEncoded(t, "RAN function name", payload, err, golden)
+	if _, err := EncodeRanFunctionDescription(&RanFunctionDescription{Name: RanFunctionName{ShortName: "KPM_monitor", OID: OID_V2, Description: "KPM"}}); err == nil {
+		t.Error("name of a non printable character encoded")
+	}
+}
@@ -0,0 +1,165 @@
+package kpm
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Measurement is one value of a RIC indication, the unit of the common metrics model
+type Measurement struct {
+	Type       MeasurementType     `json:"type"`
+	Label      *Label              `json:"label,omitempty"`       //subset the value is about (formats 1 and 3)
+	Conditions []MatchingCondition `json:"conditions,omitempty"`  //subset the value is about (format 2)
+	UeID       *UeID               `json:"ue_id,omitempty"`       //UE the value is about, nil for an E2 node or cell value
+	MatchedUes []UeID              `json:"matched_ues,omitempty"` //UEs matching Conditions (format 2)
+	Cell       *CGI                `json:"cell,omitempty"`        //cell of the action definition, nil for the whole E2 node
+	Period     int                 `json:"period"`                //index of the granularity period in the indication
+	Start      time.Time           `json:"start"`                 //start of the granularity period
+	Value      float64             `json:"value"`
+	Integer    bool                `json:"integer,omitempty"`  //the E2 node reported an integer
+	NoValue    bool                `json:"no_value,omitempty"` //the E2 node reported no value
+	Incomplete bool                `json:"incomplete,omitempty"`
+}
+
+// Report is a decoded RIC indication of a KPM v2 or v3 E2 node
+type Report struct {
+	Version      Version            `json:"version"`
+	Header       *IndicationHeader  `json:"header"`
+	Message      *IndicationMessage `json:"message"`
+	Measurements []Measurement      `json:"measurements"`
+}
+
+// Decode reads the indication header and message of an E2 node of version, flattening the message into
+// measurements. action is the action definition the indication answers: format 1 messages may leave
+// out the measurement list and granularity period of the subscription.
+func Decode(version Version, header []byte, message []byte, action *ActionDefinition) (*Report, error) {
+	h, err := DecodeIndicationHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	m, err := DecodeIndicationMessage(message, version)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Version: version, Header: h, Message: m}
+	if report.Measurements, err = Flatten(h, m, action); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Flatten returns one Measurement per value of an indication message
+func Flatten(h *IndicationHeader, m *IndicationMessage, action *ActionDefinition) ([]Measurement, error) {
+	var cell *CGI
+	if action != nil {
+		cell = action.Cell
+	}
+	start := h.CollectStart()
+	switch m.Format {
+	case 1:
+		return flattenFormat1(m, action, nil, cell, start)
+	case 2:
+		granularity := m.Granularity
+		if granularity == 0 && action != nil {
+			granularity = action.Granularity
+		}
+		var measurements []Measurement
+		for period, data := range m.MeasData {
+			if len(data.Record) != len(m.MeasCondUeIDs) {
+				return nil, errors.New("MeasurementRecord of " + strconv.Itoa(len(data.Record)) + " values for " + strconv.Itoa(len(m.MeasCondUeIDs)) + " measurement conditions")
+			}
+			for i, record := range data.Record {
+				condition := &m.MeasCondUeIDs[i]
+				measurement := Measurement{
+					Type:       condition.Type,
+					Conditions: condition.Conditions,
+					MatchedUes: condition.UeIDs,
+					Cell:       cell,
+					Period:     period,
+					Start:      start.Add(time.Duration(period) * granularity),
+					Incomplete: data.Incomplete,
+				}
+				if period < len(condition.UeIDsPerGP) {
+					measurement.MatchedUes = condition.UeIDsPerGP[period]
+				}
+				measurement.setValue(record)
+				measurements = append(measurements, measurement)
+			}
+		}
+		return measurements, nil
+	case 3:
+		var measurements []Measurement
+		for i := range m.UeReports {
+			ueReport := &m.UeReports[i]
+			ueMeasurements, err := flattenFormat1(ueReport.Report, action, &ueReport.UeID, cell, start)
+			if err != nil {
+				return nil, err
+			}
+			measurements = append(measurements, ueMeasurements...)
+		}
+		return measurements, nil
+	}
+	return nil, errors.New("Unknown E2SM-KPM-IndicationMessage format " + strconv.Itoa(m.Format))
+}
+
+// flattenFormat1 maps the values of each record of a format 1 message to the measurement and label
+// they are reported for: one value per label of each measurement, in the order of the measurement list
+func flattenFormat1(m *IndicationMessage, action *ActionDefinition, ueID *UeID, cell *CGI, start time.Time) ([]Measurement, error) {
+	infos, granularity := m.MeasInfo, m.Granularity
+	if action != nil {
+		if len(infos) == 0 {
+			infos = action.MeasInfo
+		}
+		if granularity == 0 {
+			granularity = action.Granularity
+		}
+	}
+	if len(infos) == 0 {
+		return nil, errors.New("E2SM-KPM-IndicationMessage without measurement list and no action definition to take it from")
+	}
+	type column struct {
+		measType MeasurementType
+		label    *Label
+	}
+	var columns []column
+	for i := range infos {
+		if len(infos[i].Labels) == 0 {
+			columns = append(columns, column{infos[i].Type, nil})
+		}
+		for j := range infos[i].Labels {
+			columns = append(columns, column{infos[i].Type, &infos[i].Labels[j]})
+		}
+	}
+	measurements := make([]Measurement, 0, len(columns)*len(m.MeasData))
+	for period, data := range m.MeasData {
+		if len(data.Record) != len(columns) {
+			return nil, errors.New("MeasurementRecord of " + strconv.Itoa(len(data.Record)) + " values for " + strconv.Itoa(len(columns)) + " measurement labels")
+		}
+		for i, record := range data.Record {
+			measurement := Measurement{
+				Type:       columns[i].measType,
+				Label:      columns[i].label,
+				UeID:       ueID,
+				Cell:       cell,
+				Period:     period,
+				Start:      start.Add(time.Duration(period) * granularity),
+				Incomplete: data.Incomplete,
+			}
+			measurement.setValue(record)
+			measurements = append(measurements, measurement)
+		}
+	}
+	return measurements, nil
+}
+
+func (m *Measurement) setValue(record RecordItem) {
+	switch record.Kind {
+	case RECORD_INTEGER:
+		m.Value, m.Integer = float64(record.Integer), true
+	case RECORD_REAL:
+		m.Value = record.Real
+	default:
+		m.NoValue = true
+	}
+}
@@ -0,0 +1,128 @@
+package kpm
+
+import (
+	"testing"
+	"time"
+)
+
+var reportStart = time.Unix(1700000000, 0)
+
+func reportHeader() *IndicationHeader {
+	h := &IndicationHeader{SenderName: "gnb-1"}
+	h.SetCollectStart(reportStart)
+	return h
+}
+
+func integerRecord(values ...uint32) MeasurementData {
+	data := MeasurementData{}
+	for _, v := range values {
+		data.Record = append(data.Record, RecordItem{Kind: RECORD_INTEGER, Integer: v})
+	}
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	//the columns of the records are the labels of each measurement, a measurement without label taking one
+	action := &ActionDefinition{Style: 1, Format: 1, Granularity: time.Second, Cell: testCell, MeasInfo: []MeasurementInfo{
+		{Type: MeasurementType{Name: "DRB.UEThpDl"}, Labels: []Label{{NoLabel: true}, {FiveQI: int64p(9)}}},
+		{Type: MeasurementType{Name: "RRU.PrbUsedDl"}},
+	}}
+	message := &IndicationMessage{Format: 1, MeasData: []MeasurementData{
+		integerRecord(100, 60, 30),
+		{Record: []RecordItem{{Kind: RECORD_REAL, Real: 2.5}, {Kind: RECORD_NO_VALUE}, {Kind: RECORD_INTEGER, Integer: 31}}, Incomplete: true},
+	}}
+	header, err := EncodeIndicationHeader(reportHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := EncodeIndicationMessage(message, VERSION_2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Decode(VERSION_2, header, payload, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != VERSION_2 || report.Header.SenderName != "gnb-1" || report.Message.Format != 1 {
+		t.Errorf("report of %v, header %+v, message format %d", report.Version, report.Header, report.Message.Format)
+	}
+	thp, prb := MeasurementType{Name: "DRB.UEThpDl"}, MeasurementType{Name: "RRU.PrbUsedDl"}
+	labels := action.MeasInfo[0].Labels
+	later := reportStart.Add(time.Second)
+	checkDecoded(t, "format 1 report", report.Measurements, []Measurement{
+		{Type: thp, Label: &labels[0], Cell: testCell, Start: reportStart, Value: 100, Integer: true},
+		{Type: thp, Label: &labels[1], Cell: testCell, Start: reportStart, Value: 60, Integer: true},
+		{Type: prb, Cell: testCell, Start: reportStart, Value: 30, Integer: true},
+		{Type: thp, Label: &labels[0], Cell: testCell, Period: 1, Start: later, Value: 2.5, Incomplete: true},
+		{Type: thp, Label: &labels[1], Cell: testCell, Period: 1, Start: later, NoValue: true, Incomplete: true},
+		{Type: prb, Cell: testCell, Period: 1, Start: later, Value: 31, Integer: true, Incomplete: true},
+	})
+
+	if _, err := Decode(VERSION_2, header[:2], payload, action); err == nil {
+		t.Error("truncated header decoded")
+	}
+	if _, err := Decode(VERSION_2, header, payload[:4], action); err == nil {
+		t.Error("truncated message decoded")
+	}
+	if _, err := Decode(VERSION_2, header, payload, nil); err == nil {
+		t.Error("format 1 message without measurement list decoded without action definition")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	h := reportHeader()
+	action := &ActionDefinition{Style: 1, Format: 1, Granularity: time.Second, MeasInfo: []MeasurementInfo{{Type: MeasurementType{Name: "RRU.PrbUsedDl"}}}}
+	ownInfo := []MeasurementInfo{{Type: MeasurementType{ID: 1}}, {Type: MeasurementType{ID: 2}}}
+	conditions := []MatchingCondition{{Test: &testConditions[0]}}
+	for _, test := range []struct {
+		name    string
+		message IndicationMessage
+		action  *ActionDefinition
+		want    []Measurement
+	}{
+		{"format 1 of its own measurements and period", IndicationMessage{Format: 1, MeasData: []MeasurementData{integerRecord(1, 2), integerRecord(3, 4)}, MeasInfo: ownInfo, Granularity: 5 * time.Second},
+			action, []Measurement{
+				{Type: ownInfo[0].Type, Start: reportStart, Value: 1, Integer: true},
+				{Type: ownInfo[1].Type, Start: reportStart, Value: 2, Integer: true},
+				{Type: ownInfo[0].Type, Period: 1, Start: reportStart.Add(5 * time.Second), Value: 3, Integer: true},
+				{Type: ownInfo[1].Type, Period: 1, Start: reportStart.Add(5 * time.Second), Value: 4, Integer: true},
+			}},
+		{"format 2 per condition", IndicationMessage{Format: 2, MeasData: []MeasurementData{integerRecord(10, 20), integerRecord(11, 21)}, MeasCondUeIDs: []MeasurementCondUeID{
+			{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: conditions, UeIDs: []UeID{gnbUe, duUe}, UeIDsPerGP: [][]UeID{{gnbUe}}},
+			{Type: MeasurementType{Name: "DRB.UEThpUl"}, Conditions: conditions},
+		}}, &ActionDefinition{Granularity: 2 * time.Second, Cell: testCell}, []Measurement{
+			{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: conditions, MatchedUes: []UeID{gnbUe}, Cell: testCell, Start: reportStart, Value: 10, Integer: true},
+			{Type: MeasurementType{Name: "DRB.UEThpUl"}, Conditions: conditions, Cell: testCell, Start: reportStart, Value: 20, Integer: true},
+			{Type: MeasurementType{Name: "DRB.UEThpDl"}, Conditions: conditions, MatchedUes: []UeID{gnbUe, duUe}, Cell: testCell, Period: 1, Start: reportStart.Add(2 * time.Second), Value: 11, Integer: true},
+			{Type: MeasurementType{Name: "DRB.UEThpUl"}, Conditions: conditions, Cell: testCell, Period: 1, Start: reportStart.Add(2 * time.Second), Value: 21, Integer: true},
+		}},
+		{"format 3 per UE", IndicationMessage{Format: 3, UeReports: []UeMeasurementReport{
+			{UeID: gnbUe, Report: &IndicationMessage{Format: 1, MeasData: []MeasurementData{integerRecord(7)}}},
+			{UeID: duUe, Report: &IndicationMessage{Format: 1, MeasData: []MeasurementData{integerRecord(8)}}},
+		}}, action, []Measurement{
+			{Type: action.MeasInfo[0].Type, UeID: &gnbUe, Start: reportStart, Value: 7, Integer: true},
+			{Type: action.MeasInfo[0].Type, UeID: &duUe, Start: reportStart, Value: 8, Integer: true},
+		}},
+	} {
+		measurements, err := Flatten(h, &test.message, test.action)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, measurements, test.want)
+	}
+
+	for _, test := range []struct {
+		name    string
+		message IndicationMessage
+	}{
+		{"format 1 record of another measurement count", IndicationMessage{Format: 1, MeasData: []MeasurementData{integerRecord(1, 2)}}},
+		{"format 2 record of another condition count", IndicationMessage{Format: 2, MeasData: []MeasurementData{integerRecord(1, 2)}, MeasCondUeIDs: []MeasurementCondUeID{{Type: MeasurementType{ID: 1}, Conditions: conditions}}}},
+		{"format 3 record of another measurement count", IndicationMessage{Format: 3, UeReports: []UeMeasurementReport{{UeID: gnbUe, Report: &IndicationMessage{Format: 1, MeasData: []MeasurementData{integerRecord(1, 2)}}}}}},
+		{"format 4", IndicationMessage{Format: 4}},
+	} {
+		if measurements, err := Flatten(h, &test.message, action); err == nil {
+			t.Errorf("%s flattened to %+v", test.name, measurements)
+		}
+	}
+}
@@ -0,0 +1,528 @@
+package kpm
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+const (
+	UEID_GNB       = "gnb"
+	UEID_GNB_DU    = "gnb-du"
+	UEID_GNB_CU_UP = "gnb-cu-up"
+	UEID_NG_ENB    = "ng-enb"
+	UEID_NG_ENB_DU = "ng-enb-du"
+	UEID_EN_GNB    = "en-gnb"
+	UEID_ENB       = "enb"
+
+	MAX_F1AP_ID = 4     //maxF1APid
+	MAX_E1AP_ID = 65535 //maxE1APid
+)
+
+var ueIDTypes = []string{UEID_GNB, UEID_GNB_DU, UEID_GNB_CU_UP, UEID_NG_ENB, UEID_NG_ENB_DU, UEID_EN_GNB, UEID_ENB}
+
+// UeID is the UEID of the E2SM common definitions: the identifiers of a UE at one kind of E2 node.
+// Only the fields of the UEID alternative named by Type are used, optional ones are nil or empty.
+type UeID struct {
+	Type             string        `json:"type"`
+	AMFUeNgapID      uint64        `json:"amf_ue_ngap_id,omitempty"`        //gnb, ng-enb
+	GUAMI            *GUAMI        `json:"guami,omitempty"`                 //gnb, ng-enb
+	GnbCuUeF1apIDs   []uint32      `json:"gnb_cu_ue_f1ap_ids,omitempty"`    //gnb (up to 4), gnb-du and en-gnb (1)
+	GnbCuCpUeE1apIDs []uint32      `json:"gnb_cu_cp_ue_e1ap_ids,omitempty"` //gnb, en-gnb, gnb-cu-up (1)
+	RanUeID          *[8]byte      `json:"ran_ue_id,omitempty"`             //gnb, gnb-du, gnb-cu-up, en-gnb
+	MNgRanUeXnapID   *uint32       `json:"m_ng_ran_ue_xnap_id,omitempty"`   //gnb, ng-enb
+	GlobalNodeID     *GlobalNodeID `json:"global_node_id,omitempty"`        //gnb (gNB), ng-enb (ng-eNB), en-gnb and enb (eNB)
+	GlobalNgRanNode  *GlobalNodeID `json:"global_ng_ran_node,omitempty"`    //gnb, ng-enb
+	NgEnbCuUeW1apID  *uint32       `json:"ng_enb_cu_ue_w1ap_id,omitempty"`  //ng-enb (optional), ng-enb-du
+	MEnbUeX2apID     *uint16       `json:"m_enb_ue_x2ap_id,omitempty"`      //en-gnb, enb (optional)
+	MEnbUeX2apIDExt  *uint16       `json:"m_enb_ue_x2ap_id_ext,omitempty"`  //en-gnb, enb
+	MMEUeS1apID      uint32        `json:"mme_ue_s1ap_id,omitempty"`        //enb
+	GUMMEI           *GUMMEI       `json:"gummei,omitempty"`                //enb
+}
+
+// Key returns the UE identifier kpimon stores the UE under: the RAN UE ID when the E2 node gives one,
+// as it is common to the DU, CU-CP and CU-UP of a gNB, otherwise the main ID of the UEID alternative
+func (u *UeID) Key() string {
+	if u.RanUeID != nil {
+		return strconv.FormatUint(binary.BigEndian.Uint64(u.RanUeID[:]), 10)
+	}
+	switch u.Type {
+	case UEID_GNB, UEID_NG_ENB:
+		return strconv.FormatUint(u.AMFUeNgapID, 10)
+	case UEID_GNB_DU:
+		if len(u.GnbCuUeF1apIDs) > 0 {
+			return strconv.FormatUint(uint64(u.GnbCuUeF1apIDs[0]), 10)
+		}
+	case UEID_GNB_CU_UP:
+		if len(u.GnbCuCpUeE1apIDs) > 0 {
+			return strconv.FormatUint(uint64(u.GnbCuCpUeE1apIDs[0]), 10)
+		}
+	case UEID_NG_ENB_DU:
+		if u.NgEnbCuUeW1apID != nil {
+			return strconv.FormatUint(uint64(*u.NgEnbCuUeW1apID), 10)
+		}
+	case UEID_EN_GNB:
+		if u.MEnbUeX2apID != nil {
+			return strconv.FormatUint(uint64(*u.MEnbUeX2apID), 10)
+		}
+	case UEID_ENB:
+		return strconv.FormatUint(uint64(u.MMEUeS1apID), 10)
+	}
+	return ""
+}
+
+func (u *UeID) index() int {
+	for i, t := range ueIDTypes {
+		if t == u.Type {
+			return i
+		}
+	}
+	return -1
+}
+
+func (u *UeID) encode(e *aper.Encoder) error {
+	index := u.index()
+	if index < 0 {
+		return errors.New("Unknown UEID type: " + u.Type)
+	}
+	if err := e.PutChoice(index, len(ueIDTypes), true); err != nil {
+		return err
+	}
+	switch u.Type {
+	case UEID_GNB:
+		var ext []func(e *aper.Encoder) error
+		if u.GlobalNgRanNode != nil {
+			ext = append(ext, u.GlobalNgRanNode.encodeRanNode)
+		}
+		e.PutBool(aper.HasExtensions(ext))
+		for _, present := range []bool{len(u.GnbCuUeF1apIDs) > 0, len(u.GnbCuCpUeE1apIDs) > 0, u.RanUeID != nil, u.MNgRanUeXnapID != nil, u.GlobalNodeID != nil} {
+			e.PutBool(present)
+		}
+		if err := e.PutInteger(int64(u.AMFUeNgapID), 0, 1099511627775, false); err != nil {
+			return err
+		}
+		if err := u.guami().encode(e); err != nil {
+			return err
+		}
+		if len(u.GnbCuUeF1apIDs) > 0 {
+			if err := putIDList(e, u.GnbCuUeF1apIDs, MAX_F1AP_ID); err != nil {
+				return err
+			}
+		}
+		if len(u.GnbCuCpUeE1apIDs) > 0 {
+			if err := putIDList(e, u.GnbCuCpUeE1apIDs, MAX_E1AP_ID); err != nil {
+				return err
+			}
+		}
+		if u.RanUeID != nil {
+			if err := e.PutOctetString(u.RanUeID[:], 8, 8, false); err != nil {
+				return err
+			}
+		}
+		if u.MNgRanUeXnapID != nil {
+			if err := e.PutInteger(int64(*u.MNgRanUeXnapID), 0, 4294967295, false); err != nil {
+				return err
+			}
+		}
+		if u.GlobalNodeID != nil {
+			if err := u.GlobalNodeID.encode(e); err != nil {
+				return err
+			}
+		}
+		return e.PutExtensions(ext)
+	case UEID_GNB_DU, UEID_GNB_CU_UP:
+		ids := u.GnbCuUeF1apIDs
+		if u.Type == UEID_GNB_CU_UP {
+			ids = u.GnbCuCpUeE1apIDs
+		}
+		if len(ids) != 1 {
+			return errors.New("UEID " + u.Type + " needs exactly one F1AP or E1AP ID")
+		}
+		e.PutBool(false)
+		e.PutBool(u.RanUeID != nil)
+		if err := e.PutInteger(int64(ids[0]), 0, 4294967295, false); err != nil {
+			return err
+		}
+		if u.RanUeID != nil {
+			return e.PutOctetString(u.RanUeID[:], 8, 8, false)
+		}
+		return nil
+	case UEID_NG_ENB:
+		var ext []func(e *aper.Encoder) error
+		if u.GlobalNgRanNode != nil {
+			ext = append(ext, u.GlobalNgRanNode.encodeRanNode)
+		}
+		e.PutBool(aper.HasExtensions(ext))
+		for _, present := range []bool{u.NgEnbCuUeW1apID != nil, u.MNgRanUeXnapID != nil, u.GlobalNodeID != nil} {
+			e.PutBool(present)
+		}
+		if err := e.PutInteger(int64(u.AMFUeNgapID), 0, 1099511627775, false); err != nil {
+			return err
+		}
+		if err := u.guami().encode(e); err != nil {
+			return err
+		}
+		if u.NgEnbCuUeW1apID != nil {
+			if err := e.PutInteger(int64(*u.NgEnbCuUeW1apID), 0, 4294967295, false); err != nil {
+				return err
+			}
+		}
+		if u.MNgRanUeXnapID != nil {
+			if err := e.PutInteger(int64(*u.MNgRanUeXnapID), 0, 4294967295, false); err != nil {
+				return err
+			}
+		}
+		if u.GlobalNodeID != nil {
+			if err := u.GlobalNodeID.encode(e); err != nil {
+				return err
+			}
+		}
+		return e.PutExtensions(ext)
+	case UEID_NG_ENB_DU:
+		if u.NgEnbCuUeW1apID == nil {
+			return errors.New("UEID ng-enb-du needs a W1AP ID")
+		}
+		e.PutBool(false)
+		return e.PutInteger(int64(*u.NgEnbCuUeW1apID), 0, 4294967295, false)
+	case UEID_EN_GNB:
+		if u.MEnbUeX2apID == nil || u.GlobalNodeID == nil {
+			return errors.New("UEID en-gnb needs an X2AP ID and a global eNB ID")
+		}
+		e.PutBool(false)
+		for _, present := range []bool{u.MEnbUeX2apIDExt != nil, len(u.GnbCuUeF1apIDs) > 0, len(u.GnbCuCpUeE1apIDs) > 0, u.RanUeID != nil} {
+			e.PutBool(present)
+		}
+		if err := e.PutInteger(int64(*u.MEnbUeX2apID), 0, 4095, false); err != nil {
+			return err
+		}
+		if u.MEnbUeX2apIDExt != nil {
+			if err := e.PutInteger(int64(*u.MEnbUeX2apIDExt), 0, 4095, true); err != nil {
+				return err
+			}
+		}
+		if err := u.GlobalNodeID.encode(e); err != nil {
+			return err
+		}
+		if len(u.GnbCuUeF1apIDs) > 0 {
+			if err := e.PutInteger(int64(u.GnbCuUeF1apIDs[0]), 0, 4294967295, false); err != nil {
+				return err
+			}
+		}
+		if len(u.GnbCuCpUeE1apIDs) > 0 {
+			if err := putIDList(e, u.GnbCuCpUeE1apIDs, MAX_E1AP_ID); err != nil {
+				return err
+			}
+		}
+		if u.RanUeID != nil {
+			return e.PutOctetString(u.RanUeID[:], 8, 8, false)
+		}
+		return nil
+	default:
+		e.PutBool(false)
+		for _, present := range []bool{u.MEnbUeX2apID != nil, u.MEnbUeX2apIDExt != nil, u.GlobalNodeID != nil} {
+			e.PutBool(present)
+		}
+		if err := e.PutInteger(int64(u.MMEUeS1apID), 0, 4294967295, false); err != nil {
+			return err
+		}
+		gummei := u.GUMMEI
+		if gummei == nil {
+			gummei = &GUMMEI{}
+		}
+		if err := gummei.encode(e); err != nil {
+			return err
+		}
+		if u.MEnbUeX2apID != nil {
+			if err := e.PutInteger(int64(*u.MEnbUeX2apID), 0, 4095, false); err != nil {
+				return err
+			}
+		}
+		if u.MEnbUeX2apIDExt != nil {
+			if err := e.PutInteger(int64(*u.MEnbUeX2apIDExt), 0, 4095, true); err != nil {
+				return err
+			}
+		}
+		if u.GlobalNodeID != nil {
+			return u.GlobalNodeID.encode(e)
+		}
+		return nil
+	}
+}
+
+func (u *UeID) guami() *GUAMI {
+	if u.GUAMI == nil {
+		return &GUAMI{}
+	}
+	return u.GUAMI
+}
+
+func (u *UeID) decode(d *aper.Decoder) error {
+	index, err := d.GetChoice(len(ueIDTypes), true)
+	if err != nil {
+		return err
+	}
+	if index >= len(ueIDTypes) {
+		return errors.New("Unknown UEID alternative " + strconv.Itoa(index))
+	}
+	u.Type = ueIDTypes[index]
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	switch u.Type {
+	case UEID_GNB:
+		present, err := getOptionals(d, 5)
+		if err != nil {
+			return err
+		}
+		if err = u.decodeAmf(d); err != nil {
+			return err
+		}
+		if present[0] {
+			if u.GnbCuUeF1apIDs, err = getIDList(d, MAX_F1AP_ID); err != nil {
+				return err
+			}
+		}
+		if present[1] {
+			if u.GnbCuCpUeE1apIDs, err = getIDList(d, MAX_E1AP_ID); err != nil {
+				return err
+			}
+		}
+		if present[2] {
+			if err = u.decodeRanUeID(d); err != nil {
+				return err
+			}
+		}
+		if present[3] {
+			if u.MNgRanUeXnapID, err = getID(d); err != nil {
+				return err
+			}
+		}
+		if present[4] {
+			u.GlobalNodeID = &GlobalNodeID{}
+			if err = u.GlobalNodeID.decode(d, NODE_GNB); err != nil {
+				return err
+			}
+		}
+		return u.decodeRanNodeExtension(d, extended)
+	case UEID_GNB_DU, UEID_GNB_CU_UP:
+		hasRanUeID, err := d.GetBool()
+		if err != nil {
+			return err
+		}
+		id, err := getID(d)
+		if err != nil {
+			return err
+		}
+		if u.Type == UEID_GNB_DU {
+			u.GnbCuUeF1apIDs = []uint32{*id}
+		} else {
+			u.GnbCuCpUeE1apIDs = []uint32{*id}
+		}
+		if hasRanUeID {
+			if err = u.decodeRanUeID(d); err != nil {
+				return err
+			}
+		}
+	case UEID_NG_ENB:
+		present, err := getOptionals(d, 3)
+		if err != nil {
+			return err
+		}
+		if err = u.decodeAmf(d); err != nil {
+			return err
+		}
+		if present[0] {
+			if u.NgEnbCuUeW1apID, err = getID(d); err != nil {
+				return err
+			}
+		}
+		if present[1] {
+			if u.MNgRanUeXnapID, err = getID(d); err != nil {
+				return err
+			}
+		}
+		if present[2] {
+			u.GlobalNodeID = &GlobalNodeID{}
+			if err = u.GlobalNodeID.decode(d, NODE_NG_ENB); err != nil {
+				return err
+			}
+		}
+		return u.decodeRanNodeExtension(d, extended)
+	case UEID_NG_ENB_DU:
+		if u.NgEnbCuUeW1apID, err = getID(d); err != nil {
+			return err
+		}
+	case UEID_EN_GNB:
+		present, err := getOptionals(d, 4)
+		if err != nil {
+			return err
+		}
+		if u.MEnbUeX2apID, err = getX2apID(d, false); err != nil {
+			return err
+		}
+		if present[0] {
+			if u.MEnbUeX2apIDExt, err = getX2apID(d, true); err != nil {
+				return err
+			}
+		}
+		u.GlobalNodeID = &GlobalNodeID{}
+		if err = u.GlobalNodeID.decode(d, NODE_ENB); err != nil {
+			return err
+		}
+		if present[1] {
+			id, err := getID(d)
+			if err != nil {
+				return err
+			}
+			u.GnbCuUeF1apIDs = []uint32{*id}
+		}
+		if present[2] {
+			if u.GnbCuCpUeE1apIDs, err = getIDList(d, MAX_E1AP_ID); err != nil {
+				return err
+			}
+		}
+		if present[3] {
+			if err = u.decodeRanUeID(d); err != nil {
+				return err
+			}
+		}
+	default:
+		present, err := getOptionals(d, 3)
+		if err != nil {
+			return err
+		}
+		id, err := getID(d)
+		if err != nil {
+			return err
+		}
+		u.MMEUeS1apID = *id
+		u.GUMMEI = &GUMMEI{}
+		if err = u.GUMMEI.decode(d); err != nil {
+			return err
+		}
+		if present[0] {
+			if u.MEnbUeX2apID, err = getX2apID(d, false); err != nil {
+				return err
+			}
+		}
+		if present[1] {
+			if u.MEnbUeX2apIDExt, err = getX2apID(d, true); err != nil {
+				return err
+			}
+		}
+		if present[2] {
+			u.GlobalNodeID = &GlobalNodeID{}
+			if err = u.GlobalNodeID.decode(d, NODE_ENB); err != nil {
+				return err
+			}
+		}
+	}
+	return skipExtensions(d, extended)
+}
+
+func (u *UeID) decodeAmf(d *aper.Decoder) error {
+	amf, err := d.GetInteger(0, 1099511627775, false)
+	if err != nil {
+		return err
+	}
+	u.AMFUeNgapID = uint64(amf)
+	u.GUAMI = &GUAMI{}
+	return u.GUAMI.decode(d)
+}
+
+func (u *UeID) decodeRanUeID(d *aper.Decoder) error {
+	v, err := d.GetOctetString(8, 8, false)
+	if err != nil {
+		return err
+	}
+	u.RanUeID = &[8]byte{}
+	copy(u.RanUeID[:], v)
+	return nil
+}
+
+// decodeRanNodeExtension reads the extension additions of UEID-GNB and UEID-NG-ENB, the first one being globalNG-RANNode-ID
+func (u *UeID) decodeRanNodeExtension(d *aper.Decoder, extended bool) error {
+	if !extended {
+		return nil
+	}
+	return d.GetExtensions(func(index int, d *aper.Decoder) (err error) {
+		if index == 0 {
+			u.GlobalNgRanNode, err = decodeRanNode(d)
+		}
+		return err
+	})
+}
+
+// getOptionals reads the presence bitmap of n optional components
+func getOptionals(d *aper.Decoder, n int) ([]bool, error) {
+	present := make([]bool, n)
+	for i := range present {
+		var err error
+		if present[i], err = d.GetBool(); err != nil {
+			return nil, err
+		}
+	}
+	return present, nil
+}
+
+// getID reads an INTEGER (0..4294967295): F1AP, E1AP, W1AP, XnAP and S1AP UE IDs
+func getID(d *aper.Decoder) (*uint32, error) {
+	v, err := d.GetInteger(0, 4294967295, false)
+	if err != nil {
+		return nil, err
+	}
+	id := uint32(v)
+	return &id, nil
+}
+
+func getX2apID(d *aper.Decoder, ext bool) (*uint16, error) {
+	v, err := d.GetInteger(0, 4095, ext)
+	if err != nil {
+		return nil, err
+	}
+	if v < 0 || v > 65535 {
+		return nil, aper.ErrRange
+	}
+	id := uint16(v)
+	return &id, nil
+}
+
+// putIDList writes a list of F1AP or E1AP ID items, each an extensible SEQUENCE holding the ID
+func putIDList(e *aper.Encoder, ids []uint32, max int64) error {
+	if err := e.PutSequenceOf(len(ids), 1, max, false); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		e.PutBool(false)
+		if err := e.PutInteger(int64(id), 0, 4294967295, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getIDList(d *aper.Decoder, max int64) ([]uint32, error) {
+	n, err := d.GetSequenceOf(1, max, false, 9)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint32, 0, n)
+	for i := 0; i < n; i++ {
+		extended, err := d.GetBool()
+		if err != nil {
+			return nil, err
+		}
+		id, err := getID(d)
+		if err != nil {
+			return nil, err
+		}
+		if err = skipExtensions(d, extended); err != nil {
+			return nil, err
+		}
+		ids = append(ids, *id)
+	}
+	return ids, nil
+}
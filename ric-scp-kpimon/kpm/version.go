@@ -0,0 +1,184 @@
+// Package kpm implements E2SM-KPM v2 and v3 (O-RAN.WG3.E2SM-KPM) next to the pre-standard v1 model
+// of the e2sm wrapper: version negotiation from the RAN function definition, the APER codecs of the
+// v2/v3 event trigger, action definition and indication formats, and a common metrics model the
+// indications of every version are decoded into.
+package kpm
+
+import (
+	"errors"
+	"strings"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+type Version int
+
+const (
+	VERSION_UNKNOWN Version = 0
+	VERSION_1       Version = 1 //pre-standard KPM of the e2sm wrapper, PF and RAN containers
+	VERSION_2       Version = 2 //E2SM-KPM v02.0x
+	VERSION_3       Version = 3 //E2SM-KPM v03.00
+
+	OID_V1 = "1.3.6.1.4.1.53148.1.1.2.2"
+	OID_V2 = "1.3.6.1.4.1.53148.1.2.2.2"
+	OID_V3 = "1.3.6.1.4.1.53148.1.3.2.2"
+
+	oidPrefix = "1.3.6.1.4.1.53148.1." //O-RAN E2SM OIDs, followed by the major version
+)
+
+func (v Version) String() string {
+	switch v {
+	case VERSION_1, VERSION_2, VERSION_3:
+		return "v" + string(rune('0'+int(v)))
+	}
+	return "unknown"
+}
+
+func (v Version) OID() string {
+	switch v {
+	case VERSION_1:
+		return OID_V1
+	case VERSION_2:
+		return OID_V2
+	case VERSION_3:
+		return OID_V3
+	}
+	return ""
+}
+
+// ParseVersion reads a KPM version as configured: 1, 2 or 3, with or without a v, or auto for VERSION_UNKNOWN
+func ParseVersion(s string) (Version, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v") {
+	case "", "auto":
+		return VERSION_UNKNOWN, nil
+	case "1":
+		return VERSION_1, nil
+	case "2":
+		return VERSION_2, nil
+	case "3":
+		return VERSION_3, nil
+	}
+	return VERSION_UNKNOWN, errors.New("Unknown KPM version: " + s)
+}
+
+// VersionFromOID returns the KPM version an E2SM OID names, VERSION_UNKNOWN for another OID
+func VersionFromOID(oid string) Version {
+	oid = strings.TrimSpace(oid)
+	if !strings.HasPrefix(oid, oidPrefix) {
+		return VERSION_UNKNOWN
+	}
+	switch {
+	case strings.HasPrefix(oid, oidPrefix+"1."):
+		return VERSION_1
+	case strings.HasPrefix(oid, oidPrefix+"2."):
+		return VERSION_2
+	case strings.HasPrefix(oid, oidPrefix+"3."):
+		return VERSION_3
+	}
+	return VERSION_UNKNOWN
+}
+
+// RanFunctionName is the RANfunction-Name every KPM RAN function definition starts with
+type RanFunctionName struct {
+	ShortName   string `json:"short_name"`
+	OID         string `json:"oid"`
+	Description string `json:"description"`
+	Instance    *int64 `json:"instance,omitempty"`
+}
+
+func (n *RanFunctionName) encode(e *aper.Encoder) error {
+	e.PutBool(false)
+	e.PutBool(n.Instance != nil)
+	if err := e.PutPrintableString(n.ShortName, 1, 150, true); err != nil {
+		return err
+	}
+	if err := e.PutPrintableString(n.OID, 1, 1000, true); err != nil {
+		return err
+	}
+	if err := e.PutPrintableString(n.Description, 1, 150, true); err != nil {
+		return err
+	}
+	if n.Instance != nil {
+		e.PutUnconstrainedInteger(*n.Instance)
+	}
+	return nil
+}
+
+func (n *RanFunctionName) decode(d *aper.Decoder) error {
+	extended, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	hasInstance, err := d.GetBool()
+	if err != nil {
+		return err
+	}
+	if n.ShortName, err = d.GetPrintableString(1, 150, true); err != nil {
+		return err
+	}
+	if n.OID, err = d.GetPrintableString(1, 1000, true); err != nil {
+		return err
+	}
+	if n.Description, err = d.GetPrintableString(1, 150, true); err != nil {
+		return err
+	}
+	if hasInstance {
+		instance, err := d.GetUnconstrainedInteger()
+		if err != nil {
+			return err
+		}
+		n.Instance = &instance
+	}
+	return skipExtensions(d, extended)
+}
+
+// DecodeRanFunctionName reads the RANfunction-Name of a RAN function definition of any KPM version.
+// The v2/v3 definition has two optional style lists before the name, the v1 one has none: the layout
+// whose name decodes to printable strings naming a known E2SM OID wins, then the v1 one if it decodes.
+func DecodeRanFunctionName(definition []byte) (*RanFunctionName, Version, error) {
+	var candidates []*RanFunctionName
+	var versions []Version
+	for _, optionals := range []int{2, 0} {
+		d := aper.NewDecoder(definition)
+		if _, err := getOptionals(d, 1+optionals); err != nil {
+			continue
+		}
+		name := &RanFunctionName{}
+		if err := name.decode(d); err != nil {
+			continue
+		}
+		version := VersionFromOID(name.OID)
+		if version != VERSION_UNKNOWN {
+			return name, version, nil
+		}
+		if optionals == 0 {
+			version = VERSION_1
+		}
+		candidates = append(candidates, name)
+		versions = append(versions, version)
+	}
+	if len(candidates) == 0 {
+		return nil, VERSION_UNKNOWN, errors.New("Invalid RAN function definition: no RANfunction-Name")
+	}
+	//pre-standard E2 nodes are the ones sending OIDs of their own
+	return candidates[len(candidates)-1], versions[len(versions)-1], nil
+}
+
+// Negotiate returns the KPM version to use with a RAN function. oid is the RAN function OID of the
+// E2 setup (E2AP v2 and later), empty if unknown, and definition the RAN function definition.
+// The OID wins over the definition; fallback is used when neither names a known version.
+func Negotiate(oid string, definition []byte, fallback Version) (Version, *RanFunctionName) {
+	var name *RanFunctionName
+	version := VersionFromOID(oid)
+	if len(definition) > 0 {
+		var named Version
+		var err error
+		if name, named, err = DecodeRanFunctionName(definition); err == nil && version == VERSION_UNKNOWN && VersionFromOID(name.OID) != VERSION_UNKNOWN {
+			version = named
+		}
+	}
+	if version == VERSION_UNKNOWN {
+		version = fallback
+	}
+	return version, name
+}
@@ -0,0 +1,169 @@
+package kpm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/aper"
+)
+
+// The goldens of this package are worked out by hand from the ASN.1 of E2SM-KPM and X.691, with the
+// asn1c departures of package aper; the round trip tables check every format the codecs write.
+
+var (
+	testPlmn = PlmnID{0x00, 0xf1, 0x10}
+	testCell = &CGI{PlmnID: testPlmn, CellID: 0x123456789}
+	gnbUe    = UeID{Type: UEID_GNB, AMFUeNgapID: 7, GUAMI: &GUAMI{PlmnID: testPlmn, AMFRegionID: 2, AMFSetID: 1, AMFPointer: 1}}
+	duUe     = UeID{Type: UEID_GNB_DU, GnbCuUeF1apIDs: []uint32{21}, RanUeID: &[8]byte{0, 0, 0, 0, 0, 0, 0, 21}}
+)
+
+func int64p(v int64) *int64 {
+	return &v
+}
+
+func goldenBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func checkDecoded(t *testing.T, name string, got interface{}, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("%s decoded to\n%s\nwant\n%s", name, gotJSON, wantJSON)
+	}
+}
+
+func checkEncoded(t *testing.T, name string, got []byte, err error, want []byte) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+	} else if !bytes.Equal(got, want) {
+		t.Errorf("%s encoded to %x, want %x", name, got, want)
+	}
+}
+
+// v1Definition encodes a RAN function definition of the pre-standard layout: the name follows the
+// extension bit, without the style lists of v2/v3
+func v1Definition(t *testing.T, name RanFunctionName) []byte {
+	t.Helper()
+	e := aper.NewEncoder()
+	e.PutBool(false)
+	if err := name.encode(e); err != nil {
+		t.Fatal(err)
+	}
+	return e.Bytes()
+}
+
+func definition(t *testing.T, name RanFunctionName) []byte {
+	t.Helper()
+	payload, err := EncodeRanFunctionDescription(&RanFunctionDescription{Name: name, ReportStyles: []ReportStyle{testReportStyle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, test := range []struct {
+		s       string
+		version Version
+		ok      bool
+	}{
+		{"", VERSION_UNKNOWN, true},
+		{"auto", VERSION_UNKNOWN, true},
+		{"1", VERSION_1, true},
+		{"v2", VERSION_2, true},
+		{" V3 ", VERSION_3, true},
+		{"4", VERSION_UNKNOWN, false},
+		{"v2.03", VERSION_UNKNOWN, false},
+	} {
+		version, err := ParseVersion(test.s)
+		if version != test.version || (err == nil) != test.ok {
+			t.Errorf("ParseVersion(%q) returned %v, %v", test.s, version, err)
+		}
+	}
+	for version, oid := range map[Version]string{VERSION_1: OID_V1, VERSION_2: OID_V2, VERSION_3: OID_V3, VERSION_UNKNOWN: ""} {
+		if version.OID() != oid || VersionFromOID(oid) != version {
+			t.Errorf("%v has OID %q, which names %v", version, version.OID(), VersionFromOID(oid))
+		}
+	}
+	if v := VersionFromOID("1.3.6.1.4.1.53148.1.2.2.100"); v != VERSION_2 {
+		t.Errorf("OID of another v2 revision names %v", v)
+	}
+	if v := VersionFromOID("1.3.6.1.4.1.53148.1.4.2.2"); v != VERSION_UNKNOWN {
+		t.Errorf("OID of a later major version names %v", v)
+	}
+}
+
+func TestDecodeRanFunctionName(t *testing.T) {
+	v2 := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V2, Description: "KPM Monitor"}
+	v3 := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V3, Description: "KPM Monitor", Instance: int64p(1)}
+	v1 := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V1, Description: "KPM monitor"}
+	vendor := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: "1.3.6.1.4.1.1.1", Description: "KPM monitor"}
+	for _, test := range []struct {
+		name       string
+		definition []byte
+		want       RanFunctionName
+		version    Version
+	}{
+		{"v2", definition(t, v2), v2, VERSION_2},
+		{"v3 with an instance", definition(t, v3), v3, VERSION_3},
+		{"v1 layout", v1Definition(t, v1), v1, VERSION_1},
+		{"v1 layout with a vendor OID", v1Definition(t, vendor), vendor, VERSION_1},
+	} {
+		name, version, err := DecodeRanFunctionName(test.definition)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkDecoded(t, test.name, name, &test.want)
+		if version != test.version {
+			t.Errorf("%s: version %v, want %v", test.name, version, test.version)
+		}
+	}
+	for _, payload := range [][]byte{nil, {0x00}, {0x20, 0x01}} {
+		if name, _, err := DecodeRanFunctionName(payload); err == nil {
+			t.Errorf("definition %x decoded to %+v", payload, name)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	v2 := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V2, Description: "KPM Monitor"}
+	v1 := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: OID_V1, Description: "KPM monitor"}
+	vendor := RanFunctionName{ShortName: "ORAN-E2SM-KPM", OID: "1.3.6.1.4.1.1.1", Description: "KPM monitor"}
+	for _, test := range []struct {
+		name       string
+		oid        string
+		definition []byte
+		fallback   Version
+		version    Version
+		named      *RanFunctionName
+	}{
+		{"OID over the definition", OID_V3, definition(t, v2), VERSION_1, VERSION_3, &v2},
+		{"definition without OID", "", definition(t, v2), VERSION_1, VERSION_2, &v2},
+		{"definition over an unknown OID", "1.3.6.1.4.1.1.2", definition(t, v2), VERSION_1, VERSION_2, &v2},
+		{"v1 definition", "", v1Definition(t, v1), VERSION_3, VERSION_1, &v1},
+		{"vendor OID falls back", "", v1Definition(t, vendor), VERSION_2, VERSION_2, &vendor},
+		{"OID without definition", OID_V2, nil, VERSION_1, VERSION_2, nil},
+		{"nothing falls back", "", nil, VERSION_3, VERSION_3, nil},
+		{"undecodable definition falls back", "", []byte{0x00}, VERSION_1, VERSION_1, nil},
+	} {
+		version, name := Negotiate(test.oid, test.definition, test.fallback)
+		if version != test.version {
+			t.Errorf("%s: version %v, want %v", test.name, version, test.version)
+		}
+		if (name == nil) != (test.named == nil) || (name != nil && !reflect.DeepEqual(name, test.named)) {
+			t.Errorf("%s: name %+v, want %+v", test.name, name, test.named)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+type jsonlWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+	mu      *sync.Mutex
+}
+
+// NewJSONLWriter appends records to path, one JSON object per line
+func NewJSONLWriter(path string) (Writer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0766)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlWriter{file, json.NewEncoder(file), &sync.Mutex{}}, nil
+}
+
+func (w *jsonlWriter) Write(record *Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func ReadJSONL(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []*Record
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		record := &Record{}
+		if err = decoder.Decode(record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,133 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	GENUINE = "genuine" //primitive of the writes done by handleIndication
+
+	TARGET_UE   = "ue"
+	TARGET_CELL = "cell"
+)
+
+// Record is the ground truth of one store write, done by an injection campaign or by handleIndication
+type Record struct {
+	Campaign     string          `json:"campaign"`  //empty for genuine writes
+	Primitive    string          `json:"primitive"` //genuine for the writes done by handleIndication
+	Target       string          `json:"target"`    //ue or cell
+	Key          string          `json:"key"`       //store key written
+	Before       json.RawMessage `json:"before"`    //entry before the write, null if the key did not exist
+	After        json.RawMessage `json:"after"`     //entry after the write, null if the key was deleted
+	Fields       []string        `json:"fields"`    //JSON field names that differ between Before and After
+	Time         time.Time       `json:"time"`
+	IndicationSN int64           `json:"indication_sn"` //sequence number of the last RIC Indication received before the write, -1 if none
+	Success      bool            `json:"success"`
+	Error        string          `json:"error,omitempty"`
+	Violations   []string        `json:"violations,omitempty"` //plausibility rules After violates, evaluated for campaign writes when validation rules are configured
+}
+
+// Writer appends records to a ledger output, Write must be safe for concurrent use
+type Writer interface {
+	Write(record *Record) error
+	Close() error
+}
+
+type Config struct {
+	JSONLFile  string //file receiving every record as a JSON line
+	SQLiteFile string //SQLite database receiving every record as a row of the ledger table
+}
+
+// LoadConfig reads the ledger configuration from the xApp environment (see appenv in the xApp descriptor)
+func LoadConfig() Config {
+	return Config{
+		JSONLFile:  os.Getenv("ledgerFile"),
+		SQLiteFile: os.Getenv("ledgerDB"),
+	}
+}
+
+func (cfg Config) Enabled() bool {
+	return cfg.JSONLFile != "" || cfg.SQLiteFile != ""
+}
+
+// Open returns a Writer on every output set in cfg, records are discarded if none is set
+func Open(cfg Config) (Writer, error) {
+	var writers multiWriter
+	if cfg.JSONLFile != "" {
+		w, err := NewJSONLWriter(cfg.JSONLFile)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if cfg.SQLiteFile != "" {
+		w, err := NewSQLiteWriter(cfg.SQLiteFile)
+		if err != nil {
+			writers.Close()
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	return writers, nil
+}
+
+// multiWriter writes every record to each of its writers and reports the first error
+type multiWriter []Writer
+
+func (m multiWriter) Write(record *Record) (err error) {
+	for _, w := range m {
+		if e := w.Write(record); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (m multiWriter) Close() (err error) {
+	for _, w := range m {
+		if e := w.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// ReadFile loads the records of a JSONL (.jsonl, .json) or SQLite (.db, .sqlite) ledger in file order
+func ReadFile(path string) ([]*Record, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return ReadJSONL(path)
+	case ".db", ".sqlite", ".sqlite3":
+		return ReadSQLite(path)
+	default:
+		return nil, errors.New("Unknown ledger file format: " + path)
+	}
+}
+
+// ChangedFields lists the top level JSON fields whose value differs between two encoded entries
+func ChangedFields(before json.RawMessage, after json.RawMessage) []string {
+	beforeFields := make(map[string]interface{})
+	afterFields := make(map[string]interface{})
+	json.Unmarshal(before, &beforeFields)
+	json.Unmarshal(after, &afterFields)
+	var names []string
+	for name, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[name], value) {
+			names = append(names, name)
+		}
+	}
+	for name := range beforeFields {
+		if _, ok := afterFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,137 @@
+package ledger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS ledger (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	campaign TEXT NOT NULL,
+	primitive TEXT NOT NULL,
+	target TEXT NOT NULL,
+	key TEXT NOT NULL,
+	before TEXT,
+	after TEXT,
+	fields TEXT,
+	time TEXT NOT NULL,
+	time_ns INTEGER NOT NULL,
+	indication_sn INTEGER NOT NULL,
+	success INTEGER NOT NULL,
+	error TEXT,
+	violations TEXT
+)`
+
+// separates the names of the fields and violations columns, as in the dataset rows
+const listSeparator = ";"
+
+const insertRecord = `INSERT INTO ledger
+	(campaign, primitive, target, key, before, after, fields, time, time_ns, indication_sn, success, error, violations)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const selectRecords = `SELECT
+	campaign, primitive, target, key, before, after, fields, time_ns, indication_sn, success, error, violations
+	FROM ledger ORDER BY id`
+
+type sqliteWriter struct {
+	db     *sql.DB
+	insert *sql.Stmt
+}
+
+// NewSQLiteWriter inserts records into the ledger table of the SQLite database at path, creating it if needed.
+// before and after hold the JSON encoded entries, fields and violations semicolon separated names.
+func NewSQLiteWriter(path string) (Writer, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	insert, err := db.Prepare(insertRecord)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteWriter{db, insert}, nil
+}
+
+func (w *sqliteWriter) Write(record *Record) error {
+	_, err := w.insert.Exec(
+		record.Campaign,
+		record.Primitive,
+		record.Target,
+		record.Key,
+		nullableJSON(record.Before),
+		nullableJSON(record.After),
+		strings.Join(record.Fields, listSeparator),
+		record.Time.UTC().Format(time.RFC3339Nano),
+		record.Time.UnixNano(),
+		record.IndicationSN,
+		record.Success,
+		record.Error,
+		strings.Join(record.Violations, listSeparator),
+	)
+	return err
+}
+
+func (w *sqliteWriter) Close() error {
+	w.insert.Close()
+	return w.db.Close()
+}
+
+func nullableJSON(value []byte) interface{} {
+	if len(value) == 0 || string(value) == "null" {
+		return nil
+	}
+	return string(value)
+}
+
+func ReadSQLite(path string) ([]*Record, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(selectRecords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []*Record
+	for rows.Next() {
+		record := &Record{}
+		var before, after, fields, errorText, violations sql.NullString
+		var timeNs int64
+		err = rows.Scan(&record.Campaign, &record.Primitive, &record.Target, &record.Key,
+			&before, &after, &fields, &timeNs, &record.IndicationSN, &record.Success, &errorText, &violations)
+		if err != nil {
+			return nil, err
+		}
+		record.Before = json.RawMessage(nullJSON(before))
+		record.After = json.RawMessage(nullJSON(after))
+		if fields.String != "" {
+			record.Fields = strings.Split(fields.String, listSeparator)
+		}
+		if violations.String != "" {
+			record.Violations = strings.Split(violations.String, listSeparator)
+		}
+		record.Time = time.Unix(0, timeNs).UTC()
+		record.Error = errorText.String
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func nullJSON(value sql.NullString) string {
+	if !value.Valid {
+		return "null"
+	}
+	return value.String
+}
@@ -0,0 +1,247 @@
+package scenario
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+)
+
+// Engine runs the campaigns of a scenario against a metrics store, each campaign in its own goroutine,
+// and records every write in a ground-truth ledger
+type Engine struct {
+	store        control.MetricsStore
+	scenario     *Scenario
+	ledger       ledger.Writer
+	indicationSN func() int64       //sequence number of the last RIC Indication received, recorded with every write
+	validator    *control.Validator //evaluates whether the written entries would have been caught, nil to skip
+	stop         chan struct{}
+	wg           *sync.WaitGroup
+	once         *sync.Once
+}
+
+func NewEngine(store control.MetricsStore, scenario *Scenario, ledger ledger.Writer, indicationSN func() int64, validator *control.Validator) *Engine {
+	return &Engine{store, scenario, ledger, indicationSN, validator, make(chan struct{}), &sync.WaitGroup{}, &sync.Once{}}
+}
+
+func (e *Engine) Start() {
+	for _, campaign := range e.scenario.Campaigns {
+		run := newCampaignRun(campaign, e)
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			run.run()
+		}()
+	}
+}
+
+// Stop interrupts every campaign and waits until their cleanup is done
+func (e *Engine) Stop() {
+	e.once.Do(func() { close(e.stop) })
+	e.wg.Wait()
+}
+
+// Wait blocks until every campaign has finished on its own
+func (e *Engine) Wait() {
+	e.wg.Wait()
+}
+
+// snapshot is the state of a key before a campaign first wrote it, used by the restore cleanup
+type snapshot struct {
+	entry interface{} //nil if the key did not exist
+}
+
+type campaignRun struct {
+	campaign  *Campaign
+	engine    *Engine
+	stop      <-chan struct{}
+	deadline  time.Time
+	primitive primitive
+	snapshots map[string]*snapshot //keys written since the last cleanup
+	written   []string             //same keys in write order
+}
+
+func newCampaignRun(campaign *Campaign, engine *Engine) *campaignRun {
+	return &campaignRun{
+		campaign:  campaign,
+		engine:    engine,
+		stop:      engine.stop,
+		primitive: newPrimitive(campaign, engine.store, newGeneratorStates(campaign)),
+		snapshots: make(map[string]*snapshot),
+	}
+}
+
+func newGeneratorStates(campaign *Campaign) map[string]*generatorState {
+	states := make(map[string]*generatorState, len(campaign.Generators))
+	for path, generator := range campaign.Generators {
+		states[path] = newGeneratorState(generator)
+	}
+	return states
+}
+
+func (r *campaignRun) run() {
+	schedule := r.campaign.Schedule
+	if schedule.Duration > 0 {
+		r.deadline = time.Now().Add(schedule.StartAfter.D() + schedule.Duration.D())
+	}
+	xapp.Logger.Info("Campaign %s started", r.campaign.ID)
+	log.Printf("Campaign %s started", r.campaign.ID)
+
+	if r.sleep(schedule.StartAfter.D()) {
+	rounds:
+		for round := 0; schedule.Rounds == 0 || round < schedule.Rounds; round++ {
+			for _, key := range r.campaign.Keys.keys() {
+				r.write(key)
+				if !r.sleep(schedule.Interval.D()) {
+					break rounds
+				}
+			}
+			if r.campaign.Cleanup.After == CLEANUP_AFTER_ROUND {
+				r.cleanup()
+			}
+			if !r.sleep(schedule.RoundPause.D()) {
+				break
+			}
+		}
+	}
+	r.cleanup()
+
+	xapp.Logger.Info("Campaign %s finished", r.campaign.ID)
+	log.Printf("Campaign %s finished", r.campaign.ID)
+}
+
+// sleep waits for d and reports whether the campaign may go on, i.e. it was neither stopped nor expired
+func (r *campaignRun) sleep(d time.Duration) bool {
+	if !r.deadline.IsZero() {
+		if remaining := time.Until(r.deadline); remaining <= 0 {
+			return false
+		} else if remaining < d {
+			d = remaining
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return r.deadline.IsZero() || time.Now().Before(r.deadline)
+	}
+}
+
+func (r *campaignRun) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *campaignRun) write(key string) {
+	indicationSN := r.engine.indicationSN()
+	change, err := r.primitive.apply(key)
+	if change == nil && err == nil {
+		return
+	}
+	if change == nil {
+		xapp.Logger.Error("Campaign %s failed to %s key [%s]: %v", r.campaign.ID, r.campaign.Primitive, key, err)
+		log.Printf("Campaign %s failed to %s key [%s]: %v", r.campaign.ID, r.campaign.Primitive, key, err)
+		change = &Change{Campaign: r.campaign.ID, Primitive: r.campaign.Primitive, Target: r.campaign.Target, Key: key, Time: time.Now()}
+	} else {
+		if _, ok := r.snapshots[change.Key]; !ok {
+			r.snapshots[change.Key] = &snapshot{change.Before}
+			r.written = append(r.written, change.Key)
+		}
+		if err != nil {
+			xapp.Logger.Error("Campaign %s failed to write key [%s]: %v", r.campaign.ID, change.Key, err)
+			log.Printf("Campaign %s failed to write key [%s]: %v", r.campaign.ID, change.Key, err)
+		} else {
+			xapp.Logger.Debug("Campaign %s: %s key [%s] changed %v", r.campaign.ID, change.Primitive, change.Key, change.Fields)
+		}
+	}
+	r.record(change, indicationSN, err)
+}
+
+// record appends a change to the ledger of the engine
+func (r *campaignRun) record(change *Change, indicationSN int64, err error) {
+	record := &ledger.Record{
+		Campaign:     change.Campaign,
+		Primitive:    change.Primitive,
+		Target:       change.Target,
+		Key:          change.Key,
+		Fields:       change.Fields,
+		Time:         change.Time,
+		IndicationSN: indicationSN,
+		Success:      err == nil,
+	}
+	record.Before, _ = json.Marshal(change.Before)
+	record.After, _ = json.Marshal(change.After)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if r.engine.validator != nil && change.After != nil && change.Primitive != PRIMITIVE_CLEANUP {
+		record.Violations = r.evaluate(change)
+	}
+	if err = r.engine.ledger.Write(record); err != nil {
+		xapp.Logger.Error("Campaign %s failed to record key [%s] in the ledger: %v", r.campaign.ID, change.Key, err)
+		log.Printf("Campaign %s failed to record key [%s] in the ledger: %v", r.campaign.ID, change.Key, err)
+	}
+}
+
+// evaluate runs the plausibility validator on a written entry and returns the names of the rules it violates
+func (r *campaignRun) evaluate(change *Change) []string {
+	violations, rejected := r.engine.validator.Evaluate(change.Target, change.After, change.Before)
+	var rules []string
+	seen := make(map[string]bool)
+	for _, violation := range violations {
+		if !seen[violation.Rule] {
+			seen[violation.Rule] = true
+			rules = append(rules, violation.Rule)
+		}
+	}
+	if rejected {
+		xapp.Logger.Debug("Campaign %s: %s of key [%s] would have been rejected by %v", r.campaign.ID, change.Primitive, change.Key, rules)
+	}
+	return rules
+}
+
+// cleanup applies the cleanup policy to the keys written since the last cleanup, recording each
+// delete or restore in the ledger under the cleanup primitive
+func (r *campaignRun) cleanup() {
+	if r.campaign.Cleanup.Policy == CLEANUP_KEEP {
+		r.snapshots = make(map[string]*snapshot)
+		r.written = nil
+		return
+	}
+	entries := &entryStore{r.engine.store, r.campaign.Target}
+	for _, key := range r.written {
+		indicationSN := r.engine.indicationSN()
+		change := &Change{Campaign: r.campaign.ID, Primitive: PRIMITIVE_CLEANUP, Target: r.campaign.Target, Key: key}
+		var err error
+		if change.Before, err = entries.load(key); err == nil {
+			if r.campaign.Cleanup.Policy == CLEANUP_RESTORE && r.snapshots[key].entry != nil {
+				change.After = r.snapshots[key].entry
+				err = entries.put(key, change.After)
+			} else {
+				err = entries.remove(key)
+			}
+		}
+		change.Fields = diffFields(change.Before, change.After)
+		change.Time = time.Now()
+		if err != nil {
+			xapp.Logger.Error("Campaign %s failed to clean up key [%s]: %v", r.campaign.ID, key, err)
+			log.Printf("Campaign %s failed to clean up key [%s]: %v", r.campaign.ID, key, err)
+		}
+		r.record(change, indicationSN, err)
+		if !r.stopped() {
+			time.Sleep(r.campaign.Cleanup.Interval.D())
+		}
+	}
+	r.snapshots = make(map[string]*snapshot)
+	r.written = nil
+}
@@ -0,0 +1,204 @@
+package scenario
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// memoryLedger keeps the records written by an engine
+type memoryLedger struct {
+	records []*ledger.Record
+	mu      sync.Mutex
+}
+
+func (l *memoryLedger) Write(record *ledger.Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+	return nil
+}
+
+func (l *memoryLedger) Close() error {
+	return nil
+}
+
+// primitives lists the key and primitive of every record, "<primitive>:<key>"
+func (l *memoryLedger) primitives() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var writes []string
+	for _, record := range l.records {
+		writes = append(writes, record.Primitive+":"+record.Key)
+	}
+	return writes
+}
+
+// runEngine runs the campaigns against store until they finish and returns their ledger
+func runEngine(t *testing.T, store control.MetricsStore, campaigns ...*Campaign) *memoryLedger {
+	t.Helper()
+	scenario := &Scenario{Campaigns: campaigns}
+	if err := scenario.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	records := &memoryLedger{}
+	e := NewEngine(store, scenario, records, func() int64 { return 42 }, nil)
+	e.Start()
+	done := make(chan struct{})
+	go func() {
+		e.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaigns did not finish")
+	}
+	return records
+}
+
+func checkWrites(t *testing.T, records *memoryLedger, want ...string) {
+	t.Helper()
+	got := records.primitives()
+	if len(got) != len(want) {
+		t.Fatalf("ledger records %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ledger records %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEngineSchedule(t *testing.T) {
+	store := control.NewMemoryMetricsStore()
+	records := runEngine(t, store, &Campaign{
+		ID:         "fake",
+		Keys:       KeyRange{From: 1, To: 2},
+		Generators: map[string]*Generator{"UE ID": {Type: GENERATOR_KEY}, "PRB-Usage-DL": {Type: GENERATOR_COUNTER, Start: 10}},
+		Schedule:   Schedule{Rounds: 2},
+	})
+	checkWrites(t, records, "inject:1", "inject:2", "inject:1", "inject:2")
+	for i, record := range records.records {
+		if record.Campaign != "fake" || record.Target != TARGET_UE || record.IndicationSN != 42 || !record.Success {
+			t.Errorf("record %d: %+v", i, record)
+		}
+	}
+	if string(records.records[0].Before) != "null" || string(records.records[2].Before) == "null" {
+		t.Errorf("first round before %s, second round before %s", records.records[0].Before, records.records[2].Before)
+	}
+	//the counter goes on across keys and rounds, the entries are kept
+	for key, prb := range map[string]int64{"1": 12, "2": 13} {
+		if ue, err := store.GetUe(key); err != nil || ue.UeID != key || ue.PRBUsageDL != prb {
+			t.Errorf("UE %s kept as %+v, %v, want PRB usage %d", key, ue, err, prb)
+		}
+	}
+}
+
+func TestEngineCleanup(t *testing.T) {
+	genuine := &control.UeMetricsEntry{UeID: "1", ServingCellID: "A", PRBUsageDL: 5}
+	for _, test := range []struct {
+		cleanup Cleanup
+		writes  []string
+		ue1     *control.UeMetricsEntry //entry of the live UE once done, nil when deleted
+	}{
+		{Cleanup{Policy: CLEANUP_RESTORE}, []string{"inject:1", "inject:2", "inject:1", "inject:2", "cleanup:1", "cleanup:2"}, genuine},
+		{Cleanup{Policy: CLEANUP_DELETE}, []string{"inject:1", "inject:2", "inject:1", "inject:2", "cleanup:1", "cleanup:2"}, nil},
+		{Cleanup{Policy: CLEANUP_RESTORE, After: CLEANUP_AFTER_ROUND}, []string{"inject:1", "inject:2", "cleanup:1", "cleanup:2", "inject:1", "inject:2", "cleanup:1", "cleanup:2"}, genuine},
+	} {
+		store := control.NewMemoryMetricsStore()
+		store.PutUe("1", genuine)
+		records := runEngine(t, store, &Campaign{
+			Keys:     KeyRange{List: []string{"1", "2"}},
+			Template: map[string]interface{}{"Serving Cell ID": "ghost", "PRB-Usage-DL": 100},
+			Schedule: Schedule{Rounds: 2},
+			Cleanup:  test.cleanup,
+		})
+		checkWrites(t, records, test.writes...)
+
+		ue, err := store.GetUe("1")
+		if test.ue1 == nil && err != control.ErrEntryNotFound {
+			t.Errorf("%+v: live UE left as %+v, %v, want deleted", test.cleanup, ue, err)
+		}
+		if test.ue1 != nil && (err != nil || ue.ServingCellID != test.ue1.ServingCellID || ue.PRBUsageDL != test.ue1.PRBUsageDL) {
+			t.Errorf("%+v: live UE left as %+v, %v, want %+v", test.cleanup, ue, err, test.ue1)
+		}
+		//the injected key did not exist before the campaign, it is deleted whatever the policy
+		if ok, _ := store.ExistsUe("2"); ok {
+			t.Errorf("%+v: injected UE left in the store", test.cleanup)
+		}
+		last := records.records[len(records.records)-1]
+		if !last.Success || string(last.After) != "null" || len(last.Fields) == 0 {
+			t.Errorf("%+v: cleanup of the injected UE recorded as %+v", test.cleanup, last)
+		}
+	}
+}
+
+func TestEngineStop(t *testing.T) {
+	store := control.NewMemoryMetricsStore()
+	scenario := &Scenario{Campaigns: []*Campaign{
+		{ID: "endless", Keys: KeyRange{List: []string{"1"}}, Schedule: Schedule{Interval: Duration(10 * time.Millisecond)}, Cleanup: Cleanup{Policy: CLEANUP_DELETE}},
+		{ID: "later", Keys: KeyRange{List: []string{"2"}}, Schedule: Schedule{StartAfter: Duration(time.Hour)}},
+	}}
+	if err := scenario.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	records := &memoryLedger{}
+	e := NewEngine(store, scenario, records, func() int64 { return -1 }, nil)
+	e.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		e.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	e.Stop()
+
+	writes := records.primitives()
+	if len(writes) < 2 || writes[len(writes)-1] != "cleanup:1" {
+		t.Fatalf("ledger records %v, want writes of key 1 then its cleanup", writes)
+	}
+	for _, write := range writes[:len(writes)-1] {
+		if write != "inject:1" {
+			t.Errorf("ledger records %v, want writes of key 1 only before the cleanup", writes)
+			break
+		}
+	}
+	if ok, _ := store.ExistsUe("1"); ok {
+		t.Error("UE of the stopped campaign not cleaned up")
+	}
+	if ok, _ := store.ExistsUe("2"); ok {
+		t.Error("campaign stopped before its start wrote")
+	}
+}
+
+func TestEngineDuration(t *testing.T) {
+	start := time.Now()
+	records := runEngine(t, control.NewMemoryMetricsStore(), &Campaign{
+		Keys:     KeyRange{List: []string{"1"}},
+		Schedule: Schedule{Interval: Duration(10 * time.Millisecond), Duration: Duration(100 * time.Millisecond)},
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("campaign of 100ms ran %v", elapsed)
+	}
+	if writes := records.primitives(); len(writes) == 0 || len(writes) > 11 {
+		t.Errorf("%d writes in 100ms at a 10ms interval", len(writes))
+	}
+}
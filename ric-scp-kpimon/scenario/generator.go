@@ -0,0 +1,80 @@
+package scenario
+
+import (
+	"errors"
+	"math/rand"
+)
+
+const (
+	GENERATOR_CONSTANT = "constant" //always Value
+	GENERATOR_SEQUENCE = "sequence" //Values in turn, wrapping around
+	GENERATOR_COUNTER  = "counter"  //Start, Start+Step, Start+2*Step, ...
+	GENERATOR_UNIFORM  = "uniform"  //random integer in [Min, Max]
+	GENERATOR_KEY      = "key"      //store key of the entry being written
+)
+
+// Generator produces the value of one entry field for every write of a campaign
+type Generator struct {
+	Type   string        `yaml:"type" json:"type"`
+	Value  interface{}   `yaml:"value" json:"value"`
+	Values []interface{} `yaml:"values" json:"values"`
+	Start  int64         `yaml:"start" json:"start"`
+	Step   int64         `yaml:"step" json:"step"`
+	Min    int64         `yaml:"min" json:"min"`
+	Max    int64         `yaml:"max" json:"max"`
+	Seed   int64         `yaml:"seed" json:"seed"`
+}
+
+func (g *Generator) validate() error {
+	switch g.Type {
+	case GENERATOR_CONSTANT, GENERATOR_KEY:
+	case GENERATOR_SEQUENCE:
+		if len(g.Values) == 0 {
+			return errors.New("sequence without values")
+		}
+	case GENERATOR_COUNTER:
+		if g.Step == 0 {
+			g.Step = 1
+		}
+	case GENERATOR_UNIFORM:
+		if g.Max < g.Min {
+			return errors.New("uniform with max < min")
+		}
+	default:
+		return errors.New("unknown generator type " + g.Type)
+	}
+	g.Value = normalizeValue(g.Value)
+	for i, value := range g.Values {
+		g.Values[i] = normalizeValue(value)
+	}
+	return nil
+}
+
+// generatorState is the per-run state of a Generator, n counts the values produced so far
+type generatorState struct {
+	generator *Generator
+	n         int64
+	rand      *rand.Rand
+}
+
+func newGeneratorState(g *Generator) *generatorState {
+	return &generatorState{g, 0, rand.New(rand.NewSource(g.Seed))}
+}
+
+func (s *generatorState) next(key string) (value interface{}) {
+	g := s.generator
+	switch g.Type {
+	case GENERATOR_CONSTANT:
+		value = g.Value
+	case GENERATOR_SEQUENCE:
+		value = g.Values[s.n%int64(len(g.Values))]
+	case GENERATOR_COUNTER:
+		value = g.Start + s.n*g.Step
+	case GENERATOR_UNIFORM:
+		value = g.Min + s.rand.Int63n(g.Max-g.Min+1)
+	case GENERATOR_KEY:
+		value = key
+	}
+	s.n++
+	return
+}
@@ -0,0 +1,437 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/ledger"
+)
+
+const (
+	PRIMITIVE_INJECT         = "inject"         //write the template to the key, whether it exists or not
+	PRIMITIVE_OVERWRITE      = "overwrite"      //merge the template into the live entry of the key
+	PRIMITIVE_SHADOW         = "shadow"         //copy the live entry of the key to a shadow key, merged with the template
+	PRIMITIVE_DELETE         = "delete"         //delete the live entry of the key
+	PRIMITIVE_REPLAY         = "replay"         //capture the live entry on the first visit, write it back on later visits
+	PRIMITIVE_DRIFT          = "drift"          //shift numeric fields of the live entry by an offset growing on every visit
+	PRIMITIVE_ZERO_NEIGHBORS = "zero_neighbors" //empty Neighbor-Cell-RF of the live UE entry
+	PRIMITIVE_CLEANUP        = "cleanup"        //delete or restore done by the cleanup policy, only found in the ledger
+)
+
+// PrimitiveParams are the primitive specific settings of a campaign
+type PrimitiveParams struct {
+	ShadowOffset int64                   `yaml:"shadow_offset" json:"shadow_offset"` //shadow key is the numeric key plus this offset
+	ShadowPrefix string                  `yaml:"shadow_prefix" json:"shadow_prefix"` //or the key with this prefix
+	Drift        map[string]*DriftParams `yaml:"drift" json:"drift"`                 //field path to drift settings
+}
+
+// DriftParams shifts a field by Step*n and scales it by Factor^n on the n-th visit of a key
+type DriftParams struct {
+	Step   float64 `yaml:"step" json:"step"`
+	Factor float64 `yaml:"factor" json:"factor"`
+}
+
+// Change records exactly what a primitive did to one store key
+type Change struct {
+	Campaign  string      `json:"campaign"`
+	Primitive string      `json:"primitive"`
+	Target    string      `json:"target"`
+	Key       string      `json:"key"`
+	Before    interface{} `json:"before"` //entry before the write, nil if the key did not exist
+	After     interface{} `json:"after"`  //entry after the write, nil if the key was deleted
+	Fields    []string    `json:"fields"` //JSON field names that differ between Before and After
+	Time      time.Time   `json:"time"`
+}
+
+type primitive interface {
+	// apply manipulates the entry behind key, a nil Change means nothing was written
+	apply(key string) (*Change, error)
+}
+
+func (c *Campaign) validatePrimitive() error {
+	switch c.Primitive {
+	case PRIMITIVE_INJECT, PRIMITIVE_OVERWRITE, PRIMITIVE_DELETE, PRIMITIVE_REPLAY:
+	case PRIMITIVE_SHADOW:
+		if c.Params.ShadowOffset == 0 && c.Params.ShadowPrefix == "" {
+			return errors.New("shadow without shadow_offset or shadow_prefix")
+		}
+	case PRIMITIVE_DRIFT:
+		if len(c.Params.Drift) == 0 {
+			return errors.New("drift without fields")
+		}
+		for path, drift := range c.Params.Drift {
+			if drift.Factor == 0 {
+				drift.Factor = 1
+			}
+			if drift.Step == 0 && drift.Factor == 1 {
+				return errors.New("drift of " + path + " without step or factor")
+			}
+		}
+	case PRIMITIVE_ZERO_NEIGHBORS:
+		if c.Target != TARGET_UE {
+			return errors.New("zero_neighbors only applies to ue entries")
+		}
+	default:
+		return errors.New("unknown primitive " + c.Primitive)
+	}
+	return nil
+}
+
+func newPrimitive(campaign *Campaign, store control.MetricsStore, generators map[string]*generatorState) primitive {
+	entries := &entryStore{store, campaign.Target}
+	base := basePrimitive{campaign, entries, generators}
+	switch campaign.Primitive {
+	case PRIMITIVE_OVERWRITE:
+		return &overwritePrimitive{base}
+	case PRIMITIVE_SHADOW:
+		return &shadowPrimitive{base}
+	case PRIMITIVE_DELETE:
+		return &deletePrimitive{base}
+	case PRIMITIVE_REPLAY:
+		return &replayPrimitive{base, make(map[string]interface{})}
+	case PRIMITIVE_DRIFT:
+		return &driftPrimitive{base, make(map[string]*driftState)}
+	case PRIMITIVE_ZERO_NEIGHBORS:
+		return &zeroNeighborsPrimitive{base}
+	default:
+		return &injectPrimitive{base}
+	}
+}
+
+type basePrimitive struct {
+	campaign   *Campaign
+	entries    *entryStore
+	generators map[string]*generatorState
+}
+
+// write stores after under key and records the change against before
+func (p *basePrimitive) write(key string, before interface{}, after interface{}) (*Change, error) {
+	var err error
+	if after == nil {
+		err = p.entries.remove(key)
+	} else {
+		err = p.entries.put(key, after)
+	}
+	change := &Change{
+		Campaign:  p.campaign.ID,
+		Primitive: p.campaign.Primitive,
+		Target:    p.campaign.Target,
+		Key:       key,
+		Before:    before,
+		After:     after,
+		Fields:    diffFields(before, after),
+		Time:      time.Now(),
+	}
+	return change, err
+}
+
+// live loads the entry behind key and fails if there is none
+func (p *basePrimitive) live(key string) (interface{}, error) {
+	entry, err := p.entries.load(key)
+	if err == nil && entry == nil {
+		err = errors.New("no live entry for key " + key)
+	}
+	return entry, err
+}
+
+// merge applies the template and the generators on top of the fields of entry (nil for an empty entry)
+func (p *basePrimitive) merge(entry interface{}, key string) (interface{}, error) {
+	fields, err := toFields(entry)
+	if err != nil {
+		return nil, err
+	}
+	template, err := toFields(p.campaign.Template)
+	if err != nil {
+		return nil, err
+	}
+	mergeFields(fields, template)
+	for path, generator := range p.generators {
+		if err = setField(fields, path, generator.next(key)); err != nil {
+			return nil, err
+		}
+	}
+	return p.entries.decode(fields)
+}
+
+type injectPrimitive struct {
+	basePrimitive
+}
+
+func (p *injectPrimitive) apply(key string) (*Change, error) {
+	before, err := p.entries.load(key)
+	if err != nil {
+		return nil, err
+	}
+	after, err := p.merge(nil, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.write(key, before, after)
+}
+
+type overwritePrimitive struct {
+	basePrimitive
+}
+
+func (p *overwritePrimitive) apply(key string) (*Change, error) {
+	before, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	after, err := p.merge(before, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.write(key, before, after)
+}
+
+type shadowPrimitive struct {
+	basePrimitive
+}
+
+func (p *shadowPrimitive) apply(key string) (*Change, error) {
+	source, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	shadowKey := p.campaign.Params.ShadowPrefix + key
+	if p.campaign.Params.ShadowOffset != 0 {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, errors.New("shadow_offset needs numeric keys, got " + key)
+		}
+		shadowKey = p.campaign.Params.ShadowPrefix + strconv.FormatInt(id+p.campaign.Params.ShadowOffset, 10)
+	}
+	before, err := p.entries.load(shadowKey)
+	if err != nil {
+		return nil, err
+	}
+	after, err := p.merge(source, shadowKey)
+	if err != nil {
+		return nil, err
+	}
+	return p.write(shadowKey, before, after)
+}
+
+type deletePrimitive struct {
+	basePrimitive
+}
+
+func (p *deletePrimitive) apply(key string) (*Change, error) {
+	before, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	return p.write(key, before, nil)
+}
+
+type replayPrimitive struct {
+	basePrimitive
+	captured map[string]interface{} //key to the entry captured on its first visit
+}
+
+func (p *replayPrimitive) apply(key string) (*Change, error) {
+	before, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	stale, ok := p.captured[key]
+	if !ok {
+		p.captured[key] = before
+		return nil, nil
+	}
+	return p.write(key, before, stale)
+}
+
+type driftPrimitive struct {
+	basePrimitive
+	visits map[string]*driftState //key to the drift applied so far
+}
+
+// driftState remembers the genuine value under each drifted field, so that the drift does not
+// compound when no indication has refreshed the entry between two visits
+type driftState struct {
+	n       int
+	base    map[string]float64 //field path to genuine value
+	written map[string]float64 //field path to drifted value last written
+}
+
+func (p *driftPrimitive) apply(key string) (*Change, error) {
+	before, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	state, ok := p.visits[key]
+	if !ok {
+		state = &driftState{0, make(map[string]float64), make(map[string]float64)}
+		p.visits[key] = state
+	}
+	state.n++
+	n := float64(state.n)
+	for path, drift := range p.campaign.Params.Drift {
+		value, ok := getField(fields, path).(float64)
+		if !ok {
+			return nil, errors.New("drift field " + path + " is not numeric")
+		}
+		if written, ok := state.written[path]; !ok || written != value {
+			state.base[path] = value
+		}
+		value = math.Round(state.base[path]*math.Pow(drift.Factor, n) + drift.Step*n)
+		state.written[path] = value
+		if err = setField(fields, path, value); err != nil {
+			return nil, err
+		}
+	}
+	after, err := p.entries.decode(fields)
+	if err != nil {
+		return nil, err
+	}
+	return p.write(key, before, after)
+}
+
+type zeroNeighborsPrimitive struct {
+	basePrimitive
+}
+
+func (p *zeroNeighborsPrimitive) apply(key string) (*Change, error) {
+	before, err := p.live(key)
+	if err != nil {
+		return nil, err
+	}
+	ueMetrics := *before.(*control.UeMetricsEntry)
+	ueMetrics.NeighborCellsRF = []control.NeighborCellRFType{}
+	return p.write(key, before, &ueMetrics)
+}
+
+// entryStore reads and writes the entries of one campaign target as *UeMetricsEntry or *CellMetricsEntry
+type entryStore struct {
+	store  control.MetricsStore
+	target string
+}
+
+// load returns nil without error when the key does not exist
+func (s *entryStore) load(key string) (entry interface{}, err error) {
+	if s.target == TARGET_UE {
+		entry, err = s.store.GetUe(key)
+	} else {
+		entry, err = s.store.GetCell(key)
+	}
+	if err == control.ErrEntryNotFound {
+		return nil, nil
+	}
+	return
+}
+
+func (s *entryStore) put(key string, entry interface{}) error {
+	switch e := entry.(type) {
+	case *control.UeMetricsEntry:
+		return s.store.PutUe(key, e)
+	case *control.CellMetricsEntry:
+		return s.store.PutCell(key, e)
+	default:
+		return errors.New("unexpected entry type")
+	}
+}
+
+func (s *entryStore) remove(key string) error {
+	if s.target == TARGET_UE {
+		return s.store.DeleteUe(key)
+	}
+	return s.store.DeleteCell(key)
+}
+
+// decode turns generic fields into the entry type of the target, rejecting fields unknown to the schema
+func (s *entryStore) decode(fields map[string]interface{}) (interface{}, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var entry interface{}
+	if s.target == TARGET_UE {
+		entry = &control.UeMetricsEntry{}
+	} else {
+		entry = &control.CellMetricsEntry{}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// toFields converts an entry (or any JSON encodable value) to its generic JSON object form
+func toFields(value interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if value == nil || reflect.ValueOf(value).IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// mergeFields copies src into dst, merging nested objects instead of replacing them
+func mergeFields(dst map[string]interface{}, src map[string]interface{}) {
+	for name, value := range src {
+		srcObject, srcOk := value.(map[string]interface{})
+		dstObject, dstOk := dst[name].(map[string]interface{})
+		if srcOk && dstOk {
+			mergeFields(dstObject, srcObject)
+		} else {
+			dst[name] = value
+		}
+	}
+}
+
+func getField(fields map[string]interface{}, path string) interface{} {
+	var value interface{} = fields
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// setField sets a dotted field path such as Meas-Timestamp-PRB.tv_sec, creating intermediate objects
+func setField(fields map[string]interface{}, path string, value interface{}) error {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		next, ok := fields[name]
+		if !ok {
+			next = make(map[string]interface{})
+			fields[name] = next
+		}
+		object, ok := next.(map[string]interface{})
+		if !ok {
+			return errors.New("field " + name + " of " + path + " is not an object")
+		}
+		fields = object
+	}
+	fields[names[len(names)-1]] = value
+	return nil
+}
+
+// diffFields lists the top level JSON fields whose value differs between two entries
+func diffFields(before interface{}, after interface{}) []string {
+	beforeData, _ := json.Marshal(before)
+	afterData, _ := json.Marshal(after)
+	return ledger.ChangedFields(beforeData, afterData)
+}
@@ -0,0 +1,217 @@
+package scenario
+
+import (
+	"reflect"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/scp/ric-app/kpimon/control"
+)
+
+// liveUe is the entry handleIndication would have written for UE 1
+func liveUe() *control.UeMetricsEntry {
+	return &control.UeMetricsEntry{
+		UeID:             "1",
+		ServingCellID:    "A",
+		MeasTimestampPRB: control.Timestamp{TVsec: 100, TVnsec: 200},
+		PRBUsageDL:       10,
+		PDCPBytesDL:      100,
+		ServingCellRF:    control.CellRFType{RSRP: -90},
+		NeighborCellsRF:  []control.NeighborCellRFType{{CellID: "B", CellRF: control.CellRFType{RSRP: -100}}},
+	}
+}
+
+// ueFields are the JSON field names of a UE entry, all listed when it is written to a free key
+var ueFields = []string{"Meas-Time-RF", "Meas-Timestamp-PDCP-Bytes", "Meas-Timestamp-PRB", "Neighbor-Cell-RF", "PDCP-Bytes-DL",
+	"PDCP-Bytes-UL", "PRB-Usage-DL", "PRB-Usage-UL", "Serving Cell ID", "Serving-Cell-RF", "UE ID"}
+
+// primitiveOn validates campaign and returns its primitive on a memory store holding liveUe under key 1
+func primitiveOn(t *testing.T, campaign *Campaign) (primitive, control.MetricsStore) {
+	t.Helper()
+	if campaign.Keys.List == nil {
+		campaign.Keys.List = []string{"1"}
+	}
+	if err := (&Scenario{Campaigns: []*Campaign{campaign}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	store := control.NewMemoryMetricsStore()
+	if err := store.PutUe("1", liveUe()); err != nil {
+		t.Fatal(err)
+	}
+	return newPrimitive(campaign, store, newGeneratorStates(campaign)), store
+}
+
+// applyTo applies p to key and checks the change against the store
+func applyTo(t *testing.T, p primitive, store control.MetricsStore, key string, fields []string) *Change {
+	t.Helper()
+	change, err := p.apply(key)
+	if err != nil || change == nil {
+		t.Fatalf("apply to key %s returned %+v, %v", key, change, err)
+	}
+	if !reflect.DeepEqual(change.Fields, fields) {
+		t.Errorf("key %s changed fields %v, want %v", change.Key, change.Fields, fields)
+	}
+	stored, err := store.GetUe(change.Key)
+	if change.After == nil && err != control.ErrEntryNotFound {
+		t.Errorf("key %s holds %+v, %v after a delete", change.Key, stored, err)
+	}
+	if change.After != nil && (err != nil || !reflect.DeepEqual(stored, change.After)) {
+		t.Errorf("key %s holds %+v, %v, want the change %+v", change.Key, stored, err, change.After)
+	}
+	return change
+}
+
+func TestInject(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{Template: map[string]interface{}{"Serving Cell ID": "A", "PRB-Usage-DL": 50}})
+
+	//the template replaces the live entry instead of being merged into it
+	change := applyTo(t, p, store, "1", []string{"Meas-Timestamp-PRB", "Neighbor-Cell-RF", "PDCP-Bytes-DL", "PRB-Usage-DL", "Serving-Cell-RF", "UE ID"})
+	if !reflect.DeepEqual(change.Before, liveUe()) {
+		t.Errorf("before %+v, want the live entry", change.Before)
+	}
+	//a free key gets every field of the template entry but the neighbours it has none of
+	change = applyTo(t, p, store, "2", []string{"Meas-Time-RF", "Meas-Timestamp-PDCP-Bytes", "Meas-Timestamp-PRB", "PDCP-Bytes-DL",
+		"PDCP-Bytes-UL", "PRB-Usage-DL", "PRB-Usage-UL", "Serving Cell ID", "Serving-Cell-RF", "UE ID"})
+	if change.Before != nil {
+		t.Errorf("before %+v, want nil for a free key", change.Before)
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{
+		Primitive: PRIMITIVE_OVERWRITE,
+		Template:  map[string]interface{}{"Serving Cell ID": "ghost", "Meas-Timestamp-PRB": map[string]interface{}{"tv_sec": 999}},
+	})
+	change := applyTo(t, p, store, "1", []string{"Meas-Timestamp-PRB", "Serving Cell ID"})
+
+	want := liveUe()
+	want.ServingCellID = "ghost"
+	want.MeasTimestampPRB.TVsec = 999 //tv_nsec is kept, nested objects are merged
+	if !reflect.DeepEqual(change.After, want) {
+		t.Errorf("overwritten entry %+v, want %+v", change.After, want)
+	}
+	if change.Campaign != "campaign-0" || change.Primitive != PRIMITIVE_OVERWRITE || change.Target != TARGET_UE || change.Time.IsZero() {
+		t.Errorf("change %+v", change)
+	}
+
+	//a second visit changes nothing
+	applyTo(t, p, store, "1", nil)
+	if change, err := p.apply("2"); change != nil || err == nil {
+		t.Errorf("overwrite of a free key returned %+v, %v", change, err)
+	}
+}
+
+func TestShadow(t *testing.T) {
+	for _, test := range []struct {
+		params PrimitiveParams
+		key    string
+	}{
+		{PrimitiveParams{ShadowOffset: 1000}, "1001"},
+		{PrimitiveParams{ShadowPrefix: "shadow-"}, "shadow-1"},
+		{PrimitiveParams{ShadowOffset: -1, ShadowPrefix: "x"}, "x0"},
+	} {
+		p, store := primitiveOn(t, &Campaign{
+			Primitive:  PRIMITIVE_SHADOW,
+			Params:     test.params,
+			Template:   map[string]interface{}{"Serving Cell ID": "ghost"},
+			Generators: map[string]*Generator{"UE ID": {Type: GENERATOR_KEY}},
+		})
+		change := applyTo(t, p, store, "1", ueFields)
+		if change.Key != test.key {
+			t.Errorf("%+v: shadow key %s, want %s", test.params, change.Key, test.key)
+		}
+		want := liveUe()
+		want.UeID = test.key
+		want.ServingCellID = "ghost"
+		if !reflect.DeepEqual(change.After, want) {
+			t.Errorf("%+v: shadow entry %+v, want %+v", test.params, change.After, want)
+		}
+		if ue, _ := store.GetUe("1"); !reflect.DeepEqual(ue, liveUe()) {
+			t.Errorf("%+v: source entry changed to %+v", test.params, ue)
+		}
+	}
+
+	p, store := primitiveOn(t, &Campaign{Primitive: PRIMITIVE_SHADOW, Params: PrimitiveParams{ShadowOffset: 1}})
+	store.PutUe("ue-a", liveUe())
+	if change, err := p.apply("ue-a"); change != nil || err == nil {
+		t.Errorf("shadow_offset of a non numeric key returned %+v, %v", change, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{Primitive: PRIMITIVE_DELETE})
+	change := applyTo(t, p, store, "1", ueFields)
+	if !reflect.DeepEqual(change.Before, liveUe()) || change.After != nil {
+		t.Errorf("delete changed %+v to %+v", change.Before, change.After)
+	}
+	if change, err := p.apply("1"); change != nil || err == nil {
+		t.Errorf("delete of a deleted key returned %+v, %v", change, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{Primitive: PRIMITIVE_REPLAY})
+
+	//the first visit only captures the live entry
+	if change, err := p.apply("1"); change != nil || err != nil {
+		t.Fatalf("capture returned %+v, %v", change, err)
+	}
+	fresh := liveUe()
+	fresh.PRBUsageDL = 60
+	fresh.MeasTimestampPRB.TVsec = 160
+	store.PutUe("1", fresh)
+
+	change := applyTo(t, p, store, "1", []string{"Meas-Timestamp-PRB", "PRB-Usage-DL"})
+	if !reflect.DeepEqual(change.Before, fresh) || !reflect.DeepEqual(change.After, liveUe()) {
+		t.Errorf("replay changed %+v to %+v, want the captured entry", change.Before, change.After)
+	}
+	//the captured entry is written back on every later visit
+	store.PutUe("1", fresh)
+	applyTo(t, p, store, "1", []string{"Meas-Timestamp-PRB", "PRB-Usage-DL"})
+}
+
+func TestDrift(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{Primitive: PRIMITIVE_DRIFT, Params: PrimitiveParams{Drift: map[string]*DriftParams{
+		"PRB-Usage-DL":              {Step: 2},
+		"PDCP-Bytes-DL":             {Factor: 1.5},
+		"Meas-Timestamp-PRB.tv_sec": {Step: -1},
+	}}})
+
+	for i, want := range []struct {
+		refresh          int64 //genuine PRB usage written before the visit, 0 for none
+		prb, pdcp, tvSec int64
+	}{
+		{0, 12, 150, 99},
+		{0, 14, 225, 98}, //the drift of the second visit applies to the genuine values, not to the drifted ones
+		{20, 26, 338, 97},
+	} {
+		if want.refresh != 0 {
+			fresh := liveUe()
+			fresh.PRBUsageDL = want.refresh
+			store.PutUe("1", fresh)
+		}
+		change := applyTo(t, p, store, "1", []string{"Meas-Timestamp-PRB", "PDCP-Bytes-DL", "PRB-Usage-DL"})
+		after := change.After.(*control.UeMetricsEntry)
+		if after.PRBUsageDL != want.prb || after.PDCPBytesDL != want.pdcp || after.MeasTimestampPRB.TVsec != want.tvSec {
+			t.Errorf("visit %d: PRB usage %d, PDCP bytes %d, tv_sec %d, want %d, %d and %d", i+1,
+				after.PRBUsageDL, after.PDCPBytesDL, after.MeasTimestampPRB.TVsec, want.prb, want.pdcp, want.tvSec)
+		}
+	}
+
+	p, _ = primitiveOn(t, &Campaign{Primitive: PRIMITIVE_DRIFT, Params: PrimitiveParams{Drift: map[string]*DriftParams{"Serving Cell ID": {Step: 1}}}})
+	if change, err := p.apply("1"); change != nil || err == nil {
+		t.Errorf("drift of a string field returned %+v, %v", change, err)
+	}
+}
+
+func TestZeroNeighbors(t *testing.T) {
+	p, store := primitiveOn(t, &Campaign{Primitive: PRIMITIVE_ZERO_NEIGHBORS})
+	change := applyTo(t, p, store, "1", []string{"Neighbor-Cell-RF"})
+	want := liveUe()
+	want.NeighborCellsRF = []control.NeighborCellRFType{}
+	if !reflect.DeepEqual(change.After, want) {
+		t.Errorf("entry without neighbours %+v, want %+v", change.After, want)
+	}
+	if !reflect.DeepEqual(change.Before, liveUe()) {
+		t.Errorf("before %+v, want the live entry", change.Before)
+	}
+}
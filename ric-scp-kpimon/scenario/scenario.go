@@ -0,0 +1,231 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	TARGET_UE   = "ue"
+	TARGET_CELL = "cell"
+
+	CLEANUP_KEEP    = "keep"
+	CLEANUP_DELETE  = "delete"
+	CLEANUP_RESTORE = "restore"
+
+	CLEANUP_AFTER_ROUND = "round"
+	CLEANUP_AFTER_END   = "end"
+)
+
+// Scenario is the content of a scenario file, a set of injection campaigns run side by side
+type Scenario struct {
+	Campaigns []*Campaign `yaml:"campaigns" json:"campaigns"`
+}
+
+// Campaign describes which entries are written, with which primitive and values and on which schedule
+type Campaign struct {
+	ID         string                 `yaml:"id" json:"id"`
+	Target     string                 `yaml:"target" json:"target"`         //ue or cell
+	Primitive  string                 `yaml:"primitive" json:"primitive"`   //inject (default), overwrite, shadow, delete, replay, drift or zero_neighbors
+	Params     PrimitiveParams        `yaml:"params" json:"params"`         //primitive specific settings
+	Keys       KeyRange               `yaml:"keys" json:"keys"`             //store keys written by the campaign
+	Template   map[string]interface{} `yaml:"template" json:"template"`     //initial field values, by JSON field name of the entry
+	Generators map[string]*Generator  `yaml:"generators" json:"generators"` //field path (e.g. Meas-Timestamp-PRB.tv_sec) to value generator
+	Schedule   Schedule               `yaml:"schedule" json:"schedule"`
+	Cleanup    Cleanup                `yaml:"cleanup" json:"cleanup"`
+}
+
+// KeyRange is either an explicit key list or a numeric range [From, To] of UE IDs
+type KeyRange struct {
+	List []string `yaml:"list" json:"list"`
+	From int64    `yaml:"from" json:"from"`
+	To   int64    `yaml:"to" json:"to"`
+}
+
+type Schedule struct {
+	StartAfter Duration `yaml:"start_after" json:"start_after"` //delay before the first write
+	Interval   Duration `yaml:"interval" json:"interval"`       //delay between two writes
+	RoundPause Duration `yaml:"round_pause" json:"round_pause"` //delay between two rounds over the key range
+	Rounds     int      `yaml:"rounds" json:"rounds"`           //number of rounds, 0 runs until Duration elapses or the engine stops
+	Duration   Duration `yaml:"duration" json:"duration"`       //maximum run time, 0 for no limit
+}
+
+type Cleanup struct {
+	Policy   string   `yaml:"policy" json:"policy"`     //keep, delete or restore
+	After    string   `yaml:"after" json:"after"`       //round or end
+	Interval Duration `yaml:"interval" json:"interval"` //delay between two cleanup operations
+}
+
+// Duration accepts Go duration strings ("2s", "1m30s") in scenario files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// LoadFile reads a scenario from a YAML (.yaml, .yml) or JSON (.json) file
+func LoadFile(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario := &Scenario{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, scenario)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, scenario)
+	default:
+		return nil, errors.New("Unknown scenario file format: " + path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario file %s: %v", path, err)
+	}
+	if err = scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func (s *Scenario) Validate() error {
+	ids := make(map[string]bool)
+	for i, campaign := range s.Campaigns {
+		if campaign.ID == "" {
+			campaign.ID = "campaign-" + strconv.Itoa(i)
+		}
+		if ids[campaign.ID] {
+			return errors.New("Duplicate campaign id: " + campaign.ID)
+		}
+		ids[campaign.ID] = true
+		if err := campaign.validate(); err != nil {
+			return fmt.Errorf("campaign %s: %v", campaign.ID, err)
+		}
+	}
+	return nil
+}
+
+func (c *Campaign) validate() error {
+	if c.Target == "" {
+		c.Target = TARGET_UE
+	}
+	if c.Target != TARGET_UE && c.Target != TARGET_CELL {
+		return errors.New("unknown target " + c.Target)
+	}
+	if len(c.Keys.keys()) == 0 {
+		return errors.New("empty key range")
+	}
+	if c.Primitive == "" {
+		c.Primitive = PRIMITIVE_INJECT
+	}
+	if err := c.validatePrimitive(); err != nil {
+		return err
+	}
+	if c.Cleanup.Policy == "" {
+		c.Cleanup.Policy = CLEANUP_KEEP
+	}
+	if c.Cleanup.Policy != CLEANUP_KEEP && c.Cleanup.Policy != CLEANUP_DELETE && c.Cleanup.Policy != CLEANUP_RESTORE {
+		return errors.New("unknown cleanup policy " + c.Cleanup.Policy)
+	}
+	if c.Cleanup.After == "" {
+		c.Cleanup.After = CLEANUP_AFTER_END
+	}
+	if c.Cleanup.After != CLEANUP_AFTER_ROUND && c.Cleanup.After != CLEANUP_AFTER_END {
+		return errors.New("unknown cleanup time " + c.Cleanup.After)
+	}
+	c.Template = normalize(c.Template).(map[string]interface{})
+	for path, generator := range c.Generators {
+		if err := generator.validate(); err != nil {
+			return fmt.Errorf("generator %s: %v", path, err)
+		}
+	}
+	base := basePrimitive{c, &entryStore{nil, c.Target}, newGeneratorStates(c)}
+	if _, err := base.merge(nil, c.Keys.keys()[0]); err != nil {
+		return fmt.Errorf("template does not match the %s entry schema: %v", c.Target, err)
+	}
+	return nil
+}
+
+func (k KeyRange) keys() []string {
+	if len(k.List) > 0 {
+		return k.List
+	}
+	var keys []string
+	for id := k.From; id <= k.To && k.To != 0; id++ {
+		keys = append(keys, strconv.FormatInt(id, 10))
+	}
+	return keys
+}
+
+// normalize turns the map[interface{}]interface{} produced by yaml into JSON encodable values
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalizeValue(item)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		return normalize(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
@@ -0,0 +1,147 @@
+package scenario
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScenario writes content to a file of name in a temporary directory and returns its path
+func writeScenario(t *testing.T, name string, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "scenario")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileDefaults(t *testing.T) {
+	for name, content := range map[string]string{
+		"defaults.yaml": `
+campaigns:
+  - keys:
+      from: 1001
+      to: 1003
+    template:
+      Serving Cell ID: A
+      Meas-Timestamp-PRB:
+        tv_sec: 109
+    schedule:
+      interval: 2s
+      round_pause: 1m30s
+`,
+		"defaults.json": `{"campaigns": [{"keys": {"from": 1001, "to": 1003},
+	"template": {"Serving Cell ID": "A", "Meas-Timestamp-PRB": {"tv_sec": 109}},
+	"schedule": {"interval": "2s", "round_pause": "1m30s"}}]}`,
+	} {
+		scenario, err := LoadFile(writeScenario(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(scenario.Campaigns) != 1 {
+			t.Fatalf("%s: %d campaigns, want 1", name, len(scenario.Campaigns))
+		}
+		c := scenario.Campaigns[0]
+		if c.ID != "campaign-0" || c.Target != TARGET_UE || c.Primitive != PRIMITIVE_INJECT {
+			t.Errorf("%s: campaign %s of target %s and primitive %s, want the defaults", name, c.ID, c.Target, c.Primitive)
+		}
+		if c.Cleanup.Policy != CLEANUP_KEEP || c.Cleanup.After != CLEANUP_AFTER_END {
+			t.Errorf("%s: cleanup %+v, want keep at the end", name, c.Cleanup)
+		}
+		if c.Schedule.Interval.D() != 2*time.Second || c.Schedule.RoundPause.D() != 90*time.Second || c.Schedule.Rounds != 0 {
+			t.Errorf("%s: schedule %+v", name, c.Schedule)
+		}
+		if keys := c.Keys.keys(); !reflect.DeepEqual(keys, []string{"1001", "1002", "1003"}) {
+			t.Errorf("%s: keys %v", name, keys)
+		}
+		//nested template objects are JSON objects whatever the file format
+		if prb, ok := c.Template["Meas-Timestamp-PRB"].(map[string]interface{}); !ok || prb["tv_sec"] == nil {
+			t.Errorf("%s: template %#v", name, c.Template)
+		}
+	}
+}
+
+func TestLoadFileScenarios(t *testing.T) {
+	paths, err := filepath.Glob("../scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenario found (%v)", err)
+	}
+	for _, path := range paths {
+		if _, err := LoadFile(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.txt":        `campaigns: []`,
+		"syntax.json":        `{"campaigns": [`,
+		"field.yaml":         "campaigns:\n  - keys: {list: [\"1\"]}\n    targte: cell\n",
+		"duration.yaml":      "campaigns:\n  - keys: {list: [\"1\"]}\n    schedule: {interval: often}\n",
+		"template.json":      `{"campaigns": [{"keys": {"list": ["1"]}, "template": {"PRB-Usage-DLL": 1}}]}`,
+		"template_type.yaml": "campaigns:\n  - keys: {list: [\"1\"]}\n    template: {PRB-Usage-DL: high}\n",
+	} {
+		if _, err := LoadFile(writeScenario(t, name, content)); err == nil {
+			t.Errorf("%s loaded", name)
+		}
+	}
+	if _, err := LoadFile("/nonexistent/scenario.yaml"); err == nil {
+		t.Error("missing scenario file loaded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	keys := KeyRange{List: []string{"1"}}
+	for _, test := range []struct {
+		campaign *Campaign
+		err      string
+	}{
+		{&Campaign{Target: "gnb", Keys: keys}, "unknown target gnb"},
+		{&Campaign{}, "empty key range"},
+		{&Campaign{Keys: KeyRange{From: 5, To: 4}}, "empty key range"},
+		{&Campaign{Keys: keys, Primitive: "swap"}, "unknown primitive swap"},
+		{&Campaign{Keys: keys, Primitive: PRIMITIVE_SHADOW}, "shadow without shadow_offset or shadow_prefix"},
+		{&Campaign{Keys: keys, Primitive: PRIMITIVE_DRIFT}, "drift without fields"},
+		{&Campaign{Keys: keys, Primitive: PRIMITIVE_DRIFT, Params: PrimitiveParams{Drift: map[string]*DriftParams{"PRB-Usage-DL": {}}}}, "drift of PRB-Usage-DL without step or factor"},
+		{&Campaign{Keys: keys, Primitive: PRIMITIVE_ZERO_NEIGHBORS, Target: TARGET_CELL}, "zero_neighbors only applies to ue entries"},
+		{&Campaign{Keys: keys, Cleanup: Cleanup{Policy: "wipe"}}, "unknown cleanup policy wipe"},
+		{&Campaign{Keys: keys, Cleanup: Cleanup{After: "write"}}, "unknown cleanup time write"},
+		{&Campaign{Keys: keys, Generators: map[string]*Generator{"UE ID": {Type: "random"}}}, "generator UE ID: unknown generator type random"},
+		{&Campaign{Keys: keys, Generators: map[string]*Generator{"UE ID": {Type: GENERATOR_SEQUENCE}}}, "generator UE ID: sequence without values"},
+		{&Campaign{Keys: keys, Generators: map[string]*Generator{"PRB-Usage-DL": {Type: GENERATOR_UNIFORM, Min: 2, Max: 1}}}, "generator PRB-Usage-DL: uniform with max < min"},
+		{&Campaign{Keys: keys, Target: TARGET_CELL, Template: map[string]interface{}{"UE ID": "1"}}, "template does not match the cell entry schema"},
+		{&Campaign{Keys: keys, Generators: map[string]*Generator{"Serving Cell ID.id": {Type: GENERATOR_KEY}}}, "template does not match the ue entry schema"},
+	} {
+		err := (&Scenario{Campaigns: []*Campaign{test.campaign}}).Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), "campaign campaign-0: "+test.err) {
+			t.Errorf("campaign %+v: %v, want %q", test.campaign, err, test.err)
+		}
+	}
+
+	scenario := &Scenario{Campaigns: []*Campaign{{ID: "a", Keys: keys}, {Keys: keys}, {ID: "a", Keys: keys}}}
+	if err := scenario.Validate(); err == nil || err.Error() != "Duplicate campaign id: a" {
+		t.Errorf("duplicate campaign ids: %v", err)
+	}
+	if scenario.Campaigns[1].ID != "campaign-1" {
+		t.Errorf("campaign without id named %s, want campaign-1", scenario.Campaigns[1].ID)
+	}
+
+	drift := &Campaign{Keys: keys, Primitive: PRIMITIVE_DRIFT, Params: PrimitiveParams{Drift: map[string]*DriftParams{"PRB-Usage-DL": {Step: 2}}}}
+	if err := (&Scenario{Campaigns: []*Campaign{drift}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if factor := drift.Params.Drift["PRB-Usage-DL"].Factor; factor != 1 {
+		t.Errorf("drift without factor scaled by %v, want 1", factor)
+	}
+}